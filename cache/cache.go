@@ -7,6 +7,7 @@
 //   - Metrics for hit/miss ratios
 //   - Automatic warming for recently accessed mailboxes
 //   - Content deduplication at read level
+//   - Optional zstd compression of cached objects
 //
 // # Cache Architecture
 //
@@ -82,6 +83,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/migadu/sora/helpers"
 	"github.com/migadu/sora/logger"
 	"github.com/migadu/sora/pkg/metrics"
 	_ "modernc.org/sqlite"
@@ -122,6 +124,17 @@ type Cache struct {
 
 	accessLog chan string
 	stopChan  chan struct{}
+
+	// compress stores new objects zstd-compressed; see EnableCompression.
+	compress bool
+}
+
+// EnableCompression stores objects written from now on zstd-compressed, so
+// the same disk holds more messages. Compressed objects are flagged in the
+// index and Get decompresses only those, so existing uncompressed entries stay
+// valid. Call it before the cache is used.
+func (c *Cache) EnableCompression() {
+	c.compress = true
 }
 
 func (c *Cache) getShard(contentHash string) *cacheShard {
@@ -209,13 +222,18 @@ func New(basePath string, maxSizeBytes int64, maxObjectSize int64, purgeInterval
 			CREATE TABLE IF NOT EXISTS cache_index (
 				path TEXT PRIMARY KEY,
 				size INTEGER NOT NULL,
-				mod_time DATETIME NOT NULL
+				mod_time DATETIME NOT NULL,
+				compressed INTEGER NOT NULL DEFAULT 0
 			);
 			CREATE INDEX IF NOT EXISTS idx_mod_time ON cache_index(mod_time);
 		`); err != nil {
 			db.Close()
 			return nil, fmt.Errorf("failed to initialize cache index schema for shard %s: %w", shardHex, err)
 		}
+		if err := migrateCompressedColumn(db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate cache index schema for shard %s: %w", shardHex, err)
+		}
 
 		c.shards[i] = &cacheShard{db: db}
 	}
@@ -225,6 +243,20 @@ func New(basePath string, maxSizeBytes int64, maxObjectSize int64, purgeInterval
 	return c, nil
 }
 
+// migrateCompressedColumn adds the compressed flag to indexes created before
+// it existed. Their entries were all written uncompressed.
+func migrateCompressedColumn(db *sql.DB) error {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('cache_index') WHERE name = 'compressed'`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.Exec(`ALTER TABLE cache_index ADD COLUMN compressed INTEGER NOT NULL DEFAULT 0`)
+	return err
+}
+
 func (c *Cache) processAccessLog() {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
@@ -319,15 +351,16 @@ func (c *Cache) Delete(contentHash string) error {
 	path := c.GetPathForContentHash(contentHash)
 	shard := c.getShard(contentHash)
 
+	shard.mu.Lock()
 	err := os.Remove(path)
 	if err != nil && !os.IsNotExist(err) {
+		shard.mu.Unlock()
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
 	dataDir := filepath.Join(c.basePath, DataDir)
 	removeEmptyParents(path, dataDir)
 
-	shard.mu.Lock()
 	_, dbErr := shard.db.Exec(`DELETE FROM cache_index WHERE path = ?`, path)
 	shard.mu.Unlock()
 
@@ -352,21 +385,39 @@ func (c *Cache) MoveIn(sourcePath string, contentHash string) error {
 		return c.trackFile(shard, targetPath)
 	}
 
+	if c.compress {
+		// Compressing needs a rewrite; the source cannot simply be renamed.
+		data, err := os.ReadFile(sourcePath)
+		if err != nil {
+			return fmt.Errorf("failed to read file for cache: %w", err)
+		}
+		encoded, compressed := c.encode(data)
+		if err := c.store(shard, targetPath, int64(len(encoded)), compressed, func() error {
+			return writeFile(targetPath, encoded)
+		}); err != nil {
+			return err
+		}
+		_ = os.Remove(sourcePath)
+		return nil
+	}
+
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file for cache: %w", err)
+	}
 	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
 		return fmt.Errorf("failed to create target directory: %w", err)
 	}
 
-	if err := os.Rename(sourcePath, targetPath); err != nil {
-		if errors.Is(err, syscall.EXDEV) {
-			if copyErr := c.copyAndRemove(sourcePath, targetPath); copyErr != nil {
-				return copyErr
+	return c.store(shard, targetPath, info.Size(), false, func() error {
+		if err := os.Rename(sourcePath, targetPath); err != nil {
+			if errors.Is(err, syscall.EXDEV) {
+				return c.copyAndRemove(sourcePath, targetPath)
 			}
-			return c.trackFile(shard, targetPath)
+			return fmt.Errorf("failed to move file into cache: %w", err)
 		}
-		return fmt.Errorf("failed to move file into cache: %w", err)
-	}
-
-	return c.trackFile(shard, targetPath)
+		return nil
+	})
 }
 
 // copyAndRemove is a fallback for os.Rename when crossing device boundaries (EXDEV)
@@ -399,8 +450,29 @@ func (c *Cache) copyAndRemove(src, dst string) error {
 // The caller is responsible for closing the reader.
 func (c *Cache) Get(contentHash string) ([]byte, error) {
 	path := c.GetPathForContentHash(contentHash)
+	shard := c.getShard(contentHash)
 
-	data, err := os.ReadFile(path)
+	// The file and its flag are read under the lock that store holds while
+	// writing them, so they always match. Files missing from the index were
+	// never written compressed.
+	var compressed bool
+	shard.mu.Lock()
+	f, err := os.Open(path)
+	if err == nil {
+		err = shard.db.QueryRow(`SELECT compressed FROM cache_index WHERE path = ?`, path).Scan(&compressed)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+		if err != nil {
+			f.Close()
+		}
+	}
+	shard.mu.Unlock()
+	var data []byte
+	if err == nil {
+		data, err = io.ReadAll(f)
+		f.Close()
+	}
 	if err != nil {
 		if os.IsNotExist(err) {
 			atomic.AddInt64(&c.cacheMisses, 1)
@@ -411,6 +483,14 @@ func (c *Cache) Get(contentHash string) ([]byte, error) {
 		}
 		return nil, err
 	}
+	// Objects may be compressed whether or not compression is enabled now.
+	if compressed {
+		if data, err = helpers.DecompressZstd(data); err != nil {
+			logger.Error("Cache: Failed to decompress cached object", "path", path, "error", err)
+			metrics.CacheOperationsTotal.WithLabelValues("get", "error").Inc()
+			return nil, fmt.Errorf("failed to decompress cached object: %w", err)
+		}
+	}
 	atomic.AddInt64(&c.cacheHits, 1)
 	metrics.CacheOperationsTotal.WithLabelValues("get", "hit").Inc()
 
@@ -424,13 +504,55 @@ func (c *Cache) Get(contentHash string) ([]byte, error) {
 
 // Put writes an object to the cache.
 func (c *Cache) Put(contentHash string, data []byte) error {
+	if int64(len(data)) > c.maxObjectSize {
+		return ErrObjectTooLarge
+	}
 	path := c.GetPathForContentHash(contentHash)
+	shard := c.getShard(contentHash)
 
+	// Content is addressed by hash, so an existing file already holds it;
+	// only its recency is refreshed.
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			return fmt.Errorf("failed to touch cache file: %w", err)
+		}
+		return c.trackFile(shard, path)
+	}
+
+	encoded, compressed := c.encode(data)
+	return c.store(shard, path, int64(len(encoded)), compressed, func() error {
+		return writeFile(path, encoded)
+	})
+}
+
+// store indexes path and then runs write to create the file, holding the
+// shard lock throughout so Get never sees the file with a stale compressed
+// flag. The entry is dropped again if write fails.
+func (c *Cache) store(shard *cacheShard, path string, size int64, compressed bool, write func() error) error {
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, err := shard.db.Exec(
+		`INSERT OR REPLACE INTO cache_index (path, size, mod_time, compressed) VALUES (?, ?, ?, ?)`,
+		path, size, time.Now(), compressed,
+	); err != nil {
+		return fmt.Errorf("failed to update cache index: %w", err)
+	}
+	if err := write(); err != nil {
+		shard.db.Exec(`DELETE FROM cache_index WHERE path = ?`, path)
+		return err
+	}
+	return nil
+}
+
+// writeFile writes data to path via a temporary file, so concurrent readers
+// never see a partial object.
+func writeFile(path string, data []byte) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return fmt.Errorf("failed to create cache directory structure: %w", err)
 	}
 
-	// Write to temp file first to avoid partial reads by concurrent requests
 	b := make([]byte, 4)
 	rand.Read(b)
 	tempPath := path + ".tmp." + fmt.Sprintf("%d_%x", time.Now().UnixNano(), b)
@@ -443,9 +565,6 @@ func (c *Cache) Put(contentHash string, data []byte) error {
 		os.Remove(tempPath) // Clean up temp file if rename fails
 	}()
 
-	if int64(len(data)) > c.maxObjectSize {
-		return ErrObjectTooLarge
-	}
 	_, err = file.Write(data)
 	if err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
@@ -455,9 +574,19 @@ func (c *Cache) Put(contentHash string, data []byte) error {
 	if err := os.Rename(tempPath, path); err != nil {
 		return fmt.Errorf("failed to finalize cache file: %w", err)
 	}
+	return nil
+}
 
-	shard := c.getShard(contentHash)
-	return c.trackFile(shard, path)
+// encode compresses data for storage when compression is enabled and it
+// actually shrinks the object, reporting whether it did.
+func (c *Cache) encode(data []byte) ([]byte, bool) {
+	if !c.compress {
+		return data, false
+	}
+	if compressed := helpers.CompressZstd(data); len(compressed) < len(data) {
+		return compressed, true
+	}
+	return data, false
 }
 
 func (c *Cache) trackFile(shard *cacheShard, path string) error {
@@ -468,7 +597,8 @@ func (c *Cache) trackFile(shard *cacheShard, path string) error {
 
 	shard.mu.Lock()
 	_, err = shard.db.Exec(
-		`INSERT OR REPLACE INTO cache_index (path, size, mod_time) VALUES (?, ?, ?)`,
+		`INSERT INTO cache_index (path, size, mod_time) VALUES (?, ?, ?)
+		 ON CONFLICT(path) DO UPDATE SET size = excluded.size, mod_time = excluded.mod_time`,
 		path, info.Size(), info.ModTime(),
 	)
 	shard.mu.Unlock()
@@ -776,9 +906,12 @@ func (c *Cache) PurgeOrphanedContentHashes(ctx context.Context) error {
 		for _, hash := range contentHashes {
 			if !existingMap[hash] {
 				p := hashToPath[hash]
-				os.Remove(p)
-				removeEmptyParents(p, filepath.Join(c.basePath, DataDir))
 				shard.mu.Lock()
+				if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+					shard.mu.Unlock()
+					continue
+				}
+				removeEmptyParents(p, filepath.Join(c.basePath, DataDir))
 				shard.db.Exec(`DELETE FROM cache_index WHERE path = ?`, p)
 				shard.mu.Unlock()
 				purged++
@@ -813,7 +946,10 @@ func (c *Cache) removeStaleEntriesForShard(ctx context.Context, shard *cacheShar
 
 	for _, p := range stalePaths {
 		shard.mu.Lock()
-		shard.db.ExecContext(ctx, `DELETE FROM cache_index WHERE path = ?`, p)
+		// Check again: the object may have been stored since the scan.
+		if _, statErr := os.Stat(p); os.IsNotExist(statErr) {
+			shard.db.ExecContext(ctx, `DELETE FROM cache_index WHERE path = ?`, p)
+		}
 		shard.mu.Unlock()
 	}
 	return nil
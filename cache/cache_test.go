@@ -4,10 +4,12 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -440,6 +442,93 @@ func TestMoveIn_TargetExists(t *testing.T) {
 	assert.Equal(t, existingData, retrievedData, "existing cache content should be preserved")
 }
 
+func TestCompression(t *testing.T) {
+	c, _ := newTestCache(t, 262144, 4096)
+	c.EnableCompression()
+
+	text := []byte(strings.Repeat("Subject: hello\r\n\r\nThe quick brown fox jumps over the lazy dog.\r\n", 50))
+	hash := "aabbccddeeff00112233445566778899"
+
+	// Put stores the object compressed and Get returns the original bytes.
+	require.NoError(t, c.Put(hash, text))
+	onDisk, err := os.ReadFile(c.GetPathForContentHash(hash))
+	require.NoError(t, err)
+	assert.Less(t, len(onDisk), len(text))
+	retrievedData, err := c.Get(hash)
+	require.NoError(t, err)
+	assert.Equal(t, text, retrievedData)
+
+	// Incompressible data is stored as-is.
+	random, randomHash := randomDataAndHash(t, 1000)
+	require.NoError(t, c.Put(randomHash, random))
+	onDisk, err = os.ReadFile(c.GetPathForContentHash(randomHash))
+	require.NoError(t, err)
+	assert.Equal(t, random, onDisk)
+
+	// MoveIn compresses too.
+	srcPath := filepath.Join(t.TempDir(), "source.eml")
+	require.NoError(t, os.WriteFile(srcPath, text, 0644))
+	movedHash := "ffeeddccbbaa99887766554433221100"
+	require.NoError(t, c.MoveIn(srcPath, movedHash))
+	onDisk, err = os.ReadFile(c.GetPathForContentHash(movedHash))
+	require.NoError(t, err)
+	assert.Less(t, len(onDisk), len(text))
+	retrievedData, err = c.Get(movedHash)
+	require.NoError(t, err)
+	assert.Equal(t, text, retrievedData)
+
+	// Uncompressed entries written earlier remain readable.
+	c.compress = false
+	legacyHash := "0123456789abcdef0123456789abcdef"
+	require.NoError(t, c.Put(legacyHash, text))
+	c.EnableCompression()
+	retrievedData, err = c.Get(legacyHash)
+	require.NoError(t, err)
+	assert.Equal(t, text, retrievedData)
+}
+
+func TestZstdMagicBody(t *testing.T) {
+	// A body that merely starts like a zstd frame must come back unchanged.
+	body := []byte("\x28\xb5\x2f\xfd not really zstd")
+	for _, compress := range []bool{false, true} {
+		c, _ := newTestCache(t, 262144, 4096)
+		if compress {
+			c.EnableCompression()
+		}
+		require.NoError(t, c.Put("aabbccddeeff00112233445566778899", body))
+		retrievedData, err := c.Get("aabbccddeeff00112233445566778899")
+		require.NoError(t, err)
+		assert.Equal(t, body, retrievedData, "compress=%v", compress)
+
+		srcPath := filepath.Join(t.TempDir(), "source.eml")
+		require.NoError(t, os.WriteFile(srcPath, body, 0644))
+		require.NoError(t, c.MoveIn(srcPath, "ffeeddccbbaa99887766554433221100"))
+		retrievedData, err = c.Get("ffeeddccbbaa99887766554433221100")
+		require.NoError(t, err)
+		assert.Equal(t, body, retrievedData, "compress=%v", compress)
+	}
+}
+
+func TestCompressedColumnMigration(t *testing.T) {
+	basePath := t.TempDir()
+	db, err := sql.Open("sqlite", filepath.Join(basePath, "cache_index_00.db"))
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE cache_index (path TEXT PRIMARY KEY, size INTEGER NOT NULL, mod_time DATETIME NOT NULL)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	c, err := New(basePath, 262144, 4096, time.Hour, time.Hour, newMockSourceDatabase())
+	require.NoError(t, err)
+	defer c.Close()
+	c.EnableCompression()
+
+	text := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog.\r\n", 50))
+	require.NoError(t, c.Put("00bbccddeeff00112233445566778899", text))
+	retrievedData, err := c.Get("00bbccddeeff00112233445566778899")
+	require.NoError(t, err)
+	assert.Equal(t, text, retrievedData)
+}
+
 func TestGetPathForContentHash(t *testing.T) {
 	c, _ := newTestCache(t, 262144, 1024)
 	basePath := c.basePath
@@ -54,6 +54,12 @@ type Manager struct {
 	ipLimitMu          sync.RWMutex
 	ipLimitBroadcasts  []func(int, int) [][]byte
 	ipLimitBroadcastMu sync.RWMutex
+
+	// Access list event handling
+	accessListHandlers    []func([]byte)
+	accessListMu          sync.RWMutex
+	accessListBroadcasts  []func(int, int) [][]byte
+	accessListBroadcastMu sync.RWMutex
 }
 
 // clusterDelegate implements memberlist.Delegate for custom cluster behavior
@@ -755,6 +761,85 @@ func (m *Manager) getIPLimitBroadcasts(overhead, limit int) [][]byte {
 	return allBroadcasts
 }
 
+// RegisterAccessListHandler registers a callback to handle access list events from the cluster
+func (m *Manager) RegisterAccessListHandler(handler func([]byte)) {
+	m.accessListMu.Lock()
+	defer m.accessListMu.Unlock()
+	m.accessListHandlers = append(m.accessListHandlers, handler)
+}
+
+// notifyAccessListHandlers calls all registered access list handlers
+func (m *Manager) notifyAccessListHandlers(data []byte) {
+	m.accessListMu.RLock()
+	handlers := make([]func([]byte), len(m.accessListHandlers))
+	copy(handlers, m.accessListHandlers)
+	m.accessListMu.RUnlock()
+
+	// Call handlers asynchronously to avoid blocking gossip receive
+	// Use timeout to prevent goroutine leaks from blocked handlers
+	for _, handler := range handlers {
+		go func(h func([]byte)) {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				defer func() {
+					if r := recover(); r != nil {
+						logger.Error("Panic in access list handler", "error", fmt.Errorf("%v", r))
+					}
+				}()
+				h(data)
+			}()
+
+			select {
+			case <-done:
+				// Handler completed successfully
+			case <-time.After(5 * time.Second):
+				logger.Warn("Cluster: Access list handler timed out after 5s")
+			case <-m.ctx.Done():
+				// Manager shutting down
+			}
+		}(handler)
+	}
+}
+
+// RegisterAccessListBroadcaster registers a callback to generate access list broadcasts
+func (m *Manager) RegisterAccessListBroadcaster(broadcaster func(int, int) [][]byte) {
+	m.accessListBroadcastMu.Lock()
+	defer m.accessListBroadcastMu.Unlock()
+	m.accessListBroadcasts = append(m.accessListBroadcasts, broadcaster)
+}
+
+// getAccessListBroadcasts collects broadcasts from all registered access list broadcasters
+func (m *Manager) getAccessListBroadcasts(overhead, limit int) [][]byte {
+	m.accessListBroadcastMu.RLock()
+	broadcasters := make([]func(int, int) [][]byte, len(m.accessListBroadcasts))
+	copy(broadcasters, m.accessListBroadcasts)
+	m.accessListBroadcastMu.RUnlock()
+
+	var allBroadcasts [][]byte
+	totalSize := 0
+
+	for _, broadcaster := range broadcasters {
+		for _, msg := range broadcaster(overhead, limit-totalSize) {
+			// Add 'AL' magic marker to identify access list messages
+			marked := make([]byte, len(msg)+2)
+			marked[0] = 0x41 // 'A'
+			marked[1] = 0x4C // 'L'
+			copy(marked[2:], msg)
+
+			msgSize := overhead + len(marked)
+			if totalSize+msgSize > limit && len(allBroadcasts) > 0 {
+				return allBroadcasts
+			}
+
+			allBroadcasts = append(allBroadcasts, marked)
+			totalSize += msgSize
+		}
+	}
+
+	return allBroadcasts
+}
+
 // MemberInfo holds information about a cluster member
 type MemberInfo struct {
 	Name string
@@ -830,6 +915,10 @@ func (d *clusterDelegate) NotifyMsg(msg []byte) {
 		logger.Debug("Cluster: Received per-IP limit message", "len", len(msg))
 		// Strip marker and forward to IP limit handlers
 		d.manager.notifyIPLimitHandlers(msg[2:])
+	} else if msg[0] == 0x41 && msg[1] == 0x4C { // 'A' 'L' - Access List
+		logger.Debug("Cluster: Received access list message", "len", len(msg))
+		// Strip marker and forward to access list handlers
+		d.manager.notifyAccessListHandlers(msg[2:])
 	} else {
 		logger.Warn("Cluster: Received unknown message type", "type", fmt.Sprintf("0x%02x%02x", msg[0], msg[1]), "len", len(msg))
 	}
@@ -886,6 +975,17 @@ func (d *clusterDelegate) GetBroadcasts(overhead, limit int) [][]byte {
 		totalSize += msgSize
 	}
 
+	// Get access list broadcasts
+	accessListBroadcasts := d.manager.getAccessListBroadcasts(overhead, limit-totalSize)
+	for _, msg := range accessListBroadcasts {
+		msgSize := overhead + len(msg)
+		if totalSize+msgSize > limit && len(allBroadcasts) > 0 {
+			return allBroadcasts
+		}
+		allBroadcasts = append(allBroadcasts, msg)
+		totalSize += msgSize
+	}
+
 	return allBroadcasts
 }
 
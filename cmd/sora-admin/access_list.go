@@ -0,0 +1,405 @@
+package main
+
+// access_list.go - Persistent block and allow list
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/logger"
+)
+
+// accessListEntry mirrors server.AccessListEntry as returned by the Admin API
+type accessListEntry struct {
+	ID        int64      `json:"id"`
+	Action    string     `json:"action"`
+	CIDR      string     `json:"cidr,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// handleAccessListCommand handles the 'access-list' command
+func handleAccessListCommand(ctx context.Context) {
+	if len(os.Args) < 3 {
+		printAccessListUsage()
+		os.Exit(1)
+	}
+
+	subcommand := os.Args[2]
+	switch subcommand {
+	case "list":
+		handleAccessListList(ctx)
+	case "add":
+		handleAccessListAdd(ctx)
+	case "remove":
+		handleAccessListRemove(ctx)
+	case "unblock":
+		handleAccessListUnblock(ctx)
+	case "export":
+		handleAccessListExport(ctx)
+	case "help", "--help", "-h":
+		printAccessListUsage()
+	default:
+		fmt.Printf("Unknown access-list subcommand: %s\n\n", subcommand)
+		printAccessListUsage()
+		os.Exit(1)
+	}
+}
+
+func printAccessListUsage() {
+	fmt.Printf(`Access List Management
+
+Admin-managed block and allow entries, stored in the database and enforced by
+every protocol server and proxy. Changes apply across the cluster immediately.
+
+Usage:
+  sora-admin access-list <subcommand> [options]
+
+Subcommands:
+  list      List access list entries
+  add       Block a network or username, or exempt a network from rate limiting
+  remove    Remove an entry by ID
+  unblock   Lift automatic and access list blocks for an IP and/or username
+  export    Print blocked or allowed networks for a firewall (plain or nftables)
+
+Examples:
+  sora-admin access-list add --config config.toml --action block --cidr 203.0.113.0/24 --reason "credential stuffing" --expires-in 24h
+  sora-admin access-list add --config config.toml --action allow --cidr 10.0.0.0/8
+  sora-admin access-list unblock --config config.toml --ip 203.0.113.7
+  sora-admin access-list export --config config.toml --format nftables | nft -f -
+
+Use 'sora-admin access-list <subcommand> --help' for detailed help.
+`)
+}
+
+func handleAccessListList(ctx context.Context) {
+	fs := flag.NewFlagSet("access-list list", flag.ExitOnError)
+	action := fs.String("action", "", "Only list 'block' or 'allow' entries")
+	includeExpired := fs.Bool("include-expired", false, "Include expired entries")
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+
+	fs.Usage = func() {
+		fmt.Printf(`List access list entries
+
+Usage:
+  sora-admin access-list list [options]
+
+Options:
+  --config string     Path to TOML configuration file (required)
+  --action string     Only list 'block' or 'allow' entries
+  --include-expired   Include expired entries
+  --json              Output in JSON format
+`)
+	}
+
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		logger.Fatalf("Error parsing flags: %v", err)
+	}
+
+	query := url.Values{}
+	if *action != "" {
+		query.Set("action", *action)
+	}
+	if *includeExpired {
+		query.Set("include_expired", "true")
+	}
+	path := "/admin/access-list"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	body, err := accessListRequest(ctx, "GET", path, nil)
+	if err != nil {
+		logger.Fatalf("Failed to list access list: %v", err)
+	}
+	if *jsonOutput {
+		fmt.Println(string(body))
+		return
+	}
+
+	var result struct {
+		Entries []accessListEntry `json:"entries"`
+		Count   int               `json:"count"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		logger.Fatalf("Failed to parse response: %v", err)
+	}
+
+	if result.Count == 0 {
+		fmt.Println("No access list entries found.")
+		return
+	}
+
+	fmt.Printf("%-6s %-6s %-24s %-30s %-20s %s\n", "ID", "ACTION", "CIDR", "USERNAME", "EXPIRES", "REASON")
+	fmt.Println(strings.Repeat("-", 110))
+	for _, e := range result.Entries {
+		expires := "never"
+		if e.ExpiresAt != nil {
+			expires = e.ExpiresAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-6d %-6s %-24s %-30s %-20s %s\n", e.ID, e.Action, e.CIDR, truncateString(e.Username, 30), expires, e.Reason)
+	}
+	fmt.Printf("\nTotal: %d entries\n", result.Count)
+}
+
+func handleAccessListAdd(ctx context.Context) {
+	fs := flag.NewFlagSet("access-list add", flag.ExitOnError)
+	action := fs.String("action", "block", "'block' or 'allow'")
+	cidr := fs.String("cidr", "", "IP address or network")
+	username := fs.String("username", "", "Username to block (block only)")
+	reason := fs.String("reason", "", "Reason for the entry")
+	expiresIn := fs.String("expires-in", "", "Expire after this duration, e.g. 24h (default: never)")
+
+	fs.Usage = func() {
+		fmt.Printf(`Add an access list entry
+
+A block entry rejects authentication from a network, for a username, or for a
+username from a network. An allow entry exempts a network from automatic rate
+limiting blocks. Adding an entry that already exists updates its reason and
+expiry.
+
+Usage:
+  sora-admin access-list add [options]
+
+Options:
+  --config string       Path to TOML configuration file (required)
+  --action string       'block' or 'allow' (default: block)
+  --cidr string         IP address or network
+  --username string     Username to block (block only)
+  --reason string       Reason for the entry
+  --expires-in string   Expire after this duration, e.g. 24h (default: never)
+
+Examples:
+  sora-admin access-list add --config config.toml --cidr 203.0.113.7 --expires-in 1h
+  sora-admin access-list add --config config.toml --username user@example.com --reason "compromised"
+  sora-admin access-list add --config config.toml --action allow --cidr 192.168.0.0/16
+`)
+	}
+
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		logger.Fatalf("Error parsing flags: %v", err)
+	}
+
+	if *cidr == "" && *username == "" {
+		fmt.Printf("Error: --cidr or --username is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	reqBody := map[string]any{
+		"action":     *action,
+		"cidr":       *cidr,
+		"username":   *username,
+		"reason":     *reason,
+		"expires_in": *expiresIn,
+	}
+	body, err := accessListRequest(ctx, "POST", "/admin/access-list", reqBody)
+	if err != nil {
+		logger.Fatalf("Failed to add access list entry: %v", err)
+	}
+
+	var entry accessListEntry
+	if err := json.Unmarshal(body, &entry); err != nil {
+		logger.Fatalf("Failed to parse response: %v", err)
+	}
+	target := strings.TrimSpace(entry.CIDR + " " + entry.Username)
+	fmt.Printf("[OK] Added %s entry %d for %s\n", entry.Action, entry.ID, target)
+	if entry.ExpiresAt != nil {
+		fmt.Printf("  Expires: %s\n", entry.ExpiresAt.Local().Format("2006-01-02 15:04:05"))
+	}
+}
+
+func handleAccessListRemove(ctx context.Context) {
+	fs := flag.NewFlagSet("access-list remove", flag.ExitOnError)
+	id := fs.Int64("id", 0, "Entry ID (required)")
+
+	fs.Usage = func() {
+		fmt.Printf(`Remove an access list entry
+
+Usage:
+  sora-admin access-list remove --id ID [options]
+
+Options:
+  --config string   Path to TOML configuration file (required)
+  --id int          Entry ID, as shown by 'access-list list' (required)
+`)
+	}
+
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		logger.Fatalf("Error parsing flags: %v", err)
+	}
+
+	if *id <= 0 {
+		fmt.Printf("Error: --id is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if _, err := accessListRequest(ctx, "DELETE", fmt.Sprintf("/admin/access-list/%d", *id), nil); err != nil {
+		logger.Fatalf("Failed to remove access list entry: %v", err)
+	}
+	fmt.Printf("[OK] Removed access list entry %d\n", *id)
+}
+
+func handleAccessListUnblock(ctx context.Context) {
+	fs := flag.NewFlagSet("access-list unblock", flag.ExitOnError)
+	ip := fs.String("ip", "", "IP address (or access list network)")
+	username := fs.String("username", "", "Username")
+
+	fs.Usage = func() {
+		fmt.Printf(`Unblock an IP address and/or username
+
+Lifts automatic rate limiter blocks on every node and removes access list block
+entries for exactly the given IP and/or username.
+
+Usage:
+  sora-admin access-list unblock [options]
+
+Options:
+  --config string     Path to TOML configuration file (required)
+  --ip string         IP address (or access list network)
+  --username string   Username
+
+Examples:
+  sora-admin access-list unblock --config config.toml --ip 203.0.113.7
+  sora-admin access-list unblock --config config.toml --username user@example.com
+`)
+	}
+
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		logger.Fatalf("Error parsing flags: %v", err)
+	}
+
+	if *ip == "" && *username == "" {
+		fmt.Printf("Error: --ip or --username is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	query := url.Values{}
+	if *ip != "" {
+		query.Set("ip", *ip)
+	}
+	if *username != "" {
+		query.Set("username", *username)
+	}
+	body, err := accessListRequest(ctx, "DELETE", "/admin/auth/blocked?"+query.Encode(), nil)
+	if err != nil {
+		logger.Fatalf("Failed to unblock: %v", err)
+	}
+
+	var result struct {
+		Lifted  int     `json:"rate_limit_blocks_lifted"`
+		Removed []int64 `json:"access_list_removed"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		logger.Fatalf("Failed to parse response: %v", err)
+	}
+	fmt.Printf("[OK] Unblocked %s\n", strings.TrimSpace(*ip+" "+*username))
+	fmt.Printf("  Rate limiter blocks lifted: %d\n", result.Lifted)
+	fmt.Printf("  Access list entries removed: %d\n", len(result.Removed))
+}
+
+func handleAccessListExport(ctx context.Context) {
+	fs := flag.NewFlagSet("access-list export", flag.ExitOnError)
+	format := fs.String("format", "plain", "'plain' or 'nftables'")
+	action := fs.String("action", "block", "'block' or 'allow'")
+	table := fs.String("table", "", "nftables table name (default: sora)")
+	set := fs.String("set", "", "nftables set name prefix (default: sora_<action>)")
+
+	fs.Usage = func() {
+		fmt.Printf(`Export access list networks for a firewall
+
+Prints one network per line, or an nftables script that declares <set>_v4 and
+<set>_v6 interval sets in 'table inet <table>' and replaces their contents.
+Username blocks are not exported.
+
+Usage:
+  sora-admin access-list export [options]
+
+Options:
+  --config string   Path to TOML configuration file (required)
+  --format string   'plain' or 'nftables' (default: plain)
+  --action string   'block' or 'allow' (default: block)
+  --table string    nftables table name (default: sora)
+  --set string      nftables set name prefix (default: sora_<action>)
+
+Examples:
+  sora-admin access-list export --config config.toml > blocked.txt
+  sora-admin access-list export --config config.toml --format nftables | nft -f -
+`)
+	}
+
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		logger.Fatalf("Error parsing flags: %v", err)
+	}
+
+	query := url.Values{}
+	query.Set("format", *format)
+	query.Set("action", *action)
+	if *table != "" {
+		query.Set("table", *table)
+	}
+	if *set != "" {
+		query.Set("set", *set)
+	}
+	body, err := accessListRequest(ctx, "GET", "/admin/access-list/export?"+query.Encode(), nil)
+	if err != nil {
+		logger.Fatalf("Failed to export access list: %v", err)
+	}
+	fmt.Print(string(body))
+}
+
+// accessListRequest calls the Admin API configured in [admin_cli] and returns
+// the response body of a successful request.
+func accessListRequest(ctx context.Context, method, path string, reqBody any) ([]byte, error) {
+	client, err := createHTTPAPIClient(globalConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP API client: %w", err)
+	}
+
+	var body io.Reader
+	if reqBody != nil {
+		jsonData, err := json.Marshal(reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request body: %w", err)
+		}
+		body = bytes.NewReader(jsonData)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, globalConfig.HTTPAPIAddr+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+globalConfig.HTTPAPIKey)
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("API request failed (status %d): %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
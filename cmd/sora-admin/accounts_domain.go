@@ -137,32 +137,11 @@ func purgeAccountWithStorage(ctx context.Context, cfg AdminConfig, rdb *resilien
 
 	// Initialize S3 storage if not provided
 	if s3Storage == nil {
-		useSSL := !cfg.S3.DisableTLS
-		s3Timeout, err := cfg.S3.GetTimeout()
+		backend, err := storage.NewFromConfig(&cfg.S3)
 		if err != nil {
-			return fmt.Errorf("failed to parse S3 timeout: %w", err)
+			return fmt.Errorf("failed to initialize object storage: %w", err)
 		}
-		realS3, err := storage.New(
-			cfg.S3.Endpoint,
-			cfg.S3.AccessKey,
-			cfg.S3.SecretKey,
-			cfg.S3.Bucket,
-			useSSL,
-			false, // debug mode
-			s3Timeout,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to initialize S3 storage: %w", err)
-		}
-
-		// Enable encryption if configured
-		if cfg.S3.Encrypt {
-			if err := realS3.EnableEncryption(cfg.S3.EncryptionKey); err != nil {
-				return fmt.Errorf("failed to enable S3 encryption: %w", err)
-			}
-		}
-
-		s3Storage = realS3
+		s3Storage = backend
 	}
 
 	// Step 1: Mark all messages as expunged (atomic, idempotent)
@@ -211,9 +190,9 @@ func purgeAccountWithStorage(ctx context.Context, cfg AdminConfig, rdb *resilien
 		var successfulDeletes []db.UserScopedObjectForCleanup
 		var failedDeletes int
 
-		if realS3, ok := s3Storage.(*storage.S3Storage); ok {
+		if backend, ok := s3Storage.(storage.Backend); ok {
 			// Bulk delete
-			errors := realS3.DeleteBulk(s3Keys)
+			errors := backend.DeleteBulk(s3Keys)
 			for _, s3Key := range s3Keys {
 				if err, failed := errors[s3Key]; failed {
 					fmt.Printf("    Warning: Failed to delete %s: %v\n", s3Key, err)
@@ -8,6 +8,7 @@ import (
 
 	"github.com/migadu/sora/config"
 	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
 	"github.com/migadu/sora/server/aclservice"
 )
 
@@ -99,6 +100,12 @@ func handleACLGrant(ctx context.Context) {
 		os.Exit(1)
 	}
 
+	recordAudit(ctx, rdb, db.AuditACLGrant, *email, map[string]any{
+		"mailbox":    *mailbox,
+		"identifier": targetIdentifier,
+		"rights":     *rights,
+	})
+
 	fmt.Printf("Successfully granted rights '%s' to '%s' on mailbox '%s' (owner: %s)\n",
 		*rights, targetIdentifier, *mailbox, *email)
 }
@@ -160,6 +167,11 @@ func handleACLRevoke(ctx context.Context) {
 		os.Exit(1)
 	}
 
+	recordAudit(ctx, rdb, db.AuditACLRevoke, *email, map[string]any{
+		"mailbox":    *mailbox,
+		"identifier": targetIdentifier,
+	})
+
 	fmt.Printf("Successfully revoked access for '%s' on mailbox '%s' (owner: %s)\n",
 		targetIdentifier, *mailbox, *email)
 }
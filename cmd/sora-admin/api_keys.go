@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/server/adminapi"
+)
+
+// handleAPIKeysCommand handles the 'api-keys' command
+func handleAPIKeysCommand(ctx context.Context) {
+	if len(os.Args) < 3 {
+		printAPIKeysUsage()
+		os.Exit(1)
+	}
+
+	subcommand := os.Args[2]
+	switch subcommand {
+	case "create":
+		handleAPIKeysCreate(ctx)
+	case "list":
+		handleAPIKeysList(ctx)
+	case "revoke":
+		handleAPIKeysRevoke(ctx)
+	case "help", "--help", "-h":
+		printAPIKeysUsage()
+	default:
+		fmt.Printf("Unknown api-keys subcommand: %s\n\n", subcommand)
+		printAPIKeysUsage()
+		os.Exit(1)
+	}
+}
+
+// handleAPIKeysCreate generates a new scoped Admin API key
+func handleAPIKeysCreate(ctx context.Context) {
+	fs := flag.NewFlagSet("api-keys create", flag.ExitOnError)
+	name := fs.String("name", "", "Unique name for the key (required)")
+	scopes := fs.String("scopes", "", "Comma-separated scopes (required)")
+	domains := fs.String("domains", "", "Comma-separated domains the key is restricted to (default: all)")
+	expires := fs.String("expires", "", "Expiry as a duration (e.g. 720h) or date (YYYY-MM-DD)")
+	configOnly := fs.Bool("config-only", false, "Print a config snippet instead of storing the key in the database")
+
+	fs.Usage = func() {
+		fmt.Printf(`Create a scoped Admin API key
+
+The key is printed once and cannot be shown again; only its SHA-256 is stored.
+
+Usage:
+  sora-admin api-keys create --config PATH --name NAME --scopes SCOPES [options]
+
+Options:
+  --config PATH       Path to TOML configuration file (required)
+  --name NAME         Unique name for the key (required)
+  --scopes SCOPES     Comma-separated scopes: %s (required)
+  --domains DOMAINS   Comma-separated domains the key is restricted to (default: all)
+  --expires WHEN      Expiry as a duration (e.g. 720h) or date (YYYY-MM-DD)
+  --config-only       Print an [[server.api_keys]] snippet instead of storing the key
+
+Examples:
+  sora-admin api-keys create --config config.toml --name helpdesk --scopes read,connections
+  sora-admin api-keys create --config config.toml --name example-admin --scopes accounts --domains example.com
+  sora-admin api-keys create --config config.toml --name monitoring --scopes read --config-only
+`, strings.Join(adminapi.Scopes, ", "))
+	}
+
+	fs.Parse(os.Args[3:])
+
+	if *name == "" || *scopes == "" {
+		fmt.Println("Error: --name and --scopes are required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	scopeList := splitList(*scopes)
+	if err := adminapi.ValidateScopes(scopeList); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	domainList := splitList(strings.ToLower(*domains))
+
+	var expiresAt *time.Time
+	if *expires != "" {
+		t, err := parseExpiry(*expires)
+		if err != nil {
+			fmt.Printf("Error: invalid --expires: %v\n", err)
+			os.Exit(1)
+		}
+		expiresAt = &t
+	}
+
+	key, prefix, hash, err := adminapi.GenerateAPIKey()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *configOnly {
+		if expiresAt != nil {
+			fmt.Println("Warning: --expires is ignored for config keys; remove the entry to revoke the key")
+		}
+		fmt.Printf("API key: %s\n\nAdd below the http_admin_api [[server]] entry:\n\n", key)
+		fmt.Printf("[[server.api_keys]]\nname = %q\nkey_hash = \"sha256:%s\"\nscopes = %s\n", *name, hash, tomlStringList(scopeList))
+		if len(domainList) > 0 {
+			fmt.Printf("domains = %s\n", tomlStringList(domainList))
+		}
+		return
+	}
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	_, err = rdb.CreateAdminAPIKeyWithRetry(ctx, &db.AdminAPIKey{
+		Name:      *name,
+		KeyHash:   hash,
+		KeyPrefix: prefix,
+		Scopes:    scopeList,
+		Domains:   domainList,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		fmt.Printf("Failed to create API key: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Created API key %q\n", *name)
+	fmt.Printf("API key: %s\n", key)
+	fmt.Println("Store it now; it cannot be shown again.")
+}
+
+// handleAPIKeysList lists Admin API keys stored in the database
+func handleAPIKeysList(ctx context.Context) {
+	fs := flag.NewFlagSet("api-keys list", flag.ExitOnError)
+	all := fs.Bool("all", false, "Include revoked keys")
+
+	fs.Usage = func() {
+		fmt.Printf(`List Admin API keys stored in the database
+
+Keys defined in the config file are not listed.
+
+Usage:
+  sora-admin api-keys list --config PATH [--all]
+
+Options:
+  --config PATH    Path to TOML configuration file (required)
+  --all            Include revoked keys
+
+Examples:
+  sora-admin api-keys list --config config.toml
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	keys, err := rdb.ListAdminAPIKeysWithRetry(ctx, *all)
+	if err != nil {
+		fmt.Printf("Failed to list API keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(keys) == 0 {
+		fmt.Println("No API keys found")
+		return
+	}
+
+	fmt.Printf("%-20s %-14s %-28s %-24s %-10s %-20s\n", "Name", "Prefix", "Scopes", "Domains", "Status", "Last used")
+	fmt.Printf("%-20s %-14s %-28s %-24s %-10s %-20s\n", "----", "------", "------", "-------", "------", "---------")
+	for _, k := range keys {
+		domains := strings.Join(k.Domains, ",")
+		if domains == "" {
+			domains = "(all)"
+		}
+		status := "active"
+		switch {
+		case k.RevokedAt != nil:
+			status = "revoked"
+		case k.ExpiresAt != nil && k.ExpiresAt.Before(time.Now()):
+			status = "expired"
+		}
+		lastUsed := "never"
+		if k.LastUsedAt != nil {
+			lastUsed = k.LastUsedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-20s %-14s %-28s %-24s %-10s %-20s\n",
+			k.Name, k.KeyPrefix, strings.Join(k.Scopes, ","), domains, status, lastUsed)
+	}
+}
+
+// handleAPIKeysRevoke revokes a database Admin API key by name
+func handleAPIKeysRevoke(ctx context.Context) {
+	fs := flag.NewFlagSet("api-keys revoke", flag.ExitOnError)
+	name := fs.String("name", "", "Name of the key to revoke (required)")
+
+	fs.Usage = func() {
+		fmt.Printf(`Revoke an Admin API key
+
+Running servers stop accepting the key within 30 seconds.
+
+Usage:
+  sora-admin api-keys revoke --config PATH --name NAME
+
+Options:
+  --config PATH    Path to TOML configuration file (required)
+  --name NAME      Name of the key to revoke (required)
+
+Examples:
+  sora-admin api-keys revoke --config config.toml --name helpdesk
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	if *name == "" {
+		fmt.Println("Error: --name is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	if err := rdb.RevokeAdminAPIKeyWithRetry(ctx, *name); err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			fmt.Printf("No active API key named %q\n", *name)
+			os.Exit(1)
+		}
+		fmt.Printf("Failed to revoke API key: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Revoked API key %q\n", *name)
+}
+
+func printAPIKeysUsage() {
+	fmt.Println(`Usage: sora-admin api-keys <subcommand> [options]
+
+Subcommands:
+  create        Create a scoped Admin API key
+  list          List Admin API keys stored in the database
+  revoke        Revoke an Admin API key
+
+Scopes:
+  *             Every endpoint
+  read          Every GET endpoint
+  accounts      Accounts, credentials, deleted messages, mailboxes, ACLs and Sieve
+  connections   Connections, kicks and backend affinity
+  cache         Cache, uploader, auth and health endpoints
+  delivery      Mail injection (/admin/mail/deliver) and the relay queue
+
+Keys created with --domains can only act on addresses in those domains and
+cannot call server-wide endpoints.
+
+Examples:
+  sora-admin api-keys create --config config.toml --name helpdesk --scopes read,connections
+  sora-admin api-keys list --config config.toml
+  sora-admin api-keys revoke --config config.toml --name helpdesk`)
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// parseExpiry accepts a Go duration from now or a YYYY-MM-DD date.
+func parseExpiry(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("duration must be positive")
+		}
+		return time.Now().Add(d), nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a duration or YYYY-MM-DD")
+	}
+	if t.Before(time.Now()) {
+		return time.Time{}, fmt.Errorf("date is in the past")
+	}
+	return t, nil
+}
+
+func tomlStringList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/resilient"
+)
+
+// auditSource is the audit log source for sora-admin commands.
+const auditSource = "sora-admin"
+
+// withAdminAuditActor tags ctx with the local operator, so account
+// operations in the db layer are written to the audit log.
+func withAdminAuditActor(ctx context.Context) context.Context {
+	actor := "unknown"
+	if u, err := user.Current(); err == nil {
+		actor = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		actor += "@" + host
+	}
+	return db.WithAuditActor(ctx, db.AuditActor{Source: auditSource, Actor: actor})
+}
+
+// recordAudit writes an audit entry for an action that does not go through
+// an audited db operation. A failure is logged but not fatal, since the
+// action has already happened.
+func recordAudit(ctx context.Context, rdb *resilient.ResilientDatabase, action, target string, details map[string]any) {
+	actor, ok := db.AuditActorFromContext(ctx)
+	if !ok {
+		return
+	}
+	entry := &db.AuditEntry{
+		Source:     actor.Source,
+		Actor:      actor.Actor,
+		RemoteAddr: actor.RemoteAddr,
+		Action:     action,
+		Target:     target,
+		Details:    details,
+	}
+	if err := rdb.InsertAuditEntryWithRetry(ctx, entry); err != nil {
+		logger.Warn("Failed to write audit entry", "action", action, "target", target, "error", err)
+	}
+}
+
+// handleAuditCommand handles the 'audit' command
+func handleAuditCommand(ctx context.Context) {
+	if len(os.Args) < 3 {
+		printAuditUsage()
+		os.Exit(1)
+	}
+
+	subcommand := os.Args[2]
+	switch subcommand {
+	case "list":
+		handleAuditList(ctx)
+	case "verify":
+		handleAuditVerify(ctx)
+	case "help", "--help", "-h":
+		printAuditUsage()
+	default:
+		fmt.Printf("Unknown audit subcommand: %s\n\n", subcommand)
+		printAuditUsage()
+		os.Exit(1)
+	}
+}
+
+// handleAuditList lists audit log entries
+func handleAuditList(ctx context.Context) {
+	fs := flag.NewFlagSet("audit list", flag.ExitOnError)
+	action := fs.String("action", "", "Filter by action (e.g. account.delete, or account.* for a group)")
+	actor := fs.String("actor", "", "Filter by actor (API key name or user@host)")
+	target := fs.String("target", "", "Filter by target address, or @domain for a whole domain")
+	since := fs.Duration("since", 0, "Only show entries newer than this (e.g. 24h)")
+	afterID := fs.Int64("after-id", 0, "Only show entries with an ID greater than this")
+	limit := fs.Int("limit", 100, "Maximum number of entries to show")
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+
+	fs.Usage = func() {
+		fmt.Printf(`List audit log entries
+
+Usage:
+  sora-admin audit list --config PATH [options]
+
+Options:
+  --config PATH      Path to TOML configuration file (required)
+  --action ACTION    Filter by action (e.g. account.delete, or account.* for a group)
+  --actor ACTOR      Filter by actor (API key name or user@host)
+  --target TARGET    Filter by target address, or @domain for a whole domain
+  --since DURATION   Only show entries newer than this (e.g. 24h)
+  --after-id ID      Only show entries with an ID greater than this
+  --limit N          Maximum number of entries to show (default: 100, max: 1000)
+  --json             Output in JSON format
+
+Examples:
+  sora-admin audit list --config config.toml --since 24h
+  sora-admin audit list --config config.toml --action account.* --target @example.com
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	filter := db.AuditFilter{
+		Action:  *action,
+		Actor:   *actor,
+		Target:  *target,
+		AfterID: *afterID,
+		Limit:   *limit,
+	}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+
+	entries, err := rdb.ListAuditEntriesWithRetry(ctx, filter)
+	if err != nil {
+		fmt.Printf("Failed to list audit log: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			fmt.Printf("Failed to encode JSON: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No audit log entries found")
+		return
+	}
+
+	fmt.Printf("%-8s %-20s %-10s %-24s %-20s %-30s\n", "ID", "Time", "Source", "Actor", "Action", "Target")
+	fmt.Printf("%-8s %-20s %-10s %-24s %-20s %-30s\n", "--", "----", "------", "-----", "------", "------")
+	for _, e := range entries {
+		fmt.Printf("%-8d %-20s %-10s %-24s %-20s %-30s\n",
+			e.ID, e.CreatedAt.Local().Format("2006-01-02 15:04:05"), e.Source,
+			truncateString(e.Actor, 24), e.Action, truncateString(e.Target, 30))
+	}
+}
+
+// handleAuditVerify recomputes the audit log hash chain
+func handleAuditVerify(ctx context.Context) {
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Printf(`Verify the audit log hash chain
+
+Each entry's hash covers its contents and the previous entry's hash, so an
+edited, inserted or deleted row breaks the chain. Entries pruned by the
+cleaner's audit_log_retention are not an error: verification starts from the
+oldest remaining entry.
+
+Usage:
+  sora-admin audit verify --config PATH
+
+Options:
+  --config PATH    Path to TOML configuration file (required)
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	result, err := rdb.VerifyAuditLogWithRetry(ctx)
+	if err != nil {
+		fmt.Printf("Failed to verify audit log: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !result.Valid {
+		fmt.Printf("Audit log hash chain is BROKEN at entry %d: %s\n", result.BrokenID, result.Reason)
+		fmt.Printf("Checked %d entries before the break\n", result.Checked)
+		os.Exit(2)
+	}
+	if result.Checked == 0 {
+		fmt.Println("Audit log is empty")
+		return
+	}
+	fmt.Printf("Audit log is intact: %d entries checked (IDs %d-%d)\n", result.Checked, result.FirstID, result.LastID)
+}
+
+func printAuditUsage() {
+	fmt.Println(`Usage: sora-admin audit <subcommand> [options]
+
+Subcommands:
+  list          List audit log entries
+  verify        Verify the audit log hash chain
+
+Examples:
+  sora-admin audit list --config config.toml --since 24h
+  sora-admin audit verify --config config.toml`)
+}
@@ -284,6 +284,10 @@ func purgeCacheWithConfirmation(ctx context.Context, cfg AdminConfig, autoConfir
 		return fmt.Errorf("failed to purge cache: %w", err)
 	}
 
+	recordAudit(ctx, rdb, db.AuditCachePurge, cfg.LocalCache.Path, map[string]any{
+		"before": stats,
+	})
+
 	fmt.Printf("Cache purged successfully.\n")
 	return nil
 }
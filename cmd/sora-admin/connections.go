@@ -11,8 +11,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/migadu/sora/logger"
@@ -180,6 +182,12 @@ func handleAffinityCommand(ctx context.Context) {
 		handleListAffinity(ctx)
 	case "delete":
 		handleDeleteAffinity(ctx)
+	case "drain":
+		handleDrainBackend(ctx)
+	case "undrain":
+		handleUndrainBackend(ctx)
+	case "drains":
+		handleListDrains(ctx)
 	case "help", "--help", "-h":
 		printAffinityUsage()
 	default:
@@ -419,6 +427,179 @@ Examples:
 	}
 }
 
+// findAdminAPI returns the address and key of the first http_admin_api server in the config.
+func findAdminAPI() (addr, apiKey string) {
+	for _, server := range globalConfig.DynamicServers {
+		if server.Type == "http_admin_api" {
+			addr = server.Addr
+			apiKey = server.APIKey
+			break
+		}
+	}
+
+	if addr == "" {
+		logger.Fatalf("No http_admin_api server found in config")
+	}
+	if apiKey == "" {
+		logger.Fatalf("Admin API server found but missing api_key in config")
+	}
+	return addr, apiKey
+}
+
+func handleDrainBackend(ctx context.Context) {
+	fs := flag.NewFlagSet("affinity drain", flag.ExitOnError)
+
+	backendAddr := fs.String("backend", "", "Backend address (host:port) or host to drain on every port (required)")
+	kickPerMinute := fs.Int("kick-per-minute", 0, "Existing sessions each proxy disconnects per minute (0 = leave them)")
+
+	fs.Usage = func() {
+		fmt.Printf(`Take a backend out of rotation
+
+Proxies stop sending new sessions to a draining backend and move users with
+affinity to it elsewhere on their next connection. With --kick-per-minute, each
+proxy also disconnects that many existing sessions per minute so clients
+reconnect to another backend.
+
+Usage:
+  sora-admin affinity drain [options]
+
+Options:
+  --config string          Path to TOML configuration file (required)
+  --backend string         Backend address (host:port) or host to drain on every port (required)
+  --kick-per-minute int    Existing sessions each proxy disconnects per minute (default: 0)
+
+Note: This command calls the admin API HTTP endpoint. In cluster mode the drain
+      is gossiped to all nodes; otherwise it applies to the admin API's node only.
+
+Examples:
+  sora-admin affinity drain --config config.toml --backend 192.168.1.10
+  sora-admin affinity drain --config config.toml --backend 192.168.1.10:143 --kick-per-minute 60
+`)
+	}
+
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		logger.Fatalf("Error parsing flags: %v", err)
+	}
+
+	if *backendAddr == "" {
+		fmt.Printf("Error: --backend is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *kickPerMinute < 0 {
+		fmt.Printf("Error: --kick-per-minute must not be negative\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	adminAPIAddr, adminAPIKey := findAdminAPI()
+	reqBody := map[string]any{
+		"backend":         *backendAddr,
+		"kick_per_minute": *kickPerMinute,
+	}
+	respData, err := callAdminAPI(ctx, adminAPIAddr, adminAPIKey, "POST", "/admin/proxy/backends/drain", reqBody)
+	if err != nil {
+		logger.Fatalf("Failed to drain backend: %v", err)
+	}
+
+	scope, _ := respData["scope"].(string)
+	fmt.Printf("[OK] Backend %s is draining (%s)\n", *backendAddr, scope)
+	if *kickPerMinute > 0 {
+		fmt.Printf("  Kicking up to %d sessions per minute per proxy\n", *kickPerMinute)
+	}
+}
+
+func handleUndrainBackend(ctx context.Context) {
+	fs := flag.NewFlagSet("affinity undrain", flag.ExitOnError)
+
+	backendAddr := fs.String("backend", "", "Backend address or host, as given to drain (required)")
+
+	fs.Usage = func() {
+		fmt.Printf(`Return a drained backend to rotation
+
+Usage:
+  sora-admin affinity undrain [options]
+
+Options:
+  --config string    Path to TOML configuration file (required)
+  --backend string   Backend address or host, as given to drain (required)
+
+Examples:
+  sora-admin affinity undrain --config config.toml --backend 192.168.1.10
+`)
+	}
+
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		logger.Fatalf("Error parsing flags: %v", err)
+	}
+
+	if *backendAddr == "" {
+		fmt.Printf("Error: --backend is required\n\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	adminAPIAddr, adminAPIKey := findAdminAPI()
+	path := "/admin/proxy/backends/drain?backend=" + url.QueryEscape(*backendAddr)
+	if _, err := callAdminAPI(ctx, adminAPIAddr, adminAPIKey, "DELETE", path, nil); err != nil {
+		logger.Fatalf("Failed to undrain backend: %v", err)
+	}
+
+	fmt.Printf("[OK] Backend %s is back in rotation\n", *backendAddr)
+}
+
+func handleListDrains(ctx context.Context) {
+	fs := flag.NewFlagSet("affinity drains", flag.ExitOnError)
+
+	fs.Usage = func() {
+		fmt.Printf(`List drained backends
+
+Usage:
+  sora-admin affinity drains [options]
+
+Options:
+  --config string    Path to TOML configuration file (required)
+
+Examples:
+  sora-admin affinity drains --config config.toml
+`)
+	}
+
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		logger.Fatalf("Error parsing flags: %v", err)
+	}
+
+	adminAPIAddr, adminAPIKey := findAdminAPI()
+	respData, err := callAdminAPI(ctx, adminAPIAddr, adminAPIKey, "GET", "/admin/proxy/backends/drain", nil)
+	if err != nil {
+		logger.Fatalf("Failed to list drains: %v", err)
+	}
+
+	drains, _ := respData["drains"].([]any)
+	if len(drains) == 0 {
+		fmt.Println("No backends are draining.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKEND\tSINCE\tKICK/MIN\tNODE")
+	for _, item := range drains {
+		d, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		backend, _ := d["backend"].(string)
+		since, _ := d["since"].(string)
+		kick, _ := d["kick_per_minute"].(float64)
+		node, _ := d["node_id"].(string)
+		if t, err := time.Parse(time.RFC3339Nano, since); err == nil {
+			since = t.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", backend, since, int(kick), node)
+	}
+	w.Flush()
+}
+
 func printConnectionsUsage() {
 	fmt.Printf(`Connection Management
 
@@ -449,6 +630,9 @@ Subcommands:
   set      Set backend affinity for a user (gossiped to all nodes)
   get      Get backend affinity for a user
   delete   Remove backend affinity for a user (gossiped to all nodes)
+  drain    Take a backend out of rotation (gossiped to all nodes)
+  undrain  Return a drained backend to rotation
+  drains   List drained backends
   help     Show this help message
 
 Note: Affinity is managed via the cluster gossip protocol. Changes are automatically
@@ -458,6 +642,7 @@ Examples:
   sora-admin affinity set --config config.toml --user user@example.com --protocol imap --backend 192.168.1.10:993
   sora-admin affinity get --config config.toml --user user@example.com --protocol imap
   sora-admin affinity delete --config config.toml --user user@example.com --protocol imap
+  sora-admin affinity drain --config config.toml --backend 192.168.1.10 --kick-per-minute 30
 
 Use 'sora-admin affinity <subcommand> --help' for detailed help.
 `)
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/db"
+)
+
+// handleDeliveryCommand handles the 'delivery' command
+func handleDeliveryCommand(ctx context.Context) {
+	if len(os.Args) < 3 {
+		printDeliveryUsage()
+		os.Exit(1)
+	}
+
+	subcommand := os.Args[2]
+	switch subcommand {
+	case "search":
+		handleDeliverySearch(ctx)
+	case "help", "--help", "-h":
+		printDeliveryUsage()
+	default:
+		fmt.Printf("Unknown delivery subcommand: %s\n\n", subcommand)
+		printDeliveryUsage()
+		os.Exit(1)
+	}
+}
+
+// handleDeliverySearch searches the delivery log
+func handleDeliverySearch(ctx context.Context) {
+	fs := flag.NewFlagSet("delivery search", flag.ExitOnError)
+	recipient := fs.String("recipient", "", "Filter by envelope recipient, or @domain for a whole domain")
+	sender := fs.String("sender", "", "Filter by envelope sender, or @domain for a whole domain")
+	messageID := fs.String("message-id", "", "Filter by Message-ID header")
+	since := fs.Duration("since", 0, "Only show deliveries newer than this (e.g. 24h)")
+	until := fs.Duration("until", 0, "Only show deliveries older than this (e.g. 1h)")
+	limit := fs.Int("limit", 100, "Maximum number of entries to show")
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+
+	fs.Usage = func() {
+		fmt.Printf(`Search the delivery log
+
+Every LMTP delivery outcome is recorded: delivered, deferred (4xx) or
+rejected (5xx), with the mailbox and Sieve actions chosen. Entries are kept
+for the cleaner's delivery_log_retention.
+
+Usage:
+  sora-admin delivery search --config PATH [options]
+
+Options:
+  --config PATH         Path to TOML configuration file (required)
+  --recipient ADDRESS   Filter by envelope recipient, or @domain for a whole domain
+  --sender ADDRESS      Filter by envelope sender, or @domain for a whole domain
+  --message-id ID       Filter by Message-ID header (angle brackets optional)
+  --since DURATION      Only show deliveries newer than this (e.g. 24h)
+  --until DURATION      Only show deliveries older than this (e.g. 1h)
+  --limit N             Maximum number of entries to show (default: 100, max: 1000)
+  --json                Output in JSON format
+
+Examples:
+  sora-admin delivery search --config config.toml --recipient user@example.com --since 24h
+  sora-admin delivery search --config config.toml --message-id abc123@example.net
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	if *recipient == "" && *sender == "" && *messageID == "" && *since == 0 {
+		fmt.Println("Error: at least one of --recipient, --sender, --message-id or --since is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	filter := db.DeliveryLogFilter{
+		Recipient: *recipient,
+		Sender:    *sender,
+		MessageID: *messageID,
+		Limit:     *limit,
+	}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+	if *until > 0 {
+		filter.Until = time.Now().Add(-*until)
+	}
+
+	entries, err := rdb.SearchDeliveryLogWithRetry(ctx, filter)
+	if err != nil {
+		fmt.Printf("Failed to search delivery log: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			fmt.Printf("Failed to encode JSON: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No deliveries found")
+		return
+	}
+
+	fmt.Printf("%-20s %-10s %-10s %-28s %-28s %-16s %-20s %s\n", "Time", "Result", "Code", "Sender", "Recipient", "Mailbox", "Sieve", "Message-ID")
+	fmt.Printf("%-20s %-10s %-10s %-28s %-28s %-16s %-20s %s\n", "----", "------", "----", "------", "---------", "-------", "-----", "----------")
+	for _, e := range entries {
+		sender := e.Sender
+		if sender == "" {
+			sender = "<>"
+		}
+		fmt.Printf("%-20s %-10s %-10s %-28s %-28s %-16s %-20s %s\n",
+			e.Time.Local().Format("2006-01-02 15:04:05"), e.Result, e.Code,
+			truncateString(sender, 28), truncateString(e.Recipient, 28), truncateString(e.Mailbox, 16),
+			truncateString(strings.Join(e.SieveActions, ","), 20), e.MessageID)
+	}
+}
+
+func printDeliveryUsage() {
+	fmt.Println(`Usage: sora-admin delivery <subcommand> [options]
+
+Subcommands:
+  search        Search the delivery log by recipient, sender, Message-ID or time
+
+Examples:
+  sora-admin delivery search --config config.toml --recipient user@example.com --since 24h
+  sora-admin delivery search --config config.toml --message-id abc123@example.net`)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/pkg/dkim"
+	"github.com/migadu/sora/server/delivery"
+)
+
+// handleDKIMCommand handles the 'dkim' command
+func handleDKIMCommand(ctx context.Context) {
+	if len(os.Args) < 3 {
+		printDKIMUsage()
+		os.Exit(1)
+	}
+
+	subcommand := os.Args[2]
+	switch subcommand {
+	case "generate":
+		handleDKIMCreate(ctx, false)
+	case "import":
+		handleDKIMCreate(ctx, true)
+	case "list":
+		handleDKIMList(ctx)
+	case "activate":
+		handleDKIMActivate(ctx)
+	case "delete":
+		handleDKIMDelete(ctx)
+	case "help", "--help", "-h":
+		printDKIMUsage()
+	default:
+		fmt.Printf("Unknown dkim subcommand: %s\n\n", subcommand)
+		printDKIMUsage()
+		os.Exit(1)
+	}
+}
+
+// handleDKIMCreate generates a new DKIM key, or imports one from a PEM file
+func handleDKIMCreate(ctx context.Context, imported bool) {
+	name := "dkim generate"
+	if imported {
+		name = "dkim import"
+	}
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	domain := fs.String("domain", "", "Domain the key signs for (required)")
+	selector := fs.String("selector", "", "DNS selector of the key (required)")
+	algorithm := fs.String("algorithm", "", "Key algorithm: rsa or ed25519")
+	bits := fs.Int("bits", dkim.DefaultRSABits, "RSA key size in bits")
+	keyFile := fs.String("key-file", "", "PEM private key to import")
+	active := fs.Bool("activate", false, "Make the key the domain's signing key")
+
+	fs.Usage = func() {
+		if imported {
+			fmt.Printf(`Import a DKIM private key for a domain
+
+Usage:
+  sora-admin dkim import --config PATH --domain DOMAIN --selector SELECTOR --key-file FILE [--activate]
+
+Options:
+  --config PATH        Path to TOML configuration file (required)
+  --domain DOMAIN      Domain the key signs for (required)
+  --selector SELECTOR  DNS selector of the key (required)
+  --key-file FILE      PEM-encoded RSA or Ed25519 private key, PKCS#1 or PKCS#8 (required)
+  --activate           Make the key the domain's signing key, replacing the current one
+
+Examples:
+  sora-admin dkim import --config config.toml --domain example.com --selector mail --key-file mail.pem --activate
+`)
+			return
+		}
+		fmt.Printf(`Generate a DKIM key for a domain
+
+Prints the DNS TXT record to publish. Activate the key once the record is
+visible; the active key signs relayed mail and seals redirects with ARC.
+
+Usage:
+  sora-admin dkim generate --config PATH --domain DOMAIN --selector SELECTOR [options]
+
+Options:
+  --config PATH        Path to TOML configuration file (required)
+  --domain DOMAIN      Domain the key signs for (required)
+  --selector SELECTOR  DNS selector of the key (required)
+  --algorithm ALG      rsa or ed25519 (default: rsa)
+  --bits N             RSA key size, 1024 to 4096 (default: %d)
+  --activate           Make the key the domain's signing key, replacing the current one
+
+Examples:
+  sora-admin dkim generate --config config.toml --domain example.com --selector sora2026
+  sora-admin dkim generate --config config.toml --domain example.com --selector ed2026 --algorithm ed25519
+`, dkim.DefaultRSABits)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	if *domain == "" || *selector == "" || (imported && *keyFile == "") {
+		if imported {
+			fmt.Println("Error: --domain, --selector and --key-file are required")
+		} else {
+			fmt.Println("Error: --domain and --selector are required")
+		}
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var privateKey string
+	if imported {
+		data, err := os.ReadFile(*keyFile)
+		if err != nil {
+			fmt.Printf("Error: failed to read key file: %v\n", err)
+			os.Exit(1)
+		}
+		privateKey = string(data)
+	}
+
+	key, err := delivery.NewDKIMKey(*domain, *selector, *algorithm, *bits, privateKey, *active)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	if err := rdb.CreateDKIMKeyWithRetry(ctx, key); err != nil {
+		if errors.Is(err, db.ErrDKIMKeyExists) {
+			fmt.Printf("Domain %s already has a DKIM key with selector %q\n", key.Domain, key.Selector)
+			os.Exit(1)
+		}
+		fmt.Printf("Failed to create DKIM key: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Created %s DKIM key %q for %s", key.Algorithm, key.Selector, key.Domain)
+	if key.Active {
+		fmt.Print(" (active)")
+	}
+	fmt.Printf("\n\nPublish this DNS record:\n\n%s. IN TXT \"%s\"\n", dkim.RecordName(key.Selector, key.Domain), key.PublicKey)
+	if !key.Active {
+		fmt.Printf("\nThen activate the key:\n\n  sora-admin dkim activate --domain %s --selector %s\n", key.Domain, key.Selector)
+	}
+}
+
+// handleDKIMList lists DKIM keys
+func handleDKIMList(ctx context.Context) {
+	fs := flag.NewFlagSet("dkim list", flag.ExitOnError)
+	domain := fs.String("domain", "", "Only list keys of this domain")
+	showRecords := fs.Bool("records", false, "Print the DNS record of each key")
+
+	fs.Usage = func() {
+		fmt.Printf(`List DKIM keys
+
+Usage:
+  sora-admin dkim list --config PATH [--domain DOMAIN] [--records]
+
+Options:
+  --config PATH      Path to TOML configuration file (required)
+  --domain DOMAIN    Only list keys of this domain
+  --records          Print the DNS record of each key
+
+Examples:
+  sora-admin dkim list --config config.toml
+  sora-admin dkim list --config config.toml --domain example.com --records
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	keys, err := rdb.ListDKIMKeysWithRetry(ctx, strings.ToLower(*domain))
+	if err != nil {
+		fmt.Printf("Failed to list DKIM keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(keys) == 0 {
+		fmt.Println("No DKIM keys found")
+		return
+	}
+
+	fmt.Printf("%-30s %-20s %-10s %-8s %-20s\n", "Domain", "Selector", "Algorithm", "Active", "Created")
+	fmt.Printf("%-30s %-20s %-10s %-8s %-20s\n", "------", "--------", "---------", "------", "-------")
+	for _, k := range keys {
+		active := "no"
+		if k.Active {
+			active = "yes"
+		}
+		fmt.Printf("%-30s %-20s %-10s %-8s %-20s\n", k.Domain, k.Selector, k.Algorithm, active, k.CreatedAt.Format("2006-01-02 15:04:05"))
+		if *showRecords {
+			fmt.Printf("  %s. IN TXT \"%s\"\n", dkim.RecordName(k.Selector, k.Domain), k.PublicKey)
+		}
+	}
+}
+
+// handleDKIMActivate makes a key its domain's signing key
+func handleDKIMActivate(ctx context.Context) {
+	fs := flag.NewFlagSet("dkim activate", flag.ExitOnError)
+	domain := fs.String("domain", "", "Domain of the key (required)")
+	selector := fs.String("selector", "", "Selector of the key (required)")
+
+	fs.Usage = func() {
+		fmt.Printf(`Make a DKIM key the domain's signing key
+
+The previously active key of the domain is deactivated. Running servers
+switch to the new key within five minutes.
+
+Usage:
+  sora-admin dkim activate --config PATH --domain DOMAIN --selector SELECTOR
+
+Options:
+  --config PATH        Path to TOML configuration file (required)
+  --domain DOMAIN      Domain of the key (required)
+  --selector SELECTOR  Selector of the key (required)
+
+Examples:
+  sora-admin dkim activate --config config.toml --domain example.com --selector sora2026
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	if *domain == "" || *selector == "" {
+		fmt.Println("Error: --domain and --selector are required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	*domain = strings.ToLower(*domain)
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	if err := rdb.ActivateDKIMKeyWithRetry(ctx, *domain, *selector); err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			fmt.Printf("No DKIM key with selector %q for %s\n", *selector, *domain)
+			os.Exit(1)
+		}
+		fmt.Printf("Failed to activate DKIM key: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Activated DKIM key %q for %s\n", *selector, *domain)
+}
+
+// handleDKIMDelete removes a key
+func handleDKIMDelete(ctx context.Context) {
+	fs := flag.NewFlagSet("dkim delete", flag.ExitOnError)
+	domain := fs.String("domain", "", "Domain of the key (required)")
+	selector := fs.String("selector", "", "Selector of the key (required)")
+
+	fs.Usage = func() {
+		fmt.Printf(`Delete a DKIM key
+
+Deleting the active key stops signing for the domain. Keep the DNS record
+published for a few days so mail signed before the deletion still verifies.
+
+Usage:
+  sora-admin dkim delete --config PATH --domain DOMAIN --selector SELECTOR
+
+Options:
+  --config PATH        Path to TOML configuration file (required)
+  --domain DOMAIN      Domain of the key (required)
+  --selector SELECTOR  Selector of the key (required)
+
+Examples:
+  sora-admin dkim delete --config config.toml --domain example.com --selector sora2025
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	if *domain == "" || *selector == "" {
+		fmt.Println("Error: --domain and --selector are required")
+		fs.Usage()
+		os.Exit(1)
+	}
+	*domain = strings.ToLower(*domain)
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	if err := rdb.DeleteDKIMKeyWithRetry(ctx, *domain, *selector); err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			fmt.Printf("No DKIM key with selector %q for %s\n", *selector, *domain)
+			os.Exit(1)
+		}
+		fmt.Printf("Failed to delete DKIM key: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Deleted DKIM key %q for %s\n", *selector, *domain)
+}
+
+func printDKIMUsage() {
+	fmt.Println(`Usage: sora-admin dkim <subcommand> [options]
+
+Subcommands:
+  generate      Generate a DKIM key for a domain and print its DNS record
+  import        Import a PEM private key for a domain
+  list          List DKIM keys
+  activate      Make a key the domain's signing key
+  delete        Delete a key
+
+The active key of a domain DKIM-signs relayed mail from the domain (vacation
+replies, redirects, bounces) and ARC-seals messages its accounts redirect.
+
+Examples:
+  sora-admin dkim generate --config config.toml --domain example.com --selector sora2026
+  sora-admin dkim list --config config.toml --domain example.com --records
+  sora-admin dkim activate --config config.toml --domain example.com --selector sora2026`)
+}
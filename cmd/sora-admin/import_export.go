@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/maildir"
 	"github.com/migadu/sora/storage"
 )
 
@@ -188,28 +189,18 @@ Examples:
 	// NOTE: Importer supports TestMode, but the CLI normally requires S3 connectivity.
 	// For integration tests (and some migration workflows), we allow skipping S3 by
 	// setting SORA_ADMIN_SKIP_S3=1.
-	var s3 *storage.S3Storage
+	var s3 storage.Backend
 	if os.Getenv("SORA_ADMIN_SKIP_S3") == "1" {
 		logger.Info("S3 disabled via SORA_ADMIN_SKIP_S3=1")
-		s3 = nil
 	} else {
-		s3Timeout, err := globalConfig.S3.GetTimeout()
+		s3, err = storage.NewFromConfig(&globalConfig.S3)
 		if err != nil {
-			logger.Fatalf("Failed to parse S3 timeout: %v", err)
-		}
-		s3, err = storage.New(globalConfig.S3.Endpoint, globalConfig.S3.AccessKey, globalConfig.S3.SecretKey, globalConfig.S3.Bucket, !globalConfig.S3.DisableTLS, globalConfig.S3.GetDebug(), s3Timeout)
-		if err != nil {
-			logger.Fatalf("Failed to connect to S3: %v", err)
-		}
-		if globalConfig.S3.Encrypt {
-			if err := s3.EnableEncryption(globalConfig.S3.EncryptionKey); err != nil {
-				logger.Fatalf("Failed to enable S3 encryption: %v", err)
-			}
+			logger.Fatalf("Failed to initialize object storage: %v", err)
 		}
 	}
 
 	// Create importer options
-	options := ImporterOptions{
+	options := maildir.ImporterOptions{
 		DryRun:               *dryRun,
 		StartDate:            startDateParsed,
 		EndDate:              endDateParsed,
@@ -230,7 +221,7 @@ Examples:
 		PathsFile:            *pathsFile,
 	}
 
-	importer, err := NewImporter(ctx, *maildirPath, *email, *jobs, rdb, s3, options)
+	importer, err := maildir.NewImporter(ctx, *maildirPath, *email, *jobs, rdb, s3, options)
 	if err != nil {
 		logger.Fatalf("Failed to create importer: %v", err)
 	}
@@ -449,26 +440,17 @@ Examples:
 	}
 	defer rdb.Close()
 
-	// Connect to S3
-	s3Timeout, err := globalConfig.S3.GetTimeout()
+	// Connect to object storage
+	s3, err := storage.NewFromConfig(&globalConfig.S3)
 	if err != nil {
-		logger.Fatalf("Failed to parse S3 timeout: %v", err)
-	}
-	s3, err := storage.New(globalConfig.S3.Endpoint, globalConfig.S3.AccessKey, globalConfig.S3.SecretKey, globalConfig.S3.Bucket, !globalConfig.S3.DisableTLS, globalConfig.S3.GetDebug(), s3Timeout)
-	if err != nil {
-		logger.Fatalf("Failed to connect to S3: %v", err)
-	}
-	if globalConfig.S3.Encrypt {
-		if err := s3.EnableEncryption(globalConfig.S3.EncryptionKey); err != nil {
-			logger.Fatalf("Failed to enable S3 encryption: %v", err)
-		}
+		logger.Fatalf("Failed to initialize object storage: %v", err)
 	}
 
 	// If dovecot flag is enabled, also enable UID list export
 	exportUIDListEnabled := *exportUIDList || *dovecot
 
 	// Create exporter options
-	options := ExporterOptions{
+	options := maildir.ExporterOptions{
 		DryRun:         *dryRun,
 		StartDate:      startDateParsed,
 		EndDate:        endDateParsed,
@@ -480,7 +462,7 @@ Examples:
 		ExportUIDList:  exportUIDListEnabled,
 	}
 
-	exporter, err := NewExporter(ctx, *maildirPath, *email, *jobs, rdb, s3, options)
+	exporter, err := maildir.NewExporter(ctx, *maildirPath, *email, *jobs, rdb, s3, options)
 	if err != nil {
 		logger.Fatalf("Failed to create exporter: %v", err)
 	}
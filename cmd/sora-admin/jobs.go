@@ -0,0 +1,513 @@
+package main
+
+// jobs.go - Asynchronous admin jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/server/adminjobs"
+	"github.com/migadu/sora/storage"
+)
+
+// handleJobsCommand handles the 'jobs' command
+func handleJobsCommand(ctx context.Context) {
+	if len(os.Args) < 3 {
+		printJobsUsage()
+		os.Exit(1)
+	}
+
+	subcommand := os.Args[2]
+	switch subcommand {
+	case "submit":
+		handleJobsSubmit(ctx)
+	case "list":
+		handleJobsList(ctx)
+	case "show":
+		handleJobsShow(ctx)
+	case "cancel":
+		handleJobsCancel(ctx)
+	case "retry":
+		handleJobsRetry(ctx)
+	case "worker":
+		handleJobsWorker(ctx)
+	case "help", "--help", "-h":
+		printJobsUsage()
+	default:
+		fmt.Printf("Unknown jobs subcommand: %s\n\n", subcommand)
+		printJobsUsage()
+		os.Exit(1)
+	}
+}
+
+// handleJobsSubmit queues a job
+func handleJobsSubmit(ctx context.Context) {
+	fs := flag.NewFlagSet("jobs submit", flag.ExitOnError)
+	jobType := fs.String("type", "", "Job type (required)")
+	email := fs.String("email", "", "Account address")
+	domain := fs.String("domain", "", "Domain (purge-domain)")
+	path := fs.String("path", "", "Maildir path relative to [jobs] data_path (import, export)")
+	mailboxes := fs.String("mailbox-filter", "", "Comma-separated mailboxes (import, export)")
+	dryRun := fs.Bool("dry-run", false, "Preview only (import, export)")
+	dovecot := fs.Bool("dovecot", false, "Read or write Dovecot metadata files (import, export)")
+	format := fs.String("format", "", "Archive format: mbox or maildir (takeout; default: mbox)")
+	maxAttempts := fs.Int("max-attempts", 0, "Attempts before the job fails (default: [jobs] max_attempts)")
+	wait := fs.Bool("wait", false, "Follow the job's progress until it finishes")
+
+	fs.Usage = func() {
+		fmt.Printf(`Submit an asynchronous admin job
+
+The job runs on a worker: a sora server with [jobs] enabled runs purge-account,
+purge-domain, verify-s3, rebuild-fts and takeout; import and export run only in
+'sora-admin jobs worker'.
+
+Usage:
+  sora-admin jobs submit --config PATH --type TYPE [options]
+
+Options:
+  --config PATH            Path to TOML configuration file (required)
+  --type TYPE              %s (required)
+  --email EMAIL            Account address (all types except purge-domain)
+  --domain DOMAIN          Domain (purge-domain)
+  --path PATH              Maildir path relative to [jobs] data_path (import, export)
+  --mailbox-filter LIST    Comma-separated mailboxes (import, export; default: all)
+  --dry-run                Preview only (import, export)
+  --dovecot                Read or write Dovecot metadata files (import, export)
+  --format FORMAT          Archive format: mbox or maildir (takeout; default: mbox)
+  --max-attempts N         Attempts before the job fails (default: [jobs] max_attempts)
+  --wait                   Follow the job's progress until it finishes
+
+Examples:
+  sora-admin jobs submit --config config.toml --type purge-domain --domain example.com
+  sora-admin jobs submit --config config.toml --type import --email user@example.com --path user/Maildir --wait
+`, strings.Join(adminjobs.Types, ", "))
+	}
+
+	fs.Parse(os.Args[3:])
+
+	params := map[string]any{}
+	switch *jobType {
+	case adminjobs.TypePurgeDomain:
+		params["domain"] = strings.ToLower(*domain)
+	case adminjobs.TypeImport, adminjobs.TypeExport:
+		params["email"] = *email
+		params["path"] = *path
+		if *mailboxes != "" {
+			params["mailbox"] = splitList(*mailboxes)
+		}
+		if *dryRun {
+			params["dry_run"] = true
+		}
+		if *dovecot {
+			params["dovecot"] = true
+		}
+	case adminjobs.TypeTakeout:
+		params["email"] = *email
+		if *format != "" {
+			params["format"] = *format
+		}
+	default:
+		params["email"] = *email
+	}
+	if err := adminjobs.ValidateParams(*jobType, params); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *maxAttempts <= 0 {
+		*maxAttempts = globalConfig.Jobs.GetMaxAttempts()
+	}
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	var createdBy string
+	if actor, ok := db.AuditActorFromContext(ctx); ok {
+		createdBy = actor.Actor
+	}
+	job, err := adminjobs.Submit(ctx, rdb, *jobType, params, createdBy, *maxAttempts)
+	if err != nil {
+		fmt.Printf("Failed to submit job: %v\n", err)
+		os.Exit(1)
+	}
+	recordAudit(ctx, rdb, db.AuditJobSubmit, adminjobs.Target(job), map[string]any{"job_id": job.ID, "type": job.Type})
+	fmt.Printf("Submitted job %d (%s)\n", job.ID, job.Type)
+
+	if *wait {
+		watchJob(ctx, rdb, job.ID)
+	}
+}
+
+// handleJobsList lists jobs
+func handleJobsList(ctx context.Context) {
+	fs := flag.NewFlagSet("jobs list", flag.ExitOnError)
+	status := fs.String("status", "", "Filter by status")
+	jobType := fs.String("type", "", "Filter by type")
+	limit := fs.Int("limit", 100, "Maximum number of jobs to show")
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+
+	fs.Usage = func() {
+		fmt.Printf(`List admin jobs, newest first
+
+Usage:
+  sora-admin jobs list --config PATH [options]
+
+Options:
+  --config PATH      Path to TOML configuration file (required)
+  --status STATUS    Filter by status: queued, running, succeeded, failed, cancelled
+  --type TYPE        Filter by type
+  --limit N          Maximum number of jobs to show (default: 100, max: 1000)
+  --json             Output in JSON format
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	jobs, err := rdb.ListAdminJobsWithRetry(ctx, db.AdminJobFilter{Status: *status, Type: *jobType, Limit: *limit})
+	if err != nil {
+		fmt.Printf("Failed to list jobs: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(jobs); err != nil {
+			fmt.Printf("Failed to encode JSON: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(jobs) == 0 {
+		fmt.Println("No jobs found")
+		return
+	}
+
+	fmt.Printf("%-8s %-14s %-10s %-30s %-9s %-8s %-20s\n", "ID", "Type", "Status", "Target", "Progress", "Attempt", "Created")
+	fmt.Printf("%-8s %-14s %-10s %-30s %-9s %-8s %-20s\n", "--", "----", "------", "------", "--------", "-------", "-------")
+	for _, j := range jobs {
+		fmt.Printf("%-8d %-14s %-10s %-30s %-9s %-8s %-20s\n",
+			j.ID, j.Type, j.Status, truncateString(adminjobs.Target(j), 30), formatJobPercent(j),
+			fmt.Sprintf("%d/%d", j.Attempts, j.MaxAttempts), j.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+}
+
+// handleJobsShow shows one job, optionally following it
+func handleJobsShow(ctx context.Context) {
+	fs := flag.NewFlagSet("jobs show", flag.ExitOnError)
+	id := fs.Int64("id", 0, "Job ID (required)")
+	watch := fs.Bool("watch", false, "Follow the job's progress until it finishes")
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+
+	fs.Usage = func() {
+		fmt.Printf(`Show an admin job with its progress and result
+
+Usage:
+  sora-admin jobs show --config PATH --id ID [options]
+
+Options:
+  --config PATH    Path to TOML configuration file (required)
+  --id ID          Job ID (required)
+  --watch          Follow the job's progress until it finishes
+  --json           Output in JSON format
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+	if *id <= 0 {
+		fmt.Println("Error: --id is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	if *watch {
+		watchJob(ctx, rdb, *id)
+		return
+	}
+
+	job, err := rdb.GetAdminJobWithRetry(ctx, *id)
+	if err != nil {
+		exitJobError(err)
+	}
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(job); err != nil {
+			fmt.Printf("Failed to encode JSON: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	printJob(job)
+}
+
+// handleJobsCancel cancels a job
+func handleJobsCancel(ctx context.Context) {
+	handleJobsTransition(ctx, "cancel", "Cancel a queued or running job", db.AuditJobCancel,
+		func(rdb *resilient.ResilientDatabase, id int64) (*db.AdminJob, error) {
+			return rdb.CancelAdminJobWithRetry(ctx, id)
+		})
+}
+
+// handleJobsRetry queues a failed or cancelled job again
+func handleJobsRetry(ctx context.Context) {
+	handleJobsTransition(ctx, "retry", "Queue a failed or cancelled job again", db.AuditJobRetry,
+		func(rdb *resilient.ResilientDatabase, id int64) (*db.AdminJob, error) {
+			return rdb.RetryAdminJobWithRetry(ctx, id)
+		})
+}
+
+func handleJobsTransition(ctx context.Context, name, description, action string, op func(*resilient.ResilientDatabase, int64) (*db.AdminJob, error)) {
+	fs := flag.NewFlagSet("jobs "+name, flag.ExitOnError)
+	id := fs.Int64("id", 0, "Job ID (required)")
+
+	fs.Usage = func() {
+		fmt.Printf(`%s
+
+Usage:
+  sora-admin jobs %s --config PATH --id ID
+
+Options:
+  --config PATH    Path to TOML configuration file (required)
+  --id ID          Job ID (required)
+`, description, name)
+	}
+
+	fs.Parse(os.Args[3:])
+	if *id <= 0 {
+		fmt.Println("Error: --id is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	job, err := op(rdb, *id)
+	if err != nil {
+		exitJobError(err)
+	}
+	recordAudit(ctx, rdb, action, adminjobs.Target(job), map[string]any{"job_id": job.ID, "type": job.Type})
+
+	switch {
+	case job.Status == db.AdminJobRunning:
+		fmt.Printf("Cancellation requested; job %d stops at its worker's next heartbeat\n", job.ID)
+	default:
+		fmt.Printf("Job %d is now %s\n", job.ID, job.Status)
+	}
+}
+
+// handleJobsWorker runs a job worker in the foreground
+func handleJobsWorker(ctx context.Context) {
+	fs := flag.NewFlagSet("jobs worker", flag.ExitOnError)
+	concurrency := fs.Int("concurrency", 0, "Jobs run at the same time (default: [jobs] concurrency)")
+
+	fs.Usage = func() {
+		fmt.Printf(`Run a job worker until interrupted
+
+Runs every job type, including import and export, which the sora server cannot
+run. Paths of import and export jobs are resolved inside [jobs] data_path
+(%s). Interrupted jobs are queued again.
+
+Usage:
+  sora-admin jobs worker --config PATH [options]
+
+Options:
+  --config PATH        Path to TOML configuration file (required)
+  --concurrency N      Jobs run at the same time (default: [jobs] concurrency)
+`, globalConfig.Jobs.GetDataPath())
+	}
+
+	fs.Parse(os.Args[3:])
+
+	opts, err := adminjobs.OptionsFromConfig(&globalConfig.Jobs)
+	if err != nil {
+		fmt.Printf("Error: invalid [jobs] configuration: %v\n", err)
+		os.Exit(1)
+	}
+	if *concurrency > 0 {
+		opts.Concurrency = *concurrency
+	}
+
+	rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+	if err != nil {
+		fmt.Printf("Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	defer rdb.Close()
+
+	backend, err := storage.NewFromConfig(&globalConfig.S3)
+	if err != nil {
+		fmt.Printf("Failed to initialize object storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	hostname, _ := os.Hostname()
+	worker := adminjobs.NewWorker(rdb, fmt.Sprintf("sora-admin@%s:%d", hostname, os.Getpid()), opts)
+	adminjobs.RegisterBuiltins(worker, rdb, backend, globalConfig.Cleanup.GetFTSRetentionWithDefault())
+	dataPath := globalConfig.Jobs.GetDataPath()
+	adminjobs.RegisterMaildir(worker, rdb, backend, dataPath, globalConfig.GetImportMessageLimit())
+	adminjobs.RegisterTakeout(worker, rdb, backend, nil, dataPath)
+
+	fmt.Printf("Job worker running (types: %s); press Ctrl+C to stop\n", strings.Join(worker.Types(), ", "))
+	worker.Start(ctx)
+	<-ctx.Done()
+	fmt.Println("Stopping; running jobs will be queued again")
+	worker.Stop()
+}
+
+// watchJob prints a job's progress until it finishes or ctx is cancelled.
+func watchJob(ctx context.Context, rdb *resilient.ResilientDatabase, id int64) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	last := ""
+	for {
+		job, err := rdb.GetAdminJobWithRetry(ctx, id)
+		if err != nil {
+			exitJobError(err)
+		}
+		line := fmt.Sprintf("[%s] %s %s", job.Status, formatJobPercent(job), job.ProgressMessage)
+		if job.ETASeconds != nil {
+			line += fmt.Sprintf(" (ETA %s)", time.Duration(*job.ETASeconds)*time.Second)
+		}
+		if line != last {
+			fmt.Println(line)
+			last = line
+		}
+		if job.IsFinished() {
+			fmt.Println()
+			printJob(job)
+			if job.Status != db.AdminJobSucceeded {
+				os.Exit(2)
+			}
+			return
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Printf("Stopped watching; job %d keeps running\n", id)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func printJob(job *db.AdminJob) {
+	fmt.Printf("Job %d\n", job.ID)
+	fmt.Printf("  Type:        %s\n", job.Type)
+	fmt.Printf("  Target:      %s\n", adminjobs.Target(job))
+	fmt.Printf("  Status:      %s\n", job.Status)
+	if job.CancelRequested && !job.IsFinished() {
+		fmt.Printf("  Cancel:      requested\n")
+	}
+	fmt.Printf("  Attempts:    %d/%d\n", job.Attempts, job.MaxAttempts)
+	fmt.Printf("  Progress:    %s (%d/%d)\n", formatJobPercent(job), job.ProgressDone, job.ProgressTotal)
+	if job.ProgressMessage != "" {
+		fmt.Printf("  Message:     %s\n", job.ProgressMessage)
+	}
+	if job.ETASeconds != nil {
+		fmt.Printf("  ETA:         %s\n", time.Duration(*job.ETASeconds)*time.Second)
+	}
+	fmt.Printf("  Created:     %s by %s\n", job.CreatedAt.Local().Format("2006-01-02 15:04:05"), job.CreatedBy)
+	if job.StartedAt != nil {
+		fmt.Printf("  Started:     %s on %s\n", job.StartedAt.Local().Format("2006-01-02 15:04:05"), job.WorkerID)
+	}
+	if job.FinishedAt != nil {
+		fmt.Printf("  Finished:    %s\n", job.FinishedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	if job.LastError != "" {
+		fmt.Printf("  Last error:  %s\n", job.LastError)
+	}
+	if len(job.Result) > 0 {
+		result, _ := json.MarshalIndent(job.Result, "  ", "  ")
+		fmt.Printf("  Result:      %s\n", result)
+	}
+}
+
+func formatJobPercent(job *db.AdminJob) string {
+	if job.ProgressTotal <= 0 && job.Status != db.AdminJobSucceeded {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", job.Percent)
+}
+
+func exitJobError(err error) {
+	switch {
+	case errors.Is(err, consts.ErrDBNotFound):
+		fmt.Println("Job not found")
+	case errors.Is(err, db.ErrAdminJobState):
+		fmt.Printf("Error: %v\n", err)
+	default:
+		fmt.Printf("Job operation failed: %v\n", err)
+	}
+	os.Exit(1)
+}
+
+func printJobsUsage() {
+	fmt.Printf(`Asynchronous Admin Jobs
+
+Long operations run as jobs on a worker, survive the client that started them,
+and report progress. A sora server with [jobs] enabled runs purge-account,
+purge-domain, verify-s3, rebuild-fts and takeout; 'sora-admin jobs worker' runs
+every type, including import and export.
+
+Usage:
+  sora-admin jobs <subcommand> [options]
+
+Subcommands:
+  submit   Queue a job
+  list     List jobs
+  show     Show a job's progress and result (--watch to follow it)
+  cancel   Cancel a queued or running job
+  retry    Queue a failed or cancelled job again
+  worker   Run a job worker in the foreground
+
+Job types:
+  import         Import a maildir under [jobs] data_path (--email, --path)
+  export         Export to a maildir under [jobs] data_path (--email, --path)
+  purge-account  Delete an account with all its data (--email)
+  purge-domain   Delete all accounts and aliases of a domain (--domain)
+  verify-s3      Check that every message body exists in object storage (--email)
+  rebuild-fts    Re-index message bodies missing from full-text search (--email)
+  takeout        Zip an account's mail and settings into object storage (--email, --format)
+
+Examples:
+  sora-admin jobs submit --config config.toml --type verify-s3 --email user@example.com
+  sora-admin jobs list --config config.toml --status running
+  sora-admin jobs show --config config.toml --id 42 --watch
+  sora-admin jobs cancel --config config.toml --id 42
+
+Use 'sora-admin jobs <subcommand> --help' for detailed help.
+`)
+}
@@ -258,34 +258,12 @@ Examples:
 	if *purge {
 		fmt.Printf("Purging all messages from mailbox '%s' and its children...\n", *mailbox)
 
-		// Initialize S3 storage
-		useSSL := !globalConfig.S3.DisableTLS
-		s3Timeout, err := globalConfig.S3.GetTimeout()
+		// Initialize object storage
+		s3Storage, err := storage.NewFromConfig(&globalConfig.S3)
 		if err != nil {
-			fmt.Printf("Invalid S3 timeout configuration: %v\n", err)
+			fmt.Printf("Failed to initialize object storage: %v\n", err)
 			os.Exit(1)
 		}
-		s3Storage, err := storage.New(
-			globalConfig.S3.Endpoint,
-			globalConfig.S3.AccessKey,
-			globalConfig.S3.SecretKey,
-			globalConfig.S3.Bucket,
-			useSSL,
-			false,     // debug mode
-			s3Timeout, // timeout
-		)
-		if err != nil {
-			fmt.Printf("Failed to initialize S3 storage: %v\n", err)
-			os.Exit(1)
-		}
-
-		// Enable encryption if configured
-		if globalConfig.S3.Encrypt {
-			if err := s3Storage.EnableEncryption(globalConfig.S3.EncryptionKey); err != nil {
-				fmt.Printf("Failed to enable S3 encryption: %v\n", err)
-				os.Exit(1)
-			}
-		}
 
 		err = purgeMailboxMessages(ctx, rdb, s3Storage, accountID, *mailbox)
 		if err != nil {
@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/migadu/sora/config"
-	"github.com/migadu/sora/helpers"
 	"github.com/migadu/sora/logger"
 	"github.com/migadu/sora/pkg/resilient"
 )
@@ -44,27 +43,13 @@ type AdminConfig struct {
 	HTTPAPIKey                string                       `toml:"http_api_key"`                  // HTTP API key for authentication
 	HTTPAPIInsecureSkipVerify bool                         `toml:"http_api_insecure_skip_verify"` // Skip TLS cert verification. Default: auto (loopback addr -> skip; remote -> verify).
 	Relay                     config.RelayConfig           `toml:"relay"`
+	Sieve                     config.SieveConfig           `toml:"sieve"`
+	Jobs                      config.JobsConfig            `toml:"jobs"`
 }
 
 // GetImportMessageLimit returns the import message size limit with proper fallback logic
 func (c *AdminConfig) GetImportMessageLimit() int64 {
-	// Priority 1: Use admin_cli.import_message_limit if configured
-	if c.AdminCLI.ImportMessageLimit != "" {
-		limit, err := helpers.ParseSize(c.AdminCLI.ImportMessageLimit)
-		if err != nil {
-			logger.Warn("Failed to parse admin_cli.import_message_limit, using fallback", "value", c.AdminCLI.ImportMessageLimit, "error", err)
-		} else {
-			return limit
-		}
-	}
-
-	// Priority 2: Fall back to the configured IMAP server's append_limit
-	if appendLimit, err := config.IMAPAppendLimit(c.DynamicServers); err == nil && appendLimit > 0 {
-		return appendLimit
-	}
-
-	// Priority 3: Use default (50MB - larger than IMAP default since imports are batch operations)
-	return 50 * 1024 * 1024
+	return c.AdminCLI.GetImportMessageLimit(c.DynamicServers)
 }
 
 // newAdminDatabase creates a resilient database for admin CLI operations.
@@ -117,6 +102,7 @@ func loadAdminConfig(configPath string, cfg *AdminConfig) error {
 	cfg.HTTPAPIAddr = fullCfg.AdminCLI.Addr
 	cfg.HTTPAPIKey = fullCfg.AdminCLI.APIKey
 	cfg.Relay = fullCfg.Relay
+	cfg.Jobs = fullCfg.Jobs
 
 	// Default: verify the Admin API server's TLS certificate. Skip verification
 	// automatically only for loopback addresses (local admin use with self-signed
@@ -240,6 +226,9 @@ func main() {
 	// Replace os.Args with filtered args (without --config)
 	os.Args = newArgs
 
+	// Attribute changes made by this run to the local operator in the audit log
+	ctx = withAdminAuditActor(ctx)
+
 	switch command {
 	case "accounts":
 		handleAccountsCommand(ctx)
@@ -261,6 +250,8 @@ func main() {
 		handleConnectionsCommand(ctx)
 	case "affinity":
 		handleAffinityCommand(ctx)
+	case "access-list":
+		handleAccessListCommand(ctx)
 	case "health":
 		handleHealthCommand(ctx)
 	case "migrate":
@@ -279,10 +270,22 @@ func main() {
 		handleRelayCommand(ctx)
 	case "verify":
 		handleVerifyCommand(ctx)
+	case "storage":
+		handleStorageCommand(ctx)
 	case "tls":
 		handleTLSCommand(ctx)
 	case "sieve":
 		handleSieveCommand(ctx)
+	case "api-keys":
+		handleAPIKeysCommand(ctx)
+	case "dkim":
+		handleDKIMCommand(ctx)
+	case "audit":
+		handleAuditCommand(ctx)
+	case "delivery":
+		handleDeliveryCommand(ctx)
+	case "jobs":
+		handleJobsCommand(ctx)
 	default:
 		fmt.Printf("Unknown command: %s\n\n", command)
 		printUsage()
@@ -313,7 +316,8 @@ Commands:
   affinity-cache Affinity cache management (persistent user-to-backend cache)
   stats         System statistics and analytics
   connections   Connection management
-  affinity      User-to-backend affinity management
+  affinity      User-to-backend affinity management and backend drain
+  access-list   Block or allow networks and usernames, unblock, firewall export
   health        System health status
   config        Configuration management
   migrate       Database schema migration management
@@ -321,10 +325,16 @@ Commands:
   messages      List and restore deleted messages
   relay         Relay queue management (stats, list, show, delete, requeue)
   verify        Verify data integrity (S3 storage, etc.)
+  storage       Object storage maintenance (recompress existing bodies)
   import        Import maildir data
   export        Export maildir data
   tls           TLS certificate management (list certificates from S3 and cache)
   sieve         Manage user Sieve filtering scripts
+  api-keys      Manage scoped Admin API keys
+  dkim          Manage per-domain DKIM signing keys
+  audit         List and verify the administrative audit log
+  delivery      Search the per-message delivery log
+  jobs          Run and track asynchronous jobs (import, export, purge, verify, FTS rebuild)
   version       Show version information
   help          Show this help message
 
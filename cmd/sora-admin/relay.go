@@ -2,11 +2,11 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -45,21 +45,9 @@ func handleRelayStats(_ context.Context) {
 	flags := flag.NewFlagSet("relay stats", flag.ExitOnError)
 	flags.Parse(os.Args[3:])
 
-	// Use global config for relay queue path
 	cfg := globalConfig
-
-	if !cfg.Relay.IsQueueEnabled() {
-		fmt.Println("Relay is not configured (queue is enabled automatically when relay is configured)")
-		os.Exit(1)
-	}
-
-	// Create queue instance (just for stats, doesn't start worker)
+	queue := openRelayQueue(cfg)
 	queuePath := cfg.Relay.GetQueuePath()
-	queue, err := relayqueue.NewDiskQueue(queuePath, cfg.Relay.Queue.MaxAttempts, nil)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error accessing relay queue: %v\n", err)
-		os.Exit(1)
-	}
 
 	// Get stats
 	pending, processing, failed, err := queue.GetStats()
@@ -80,70 +68,114 @@ func handleRelayStats(_ context.Context) {
 	fmt.Printf("Worker Interval:  %s\n", cfg.Relay.Queue.WorkerInterval)
 }
 
+// openRelayQueue opens the configured relay queue without starting a worker.
+func openRelayQueue(cfg AdminConfig) *relayqueue.DiskQueue {
+	if !cfg.Relay.IsQueueEnabled() {
+		fmt.Println("Relay is not configured (queue is enabled automatically when relay is configured)")
+		os.Exit(1)
+	}
+	queue, err := relayqueue.NewDiskQueue(cfg.Relay.GetQueuePath(), cfg.Relay.Queue.MaxAttempts, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error accessing relay queue: %v\n", err)
+		os.Exit(1)
+	}
+	priorities, err := relayqueue.ParsePriorities(cfg.Relay.Queue.Priorities)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in relay queue priorities: %v\n", err)
+		os.Exit(1)
+	}
+	queue.SetPriorities(priorities)
+	return queue
+}
+
+// relayRouter returns a router over the configured relay routes, used only to
+// tell which route each message takes.
+func relayRouter(cfg AdminConfig) *relayqueue.Router {
+	routes := make([]relayqueue.Route, 0, len(cfg.Relay.Routes))
+	for _, rc := range cfg.Relay.Routes {
+		routes = append(routes, relayqueue.Route{
+			Name:             rc.Name,
+			SenderDomains:    rc.SenderDomains,
+			RecipientDomains: rc.RecipientDomains,
+		})
+	}
+	return relayqueue.NewRouter(nil, routes)
+}
+
+// relayMessageKey returns the value of a message for a --group-by dimension.
+func relayMessageKey(queue *relayqueue.DiskQueue, router *relayqueue.Router, msg relayqueue.QueuedMessage, dimension string) string {
+	switch dimension {
+	case "type":
+		return msg.Type
+	case "priority":
+		return relayqueue.PriorityName(queue.Priority(msg.Type))
+	case "domain":
+		return relayqueue.RecipientDomain(msg.To)
+	case "route":
+		return router.Route(msg.From, msg.To)
+	default:
+		return ""
+	}
+}
+
 func handleRelayList(_ context.Context) {
 	flags := flag.NewFlagSet("relay list", flag.ExitOnError)
 	queueType := flags.String("queue", "pending", "Queue to list (pending, processing, failed)")
 	limit := flags.Int("limit", 100, "Maximum number of messages to display")
+	msgType := flags.String("type", "", "Only messages of this type (redirect, forward, vacation, notification, bounce)")
+	priority := flags.String("priority", "", "Only messages of this priority class (high, normal, low)")
+	domain := flags.String("domain", "", "Only messages to this recipient domain")
+	route := flags.String("route", "", "Only messages taking this relay route ('default' for the main relay)")
+	groupBy := flags.String("group-by", "", "Count messages by type, priority, domain, or route instead of listing them")
 	flags.Parse(os.Args[3:])
 
-	// Use global config
-	cfg := globalConfig
-
-	if !cfg.Relay.IsQueueEnabled() {
-		fmt.Println("Relay is not configured (queue is enabled automatically when relay is configured)")
-		os.Exit(1)
-	}
-
-	// Determine queue directory
-	var queueDir string
-	switch *queueType {
-	case "pending":
-		queueDir = filepath.Join(cfg.Relay.GetQueuePath(), "pending")
-	case "processing":
-		queueDir = filepath.Join(cfg.Relay.GetQueuePath(), "processing")
-	case "failed":
-		queueDir = filepath.Join(cfg.Relay.GetQueuePath(), "failed")
+	switch *groupBy {
+	case "", "type", "priority", "domain", "route":
 	default:
-		fmt.Fprintf(os.Stderr, "Invalid queue type: %s (must be pending, processing, or failed)\n", *queueType)
+		fmt.Fprintf(os.Stderr, "Error: --group-by must be type, priority, domain, or route\n")
 		os.Exit(1)
 	}
+	if *priority != "" {
+		if _, err := relayqueue.ParsePriority(*priority); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	// Read directory
-	entries, err := os.ReadDir(queueDir)
+	queue := openRelayQueue(globalConfig)
+	router := relayRouter(globalConfig)
+
+	// Filters and grouping need the whole queue; otherwise ask for one more
+	// than the limit to know whether there are more
+	filtered := *msgType != "" || *priority != "" || *domain != "" || *route != ""
+	listLimit := *limit + 1
+	if filtered || *groupBy != "" {
+		listLimit = 0
+	}
+	all, err := queue.List(*queueType, listLimit)
 	if err != nil {
-		if os.IsNotExist(err) {
-			fmt.Printf("Queue directory does not exist: %s\n", queueDir)
-			fmt.Println("This may indicate the relay queue has never been used.")
-			os.Exit(0)
-		}
-		fmt.Fprintf(os.Stderr, "Error reading queue directory: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Collect metadata files
-	messages := []relayqueue.QueuedMessage{}
-	count := 0
-	for _, entry := range entries {
-		if count >= *limit {
-			break
+	messages := all[:0]
+	for _, msg := range all {
+		if (*msgType != "" && msg.Type != *msgType) ||
+			(*priority != "" && !strings.EqualFold(relayMessageKey(queue, router, msg, "priority"), *priority)) ||
+			(*domain != "" && relayqueue.RecipientDomain(msg.To) != strings.ToLower(*domain)) ||
+			(*route != "" && relayMessageKey(queue, router, msg, "route") != *route) {
+			continue
 		}
-		if filepath.Ext(entry.Name()) == ".json" {
-			metadataPath := filepath.Join(queueDir, entry.Name())
-			data, err := os.ReadFile(metadataPath)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Could not read %s: %v\n", entry.Name(), err)
-				continue
-			}
+		messages = append(messages, msg)
+	}
 
-			var msg relayqueue.QueuedMessage
-			if err := json.Unmarshal(data, &msg); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: Could not parse %s: %v\n", entry.Name(), err)
-				continue
-			}
+	if *groupBy != "" {
+		printRelayGroups(queue, router, messages, *queueType, *groupBy)
+		return
+	}
 
-			messages = append(messages, msg)
-			count++
-		}
+	truncated := len(messages) > *limit
+	if truncated {
+		messages = messages[:*limit]
 	}
 
 	// Display messages
@@ -156,7 +188,7 @@ func handleRelayList(_ context.Context) {
 	fmt.Println(strings.Repeat("=", 80))
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "ID\tTYPE\tFROM\tTO\tATTEMPTS\tQUEUED\tNEXT RETRY")
+	fmt.Fprintln(w, "ID\tTYPE\tPRIORITY\tROUTE\tFROM\tTO\tATTEMPTS\tQUEUED\tNEXT RETRY")
 
 	for _, msg := range messages {
 		queuedStr := msg.QueuedAt.Format("2006-01-02 15:04:05")
@@ -171,16 +203,69 @@ func handleRelayList(_ context.Context) {
 			idShort = idShort[:8]
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
-			idShort, msg.Type, msg.From, msg.To, msg.Attempts, queuedStr, nextRetryStr)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			idShort, msg.Type, relayMessageKey(queue, router, msg, "priority"), relayMessageKey(queue, router, msg, "route"),
+			msg.From, msg.To, msg.Attempts, queuedStr, nextRetryStr)
 	}
 	w.Flush()
 
-	if len(messages) >= *limit {
+	if truncated {
 		fmt.Printf("\n(Showing first %d messages. Use --limit to see more)\n", *limit)
 	}
 }
 
+// printRelayGroups prints how many messages fall in each value of a
+// dimension, largest group first, with the oldest message of each.
+func printRelayGroups(queue *relayqueue.DiskQueue, router *relayqueue.Router, messages []relayqueue.QueuedMessage, queueType, dimension string) {
+	if len(messages) == 0 {
+		fmt.Printf("No messages in %s queue\n", queueType)
+		return
+	}
+
+	type group struct {
+		key    string
+		count  int
+		oldest time.Time
+	}
+	byKey := make(map[string]*group)
+	for _, msg := range messages {
+		key := relayMessageKey(queue, router, msg, dimension)
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{key: key, oldest: msg.QueuedAt}
+			byKey[key] = g
+		}
+		g.count++
+		if msg.QueuedAt.Before(g.oldest) {
+			g.oldest = msg.QueuedAt
+		}
+	}
+	groups := make([]*group, 0, len(byKey))
+	for _, g := range byKey {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+		return groups[i].key < groups[j].key
+	})
+
+	fmt.Printf("%s Queue by %s (%d messages)\n", queueType, dimension, len(messages))
+	fmt.Println(strings.Repeat("=", 80))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tMESSAGES\tOLDEST\n", strings.ToUpper(dimension))
+	for _, g := range groups {
+		key := g.key
+		if key == "" {
+			key = "(none)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", key, g.count, g.oldest.Format("2006-01-02 15:04:05"))
+	}
+	w.Flush()
+}
+
 func handleRelayShow(_ context.Context) {
 	flags := flag.NewFlagSet("relay show", flag.ExitOnError)
 	messageID := flags.String("id", "", "Message ID to display (required)")
@@ -193,41 +278,20 @@ func handleRelayShow(_ context.Context) {
 		os.Exit(1)
 	}
 
-	// Use global config
-	cfg := globalConfig
+	queue := openRelayQueue(globalConfig)
 
-	if !cfg.Relay.IsQueueEnabled() {
-		fmt.Println("Relay is not configured (queue is enabled automatically when relay is configured)")
-		os.Exit(1)
-	}
-
-	// Try to find the message in the specified queue
-	queueDir := filepath.Join(cfg.Relay.GetQueuePath(), *queueType)
-	metadataPath := filepath.Join(queueDir, *messageID+".json")
-	messagePath := filepath.Join(queueDir, *messageID+".msg")
-
-	// Read metadata
-	data, err := os.ReadFile(metadataPath)
+	msg, messageBody, err := queue.Get(*queueType, *messageID)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, relayqueue.ErrMessageNotFound) {
 			fmt.Fprintf(os.Stderr, "Message ID %s not found in %s queue\n", *messageID, *queueType)
 			fmt.Fprintf(os.Stderr, "Try searching other queues with --queue flag\n")
 			os.Exit(1)
 		}
-		fmt.Fprintf(os.Stderr, "Error reading metadata: %v\n", err)
-		os.Exit(1)
-	}
-
-	var msg relayqueue.QueuedMessage
-	if err := json.Unmarshal(data, &msg); err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing metadata: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
-
-	// Read message body
-	messageBody, err := os.ReadFile(messagePath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: Could not read message body: %v\n", err)
+	if messageBody == nil {
+		fmt.Fprintf(os.Stderr, "Warning: Could not read message body\n")
 		messageBody = []byte("(message body not available)")
 	}
 
@@ -236,6 +300,8 @@ func handleRelayShow(_ context.Context) {
 	fmt.Println("============================")
 	fmt.Printf("ID:           %s\n", msg.ID)
 	fmt.Printf("Type:         %s\n", msg.Type)
+	fmt.Printf("Priority:     %s\n", relayqueue.PriorityName(queue.Priority(msg.Type)))
+	fmt.Printf("Route:        %s\n", relayRouter(globalConfig).Route(msg.From, msg.To))
 	fmt.Printf("From:         %s\n", msg.From)
 	fmt.Printf("To:           %s\n", msg.To)
 	fmt.Printf("Queue:        %s\n", *queueType)
@@ -279,60 +345,24 @@ func handleRelayDelete(_ context.Context) {
 		os.Exit(1)
 	}
 
-	// Use global config
-	cfg := globalConfig
-
-	if !cfg.Relay.IsQueueEnabled() {
-		fmt.Println("Relay is not configured (queue is enabled automatically when relay is configured)")
-		os.Exit(1)
-	}
-
-	queueDir := filepath.Join(cfg.Relay.GetQueuePath(), *queueType)
+	queue := openRelayQueue(globalConfig)
 
 	if *messageID == "all" {
-		// Delete all messages in the queue
-		entries, err := os.ReadDir(queueDir)
+		deleted, err := queue.DeleteAll(*queueType)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading queue directory: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-
-		deleted := 0
-		for _, entry := range entries {
-			name := entry.Name()
-			if filepath.Ext(name) == ".json" || filepath.Ext(name) == ".msg" {
-				if err := os.Remove(filepath.Join(queueDir, name)); err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: Could not delete %s: %v\n", name, err)
-				} else {
-					if filepath.Ext(name) == ".json" {
-						deleted++
-					}
-				}
-			}
-		}
-
 		fmt.Printf("Deleted %d messages from %s queue\n", deleted, *queueType)
 	} else {
-		// Delete specific message
-		metadataPath := filepath.Join(queueDir, *messageID+".json")
-		messagePath := filepath.Join(queueDir, *messageID+".msg")
-
-		// Check if message exists
-		if _, err := os.Stat(metadataPath); os.IsNotExist(err) {
-			fmt.Fprintf(os.Stderr, "Message ID %s not found in %s queue\n", *messageID, *queueType)
-			os.Exit(1)
-		}
-
-		// Delete both files
-		if err := os.Remove(metadataPath); err != nil {
-			fmt.Fprintf(os.Stderr, "Error deleting metadata: %v\n", err)
+		if err := queue.Delete(*queueType, *messageID); err != nil {
+			if errors.Is(err, relayqueue.ErrMessageNotFound) {
+				fmt.Fprintf(os.Stderr, "Message ID %s not found in %s queue\n", *messageID, *queueType)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-
-		if err := os.Remove(messagePath); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Error deleting message body: %v\n", err)
-		}
-
 		fmt.Printf("Deleted message %s from %s queue\n", *messageID, *queueType)
 	}
 }
@@ -354,106 +384,24 @@ func handleRelayRequeue(_ context.Context) {
 		os.Exit(1)
 	}
 
-	// Use global config
-	cfg := globalConfig
-
-	if !cfg.Relay.IsQueueEnabled() {
-		fmt.Println("Relay is not configured (queue is enabled automatically when relay is configured)")
-		os.Exit(1)
-	}
-
-	failedDir := filepath.Join(cfg.Relay.GetQueuePath(), "failed")
-	pendingDir := filepath.Join(cfg.Relay.GetQueuePath(), "pending")
+	queue := openRelayQueue(globalConfig)
 
 	if *messageID == "all" {
-		// Requeue all failed messages
-		entries, err := os.ReadDir(failedDir)
+		requeued, err := queue.RequeueAll()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error reading failed queue: %v\n", err)
 			os.Exit(1)
 		}
-
-		requeued := 0
-		for _, entry := range entries {
-			name := entry.Name()
-			if filepath.Ext(name) == ".json" {
-				id := name[:len(name)-5] // Remove .json extension
-
-				// Reset metadata
-				if err := requeueMessage(failedDir, pendingDir, id); err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: Could not requeue %s: %v\n", id, err)
-				} else {
-					requeued++
-				}
-			}
-		}
-
 		fmt.Printf("Requeued %d messages from failed to pending queue\n", requeued)
 	} else {
-		// Requeue specific message
-		if err := requeueMessage(failedDir, pendingDir, *messageID); err != nil {
+		if err := queue.Requeue(*messageID); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
-
 		fmt.Printf("Requeued message %s from failed to pending queue\n", *messageID)
 	}
 }
 
-func requeueMessage(failedDir, pendingDir, messageID string) error {
-	metadataPath := filepath.Join(failedDir, messageID+".json")
-	messagePath := filepath.Join(failedDir, messageID+".msg")
-
-	// Read metadata
-	data, err := os.ReadFile(metadataPath)
-	if err != nil {
-		return fmt.Errorf("could not read metadata: %w", err)
-	}
-
-	var msg relayqueue.QueuedMessage
-	if err := json.Unmarshal(data, &msg); err != nil {
-		return fmt.Errorf("could not parse metadata: %w", err)
-	}
-
-	// Reset for retry
-	msg.Attempts = 0
-	msg.LastAttempt = time.Time{}
-	msg.NextRetry = time.Now() // Ready for immediate processing
-	msg.Errors = []string{}
-
-	// Write updated metadata to pending
-	updatedData, err := json.MarshalIndent(msg, "", "  ")
-	if err != nil {
-		return fmt.Errorf("could not marshal metadata: %w", err)
-	}
-
-	newMetadataPath := filepath.Join(pendingDir, messageID+".json")
-	if err := os.WriteFile(newMetadataPath, updatedData, 0644); err != nil {
-		return fmt.Errorf("could not write metadata to pending: %w", err)
-	}
-
-	// Copy message body
-	messageBody, err := os.ReadFile(messagePath)
-	if err != nil {
-		// Clean up metadata if body read fails
-		os.Remove(newMetadataPath)
-		return fmt.Errorf("could not read message body: %w", err)
-	}
-
-	newMessagePath := filepath.Join(pendingDir, messageID+".msg")
-	if err := os.WriteFile(newMessagePath, messageBody, 0644); err != nil {
-		// Clean up metadata if body write fails
-		os.Remove(newMetadataPath)
-		return fmt.Errorf("could not write message body to pending: %w", err)
-	}
-
-	// Delete from failed queue
-	os.Remove(metadataPath)
-	os.Remove(messagePath)
-
-	return nil
-}
-
 func printRelayUsage() {
 	fmt.Printf(`Relay Queue Management
 
@@ -477,6 +425,15 @@ Examples:
   # List failed messages (limit to 50)
   sora-admin relay list --config config.toml --queue failed --limit 50
 
+  # List pending vacation replies to one domain
+  sora-admin relay list --config config.toml --type vacation --domain gmail.com
+
+  # Count pending messages per recipient domain (or type, priority, route)
+  sora-admin relay list --config config.toml --group-by domain
+
+  # List messages taking a relay route
+  sora-admin relay list --config config.toml --route google
+
   # Show details for a specific message
   sora-admin relay show --config config.toml --id a1b2c3d4-e5f6 --queue failed
 
@@ -497,5 +454,7 @@ Notes:
   - Use --confirm flag for destructive operations (delete, requeue)
   - Queue types: pending, processing, failed
   - Requeue resets attempt count and clears error history
+  - Messages are sent highest priority first; routes are the [[relay.routes]]
+    in config.toml, and 'default' is the main relay
 `)
 }
@@ -46,6 +46,7 @@ type S3ImporterOptions struct {
 // S3Importer handles the S3 import process
 type S3Importer struct {
 	s3      *resilient.ResilientS3Storage
+	bucket  *storage.S3Storage // listed directly for StartAfter pagination
 	rdb     *resilient.ResilientDatabase
 	db      *sql.DB
 	dbPath  string
@@ -110,6 +111,7 @@ func NewS3Importer(rdb *resilient.ResilientDatabase, s3 *storage.S3Storage, opti
 
 	return &S3Importer{
 		s3:        resilientS3,
+		bucket:    s3,
 		rdb:       rdb,
 		db:        db,
 		dbPath:    dbPath,
@@ -190,7 +192,7 @@ func (si *S3Importer) scanS3Objects() error {
 
 	ctx := context.Background()
 	input := &s3.ListObjectsV2Input{
-		Bucket:     aws.String(si.s3.GetStorage().BucketName),
+		Bucket:     aws.String(si.bucket.BucketName),
 		Prefix:     aws.String(s3Prefix),
 		MaxKeys:    aws.Int32(1000), // Process in batches
 		StartAfter: aws.String(si.options.ContinuationToken),
@@ -199,7 +201,7 @@ func (si *S3Importer) scanS3Objects() error {
 	objectCount := 0
 	batchCount := 0
 
-	paginator := s3.NewListObjectsV2Paginator(si.s3.GetStorage().Client, input)
+	paginator := s3.NewListObjectsV2Paginator(si.bucket.Client, input)
 
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
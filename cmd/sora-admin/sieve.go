@@ -7,8 +7,15 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/emersion/go-imap/v2"
 	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/server/sieveengine"
+	"github.com/migadu/sora/storage"
 )
 
 // handleSieveCommand handles the 'sieve' command
@@ -34,6 +41,8 @@ func handleSieveCommand(ctx context.Context) {
 		handleSieveDeactivate(ctx)
 	case "rename":
 		handleSieveRename(ctx)
+	case "test":
+		handleSieveTest(ctx)
 	case "help", "--help", "-h":
 		printSieveUsage()
 	default:
@@ -511,6 +520,196 @@ Examples:
 	fmt.Printf("Successfully renamed Sieve script '%s' to '%s' for account %s\n", *oldName, *newName, *email)
 }
 
+// handleSieveTest runs a Sieve script against a message in trace mode. The
+// script runs without side effects: nothing is delivered, sent or recorded.
+func handleSieveTest(ctx context.Context) {
+	fs := flag.NewFlagSet("sieve test", flag.ExitOnError)
+	email := fs.String("email", "", "Email address of the account (required)")
+	scriptFile := fs.String("script", "", "Path to Sieve script file (defaults to the account's active script)")
+	messageFile := fs.String("message-file", "", "Path to an RFC 5322 message file")
+	uid := fs.Uint("uid", 0, "UID of a stored message to test against")
+	mailbox := fs.String("mailbox", "INBOX", "Mailbox containing the message given by --uid")
+	envelopeFrom := fs.String("envelope-from", "", "Envelope sender (defaults to the From address)")
+
+	fs.Usage = func() {
+		fmt.Printf(`Dry-run a Sieve script against a message and trace every test and action
+
+Usage:
+  sora-admin sieve test --config PATH --email EMAIL [--script FILE] (--message-file FILE | --uid UID [--mailbox NAME])
+
+Options:
+  --config PATH           Path to TOML configuration file (required)
+  --email EMAIL           Email address of the account (required)
+  --script FILE           Path to Sieve script file (defaults to the account's active script)
+  --message-file FILE     Path to an RFC 5322 message file
+  --uid UID               UID of a stored message to test against
+  --mailbox NAME          Mailbox containing the message given by --uid (default: INBOX)
+  --envelope-from ADDR    Envelope sender (defaults to the From address)
+
+Nothing is delivered, flagged, redirected or recorded.
+
+Examples:
+  sora-admin sieve test --config config.toml --email user@example.com --script filter.sieve --message-file msg.eml
+  sora-admin sieve test --config config.toml --email user@example.com --uid 42 --mailbox Archive
+`)
+	}
+
+	fs.Parse(os.Args[3:])
+
+	if *email == "" {
+		fmt.Println("Error: --email is required")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+	if (*messageFile == "") == (*uid == 0) {
+		fmt.Println("Error: exactly one of --message-file or --uid is required")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	var scriptContent string
+	if *scriptFile != "" {
+		content, err := os.ReadFile(*scriptFile)
+		if err != nil {
+			fmt.Printf("Failed to read script file: %v\n", err)
+			os.Exit(1)
+		}
+		scriptContent = string(content)
+	}
+
+	var rawMessage []byte
+	if *messageFile != "" {
+		var err error
+		rawMessage, err = os.ReadFile(*messageFile)
+		if err != nil {
+			fmt.Printf("Failed to read message file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if scriptContent == "" || rawMessage == nil {
+		rdb, err := newAdminDatabase(ctx, &globalConfig.Database)
+		if err != nil {
+			fmt.Printf("Failed to connect to database: %v\n", err)
+			os.Exit(1)
+		}
+		defer rdb.Close()
+
+		accountID, err := rdb.GetAccountIDByEmailWithRetry(ctx, *email)
+		if err != nil {
+			fmt.Printf("Failed to find account: %v\n", err)
+			os.Exit(1)
+		}
+
+		if scriptContent == "" {
+			script, err := rdb.GetActiveScriptWithRetry(ctx, accountID)
+			if err != nil {
+				if errors.Is(err, consts.ErrDBNotFound) {
+					fmt.Printf("No active Sieve script for account %s; use --script\n", *email)
+				} else {
+					fmt.Printf("Failed to retrieve active Sieve script: %v\n", err)
+				}
+				os.Exit(1)
+			}
+			scriptContent = script.Script
+		}
+
+		if rawMessage == nil {
+			rawMessage, err = fetchStoredMessage(ctx, rdb, accountID, *mailbox, imap.UID(*uid))
+			if err != nil {
+				fmt.Printf("Failed to retrieve message: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	evalCtx, err := sieveengine.ContextFromMessage(rawMessage, *envelopeFrom, *email)
+	if err != nil {
+		fmt.Printf("Failed to parse message: %v\n", err)
+		os.Exit(1)
+	}
+
+	extensions := globalConfig.Sieve.EnabledExtensions
+	if len(extensions) == 0 {
+		extensions = sieveengine.DefaultSieveExtensions
+	}
+	sieveengine.SetScriptExecutionTimeout(globalConfig.Sieve.GetMaxExecutionTime())
+	result, steps, err := sieveengine.Trace(ctx, scriptContent, evalCtx, extensions)
+	if err != nil {
+		fmt.Printf("Script failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	printSieveTrace(result, steps)
+}
+
+// fetchStoredMessage loads the raw message with the given UID from object storage.
+func fetchStoredMessage(ctx context.Context, rdb *resilient.ResilientDatabase, accountID int64, mailboxName string, uid imap.UID) ([]byte, error) {
+	mailbox, err := rdb.GetMailboxByNameWithRetry(ctx, accountID, mailboxName)
+	if err != nil {
+		return nil, fmt.Errorf("mailbox %q: %w", mailboxName, err)
+	}
+	messages, err := rdb.GetMessagesByNumSetWithRetry(ctx, mailbox.ID, imap.UIDSetNum(uid))
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("no message with UID %d in %s", uid, mailboxName)
+	}
+	msg := messages[0]
+	if msg.S3Domain == "" || msg.S3Localpart == "" || msg.ContentHash == "" {
+		return nil, fmt.Errorf("message UID %d has not been uploaded yet", uid)
+	}
+
+	backend, err := storage.NewFromConfig(&globalConfig.S3)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize object storage: %w", err)
+	}
+
+	reader, err := backend.Get(helpers.NewS3Key(msg.S3Domain, msg.S3Localpart, msg.ContentHash))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// printSieveTrace prints the steps of a traced script run and its outcome
+func printSieveTrace(result sieveengine.Result, steps []sieveengine.TraceStep) {
+	fmt.Printf("%-6s %-8s %-10s %s\n", "Line", "Kind", "Result", "Command")
+	fmt.Printf("%-6s %-8s %-10s %s\n", "----", "----", "------", "-------")
+	for _, step := range steps {
+		outcome := "skipped"
+		switch {
+		case step.Kind == "action" && step.Executed > 0:
+			outcome = fmt.Sprintf("run x%d", step.Executed)
+		case step.Kind == "test" && len(step.Results) > 0:
+			parts := make([]string, len(step.Results))
+			for i, r := range step.Results {
+				parts[i] = strconv.FormatBool(r)
+			}
+			outcome = strings.Join(parts, ",")
+		}
+		fmt.Printf("%-6d %-8s %-10s %s\n", step.Line, step.Kind, outcome, step.Command)
+	}
+
+	fmt.Printf("\nFinal action: %s\n", result.Action)
+	switch result.Action {
+	case sieveengine.ActionFileInto:
+		fmt.Printf("Mailbox:      %s (copy: %v)\n", result.Mailbox, result.Copy)
+	case sieveengine.ActionRedirect:
+		fmt.Printf("Redirect to:  %s (copy: %v)\n", result.RedirectTo, result.Copy)
+	case sieveengine.ActionVacation:
+		fmt.Printf("Vacation:     %s\n", result.VacationSubj)
+	}
+	if len(result.Flags) > 0 {
+		fmt.Printf("Flags:        %s\n", strings.Join(result.Flags, " "))
+	}
+	if result.Message != nil {
+		fmt.Println("Message:      rewritten by replace/enclose")
+	}
+}
+
 // printSieveUsage prints usage information for the 'sieve' command
 func printSieveUsage() {
 	fmt.Println(`Usage: sora-admin sieve <subcommand> [options]
@@ -523,6 +722,7 @@ Subcommands:
   activate      Activate a Sieve script
   deactivate    Deactivate all Sieve scripts for an account
   rename        Rename a Sieve script
+  test          Dry-run a Sieve script against a message and trace it
 
 Examples:
   # List all scripts
@@ -549,6 +749,9 @@ Examples:
   # Rename script
   sora-admin sieve rename --config config.toml --email user@example.com --old "oldname" --new "newname"
 
+  # Trace a script against a stored message
+  sora-admin sieve test --config config.toml --email user@example.com --script filter.sieve --uid 42
+
 For more information on a subcommand, run:
   sora-admin sieve <subcommand> --help`)
 }
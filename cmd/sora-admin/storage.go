@@ -0,0 +1,348 @@
+package main
+
+// storage.go - Command handlers for object storage maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/storage"
+)
+
+func handleStorageCommand(ctx context.Context) {
+	if len(os.Args) < 3 {
+		printStorageUsage()
+		os.Exit(1)
+	}
+
+	subcommand := os.Args[2]
+	switch subcommand {
+	case "recompress":
+		handleStorageRecompress(ctx)
+	case "help", "--help", "-h":
+		printStorageUsage()
+	default:
+		fmt.Printf("Unknown storage subcommand: %s\n\n", subcommand)
+		printStorageUsage()
+		os.Exit(1)
+	}
+}
+
+func printStorageUsage() {
+	fmt.Printf(`Object storage maintenance
+
+Usage:
+  sora-admin storage <subcommand> [options]
+
+Subcommands:
+  recompress   Compress existing message bodies in S3 with zstd
+
+Examples:
+  sora-admin storage recompress --state-file /var/lib/sora/recompress.json
+  sora-admin storage recompress --prefix example.com/ --workers 8 --rate 200
+
+Use 'sora-admin storage <subcommand> --help' for detailed help.
+`)
+}
+
+func handleStorageRecompress(ctx context.Context) {
+	fs := flag.NewFlagSet("storage recompress", flag.ExitOnError)
+	prefix := fs.String("prefix", "", "Only process keys with this prefix (e.g. a domain or domain/localpart)")
+	stateFile := fs.String("state-file", "", "File recording progress; an interrupted run resumes from it")
+	workers := fs.Int("workers", 4, "Number of objects processed concurrently")
+	rate := fs.Float64("rate", 50, "Maximum objects per second (0 = unlimited)")
+	progressInterval := fs.Duration("progress-interval", 10*time.Second, "How often to print progress and save the state file")
+
+	fs.Usage = func() {
+		fmt.Printf(`Compress existing message bodies in S3 with zstd
+
+Usage:
+  sora-admin storage recompress [options]
+
+Options:
+  --prefix string               Only process keys with this prefix (e.g. a domain or domain/localpart)
+  --state-file string           File recording progress; an interrupted run resumes from it
+  --workers int                 Number of objects processed concurrently (default: 4)
+  --rate float                  Maximum objects per second, 0 for unlimited (default: 50)
+  --progress-interval duration  How often to print progress and save the state file (default: 10s)
+
+Objects are walked in key order. Each uncompressed object is downloaded,
+compressed, re-encrypted if encryption is enabled, and uploaded under the
+same key with the compression marker. Objects already compressed, or that
+would not shrink, are left as they are. Servers read compressed and
+uncompressed objects alike, so this can run while they are serving mail.
+
+With --state-file, the last key below which every object has been handled is
+saved periodically and on interrupt (Ctrl+C); re-running the same command
+continues from there. Without it, a re-run starts over but only re-checks
+objects that are already compressed.
+
+An object deleted by the cleaner while it is being recompressed may be
+re-created; 'sora-admin verify' reports such objects as orphaned.
+
+Examples:
+  sora-admin storage recompress --state-file /var/lib/sora/recompress.json
+  sora-admin storage recompress --prefix example.com/ --workers 8 --rate 200
+`)
+	}
+
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		logger.Fatalf("Error parsing flags: %v", err)
+	}
+	if *workers < 1 {
+		logger.Fatalf("--workers must be at least 1")
+	}
+
+	backend, err := storage.NewFromConfig(&globalConfig.S3)
+	if err != nil {
+		logger.Fatalf("Failed to initialize object storage: %v", err)
+	}
+	s3Storage, ok := backend.(*storage.S3Storage)
+	if !ok {
+		logger.Fatalf("Recompression is only supported for the s3 storage backend")
+	}
+
+	opts := recompressOptions{
+		Prefix:           *prefix,
+		StateFile:        *stateFile,
+		Workers:          *workers,
+		Rate:             *rate,
+		ProgressInterval: *progressInterval,
+	}
+	state, err := recompressObjects(ctx, s3Storage, opts)
+	printRecompressProgress(state, true)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			if opts.StateFile != "" {
+				fmt.Printf("\nInterrupted. Run the same command again to resume from %s.\n", state.LastKey)
+			}
+			os.Exit(1)
+		}
+		logger.Fatalf("Recompression failed: %v", err)
+	}
+}
+
+// recompressTarget is the part of storage.S3Storage used by recompression.
+type recompressTarget interface {
+	ListObjectsAfter(ctx context.Context, prefix, startAfter string) (<-chan storage.S3Object, <-chan error)
+	Recompress(key string) (storage.RecompressResult, error)
+}
+
+type recompressOptions struct {
+	Prefix           string
+	StateFile        string
+	Workers          int
+	Rate             float64
+	ProgressInterval time.Duration
+}
+
+// recompressState is the progress of a recompression run, saved to the state
+// file. Every object with a key up to LastKey has been handled.
+type recompressState struct {
+	Prefix      string    `json:"prefix"`
+	LastKey     string    `json:"last_key"`
+	Objects     int64     `json:"objects"`
+	Compressed  int64     `json:"compressed"`
+	Skipped     int64     `json:"skipped"`
+	Failed      int64     `json:"failed"`
+	BytesBefore int64     `json:"bytes_before"`
+	BytesAfter  int64     `json:"bytes_after"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type recompressJob struct {
+	seq int
+	key string
+}
+
+type recompressOutcome struct {
+	seq    int
+	result storage.RecompressResult
+	err    error
+}
+
+func recompressObjects(ctx context.Context, target recompressTarget, opts recompressOptions) (*recompressState, error) {
+	state := &recompressState{Prefix: opts.Prefix}
+	if opts.StateFile != "" {
+		loaded, err := loadRecompressState(opts.StateFile)
+		if err != nil {
+			return state, err
+		}
+		if loaded != nil {
+			if loaded.Prefix != opts.Prefix {
+				return state, fmt.Errorf("state file %s was created for prefix %q, not %q", opts.StateFile, loaded.Prefix, opts.Prefix)
+			}
+			state = loaded
+			fmt.Printf("Resuming after %s (%d objects already processed)\n", state.LastKey, state.Objects)
+		}
+	}
+
+	listCtx, cancelList := context.WithCancel(ctx)
+	defer cancelList()
+	objectCh, listErrCh := target.ListObjectsAfter(listCtx, opts.Prefix, state.LastKey)
+
+	jobs := make(chan recompressJob)
+	outcomes := make(chan recompressOutcome)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				result, err := target.Recompress(job.key)
+				outcomes <- recompressOutcome{seq: job.seq, result: result, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// Dispatch in key order, at most Rate objects per second.
+	var throttle <-chan time.Time
+	if opts.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+	var mu sync.Mutex
+	pending := make(map[int]string) // seq -> key, dispatched but not yet checkpointed
+	go func() {
+		defer close(jobs)
+		seq := 0
+		for obj := range objectCh {
+			if throttle != nil {
+				select {
+				case <-throttle:
+				case <-ctx.Done():
+					return
+				}
+			}
+			mu.Lock()
+			pending[seq] = obj.Key
+			mu.Unlock()
+			select {
+			case jobs <- recompressJob{seq: seq, key: obj.Key}:
+			case <-ctx.Done():
+				return
+			}
+			seq++
+		}
+	}()
+
+	// Collect outcomes. The checkpoint only advances over a contiguous run of
+	// finished objects, so a resumed run never skips one still in flight.
+	done := make(map[int]bool)
+	next := 0
+	ticker := time.NewTicker(opts.ProgressInterval)
+	defer ticker.Stop()
+	save := func() {
+		printRecompressProgress(state, false)
+		if opts.StateFile != "" {
+			if err := saveRecompressState(opts.StateFile, state); err != nil {
+				logger.Warn("Failed to save recompression state", "file", opts.StateFile, "error", err)
+			}
+		}
+	}
+	for {
+		select {
+		case out, ok := <-outcomes:
+			if !ok {
+				save()
+				if err := ctx.Err(); err != nil {
+					return state, err
+				}
+				if err := <-listErrCh; err != nil {
+					return state, fmt.Errorf("failed to list objects: %w", err)
+				}
+				return state, nil
+			}
+			mu.Lock()
+			key := pending[out.seq]
+			mu.Unlock()
+			state.Objects++
+			switch {
+			case out.err != nil:
+				state.Failed++
+				logger.Warn("Failed to recompress object", "key", key, "error", out.err)
+			case out.result.CompressedSize > 0:
+				state.Compressed++
+				state.BytesBefore += out.result.Size
+				state.BytesAfter += out.result.CompressedSize
+			default:
+				state.Skipped++
+			}
+			done[out.seq] = true
+			mu.Lock()
+			for done[next] {
+				state.LastKey = pending[next]
+				delete(done, next)
+				delete(pending, next)
+				next++
+			}
+			mu.Unlock()
+		case <-ticker.C:
+			save()
+		}
+	}
+}
+
+func printRecompressProgress(state *recompressState, final bool) {
+	saved := state.BytesBefore - state.BytesAfter
+	ratio := 0.0
+	if state.BytesAfter > 0 {
+		ratio = float64(state.BytesBefore) / float64(state.BytesAfter)
+	}
+	label := "Progress"
+	if final {
+		label = "Done"
+	}
+	fmt.Printf("%s: %d objects (%d compressed, %d skipped, %d failed), %s saved (%.1fx), last key %s\n",
+		label, state.Objects, state.Compressed, state.Skipped, state.Failed, formatBytes(saved), ratio, state.LastKey)
+}
+
+// loadRecompressState reads the state file; a missing file means a fresh run.
+func loadRecompressState(path string) (*recompressState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	var state recompressState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+func saveRecompressState(path string, state *recompressState) error {
+	state.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".recompress-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/migadu/sora/storage"
+)
+
+// fakeRecompressTarget serves keys in order and records which were processed.
+type fakeRecompressTarget struct {
+	mu         sync.Mutex
+	keys       []string
+	compressed map[string]bool
+	failing    map[string]bool
+	processed  []string
+	// stopAfter cancels the run once this many objects were processed.
+	stopAfter int
+	cancel    context.CancelFunc
+}
+
+func (f *fakeRecompressTarget) ListObjectsAfter(ctx context.Context, prefix, startAfter string) (<-chan storage.S3Object, <-chan error) {
+	objectCh := make(chan storage.S3Object)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(objectCh)
+		defer close(errCh)
+		for _, key := range f.keys {
+			if !strings.HasPrefix(key, prefix) || key <= startAfter {
+				continue
+			}
+			select {
+			case objectCh <- storage.S3Object{Key: key}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return objectCh, errCh
+}
+
+func (f *fakeRecompressTarget) Recompress(key string) (storage.RecompressResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.processed = append(f.processed, key)
+	if f.stopAfter > 0 && len(f.processed) == f.stopAfter {
+		f.cancel()
+	}
+	switch {
+	case f.failing[key]:
+		return storage.RecompressResult{}, errors.New("boom")
+	case f.compressed[key]:
+		return storage.RecompressResult{AlreadyCompressed: true}, nil
+	}
+	f.compressed[key] = true
+	return storage.RecompressResult{Size: 1000, CompressedSize: 250}, nil
+}
+
+func TestRecompressObjects(t *testing.T) {
+	target := &fakeRecompressTarget{
+		keys:       []string{"a.com/u/1111", "a.com/u/2222", "a.com/u/3333", "b.com/u/4444"},
+		compressed: map[string]bool{"a.com/u/2222": true},
+		failing:    map[string]bool{"a.com/u/3333": true},
+	}
+	state, err := recompressObjects(context.Background(), target, recompressOptions{
+		Prefix:           "a.com/",
+		Workers:          2,
+		ProgressInterval: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("recompressObjects failed: %v", err)
+	}
+	if state.Objects != 3 || state.Compressed != 1 || state.Skipped != 1 || state.Failed != 1 {
+		t.Errorf("unexpected counts %+v", state)
+	}
+	if state.BytesBefore != 1000 || state.BytesAfter != 250 {
+		t.Errorf("unexpected byte counts %+v", state)
+	}
+	if state.LastKey != "a.com/u/3333" {
+		t.Errorf("LastKey = %q", state.LastKey)
+	}
+}
+
+func TestRecompressObjectsResume(t *testing.T) {
+	var keys []string
+	for _, k := range []string{"0001", "0002", "0003", "0004", "0005", "0006"} {
+		keys = append(keys, "a.com/u/"+k)
+	}
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	opts := recompressOptions{StateFile: stateFile, Workers: 1, ProgressInterval: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := &fakeRecompressTarget{keys: keys, compressed: map[string]bool{}, stopAfter: 3, cancel: cancel}
+	state, err := recompressObjects(ctx, first, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to be interrupted, got %v", err)
+	}
+	if state.LastKey == "" {
+		t.Fatal("no checkpoint recorded")
+	}
+
+	second := &fakeRecompressTarget{keys: keys, compressed: first.compressed}
+	state, err = recompressObjects(context.Background(), second, opts)
+	if err != nil {
+		t.Fatalf("resumed run failed: %v", err)
+	}
+	all := append(append([]string{}, first.processed...), second.processed...)
+	sort.Strings(all)
+	if strings.Join(all, ",") != strings.Join(keys, ",") {
+		t.Errorf("processed %v, want every key exactly once", all)
+	}
+	if state.Compressed != int64(len(keys)) || state.LastKey != keys[len(keys)-1] {
+		t.Errorf("unexpected final state %+v", state)
+	}
+
+	// A different prefix does not reuse the state file.
+	opts.Prefix = "b.com/"
+	if _, err := recompressObjects(context.Background(), second, opts); err == nil {
+		t.Error("expected a prefix mismatch error")
+	}
+}
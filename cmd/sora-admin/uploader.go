@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/uploader"
 	"github.com/migadu/sora/storage"
 )
 
@@ -125,35 +126,17 @@ func resolveFailedUploads(ctx context.Context, cfg AdminConfig, dryRun bool, lim
 	}
 	defer rdb.Close()
 
-	s3Timeout, err := cfg.S3.GetTimeout()
+	s3Storage, err := storage.NewFromConfig(&cfg.S3)
 	if err != nil {
-		return fmt.Errorf("invalid S3 timeout configuration: %w", err)
+		return fmt.Errorf("failed to initialize object storage: %w", err)
 	}
 
-	s3Storage, err := storage.New(
-		cfg.S3.Endpoint,
-		cfg.S3.AccessKey,
-		cfg.S3.SecretKey,
-		cfg.S3.Bucket,
-		!cfg.S3.DisableTLS,
-		false,
-		s3Timeout,
-	)
+	report, err := uploader.ResolveFailedUploads(ctx, rdb, s3Storage, cfg.Uploader.MaxAttempts, limit, dryRun)
 	if err != nil {
-		return fmt.Errorf("failed to initialize S3: %w", err)
-	}
-	if cfg.S3.Encrypt {
-		if err := s3Storage.EnableEncryption(cfg.S3.EncryptionKey); err != nil {
-			return fmt.Errorf("failed to enable S3 encryption: %w", err)
-		}
-	}
-
-	failedUploads, err := rdb.GetFailedUploadsWithEmailWithRetry(ctx, cfg.Uploader.MaxAttempts, limit)
-	if err != nil {
-		return fmt.Errorf("failed to get failed uploads: %w", err)
+		return err
 	}
 
-	if len(failedUploads) == 0 {
+	if len(report.Results) == 0 {
 		fmt.Println("No failed uploads to resolve.")
 		return nil
 	}
@@ -162,59 +145,23 @@ func resolveFailedUploads(ctx context.Context, cfg AdminConfig, dryRun bool, lim
 		fmt.Println("DRY RUN - no changes will be made.")
 	}
 
-	var resolved, deleted, skipped int
-
-	for _, upload := range failedUploads {
-		if upload.AccountEmail == "" {
-			fmt.Printf("  [SKIP]   id=%-10d hash=%.16s... (no account email found)\n", upload.ID, upload.ContentHash)
-			skipped++
-			continue
-		}
-
-		parts := strings.Split(upload.AccountEmail, "@")
-		if len(parts) != 2 {
-			fmt.Printf("  [SKIP]   id=%-10d hash=%.16s... (malformed email: %s)\n", upload.ID, upload.ContentHash, upload.AccountEmail)
-			skipped++
-			continue
-		}
-
-		s3Key := fmt.Sprintf("%s/%s/%s", parts[1], parts[0], upload.ContentHash)
-		exists, _, checkErr := s3Storage.Exists(s3Key)
-		if checkErr != nil {
-			fmt.Printf("  [ERROR]  id=%-10d hash=%.16s... S3 check failed: %v\n", upload.ID, upload.ContentHash, checkErr)
-			skipped++
-			continue
-		}
-
-		if exists {
-			// Content is in S3 - mark messages as uploaded=TRUE, remove pending record.
+	for _, res := range report.Results {
+		switch {
+		case res.Outcome == uploader.ResolveRepaired:
 			fmt.Printf("  [REPAIR] id=%-10d account=%-8d hash=%.16s... [OK] EXISTS in S3 → CompleteS3Upload\n",
-				upload.ID, upload.AccountID, upload.ContentHash)
-			if !dryRun {
-				if err := rdb.CompleteS3UploadWithRetry(ctx, upload.ContentHash, upload.AccountID); err != nil {
-					fmt.Printf("            ERROR: %v\n", err)
-					skipped++
-					continue
-				}
-			}
-			resolved++
-		} else {
-			// Content is NOT in S3 - the message was never delivered. Clean up.
+				res.ID, res.AccountID, res.ContentHash)
+		case res.Outcome == uploader.ResolveDeleted:
 			fmt.Printf("  [DELETE] id=%-10d account=%-8d hash=%.16s... [FAIL] MISSING in S3 → DeleteFailedUpload\n",
-				upload.ID, upload.AccountID, upload.ContentHash)
+				res.ID, res.AccountID, res.ContentHash)
 			if !dryRun {
-				n, err := rdb.DeleteFailedUploadWithRetry(ctx, upload.ContentHash, upload.AccountID)
-				if err != nil {
-					fmt.Printf("            ERROR: %v\n", err)
-					skipped++
-					continue
-				}
-				fmt.Printf("            Deleted %d message row(s)\n", n)
+				fmt.Printf("            Deleted %d message row(s)\n", res.DeletedMessages)
 			}
-			deleted++
+		default:
+			fmt.Printf("  [SKIP]   id=%-10d hash=%.16s... (%s)\n", res.ID, res.ContentHash, res.Error)
 		}
 	}
 
+	resolved, deleted, skipped := report.Repaired, report.Deleted, report.Skipped
 	fmt.Printf("\nSummary: %d repaired ([OK] EXISTS), %d deleted ([FAIL] MISSING), %d skipped\n", resolved, deleted, skipped)
 	if dryRun {
 		fmt.Println("(dry run - run without --dry-run to apply changes)")
@@ -296,33 +243,11 @@ func showUploaderStatus(ctx context.Context, cfg AdminConfig, showFailed bool, f
 
 	// Show failed uploads if requested
 	if showFailed && stats.FailedUploads > 0 {
-		// Initialize S3 storage for checking existence
-		s3Timeout, err := cfg.S3.GetTimeout()
+		// Initialize object storage for checking existence
+		s3Storage, err := storage.NewFromConfig(&cfg.S3)
 		if err != nil {
-			logger.Warn("Invalid S3 timeout configuration (S3 Status column will show 'N/A')", "error", err)
-		}
-
-		var s3Storage *storage.S3Storage
-		if err == nil {
-			s3Storage, err = storage.New(
-				cfg.S3.Endpoint,
-				cfg.S3.AccessKey,
-				cfg.S3.SecretKey,
-				cfg.S3.Bucket,
-				!cfg.S3.DisableTLS,
-				false,
-				s3Timeout,
-			)
-			if err != nil {
-				logger.Warn("Failed to initialize S3 (S3 Status column will show 'N/A')", "error", err)
-				s3Storage = nil
-			}
-		}
-		if s3Storage != nil && cfg.S3.Encrypt {
-			if err := s3Storage.EnableEncryption(cfg.S3.EncryptionKey); err != nil {
-				logger.Warn("Failed to enable S3 encryption (S3 Status column will show 'N/A')", "error", err)
-				s3Storage = nil
-			}
+			logger.Warn("Failed to initialize object storage (S3 Status column will show 'N/A')", "error", err)
+			s3Storage = nil
 		}
 
 		fmt.Printf("\nFailed Uploads (showing up to %d):\n", failedLimit)
@@ -155,30 +155,10 @@ func verifyS3Storage(ctx context.Context, cfg AdminConfig, email string, showMis
 	}
 	defer rdb.Close()
 
-	// Initialize S3 storage
-	s3Timeout, err := cfg.S3.GetTimeout()
+	// Initialize object storage (S3 or filesystem, with encryption if configured)
+	s3Storage, err := storage.NewFromConfig(&cfg.S3)
 	if err != nil {
-		return fmt.Errorf("invalid S3 timeout configuration: %w", err)
-	}
-
-	s3Storage, err := storage.New(
-		cfg.S3.Endpoint,
-		cfg.S3.AccessKey,
-		cfg.S3.SecretKey,
-		cfg.S3.Bucket,
-		!cfg.S3.DisableTLS, // useSSL = !DisableTLS
-		false,              // no debug
-		s3Timeout,          // timeout
-	)
-	if err != nil {
-		return fmt.Errorf("failed to initialize S3 storage: %w", err)
-	}
-
-	// Enable encryption if configured
-	if cfg.S3.Encrypt {
-		if err := s3Storage.EnableEncryption(cfg.S3.EncryptionKey); err != nil {
-			return fmt.Errorf("failed to enable encryption: %w", err)
-		}
+		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
 	fmt.Printf("Verifying S3 consistency for %s...\n\n", email)
@@ -249,7 +229,7 @@ func verifyS3Storage(ctx context.Context, cfg AdminConfig, email string, showMis
 	return nil
 }
 
-func checkDBToS3(ctx context.Context, rdb *resilient.ResilientDatabase, s3Storage *storage.S3Storage, accountID int64, result *verificationResult, batchSize int, deep bool) error {
+func checkDBToS3(ctx context.Context, rdb *resilient.ResilientDatabase, s3Storage storage.Backend, accountID int64, result *verificationResult, batchSize int, deep bool) error {
 	// Get all messages for the user
 	messages, err := rdb.GetAllMessagesForUserVerificationWithRetry(ctx, accountID)
 	if err != nil {
@@ -312,7 +292,7 @@ func hashFromS3Key(s3Key string) string {
 	return s3Key[idx+1:]
 }
 
-func checkS3ToDB(ctx context.Context, rdb *resilient.ResilientDatabase, s3Storage *storage.S3Storage, accountID int64, email string, result *verificationResult) error {
+func checkS3ToDB(ctx context.Context, rdb *resilient.ResilientDatabase, s3Storage storage.Backend, accountID int64, email string, result *verificationResult) error {
 	// Parse email to get domain and localpart
 	parts := strings.Split(email, "@")
 	if len(parts) != 2 {
@@ -362,7 +342,7 @@ func checkS3ToDB(ctx context.Context, rdb *resilient.ResilientDatabase, s3Storag
 	}
 }
 
-func applyFixes(ctx context.Context, rdb *resilient.ResilientDatabase, s3Storage *storage.S3Storage, result *verificationResult, fixOrphaned, fixMissing bool) error {
+func applyFixes(ctx context.Context, rdb *resilient.ResilientDatabase, s3Storage storage.Backend, result *verificationResult, fixOrphaned, fixMissing bool) error {
 	var wg sync.WaitGroup
 	errors := make(chan error, 2)
 
@@ -480,7 +460,11 @@ func printVerificationSummary(result *verificationResult, showMissing, dryRun, f
 // the body is truncated. It reports the bytes written against the declared
 // Content-Length so the extent of any corruption is visible. The saved file is
 // the raw stored object (still encrypted, if client-side encryption is on).
-func downloadS3ObjectRaw(ctx context.Context, s3Storage *storage.S3Storage, s3Key, destPath string) error {
+func downloadS3ObjectRaw(ctx context.Context, backend storage.Backend, s3Key, destPath string) error {
+	s3Storage, ok := backend.(*storage.S3Storage)
+	if !ok {
+		return downloadFileObjectRaw(backend, s3Key, destPath)
+	}
 	out, err := s3Storage.Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s3Storage.BucketName),
 		Key:    aws.String(s3Key),
@@ -511,12 +495,43 @@ func downloadS3ObjectRaw(ctx context.Context, s3Storage *storage.S3Storage, s3Ke
 	return nil
 }
 
+// downloadFileObjectRaw copies the stored file of a filesystem backend, still
+// encrypted if client-side encryption is on.
+func downloadFileObjectRaw(backend storage.Backend, key, destPath string) error {
+	fsStorage, ok := backend.(*storage.FilesystemStorage)
+	if !ok {
+		return fmt.Errorf("raw download is not supported by this storage backend")
+	}
+	srcPath, err := fsStorage.Path(key)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", srcPath, err)
+	}
+	defer src.Close()
+
+	f, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", destPath, err)
+	}
+	defer f.Close()
+
+	written, err := io.Copy(f, src)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %w", srcPath, err)
+	}
+	fmt.Printf("  Wrote %d bytes to %s\n", written, destPath)
+	return nil
+}
+
 // downloadS3ObjectDecrypted fetches the object through the normal Get path
 // (which transparently decrypts when client-side encryption is enabled) and
 // writes the readable message to a local file. It returns an error — saving
 // nothing — if the object is corrupt or truncated, since decryption requires
 // the complete ciphertext and authentication tag.
-func downloadS3ObjectDecrypted(s3Storage *storage.S3Storage, s3Key, destPath string) error {
+func downloadS3ObjectDecrypted(s3Storage storage.Backend, s3Key, destPath string) error {
 	reader, err := s3Storage.Get(s3Key)
 	if err != nil {
 		return fmt.Errorf("download/decrypt failed: %w", err)
@@ -544,7 +559,7 @@ func downloadS3ObjectDecrypted(s3Storage *storage.S3Storage, s3Key, destPath str
 // body cannot be fully read (truncation → "unexpected EOF"), decryption fails
 // (GCM auth failure on a corrupt tail), or the recomputed hash does not match.
 // It does not print — callers decide what to report.
-func verifyS3Content(s3Storage *storage.S3Storage, s3Key, expectedHash string) (int, error) {
+func verifyS3Content(s3Storage storage.Backend, s3Key, expectedHash string) (int, error) {
 	reader, err := s3Storage.Get(s3Key)
 	if err != nil {
 		return 0, fmt.Errorf("download/decrypt failed: %w", err)
@@ -658,30 +673,10 @@ func verifyContentHash(ctx context.Context, cfg AdminConfig, hash, email string,
 	domain := parts[1]
 	s3Key := fmt.Sprintf("%s/%s/%s", domain, localpart, hash)
 
-	// Initialize S3 storage
-	s3Timeout, err := cfg.S3.GetTimeout()
-	if err != nil {
-		return fmt.Errorf("invalid S3 timeout configuration: %w", err)
-	}
-
-	s3Storage, err := storage.New(
-		cfg.S3.Endpoint,
-		cfg.S3.AccessKey,
-		cfg.S3.SecretKey,
-		cfg.S3.Bucket,
-		!cfg.S3.DisableTLS,
-		false,
-		s3Timeout,
-	)
+	// Initialize object storage (S3 or filesystem, with encryption if configured)
+	s3Storage, err := storage.NewFromConfig(&cfg.S3)
 	if err != nil {
-		return fmt.Errorf("failed to initialize S3 storage: %w", err)
-	}
-
-	// Enable encryption if configured
-	if cfg.S3.Encrypt {
-		if err := s3Storage.EnableEncryption(cfg.S3.EncryptionKey); err != nil {
-			return fmt.Errorf("failed to enable encryption: %w", err)
-		}
+		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
 	fmt.Printf("Checking content hash: %s\n", hash)
@@ -23,12 +23,16 @@ import (
 	"github.com/migadu/sora/helpers"
 	"github.com/migadu/sora/logger"
 	"github.com/migadu/sora/pkg/errors"
+	"github.com/migadu/sora/pkg/geoip"
 	"github.com/migadu/sora/pkg/health"
 	"github.com/migadu/sora/pkg/metrics"
 	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/pkg/scanner"
 	"github.com/migadu/sora/pkg/spamtraining"
+	"github.com/migadu/sora/pkg/srs"
 	"github.com/migadu/sora/server"
 	"github.com/migadu/sora/server/adminapi"
+	"github.com/migadu/sora/server/adminjobs"
 	"github.com/migadu/sora/server/cleaner"
 	"github.com/migadu/sora/server/delivery"
 	"github.com/migadu/sora/server/fts"
@@ -40,11 +44,13 @@ import (
 	"github.com/migadu/sora/server/managesieveproxy"
 	"github.com/migadu/sora/server/pop3"
 	"github.com/migadu/sora/server/pop3proxy"
+	"github.com/migadu/sora/server/proxy"
 	"github.com/migadu/sora/server/relayqueue"
 	"github.com/migadu/sora/server/sieveengine"
 	"github.com/migadu/sora/server/uploader"
 	mailapi "github.com/migadu/sora/server/userapi"
 	"github.com/migadu/sora/server/userapiproxy"
+	"github.com/migadu/sora/server/webhook"
 	"github.com/migadu/sora/storage"
 	"github.com/migadu/sora/tlsmanager"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -81,20 +87,28 @@ func (sm *serverManager) Wait() {
 
 // serverDependencies encapsulates all shared services and dependencies needed by servers
 type serverDependencies struct {
-	storage               *storage.S3Storage
+	storage               storage.Backend
 	resilientDB           *resilient.ResilientDatabase
 	uploadWorker          *uploader.UploadWorker
 	cacheInstance         *cache.Cache
 	cleanupWorker         *cleaner.CleanupWorker
 	ftsWorker             *fts.Worker
+	jobWorker             *adminjobs.Worker
 	relayQueue            *relayqueue.DiskQueue
 	relayWorker           *relayqueue.Worker
+	srs                   *srs.Rewriter
+	dkimSigner            *delivery.DKIMSigner
 	healthIntegration     *health.HealthIntegration
 	metricsCollector      *metrics.Collector
 	clusterManager        *cluster.Manager
 	tlsManager            *tlsmanager.Manager
 	affinityManager       *server.AffinityManager
-	spamTrainingClient    *spamtraining.Client // Spam filter training client (optional)
+	spamTrainingClient    *spamtraining.Client            // Spam filter training client (optional)
+	webhookDispatcher     *webhook.Dispatcher             // Outbound event webhooks (optional)
+	scanner               *scanner.Client                 // Pre-delivery content scanner (optional)
+	loginHistory          *server.LoginHistory            // Per-account login history (optional)
+	deliveryLog           *server.DeliveryLog             // Per-message LMTP delivery log (optional)
+	suspiciousLogins      *server.SuspiciousLoginDetector // New network/country/client alerts (optional)
 	hostname              string
 	ftsRetention          time.Duration
 	config                config.Config
@@ -212,6 +226,12 @@ func main() {
 	if deps.resilientDB != nil {
 		defer deps.resilientDB.Close()
 	}
+	if deps.loginHistory != nil {
+		defer deps.loginHistory.Wait() // Flushes queued records before the database closes
+	}
+	if deps.deliveryLog != nil {
+		defer deps.deliveryLog.Wait() // Flushes queued records before the database closes
+	}
 	if deps.authCacheInstance != nil {
 		defer deps.authCacheInstance.Close()
 	}
@@ -239,9 +259,15 @@ func main() {
 	if deps.uploadWorker != nil {
 		defer deps.uploadWorker.Stop()
 	}
+	if deps.jobWorker != nil {
+		defer deps.jobWorker.Stop()
+	}
 	if deps.relayWorker != nil {
 		defer deps.relayWorker.Stop()
 	}
+	if deps.webhookDispatcher != nil {
+		defer deps.webhookDispatcher.Stop()
+	}
 
 	// SIGHUP handler for config reload - set up AFTER deps is created so we can
 	// update deps.config directly. Servers that hold Config *config.Config pointers
@@ -561,39 +587,24 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 		proxyServers:       make(map[string]adminapi.ProxyServer),
 	}
 
-	// Initialize S3 storage if needed
+	// Initialize object storage (S3 or filesystem) if needed
 	if storageServicesNeeded {
-		// Ensure required S3 arguments are provided only if needed
-		if cfg.S3.AccessKey == "" || cfg.S3.SecretKey == "" || cfg.S3.Bucket == "" {
-			errorHandler.ValidationError("S3 credentials", fmt.Errorf("missing required S3 credentials for mail services (IMAP, LMTP, POP3)"))
-			os.Exit(errorHandler.WaitForExit())
-		}
-
-		// Initialize S3 storage
-		s3EndpointToUse := cfg.S3.Endpoint
-		if s3EndpointToUse == "" {
-			errorHandler.ValidationError("S3 endpoint", fmt.Errorf("S3 endpoint not specified"))
-			os.Exit(errorHandler.WaitForExit())
-		}
-		s3Timeout, err := cfg.S3.GetTimeout()
-		if err != nil {
-			errorHandler.ValidationError("S3 timeout", err)
-			os.Exit(errorHandler.WaitForExit())
+		if cfg.S3.IsFilesystem() {
+			logger.Info("Using filesystem object storage", "path", cfg.S3.Path)
+		} else {
+			s3Timeout, err := cfg.S3.GetTimeout()
+			if err != nil {
+				errorHandler.ValidationError("S3 timeout", err)
+				os.Exit(errorHandler.WaitForExit())
+			}
+			logger.Info("Connecting to S3", "endpoint", cfg.S3.Endpoint, "bucket", cfg.S3.Bucket, "timeout", s3Timeout)
 		}
-		logger.Info("Connecting to S3", "endpoint", s3EndpointToUse, "bucket", cfg.S3.Bucket, "timeout", s3Timeout)
-		deps.storage, err = storage.New(s3EndpointToUse, cfg.S3.AccessKey, cfg.S3.SecretKey, cfg.S3.Bucket, !cfg.S3.DisableTLS, cfg.S3.GetDebug(), s3Timeout)
+		var err error
+		deps.storage, err = storage.NewFromConfig(&cfg.S3)
 		if err != nil {
-			errorHandler.FatalError(fmt.Sprintf("initialize S3 storage at endpoint '%s'", s3EndpointToUse), err)
+			errorHandler.FatalError("initialize object storage", err)
 			os.Exit(errorHandler.WaitForExit())
 		}
-
-		// Enable encryption if configured
-		if cfg.S3.Encrypt {
-			if err := deps.storage.EnableEncryption(cfg.S3.EncryptionKey); err != nil {
-				errorHandler.FatalError("enable S3 encryption", err)
-				os.Exit(errorHandler.WaitForExit())
-			}
-		}
 	}
 
 	// Initialize the resilient database with runtime failover (if needed)
@@ -677,6 +688,9 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 			errorHandler.FatalError("initialize cache", err)
 			os.Exit(errorHandler.WaitForExit())
 		}
+		if cfg.LocalCache.Compress {
+			deps.cacheInstance.EnableCompression()
+		}
 		deps.cacheInstance.StartPurgeLoop(ctx)
 
 		// Register cache health check
@@ -756,9 +770,11 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 		ftsRetention := cfg.Cleanup.GetFTSRetentionWithDefault()
 		deps.ftsRetention = ftsRetention
 		healthStatusRetention := cfg.Cleanup.GetHealthStatusRetentionWithDefault()
+		auditLogRetention := cfg.Cleanup.GetAuditLogRetentionWithDefault()
+		deliveryLogRetention := cfg.Cleanup.GetDeliveryLogRetentionWithDefault()
 
 		cleanupErrChan := make(chan error, 1)
-		deps.cleanupWorker = cleaner.New(deps.resilientDB, deps.storage, deps.cacheInstance, wakeInterval, gracePeriod, maxAgeRestriction, ftsRetention, healthStatusRetention, cleanupErrChan)
+		deps.cleanupWorker = cleaner.New(deps.resilientDB, deps.storage, deps.cacheInstance, wakeInterval, gracePeriod, maxAgeRestriction, ftsRetention, healthStatusRetention, auditLogRetention, deliveryLogRetention, cleanupErrChan)
 		deps.ftsWorker = fts.NewWorker(deps.resilientDB)
 
 		// Start error listener for cleanup worker
@@ -809,6 +825,20 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 			errorHandler.FatalError("start upload worker", err)
 			os.Exit(errorHandler.WaitForExit())
 		}
+
+		// Start the admin job worker
+		if cfg.Jobs.Enabled {
+			opts, err := adminjobs.OptionsFromConfig(&cfg.Jobs)
+			if err != nil {
+				errorHandler.FatalError("parse jobs configuration", err)
+				os.Exit(errorHandler.WaitForExit())
+			}
+			deps.jobWorker = adminjobs.NewWorker(deps.resilientDB, fmt.Sprintf("%s:%d", hostname, os.Getpid()), opts)
+			adminjobs.RegisterBuiltins(deps.jobWorker, deps.resilientDB, deps.storage, ftsRetention)
+			adminjobs.RegisterMaildir(deps.jobWorker, deps.resilientDB, deps.storage, cfg.Jobs.GetDataPath(), cfg.AdminCLI.GetImportMessageLimit(cfg.DynamicServers))
+			adminjobs.RegisterTakeout(deps.jobWorker, deps.resilientDB, deps.storage, deps.cacheInstance, cfg.Jobs.GetDataPath())
+			deps.jobWorker.Start(ctx)
+		}
 	} else {
 		logger.Info("Skipping startup of cache, uploader, and cleaner services as no mail storage services (IMAP, POP3, LMTP) are enabled.")
 	}
@@ -842,8 +872,29 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 			os.Exit(errorHandler.WaitForExit())
 		}
 
+		priorities, err := relayqueue.ParsePriorities(cfg.Relay.Queue.Priorities)
+		if err != nil {
+			errorHandler.FatalError("parse relay queue priorities", err)
+			os.Exit(errorHandler.WaitForExit())
+		}
+		deps.relayQueue.SetPriorities(priorities)
+
 		logger.Info("Relay queue initialized", "path", queuePath, "max_attempts", cfg.Relay.Queue.MaxAttempts)
 
+		if cfg.Relay.SRS.Enabled {
+			maxAge, err := cfg.Relay.SRS.GetMaxAge()
+			if err != nil {
+				errorHandler.FatalError("parse SRS max age", err)
+				os.Exit(errorHandler.WaitForExit())
+			}
+			deps.srs, err = srs.New(cfg.Relay.SRS.Domain, cfg.Relay.SRS.Secrets, maxAge)
+			if err != nil {
+				errorHandler.FatalError("configure SRS", err)
+				os.Exit(errorHandler.WaitForExit())
+			}
+			logger.Info("SRS enabled for redirected messages", "domain", deps.srs.Domain(), "keys", len(cfg.Relay.SRS.Secrets))
+		}
+
 		// Create relay handler from global relay config if configured
 		if cfg.Relay.IsConfigured() {
 			var relayHandler delivery.RelayHandler
@@ -902,6 +953,65 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 				logger.Info("Relay handler configured: type=http", "url", cfg.Relay.HTTPURL, "cb_threshold", cbThreshold, "cb_timeout", cbTimeout, "cb_max_requests", cbMaxRequests)
 			}
 
+			// Smart hosts for selected sender or recipient domains, each with
+			// its own circuit breaker
+			var routes []relayqueue.Route
+			for _, rc := range cfg.Relay.Routes {
+				if err := rc.Validate(); err != nil {
+					errorHandler.FatalError("configure relay routes", err)
+					os.Exit(errorHandler.WaitForExit())
+				}
+				routes = append(routes, relayqueue.Route{
+					Name:             rc.Name,
+					SenderDomains:    rc.SenderDomains,
+					RecipientDomains: rc.RecipientDomains,
+					Handler: delivery.NewRelayHandlerFromConfig(
+						rc.Type,
+						rc.SMTPHost,
+						rc.HTTPURL,
+						rc.AuthToken,
+						"relay_queue",
+						rc.SMTPTLS,
+						rc.SMTPTLSVerify,
+						rc.SMTPUseStartTLS,
+						rc.SMTPTLSCertFile,
+						rc.SMTPTLSKeyFile,
+						cfg.Relay.GetSMTPHELOHost(),
+						&serverLogger{},
+						cbConfig,
+					),
+				})
+				logger.Info("Relay route configured", "name", rc.Name, "type", rc.Type, "host", rc.SMTPHost, "url", rc.HTTPURL,
+					"sender_domains", rc.SenderDomains, "recipient_domains", rc.RecipientDomains)
+			}
+
+			// DKIM-sign relayed mail with the per-domain keys in the database
+			if deps.resilientDB != nil {
+				deps.dkimSigner = delivery.NewDKIMSigner(deps.resilientDB)
+				handlers := []delivery.RelayHandler{relayHandler}
+				for _, route := range routes {
+					handlers = append(handlers, route.Handler)
+				}
+				for _, handler := range handlers {
+					switch h := handler.(type) {
+					case *delivery.SMTPRelayHandler:
+						h.Signer = deps.dkimSigner
+					case *delivery.HTTPRelayHandler:
+						h.Signer = deps.dkimSigner
+					}
+				}
+			}
+
+			destinationLimits := make(map[string]relayqueue.DestinationLimit, len(cfg.Relay.Limits))
+			for _, lc := range cfg.Relay.Limits {
+				rate, period, err := lc.GetRate()
+				if err != nil {
+					errorHandler.FatalError("parse relay limits", err)
+					os.Exit(errorHandler.WaitForExit())
+				}
+				destinationLimits[lc.Domain] = relayqueue.DestinationLimit{Concurrency: lc.Concurrency, Rate: rate, Period: period}
+			}
+
 			if relayHandler != nil {
 				batchSize := cfg.Relay.Queue.BatchSize
 				if batchSize <= 0 {
@@ -934,9 +1044,14 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 					}
 				}()
 
+				var workerHandler relayqueue.RelayHandler = relayHandler
+				if len(routes) > 0 {
+					workerHandler = relayqueue.NewRouter(relayHandler, routes)
+				}
+
 				deps.relayWorker = relayqueue.NewWorker(
 					deps.relayQueue,
-					relayHandler,
+					workerHandler,
 					workerInterval,
 					batchSize,
 					concurrency,
@@ -945,6 +1060,24 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 					errCh,
 				)
 
+				if len(destinationLimits) > 0 {
+					deps.relayWorker.SetDestinationLimits(destinationLimits)
+					logger.Info("Relay destination limits configured", "domains", len(destinationLimits))
+				}
+
+				// Return failed messages to their originating accounts as DSNs
+				if cfg.Relay.Bounces.IsEnabled() && deps.resilientDB != nil && deps.uploadWorker != nil {
+					deps.relayWorker.SetBouncer(&delivery.Bouncer{
+						RDB:          deps.resilientDB,
+						Uploader:     deps.uploadWorker,
+						Hostname:     deps.hostname,
+						FTSRetention: deps.ftsRetention,
+						Logger:       &serverLogger{},
+						Types:        cfg.Relay.Bounces.GetTypes(),
+					})
+					logger.Info("Relay bounces enabled", "types", cfg.Relay.Bounces.GetTypes())
+				}
+
 				if err := deps.relayWorker.Start(ctx); err != nil {
 					errorHandler.FatalError("start relay worker", err)
 					os.Exit(errorHandler.WaitForExit())
@@ -968,6 +1101,11 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 						logger.Info("Registered HTTP relay circuit breaker health check")
 					}
 				}
+				for _, route := range routes {
+					if provider, ok := route.Handler.(relayqueue.CircuitBreakerProvider); ok && provider.GetCircuitBreaker() != nil {
+						deps.healthIntegration.RegisterCircuitBreakerCheck("relay_route_"+route.Name, provider.GetCircuitBreaker())
+					}
+				}
 			} else {
 				logger.Warn("Relay queue enabled but no valid relay handler configured")
 			}
@@ -1015,6 +1153,15 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 		}
 	}
 
+	// Load the admin-managed access list (persistent IP/username blocks and
+	// allows) consulted by every protocol server and proxy. Without a database
+	// the list only receives changes gossiped by other cluster nodes.
+	var accessListLoader server.AccessListLoader
+	if deps.resilientDB != nil {
+		accessListLoader = deps.resilientDB
+	}
+	server.GetAccessList().Start(ctx, accessListLoader, deps.clusterManager, server.DefaultAccessListReloadInterval)
+
 	// Initialize TLS manager if TLS is enabled
 	if cfg.TLS.Enabled {
 		logger.Info("Initializing TLS manager", "provider", cfg.TLS.Provider)
@@ -1026,6 +1173,22 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 		logger.Info("TLS manager initialized successfully")
 	}
 
+	// Initialize the pre-delivery content scanner if configured. A broken
+	// scanner configuration is fatal: LMTP would otherwise deliver unscanned.
+	if cfg.Scanner.IsConfigured() {
+		deps.scanner, err = scanner.NewClient(&cfg.Scanner)
+		if err != nil {
+			errorHandler.FatalError("initialize content scanner", err)
+			os.Exit(errorHandler.WaitForExit())
+		}
+		deps.healthIntegration.RegisterCircuitBreakerCheck("scanner", deps.scanner.GetCircuitBreaker())
+		logger.Info("Content scanner initialized",
+			"type", cfg.Scanner.Type,
+			"address", cfg.Scanner.Address,
+			"fail_mode", cfg.Scanner.FailMode,
+			"cb_threshold", cfg.Scanner.CircuitBreaker.GetThreshold())
+	}
+
 	// Start health monitoring
 	deps.healthIntegration.Start(ctx)
 	logger.Info("Health monitoring started - collecting metrics every 30-60 seconds")
@@ -1055,6 +1218,73 @@ func initializeServices(ctx context.Context, cfg config.Config, errorHandler *er
 		}
 	}
 
+	// Initialize webhook dispatcher if configured
+	if cfg.Webhooks.IsConfigured() {
+		dispatcher, err := webhook.New(&cfg.Webhooks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize webhooks: %w", err)
+		}
+		if err := dispatcher.Start(ctx); err != nil {
+			return nil, fmt.Errorf("failed to start webhook dispatcher: %w", err)
+		}
+		deps.webhookDispatcher = dispatcher
+		logger.Info("Webhook dispatcher initialized", "endpoints", len(cfg.Webhooks.Endpoints), "queue_path", cfg.Webhooks.GetQueuePath())
+	}
+
+	// Initialize login history recording (needs the database)
+	if cfg.LoginHistory.IsEnabled() && deps.resilientDB != nil {
+		deps.loginHistory = server.NewLoginHistory(deps.resilientDB, cfg.LoginHistory.GetMaxEntriesPerAccount())
+		deps.loginHistory.Start(ctx)
+		logger.Info("Login history enabled", "max_entries_per_account", cfg.LoginHistory.GetMaxEntriesPerAccount())
+	}
+
+	// Initialize the delivery log (needs the database)
+	if cfg.DeliveryLog.IsEnabled() && deps.resilientDB != nil {
+		deps.deliveryLog = server.NewDeliveryLog(deps.resilientDB)
+		deps.deliveryLog.Start(ctx)
+		logger.Info("Delivery log enabled")
+	}
+
+	// Initialize suspicious login detection (needs the database; notifications need the relay queue)
+	if cfg.SuspiciousLogin.Enabled && deps.resilientDB != nil {
+		slCfg := cfg.SuspiciousLogin
+		opts := server.SuspiciousLoginOptions{
+			Store:            deps.resilientDB,
+			Hostname:         deps.hostname,
+			CheckNetwork:     slCfg.IsCheckNetworkEnabled(),
+			CheckFingerprint: slCfg.IsCheckFingerprintEnabled(),
+			IPv4Prefix:       slCfg.GetIPv4Prefix(),
+			IPv6Prefix:       slCfg.GetIPv6Prefix(),
+			IgnoreNetworks:   slCfg.IgnoreNetworks,
+			Notify:           slCfg.Notify,
+			NotifyFrom:       slCfg.NotifyFrom,
+			StepUp:           slCfg.StepUp,
+		}
+		if slCfg.IsCheckCountryEnabled() {
+			reader, err := geoip.Open(slCfg.GeoIPDatabase)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+			}
+			opts.Countries = reader
+			logger.Info("GeoIP country database loaded", "path", slCfg.GeoIPDatabase, "type", reader.DatabaseType())
+		}
+		if deps.relayQueue != nil {
+			opts.Queue = deps.relayQueue
+			if deps.relayWorker != nil {
+				opts.QueueNotify = deps.relayWorker.NotifyQueued
+			}
+		} else if slCfg.Notify {
+			logger.Warn("Suspicious login notifications need a relay queue; notifications disabled")
+		}
+		detector, err := server.NewSuspiciousLoginDetector(opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize suspicious login detection: %w", err)
+		}
+		deps.suspiciousLogins = detector
+		logger.Info("Suspicious login detection enabled", "check_network", opts.CheckNetwork, "check_country", opts.Countries != nil,
+			"check_fingerprint", opts.CheckFingerprint, "notify", opts.Queue != nil && opts.Notify, "step_up", opts.StepUp)
+	}
+
 	return deps, nil
 }
 
@@ -1157,6 +1387,21 @@ func startServers(ctx context.Context, deps *serverDependencies) chan error {
 	return errChan
 }
 
+// configureProxyBalancing applies remote_balancing and remote_weights to a proxy's connection manager.
+func configureProxyBalancing(connMgr *proxy.ConnectionManager, serverConfig config.ServerConfig) error {
+	balancing, err := serverConfig.GetRemoteBalancing()
+	if err != nil {
+		return err
+	}
+	if err := connMgr.SetBalancing(balancing, serverConfig.RemoteWeights); err != nil {
+		return err
+	}
+	if balancing != proxy.BalancingConsistentHash || len(serverConfig.RemoteWeights) > 0 {
+		logger.Info("Proxy: Backend balancing configured", "name", serverConfig.Name, "strategy", balancing, "weights", serverConfig.RemoteWeights)
+	}
+	return nil
+}
+
 // startConnectionTrackerForProxy initializes and starts a connection tracker for a proxy server (with gossip).
 // Returns both the tracker and the map key to use for registration.
 func startConnectionTrackerForProxy(protocol string, serverName string, hostname string, maxConnectionsPerUser int, maxConnectionsPerUserPerIP int, clusterMgr *cluster.Manager, clusterCfg *config.ClusterConfig, srv interface {
@@ -1319,6 +1564,9 @@ func startDynamicIMAPServer(ctx context.Context, deps *serverDependencies, serve
 			InsecureAuth:                 serverConfig.InsecureAuth || !serverConfig.TLS, // Default true when TLS not enabled (backend behind proxy)
 			Config:                       &deps.config,
 			SpamTraining:                 deps.spamTrainingClient,
+			Webhooks:                     deps.webhookDispatcher,
+			LoginHistory:                 deps.loginHistory,
+			SuspiciousLogins:             deps.suspiciousLogins,
 		})
 	if err != nil {
 		errChan <- err
@@ -1382,6 +1630,8 @@ func startDynamicLMTPServer(ctx context.Context, deps *serverDependencies, serve
 	lmtpServer, err := lmtp.New(ctx, serverConfig.Name, deps.hostname, serverConfig.Addr, deps.storage, deps.resilientDB, deps.uploadWorker, lmtp.LMTPServerOptions{
 		RelayQueue:              deps.relayQueue,  // Global relay queue
 		RelayWorker:             deps.relayWorker, // Global relay worker for immediate processing
+		SRS:                     deps.srs,
+		ARCSealer:               deps.dkimSigner,
 		TLSVerify:               serverConfig.TLSVerify,
 		TLS:                     serverConfig.TLS,
 		TLSCertFile:             serverConfig.TLSCertFile,
@@ -1403,6 +1653,9 @@ func startDynamicLMTPServer(ctx context.Context, deps *serverDependencies, serve
 		RedirectRateWindow:      redirectRateWindow,
 		MaxRedirectHops:         serverConfig.GetMaxRedirectHops(),
 		CommandTimeoutOverrides: lmtpCommandTimeoutOverrides,
+		Webhooks:                deps.webhookDispatcher,
+		DeliveryLog:             deps.deliveryLog,
+		Scanner:                 deps.scanner,
 	})
 
 	if err != nil {
@@ -1495,6 +1748,8 @@ func startDynamicPOP3Server(ctx context.Context, deps *serverDependencies, serve
 		MinBytesPerMinute:         serverConfig.GetMinBytesPerMinute(),
 		InsecureAuth:              serverConfig.InsecureAuth || !serverConfig.TLS, // Default true when TLS not enabled (backend behind proxy)
 		Config:                    &deps.config,
+		LoginHistory:              deps.loginHistory,
+		SuspiciousLogins:          deps.suspiciousLogins,
 	})
 
 	if err != nil {
@@ -1604,6 +1859,8 @@ func startDynamicManageSieveServer(ctx context.Context, deps *serverDependencies
 		AbsoluteSessionTimeout:    absoluteSessionTimeout,
 		MinBytesPerMinute:         serverConfig.GetMinBytesPerMinute(),
 		Config:                    &deps.config,
+		LoginHistory:              deps.loginHistory,
+		SuspiciousLogins:          deps.suspiciousLogins,
 	})
 
 	if err != nil {
@@ -1761,6 +2018,11 @@ func startDynamicIMAPProxyServer(ctx context.Context, deps *serverDependencies,
 			logger.Info("IMAP Proxy: Affinity manager attached to connection manager", "name", serverConfig.Name)
 		}
 
+		if err := configureProxyBalancing(connMgr, serverConfig); err != nil {
+			errChan <- fmt.Errorf("IMAP proxy %s: %w", serverConfig.Name, err)
+			return
+		}
+
 		// Register remotelookup health check if remotelookup is enabled
 		if routingLookup := connMgr.GetRoutingLookup(); routingLookup != nil {
 			if healthChecker, ok := routingLookup.(health.RemoteLookupHealthChecker); ok {
@@ -1885,6 +2147,11 @@ func startDynamicPOP3ProxyServer(ctx context.Context, deps *serverDependencies,
 			logger.Info("POP3 Proxy: Affinity manager attached to connection manager", "name", serverConfig.Name)
 		}
 
+		if err := configureProxyBalancing(connMgr, serverConfig); err != nil {
+			errChan <- fmt.Errorf("POP3 proxy %s: %w", serverConfig.Name, err)
+			return
+		}
+
 		// Register remotelookup health check if remotelookup is enabled
 		if routingLookup := connMgr.GetRoutingLookup(); routingLookup != nil {
 			if healthChecker, ok := routingLookup.(health.RemoteLookupHealthChecker); ok {
@@ -2008,6 +2275,11 @@ func startDynamicManageSieveProxyServer(ctx context.Context, deps *serverDepende
 			logger.Info("ManageSieve Proxy: Affinity manager attached to connection manager", "name", serverConfig.Name)
 		}
 
+		if err := configureProxyBalancing(connMgr, serverConfig); err != nil {
+			errChan <- fmt.Errorf("ManageSieve proxy %s: %w", serverConfig.Name, err)
+			return
+		}
+
 		// Register remotelookup health check if remotelookup is enabled
 		if routingLookup := connMgr.GetRoutingLookup(); routingLookup != nil {
 			if healthChecker, ok := routingLookup.(health.RemoteLookupHealthChecker); ok {
@@ -2129,6 +2401,11 @@ func startDynamicLMTPProxyServer(ctx context.Context, deps *serverDependencies,
 			logger.Info("LMTP Proxy: Affinity manager attached to connection manager", "name", serverConfig.Name)
 		}
 
+		if err := configureProxyBalancing(connMgr, serverConfig); err != nil {
+			errChan <- fmt.Errorf("LMTP proxy %s: %w", serverConfig.Name, err)
+			return
+		}
+
 		// Register remotelookup health check if remotelookup is enabled
 		if routingLookup := connMgr.GetRoutingLookup(); routingLookup != nil {
 			if healthChecker, ok := routingLookup.(health.RemoteLookupHealthChecker); ok {
@@ -2168,7 +2445,7 @@ func startDynamicHTTPAdminAPIServer(ctx context.Context, deps *serverDependencie
 	deps.serverManager.Add()
 	defer deps.serverManager.Done()
 
-	if serverConfig.APIKey == "" {
+	if serverConfig.APIKey == "" && len(serverConfig.APIKeys) == 0 {
 		logger.Info("HTTP Admin API server enabled but no API key configured - skipping", "name", serverConfig.Name)
 		return
 	}
@@ -2204,15 +2481,25 @@ func startDynamicHTTPAdminAPIServer(ctx context.Context, deps *serverDependencie
 		redirectRateWindow = time.Hour
 	}
 
+	// Only hand over a live affinity manager: a nil *server.AffinityManager stored in
+	// the interface would not compare equal to nil in the handlers.
+	var affinityManager adminapi.AffinityManager
+	if deps.affinityManager != nil {
+		affinityManager = deps.affinityManager
+	}
+
 	options := adminapi.ServerOptions{
 		Name:               serverConfig.Name,
 		Addr:               serverConfig.Addr,
 		APIKey:             serverConfig.APIKey,
+		APIKeys:            serverConfig.APIKeys,
 		AllowedHosts:       serverConfig.AllowedHosts,
 		Cache:              deps.cacheInstance,
 		Uploader:           deps.uploadWorker,
 		Storage:            deps.storage,
 		RelayQueue:         deps.relayQueue, // Global relay queue
+		SRS:                deps.srs,
+		ARCSealer:          deps.dkimSigner,
 		MaxMessageSize:     serverConfig.GetMaxMessageSizeWithDefault(),
 		MaxConnections:     serverConfig.MaxConnections,
 		TLS:                serverConfig.TLS,
@@ -2222,7 +2509,7 @@ func startDynamicHTTPAdminAPIServer(ctx context.Context, deps *serverDependencie
 		TLSVerify:          serverConfig.TLSVerify,
 		Hostname:           deps.hostname,
 		FTSRetention:       deps.ftsRetention,
-		AffinityManager:    deps.affinityManager,
+		AffinityManager:    affinityManager,
 		ValidBackends:      validBackends,
 		ConnectionTrackers: deps.connectionTrackers,
 		ProxyServers:       deps.proxyServers,
@@ -2230,6 +2517,10 @@ func startDynamicHTTPAdminAPIServer(ctx context.Context, deps *serverDependencie
 		RedirectRateLimit:  serverConfig.GetRedirectRateLimit(),
 		RedirectRateWindow: redirectRateWindow,
 		MaxRedirectHops:    serverConfig.GetMaxRedirectHops(),
+		Webhooks:           deps.webhookDispatcher,
+		JobWorker:          deps.jobWorker,
+		JobMaxAttempts:     deps.config.Jobs.GetMaxAttempts(),
+		SieveExtensions:    deps.config.Sieve.EnabledExtensions,
 	}
 
 	srv := adminapi.Start(ctx, deps.resilientDB, options, errChan)
@@ -2272,6 +2563,14 @@ func startDynamicHTTPUserAPIServer(ctx context.Context, deps *serverDependencies
 		}
 	}
 
+	var takeout *mailapi.TakeoutOptions
+	if deps.config.Takeout.Enabled {
+		var err error
+		if takeout, err = takeoutOptions(&deps.config.Takeout, deps.config.Jobs.GetMaxAttempts()); err != nil {
+			logger.Warn("Invalid [takeout] configuration for HTTP User API server - account export disabled", "name", serverConfig.Name, "error", err)
+		}
+	}
+
 	options := mailapi.ServerOptions{
 		Name:                        serverConfig.Name,
 		Addr:                        serverConfig.Addr,
@@ -2294,6 +2593,12 @@ func startDynamicHTTPUserAPIServer(ctx context.Context, deps *serverDependencies
 		ProxyProtocolTimeout:        serverConfig.GetProxyProtocolTimeoutWithDefault(),
 		ProxyProtocolTrustedProxies: deps.config.Servers.TrustedNetworks,
 		TrustedNetworks:             deps.config.Servers.TrustedNetworks,
+		Webhooks:                    deps.webhookDispatcher,
+		LoginHistory:                deps.loginHistory,
+		SuspiciousLogins:            deps.suspiciousLogins,
+		ConnectionTrackers:          deps.connectionTrackers,
+		Takeout:                     takeout,
+		SieveExtensions:             deps.config.Sieve.EnabledExtensions,
 	}
 
 	srv := mailapi.Start(ctx, deps.resilientDB, options, errChan)
@@ -2302,6 +2607,28 @@ func startDynamicHTTPUserAPIServer(ctx context.Context, deps *serverDependencies
 	}
 }
 
+// takeoutOptions parses the [takeout] durations for the User API.
+func takeoutOptions(cfg *config.TakeoutConfig, maxAttempts int) (*mailapi.TakeoutOptions, error) {
+	minInterval, err := cfg.GetMinInterval()
+	if err != nil {
+		return nil, fmt.Errorf("min_interval: %w", err)
+	}
+	linkTTL, err := cfg.GetLinkTTL()
+	if err != nil {
+		return nil, fmt.Errorf("link_ttl: %w", err)
+	}
+	retention, err := cfg.GetRetention()
+	if err != nil {
+		return nil, fmt.Errorf("retention: %w", err)
+	}
+	return &mailapi.TakeoutOptions{
+		MinInterval: minInterval,
+		LinkTTL:     linkTTL,
+		Retention:   retention,
+		MaxAttempts: maxAttempts,
+	}, nil
+}
+
 func startDynamicUserAPIProxyServer(ctx context.Context, deps *serverDependencies, serverConfig config.ServerConfig, errChan chan error) {
 	deps.serverManager.Add()
 	defer deps.serverManager.Done()
@@ -154,6 +154,8 @@ func (d *DatabaseConfig) GetFetchChunkSize() int {
 
 // S3Config holds S3 configuration.
 type S3Config struct {
+	Backend       string `toml:"backend"` // Object storage backend: "s3" (default) or "filesystem"
+	Path          string `toml:"path"`    // Root directory for the filesystem backend
 	Endpoint      string `toml:"endpoint"`
 	DisableTLS    bool   `toml:"disable_tls"`
 	AccessKey     string `toml:"access_key"`
@@ -163,6 +165,13 @@ type S3Config struct {
 	Timeout       string `toml:"timeout"` // Timeout for individual S3 operations (default: 30s)
 	Encrypt       bool   `toml:"encrypt"`
 	EncryptionKey string `toml:"encryption_key"`
+	Compress      bool   `toml:"compress"` // Compress message bodies with zstd before upload
+}
+
+// IsFilesystem reports whether message bodies are stored in a local (or
+// NFS-mounted) directory instead of S3.
+func (s *S3Config) IsFilesystem() bool {
+	return strings.EqualFold(s.Backend, "filesystem")
 }
 
 // GetDebug returns the debug flag
@@ -303,6 +312,8 @@ type CleanupConfig struct {
 	MaxAgeRestriction     string `toml:"max_age_restriction"`
 	FTSRetention          string `toml:"fts_retention"` // How long to keep the messages_fts row (FTS vectors + raw headers)
 	HealthStatusRetention string `toml:"health_status_retention"`
+	AuditLogRetention     string `toml:"audit_log_retention"`    // How long to keep audit log entries (empty = forever)
+	DeliveryLogRetention  string `toml:"delivery_log_retention"` // How long to keep delivery log entries (default: 30d)
 }
 
 // GetGracePeriod parses the grace period duration
@@ -345,6 +356,22 @@ func (c *CleanupConfig) GetHealthStatusRetention() (time.Duration, error) {
 	return helpers.ParseDuration(c.HealthStatusRetention)
 }
 
+// GetAuditLogRetention parses the audit log retention duration
+func (c *CleanupConfig) GetAuditLogRetention() (time.Duration, error) {
+	if c.AuditLogRetention == "" {
+		return 0, nil // 0 means keep audit entries forever (default)
+	}
+	return helpers.ParseDuration(c.AuditLogRetention)
+}
+
+// GetDeliveryLogRetention parses the delivery log retention duration
+func (c *CleanupConfig) GetDeliveryLogRetention() (time.Duration, error) {
+	if c.DeliveryLogRetention == "" {
+		return 30 * 24 * time.Hour, nil // 30 days default
+	}
+	return helpers.ParseDuration(c.DeliveryLogRetention)
+}
+
 // LocalCacheConfig holds local disk cache configuration.
 type LocalCacheConfig struct {
 	Capacity           string   `toml:"capacity"`
@@ -360,6 +387,7 @@ type LocalCacheConfig struct {
 	WarmupAsync        bool     `toml:"warmup_async"`
 	WarmupTimeout      string   `toml:"warmup_timeout"`
 	WarmupInterval     string   `toml:"warmup_interval"`
+	Compress           bool     `toml:"compress"` // Store cached objects zstd-compressed
 }
 
 // GetCapacity parses the cache capacity size
@@ -895,6 +923,15 @@ type LMTPCommandTimeoutsConfig struct {
 	Data string `toml:"data,omitempty"` // DATA processing timeout (default: "60s")
 }
 
+// AdminAPIKeyConfig is a named, scoped Admin API key defined in the config
+// file. Keys can also be stored in the database with "sora-admin api-keys".
+type AdminAPIKeyConfig struct {
+	Name    string   `toml:"name"`
+	KeyHash string   `toml:"key_hash"` // hex SHA-256 of the key, optionally prefixed with "sha256:"
+	Scopes  []string `toml:"scopes"`   // read, accounts, connections, cache, delivery, or "*"
+	Domains []string `toml:"domains"`  // restrict the key to these domains (empty = all)
+}
+
 // ServerConfig represents a single server instance
 type ServerConfig struct {
 	Type string `toml:"type"`
@@ -960,9 +997,14 @@ type ServerConfig struct {
 	EnableAffinity         bool     `toml:"enable_affinity,omitempty"`
 	RemoteHealthChecks     *bool    `toml:"remote_health_checks,omitempty"` // Enable backend health checking (default: true)
 
+	// Proxy load balancing
+	RemoteBalancing string         `toml:"remote_balancing,omitempty"` // Backend selection for users without affinity: "consistent_hash" (default) or "least_connections"
+	RemoteWeights   map[string]int `toml:"remote_weights,omitempty"`   // Per-backend weight keyed by remote_addrs entry (default: 1)
+
 	// HTTP API specific
-	APIKey       string   `toml:"api_key,omitempty"`
-	AllowedHosts []string `toml:"allowed_hosts,omitempty"`
+	APIKey       string              `toml:"api_key,omitempty"`  // Full-access key
+	APIKeys      []AdminAPIKeyConfig `toml:"api_keys,omitempty"` // Additional scoped keys
+	AllowedHosts []string            `toml:"allowed_hosts,omitempty"`
 
 	// Mail HTTP API specific (stateless JWT-based authentication)
 	JWTSecret      string   `toml:"jwt_secret,omitempty"`      // Secret key for signing JWT tokens
@@ -1056,6 +1098,24 @@ type AdminCLIConfig struct {
 	ImportMessageLimit string `toml:"import_message_limit"` // Maximum message size for import operations (e.g., "50mb")
 }
 
+// GetImportMessageLimit returns the maximum size of an imported message:
+// import_message_limit if set, else the append limit of the first IMAP
+// server in servers, else 50MB (more than the IMAP default, since imports
+// are batch operations).
+func (c *AdminCLIConfig) GetImportMessageLimit(servers []ServerConfig) int64 {
+	if c.ImportMessageLimit != "" {
+		limit, err := helpers.ParseSize(c.ImportMessageLimit)
+		if err == nil {
+			return limit
+		}
+		log.Printf("WARNING: Failed to parse admin_cli.import_message_limit '%s': %v, using fallback", c.ImportMessageLimit, err)
+	}
+	if appendLimit, err := IMAPAppendLimit(servers); err == nil && appendLimit > 0 {
+		return appendLimit
+	}
+	return 50 * 1024 * 1024
+}
+
 // SieveConfig holds Sieve script engine configuration
 type SieveConfig struct {
 	EnabledExtensions []string `toml:"enabled_extensions"` // List of enabled Sieve extensions (empty = all extensions enabled)
@@ -1129,6 +1189,13 @@ type Config struct {
 	Sieve            SieveConfig            `toml:"sieve"`
 	Relay            RelayConfig            `toml:"relay"`
 	SpamTraining     SpamTrainingConfig     `toml:"spam_training"`     // Spam filter training configuration
+	Scanner          ScannerConfig          `toml:"scanner"`           // Pre-delivery content scanning
+	Webhooks         WebhooksConfig         `toml:"webhooks"`          // Outbound webhook events
+	Jobs             JobsConfig             `toml:"jobs"`              // Asynchronous admin jobs
+	LoginHistory     LoginHistoryConfig     `toml:"login_history"`     // Per-account login history
+	DeliveryLog      DeliveryLogConfig      `toml:"delivery_log"`      // Searchable per-message LMTP delivery log
+	SuspiciousLogin  SuspiciousLoginConfig  `toml:"suspicious_login"`  // New network/country/device detection
+	Takeout          TakeoutConfig          `toml:"takeout"`           // Self-service account exports
 	AdminCLI         AdminCLIConfig         `toml:"admin_cli"`         // Admin CLI tool configuration
 	TimeoutScheduler TimeoutSchedulerConfig `toml:"timeout_scheduler"` // Global timeout scheduler configuration
 
@@ -1261,7 +1328,7 @@ func (s *ServerConfig) GetAppendLimit() (int64, error) {
 
 // IMAPAppendLimit returns the append limit of the first enabled IMAP server
 // ([[server]] with type = "imap") in the given list, or an error if none is
-// configured. Used by sora-admin as a fallback bound for batch imports.
+// configured. Used as a fallback bound for batch imports.
 func IMAPAppendLimit(servers []ServerConfig) (int64, error) {
 	for i := range servers {
 		s := &servers[i]
@@ -1584,6 +1651,19 @@ func (s *ServerConfig) GetRemoteHealthChecks() bool {
 	return *s.RemoteHealthChecks
 }
 
+// GetRemoteBalancing returns the backend balancing strategy for proxy servers.
+// Defaults to "consistent_hash" if not set.
+func (s *ServerConfig) GetRemoteBalancing() (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s.RemoteBalancing)) {
+	case "", "consistent_hash":
+		return "consistent_hash", nil
+	case "least_connections":
+		return "least_connections", nil
+	default:
+		return "", fmt.Errorf("invalid remote_balancing %q (must be consistent_hash or least_connections)", s.RemoteBalancing)
+	}
+}
+
 // Configuration defaulting methods with logging
 func (s *ServerConfig) GetAppendLimitWithDefault() int64 {
 	limit, err := s.GetAppendLimit()
@@ -1691,6 +1771,24 @@ func (c *CleanupConfig) GetHealthStatusRetentionWithDefault() time.Duration {
 	return retention
 }
 
+func (c *CleanupConfig) GetAuditLogRetentionWithDefault() time.Duration {
+	retention, err := c.GetAuditLogRetention()
+	if err != nil {
+		log.Printf("WARNING: Failed to parse cleanup audit_log_retention: %v, using default (keep forever)", err)
+		return 0
+	}
+	return retention
+}
+
+func (c *CleanupConfig) GetDeliveryLogRetentionWithDefault() time.Duration {
+	retention, err := c.GetDeliveryLogRetention()
+	if err != nil {
+		log.Printf("WARNING: Failed to parse cleanup delivery_log_retention: %v, using default (30 days)", err)
+		return 30 * 24 * time.Hour
+	}
+	return retention
+}
+
 func (c *LocalCacheConfig) GetCapacityWithDefault() int64 {
 	capacity, err := c.GetCapacity()
 	if err != nil {
@@ -1795,6 +1893,15 @@ func (s *ServerConfig) Validate() error {
 		return fmt.Errorf("server %q: master_sasl_username is set but master_sasl_password is empty; refusing to start (an empty password would allow impersonation of any account)", s.Name)
 	}
 
+	if _, err := s.GetRemoteBalancing(); err != nil {
+		return fmt.Errorf("server %q: %w", s.Name, err)
+	}
+	for addr, weight := range s.RemoteWeights {
+		if weight < 1 {
+			return fmt.Errorf("server %q: remote_weights[%q] must be at least 1", s.Name, addr)
+		}
+	}
+
 	return nil
 }
 
@@ -89,6 +89,76 @@ func TestRelayQueueConfig_CircuitBreakerInvalidTimeout(t *testing.T) {
 	}
 }
 
+func TestRelayLimitConfig_GetRate(t *testing.T) {
+	tests := []struct {
+		rate    string
+		count   int
+		period  time.Duration
+		wantErr bool
+	}{
+		{"", 0, 0, false},
+		{"100/h", 100, time.Hour, false},
+		{"20/10m", 20, 10 * time.Minute, false},
+		{"5 / 1d", 5, 24 * time.Hour, false},
+		{"100", 0, 0, true},
+		{"0/h", 0, 0, true},
+		{"10/fortnight", 0, 0, true},
+	}
+	for _, tt := range tests {
+		cfg := RelayLimitConfig{Domain: "example.com", Rate: tt.rate}
+		count, period, err := cfg.GetRate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("GetRate(%q) error = %v, wantErr %v", tt.rate, err, tt.wantErr)
+			continue
+		}
+		if count != tt.count || period != tt.period {
+			t.Errorf("GetRate(%q) = %d, %v; want %d, %v", tt.rate, count, period, tt.count, tt.period)
+		}
+	}
+}
+
+func TestRelayRouteConfig_Validate(t *testing.T) {
+	valid := RelayRouteConfig{Name: "google", RecipientDomains: []string{"gmail.com"}, Type: "smtp", SMTPHost: "smtp.example.com:587"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid route: %v", err)
+	}
+	for name, route := range map[string]RelayRouteConfig{
+		"no name":    {RecipientDomains: []string{"gmail.com"}, Type: "smtp", SMTPHost: "h:25"},
+		"no domains": {Name: "r", Type: "smtp", SMTPHost: "h:25"},
+		"no host":    {Name: "r", SenderDomains: []string{"a.example"}, Type: "smtp"},
+		"no url":     {Name: "r", SenderDomains: []string{"a.example"}, Type: "http"},
+		"bad type":   {Name: "r", SenderDomains: []string{"a.example"}, Type: "lmtp"},
+	} {
+		if err := route.Validate(); err == nil {
+			t.Errorf("%s: Validate accepted %+v", name, route)
+		}
+	}
+}
+
+func TestScannerConfig_Validate(t *testing.T) {
+	valid := ScannerConfig{Enabled: true, Type: "rspamd", Address: "http://127.0.0.1:11333"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid scanner: %v", err)
+	}
+	if timeout, _ := valid.GetTimeout(); timeout != 15*time.Second {
+		t.Errorf("default timeout = %v", timeout)
+	}
+	if valid.IsFailClosed() || !valid.ShouldAddHeaders() || valid.GetVirusAction() != "reject" {
+		t.Errorf("unexpected defaults: %+v", valid)
+	}
+	for name, cfg := range map[string]ScannerConfig{
+		"bad type":         {Type: "spamassassin"},
+		"bad fail mode":    {Type: "clamd", FailMode: "maybe"},
+		"bad virus action": {Type: "clamd", VirusAction: "delete"},
+		"bad timeout":      {Type: "clamd", Timeout: "soon"},
+		"bad max size":     {Type: "clamd", MaxMessageSize: "big"},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: Validate accepted %+v", name, cfg)
+		}
+	}
+}
+
 func TestClusterConfig_AddrWithPort(t *testing.T) {
 	cfg := ClusterConfig{
 		Addr: "10.10.10.40:7946",
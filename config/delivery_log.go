@@ -0,0 +1,17 @@
+package config
+
+// DeliveryLogConfig configures the delivery log: one entry per LMTP delivery
+// outcome, searched through the Admin API and sora-admin. Entries are expired
+// by the cleaner (cleanup.delivery_log_retention).
+type DeliveryLogConfig struct {
+	// Record deliveries (default: true)
+	Enabled *bool `toml:"enabled"`
+}
+
+// IsEnabled reports whether deliveries are recorded. Defaults to true.
+func (d *DeliveryLogConfig) IsEnabled() bool {
+	if d.Enabled == nil {
+		return true
+	}
+	return *d.Enabled
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"time"
+
+	"github.com/migadu/sora/helpers"
+)
+
+// JobsConfig configures the worker that runs asynchronous admin jobs
+// (import, export, purge, S3 verification, FTS rebuild) submitted through the
+// Admin API or 'sora-admin jobs'.
+type JobsConfig struct {
+	// Run a job worker in this instance. Any number of instances may run one;
+	// each job is claimed by exactly one worker.
+	Enabled bool `toml:"enabled"`
+
+	// Number of jobs run at the same time by this worker (default: 1)
+	Concurrency int `toml:"concurrency"`
+
+	// How often the worker looks for new jobs (default: "10s")
+	PollInterval string `toml:"poll_interval"`
+
+	// A running job whose worker has not reported for this long is taken over
+	// by another worker (default: "2m")
+	HeartbeatTimeout string `toml:"heartbeat_timeout"`
+
+	// Attempts before a failing job is marked failed (default: 3)
+	MaxAttempts int `toml:"max_attempts"`
+
+	// Delay before a failed attempt is retried (default: "1m")
+	RetryDelay string `toml:"retry_delay"`
+
+	// How long finished jobs are kept (default: "30d"; "0" keeps them forever)
+	Retention string `toml:"retention"`
+
+	// Directory that import and export job paths are relative to. Jobs cannot
+	// read or write outside it (default: "/var/lib/sora/jobs")
+	DataPath string `toml:"data_path"`
+}
+
+// GetConcurrency returns the number of concurrent jobs
+func (j *JobsConfig) GetConcurrency() int {
+	if j.Concurrency <= 0 {
+		return 1
+	}
+	return j.Concurrency
+}
+
+// GetPollInterval parses the poll interval
+func (j *JobsConfig) GetPollInterval() (time.Duration, error) {
+	if j.PollInterval == "" {
+		return 10 * time.Second, nil
+	}
+	return helpers.ParseDuration(j.PollInterval)
+}
+
+// GetHeartbeatTimeout parses the heartbeat timeout
+func (j *JobsConfig) GetHeartbeatTimeout() (time.Duration, error) {
+	if j.HeartbeatTimeout == "" {
+		return 2 * time.Minute, nil
+	}
+	return helpers.ParseDuration(j.HeartbeatTimeout)
+}
+
+// GetMaxAttempts returns the default attempts for new jobs
+func (j *JobsConfig) GetMaxAttempts() int {
+	if j.MaxAttempts <= 0 {
+		return 3
+	}
+	return j.MaxAttempts
+}
+
+// GetRetryDelay parses the retry delay
+func (j *JobsConfig) GetRetryDelay() (time.Duration, error) {
+	if j.RetryDelay == "" {
+		return time.Minute, nil
+	}
+	return helpers.ParseDuration(j.RetryDelay)
+}
+
+// GetRetention parses how long finished jobs are kept
+func (j *JobsConfig) GetRetention() (time.Duration, error) {
+	if j.Retention == "" {
+		return 30 * 24 * time.Hour, nil
+	}
+	return helpers.ParseDuration(j.Retention)
+}
+
+// GetDataPath returns the data path with default if not set
+func (j *JobsConfig) GetDataPath() string {
+	if j.DataPath != "" {
+		return j.DataPath
+	}
+	return "/var/lib/sora/jobs"
+}
@@ -0,0 +1,27 @@
+package config
+
+// LoginHistoryConfig configures the per-account login history that backend
+// servers record and the User API shows to users.
+type LoginHistoryConfig struct {
+	// Record logins (default: true)
+	Enabled *bool `toml:"enabled"`
+
+	// Entries kept per account; older ones are pruned (default: 100)
+	MaxEntriesPerAccount int `toml:"max_entries_per_account"`
+}
+
+// IsEnabled reports whether login history is recorded. Defaults to true.
+func (l *LoginHistoryConfig) IsEnabled() bool {
+	if l.Enabled == nil {
+		return true
+	}
+	return *l.Enabled
+}
+
+// GetMaxEntriesPerAccount returns the number of entries kept per account
+func (l *LoginHistoryConfig) GetMaxEntriesPerAccount() int {
+	if l.MaxEntriesPerAccount <= 0 {
+		return 100
+	}
+	return l.MaxEntriesPerAccount
+}
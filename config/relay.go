@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/migadu/sora/helpers"
@@ -27,6 +30,67 @@ type RelayConfig struct {
 
 	// Queue configuration (nested under [relay.queue] in TOML)
 	Queue RelayQueueConfig `toml:"queue"`
+
+	// Sender Rewriting Scheme for redirected messages (nested under [relay.srs] in TOML)
+	SRS RelaySRSConfig `toml:"srs"`
+
+	// Delivery status notifications for failed messages (nested under [relay.bounces] in TOML)
+	Bounces RelayBouncesConfig `toml:"bounces"`
+
+	// Per-destination-domain delivery caps ([[relay.limits]] in TOML)
+	Limits []RelayLimitConfig `toml:"limits"`
+
+	// Smart hosts for selected sender or recipient domains ([[relay.routes]] in TOML).
+	// The first matching route is used; other messages go through the relay above.
+	Routes []RelayRouteConfig `toml:"routes"`
+}
+
+// RelayLimitConfig caps deliveries to one recipient domain. Messages over a
+// cap stay in the pending queue, without using up an attempt, until a later
+// worker cycle.
+type RelayLimitConfig struct {
+	Domain      string `toml:"domain"`      // Recipient domain, or "*" for every domain without a limit of its own
+	Concurrency int    `toml:"concurrency"` // Maximum deliveries in progress at once (0 = no cap)
+	Rate        string `toml:"rate"`        // Maximum deliveries per period, e.g. "100/h" or "20/10m" (empty = no cap)
+}
+
+// RelayRouteConfig sends the messages of some sender or recipient domains
+// through a smart host of their own. A route with both lists set needs both
+// to match.
+type RelayRouteConfig struct {
+	Name             string   `toml:"name"`              // Shown in logs and 'sora-admin relay list'
+	SenderDomains    []string `toml:"sender_domains"`    // Envelope sender domains (e.g. the SRS domain)
+	RecipientDomains []string `toml:"recipient_domains"` // Recipient domains
+
+	// Type and connection settings, as for [relay]
+	Type            string `toml:"type"`
+	SMTPHost        string `toml:"smtp_host"`
+	SMTPTLS         bool   `toml:"smtp_tls"`
+	SMTPTLSVerify   bool   `toml:"smtp_tls_verify"`
+	SMTPUseStartTLS bool   `toml:"smtp_use_starttls"`
+	SMTPTLSCertFile string `toml:"smtp_tls_cert_file"`
+	SMTPTLSKeyFile  string `toml:"smtp_tls_key_file"`
+	HTTPURL         string `toml:"http_url"`
+	AuthToken       string `toml:"auth_token"`
+}
+
+// RelayBouncesConfig selects which relay queue message types produce a
+// delivery status notification (RFC 3464) to the originating account when
+// they fail permanently or run out of attempts.
+type RelayBouncesConfig struct {
+	Enabled *bool    `toml:"enabled"` // Send delivery status notifications (default: true)
+	Types   []string `toml:"types"`   // Message types to bounce (default: ["redirect", "vacation"])
+}
+
+// RelaySRSConfig holds the Sender Rewriting Scheme settings. Redirected
+// messages leave with an envelope sender of Domain, so they pass SPF/DMARC at
+// the destination, and bounces to those addresses are returned to the
+// original sender through the relay queue.
+type RelaySRSConfig struct {
+	Enabled bool     `toml:"enabled"` // Rewrite envelope senders of redirected messages
+	Domain  string   `toml:"domain"`  // Domain of the rewritten addresses; must be delivered to Sora's LMTP
+	Secrets []string `toml:"secrets"` // HMAC secrets: the first signs, all verify (prepend a new secret to rotate)
+	MaxAge  string   `toml:"max_age"` // How long rewritten addresses accept bounces (default: "21d")
 }
 
 // RelayQueueConfig holds relay queue configuration for disk-based retry queue
@@ -42,6 +106,11 @@ type RelayQueueConfig struct {
 	CircuitBreakerMaxRequests int      `toml:"circuit_breaker_max_requests"` // Max requests in half-open state (default: 3)
 	FailedRetention           string   `toml:"failed_retention"`             // How long to retain failed messages before cleanup (e.g., "168h" = 7 days, default: "168h")
 	CleanupInterval           string   `toml:"cleanup_interval"`             // How often to run cleanup of old failed messages (default: "1h")
+
+	// Priority class ("high", "normal" or "low") per message type. Ready
+	// messages are sent highest class first, oldest first within a class.
+	// Types not listed keep their default (vacation is low, the rest normal).
+	Priorities map[string]string `toml:"priorities"`
 }
 
 // IsConfigured returns true if the relay is configured
@@ -85,6 +154,83 @@ func (r *RelayConfig) GetQueuePath() string {
 	return "/var/spool/sora/relay" // Default path
 }
 
+// GetMaxAge parses the SRS address lifetime. Zero means the default.
+func (s *RelaySRSConfig) GetMaxAge() (time.Duration, error) {
+	if s.MaxAge == "" {
+		return 0, nil
+	}
+	return helpers.ParseDuration(s.MaxAge)
+}
+
+// DefaultBounceTypes are the message types bounced when types is not set.
+// Notifications come from postmaster addresses that are usually not accounts,
+// and SRS bounces have a null sender.
+var DefaultBounceTypes = []string{"redirect", "vacation"}
+
+// IsEnabled reports whether failed messages are bounced. Defaults to true.
+func (b *RelayBouncesConfig) IsEnabled() bool {
+	if b.Enabled == nil {
+		return true
+	}
+	return *b.Enabled
+}
+
+// GetTypes returns the message types to bounce.
+func (b *RelayBouncesConfig) GetTypes() []string {
+	if len(b.Types) == 0 {
+		return DefaultBounceTypes
+	}
+	return b.Types
+}
+
+// Validate checks a route's matching and connection settings.
+func (r *RelayRouteConfig) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("relay route: name is required")
+	}
+	if len(r.SenderDomains) == 0 && len(r.RecipientDomains) == 0 {
+		return fmt.Errorf("relay route %q: sender_domains or recipient_domains is required", r.Name)
+	}
+	switch r.Type {
+	case "smtp":
+		if r.SMTPHost == "" {
+			return fmt.Errorf("relay route %q: smtp_host is required", r.Name)
+		}
+	case "http":
+		if r.HTTPURL == "" {
+			return fmt.Errorf("relay route %q: http_url is required", r.Name)
+		}
+	default:
+		return fmt.Errorf("relay route %q: type must be \"smtp\" or \"http\"", r.Name)
+	}
+	return nil
+}
+
+// GetRate parses the rate cap into a number of deliveries and the period they
+// are counted over. A zero count means no cap.
+func (l *RelayLimitConfig) GetRate() (int, time.Duration, error) {
+	if l.Rate == "" {
+		return 0, 0, nil
+	}
+	countStr, periodStr, ok := strings.Cut(l.Rate, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate %q for domain %q: expected count/period, e.g. \"100/h\"", l.Rate, l.Domain)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(countStr))
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate %q for domain %q: count must be a positive number", l.Rate, l.Domain)
+	}
+	periodStr = strings.TrimSpace(periodStr)
+	if periodStr != "" && (periodStr[0] < '0' || periodStr[0] > '9') {
+		periodStr = "1" + periodStr // "h" = "1h"
+	}
+	period, err := helpers.ParseDuration(periodStr)
+	if err != nil || period <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate %q for domain %q: bad period", l.Rate, l.Domain)
+	}
+	return count, period, nil
+}
+
 // GetWorkerInterval parses the worker interval duration
 func (q *RelayQueueConfig) GetWorkerInterval() (time.Duration, error) {
 	if q.WorkerInterval == "" {
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/migadu/sora/helpers"
+)
+
+// ScannerConfig defines the content scanner LMTP consults before storing a
+// message. The scanner speaks the rspamd HTTP protocol (/checkv2) or the
+// clamd INSTREAM protocol, over TCP or a local socket, and its verdict adds
+// headers, files the message into Junk, rejects it or quarantines it.
+type ScannerConfig struct {
+	// Enable scanning of delivered messages
+	Enabled bool `toml:"enabled"`
+
+	// Scanner protocol: "rspamd" or "clamd"
+	Type string `toml:"type"`
+
+	// rspamd: "http://127.0.0.1:11333" or "unix:/run/rspamd/normal.sock"
+	// clamd: "127.0.0.1:3310" or "unix:/run/clamav/clamd.ctl"
+	Address string `toml:"address"`
+
+	// rspamd Password header (optional)
+	Password string `toml:"password"`
+
+	// Timeout of one scan (default: "15s")
+	Timeout string `toml:"timeout"`
+
+	// Larger messages are delivered without scanning (default: "25mb")
+	MaxMessageSize string `toml:"max_message_size"`
+
+	// What to do when the scanner fails, times out or its circuit breaker is
+	// open: "open" delivers the message unscanned, "closed" defers it with a
+	// 451 so the sending MTA retries (default: "open")
+	FailMode string `toml:"fail_mode"`
+
+	// Add X-Spam-*/X-Virus-* headers and the headers the scanner asks for
+	// (default: true)
+	AddHeaders *bool `toml:"add_headers"`
+
+	// rspamd actions mapped to verdicts. "soft reject" always defers the
+	// message; other actions deliver it normally.
+	JunkActions       []string `toml:"junk_actions"`       // Default: ["add header", "rewrite subject"]
+	RejectActions     []string `toml:"reject_actions"`     // Default: ["reject"]
+	QuarantineActions []string `toml:"quarantine_actions"` // Default: none
+
+	// Verdict for a virus found by clamd: "reject", "quarantine" or "junk"
+	// (default: "reject")
+	VirusAction string `toml:"virus_action"`
+
+	// Directory quarantined messages are written to
+	// (default: "/var/spool/sora/quarantine")
+	QuarantinePath string `toml:"quarantine_path"`
+
+	// Circuit breaker for the scanner
+	CircuitBreaker SpamTrainingCircuitBreakerConfig `toml:"circuit_breaker"`
+}
+
+// IsConfigured returns true if scanning is enabled and a scanner is set
+func (s *ScannerConfig) IsConfigured() bool {
+	return s.Enabled && s.Address != ""
+}
+
+// Validate checks the scanner type and verdict settings
+func (s *ScannerConfig) Validate() error {
+	switch s.Type {
+	case "rspamd", "clamd":
+	default:
+		return fmt.Errorf("scanner: type must be \"rspamd\" or \"clamd\", got %q", s.Type)
+	}
+	switch s.FailMode {
+	case "", "open", "closed":
+	default:
+		return fmt.Errorf("scanner: fail_mode must be \"open\" or \"closed\", got %q", s.FailMode)
+	}
+	switch s.VirusAction {
+	case "", "reject", "quarantine", "junk":
+	default:
+		return fmt.Errorf("scanner: virus_action must be \"reject\", \"quarantine\" or \"junk\", got %q", s.VirusAction)
+	}
+	if _, err := s.GetTimeout(); err != nil {
+		return fmt.Errorf("scanner: invalid timeout: %w", err)
+	}
+	if _, err := s.GetMaxMessageSize(); err != nil {
+		return fmt.Errorf("scanner: invalid max_message_size: %w", err)
+	}
+	if _, err := s.CircuitBreaker.GetTimeout(); err != nil {
+		return fmt.Errorf("scanner: invalid circuit_breaker timeout: %w", err)
+	}
+	return nil
+}
+
+// GetTimeout parses and returns the scan timeout
+func (s *ScannerConfig) GetTimeout() (time.Duration, error) {
+	if s.Timeout == "" {
+		return 15 * time.Second, nil // Default: 15 seconds
+	}
+	return helpers.ParseDuration(s.Timeout)
+}
+
+// GetMaxMessageSize parses and returns the maximum scanned message size
+func (s *ScannerConfig) GetMaxMessageSize() (int64, error) {
+	if s.MaxMessageSize == "" {
+		return 25 * 1024 * 1024, nil // Default: 25MB
+	}
+	return helpers.ParseSize(s.MaxMessageSize)
+}
+
+// IsFailClosed reports whether messages are deferred when the scanner fails
+func (s *ScannerConfig) IsFailClosed() bool {
+	return s.FailMode == "closed"
+}
+
+// ShouldAddHeaders reports whether verdict headers are added. Defaults to true.
+func (s *ScannerConfig) ShouldAddHeaders() bool {
+	if s.AddHeaders == nil {
+		return true
+	}
+	return *s.AddHeaders
+}
+
+// GetJunkActions returns the rspamd actions filed into Junk
+func (s *ScannerConfig) GetJunkActions() []string {
+	if s.JunkActions == nil {
+		return []string{"add header", "rewrite subject"}
+	}
+	return s.JunkActions
+}
+
+// GetRejectActions returns the rspamd actions rejected with a 550
+func (s *ScannerConfig) GetRejectActions() []string {
+	if s.RejectActions == nil {
+		return []string{"reject"}
+	}
+	return s.RejectActions
+}
+
+// GetVirusAction returns the verdict for a virus found by clamd
+func (s *ScannerConfig) GetVirusAction() string {
+	if s.VirusAction == "" {
+		return "reject"
+	}
+	return s.VirusAction
+}
+
+// GetQuarantinePath returns the quarantine directory with default if not set
+func (s *ScannerConfig) GetQuarantinePath() string {
+	if s.QuarantinePath != "" {
+		return s.QuarantinePath
+	}
+	return "/var/spool/sora/quarantine"
+}
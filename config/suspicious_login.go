@@ -0,0 +1,65 @@
+package config
+
+// SuspiciousLoginConfig configures detection of logins from networks,
+// countries or TLS client fingerprints never seen before for an account.
+type SuspiciousLoginConfig struct {
+	// Check successful logins against the account's known sources (default: false)
+	Enabled bool `toml:"enabled"`
+
+	// MaxMind-format (MMDB) country database, e.g. GeoLite2-Country.mmdb.
+	// Without it countries are not checked.
+	GeoIPDatabase string `toml:"geoip_database"`
+
+	// Which sources are checked (default: all true)
+	CheckNetwork     *bool `toml:"check_network"`
+	CheckCountry     *bool `toml:"check_country"`
+	CheckFingerprint *bool `toml:"check_fingerprint"`
+
+	// Prefix lengths that make up a network (defaults: 24 and 48)
+	IPv4Prefix int `toml:"ipv4_prefix"`
+	IPv6Prefix int `toml:"ipv6_prefix"`
+
+	// Client networks never considered suspicious (e.g. office networks)
+	IgnoreNetworks []string `toml:"ignore_networks"`
+
+	// Send a notification email to the account through the relay queue
+	Notify bool `toml:"notify"`
+
+	// Sender of notification emails (default: postmaster@<account domain>)
+	NotifyFrom string `toml:"notify_from"`
+
+	// Reject IMAP, POP3 and ManageSieve logins from new sources until the user
+	// confirms them in the User API (default: false)
+	StepUp bool `toml:"step_up"`
+}
+
+// IsCheckNetworkEnabled reports whether client networks are checked
+func (s *SuspiciousLoginConfig) IsCheckNetworkEnabled() bool {
+	return s.CheckNetwork == nil || *s.CheckNetwork
+}
+
+// IsCheckCountryEnabled reports whether client countries are checked
+func (s *SuspiciousLoginConfig) IsCheckCountryEnabled() bool {
+	return s.GeoIPDatabase != "" && (s.CheckCountry == nil || *s.CheckCountry)
+}
+
+// IsCheckFingerprintEnabled reports whether JA4 client fingerprints are checked
+func (s *SuspiciousLoginConfig) IsCheckFingerprintEnabled() bool {
+	return s.CheckFingerprint == nil || *s.CheckFingerprint
+}
+
+// GetIPv4Prefix returns the IPv4 network prefix length
+func (s *SuspiciousLoginConfig) GetIPv4Prefix() int {
+	if s.IPv4Prefix <= 0 || s.IPv4Prefix > 32 {
+		return 24
+	}
+	return s.IPv4Prefix
+}
+
+// GetIPv6Prefix returns the IPv6 network prefix length
+func (s *SuspiciousLoginConfig) GetIPv6Prefix() int {
+	if s.IPv6Prefix <= 0 || s.IPv6Prefix > 128 {
+		return 48
+	}
+	return s.IPv6Prefix
+}
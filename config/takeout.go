@@ -0,0 +1,48 @@
+package config
+
+import (
+	"time"
+
+	"github.com/migadu/sora/helpers"
+)
+
+// TakeoutConfig configures self-service account exports (takeouts) in the
+// User API. Archives are built by the job worker ([jobs]), so at least one
+// instance must run it.
+type TakeoutConfig struct {
+	// Let users export their account through the User API (default: false)
+	Enabled bool `toml:"enabled"`
+
+	// Minimum time between two successful takeouts of an account (default: "24h")
+	MinInterval string `toml:"min_interval"`
+
+	// How long a download link is valid (default: "15m")
+	LinkTTL string `toml:"link_ttl"`
+
+	// How long archives can be downloaded before they are deleted (default: "7d")
+	Retention string `toml:"retention"`
+}
+
+// GetMinInterval parses the minimum time between takeouts
+func (t *TakeoutConfig) GetMinInterval() (time.Duration, error) {
+	if t.MinInterval == "" {
+		return 24 * time.Hour, nil
+	}
+	return helpers.ParseDuration(t.MinInterval)
+}
+
+// GetLinkTTL parses the download link lifetime
+func (t *TakeoutConfig) GetLinkTTL() (time.Duration, error) {
+	if t.LinkTTL == "" {
+		return 15 * time.Minute, nil
+	}
+	return helpers.ParseDuration(t.LinkTTL)
+}
+
+// GetRetention parses how long archives are kept
+func (t *TakeoutConfig) GetRetention() (time.Duration, error) {
+	if t.Retention == "" {
+		return 7 * 24 * time.Hour, nil
+	}
+	return helpers.ParseDuration(t.Retention)
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/migadu/sora/helpers"
+)
+
+// WebhooksConfig defines outbound webhook delivery of mailbox and account
+// events. Events are queued on disk and delivered to every endpoint whose
+// event filter matches, with retries and a circuit breaker per endpoint.
+type WebhooksConfig struct {
+	// Enable webhook delivery
+	Enabled bool `toml:"enabled"`
+
+	// Base path for the delivery queue (default: "/var/spool/sora/webhooks")
+	QueuePath string `toml:"queue_path"`
+
+	// How often the worker processes the queue (default: "10s")
+	WorkerInterval string `toml:"worker_interval"`
+
+	// Number of deliveries processed per worker cycle (default: 100)
+	BatchSize int `toml:"batch_size"`
+
+	// Number of concurrent deliveries (default: 5)
+	Concurrency int `toml:"concurrency"`
+
+	// Maximum delivery attempts before an event is moved to failed (default: 10)
+	MaxAttempts int `toml:"max_attempts"`
+
+	// Backoff durations between retries (default: ["30s", "2m", "10m", "30m", "1h", "6h"])
+	RetryBackoff []string `toml:"retry_backoff"`
+
+	// Timeout for each HTTP request (default: "10s")
+	Timeout string `toml:"timeout"`
+
+	// How long failed deliveries are kept before cleanup (default: "168h"; "0" keeps them forever)
+	FailedRetention string `toml:"failed_retention"`
+
+	// Circuit breaker applied to each endpoint separately
+	CircuitBreaker SpamTrainingCircuitBreakerConfig `toml:"circuit_breaker"`
+
+	// Endpoints receiving events ([[webhooks.endpoint]] in TOML)
+	Endpoints []WebhookEndpointConfig `toml:"endpoint"`
+}
+
+// WebhookEndpointConfig is a single webhook receiver.
+type WebhookEndpointConfig struct {
+	// Unique name, used in logs, metrics and the queue
+	Name string `toml:"name"`
+
+	// URL receiving POST requests with the JSON event
+	URL string `toml:"url"`
+
+	// Shared secret for the X-Sora-Signature HMAC-SHA256 header
+	Secret string `toml:"secret"`
+
+	// Event types to deliver, e.g. ["message.delivered", "account.*"].
+	// Empty delivers every event.
+	Events []string `toml:"events"`
+}
+
+// IsConfigured returns true if webhooks are enabled and have an endpoint
+func (w *WebhooksConfig) IsConfigured() bool {
+	return w.Enabled && len(w.Endpoints) > 0
+}
+
+// Validate checks the endpoint definitions
+func (w *WebhooksConfig) Validate() error {
+	seen := make(map[string]bool)
+	for i, ep := range w.Endpoints {
+		if ep.Name == "" {
+			return fmt.Errorf("webhooks.endpoint[%d]: name is required", i)
+		}
+		if seen[ep.Name] {
+			return fmt.Errorf("webhooks.endpoint[%d]: duplicate name %q", i, ep.Name)
+		}
+		seen[ep.Name] = true
+		u, err := url.Parse(ep.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhooks.endpoint %q: invalid url %q", ep.Name, ep.URL)
+		}
+	}
+	return nil
+}
+
+// GetQueuePath returns the queue path with default if not set
+func (w *WebhooksConfig) GetQueuePath() string {
+	if w.QueuePath != "" {
+		return w.QueuePath
+	}
+	return "/var/spool/sora/webhooks"
+}
+
+// GetWorkerInterval parses the worker interval duration
+func (w *WebhooksConfig) GetWorkerInterval() (time.Duration, error) {
+	if w.WorkerInterval == "" {
+		return 10 * time.Second, nil
+	}
+	return helpers.ParseDuration(w.WorkerInterval)
+}
+
+// GetRetryBackoff parses the retry backoff durations
+func (w *WebhooksConfig) GetRetryBackoff() ([]time.Duration, error) {
+	if len(w.RetryBackoff) == 0 {
+		return []time.Duration{
+			30 * time.Second,
+			2 * time.Minute,
+			10 * time.Minute,
+			30 * time.Minute,
+			1 * time.Hour,
+			6 * time.Hour,
+		}, nil
+	}
+	backoff := make([]time.Duration, 0, len(w.RetryBackoff))
+	for _, b := range w.RetryBackoff {
+		d, err := helpers.ParseDuration(b)
+		if err != nil {
+			return nil, err
+		}
+		backoff = append(backoff, d)
+	}
+	return backoff, nil
+}
+
+// GetTimeout parses the HTTP request timeout
+func (w *WebhooksConfig) GetTimeout() (time.Duration, error) {
+	if w.Timeout == "" {
+		return 10 * time.Second, nil
+	}
+	return helpers.ParseDuration(w.Timeout)
+}
+
+// GetFailedRetention returns how long failed deliveries are kept
+func (w *WebhooksConfig) GetFailedRetention() (time.Duration, error) {
+	if w.FailedRetention == "" {
+		return 168 * time.Hour, nil
+	}
+	return helpers.ParseDuration(w.FailedRetention)
+}
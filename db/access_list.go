@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/server"
+)
+
+const accessListColumns = `id, action, COALESCE(cidr::text, ''), username, reason, created_by, created_at, expires_at`
+
+func scanAccessListEntry(row pgx.Row) (*server.AccessListEntry, error) {
+	var e server.AccessListEntry
+	if err := row.Scan(&e.ID, &e.Action, &e.CIDR, &e.Username, &e.Reason, &e.CreatedBy, &e.CreatedAt, &e.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// AddAccessListEntry stores an entry and returns it with its ID and creation time
+// set. An existing entry with the same action, network and username is replaced,
+// so adding a rule again updates its reason and expiry. The IDs of replaced
+// entries are returned.
+func (db *Database) AddAccessListEntry(ctx context.Context, tx pgx.Tx, entry *server.AccessListEntry) (*server.AccessListEntry, []int64, error) {
+	e := *entry
+	if err := e.Normalize(); err != nil {
+		return nil, nil, err
+	}
+
+	replaced, err := deleteAccessListEntries(ctx, tx, e.Action, e.CIDR, e.Username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO access_list (action, cidr, username, reason, created_by, expires_at)
+		VALUES ($1, NULLIF($2, '')::cidr, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, e.Action, e.CIDR, e.Username, e.Reason, e.CreatedBy, e.ExpiresAt).Scan(&e.ID, &e.CreatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to add access list entry: %w", err)
+	}
+	return &e, replaced, nil
+}
+
+// ListAccessListEntries returns access list entries ordered by ID. Expired
+// entries are included only when includeExpired is set.
+func (db *Database) ListAccessListEntries(ctx context.Context, includeExpired bool) ([]*server.AccessListEntry, error) {
+	query := `SELECT ` + accessListColumns + ` FROM access_list`
+	if !includeExpired {
+		query += ` WHERE expires_at IS NULL OR expires_at > now()`
+	}
+	query += ` ORDER BY id`
+
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access list entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*server.AccessListEntry{}
+	for rows.Next() {
+		entry, err := scanAccessListEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan access list entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteAccessListEntry removes the entry with the given ID and returns it, or
+// consts.ErrDBNotFound.
+func (db *Database) DeleteAccessListEntry(ctx context.Context, tx pgx.Tx, id int64) (*server.AccessListEntry, error) {
+	entry, err := scanAccessListEntry(tx.QueryRow(ctx, `
+		DELETE FROM access_list WHERE id = $1
+		RETURNING `+accessListColumns, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, consts.ErrDBNotFound
+		}
+		return nil, fmt.Errorf("failed to delete access list entry: %w", err)
+	}
+	return entry, nil
+}
+
+// DeleteAccessListBlocks removes the block entries for exactly the given
+// network and username (either may be empty) and returns their IDs.
+func (db *Database) DeleteAccessListBlocks(ctx context.Context, tx pgx.Tx, cidr, username string) ([]int64, error) {
+	e := server.AccessListEntry{Action: server.AccessListBlock, CIDR: cidr, Username: username}
+	if err := e.Normalize(); err != nil {
+		return nil, err
+	}
+	return deleteAccessListEntries(ctx, tx, e.Action, e.CIDR, e.Username)
+}
+
+// deleteAccessListEntries removes the entries matching an already normalized
+// action, network and username.
+func deleteAccessListEntries(ctx context.Context, tx pgx.Tx, action, cidr, username string) ([]int64, error) {
+	rows, err := tx.Query(ctx, `
+		DELETE FROM access_list
+		WHERE action = $1
+		  AND cidr IS NOT DISTINCT FROM NULLIF($2, '')::cidr
+		  AND username = $3
+		RETURNING id
+	`, action, cidr, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete access list entries: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete access list entries: %w", err)
+	}
+	return ids, nil
+}
@@ -99,6 +99,12 @@ func (db *Database) CreateAccount(ctx context.Context, tx pgx.Tx, req CreateAcco
 		return 0, fmt.Errorf("failed to create credential: %w", err)
 	}
 
+	if err := db.recordAudit(ctx, tx, AuditAccountCreate, normalizedEmail, map[string]any{
+		"account_id": accountID,
+	}); err != nil {
+		return 0, err
+	}
+
 	return accountID, nil
 }
 
@@ -182,7 +188,10 @@ func (db *Database) AddCredential(ctx context.Context, tx pgx.Tx, req AddCredent
 		return fmt.Errorf("failed to create new credential: %w", err)
 	}
 
-	return nil
+	return db.recordAudit(ctx, tx, AuditCredentialAdd, normalizedNewEmail, map[string]any{
+		"account_id": req.AccountID,
+		"primary":    req.IsPrimary,
+	})
 }
 
 // UpdateAccountRequest represents the parameters for updating an account
@@ -289,7 +298,18 @@ func (db *Database) UpdateAccount(ctx context.Context, tx pgx.Tx, req UpdateAcco
 		}
 	}
 
-	return nil
+	// Record what changed, never the password or hash itself.
+	changed := []string{}
+	if updatePassword {
+		changed = append(changed, "password")
+	}
+	if req.MakePrimary {
+		changed = append(changed, "primary")
+	}
+	return db.recordAudit(ctx, tx, AuditAccountUpdate, normalizedEmail, map[string]any{
+		"account_id": accountID,
+		"changed":    changed,
+	})
 }
 
 // Credential represents a credential with its details
@@ -469,7 +489,7 @@ func (db *Database) DeleteCredential(ctx context.Context, tx pgx.Tx, email strin
 		return fmt.Errorf("failed to delete credential: %w", err)
 	}
 
-	return nil
+	return db.recordAudit(ctx, tx, AuditCredentialDelete, normalizedEmail, nil)
 }
 
 // AccountExistsResult contains the result of checking if an account exists
@@ -579,7 +599,11 @@ func (db *Database) DeleteAccount(ctx context.Context, tx pgx.Tx, email string)
 	// Active connections are tracked in-memory via gossip/local tracking.
 	// To disconnect users, use ConnectionTracker.KickUser() instead.
 
-	return nil
+	return db.recordAudit(ctx, tx, AuditAccountDelete, normalizedEmail, map[string]any{
+		"account_id": accountID,
+		"before":     "active",
+		"after":      "deleted",
+	})
 }
 
 // RestoreAccount restores a soft-deleted account
@@ -627,14 +651,18 @@ func (db *Database) RestoreAccount(ctx context.Context, tx pgx.Tx, email string)
 		return fmt.Errorf("%w or not deleted", ErrAccountNotFound)
 	}
 
-	return nil
+	return db.recordAudit(ctx, tx, AuditAccountRestore, normalizedEmail, map[string]any{
+		"account_id": accountID,
+		"before":     "deleted",
+		"after":      "active",
+	})
 }
 
 // getAccountIDByAddressInTx retrieves the main user ID associated with a given identity (address)
 // within a transaction.
 func (db *Database) getAccountIDByAddressInTx(ctx context.Context, tx pgx.Tx, address string) (int64, error) {
 	var accountID int64
-	normalizedAddress := strings.ToLower(strings.TrimSpace(address))
+	normalizedAddress := normalizeAddress(address)
 
 	if normalizedAddress == "" {
 		return 0, errors.New("address cannot be empty")
@@ -1126,5 +1154,22 @@ func (db *Database) CreateAccountWithCredentials(ctx context.Context, tx pgx.Tx,
 		}
 	}
 
+	if err := db.recordAudit(ctx, tx, AuditAccountCreate, normalizedEmails[primaryIndex(req.Credentials)], map[string]any{
+		"account_id":  accountID,
+		"credentials": normalizedEmails,
+	}); err != nil {
+		return 0, err
+	}
+
 	return accountID, nil
 }
+
+// primaryIndex returns the index of the primary credential, or 0.
+func primaryIndex(creds []CredentialSpec) int {
+	for i, cred := range creds {
+		if cred.IsPrimary {
+			return i
+		}
+	}
+	return 0
+}
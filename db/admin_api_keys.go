@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/migadu/sora/consts"
+)
+
+// AdminAPIKey is a named Admin API key. Only the SHA-256 of the key is stored.
+type AdminAPIKey struct {
+	ID         int64
+	Name       string
+	KeyHash    string // hex SHA-256 of the key
+	KeyPrefix  string // first characters of the key, for identification
+	Scopes     []string
+	Domains    []string // empty = all domains
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+// ErrAdminAPIKeyExists is returned when an active key with the same name exists.
+var ErrAdminAPIKeyExists = errors.New("an active API key with this name already exists")
+
+const adminAPIKeyColumns = `id, name, key_hash, key_prefix, scopes, domains, created_at, expires_at, last_used_at, revoked_at`
+
+func scanAdminAPIKey(row pgx.Row) (*AdminAPIKey, error) {
+	var k AdminAPIKey
+	if err := row.Scan(&k.ID, &k.Name, &k.KeyHash, &k.KeyPrefix, &k.Scopes, &k.Domains,
+		&k.CreatedAt, &k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// CreateAdminAPIKey stores a new key and returns its ID.
+func (db *Database) CreateAdminAPIKey(ctx context.Context, tx pgx.Tx, key *AdminAPIKey) (int64, error) {
+	if key.Name == "" || key.KeyHash == "" {
+		return 0, fmt.Errorf("name and key hash are required")
+	}
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	domains := key.Domains
+	if domains == nil {
+		domains = []string{}
+	}
+
+	var id int64
+	err := tx.QueryRow(ctx, `
+		INSERT INTO admin_api_keys (name, key_hash, key_prefix, scopes, domains, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, key.Name, key.KeyHash, key.KeyPrefix, scopes, domains, key.ExpiresAt).Scan(&id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return 0, ErrAdminAPIKeyExists
+		}
+		return 0, fmt.Errorf("failed to create API key: %w", err)
+	}
+	return id, nil
+}
+
+// GetAdminAPIKeyByHash returns the active, unexpired key with the given hash,
+// or consts.ErrDBNotFound.
+func (db *Database) GetAdminAPIKeyByHash(ctx context.Context, keyHash string) (*AdminAPIKey, error) {
+	key, err := scanAdminAPIKey(db.GetReadPoolWithContext(ctx).QueryRow(ctx, `
+		SELECT `+adminAPIKeyColumns+`
+		FROM admin_api_keys
+		WHERE key_hash = $1
+		  AND revoked_at IS NULL
+		  AND (expires_at IS NULL OR expires_at > now())
+	`, keyHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, consts.ErrDBNotFound
+		}
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+	return key, nil
+}
+
+// ListAdminAPIKeys returns all keys ordered by name. Revoked keys are included
+// only when includeRevoked is set.
+func (db *Database) ListAdminAPIKeys(ctx context.Context, includeRevoked bool) ([]*AdminAPIKey, error) {
+	query := `SELECT ` + adminAPIKeyColumns + ` FROM admin_api_keys`
+	if !includeRevoked {
+		query += ` WHERE revoked_at IS NULL`
+	}
+	query += ` ORDER BY name, id`
+
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*AdminAPIKey{}
+	for rows.Next() {
+		key, err := scanAdminAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAdminAPIKey revokes the active key with the given name.
+func (db *Database) RevokeAdminAPIKey(ctx context.Context, tx pgx.Tx, name string) error {
+	tag, err := tx.Exec(ctx, `
+		UPDATE admin_api_keys SET revoked_at = now()
+		WHERE name = $1 AND revoked_at IS NULL
+	`, name)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return consts.ErrDBNotFound
+	}
+	return nil
+}
+
+// TouchAdminAPIKey records that a key was used.
+func (db *Database) TouchAdminAPIKey(ctx context.Context, tx pgx.Tx, id int64) error {
+	_, err := tx.Exec(ctx, `UPDATE admin_api_keys SET last_used_at = now() WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,368 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/migadu/sora/consts"
+)
+
+// Admin job statuses.
+const (
+	AdminJobQueued    = "queued"
+	AdminJobRunning   = "running"
+	AdminJobSucceeded = "succeeded"
+	AdminJobFailed    = "failed"
+	AdminJobCancelled = "cancelled"
+)
+
+// ErrAdminJobState is returned when a job cannot be cancelled or retried in
+// its current status, or when a worker no longer owns the job it reports on.
+var ErrAdminJobState = errors.New("job is not in a state that allows this operation")
+
+// AdminJob is one row of admin_jobs. Percent and ETASeconds are derived from
+// the progress counters when the row is read.
+type AdminJob struct {
+	ID              int64          `json:"id"`
+	Type            string         `json:"type"`
+	Params          map[string]any `json:"params"`
+	Status          string         `json:"status"`
+	CreatedBy       string         `json:"created_by,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	RunAfter        time.Time      `json:"run_after"`
+	StartedAt       *time.Time     `json:"started_at,omitempty"`
+	HeartbeatAt     *time.Time     `json:"heartbeat_at,omitempty"`
+	FinishedAt      *time.Time     `json:"finished_at,omitempty"`
+	WorkerID        string         `json:"worker_id,omitempty"`
+	Attempts        int            `json:"attempts"`
+	MaxAttempts     int            `json:"max_attempts"`
+	ProgressDone    int64          `json:"progress_done"`
+	ProgressTotal   int64          `json:"progress_total"`
+	ProgressMessage string         `json:"progress_message,omitempty"`
+	Percent         float64        `json:"percent"`
+	ETASeconds      *int64         `json:"eta_seconds,omitempty"`
+	CancelRequested bool           `json:"cancel_requested"`
+	LastError       string         `json:"last_error,omitempty"`
+	Result          map[string]any `json:"result,omitempty"`
+}
+
+// IsFinished reports whether the job has reached a final status.
+func (j *AdminJob) IsFinished() bool {
+	return j.Status == AdminJobSucceeded || j.Status == AdminJobFailed || j.Status == AdminJobCancelled
+}
+
+// computeProgress fills Percent and, for a running job with a known total,
+// ETASeconds extrapolated from the rate so far.
+func (j *AdminJob) computeProgress(now time.Time) {
+	j.Percent = 0
+	j.ETASeconds = nil
+	if j.Status == AdminJobSucceeded {
+		j.Percent = 100
+		return
+	}
+	if j.ProgressTotal <= 0 {
+		return
+	}
+	done := min(j.ProgressDone, j.ProgressTotal)
+	j.Percent = float64(int(float64(done)*1000/float64(j.ProgressTotal))) / 10
+	if j.Status != AdminJobRunning || j.StartedAt == nil || done == 0 {
+		return
+	}
+	elapsed := now.Sub(*j.StartedAt)
+	remaining := time.Duration(float64(elapsed) * float64(j.ProgressTotal-done) / float64(done))
+	eta := int64(remaining.Round(time.Second).Seconds())
+	j.ETASeconds = &eta
+}
+
+const adminJobColumns = `id, job_type, params, status, created_by, created_at, run_after, started_at,
+	heartbeat_at, finished_at, worker_id, attempts, max_attempts, progress_done, progress_total,
+	progress_message, cancel_requested, last_error, result`
+
+func scanAdminJob(row pgx.Row) (*AdminJob, error) {
+	var j AdminJob
+	var params, result []byte
+	if err := row.Scan(&j.ID, &j.Type, &params, &j.Status, &j.CreatedBy, &j.CreatedAt, &j.RunAfter, &j.StartedAt,
+		&j.HeartbeatAt, &j.FinishedAt, &j.WorkerID, &j.Attempts, &j.MaxAttempts, &j.ProgressDone, &j.ProgressTotal,
+		&j.ProgressMessage, &j.CancelRequested, &j.LastError, &result); err != nil {
+		return nil, err
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &j.Params); err != nil {
+			return nil, fmt.Errorf("invalid params for job %d: %w", j.ID, err)
+		}
+	}
+	if len(result) > 0 {
+		if err := json.Unmarshal(result, &j.Result); err != nil {
+			return nil, fmt.Errorf("invalid result for job %d: %w", j.ID, err)
+		}
+	}
+	j.computeProgress(time.Now())
+	return &j, nil
+}
+
+// InsertAdminJob queues a job, filling in its ID, status and times.
+func (db *Database) InsertAdminJob(ctx context.Context, tx pgx.Tx, job *AdminJob) error {
+	if job.Type == "" {
+		return fmt.Errorf("job type is required")
+	}
+	params := job.Params
+	if params == nil {
+		params = map[string]any{}
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode job params: %w", err)
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = 3
+	}
+
+	row := tx.QueryRow(ctx, `
+		INSERT INTO admin_jobs (job_type, params, created_by, max_attempts)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+adminJobColumns,
+		job.Type, raw, job.CreatedBy, job.MaxAttempts)
+	inserted, err := scanAdminJob(row)
+	if err != nil {
+		return fmt.Errorf("failed to insert job: %w", err)
+	}
+	*job = *inserted
+	return nil
+}
+
+// ClaimAdminJob marks the oldest runnable job of one of the given types as
+// running by workerID and returns it, or nil if there is none. A job is
+// runnable when it is queued and due, or running with a heartbeat older than
+// staleAfter (its worker died). Concurrent workers never claim the same job.
+func (db *Database) ClaimAdminJob(ctx context.Context, tx pgx.Tx, workerID string, types []string, staleAfter time.Duration) (*AdminJob, error) {
+	row := tx.QueryRow(ctx, `
+		UPDATE admin_jobs SET
+			status = 'running',
+			worker_id = $1,
+			attempts = attempts + 1,
+			started_at = now(),
+			heartbeat_at = now(),
+			last_error = CASE WHEN status = 'running'
+				THEN 'worker ' || worker_id || ' stopped responding'
+				ELSE last_error END
+		WHERE id = (
+			SELECT id FROM admin_jobs
+			WHERE job_type = ANY($2)
+			  AND ((status = 'queued' AND run_after <= now() AND NOT cancel_requested)
+			    OR (status = 'running' AND heartbeat_at < now() - $3::interval))
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+adminJobColumns,
+		workerID, types, staleAfter)
+	job, err := scanAdminJob(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+	return job, nil
+}
+
+// UpdateAdminJobProgress records progress and refreshes the heartbeat of a
+// job owned by workerID. It returns whether cancellation was requested, or
+// ErrAdminJobState if the job is no longer running on this worker.
+func (db *Database) UpdateAdminJobProgress(ctx context.Context, tx pgx.Tx, id int64, workerID string, done, total int64, message string) (bool, error) {
+	var cancelRequested bool
+	err := tx.QueryRow(ctx, `
+		UPDATE admin_jobs
+		SET progress_done = $3, progress_total = $4, progress_message = $5, heartbeat_at = now()
+		WHERE id = $1 AND worker_id = $2 AND status = 'running'
+		RETURNING cancel_requested
+	`, id, workerID, done, total, message).Scan(&cancelRequested)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrAdminJobState
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update job progress: %w", err)
+	}
+	return cancelRequested, nil
+}
+
+// FinishAdminJob sets the final status of a job owned by workerID.
+func (db *Database) FinishAdminJob(ctx context.Context, tx pgx.Tx, id int64, workerID, status, lastError string, result map[string]any) error {
+	var raw []byte
+	if result != nil {
+		var err error
+		if raw, err = json.Marshal(result); err != nil {
+			return fmt.Errorf("failed to encode job result: %w", err)
+		}
+	}
+	tag, err := tx.Exec(ctx, `
+		UPDATE admin_jobs
+		SET status = $3, last_error = $4, result = $5, finished_at = now(), heartbeat_at = now(),
+			progress_done = CASE WHEN $3 = 'succeeded' AND progress_total > 0 THEN progress_total ELSE progress_done END
+		WHERE id = $1 AND worker_id = $2 AND status = 'running'
+	`, id, workerID, status, lastError, raw)
+	if err != nil {
+		return fmt.Errorf("failed to finish job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAdminJobState
+	}
+	return nil
+}
+
+// RequeueAdminJob puts a job owned by workerID back in the queue after a
+// failed attempt, to run again no earlier than delay from now.
+func (db *Database) RequeueAdminJob(ctx context.Context, tx pgx.Tx, id int64, workerID, lastError string, delay time.Duration) error {
+	tag, err := tx.Exec(ctx, `
+		UPDATE admin_jobs
+		SET status = 'queued', last_error = $3, run_after = now() + $4::interval, worker_id = ''
+		WHERE id = $1 AND worker_id = $2 AND status = 'running'
+	`, id, workerID, lastError, delay)
+	if err != nil {
+		return fmt.Errorf("failed to requeue job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAdminJobState
+	}
+	return nil
+}
+
+// CancelAdminJob cancels a queued job immediately, or asks the worker running
+// it to stop. It returns ErrAdminJobState if the job has already finished.
+func (db *Database) CancelAdminJob(ctx context.Context, tx pgx.Tx, id int64) (*AdminJob, error) {
+	row := tx.QueryRow(ctx, `
+		UPDATE admin_jobs SET
+			cancel_requested = TRUE,
+			status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+			finished_at = CASE WHEN status = 'queued' THEN now() ELSE finished_at END
+		WHERE id = $1 AND status IN ('queued', 'running')
+		RETURNING `+adminJobColumns, id)
+	job, err := scanAdminJob(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, db.adminJobStateError(ctx, tx, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+	return job, nil
+}
+
+// RetryAdminJob queues a failed or cancelled job again with its attempts and
+// progress reset.
+func (db *Database) RetryAdminJob(ctx context.Context, tx pgx.Tx, id int64) (*AdminJob, error) {
+	row := tx.QueryRow(ctx, `
+		UPDATE admin_jobs SET
+			status = 'queued', run_after = now(), attempts = 0, cancel_requested = FALSE,
+			worker_id = '', last_error = '', result = NULL, started_at = NULL,
+			heartbeat_at = NULL, finished_at = NULL,
+			progress_done = 0, progress_total = 0, progress_message = ''
+		WHERE id = $1 AND status IN ('failed', 'cancelled')
+		RETURNING `+adminJobColumns, id)
+	job, err := scanAdminJob(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, db.adminJobStateError(ctx, tx, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry job: %w", err)
+	}
+	return job, nil
+}
+
+// adminJobStateError tells a missing job (ErrDBNotFound) apart from one in
+// the wrong status (ErrAdminJobState).
+func (db *Database) adminJobStateError(ctx context.Context, tx pgx.Tx, id int64) error {
+	var exists bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM admin_jobs WHERE id = $1)", id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to look up job: %w", err)
+	}
+	if !exists {
+		return consts.ErrDBNotFound
+	}
+	return ErrAdminJobState
+}
+
+// GetAdminJob returns a job by ID, or consts.ErrDBNotFound.
+func (db *Database) GetAdminJob(ctx context.Context, id int64) (*AdminJob, error) {
+	row := db.GetReadPoolWithContext(ctx).QueryRow(ctx, "SELECT "+adminJobColumns+" FROM admin_jobs WHERE id = $1", id)
+	job, err := scanAdminJob(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, consts.ErrDBNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return job, nil
+}
+
+// AdminJobFilter selects jobs. Zero fields do not filter.
+type AdminJobFilter struct {
+	Status string
+	Type   string
+	Email  string // Jobs acting on this account (params email)
+	Limit  int    // default 100, max 1000
+}
+
+// ListAdminJobs returns matching jobs, newest first.
+func (db *Database) ListAdminJobs(ctx context.Context, filter AdminJobFilter) ([]*AdminJob, error) {
+	var conds []string
+	var args []any
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conds = append(conds, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conds = append(conds, fmt.Sprintf("job_type = $%d", len(args)))
+	}
+	if filter.Email != "" {
+		args = append(args, filter.Email)
+		conds = append(conds, fmt.Sprintf("params->>'email' = $%d", len(args)))
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	query := "SELECT " + adminJobColumns + " FROM admin_jobs"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []*AdminJob{}
+	for rows.Next() {
+		job, err := scanAdminJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// CleanupOldAdminJobs deletes finished jobs older than retention.
+func (db *Database) CleanupOldAdminJobs(ctx context.Context, tx pgx.Tx, retention time.Duration) (int64, error) {
+	tag, err := tx.Exec(ctx, `
+		DELETE FROM admin_jobs
+		WHERE status IN ('succeeded', 'failed', 'cancelled') AND finished_at < now() - $1::interval
+	`, retention)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clean up old jobs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminJobComputeProgress(t *testing.T) {
+	now := time.Now()
+	started := now.Add(-time.Minute)
+
+	job := &AdminJob{Status: AdminJobRunning, StartedAt: &started, ProgressDone: 25, ProgressTotal: 100}
+	job.computeProgress(now)
+	assert.Equal(t, 25.0, job.Percent)
+	require.NotNil(t, job.ETASeconds)
+	assert.Equal(t, int64(180), *job.ETASeconds) // 1m for 25%, 3m for the rest
+
+	// No total: no percentage or ETA.
+	job = &AdminJob{Status: AdminJobRunning, StartedAt: &started, ProgressDone: 5}
+	job.computeProgress(now)
+	assert.Zero(t, job.Percent)
+	assert.Nil(t, job.ETASeconds)
+
+	// Queued jobs have no ETA; finished jobs report 100%.
+	job = &AdminJob{Status: AdminJobQueued, ProgressDone: 1, ProgressTotal: 3}
+	job.computeProgress(now)
+	assert.Equal(t, 33.3, job.Percent)
+	assert.Nil(t, job.ETASeconds)
+
+	job = &AdminJob{Status: AdminJobSucceeded}
+	job.computeProgress(now)
+	assert.Equal(t, 100.0, job.Percent)
+}
@@ -0,0 +1,363 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// AUDIT_LOG_ADVISORY_LOCK_ID serializes audit log inserts so each row chains
+// to the one committed before it.
+const AUDIT_LOG_ADVISORY_LOCK_ID = 1876543211
+
+// auditGenesisHash is the prev_hash of the first row in the log.
+const auditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Audit actions. The account and message actions are written by the db
+// package itself (see WithAuditActor); the others are recorded by callers
+// with InsertAuditEntry.
+const (
+	AuditAccountCreate    = "account.create"
+	AuditAccountUpdate    = "account.update"
+	AuditAccountDelete    = "account.delete"
+	AuditAccountRestore   = "account.restore"
+	AuditCredentialAdd    = "credential.add"
+	AuditCredentialDelete = "credential.delete"
+	AuditMessagesRestore  = "messages.restore"
+	AuditACLGrant         = "acl.grant"
+	AuditACLRevoke        = "acl.revoke"
+	AuditConnectionKick   = "connection.kick"
+	AuditCachePurge       = "cache.purge"
+	AuditAffinitySet      = "affinity.set"
+	AuditAffinityDelete   = "affinity.delete"
+	AuditBackendDrain     = "backend.drain"
+	AuditBackendUndrain   = "backend.undrain"
+	AuditAccessListAdd    = "access_list.add"
+	AuditAccessListRemove = "access_list.remove"
+	AuditAuthUnblock      = "auth.unblock"
+	AuditMailboxCreate    = "mailbox.create"
+	AuditMailboxDelete    = "mailbox.delete"
+	AuditMailboxRename    = "mailbox.rename"
+	AuditSievePut         = "sieve.put"
+	AuditSieveDelete      = "sieve.delete"
+	AuditSieveRename      = "sieve.rename"
+	AuditSieveActivate    = "sieve.activate"
+	AuditSieveDeactivate  = "sieve.deactivate"
+	AuditVacationPut      = "vacation.put"
+	AuditVacationDelete   = "vacation.delete"
+	AuditDKIMCreate       = "dkim.create"
+	AuditDKIMActivate     = "dkim.activate"
+	AuditDKIMDelete       = "dkim.delete"
+	AuditRoutePut         = "route.put"
+	AuditRouteDelete      = "route.delete"
+	AuditDeliveryPut      = "delivery.put"
+	AuditDeliveryDelete   = "delivery.delete"
+	AuditRelayDelete      = "relay.delete"
+	AuditRelayRequeue     = "relay.requeue"
+	AuditUploadsResolve   = "uploads.resolve"
+	AuditJobSubmit        = "job.submit"
+	AuditJobCancel        = "job.cancel"
+	AuditJobRetry         = "job.retry"
+)
+
+// AuditActor identifies who performed an audited operation.
+type AuditActor struct {
+	Source     string // adminapi, sora-admin, ...
+	Actor      string // API key name or OS user
+	RemoteAddr string
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor returns a context whose account operations (create, update,
+// delete, restore, credential changes) are recorded in the audit log in the
+// same transaction. Operations without an actor are not audited.
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActorFromContext returns the actor set by WithAuditActor.
+func AuditActorFromContext(ctx context.Context) (AuditActor, bool) {
+	actor, ok := ctx.Value(auditActorKey{}).(AuditActor)
+	return actor, ok
+}
+
+// AuditEntry is one row of the audit log.
+type AuditEntry struct {
+	ID         int64          `json:"id"`
+	CreatedAt  time.Time      `json:"created_at"`
+	Source     string         `json:"source"`
+	Actor      string         `json:"actor"`
+	RemoteAddr string         `json:"remote_addr,omitempty"`
+	Action     string         `json:"action"`
+	Target     string         `json:"target,omitempty"`
+	Details    map[string]any `json:"details,omitempty"`
+	PrevHash   string         `json:"prev_hash"`
+	Hash       string         `json:"hash"`
+}
+
+// canonicalAuditDetails returns the JSON that is hashed for details. Decoding
+// with UseNumber and re-encoding gives sorted keys and unchanged numbers, so
+// the result is the same before insert and after a JSONB round trip.
+func canonicalAuditDetails(raw []byte) ([]byte, error) {
+	if len(raw) == 0 {
+		return []byte("{}"), nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// AuditEntryHash computes the chained hash of an entry from its fields and
+// the previous entry's hash.
+func AuditEntryHash(prevHash string, createdAt time.Time, source, actor, remoteAddr, action, target string, canonicalDetails []byte) string {
+	h := sha256.New()
+	for _, field := range []string{
+		prevHash,
+		createdAt.UTC().Format(time.RFC3339Nano),
+		source, actor, remoteAddr, action, target,
+	} {
+		fmt.Fprintf(h, "%d:%s\n", len(field), field)
+	}
+	h.Write(canonicalDetails)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// InsertAuditEntry appends an entry to the audit log, filling in its ID,
+// time and hashes.
+func (db *Database) InsertAuditEntry(ctx context.Context, tx pgx.Tx, entry *AuditEntry) error {
+	if entry.Action == "" {
+		return fmt.Errorf("audit action is required")
+	}
+	details := entry.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+	canonical, err := canonicalAuditDetails(raw)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", AUDIT_LOG_ADVISORY_LOCK_ID); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+
+	prevHash := auditGenesisHash
+	err = tx.QueryRow(ctx, "SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&prevHash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to read audit log head: %w", err)
+	}
+
+	// PostgreSQL stores microseconds; truncate so the hash survives the round trip.
+	entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	entry.PrevHash = prevHash
+	entry.Hash = AuditEntryHash(prevHash, entry.CreatedAt, entry.Source, entry.Actor, entry.RemoteAddr, entry.Action, entry.Target, canonical)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO audit_log (created_at, source, actor, remote_addr, action, target, details, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, entry.CreatedAt, entry.Source, entry.Actor, entry.RemoteAddr, entry.Action, entry.Target, canonical, entry.PrevHash, entry.Hash).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+	return nil
+}
+
+// recordAudit writes an audit entry for an account operation if the context
+// carries an actor.
+func (db *Database) recordAudit(ctx context.Context, tx pgx.Tx, action, target string, details map[string]any) error {
+	actor, ok := AuditActorFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return db.InsertAuditEntry(ctx, tx, &AuditEntry{
+		Source:     actor.Source,
+		Actor:      actor.Actor,
+		RemoteAddr: actor.RemoteAddr,
+		Action:     action,
+		Target:     target,
+		Details:    details,
+	})
+}
+
+// AuditFilter selects audit log entries. Zero fields do not filter.
+type AuditFilter struct {
+	Action  string // exact action, or a prefix ending in "." or ".*" (e.g. "account.*")
+	Actor   string
+	Target  string // exact, case-insensitive; "@domain" matches a whole domain
+	Since   time.Time
+	Until   time.Time
+	AfterID int64 // for paging: only entries with a larger ID
+	Limit   int   // default 100, max 1000
+}
+
+const auditLogColumns = `id, created_at, source, actor, remote_addr, action, target, details, prev_hash, hash`
+
+func scanAuditEntry(row pgx.Row) (*AuditEntry, []byte, error) {
+	var e AuditEntry
+	var details []byte
+	if err := row.Scan(&e.ID, &e.CreatedAt, &e.Source, &e.Actor, &e.RemoteAddr, &e.Action, &e.Target, &details, &e.PrevHash, &e.Hash); err != nil {
+		return nil, nil, err
+	}
+	if len(details) > 0 {
+		if err := json.Unmarshal(details, &e.Details); err != nil {
+			return nil, nil, fmt.Errorf("invalid audit details for entry %d: %w", e.ID, err)
+		}
+	}
+	return &e, details, nil
+}
+
+// ListAuditEntries returns matching entries in ID order.
+func (db *Database) ListAuditEntries(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.Action != "" {
+		if prefix, ok := strings.CutSuffix(filter.Action, "*"); ok || strings.HasSuffix(filter.Action, ".") {
+			if !ok {
+				prefix = filter.Action
+			}
+			add("starts_with(action, $%d)", prefix)
+		} else {
+			add("action = $%d", filter.Action)
+		}
+	}
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if filter.Target != "" {
+		target := strings.ToLower(filter.Target)
+		if strings.HasPrefix(target, "@") {
+			add(`LOWER(target) LIKE $%d ESCAPE '\'`, likeSuffix(target))
+		} else {
+			add("LOWER(target) = $%d", target)
+		}
+	}
+	if !filter.Since.IsZero() {
+		add("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("created_at < $%d", filter.Until)
+	}
+	if filter.AfterID > 0 {
+		add("id > $%d", filter.AfterID)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	query := "SELECT " + auditLogColumns + " FROM audit_log"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args))
+
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*AuditEntry{}
+	for rows.Next() {
+		e, _, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// AuditVerifyResult reports the outcome of VerifyAuditLog.
+type AuditVerifyResult struct {
+	Checked  int64  `json:"checked"`
+	FirstID  int64  `json:"first_id,omitempty"`
+	LastID   int64  `json:"last_id,omitempty"`
+	Valid    bool   `json:"valid"`
+	BrokenID int64  `json:"broken_id,omitempty"` // first entry that does not verify
+	Reason   string `json:"reason,omitempty"`
+}
+
+// VerifyAuditLog recomputes the hash chain over the whole log. The oldest
+// remaining entry is trusted as the start of the chain, since the cleaner
+// prunes old entries.
+func (db *Database) VerifyAuditLog(ctx context.Context) (*AuditVerifyResult, error) {
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, "SELECT "+auditLogColumns+" FROM audit_log ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	result := &AuditVerifyResult{Valid: true}
+	prevHash := ""
+	for rows.Next() {
+		e, details, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if result.FirstID == 0 {
+			result.FirstID = e.ID
+		}
+		result.LastID = e.ID
+		result.Checked++
+		if !result.Valid {
+			continue
+		}
+
+		if prevHash != "" && e.PrevHash != prevHash {
+			result.Valid, result.BrokenID, result.Reason = false, e.ID, "prev_hash does not match the previous entry (entry removed or reordered)"
+			continue
+		}
+		canonical, err := canonicalAuditDetails(details)
+		if err != nil {
+			result.Valid, result.BrokenID, result.Reason = false, e.ID, "details are not valid JSON"
+			continue
+		}
+		if AuditEntryHash(e.PrevHash, e.CreatedAt, e.Source, e.Actor, e.RemoteAddr, e.Action, e.Target, canonical) != e.Hash {
+			result.Valid, result.BrokenID, result.Reason = false, e.ID, "hash does not match the entry contents (entry modified)"
+			continue
+		}
+		prevHash = e.Hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return result, nil
+}
+
+// CleanupOldAuditEntries removes entries older than retention.
+func (db *Database) CleanupOldAuditEntries(ctx context.Context, tx pgx.Tx, retention time.Duration) (int64, error) {
+	result, err := tx.Exec(ctx, `DELETE FROM audit_log WHERE created_at < $1`, time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup old audit entries: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
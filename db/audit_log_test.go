@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCanonicalAuditDetails guards the property the hash chain depends on:
+// details read back from JSONB (reordered keys, different whitespace) must
+// canonicalize to the same bytes that were hashed on insert.
+func TestCanonicalAuditDetails(t *testing.T) {
+	written, err := json.Marshal(map[string]any{"b": 2, "a": "x", "n": int64(1 << 53)})
+	require.NoError(t, err)
+	stored := []byte(`{"n": 9007199254740992, "a": "x",  "b": 2}`)
+
+	c1, err := canonicalAuditDetails(written)
+	require.NoError(t, err)
+	c2, err := canonicalAuditDetails(stored)
+	require.NoError(t, err)
+	assert.Equal(t, string(c1), string(c2))
+
+	empty, err := canonicalAuditDetails(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(empty))
+}
+
+func TestAuditEntryHash(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC)
+	h := AuditEntryHash(auditGenesisHash, ts, "adminapi", "ops", "10.0.0.1", AuditAccountDelete, "user@example.com", []byte("{}"))
+	assert.Len(t, h, 64)
+
+	// Same time in another zone hashes the same.
+	assert.Equal(t, h, AuditEntryHash(auditGenesisHash, ts.In(time.FixedZone("X", 3600)), "adminapi", "ops", "10.0.0.1", AuditAccountDelete, "user@example.com", []byte("{}")))
+
+	// Fields are length-prefixed, so moving a boundary changes the hash.
+	assert.NotEqual(t, h, AuditEntryHash(auditGenesisHash, ts, "adminapi", "op", "s10.0.0.1", AuditAccountDelete, "user@example.com", []byte("{}")))
+	assert.NotEqual(t, h, AuditEntryHash(h, ts, "adminapi", "ops", "10.0.0.1", AuditAccountDelete, "user@example.com", []byte("{}")))
+}
+
+func TestAuditLog_InsertAndVerify(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping database integration test in short mode")
+	}
+
+	db := setupTestDatabase(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	target := "audit-" + time.Now().Format("150405.000000") + "@example.com"
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		tx, err := db.GetWritePool().Begin(ctx)
+		require.NoError(t, err)
+		entry := &AuditEntry{
+			Source:  "test",
+			Actor:   "tester",
+			Action:  AuditAccountUpdate,
+			Target:  target,
+			Details: map[string]any{"step": i, "changed": []string{"password"}},
+		}
+		require.NoError(t, db.InsertAuditEntry(ctx, tx, entry))
+		require.NoError(t, tx.Commit(ctx))
+		ids = append(ids, entry.ID)
+	}
+
+	entries, err := db.ListAuditEntries(ctx, AuditFilter{Target: target})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	result, err := db.VerifyAuditLog(ctx)
+	require.NoError(t, err)
+	require.True(t, result.Valid, "chain should verify: %+v", result)
+
+	// Tamper with the middle entry, then restore it.
+	_, err = db.GetWritePool().Exec(ctx, "UPDATE audit_log SET actor = 'someone-else' WHERE id = $1", ids[1])
+	require.NoError(t, err)
+	defer db.GetWritePool().Exec(ctx, "UPDATE audit_log SET actor = 'tester' WHERE id = $1", ids[1])
+
+	result, err = db.VerifyAuditLog(ctx)
+	require.NoError(t, err)
+	assert.False(t, result.Valid)
+	assert.Equal(t, ids[1], result.BrokenID)
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/migadu/sora/server"
 	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/sync/singleflight"
+	"golang.org/x/text/unicode/norm"
 )
 
 const (
@@ -356,9 +357,16 @@ func NeedsRehash(hash string) bool {
 	return currentCost != defaultCost
 }
 
+// normalizeAddress returns the form in which credential addresses are stored:
+// trimmed, lowercased and, for SMTPUTF8 addresses, in Unicode NFC (as
+// server.NewAddress produces them).
+func normalizeAddress(address string) string {
+	return norm.NFC.String(strings.ToLower(strings.TrimSpace(address)))
+}
+
 // UpdatePassword updates the stored password for a user
 func (db *Database) UpdatePassword(ctx context.Context, tx pgx.Tx, address string, newHashedPassword string) error {
-	normalizedAddress := strings.ToLower(strings.TrimSpace(address))
+	normalizedAddress := normalizeAddress(address)
 	if normalizedAddress == "" {
 		return errors.New("address cannot be empty")
 	}
@@ -391,7 +399,7 @@ func (db *Database) GetCredentialForAuth(ctx context.Context, address string) (a
 		metrics.DBQueriesTotal.WithLabelValues("auth_get_credential", status, "read").Inc()
 	}()
 
-	normalizedAddress := strings.ToLower(strings.TrimSpace(address))
+	normalizedAddress := normalizeAddress(address)
 	if normalizedAddress == "" {
 		return 0, "", errors.New("address cannot be empty")
 	}
@@ -427,7 +435,7 @@ func (db *Database) GetCredentialForAuth(ctx context.Context, address string) (a
 // invalidate live sessions. Returns consts.ErrUserNotFound when the credential is
 // missing or the account is soft-deleted.
 func (db *Database) GetCredentialEpoch(ctx context.Context, address string) (accountID int64, epoch time.Time, err error) {
-	normalizedAddress := strings.ToLower(strings.TrimSpace(address))
+	normalizedAddress := normalizeAddress(address)
 	if normalizedAddress == "" {
 		return 0, time.Time{}, errors.New("address cannot be empty")
 	}
@@ -453,7 +461,7 @@ func (db *Database) GetCredentialEpoch(ctx context.Context, address string) (acc
 // by looking it up in the `credentials` table.
 func (db *Database) GetAccountIDByAddress(ctx context.Context, address string) (int64, error) {
 	var accountID int64
-	normalizedAddress := strings.ToLower(strings.TrimSpace(address))
+	normalizedAddress := normalizeAddress(address)
 
 	if normalizedAddress == "" {
 		return 0, errors.New("address cannot be empty")
@@ -477,7 +485,7 @@ func (db *Database) GetAccountIDByAddress(ctx context.Context, address string) (
 // recipient validation where we need to reject deleted accounts.
 func (db *Database) GetActiveAccountIDByAddress(ctx context.Context, address string) (int64, error) {
 	var accountID int64
-	normalizedAddress := strings.ToLower(strings.TrimSpace(address))
+	normalizedAddress := normalizeAddress(address)
 
 	if normalizedAddress == "" {
 		return 0, errors.New("address cannot be empty")
@@ -0,0 +1,234 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/migadu/sora/server"
+)
+
+const (
+	// deliveryLogPartitionPrefix is followed by the partition's UTC day (YYYYMMDD)
+	deliveryLogPartitionPrefix = "delivery_log_p"
+
+	// deliveryLogPartitionsAhead is how many days of partitions are kept ready
+	deliveryLogPartitionsAhead = 7
+)
+
+// InsertDeliveryLog stores delivery records.
+func (db *Database) InsertDeliveryLog(ctx context.Context, tx pgx.Tx, records []server.DeliveryRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	n := len(records)
+	times := make([]time.Time, n)
+	messageIDs := make([]string, n)
+	senders := make([]string, n)
+	recipients := make([]string, n)
+	accountIDs := make([]int64, n)
+	mailboxes := make([]string, n)
+	actions := make([]string, n) // Joined with ",": unnest cannot take an array of arrays
+	sizes := make([]int64, n)
+	results := make([]string, n)
+	codes := make([]string, n)
+	nodes := make([]string, n)
+	for i, r := range records {
+		times[i] = r.Time
+		messageIDs[i] = r.MessageID
+		senders[i] = normalizeAddress(r.Sender)
+		recipients[i] = normalizeAddress(r.Recipient)
+		accountIDs[i] = r.AccountID
+		mailboxes[i] = r.Mailbox
+		actions[i] = strings.Join(r.SieveActions, ",")
+		sizes[i] = r.Size
+		results[i] = r.Result
+		codes[i] = r.Code
+		nodes[i] = r.Node
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO delivery_log (delivered_at, message_id, sender, recipient, account_id, mailbox, sieve_actions, size, result, code, node)
+		SELECT r.delivered_at, r.message_id, r.sender, r.recipient, NULLIF(r.account_id, 0), r.mailbox,
+			COALESCE(string_to_array(NULLIF(r.actions, ''), ','), '{}'), r.size, r.result, r.code, r.node
+		FROM unnest($1::timestamptz[], $2::text[], $3::text[], $4::text[], $5::bigint[], $6::text[], $7::text[], $8::bigint[], $9::text[], $10::text[], $11::text[])
+			AS r(delivered_at, message_id, sender, recipient, account_id, mailbox, actions, size, result, code, node)
+	`, times, messageIDs, senders, recipients, accountIDs, mailboxes, actions, sizes, results, codes, nodes)
+	if err != nil {
+		return fmt.Errorf("failed to insert delivery log: %w", err)
+	}
+	return nil
+}
+
+// DeliveryLogFilter selects delivery log entries. At least one of the
+// address or message-id filters is normally given; the time range bounds the
+// partitions searched.
+type DeliveryLogFilter struct {
+	Recipient string // exact, case-insensitive; "@domain" matches a whole domain
+	Sender    string // exact, case-insensitive; "@domain" matches a whole domain
+	MessageID string // with or without angle brackets
+	Since     time.Time
+	Until     time.Time
+	Limit     int // default 100, max 1000
+}
+
+// SearchDeliveryLog returns matching entries, newest first.
+func (db *Database) SearchDeliveryLog(ctx context.Context, filter DeliveryLogFilter) ([]server.DeliveryRecord, error) {
+	var conds []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	addAddress := func(column, address string) {
+		address = normalizeAddress(address)
+		if strings.HasPrefix(address, "@") {
+			add(column+` LIKE $%d ESCAPE '\'`, likeSuffix(address))
+		} else {
+			add(column+" = $%d", address)
+		}
+	}
+
+	if filter.Recipient != "" {
+		addAddress("recipient", filter.Recipient)
+	}
+	if filter.Sender != "" {
+		addAddress("sender", filter.Sender)
+	}
+	if filter.MessageID != "" {
+		add("message_id = $%d", strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(filter.MessageID), "<"), ">"))
+	}
+	if !filter.Since.IsZero() {
+		add("delivered_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("delivered_at < $%d", filter.Until)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	query := `SELECT id, delivered_at, message_id, sender, recipient, COALESCE(account_id, 0), mailbox, sieve_actions, size, result, code, node FROM delivery_log`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY delivered_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search delivery log: %w", err)
+	}
+	defer rows.Close()
+
+	records := []server.DeliveryRecord{}
+	for rows.Next() {
+		var r server.DeliveryRecord
+		if err := rows.Scan(&r.ID, &r.Time, &r.MessageID, &r.Sender, &r.Recipient, &r.AccountID, &r.Mailbox, &r.SieveActions, &r.Size, &r.Result, &r.Code, &r.Node); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery log: %w", err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// DeliveryLogMaintenance reports what MaintainDeliveryLog changed.
+type DeliveryLogMaintenance struct {
+	Created int   // Daily partitions created
+	Dropped int   // Expired daily partitions dropped
+	Deleted int64 // Expired rows deleted from the default partition
+}
+
+// MaintainDeliveryLog creates the daily partitions of the coming days and,
+// when retention is set, drops the partitions that ended before it and
+// deletes expired rows from the default partition. A day whose rows already
+// went to the default partition gets no partition of its own, since creating
+// it would fail; its rows are pruned from the default partition instead.
+func (db *Database) MaintainDeliveryLog(ctx context.Context, tx pgx.Tx, retention time.Duration) (DeliveryLogMaintenance, error) {
+	var m DeliveryLogMaintenance
+
+	rows, err := tx.Query(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'delivery_log'::regclass
+	`)
+	if err != nil {
+		return m, fmt.Errorf("failed to list delivery log partitions: %w", err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return m, fmt.Errorf("failed to list delivery log partitions: %w", err)
+	}
+	existing := make(map[string]bool, len(names))
+	for _, name := range names {
+		existing[name] = true
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= deliveryLogPartitionsAhead; i++ {
+		from := today.AddDate(0, 0, i)
+		name := deliveryLogPartitionPrefix + from.Format("20060102")
+		if existing[name] {
+			continue
+		}
+		to := from.AddDate(0, 0, 1)
+		var inDefault bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM delivery_log_default WHERE delivered_at >= $1 AND delivered_at < $2)`, from, to).Scan(&inDefault)
+		if err != nil {
+			return m, fmt.Errorf("failed to check delivery log default partition: %w", err)
+		}
+		if inDefault {
+			continue
+		}
+		_, err = tx.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF delivery_log FOR VALUES FROM ('%s') TO ('%s')`,
+			pgx.Identifier{name}.Sanitize(), from.Format(time.RFC3339), to.Format(time.RFC3339)))
+		if err != nil {
+			return m, fmt.Errorf("failed to create delivery log partition %s: %w", name, err)
+		}
+		m.Created++
+	}
+
+	if retention <= 0 {
+		return m, nil
+	}
+	cutoff := now.Add(-retention)
+	for _, name := range names {
+		day, ok := deliveryLogPartitionDay(name)
+		if !ok || day.AddDate(0, 0, 1).After(cutoff) {
+			continue
+		}
+		if _, err := tx.Exec(ctx, "DROP TABLE IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+			return m, fmt.Errorf("failed to drop delivery log partition %s: %w", name, err)
+		}
+		m.Dropped++
+	}
+	result, err := tx.Exec(ctx, `DELETE FROM delivery_log_default WHERE delivered_at < $1`, cutoff)
+	if err != nil {
+		return m, fmt.Errorf("failed to cleanup old delivery log entries: %w", err)
+	}
+	m.Deleted = result.RowsAffected()
+	return m, nil
+}
+
+// deliveryLogPartitionDay returns the UTC day a daily partition covers.
+func deliveryLogPartitionDay(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, deliveryLogPartitionPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	day, err := time.Parse("20060102", suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return day, true
+}
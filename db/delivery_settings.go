@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/migadu/sora/consts"
+)
+
+// Values of DeliverySettings.SubaddressFolders.
+const (
+	SubaddressFoldersOff      = "off"      // user+detail@domain is delivered to INBOX
+	SubaddressFoldersExisting = "existing" // ... to the mailbox named detail, if it exists
+	SubaddressFoldersCreate   = "create"   // ... to the mailbox named detail, created if missing
+)
+
+// Sources of effective delivery settings.
+const (
+	DeliverySettingsAccount = "account"
+	DeliverySettingsDomain  = "domain"
+	DeliverySettingsDefault = "default"
+)
+
+// DeliverySettings are the delivery options of an account or the defaults of
+// a domain. Source tells where the effective settings of an account come from.
+type DeliverySettings struct {
+	SubaddressFolders string     `json:"subaddress_folders"`
+	Source            string     `json:"source,omitempty"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
+}
+
+// GetDeliverySettings returns the effective delivery settings of an account:
+// its own, else those of domain, else the defaults. domain is the domain mail
+// was addressed to; when empty, that of the account's primary address is used.
+func (db *Database) GetDeliverySettings(ctx context.Context, AccountID int64, domain string) (*DeliverySettings, error) {
+	s := &DeliverySettings{}
+	err := db.GetReadPoolWithContext(ctx).QueryRow(ctx, `
+		SELECT subaddress_folders, source, updated_at FROM (
+			SELECT subaddress_folders, 'account' AS source, updated_at, 1 AS rank
+			FROM account_delivery_settings
+			WHERE account_id = $1
+			UNION ALL
+			SELECT d.subaddress_folders, 'domain', d.updated_at, 2
+			FROM domain_delivery_settings d
+			WHERE d.domain = COALESCE(NULLIF(LOWER($2), ''), (
+				SELECT LOWER(SPLIT_PART(c.address, '@', 2))
+				FROM credentials c
+				WHERE c.account_id = $1 AND c.primary_identity = TRUE
+			))
+		) s
+		ORDER BY rank
+		LIMIT 1
+	`, AccountID, domain).Scan(&s.SubaddressFolders, &s.Source, &s.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &DeliverySettings{SubaddressFolders: SubaddressFoldersOff, Source: DeliverySettingsDefault}, nil
+		}
+		return nil, fmt.Errorf("failed to get delivery settings: %w", err)
+	}
+	return s, nil
+}
+
+// SetAccountDeliverySettings creates or replaces an account's delivery
+// settings and sets s.Source and s.UpdatedAt.
+func (db *Database) SetAccountDeliverySettings(ctx context.Context, tx pgx.Tx, AccountID int64, s *DeliverySettings) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO account_delivery_settings (account_id, subaddress_folders)
+		VALUES ($1, $2)
+		ON CONFLICT (account_id) DO UPDATE
+		SET subaddress_folders = EXCLUDED.subaddress_folders, updated_at = now()
+		RETURNING updated_at
+	`, AccountID, s.SubaddressFolders).Scan(&s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save delivery settings: %w", err)
+	}
+	s.Source = DeliverySettingsAccount
+	return nil
+}
+
+// DeleteAccountDeliverySettings removes an account's delivery settings, so
+// the domain's apply again. It returns consts.ErrDBNotFound if none were saved.
+func (db *Database) DeleteAccountDeliverySettings(ctx context.Context, tx pgx.Tx, AccountID int64) error {
+	tag, err := tx.Exec(ctx, `DELETE FROM account_delivery_settings WHERE account_id = $1`, AccountID)
+	if err != nil {
+		return fmt.Errorf("failed to delete delivery settings: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return consts.ErrDBNotFound
+	}
+	return nil
+}
+
+// GetDomainDeliverySettings returns a domain's default delivery settings, or
+// consts.ErrDBNotFound.
+func (db *Database) GetDomainDeliverySettings(ctx context.Context, domain string) (*DeliverySettings, error) {
+	s := &DeliverySettings{Source: DeliverySettingsDomain}
+	err := db.GetReadPoolWithContext(ctx).QueryRow(ctx, `
+		SELECT subaddress_folders, updated_at
+		FROM domain_delivery_settings
+		WHERE domain = $1
+	`, domain).Scan(&s.SubaddressFolders, &s.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, consts.ErrDBNotFound
+		}
+		return nil, fmt.Errorf("failed to get domain delivery settings: %w", err)
+	}
+	return s, nil
+}
+
+// SetDomainDeliverySettings creates or replaces a domain's default delivery
+// settings and sets s.Source and s.UpdatedAt.
+func (db *Database) SetDomainDeliverySettings(ctx context.Context, tx pgx.Tx, domain string, s *DeliverySettings) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO domain_delivery_settings (domain, subaddress_folders)
+		VALUES ($1, $2)
+		ON CONFLICT (domain) DO UPDATE
+		SET subaddress_folders = EXCLUDED.subaddress_folders, updated_at = now()
+		RETURNING updated_at
+	`, domain, s.SubaddressFolders).Scan(&s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save domain delivery settings: %w", err)
+	}
+	s.Source = DeliverySettingsDomain
+	return nil
+}
+
+// DeleteDomainDeliverySettings removes a domain's default delivery settings.
+// It returns consts.ErrDBNotFound if none were saved.
+func (db *Database) DeleteDomainDeliverySettings(ctx context.Context, tx pgx.Tx, domain string) error {
+	tag, err := tx.Exec(ctx, `DELETE FROM domain_delivery_settings WHERE domain = $1`, domain)
+	if err != nil {
+		return fmt.Errorf("failed to delete domain delivery settings: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return consts.ErrDBNotFound
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/migadu/sora/consts"
+)
+
+// DKIMKey is a domain's DKIM signing key. PublicKey is the DNS TXT record
+// value for <selector>._domainkey.<domain>.
+type DKIMKey struct {
+	ID         int64     `json:"id"`
+	Domain     string    `json:"domain"`
+	Selector   string    `json:"selector"`
+	Algorithm  string    `json:"algorithm"`
+	PrivateKey string    `json:"-"`
+	PublicKey  string    `json:"public_key"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ErrDKIMKeyExists is returned when the domain already has a key with the selector.
+var ErrDKIMKeyExists = errors.New("a DKIM key with this selector already exists for the domain")
+
+const dkimKeyColumns = `id, domain, selector, algorithm, private_key, public_key, active, created_at`
+
+func scanDKIMKey(row pgx.Row) (*DKIMKey, error) {
+	var k DKIMKey
+	if err := row.Scan(&k.ID, &k.Domain, &k.Selector, &k.Algorithm, &k.PrivateKey, &k.PublicKey, &k.Active, &k.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// CreateDKIMKey stores a new key and sets key.ID and key.CreatedAt. An active
+// key replaces the domain's previously active key.
+func (db *Database) CreateDKIMKey(ctx context.Context, tx pgx.Tx, key *DKIMKey) error {
+	if key.Domain == "" || key.Selector == "" || key.PrivateKey == "" {
+		return fmt.Errorf("domain, selector and private key are required")
+	}
+	if key.Active {
+		if _, err := tx.Exec(ctx, `UPDATE dkim_keys SET active = FALSE WHERE domain = $1 AND active`, key.Domain); err != nil {
+			return fmt.Errorf("failed to deactivate DKIM keys: %w", err)
+		}
+	}
+
+	err := tx.QueryRow(ctx, `
+		INSERT INTO dkim_keys (domain, selector, algorithm, private_key, public_key, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, key.Domain, key.Selector, key.Algorithm, key.PrivateKey, key.PublicKey, key.Active).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return ErrDKIMKeyExists
+		}
+		return fmt.Errorf("failed to create DKIM key: %w", err)
+	}
+	return nil
+}
+
+// ListDKIMKeys returns the keys of a domain, or of all domains when domain is
+// empty, ordered by domain and creation time.
+func (db *Database) ListDKIMKeys(ctx context.Context, domain string) ([]*DKIMKey, error) {
+	query := `SELECT ` + dkimKeyColumns + ` FROM dkim_keys`
+	var args []any
+	if domain != "" {
+		query += ` WHERE domain = $1`
+		args = append(args, domain)
+	}
+	query += ` ORDER BY domain, created_at, id`
+
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list DKIM keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*DKIMKey{}
+	for rows.Next() {
+		key, err := scanDKIMKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan DKIM key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GetActiveDKIMKey returns the active key of a domain, or consts.ErrDBNotFound.
+func (db *Database) GetActiveDKIMKey(ctx context.Context, domain string) (*DKIMKey, error) {
+	key, err := scanDKIMKey(db.GetReadPoolWithContext(ctx).QueryRow(ctx, `
+		SELECT `+dkimKeyColumns+`
+		FROM dkim_keys
+		WHERE domain = $1 AND active
+	`, domain))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, consts.ErrDBNotFound
+		}
+		return nil, fmt.Errorf("failed to look up DKIM key: %w", err)
+	}
+	return key, nil
+}
+
+// ActivateDKIMKey makes the key with the selector the domain's signing key.
+func (db *Database) ActivateDKIMKey(ctx context.Context, tx pgx.Tx, domain, selector string) error {
+	if _, err := tx.Exec(ctx, `UPDATE dkim_keys SET active = FALSE WHERE domain = $1 AND active AND selector <> $2`, domain, selector); err != nil {
+		return fmt.Errorf("failed to deactivate DKIM keys: %w", err)
+	}
+	tag, err := tx.Exec(ctx, `UPDATE dkim_keys SET active = TRUE WHERE domain = $1 AND selector = $2`, domain, selector)
+	if err != nil {
+		return fmt.Errorf("failed to activate DKIM key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return consts.ErrDBNotFound
+	}
+	return nil
+}
+
+// DeleteDKIMKey removes the key with the selector from the domain.
+func (db *Database) DeleteDKIMKey(ctx context.Context, tx pgx.Tx, domain, selector string) error {
+	tag, err := tx.Exec(ctx, `DELETE FROM dkim_keys WHERE domain = $1 AND selector = $2`, domain, selector)
+	if err != nil {
+		return fmt.Errorf("failed to delete DKIM key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return consts.ErrDBNotFound
+	}
+	return nil
+}
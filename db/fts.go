@@ -3,6 +3,8 @@ package db
 import (
 	"context"
 	"fmt"
+	"time"
+	"unicode/utf8"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/migadu/sora/helpers"
@@ -106,3 +108,80 @@ func (d *Database) ProcessFTSBatch(ctx context.Context, tx pgx.Tx, limit int) (i
 
 	return len(items), nil
 }
+
+// FTSRebuildCandidate is a live message whose body has no messages_fts row,
+// either because it was pruned by fts_retention or never staged.
+type FTSRebuildCandidate struct {
+	ID          int64
+	ContentHash string
+	S3Domain    string
+	S3Localpart string
+	SentDate    *time.Time
+}
+
+const messagesWithoutFTSCondition = `
+	m.account_id = $1 AND m.expunged_at IS NULL AND m.uploaded = TRUE
+	AND NOT EXISTS (SELECT 1 FROM messages_fts f WHERE f.content_hash = m.content_hash)`
+
+// CountMessagesWithoutFTS counts an account's messages that have no messages_fts row.
+func (d *Database) CountMessagesWithoutFTS(ctx context.Context, accountID int64) (int64, error) {
+	var count int64
+	err := d.GetReadPoolWithContext(ctx).QueryRow(ctx,
+		"SELECT COUNT(DISTINCT m.content_hash) FROM messages m WHERE"+messagesWithoutFTSCondition, accountID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count messages without FTS: %w", err)
+	}
+	return count, nil
+}
+
+// GetMessagesWithoutFTS returns up to limit of an account's messages with ID
+// greater than afterID that have no messages_fts row, in ID order.
+func (d *Database) GetMessagesWithoutFTS(ctx context.Context, accountID, afterID int64, limit int) ([]FTSRebuildCandidate, error) {
+	rows, err := d.GetReadPoolWithContext(ctx).Query(ctx, `
+		SELECT m.id, m.content_hash, m.s3_domain, m.s3_localpart, m.sent_date
+		FROM messages m
+		WHERE`+messagesWithoutFTSCondition+` AND m.id > $2
+		ORDER BY m.id
+		LIMIT $3
+	`, accountID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages without FTS: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []FTSRebuildCandidate
+	for rows.Next() {
+		var c FTSRebuildCandidate
+		if err := rows.Scan(&c.ID, &c.ContentHash, &c.S3Domain, &c.S3Localpart, &c.SentDate); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// StageFTSBody queues a plaintext body for indexing by the FTS worker, as
+// message delivery does. Bodies are truncated to 64 KB.
+func (d *Database) StageFTSBody(ctx context.Context, tx pgx.Tx, contentHash, textBody string, sentDate *time.Time) error {
+	const maxStoredBodySize = 64 * 1024
+	textBody = helpers.SanitizeUTF8(textBody)
+	if len(textBody) > maxStoredBodySize {
+		truncLen := maxStoredBodySize
+		for truncLen > 0 && !utf8.RuneStart(textBody[truncLen]) {
+			truncLen--
+		}
+		textBody = textBody[:truncLen]
+	}
+	if textBody == "" {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO messages_fts (content_hash, text_body, sent_date)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (content_hash) DO NOTHING
+	`, contentHash, textBody, sentDate)
+	if err != nil {
+		return fmt.Errorf("failed to stage FTS body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/migadu/sora/server"
+)
+
+// InsertLoginHistory stores login records and prunes each affected account to
+// its newest maxPerAccount entries. Records without an account ID are matched
+// to an account by username; records that match no existing account are
+// dropped.
+func (db *Database) InsertLoginHistory(ctx context.Context, tx pgx.Tx, records []server.LoginRecord, maxPerAccount int) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	n := len(records)
+	accountIDs := make([]int64, n)
+	usernames := make([]string, n)
+	protocols := make([]string, n)
+	ips := make([]string, n)
+	clientIDs := make([]string, n)
+	ja4s := make([]string, n)
+	successes := make([]bool, n)
+	reasons := make([]string, n)
+	times := make([]time.Time, n)
+	for i, r := range records {
+		accountIDs[i] = r.AccountID
+		usernames[i] = strings.ToLower(strings.TrimSpace(r.Username))
+		protocols[i] = r.Protocol
+		ips[i] = r.IP
+		clientIDs[i] = r.ClientID
+		ja4s[i] = r.JA4
+		successes[i] = r.Success
+		reasons[i] = r.Reason
+		times[i] = r.Time
+	}
+
+	rows, err := tx.Query(ctx, `
+		WITH input AS (
+			SELECT * FROM unnest($1::bigint[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[], $7::boolean[], $8::text[], $9::timestamptz[])
+				AS r(account_id, username, protocol, ip, client_id, ja4, success, reason, created_at)
+		), inserted AS (
+			INSERT INTO login_history (account_id, username, protocol, ip, client_id, ja4, success, reason, created_at)
+			SELECT a.id, r.username, r.protocol, r.ip, r.client_id, r.ja4, r.success, r.reason, r.created_at
+			FROM input r
+			LEFT JOIN credentials c ON r.account_id = 0 AND LOWER(c.address) = r.username
+			JOIN accounts a ON a.id = COALESCE(NULLIF(r.account_id, 0), c.account_id)
+			RETURNING account_id
+		)
+		SELECT DISTINCT account_id FROM inserted
+	`, accountIDs, usernames, protocols, ips, clientIDs, ja4s, successes, reasons, times)
+	if err != nil {
+		return fmt.Errorf("failed to insert login history: %w", err)
+	}
+	affected, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return fmt.Errorf("failed to insert login history: %w", err)
+	}
+	if len(affected) == 0 || maxPerAccount <= 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM login_history h
+		USING (
+			SELECT id, row_number() OVER (PARTITION BY account_id ORDER BY created_at DESC, id DESC) AS rn
+			FROM login_history
+			WHERE account_id = ANY($1)
+		) ranked
+		WHERE h.id = ranked.id AND ranked.rn > $2
+	`, affected, maxPerAccount)
+	if err != nil {
+		return fmt.Errorf("failed to prune login history: %w", err)
+	}
+	return nil
+}
+
+// GetLoginHistory returns an account's most recent login records, newest first.
+func (db *Database) GetLoginHistory(ctx context.Context, accountID int64, limit int) ([]server.LoginRecord, error) {
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, `
+		SELECT account_id, username, protocol, ip, client_id, ja4, success, reason, created_at
+		FROM login_history
+		WHERE account_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, accountID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login history: %w", err)
+	}
+	defer rows.Close()
+
+	records := []server.LoginRecord{}
+	for rows.Next() {
+		var r server.LoginRecord
+		if err := rows.Scan(&r.AccountID, &r.Username, &r.Protocol, &r.IP, &r.ClientID, &r.JA4, &r.Success, &r.Reason, &r.Time); err != nil {
+			return nil, fmt.Errorf("failed to scan login history: %w", err)
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
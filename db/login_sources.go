@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/server"
+)
+
+const loginSourceColumns = `id, kind, value, confirmed, first_ip, first_protocol, first_seen, last_seen`
+
+// scanLoginSource scans loginSourceColumns followed by any extra columns.
+func scanLoginSource(row pgx.Row, extra ...any) (server.LoginSource, error) {
+	var s server.LoginSource
+	dest := append([]any{&s.ID, &s.Kind, &s.Value, &s.Confirmed, &s.FirstIP, &s.FirstProtocol, &s.FirstSeen, &s.LastSeen}, extra...)
+	err := row.Scan(dest...)
+	return s, err
+}
+
+// ObserveLoginSources records the sources of a successful login. Sources the
+// account has not used before are inserted and returned as new; they are
+// stored as confirmed when confirmNew is set or when the account has no
+// sources of that kind yet (the first login establishes the baseline and is
+// not reported). Sources still awaiting confirmation are returned as pending.
+// Each account keeps its maxPerAccount most recently seen sources.
+func (db *Database) ObserveLoginSources(ctx context.Context, tx pgx.Tx, accountID int64, ip, protocol string, sources []server.LoginSource, confirmNew bool, maxPerAccount int) (newSources, pending []server.LoginSource, err error) {
+	if len(sources) == 0 {
+		return nil, nil, nil
+	}
+
+	rows, err := tx.Query(ctx, `SELECT DISTINCT kind FROM login_sources WHERE account_id = $1`, accountID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get login source kinds: %w", err)
+	}
+	knownKinds, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get login source kinds: %w", err)
+	}
+	baseline := make(map[string]bool, len(sources))
+	for _, s := range sources {
+		baseline[s.Kind] = true
+	}
+	for _, kind := range knownKinds {
+		baseline[kind] = false
+	}
+
+	inserted := false
+	for _, s := range sources {
+		var isNew bool // xmax is 0 for freshly inserted rows
+		src, err := scanLoginSource(tx.QueryRow(ctx, `
+			INSERT INTO login_sources (account_id, kind, value, confirmed, first_ip, first_protocol)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (account_id, kind, value) DO UPDATE SET last_seen = now()
+			RETURNING `+loginSourceColumns+`, (xmax = 0)
+		`, accountID, s.Kind, s.Value, confirmNew || baseline[s.Kind], ip, protocol), &isNew)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to record login source: %w", err)
+		}
+		if isNew {
+			inserted = true
+			if !baseline[s.Kind] {
+				newSources = append(newSources, src)
+			}
+		}
+		if !src.Confirmed {
+			pending = append(pending, src)
+		}
+	}
+
+	if inserted && maxPerAccount > 0 {
+		_, err = tx.Exec(ctx, `
+			DELETE FROM login_sources
+			WHERE id IN (
+				SELECT id FROM login_sources
+				WHERE account_id = $1
+				ORDER BY last_seen DESC, id DESC
+				OFFSET $2
+			)
+		`, accountID, maxPerAccount)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to prune login sources: %w", err)
+		}
+	}
+	return newSources, pending, nil
+}
+
+// ListLoginSources returns an account's known login sources, most recently
+// seen first.
+func (db *Database) ListLoginSources(ctx context.Context, accountID int64) ([]server.LoginSource, error) {
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, `
+		SELECT `+loginSourceColumns+`
+		FROM login_sources
+		WHERE account_id = $1
+		ORDER BY last_seen DESC, id DESC
+	`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login sources: %w", err)
+	}
+	defer rows.Close()
+
+	sources := []server.LoginSource{}
+	for rows.Next() {
+		s, err := scanLoginSource(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan login source: %w", err)
+		}
+		sources = append(sources, s)
+	}
+	return sources, rows.Err()
+}
+
+// ConfirmLoginSource marks one of the account's login sources as confirmed and
+// returns it, or consts.ErrDBNotFound.
+func (db *Database) ConfirmLoginSource(ctx context.Context, tx pgx.Tx, accountID, id int64) (server.LoginSource, error) {
+	s, err := scanLoginSource(tx.QueryRow(ctx, `
+		UPDATE login_sources SET confirmed = TRUE
+		WHERE account_id = $1 AND id = $2
+		RETURNING `+loginSourceColumns, accountID, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return s, consts.ErrDBNotFound
+		}
+		return s, fmt.Errorf("failed to confirm login source: %w", err)
+	}
+	return s, nil
+}
+
+// DeleteLoginSource removes one of the account's login sources, so the next
+// login from it is reported as new again. Returns consts.ErrDBNotFound if the
+// account has no such source.
+func (db *Database) DeleteLoginSource(ctx context.Context, tx pgx.Tx, accountID, id int64) error {
+	tag, err := tx.Exec(ctx, `DELETE FROM login_sources WHERE account_id = $1 AND id = $2`, accountID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete login source: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return consts.ErrDBNotFound
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/migadu/sora/consts"
+)
+
+// Kinds of mail routes.
+const (
+	MailRouteGroup       = "group"        // address@domain expanding to several targets
+	MailRouteCatchAll    = "catchall"     // @domain receiving mail for unknown addresses
+	MailRouteDomainAlias = "domain_alias" // @domain whose addresses are those of another domain
+)
+
+// MailRoute is an inbound routing entry that is not a login credential.
+// Address is a full address for groups and "@domain" for catch-alls and
+// domain aliases. Targets are addresses, or the target domain of a domain
+// alias.
+type MailRoute struct {
+	ID        int64     `json:"id"`
+	Address   string    `json:"address"`
+	Domain    string    `json:"domain"`
+	Kind      string    `json:"kind"`
+	Targets   []string  `json:"targets"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const mailRouteColumns = `id, address, domain, kind, targets, created_at, updated_at`
+
+func scanMailRoute(row pgx.Row) (*MailRoute, error) {
+	var r MailRoute
+	if err := row.Scan(&r.ID, &r.Address, &r.Domain, &r.Kind, &r.Targets, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// PutMailRoute creates the route for route.Address, or replaces the kind and
+// targets of the existing one, and sets route.ID and the timestamps.
+func (db *Database) PutMailRoute(ctx context.Context, tx pgx.Tx, route *MailRoute) error {
+	if route.Address == "" || route.Domain == "" || len(route.Targets) == 0 {
+		return fmt.Errorf("address, domain and targets are required")
+	}
+	err := tx.QueryRow(ctx, `
+		INSERT INTO mail_routes (address, domain, kind, targets)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (address) DO UPDATE
+		SET kind = EXCLUDED.kind, targets = EXCLUDED.targets, updated_at = now()
+		RETURNING id, created_at, updated_at
+	`, route.Address, route.Domain, route.Kind, route.Targets).Scan(&route.ID, &route.CreatedAt, &route.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save mail route: %w", err)
+	}
+	return nil
+}
+
+// GetMailRoute returns the route of an address or "@domain", or
+// consts.ErrDBNotFound.
+func (db *Database) GetMailRoute(ctx context.Context, address string) (*MailRoute, error) {
+	route, err := scanMailRoute(db.GetReadPoolWithContext(ctx).QueryRow(ctx, `
+		SELECT `+mailRouteColumns+`
+		FROM mail_routes
+		WHERE address = $1
+	`, address))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, consts.ErrDBNotFound
+		}
+		return nil, fmt.Errorf("failed to look up mail route: %w", err)
+	}
+	return route, nil
+}
+
+// ListMailRoutes returns the routes of a domain, or of all domains when domain
+// is empty, ordered by domain and address.
+func (db *Database) ListMailRoutes(ctx context.Context, domain string) ([]*MailRoute, error) {
+	query := `SELECT ` + mailRouteColumns + ` FROM mail_routes`
+	var args []any
+	if domain != "" {
+		query += ` WHERE domain = $1`
+		args = append(args, domain)
+	}
+	query += ` ORDER BY domain, address`
+
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mail routes: %w", err)
+	}
+	defer rows.Close()
+
+	routes := []*MailRoute{}
+	for rows.Next() {
+		route, err := scanMailRoute(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan mail route: %w", err)
+		}
+		routes = append(routes, route)
+	}
+	return routes, rows.Err()
+}
+
+// DeleteMailRoute removes the route of an address or "@domain".
+func (db *Database) DeleteMailRoute(ctx context.Context, tx pgx.Tx, address string) error {
+	tag, err := tx.Exec(ctx, `DELETE FROM mail_routes WHERE address = $1`, address)
+	if err != nil {
+		return fmt.Errorf("failed to delete mail route: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return consts.ErrDBNotFound
+	}
+	return nil
+}
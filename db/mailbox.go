@@ -646,6 +646,23 @@ func (db *Database) HasMailboxWithSpecialUse(ctx context.Context, accountID int6
 	return exists, nil
 }
 
+// GetMailboxNameBySpecialUse returns the name of the account's own live
+// mailbox carrying the given special-use attribute, or consts.ErrMailboxNotFound.
+func (db *Database) GetMailboxNameBySpecialUse(ctx context.Context, accountID int64, attr string) (string, error) {
+	var name string
+	err := db.GetReadPoolWithContext(ctx).QueryRow(ctx, `
+		SELECT name FROM mailboxes WHERE account_id = $1 AND special_use = $2 AND deleted_at IS NULL
+		ORDER BY id LIMIT 1
+	`, accountID, attr).Scan(&name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", consts.ErrMailboxNotFound
+		}
+		return "", fmt.Errorf("failed to get special-use mailbox: %w", err)
+	}
+	return name, nil
+}
+
 func (db *Database) CreateDefaultMailboxes(ctx context.Context, tx pgx.Tx, AccountID int64) error {
 	// OPTIMIZATION: Early exit if INBOX already exists
 	// This avoids 5 INSERT attempts on every LMTP delivery when mailboxes already exist
@@ -383,3 +383,38 @@ func (db *Database) DeleteAccountMetadata(ctx context.Context, tx pgx.Tx, accoun
 	}
 	return nil
 }
+
+// MetadataEntry is a stored server or mailbox metadata entry. MailboxName is
+// empty for server metadata.
+type MetadataEntry struct {
+	MailboxName string `json:"mailbox,omitempty"`
+	Name        string `json:"name"`
+	Value       []byte `json:"value"`
+	ContentType string `json:"content_type"`
+}
+
+// ListAccountMetadata returns all server and mailbox metadata entries of an
+// account.
+func (db *Database) ListAccountMetadata(ctx context.Context, accountID int64) ([]MetadataEntry, error) {
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, `
+		SELECT COALESCE(mb.name, ''), md.entry_name, md.entry_value, COALESCE(md.content_type, '')
+		FROM metadata md
+		LEFT JOIN mailboxes mb ON mb.id = md.mailbox_id
+		WHERE md.account_id = $1
+		ORDER BY 1, 2
+	`, accountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list metadata: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []MetadataEntry{}
+	for rows.Next() {
+		var e MetadataEntry
+		if err := rows.Scan(&e.MailboxName, &e.Name, &e.Value, &e.ContentType); err != nil {
+			return nil, fmt.Errorf("failed to scan metadata: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,21 @@
+-- Named, scoped Admin API keys. Only the SHA-256 of a key is stored; the key
+-- itself is shown once when it is created. `scopes` limits which endpoints a key
+-- may call and a non-empty `domains` restricts it to accounts in those domains.
+CREATE TABLE IF NOT EXISTS admin_api_keys (
+    id           BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name         TEXT        NOT NULL,
+    key_hash     TEXT        NOT NULL,
+    key_prefix   TEXT        NOT NULL,
+    scopes       TEXT[]      NOT NULL DEFAULT '{}',
+    domains      TEXT[]      NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS admin_api_keys_hash_unique ON admin_api_keys (key_hash);
+
+-- Names identify active keys; a revoked key's name may be reused.
+CREATE UNIQUE INDEX IF NOT EXISTS admin_api_keys_name_active_unique
+    ON admin_api_keys (name) WHERE revoked_at IS NULL;
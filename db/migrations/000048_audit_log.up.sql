@@ -0,0 +1,20 @@
+-- Append-only log of administrative and security events. Each row carries the
+-- SHA-256 of the previous row's hash and its own fields, so editing or deleting
+-- a row in the middle of the log breaks the chain (see db.VerifyAuditLog).
+-- Rows are only ever inserted, or pruned from the start by the cleaner.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id          BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    created_at  TIMESTAMPTZ NOT NULL,
+    source      TEXT        NOT NULL, -- adminapi, sora-admin, ...
+    actor       TEXT        NOT NULL, -- API key name or OS user
+    remote_addr TEXT        NOT NULL DEFAULT '',
+    action      TEXT        NOT NULL, -- e.g. account.delete
+    target      TEXT        NOT NULL DEFAULT '',
+    details     JSONB       NOT NULL DEFAULT '{}',
+    prev_hash   TEXT        NOT NULL,
+    hash        TEXT        NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS audit_log_action_idx ON audit_log (action, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (LOWER(target), created_at);
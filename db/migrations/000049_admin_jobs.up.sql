@@ -0,0 +1,30 @@
+-- Long-running administrative jobs (import, export, purge, verification,
+-- re-indexing). Jobs are submitted through the Admin API or sora-admin and
+-- run by job workers, which claim them with FOR UPDATE SKIP LOCKED and keep
+-- heartbeat_at fresh while they run. A running job whose heartbeat goes stale
+-- is claimed again by another worker.
+CREATE TABLE IF NOT EXISTS admin_jobs (
+    id               BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    job_type         TEXT        NOT NULL, -- import, export, purge-account, ...
+    params           JSONB       NOT NULL DEFAULT '{}',
+    status           TEXT        NOT NULL DEFAULT 'queued', -- queued, running, succeeded, failed, cancelled
+    created_by       TEXT        NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    run_after        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at       TIMESTAMPTZ,
+    heartbeat_at     TIMESTAMPTZ,
+    finished_at      TIMESTAMPTZ,
+    worker_id        TEXT        NOT NULL DEFAULT '',
+    attempts         INT         NOT NULL DEFAULT 0,
+    max_attempts     INT         NOT NULL DEFAULT 3,
+    progress_done    BIGINT      NOT NULL DEFAULT 0,
+    progress_total   BIGINT      NOT NULL DEFAULT 0,
+    progress_message TEXT        NOT NULL DEFAULT '',
+    cancel_requested BOOLEAN     NOT NULL DEFAULT FALSE,
+    last_error       TEXT        NOT NULL DEFAULT '',
+    result           JSONB
+);
+
+CREATE INDEX IF NOT EXISTS admin_jobs_queued_idx ON admin_jobs (run_after) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS admin_jobs_running_idx ON admin_jobs (heartbeat_at) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS admin_jobs_created_at_idx ON admin_jobs (created_at);
@@ -0,0 +1,20 @@
+-- Admin-managed access list, loaded by every protocol server and proxy.
+-- "block" entries reject authentication (and, where supported, connections)
+-- from a network, for a username, or for a username from a network. "allow"
+-- entries exempt a network from auth rate limiting. Entries past expires_at
+-- are ignored and may be removed at any time.
+CREATE TABLE IF NOT EXISTS access_list (
+    id         BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    action     TEXT        NOT NULL CHECK (action IN ('block', 'allow')),
+    cidr       CIDR,
+    username   TEXT        NOT NULL DEFAULT '',
+    reason     TEXT        NOT NULL DEFAULT '',
+    created_by TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ,
+    CHECK (cidr IS NOT NULL OR username <> ''),
+    CHECK (action = 'block' OR username = '')
+);
+
+CREATE INDEX IF NOT EXISTS access_list_cidr_idx ON access_list (cidr);
+CREATE INDEX IF NOT EXISTS access_list_username_idx ON access_list (username) WHERE username <> '';
@@ -0,0 +1,18 @@
+-- Recent authentication attempts per account, shown to users in the User API.
+-- Failed attempts are recorded only when the attempted address belongs to an
+-- account. The writer prunes each account to its newest entries, so the table
+-- stays bounded.
+CREATE TABLE IF NOT EXISTS login_history (
+    id          BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    account_id  BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    username    TEXT        NOT NULL,
+    protocol    TEXT        NOT NULL,
+    ip          TEXT        NOT NULL DEFAULT '',
+    client_id   TEXT        NOT NULL DEFAULT '',
+    ja4         TEXT        NOT NULL DEFAULT '',
+    success     BOOLEAN     NOT NULL,
+    reason      TEXT        NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS login_history_account_created_idx ON login_history (account_id, created_at DESC, id DESC);
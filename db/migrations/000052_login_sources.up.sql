@@ -0,0 +1,16 @@
+-- Networks, countries and TLS client fingerprints each account has logged in
+-- from. Successful logins from a source not listed here are reported as
+-- suspicious; with step-up enabled, unconfirmed sources are rejected until the
+-- user confirms them in the User API.
+CREATE TABLE IF NOT EXISTS login_sources (
+    id              BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    account_id      BIGINT      NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    kind            TEXT        NOT NULL CHECK (kind IN ('network', 'country', 'fingerprint')),
+    value           TEXT        NOT NULL,
+    confirmed       BOOLEAN     NOT NULL DEFAULT TRUE,
+    first_ip        TEXT        NOT NULL DEFAULT '',
+    first_protocol  TEXT        NOT NULL DEFAULT '',
+    first_seen      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (account_id, kind, value)
+);
@@ -0,0 +1,19 @@
+-- Structured out-of-office settings edited through the User and Admin APIs.
+-- script is the Sieve script compiled from the settings when they are saved;
+-- LMTP runs it next to the account's active script, so users never have to
+-- write a vacation action themselves.
+CREATE TABLE IF NOT EXISTS vacation_settings (
+    account_id       BIGINT      PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    enabled          BOOLEAN     NOT NULL DEFAULT FALSE,
+    start_at         TIMESTAMPTZ,
+    end_at           TIMESTAMPTZ,
+    subject          TEXT        NOT NULL DEFAULT '',
+    text_body        TEXT        NOT NULL DEFAULT '',
+    html_body        TEXT        NOT NULL DEFAULT '',
+    days             INTEGER     NOT NULL DEFAULT 7,
+    from_address     TEXT        NOT NULL DEFAULT '',
+    addresses        TEXT[]      NOT NULL DEFAULT '{}',
+    exclude_domains  TEXT[]      NOT NULL DEFAULT '{}',
+    script           TEXT        NOT NULL DEFAULT '',
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
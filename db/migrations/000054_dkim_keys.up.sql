@@ -0,0 +1,18 @@
+-- Per-domain DKIM keys used to sign mail Sora sends through the relay
+-- (vacation replies, Sieve redirects, notifications) and to ARC-seal
+-- redirects. public_key is the DNS TXT record value to publish at
+-- <selector>._domainkey.<domain>. At most one key per domain is active; a
+-- new key is created inactive, published, then activated.
+CREATE TABLE IF NOT EXISTS dkim_keys (
+    id           BIGSERIAL   PRIMARY KEY,
+    domain       TEXT        NOT NULL,
+    selector     TEXT        NOT NULL,
+    algorithm    TEXT        NOT NULL CHECK (algorithm IN ('rsa', 'ed25519')),
+    private_key  TEXT        NOT NULL,
+    public_key   TEXT        NOT NULL,
+    active       BOOLEAN     NOT NULL DEFAULT FALSE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (domain, selector)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_dkim_keys_active_domain ON dkim_keys (domain) WHERE active;
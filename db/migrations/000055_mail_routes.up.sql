@@ -0,0 +1,16 @@
+-- Inbound routing entries that are not login credentials. address is either
+-- a full address (groups) or "@domain" (catch-all accounts and domain
+-- aliases). targets are addresses for groups and catch-alls, and the target
+-- domain for a domain alias. Targets that do not resolve to a local account
+-- or route are forwarded through the relay queue.
+CREATE TABLE IF NOT EXISTS mail_routes (
+    id          BIGSERIAL   PRIMARY KEY,
+    address     TEXT        NOT NULL UNIQUE,
+    domain      TEXT        NOT NULL,
+    kind        TEXT        NOT NULL CHECK (kind IN ('group', 'catchall', 'domain_alias')),
+    targets     TEXT[]      NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mail_routes_domain ON mail_routes (domain);
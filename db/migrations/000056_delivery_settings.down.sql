@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS domain_delivery_settings;
+DROP TABLE IF EXISTS account_delivery_settings;
@@ -0,0 +1,16 @@
+-- Delivery options of an account, and the defaults of a domain for accounts
+-- without their own row. subaddress_folders controls where mail addressed to
+-- user+detail@domain goes when Sieve keeps it: 'off' (INBOX), 'existing' (the
+-- mailbox named after the detail, if there is one) or 'create' (that mailbox,
+-- created when missing).
+CREATE TABLE IF NOT EXISTS account_delivery_settings (
+    account_id          BIGINT      PRIMARY KEY REFERENCES accounts(id) ON DELETE CASCADE,
+    subaddress_folders  TEXT        NOT NULL DEFAULT 'off' CHECK (subaddress_folders IN ('off', 'existing', 'create')),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS domain_delivery_settings (
+    domain              TEXT        PRIMARY KEY,
+    subaddress_folders  TEXT        NOT NULL DEFAULT 'off' CHECK (subaddress_folders IN ('off', 'existing', 'create')),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,45 @@
+-- One row per LMTP delivery outcome, searched by support staff through the
+-- Admin API and sora-admin. The table is partitioned by day so the cleaner
+-- expires old entries by dropping whole partitions; it creates the partitions
+-- of the coming days ahead of time. Rows outside every daily partition land in
+-- the default partition, which the cleaner prunes row by row. account_id has
+-- no foreign key: entries outlive deleted accounts, and routed or returned
+-- messages have no account.
+CREATE TABLE IF NOT EXISTS delivery_log (
+    id             BIGINT GENERATED ALWAYS AS IDENTITY,
+    delivered_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    message_id     TEXT        NOT NULL DEFAULT '',
+    sender         TEXT        NOT NULL DEFAULT '',
+    recipient      TEXT        NOT NULL,
+    account_id     BIGINT,
+    mailbox        TEXT        NOT NULL DEFAULT '',
+    sieve_actions  TEXT[]      NOT NULL DEFAULT '{}',
+    size           BIGINT      NOT NULL DEFAULT 0,
+    result         TEXT        NOT NULL,
+    code           TEXT        NOT NULL DEFAULT '',
+    node           TEXT        NOT NULL DEFAULT '',
+    PRIMARY KEY (delivered_at, id)
+) PARTITION BY RANGE (delivered_at);
+
+CREATE TABLE IF NOT EXISTS delivery_log_default PARTITION OF delivery_log DEFAULT;
+
+CREATE INDEX IF NOT EXISTS delivery_log_recipient_idx ON delivery_log (recipient, delivered_at DESC);
+CREATE INDEX IF NOT EXISTS delivery_log_sender_idx ON delivery_log (sender, delivered_at DESC);
+CREATE INDEX IF NOT EXISTS delivery_log_message_id_idx ON delivery_log (message_id);
+CREATE INDEX IF NOT EXISTS delivery_log_account_idx ON delivery_log (account_id, delivered_at DESC);
+
+-- Partitions for today and the next week (UTC days), so deliveries do not go
+-- to the default partition before the cleaner first runs.
+DO $$
+DECLARE
+    day DATE;
+BEGIN
+    FOR i IN 0..7 LOOP
+        day := (now() AT TIME ZONE 'UTC')::date + i;
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF delivery_log FOR VALUES FROM (%L) TO (%L)',
+            'delivery_log_p' || to_char(day, 'YYYYMMDD'),
+            day::timestamp AT TIME ZONE 'UTC',
+            (day + 1)::timestamp AT TIME ZONE 'UTC');
+    END LOOP;
+END $$;
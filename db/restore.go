@@ -395,5 +395,18 @@ func (d *Database) RestoreMessages(ctx context.Context, tx pgx.Tx, params Restor
 		logger.Info("Database: skipped restoring messages that already exist in target mailboxes", "count", skippedCount)
 	}
 
+	if restoredCount > 0 {
+		details := map[string]any{"restored": restoredCount, "skipped": skippedCount}
+		if params.MailboxPath != nil {
+			details["mailbox"] = *params.MailboxPath
+		}
+		if len(params.MessageIDs) > 0 {
+			details["message_ids"] = params.MessageIDs
+		}
+		if err := d.recordAudit(ctx, tx, AuditMessagesRestore, params.Email, details); err != nil {
+			return 0, err
+		}
+	}
+
 	return restoredCount, nil
 }
@@ -108,3 +108,9 @@ func escapeLike(s string) string {
 	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
 	return r.Replace(s)
 }
+
+// likeSuffix returns a `LIKE ... ESCAPE '\'` pattern matching values that end
+// in s, taken literally.
+func likeSuffix(s string) string {
+	return "%" + escapeLike(s)
+}
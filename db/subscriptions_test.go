@@ -71,3 +71,8 @@ func TestSubscriptions_NameBased(t *testing.T) {
 	require.Equal(t, []string{"Ghost/Reports", "Ghost/Reports/2026"},
 		subNames(t, db, ctx, accountID))
 }
+
+func TestLikeSuffix(t *testing.T) {
+	require.Equal(t, `%@ex\_ample\%.com`, likeSuffix("@ex_ample%.com"))
+	require.Equal(t, `%@a\\b.com`, likeSuffix(`@a\b.com`))
+}
@@ -2,9 +2,11 @@ package db
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/migadu/sora/consts"
 )
 
 // VacationResponse represents a record of a vacation auto-response sent to a sender
@@ -59,3 +61,132 @@ func (db *Database) CleanupOldVacationResponses(ctx context.Context, tx pgx.Tx,
 
 	return result.RowsAffected(), nil
 }
+
+// ListVacationResponses returns the vacation responses sent for an account,
+// newest first.
+func (db *Database) ListVacationResponses(ctx context.Context, AccountID int64) ([]VacationResponse, error) {
+	rows, err := db.GetReadPoolWithContext(ctx).Query(ctx, `
+		SELECT id, account_id, sender_address, response_date, created_at
+		FROM vacation_responses
+		WHERE account_id = $1
+		ORDER BY response_date DESC
+	`, AccountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	responses := []VacationResponse{}
+	for rows.Next() {
+		var r VacationResponse
+		if err := rows.Scan(&r.ID, &r.AccountID, &r.SenderAddress, &r.ResponseDate, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		responses = append(responses, r)
+	}
+	return responses, rows.Err()
+}
+
+// VacationSettings is an account's structured out-of-office reply. Script is
+// the Sieve script compiled from the other fields when they are saved.
+type VacationSettings struct {
+	AccountID      int64      `json:"-"`
+	Enabled        bool       `json:"enabled"`
+	StartAt        *time.Time `json:"start_at,omitempty"`
+	EndAt          *time.Time `json:"end_at,omitempty"`
+	Subject        string     `json:"subject"`
+	TextBody       string     `json:"text_body"`
+	HTMLBody       string     `json:"html_body,omitempty"`
+	Days           int        `json:"days"`
+	From           string     `json:"from,omitempty"`
+	Addresses      []string   `json:"addresses"`
+	ExcludeDomains []string   `json:"exclude_domains"`
+	Script         string     `json:"-"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// GetVacationSettings returns an account's vacation settings, or
+// consts.ErrDBNotFound if none were saved.
+func (db *Database) GetVacationSettings(ctx context.Context, AccountID int64) (*VacationSettings, error) {
+	v := &VacationSettings{AccountID: AccountID}
+	err := db.GetReadPoolWithContext(ctx).QueryRow(ctx, `
+		SELECT enabled, start_at, end_at, subject, text_body, html_body, days,
+			from_address, addresses, exclude_domains, script, updated_at
+		FROM vacation_settings
+		WHERE account_id = $1
+	`, AccountID).Scan(&v.Enabled, &v.StartAt, &v.EndAt, &v.Subject, &v.TextBody, &v.HTMLBody, &v.Days,
+		&v.From, &v.Addresses, &v.ExcludeDomains, &v.Script, &v.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, consts.ErrDBNotFound
+		}
+		return nil, err
+	}
+	return v, nil
+}
+
+// SetVacationSettings creates or replaces an account's vacation settings and
+// sets v.UpdatedAt.
+func (db *Database) SetVacationSettings(ctx context.Context, tx pgx.Tx, v *VacationSettings) error {
+	addresses, excludeDomains := v.Addresses, v.ExcludeDomains
+	if addresses == nil {
+		addresses = []string{}
+	}
+	if excludeDomains == nil {
+		excludeDomains = []string{}
+	}
+	return tx.QueryRow(ctx, `
+		INSERT INTO vacation_settings (account_id, enabled, start_at, end_at, subject, text_body, html_body,
+			days, from_address, addresses, exclude_domains, script, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, now())
+		ON CONFLICT (account_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			start_at = EXCLUDED.start_at,
+			end_at = EXCLUDED.end_at,
+			subject = EXCLUDED.subject,
+			text_body = EXCLUDED.text_body,
+			html_body = EXCLUDED.html_body,
+			days = EXCLUDED.days,
+			from_address = EXCLUDED.from_address,
+			addresses = EXCLUDED.addresses,
+			exclude_domains = EXCLUDED.exclude_domains,
+			script = EXCLUDED.script,
+			updated_at = now()
+		RETURNING updated_at
+	`, v.AccountID, v.Enabled, v.StartAt, v.EndAt, v.Subject, v.TextBody, v.HTMLBody,
+		v.Days, v.From, addresses, excludeDomains, v.Script).Scan(&v.UpdatedAt)
+}
+
+// DeleteVacationSettings removes an account's vacation settings. It returns
+// consts.ErrDBNotFound if none were saved.
+func (db *Database) DeleteVacationSettings(ctx context.Context, tx pgx.Tx, AccountID int64) error {
+	result, err := tx.Exec(ctx, `DELETE FROM vacation_settings WHERE account_id = $1`, AccountID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return consts.ErrDBNotFound
+	}
+	return nil
+}
+
+// GetActiveVacationScript returns the compiled vacation script of an account
+// whose reply is enabled and has not ended, or consts.ErrDBNotFound. The start
+// and end dates are also checked by the script itself.
+func (db *Database) GetActiveVacationScript(ctx context.Context, AccountID int64) (string, time.Time, error) {
+	var script string
+	var updatedAt time.Time
+	err := db.GetReadPoolWithContext(ctx).QueryRow(ctx, `
+		SELECT script, updated_at
+		FROM vacation_settings
+		WHERE account_id = $1 AND enabled AND script <> ''
+			AND (end_at IS NULL OR end_at > now())
+	`, AccountID).Scan(&script, &updatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", time.Time{}, consts.ErrDBNotFound
+		}
+		return "", time.Time{}, err
+	}
+	return script, updatedAt, nil
+}
@@ -0,0 +1,35 @@
+package helpers
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// maxDecompressedSize bounds decompression so a corrupt or hostile object
+// cannot exhaust memory.
+const maxDecompressedSize = 1 << 30
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func initZstd() {
+	// Both are safe for concurrent EncodeAll/DecodeAll calls.
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedSize))
+}
+
+// CompressZstd compresses data into a single zstd frame.
+func CompressZstd(data []byte) []byte {
+	zstdOnce.Do(initZstd)
+	return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/3))
+}
+
+// DecompressZstd decompresses data produced by CompressZstd.
+func DecompressZstd(data []byte) ([]byte, error) {
+	zstdOnce.Do(initZstd)
+	return zstdDecoder.DecodeAll(data, nil)
+}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// zstdMagic starts every zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+func TestZstdRoundTrip(t *testing.T) {
+	msg := []byte(strings.Repeat("From: alice@example.com\r\nSubject: test\r\n\r\nHello, world.\r\n", 100))
+
+	compressed := CompressZstd(msg)
+	if !bytes.HasPrefix(compressed, zstdMagic) {
+		t.Fatal("compressed data does not start with a zstd frame")
+	}
+	if len(compressed) >= len(msg) {
+		t.Errorf("compressed size %d not smaller than %d", len(compressed), len(msg))
+	}
+
+	decompressed, err := DecompressZstd(compressed)
+	if err != nil {
+		t.Fatalf("DecompressZstd failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, msg) {
+		t.Error("round trip changed the data")
+	}
+
+	if _, err := DecompressZstd(append(zstdMagic, 0xff, 0xff, 0xff)); err == nil {
+		t.Error("expected an error for a corrupt frame")
+	}
+}
@@ -0,0 +1,355 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxARCInstances is the highest ARC instance number allowed (RFC 8617 §4.2.1)
+const maxARCInstances = 50
+
+var (
+	// ErrNoAuthResults is returned by Seal for messages without an
+	// Authentication-Results header: there are no results to preserve.
+	ErrNoAuthResults = errors.New("message has no Authentication-Results header")
+
+	// ErrARCChainFailed is returned by Seal when the existing chain has
+	// failed or is malformed, so it must not be extended.
+	ErrARCChainFailed = errors.New("ARC chain has failed")
+)
+
+// Seal returns message with an ARC set for key prepended (RFC 8617). The
+// ARC-Authentication-Results carry the topmost Authentication-Results header,
+// which the receiving MTA in front of Sora added. That header's arc= result
+// decides the chain validation status when the message already carries ARC
+// sets, since Sora does not repeat the DNS lookups to validate them itself.
+func Seal(message []byte, key Key, now time.Time) ([]byte, error) {
+	message = toCRLF(message)
+	fields := parseHeader(message)
+
+	var authResults string
+	for _, f := range fields {
+		if strings.EqualFold(f.name, "Authentication-Results") {
+			authResults = strings.TrimSpace(unfold(f.value()))
+			break
+		}
+	}
+	if authResults == "" {
+		return nil, ErrNoAuthResults
+	}
+
+	sets, err := arcSets(fields)
+	if err != nil {
+		return nil, err
+	}
+	instance := len(sets) + 1
+	if instance > maxARCInstances {
+		return nil, ErrARCChainFailed
+	}
+	cv := "none"
+	if instance > 1 {
+		if tagValue(sets[len(sets)-1].seal.value(), "cv") == "fail" {
+			return nil, ErrARCChainFailed
+		}
+		cv = "fail"
+		if resultOf(authResults, "arc") == "pass" {
+			cv = "pass"
+		}
+	}
+
+	alg, err := signatureAlgorithm(key.Signer)
+	if err != nil {
+		return nil, err
+	}
+	i := strconv.Itoa(instance)
+	t := strconv.FormatInt(now.Unix(), 10)
+
+	aar := headerField{raw: "ARC-Authentication-Results: i=" + i + "; " + authResults + "\r\n"}
+
+	// ARC-Message-Signature: a DKIM-style signature over header and body
+	var names []string
+	var signed []headerField
+	for _, name := range presentHeaders(message) {
+		if f, ok := pickHeader(fields, name, signed); ok {
+			names = append(names, name)
+			signed = append(signed, f)
+		}
+	}
+	amsTags := "i=" + i + "; a=" + alg + "; c=relaxed/relaxed; d=" + key.Domain + "; s=" + key.Selector +
+		"; t=" + t + "; h=" + strings.Join(names, ":") + "; bh=" + bodyHash(message) + "; b="
+	var input bytes.Buffer
+	for _, f := range signed {
+		input.WriteString(relaxedHeader(f.raw))
+	}
+	input.WriteString(strings.TrimSuffix(relaxedHeader("ARC-Message-Signature: "+amsTags), "\r\n"))
+	b, err := signData(key.Signer, input.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	ams := headerField{raw: "ARC-Message-Signature: " + amsTags + foldSignature(b) + "\r\n"}
+
+	// ARC-Seal: signs all ARC sets including the new one, whose own b= is empty
+	asTags := "i=" + i + "; a=" + alg + "; cv=" + cv + "; d=" + key.Domain + "; s=" + key.Selector + "; t=" + t + "; b="
+	input.Reset()
+	for _, set := range sets {
+		input.WriteString(relaxedHeader(set.results.raw))
+		input.WriteString(relaxedHeader(set.signature.raw))
+		input.WriteString(relaxedHeader(set.seal.raw))
+	}
+	input.WriteString(relaxedHeader(aar.raw))
+	input.WriteString(relaxedHeader(ams.raw))
+	input.WriteString(strings.TrimSuffix(relaxedHeader("ARC-Seal: "+asTags), "\r\n"))
+	b, err = signData(key.Signer, input.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	seal := "ARC-Seal: " + asTags + foldSignature(b) + "\r\n"
+
+	out := make([]byte, 0, len(seal)+len(ams.raw)+len(aar.raw)+len(message))
+	out = append(out, seal...)
+	out = append(out, ams.raw...)
+	out = append(out, aar.raw...)
+	return append(out, message...), nil
+}
+
+// arcSet is one instance of ARC headers.
+type arcSet struct {
+	results, signature, seal headerField
+}
+
+// arcSets returns the existing ARC sets in instance order. Every instance
+// from 1 up must have exactly one header of each kind.
+func arcSets(fields []headerField) ([]arcSet, error) {
+	byInstance := map[int]*arcSet{}
+	kinds := map[int]int{}
+	max := 0
+	for _, f := range fields {
+		name := strings.ToLower(f.name)
+		if name != "arc-seal" && name != "arc-message-signature" && name != "arc-authentication-results" {
+			continue
+		}
+		n, err := strconv.Atoi(tagValue(f.value(), "i"))
+		if err != nil || n < 1 || n > maxARCInstances {
+			return nil, ErrARCChainFailed
+		}
+		set := byInstance[n]
+		if set == nil {
+			set = &arcSet{}
+			byInstance[n] = set
+		}
+		switch name {
+		case "arc-seal":
+			set.seal = f
+		case "arc-message-signature":
+			set.signature = f
+		default:
+			set.results = f
+		}
+		kinds[n]++
+		if n > max {
+			max = n
+		}
+	}
+	sets := make([]arcSet, 0, max)
+	for n := 1; n <= max; n++ {
+		set := byInstance[n]
+		if set == nil || kinds[n] != 3 || set.seal.raw == "" || set.signature.raw == "" || set.results.raw == "" {
+			return nil, ErrARCChainFailed
+		}
+		sets = append(sets, *set)
+	}
+	return sets, nil
+}
+
+// headerField is one header field as it appears in the message, including
+// folding and the final CRLF.
+type headerField struct {
+	name string
+	raw  string
+}
+
+// value returns the field body after the colon.
+func (f headerField) value() string {
+	return f.raw[strings.IndexByte(f.raw, ':')+1:]
+}
+
+// parseHeader splits the header section of a CRLF message into fields.
+func parseHeader(message []byte) []headerField {
+	header := message
+	if i := bytes.Index(message, []byte("\r\n\r\n")); i >= 0 {
+		header = message[:i+2]
+	} else if bytes.HasPrefix(message, []byte("\r\n")) {
+		return nil
+	}
+	var fields []headerField
+	for _, line := range strings.SplitAfter(string(header), "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].raw += line
+			continue
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 {
+			continue
+		}
+		raw := line
+		if !strings.HasSuffix(raw, "\r\n") {
+			raw += "\r\n"
+		}
+		fields = append(fields, headerField{name: strings.TrimSpace(line[:colon]), raw: raw})
+	}
+	return fields
+}
+
+// pickHeader selects the bottom-most occurrence of name not yet in used, as
+// DKIM verifiers do for repeated names in h=.
+func pickHeader(fields []headerField, name string, used []headerField) (headerField, bool) {
+	skip := 0
+	for _, u := range used {
+		if strings.EqualFold(u.name, name) {
+			skip++
+		}
+	}
+	for i := len(fields) - 1; i >= 0; i-- {
+		if !strings.EqualFold(fields[i].name, name) {
+			continue
+		}
+		if skip == 0 {
+			return fields[i], true
+		}
+		skip--
+	}
+	return headerField{}, false
+}
+
+// relaxedHeader applies relaxed header canonicalization (RFC 6376 §3.4.2).
+func relaxedHeader(raw string) string {
+	colon := strings.IndexByte(raw, ':')
+	name := strings.ToLower(strings.TrimSpace(raw[:colon]))
+	value := strings.TrimSpace(compressWSP(unfold(raw[colon+1:])))
+	return name + ":" + value + "\r\n"
+}
+
+// bodyHash returns the base64 SHA-256 of the relaxed body (RFC 6376 §3.4.4).
+func bodyHash(message []byte) string {
+	var body []byte
+	if i := bytes.Index(message, []byte("\r\n\r\n")); i >= 0 {
+		body = message[i+4:]
+	}
+	lines := strings.Split(string(body), "\r\n")
+	var canon strings.Builder
+	blank := 0
+	for n, line := range lines {
+		if n == len(lines)-1 && line == "" {
+			break // text after the final CRLF
+		}
+		line = strings.TrimRight(compressWSP(line), " ")
+		if line == "" {
+			blank++
+			continue
+		}
+		for ; blank > 0; blank-- {
+			canon.WriteString("\r\n")
+		}
+		canon.WriteString(line + "\r\n")
+	}
+	sum := sha256.Sum256([]byte(canon.String()))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// signData signs the SHA-256 of data: PKCS#1 v1.5 for RSA, and Ed25519 over
+// the digest for Ed25519 (RFC 8463).
+func signData(signer crypto.Signer, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	opts := crypto.SignerOpts(crypto.SHA256)
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		opts = crypto.Hash(0)
+	}
+	sig, err := signer.Sign(rand.Reader, sum[:], opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// signatureAlgorithm returns the a= value for signer.
+func signatureAlgorithm(signer crypto.Signer) (string, error) {
+	alg, err := Algorithm(signer)
+	if err != nil {
+		return "", err
+	}
+	return alg + "-sha256", nil
+}
+
+// foldSignature folds a b= value into continuation lines. Verifiers remove
+// the value with its whitespace before hashing, so folding is free.
+func foldSignature(b string) string {
+	var out strings.Builder
+	for len(b) > 72 {
+		out.WriteString(b[:72] + "\r\n\t")
+		b = b[72:]
+	}
+	out.WriteString(b)
+	return out.String()
+}
+
+// tagValue returns the value of a tag in a tag=value list.
+func tagValue(list, name string) string {
+	for _, tag := range strings.Split(unfold(list), ";") {
+		if k, v, ok := strings.Cut(tag, "="); ok && strings.TrimSpace(k) == name {
+			return strings.Join(strings.Fields(v), "")
+		}
+	}
+	return ""
+}
+
+// resultOf returns the result of method in an Authentication-Results value,
+// e.g. "pass" for "arc=pass".
+func resultOf(authResults, method string) string {
+	for _, part := range strings.Split(authResults, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		if k, v, ok := strings.Cut(fields[0], "="); ok && strings.EqualFold(k, method) {
+			return strings.ToLower(v)
+		}
+	}
+	return ""
+}
+
+func unfold(s string) string {
+	return strings.NewReplacer("\r\n", "", "\n", "").Replace(s)
+}
+
+// compressWSP reduces runs of spaces and tabs to a single space.
+func compressWSP(s string) string {
+	var out strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			out.WriteByte(' ')
+			space = false
+		}
+		out.WriteByte(s[i])
+	}
+	if space {
+		out.WriteByte(' ')
+	}
+	return out.String()
+}
@@ -0,0 +1,192 @@
+// Package dkim signs outgoing messages with DKIM (RFC 6376) and seals
+// forwarded messages with ARC (RFC 8617). Keys are RSA or Ed25519 (RFC 8463).
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// Key algorithms
+const (
+	AlgorithmRSA     = "rsa"
+	AlgorithmEd25519 = "ed25519"
+)
+
+// DefaultRSABits is the size of generated RSA keys
+const DefaultRSABits = 2048
+
+// signedHeaders are the header fields covered by DKIM signatures and ARC
+// message signatures (RFC 6376 §5.4.1), when present.
+var signedHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID",
+	"In-Reply-To", "References", "MIME-Version", "Content-Type",
+	"Content-Transfer-Encoding", "Auto-Submitted", "List-Id", "List-Unsubscribe",
+}
+
+// Key is a private key with the domain and selector it signs for.
+type Key struct {
+	Domain   string
+	Selector string
+	Signer   crypto.Signer
+}
+
+// GenerateKey creates a key of the given algorithm and returns it PEM-encoded
+// (PKCS#8) together with its DNS TXT record value. bits applies to RSA only;
+// zero means DefaultRSABits.
+func GenerateKey(algorithm string, bits int) (string, string, error) {
+	var signer crypto.Signer
+	switch algorithm {
+	case AlgorithmRSA:
+		if bits == 0 {
+			bits = DefaultRSABits
+		}
+		if bits < 1024 || bits > 4096 {
+			return "", "", fmt.Errorf("RSA key size must be between 1024 and 4096 bits")
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return "", "", err
+		}
+		signer = key
+	case AlgorithmEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		signer = key
+	default:
+		return "", "", fmt.Errorf("unsupported DKIM algorithm %q", algorithm)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return "", "", err
+	}
+	record, err := DNSRecord(signer)
+	if err != nil {
+		return "", "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), record, nil
+}
+
+// ParsePrivateKey parses a PEM-encoded RSA or Ed25519 private key in PKCS#8
+// or PKCS#1 form.
+func ParsePrivateKey(pemData string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", key)
+}
+
+// Algorithm returns the key algorithm of signer.
+func Algorithm(signer crypto.Signer) (string, error) {
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		return AlgorithmRSA, nil
+	case ed25519.PublicKey:
+		return AlgorithmEd25519, nil
+	}
+	return "", fmt.Errorf("unsupported key type %T", signer.Public())
+}
+
+// DNSRecord returns the TXT record value publishing signer's public key at
+// <selector>._domainkey.<domain>.
+func DNSRecord(signer crypto.Signer) (string, error) {
+	var k string
+	var pub []byte
+	switch p := signer.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(p)
+		if err != nil {
+			return "", err
+		}
+		k, pub = "rsa", der
+	case ed25519.PublicKey:
+		k, pub = "ed25519", p
+	default:
+		return "", fmt.Errorf("unsupported key type %T", p)
+	}
+	return "v=DKIM1; k=" + k + "; p=" + base64.StdEncoding.EncodeToString(pub), nil
+}
+
+// RecordName returns the DNS name of the key record for selector and domain.
+func RecordName(selector, domain string) string {
+	return selector + "._domainkey." + domain
+}
+
+// Sign returns message with a DKIM-Signature for key prepended. The
+// signature uses relaxed/relaxed canonicalization and covers the usual
+// originator and content headers.
+func Sign(message []byte, key Key) ([]byte, error) {
+	message = toCRLF(message)
+	var signed bytes.Buffer
+	err := dkim.Sign(&signed, bytes.NewReader(message), &dkim.SignOptions{
+		Domain:                 key.Domain,
+		Selector:               key.Selector,
+		Signer:                 key.Signer,
+		HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+		BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+		HeaderKeys:             presentHeaders(message),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signed.Bytes(), nil
+}
+
+// presentHeaders returns the signedHeaders that occur in message, once per
+// occurrence, always including From.
+func presentHeaders(message []byte) []string {
+	fields := parseHeader(message)
+	keys := []string{"From"}
+	for _, name := range signedHeaders {
+		n := 0
+		for _, f := range fields {
+			if strings.EqualFold(f.name, name) {
+				n++
+			}
+		}
+		if name == "From" {
+			n-- // already listed
+		}
+		for ; n > 0; n-- {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// toCRLF converts bare LF line endings to CRLF.
+func toCRLF(message []byte) []byte {
+	if !bytes.Contains(message, []byte("\n")) || bytes.Count(message, []byte("\n")) == bytes.Count(message, []byte("\r\n")) {
+		return message
+	}
+	normalized := bytes.ReplaceAll(message, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(normalized, []byte("\n"), []byte("\r\n"))
+}
@@ -0,0 +1,199 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+const testMessage = "From: Jane <jane@example.com>\r\n" +
+	"To: bob@example.net\r\n" +
+	"Subject:  A  folded\r\n\tsubject \r\n" +
+	"Date: Mon, 12 Oct 2026 10:00:00 +0000\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"\r\n" +
+	"Hello  world \r\n" +
+	"\r\n" +
+	"\r\n"
+
+// testKey generates a key and a TXT lookup answering with its record.
+func testKey(t *testing.T, algorithm string) (Key, func(string) ([]string, error)) {
+	t.Helper()
+	pemKey, record, err := GenerateKey(algorithm, 1024)
+	if err != nil {
+		t.Fatalf("GenerateKey(%s): %v", algorithm, err)
+	}
+	signer, err := ParsePrivateKey(pemKey)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	if alg, _ := Algorithm(signer); alg != algorithm {
+		t.Fatalf("Algorithm = %q, want %q", alg, algorithm)
+	}
+	lookup := func(name string) ([]string, error) {
+		if name != RecordName("sel", "example.com") {
+			return nil, errors.New("no such record")
+		}
+		return []string{record}, nil
+	}
+	return Key{Domain: "example.com", Selector: "sel", Signer: signer}, lookup
+}
+
+func verifyDKIM(t *testing.T, message []byte, lookup func(string) ([]string, error)) {
+	t.Helper()
+	verifications, err := dkim.VerifyWithOptions(bytes.NewReader(message), &dkim.VerifyOptions{LookupTXT: lookup})
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(verifications) != 1 || verifications[0].Err != nil {
+		t.Fatalf("signature does not verify: %+v", verifications)
+	}
+}
+
+func TestSign(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRSA, AlgorithmEd25519} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, lookup := testKey(t, algorithm)
+			signed, err := Sign([]byte(testMessage), key)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			verifyDKIM(t, signed, lookup)
+		})
+	}
+}
+
+// TestCanonicalization signs with the helpers used for ARC, under the
+// DKIM-Signature name, and checks an independent verifier accepts it.
+func TestCanonicalization(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmRSA, AlgorithmEd25519} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, lookup := testKey(t, algorithm)
+			message := []byte(testMessage)
+			fields := parseHeader(message)
+
+			var names []string
+			var signed []headerField
+			for _, name := range presentHeaders(message) {
+				if f, ok := pickHeader(fields, name, signed); ok {
+					names = append(names, name)
+					signed = append(signed, f)
+				}
+			}
+			alg, _ := signatureAlgorithm(key.Signer)
+			tags := "v=1; a=" + alg + "; c=relaxed/relaxed; d=example.com; s=sel; h=" +
+				strings.Join(names, ":") + "; bh=" + bodyHash(message) + "; b="
+			var input bytes.Buffer
+			for _, f := range signed {
+				input.WriteString(relaxedHeader(f.raw))
+			}
+			input.WriteString(strings.TrimSuffix(relaxedHeader("DKIM-Signature: "+tags), "\r\n"))
+			b, err := signData(key.Signer, input.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifyDKIM(t, append([]byte("DKIM-Signature: "+tags+foldSignature(b)+"\r\n"), message...), lookup)
+		})
+	}
+}
+
+// verifyARCSeal checks the ARC-Seal of the highest instance against key.
+func verifyARCSeal(t *testing.T, message []byte, key Key) {
+	t.Helper()
+	sets, err := arcSets(parseHeader(message))
+	if err != nil {
+		t.Fatalf("arcSets: %v", err)
+	}
+	var input bytes.Buffer
+	for n, set := range sets {
+		input.WriteString(relaxedHeader(set.results.raw))
+		input.WriteString(relaxedHeader(set.signature.raw))
+		if n < len(sets)-1 {
+			input.WriteString(relaxedHeader(set.seal.raw))
+			continue
+		}
+		raw := set.seal.raw
+		b := tagValue(set.seal.value(), "b")
+		unsigned := strings.TrimSuffix(unfold(raw), "\r\n")
+		unsigned = unsigned[:strings.LastIndex(unsigned, "b=")+2]
+		input.WriteString(strings.TrimSuffix(relaxedHeader(unsigned), "\r\n"))
+
+		sig, err := base64.StdEncoding.DecodeString(b)
+		if err != nil {
+			t.Fatalf("bad b= in %q: %v", raw, err)
+		}
+		sum := sha256.Sum256(input.Bytes())
+		switch pub := key.Signer.Public().(type) {
+		case *rsa.PublicKey:
+			err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig)
+		case ed25519.PublicKey:
+			if !ed25519.Verify(pub, sum[:], sig) {
+				err = errors.New("bad signature")
+			}
+		}
+		if err != nil {
+			t.Fatalf("ARC-Seal i=%d does not verify: %v", n+1, err)
+		}
+	}
+}
+
+func TestSeal(t *testing.T) {
+	key, _ := testKey(t, AlgorithmRSA)
+	now := time.Unix(1760000000, 0)
+	message := []byte("Authentication-Results: mx.example.com; spf=pass smtp.mailfrom=example.org; dkim=pass header.d=example.org\r\n" + testMessage)
+
+	sealed, err := Seal(message, key, now)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	header := string(sealed[:len(sealed)-len(message)])
+	if !strings.HasPrefix(header, "ARC-Seal: i=1; a=rsa-sha256; cv=none; d=example.com; s=sel; t=1760000000; b=") ||
+		!strings.Contains(header, "ARC-Authentication-Results: i=1; mx.example.com; spf=pass") ||
+		!strings.Contains(header, "h=From:Subject:Date:To:Message-ID;") {
+		t.Fatalf("unexpected ARC set:\n%s", header)
+	}
+	verifyARCSeal(t, sealed, key)
+
+	// The next hop extends the chain; the upstream arc= result sets cv
+	again, err := Seal(append([]byte("Authentication-Results: mx2.example.net; arc=pass\r\n"), sealed...), key, now)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !strings.HasPrefix(string(again), "ARC-Seal: i=2; a=rsa-sha256; cv=pass;") {
+		t.Fatalf("unexpected second seal: %.80s", again)
+	}
+	verifyARCSeal(t, again, key)
+
+	failed, err := Seal(append([]byte("Authentication-Results: mx2.example.net; arc=fail\r\n"), sealed...), key, now)
+	if err != nil || !strings.HasPrefix(string(failed), "ARC-Seal: i=2; a=rsa-sha256; cv=fail;") {
+		t.Fatalf("expected cv=fail seal, got %v: %.80s", err, failed)
+	}
+	if _, err := Seal(append([]byte("Authentication-Results: mx3.example.net; arc=pass\r\n"), failed...), key, now); !errors.Is(err, ErrARCChainFailed) {
+		t.Errorf("failed chain extended, err = %v", err)
+	}
+
+	if _, err := Seal([]byte(testMessage), key, now); !errors.Is(err, ErrNoAuthResults) {
+		t.Errorf("err = %v, want ErrNoAuthResults", err)
+	}
+}
+
+func TestGenerateKeyValidation(t *testing.T) {
+	if _, _, err := GenerateKey("dsa", 0); err == nil {
+		t.Error("expected error for unsupported algorithm")
+	}
+	if _, _, err := GenerateKey(AlgorithmRSA, 512); err == nil {
+		t.Error("expected error for a short RSA key")
+	}
+	if _, err := ParsePrivateKey("not a key"); err == nil {
+		t.Error("expected error for invalid PEM")
+	}
+}
@@ -0,0 +1,382 @@
+// Package geoip looks up the country of an IP address in a local MaxMind DB
+// (MMDB) file such as GeoLite2-Country or DB-IP Country Lite.
+//
+// Only the parts of the format needed for country lookups are implemented:
+// the binary search tree and the data section decoder. The whole file is read
+// into memory, so a Reader is safe for concurrent use.
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of the file
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the number of zero bytes between tree and data
+const dataSectionSeparator = 16
+
+// maxDecodeDepth bounds nesting of maps, arrays and pointers in corrupt files
+const maxDecodeDepth = 32
+
+// ErrInvalidDatabase is returned for files that are not valid MMDB databases.
+var ErrInvalidDatabase = errors.New("invalid MaxMind database")
+
+// Reader looks up records in an MMDB database.
+type Reader struct {
+	buf          []byte
+	data         []byte // Data section
+	nodeCount    uint32
+	recordSize   uint16
+	ipVersion    uint16
+	databaseType string
+	ipv4Start    uint32 // Node reached after the 96 zero bits of ::/96
+}
+
+// Open reads the database at path.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes parses a database held in memory. buf must not be modified
+// afterwards.
+func FromBytes(buf []byte) (*Reader, error) {
+	idx := bytes.LastIndex(buf, metadataMarker)
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", ErrInvalidDatabase)
+	}
+	metaStart := idx + len(metadataMarker)
+	meta, _, err := (&decoder{buf: buf[metaStart:]}).decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalidDatabase, err)
+	}
+	m, ok := meta.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+
+	r := &Reader{buf: buf}
+	nodeCount, ok1 := toUint(m["node_count"])
+	recordSize, ok2 := toUint(m["record_size"])
+	ipVersion, ok3 := toUint(m["ip_version"])
+	if !ok1 || !ok2 || !ok3 {
+		return nil, fmt.Errorf("%w: missing node_count, record_size or ip_version", ErrInvalidDatabase)
+	}
+	if recordSize != 24 && recordSize != 28 && recordSize != 32 {
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, recordSize)
+	}
+	if ipVersion != 4 && ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %d", ErrInvalidDatabase, ipVersion)
+	}
+	r.nodeCount = uint32(nodeCount)
+	r.recordSize = uint16(recordSize)
+	r.ipVersion = uint16(ipVersion)
+	r.databaseType, _ = m["database_type"].(string)
+
+	treeSize := uint64(r.nodeCount) * uint64(r.recordSize) / 4
+	dataStart := treeSize + dataSectionSeparator
+	if dataStart > uint64(idx) {
+		return nil, fmt.Errorf("%w: search tree exceeds file size", ErrInvalidDatabase)
+	}
+	r.data = buf[dataStart:idx]
+
+	if r.ipVersion == 6 {
+		node := uint32(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readRecord(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// DatabaseType returns the database_type from the metadata, e.g.
+// "GeoLite2-Country".
+func (r *Reader) DatabaseType() string {
+	return r.databaseType
+}
+
+// Lookup returns the decoded record for ip, or nil if the database has none.
+func (r *Reader) Lookup(ip net.IP) (any, error) {
+	node, bits, err := r.startNode(ip)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := (bits[i/8] >> (7 - uint(i%8))) & 1
+		node = r.readRecord(node, bit)
+	}
+	if node == r.nodeCount {
+		return nil, nil // Empty record
+	}
+	if node < r.nodeCount {
+		return nil, fmt.Errorf("%w: search tree too deep", ErrInvalidDatabase)
+	}
+	offset := uint64(node-r.nodeCount) - dataSectionSeparator
+	if offset >= uint64(len(r.data)) {
+		return nil, fmt.Errorf("%w: data pointer out of range", ErrInvalidDatabase)
+	}
+	value, _, err := (&decoder{buf: r.data}).decode(uint(offset), 0)
+	return value, err
+}
+
+// Country returns the ISO 3166-1 alpha-2 code of the country ip is located in,
+// falling back to the country the network is registered in. It returns an
+// empty string when the database has no country for ip.
+func (r *Reader) Country(ip net.IP) (string, error) {
+	record, err := r.Lookup(ip)
+	if err != nil || record == nil {
+		return "", err
+	}
+	m, _ := record.(map[string]any)
+	for _, key := range []string{"country", "registered_country"} {
+		if c, ok := m[key].(map[string]any); ok {
+			if code, ok := c["iso_code"].(string); ok && code != "" {
+				return code, nil
+			}
+		}
+	}
+	// DB-IP and some other producers store the code at the top level
+	if code, ok := m["country_code"].(string); ok {
+		return code, nil
+	}
+	return "", nil
+}
+
+// startNode returns the tree node and address bytes a lookup of ip starts with.
+func (r *Reader) startNode(ip net.IP) (uint32, []byte, error) {
+	if v4 := ip.To4(); v4 != nil {
+		if r.ipVersion == 6 {
+			return r.ipv4Start, v4, nil
+		}
+		return 0, v4, nil
+	}
+	if v6 := ip.To16(); v6 != nil {
+		if r.ipVersion == 4 {
+			return 0, nil, fmt.Errorf("IPv6 address %s in an IPv4-only database", ip)
+		}
+		return 0, v6, nil
+	}
+	return 0, nil, fmt.Errorf("invalid IP address")
+}
+
+// readRecord returns the left (bit 0) or right (bit 1) record of a node.
+func (r *Reader) readRecord(node uint32, bit byte) uint32 {
+	switch r.recordSize {
+	case 24:
+		b := r.buf[node*6:]
+		if bit == 0 {
+			return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+		}
+		return uint32(b[3])<<16 | uint32(b[4])<<8 | uint32(b[5])
+	case 28:
+		b := r.buf[node*7:]
+		if bit == 0 {
+			return uint32(b[3]&0xF0)<<20 | uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+		}
+		return uint32(b[3]&0x0F)<<24 | uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6])
+	default:
+		b := r.buf[node*8:]
+		if bit == 0 {
+			return binary.BigEndian.Uint32(b[0:4])
+		}
+		return binary.BigEndian.Uint32(b[4:8])
+	}
+}
+
+// Data section field types
+const (
+	typeExtended = 0
+	typePointer  = 1
+	typeString   = 2
+	typeDouble   = 3
+	typeBytes    = 4
+	typeUint16   = 5
+	typeUint32   = 6
+	typeMap      = 7
+	typeInt32    = 8
+	typeUint64   = 9
+	typeUint128  = 10
+	typeArray    = 11
+	typeBool     = 14
+	typeFloat    = 15
+)
+
+type decoder struct {
+	buf []byte
+}
+
+var errTruncated = fmt.Errorf("%w: truncated data", ErrInvalidDatabase)
+
+// decode decodes the value at offset and returns it with the offset of the
+// next value.
+func (d *decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, fmt.Errorf("%w: nesting too deep", ErrInvalidDatabase)
+	}
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, errTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typeNum := int(ctrl >> 5)
+
+	if typeNum == typePointer {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(pointer, depth+1)
+		return value, next, err
+	}
+
+	if typeNum == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errTruncated
+		}
+		typeNum = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28 // Extra size bytes: 1, 2 or 3
+		if offset+n > uint(len(d.buf)) {
+			return nil, 0, errTruncated
+		}
+		var extra uint
+		for _, b := range d.buf[offset : offset+n] {
+			extra = extra<<8 | uint(b)
+		}
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + extra
+		case 30:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+
+	switch typeNum {
+	case typeMap:
+		m := make(map[string]any, min(size, 64))
+		for range size {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is not a string", ErrInvalidDatabase)
+			}
+			value, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[k] = value
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 64))
+		for range size {
+			value, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errTruncated
+	}
+	b := d.buf[offset : offset+size]
+	next := offset + size
+	switch typeNum {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: bad double size", ErrInvalidDatabase)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: bad float size", ErrInvalidDatabase)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("%w: bad integer size", ErrInvalidDatabase)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: bad integer size", ErrInvalidDatabase)
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		if size == 4 {
+			return int64(int32(v)), next, nil
+		}
+		return int64(v), next, nil
+	case typeUint128:
+		// Not needed for lookups; keep the raw bytes
+		return append([]byte(nil), b...), next, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: unknown data type %d", ErrInvalidDatabase, typeNum)
+	}
+}
+
+// pointer decodes a pointer field whose control byte has been read.
+func (d *decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint((ctrl>>3)&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errTruncated
+	}
+	b := d.buf[offset : offset+n]
+	vvv := uint(ctrl & 0x7)
+	var p uint
+	switch n {
+	case 1:
+		p = vvv<<8 | uint(b[0])
+	case 2:
+		p = (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 3:
+		p = (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		p = uint(binary.BigEndian.Uint32(b))
+	}
+	return p, offset + n, nil
+}
+
+// toUint converts a decoded unsigned integer to uint64.
+func toUint(v any) (uint64, bool) {
+	u, ok := v.(uint64)
+	return u, ok
+}
@@ -0,0 +1,190 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+// Minimal MMDB writer for tests
+
+func encString(s string) []byte {
+	return append([]byte{byte(typeString<<5 | len(s))}, s...)
+}
+
+func encUint(typeNum int, v uint64) []byte {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return append([]byte{byte(typeNum<<5 | len(b))}, b...)
+}
+
+func encMap(pairs ...[]byte) []byte {
+	out := []byte{byte(typeMap<<5 | len(pairs)/2)}
+	for _, p := range pairs {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func encPointer(offset int) []byte {
+	return []byte{byte(typePointer << 5), byte(offset)}
+}
+
+type testNetwork struct {
+	cidr string
+	data int // Offset of the record in the data section
+}
+
+// buildDB returns an MMDB file with the networks mapped to records in data.
+func buildDB(t *testing.T, ipVersion, recordSize int, networks []testNetwork, data []byte) []byte {
+	t.Helper()
+	const empty = ^uint32(0)
+	nodes := [][2]uint32{{empty, empty}}
+	type leaf struct{ node, bit, data int }
+	var leaves []leaf
+
+	for _, n := range networks {
+		_, ipNet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := ipNet.Mask.Size()
+		addr := []byte(ipNet.IP)
+		if v4 := ipNet.IP.To4(); v4 != nil {
+			addr = v4
+			if ipVersion == 6 {
+				addr = append(make([]byte, 12), v4...)
+				ones += 96
+			}
+		}
+		node := 0
+		for i := range ones {
+			bit := int(addr[i/8]>>(7-uint(i%8))) & 1
+			if i == ones-1 {
+				leaves = append(leaves, leaf{node, bit, n.data})
+				break
+			}
+			if nodes[node][bit] == empty {
+				nodes = append(nodes, [2]uint32{empty, empty})
+				nodes[node][bit] = uint32(len(nodes) - 1)
+			}
+			node = int(nodes[node][bit])
+		}
+	}
+
+	nodeCount := uint32(len(nodes))
+	for _, l := range leaves {
+		nodes[l.node][l.bit] = nodeCount + dataSectionSeparator + uint32(l.data)
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		left, right := n[0], n[1]
+		if left == empty {
+			left = nodeCount
+		}
+		if right == empty {
+			right = nodeCount
+		}
+		switch recordSize {
+		case 24:
+			buf.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			buf.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(left>>24)<<4 | byte(right>>24)&0x0F, byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			b := make([]byte, 8)
+			binary.BigEndian.PutUint32(b, left)
+			binary.BigEndian.PutUint32(b[4:], right)
+			buf.Write(b)
+		}
+	}
+	buf.Write(make([]byte, dataSectionSeparator))
+	buf.Write(data)
+	buf.Write(metadataMarker)
+	buf.Write(encMap(
+		encString("node_count"), encUint(typeUint32, uint64(nodeCount)),
+		encString("record_size"), encUint(typeUint16, uint64(recordSize)),
+		encString("ip_version"), encUint(typeUint16, uint64(ipVersion)),
+		encString("database_type"), encString("Test-Country"),
+	))
+	return buf.Bytes()
+}
+
+func TestCountryLookup(t *testing.T) {
+	// Record 0: country DE; record 1 reuses the "country" key via a pointer
+	var data []byte
+	data = append(data, encMap(encString("country"), encMap(encString("iso_code"), encString("DE")))...)
+	second := len(data)
+	data = append(data, encMap(encPointer(1), encMap(encString("iso_code"), encString("SE")))...)
+	third := len(data)
+	data = append(data, encMap(encString("registered_country"), encMap(encString("iso_code"), encString("US")))...)
+
+	networks := []testNetwork{
+		{"192.0.2.0/24", 0},
+		{"198.51.100.0/25", second},
+		{"203.0.113.0/24", third},
+	}
+
+	for _, tc := range []struct {
+		ipVersion, recordSize int
+	}{{4, 24}, {4, 32}, {6, 24}, {6, 28}} {
+		nets := networks
+		if tc.ipVersion == 6 {
+			nets = append(nets, testNetwork{"2001:db8::/32", second})
+		}
+		r, err := FromBytes(buildDB(t, tc.ipVersion, tc.recordSize, nets, data))
+		if err != nil {
+			t.Fatalf("v%d/%d: FromBytes: %v", tc.ipVersion, tc.recordSize, err)
+		}
+		if r.DatabaseType() != "Test-Country" {
+			t.Errorf("DatabaseType() = %q", r.DatabaseType())
+		}
+
+		tests := map[string]string{
+			"192.0.2.77":     "DE",
+			"198.51.100.1":   "SE",
+			"198.51.100.200": "", // Outside the /25
+			"203.0.113.9":    "US",
+			"10.0.0.1":       "",
+		}
+		if tc.ipVersion == 6 {
+			tests["2001:db8::1"] = "SE"
+			tests["2001:db9::1"] = ""
+		}
+		for ip, want := range tests {
+			got, err := r.Country(net.ParseIP(ip))
+			if err != nil {
+				t.Errorf("v%d/%d: Country(%s) error: %v", tc.ipVersion, tc.recordSize, ip, err)
+				continue
+			}
+			if got != want {
+				t.Errorf("v%d/%d: Country(%s) = %q, want %q", tc.ipVersion, tc.recordSize, ip, got, want)
+			}
+		}
+	}
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	for name, buf := range map[string][]byte{
+		"empty":          nil,
+		"no metadata":    []byte("not a database"),
+		"bad metadata":   append(append([]byte{}, metadataMarker...), 0xFF),
+		"truncated tree": append(append([]byte{}, metadataMarker...), encMap(encString("node_count"), encUint(typeUint32, 1000), encString("record_size"), encUint(typeUint16, 24), encString("ip_version"), encUint(typeUint16, 4))...),
+	} {
+		if _, err := FromBytes(buf); !errors.Is(err, ErrInvalidDatabase) {
+			t.Errorf("%s: err = %v, want ErrInvalidDatabase", name, err)
+		}
+	}
+
+	r, err := FromBytes(buildDB(t, 4, 24, nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Country(net.ParseIP("2001:db8::1")); err == nil {
+		t.Error("IPv6 lookup in an IPv4 database should fail")
+	}
+}
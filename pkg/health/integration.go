@@ -6,8 +6,6 @@ import (
 	"os"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/migadu/sora/db"
 	"github.com/migadu/sora/logger"
 	"github.com/migadu/sora/pkg/circuitbreaker"
@@ -85,23 +83,16 @@ func (hi *HealthIntegration) registerStandardChecks() {
 	}
 }
 
-func (hi *HealthIntegration) RegisterS3Check(s3storage *storage.S3Storage) {
+func (hi *HealthIntegration) RegisterS3Check(backend storage.Backend) {
 	s3Check := &HealthCheck{
 		Name:     "s3_storage",
 		Interval: 60 * time.Second,
 		Timeout:  15 * time.Second,
 		Critical: true,
 		Check: func(ctx context.Context) error {
-			// Test S3 connectivity by attempting to list objects
-			input := &s3.ListObjectsV2Input{
-				Bucket:  aws.String(s3storage.BucketName),
-				MaxKeys: aws.Int32(1),
-			}
-			_, err := s3storage.Client.ListObjectsV2(ctx, input)
-			if err != nil {
-				return fmt.Errorf("S3 list objects failed: %w", err)
-			}
-			return nil
+			// Test object storage connectivity (an S3 list, or a write probe
+			// for filesystem storage)
+			return backend.Ping(ctx)
 		},
 	}
 	hi.monitor.RegisterCheck(s3Check)
@@ -1,4 +1,4 @@
-package main
+package maildir
 
 import (
 	"context"
@@ -17,6 +17,7 @@ import (
 	"github.com/emersion/go-imap/v2"
 	"github.com/migadu/sora/db"
 	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/pkg/mailexport"
 	"github.com/migadu/sora/pkg/resilient"
 	"github.com/migadu/sora/server"
 	_ "modernc.org/sqlite"
@@ -44,7 +45,7 @@ type Exporter struct {
 	db          *sql.DB
 	dbPath      string // Path to the SQLite database file
 	rdb         *resilient.ResilientDatabase
-	s3          objectStorage
+	s3          ObjectStorage
 	options     ExporterOptions
 
 	totalMessages    int64
@@ -59,7 +60,7 @@ type Exporter struct {
 }
 
 // NewExporter creates a new Exporter instance.
-func NewExporter(ctx context.Context, maildirPath, email string, jobs int, rdb *resilient.ResilientDatabase, s3 objectStorage, options ExporterOptions) (*Exporter, error) {
+func NewExporter(ctx context.Context, maildirPath, email string, jobs int, rdb *resilient.ResilientDatabase, s3 ObjectStorage, options ExporterOptions) (*Exporter, error) {
 	// Ensure maildir path exists
 	if err := os.MkdirAll(maildirPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create maildir path: %w", err)
@@ -169,6 +170,13 @@ func (exporter *Exporter) Close() error {
 	return nil
 }
 
+// Stats returns the number of messages found, exported, skipped and failed
+// so far. It is safe to call while Run is in progress.
+func (exporter *Exporter) Stats() (total, exported, skipped, failed int64) {
+	return atomic.LoadInt64(&exporter.totalMessages), atomic.LoadInt64(&exporter.exportedMessages),
+		atomic.LoadInt64(&exporter.skippedMessages), atomic.LoadInt64(&exporter.failedMessages)
+}
+
 // Run starts the export process.
 func (exporter *Exporter) Run() error {
 	defer exporter.Close()
@@ -323,7 +331,7 @@ func (exporter *Exporter) performDryRun(ctx context.Context, AccountID int64, ma
 			fmt.Printf("      Date: %s | Size: %s | Flags: %s\n",
 				msg.InternalDate.Format("2006-01-02 15:04"),
 				formatSize(msg.Size),
-				exporter.buildMaildirFlags(&msg))
+				mailexport.MaildirFlags(msg.BitwiseFlags))
 			fmt.Printf("      Action: %s: %s\n", action, reason)
 
 			// Show first few custom flags if any
@@ -664,52 +672,8 @@ func (exporter *Exporter) exportMessage(msg *db.Message, mailboxName string) err
 
 // generateMaildirFilename generates a maildir-compatible filename for a message
 func (exporter *Exporter) generateMaildirFilename(msg *db.Message) string {
-	// Basic maildir filename format: timestamp.unique_id.hostname:2,flags
-	timestamp := msg.InternalDate.Unix()
-	uniqueID := fmt.Sprintf("M%dP%d", timestamp, msg.UID)
 	hostname, _ := os.Hostname()
-	if hostname == "" {
-		hostname = "localhost"
-	}
-
-	// Build flags string
-	flagStr := exporter.buildMaildirFlags(msg)
-
-	return fmt.Sprintf("%d.%s.%s:2,%s", timestamp, uniqueID, hostname, flagStr)
-}
-
-// buildMaildirFlags converts IMAP flags to maildir flag characters
-func (exporter *Exporter) buildMaildirFlags(msg *db.Message) string {
-	var flags []byte
-
-	// Convert system flags from bitwise representation
-	systemFlags := db.BitwiseToFlags(msg.BitwiseFlags)
-	for _, flag := range systemFlags {
-		switch flag {
-		case imap.FlagDraft:
-			flags = append(flags, 'D')
-		case imap.FlagFlagged:
-			flags = append(flags, 'F')
-		case imap.FlagAnswered:
-			flags = append(flags, 'R')
-		case imap.FlagSeen:
-			flags = append(flags, 'S')
-		case imap.FlagDeleted:
-			flags = append(flags, 'T')
-			// Note: \Recent flag is not stored in maildir filenames
-		}
-	}
-
-	// Sort flags alphabetically as per maildir spec
-	for i := 0; i < len(flags)-1; i++ {
-		for j := i + 1; j < len(flags); j++ {
-			if flags[i] > flags[j] {
-				flags[i], flags[j] = flags[j], flags[i]
-			}
-		}
-	}
-
-	return string(flags)
+	return mailexport.MaildirFilename(msg, hostname)
 }
 
 // isMessageExported checks if a message already exists in the maildir
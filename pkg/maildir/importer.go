@@ -1,4 +1,4 @@
-package main
+package maildir
 
 import (
 	"bytes"
@@ -124,7 +124,7 @@ type Importer struct {
 	sqliteDB    *sql.DB // SQLite database for caching (nil in non-incremental mode)
 	dbPath      string  // Path to the SQLite database file
 	rdb         resilientDB
-	s3          objectStorage
+	s3          ObjectStorage
 	options     ImporterOptions
 
 	totalMessages    int64
@@ -151,7 +151,7 @@ type Importer struct {
 }
 
 // NewImporter creates a new Importer instance.
-func NewImporter(ctx context.Context, maildirPath, email string, jobs int, rdb *resilient.ResilientDatabase, s3 objectStorage, options ImporterOptions) (*Importer, error) {
+func NewImporter(ctx context.Context, maildirPath, email string, jobs int, rdb *resilient.ResilientDatabase, s3 ObjectStorage, options ImporterOptions) (*Importer, error) {
 	// Always create SQLite database in the maildir path to persist maildir state
 	dbPath := filepath.Join(maildirPath, "sora-maildir.db")
 
@@ -378,6 +378,13 @@ func (i *Importer) recoverOrphanedSQLiteState() error {
 	return nil
 }
 
+// Stats returns the number of messages found, imported, skipped and failed
+// so far. It is safe to call while Run is in progress.
+func (i *Importer) Stats() (total, imported, skipped, failed int64) {
+	return atomic.LoadInt64(&i.totalMessages), atomic.LoadInt64(&i.importedMessages),
+		atomic.LoadInt64(&i.skippedMessages), atomic.LoadInt64(&i.failedMessages)
+}
+
 // Run starts the import process.
 func (i *Importer) Run() error {
 	defer i.Close()
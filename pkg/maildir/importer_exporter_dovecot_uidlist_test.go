@@ -1,6 +1,6 @@
 //go:build integration
 
-package main
+package maildir
 
 import (
 	"context"
@@ -19,7 +19,7 @@ import (
 )
 
 // createTestS3Storage creates a file-based S3 mock for testing
-func createTestS3Storage(t *testing.T) objectStorage {
+func createTestS3Storage(t *testing.T) ObjectStorage {
 	t.Helper()
 
 	// Create temporary directory for mock S3 storage
@@ -0,0 +1,18 @@
+// Package maildir imports maildirs into accounts and exports accounts as
+// maildirs. It is shared by 'sora-admin import/export' and the import and
+// export admin jobs.
+package maildir
+
+import "io"
+
+// ObjectStorage defines the interface for S3-compatible object storage operations.
+// This allows for using either real S3 storage or file-based mocks during testing.
+// Both storage.S3Storage and testutils.FileBasedS3Mock implement this interface.
+type ObjectStorage interface {
+	Put(key string, reader io.Reader, size int64) error
+	Get(key string) (io.ReadCloser, error)
+	Exists(key string) (bool, string, error)
+	Delete(key string) error
+	Copy(sourcePath, destPath string) error
+	EnableEncryption(encryptionKey string) error
+}
@@ -0,0 +1,76 @@
+// Package mailexport writes messages in the maildir and mbox formats. It is
+// shared by 'sora-admin export' and the account takeout job.
+package mailexport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/migadu/sora/db"
+)
+
+// MaildirFlags converts a message's system flags to maildir info flags,
+// sorted as the maildir specification requires. \Recent has no maildir flag.
+func MaildirFlags(bitwiseFlags int) string {
+	var flags []byte
+	for _, flag := range db.BitwiseToFlags(bitwiseFlags) {
+		switch flag {
+		case imap.FlagDraft:
+			flags = append(flags, 'D')
+		case imap.FlagFlagged:
+			flags = append(flags, 'F')
+		case imap.FlagAnswered:
+			flags = append(flags, 'R')
+		case imap.FlagSeen:
+			flags = append(flags, 'S')
+		case imap.FlagDeleted:
+			flags = append(flags, 'T')
+		}
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i] < flags[j] })
+	return string(flags)
+}
+
+// MaildirFilename returns a maildir filename for a message:
+// timestamp.unique_id.hostname:2,flags
+func MaildirFilename(msg *db.Message, hostname string) string {
+	if hostname == "" {
+		hostname = "localhost"
+	}
+	timestamp := msg.InternalDate.Unix()
+	uniqueID := fmt.Sprintf("M%dP%d", timestamp, msg.UID)
+	return fmt.Sprintf("%d.%s.%s:2,%s", timestamp, uniqueID, hostname, MaildirFlags(msg.BitwiseFlags))
+}
+
+// WriteMboxMessage appends a message to an mbox in the mboxrd format: a
+// "From " separator line, the message with CRLF line endings converted to LF
+// and lines matching ^>*From  quoted with one more '>', and a blank line.
+func WriteMboxMessage(w io.Writer, sender string, date time.Time, content []byte) error {
+	if sender == "" {
+		sender = "MAILER-DAEMON"
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "From %s %s\n", sender, date.UTC().Format(time.ANSIC))
+
+	for len(content) > 0 {
+		line := content
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			line, content = content[:i], content[i+1:]
+		} else {
+			content = nil
+		}
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			bw.WriteByte('>')
+		}
+		bw.Write(line)
+		bw.WriteByte('\n')
+	}
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
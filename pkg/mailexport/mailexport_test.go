@@ -0,0 +1,40 @@
+package mailexport
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/migadu/sora/db"
+)
+
+func TestMaildirFlags(t *testing.T) {
+	flags := db.FlagSeen | db.FlagFlagged | db.FlagAnswered | db.FlagDraft | db.FlagDeleted
+	if got := MaildirFlags(flags); got != "DFRST" {
+		t.Errorf("MaildirFlags = %q, want DFRST", got)
+	}
+	if got := MaildirFlags(0); got != "" {
+		t.Errorf("MaildirFlags(0) = %q, want empty", got)
+	}
+}
+
+func TestMaildirFilename(t *testing.T) {
+	msg := &db.Message{UID: 7, InternalDate: time.Unix(1700000000, 0), BitwiseFlags: db.FlagSeen}
+	if got, want := MaildirFilename(msg, "host"), "1700000000.M1700000000P7.host:2,S"; got != want {
+		t.Errorf("MaildirFilename = %q, want %q", got, want)
+	}
+}
+
+func TestWriteMboxMessage(t *testing.T) {
+	var buf bytes.Buffer
+	date := time.Date(2024, 3, 5, 10, 4, 5, 0, time.UTC)
+	content := []byte("Subject: hi\r\n\r\nFrom here\r\n>From there\r\nbye")
+	if err := WriteMboxMessage(&buf, "a@example.com", date, content); err != nil {
+		t.Fatal(err)
+	}
+	want := "From a@example.com Tue Mar  5 10:04:05 2024\n" +
+		"Subject: hi\n\n>From here\n>>From there\nbye\n\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%q\nwant:\n%q", buf.String(), want)
+	}
+}
@@ -228,6 +228,22 @@ var (
 		[]string{"result"},
 	)
 
+	LMTPProxyFanoutTransactions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sora_lmtp_proxy_fanout_transactions_total",
+			Help: "Total number of LMTP proxy transactions delivered to more than one backend",
+		},
+		[]string{"result"}, // success, partial, failure
+	)
+
+	LMTPProxyFanoutBackends = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "sora_lmtp_proxy_fanout_backends",
+			Help:    "Number of backends a fanned-out LMTP proxy transaction spans",
+			Buckets: []float64{2, 3, 4, 6, 8, 12, 16},
+		},
+	)
+
 	// Relay queue metrics
 	RelayQueueDepth = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -281,6 +297,75 @@ var (
 		[]string{"operation"}, // operation: enqueue, acquire, mark_success, mark_failure
 	)
 
+	// Content scanner metrics
+	ScannerVerdicts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sora_scanner_verdicts_total",
+			Help: "Total number of pre-delivery content scans, by verdict",
+		},
+		[]string{"scanner", "verdict"}, // verdict: accept, junk, reject, quarantine, tempfail, skipped, error
+	)
+
+	ScannerDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "sora_scanner_duration_seconds",
+			Help:    "Duration of pre-delivery content scans",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1.0, 2.0, 5.0, 10.0, 30.0},
+		},
+		[]string{"scanner"},
+	)
+
+	// Webhook metrics
+	WebhookEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sora_webhook_events_total",
+			Help: "Total number of webhook events emitted, by event type",
+		},
+		[]string{"event"},
+	)
+
+	WebhookDelivery = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sora_webhook_delivery_total",
+			Help: "Total number of webhook delivery attempts",
+		},
+		[]string{"endpoint", "result"}, // result: success, temporary_failure, permanent_failure, circuit_breaker_blocked
+	)
+
+	WebhookDeliveryDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "sora_webhook_delivery_duration_seconds",
+			Help:    "Duration of webhook delivery attempts",
+			Buckets: []float64{0.05, 0.1, 0.5, 1.0, 2.0, 5.0, 10.0, 30.0},
+		},
+		[]string{"endpoint"},
+	)
+
+	WebhookQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sora_webhook_queue_depth",
+			Help: "Number of webhook deliveries in the queue by state",
+		},
+		[]string{"state"}, // pending, processing, failed
+	)
+
+	// Suspicious login metrics
+	SuspiciousLogins = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sora_suspicious_logins_total",
+			Help: "Total number of successful logins from a source new to the account",
+		},
+		[]string{"protocol", "kind"}, // kind: network, country, fingerprint
+	)
+
+	SuspiciousLoginsBlocked = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sora_suspicious_logins_blocked_total",
+			Help: "Total number of logins rejected until the user confirms the new source",
+		},
+		[]string{"protocol"},
+	)
+
 	// IMAP-specific
 	IMAPIdleConnections = promauto.NewGauge(
 		prometheus.GaugeOpts{
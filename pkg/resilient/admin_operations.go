@@ -373,6 +373,28 @@ func (rd *ResilientDatabase) CleanupOldHealthStatusesWithRetry(ctx context.Conte
 	return result.(int64), nil
 }
 
+func (rd *ResilientDatabase) CleanupOldAuditEntriesWithRetry(ctx context.Context, retention time.Duration) (int64, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, true).CleanupOldAuditEntries(ctx, tx, retention)
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, cleanupRetryConfig, timeoutWrite, op)
+	if err != nil {
+		return 0, err
+	}
+	return result.(int64), nil
+}
+
+func (rd *ResilientDatabase) MaintainDeliveryLogWithRetry(ctx context.Context, retention time.Duration) (db.DeliveryLogMaintenance, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, true).MaintainDeliveryLog(ctx, tx, retention)
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, cleanupRetryConfig, timeoutWrite, op)
+	if err != nil {
+		return db.DeliveryLogMaintenance{}, err
+	}
+	return result.(db.DeliveryLogMaintenance), nil
+}
+
 func (rd *ResilientDatabase) GetUserScopedObjectsForCleanupWithRetry(ctx context.Context, gracePeriod time.Duration, batchSize int) ([]db.UserScopedObjectForCleanup, error) {
 	op := func(ctx context.Context) (any, error) {
 		return rd.getOperationalDatabaseForOperation(ctx, false).GetUserScopedObjectsForCleanup(ctx, gracePeriod, batchSize)
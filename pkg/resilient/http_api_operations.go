@@ -4,7 +4,10 @@ import (
 	"context"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/migadu/sora/consts"
 	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/server"
 )
 
 // --- HTTP API Wrappers ---
@@ -159,3 +162,425 @@ func (rd *ResilientDatabase) GetFailedUploadsWithEmailWithRetry(ctx context.Cont
 	}
 	return result.([]db.PendingUploadWithEmail), nil
 }
+
+// --- Admin API Key Wrappers ---
+
+func (rd *ResilientDatabase) CreateAdminAPIKeyWithRetry(ctx context.Context, key *db.AdminAPIKey) (int64, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, true).CreateAdminAPIKey(ctx, tx, key)
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op, db.ErrAdminAPIKeyExists)
+	if err != nil {
+		return 0, err
+	}
+	return result.(int64), nil
+}
+
+func (rd *ResilientDatabase) GetAdminAPIKeyByHashWithRetry(ctx context.Context, keyHash string) (*db.AdminAPIKey, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).GetAdminAPIKeyByHash(ctx, keyHash)
+	}
+	result, err := rd.executeReadWithRetry(ctx, apiRetryConfig, timeoutRead, op, consts.ErrDBNotFound)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*db.AdminAPIKey), nil
+}
+
+func (rd *ResilientDatabase) ListAdminAPIKeysWithRetry(ctx context.Context, includeRevoked bool) ([]*db.AdminAPIKey, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).ListAdminAPIKeys(ctx, includeRevoked)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*db.AdminAPIKey), nil
+}
+
+func (rd *ResilientDatabase) RevokeAdminAPIKeyWithRetry(ctx context.Context, name string) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).RevokeAdminAPIKey(ctx, tx, name)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op, consts.ErrDBNotFound)
+	return err
+}
+
+func (rd *ResilientDatabase) TouchAdminAPIKeyWithRetry(ctx context.Context, id int64) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).TouchAdminAPIKey(ctx, tx, id)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, apiRetryConfig, timeoutWrite, op)
+	return err
+}
+
+// --- DKIM Key Wrappers ---
+
+func (rd *ResilientDatabase) CreateDKIMKeyWithRetry(ctx context.Context, key *db.DKIMKey) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).CreateDKIMKey(ctx, tx, key)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op, db.ErrDKIMKeyExists)
+	return err
+}
+
+func (rd *ResilientDatabase) ListDKIMKeysWithRetry(ctx context.Context, domain string) ([]*db.DKIMKey, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).ListDKIMKeys(ctx, domain)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*db.DKIMKey), nil
+}
+
+func (rd *ResilientDatabase) GetActiveDKIMKeyWithRetry(ctx context.Context, domain string) (*db.DKIMKey, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).GetActiveDKIMKey(ctx, domain)
+	}
+	result, err := rd.executeReadWithRetry(ctx, apiRetryConfig, timeoutRead, op, consts.ErrDBNotFound)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*db.DKIMKey), nil
+}
+
+func (rd *ResilientDatabase) ActivateDKIMKeyWithRetry(ctx context.Context, domain, selector string) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).ActivateDKIMKey(ctx, tx, domain, selector)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op, consts.ErrDBNotFound)
+	return err
+}
+
+func (rd *ResilientDatabase) DeleteDKIMKeyWithRetry(ctx context.Context, domain, selector string) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).DeleteDKIMKey(ctx, tx, domain, selector)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op, consts.ErrDBNotFound)
+	return err
+}
+
+// --- Mail Route Wrappers ---
+
+func (rd *ResilientDatabase) PutMailRouteWithRetry(ctx context.Context, route *db.MailRoute) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).PutMailRoute(ctx, tx, route)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	return err
+}
+
+func (rd *ResilientDatabase) GetMailRouteWithRetry(ctx context.Context, address string) (*db.MailRoute, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).GetMailRoute(ctx, address)
+	}
+	result, err := rd.executeReadWithRetry(ctx, apiRetryConfig, timeoutRead, op, consts.ErrDBNotFound)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*db.MailRoute), nil
+}
+
+func (rd *ResilientDatabase) ListMailRoutesWithRetry(ctx context.Context, domain string) ([]*db.MailRoute, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).ListMailRoutes(ctx, domain)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*db.MailRoute), nil
+}
+
+func (rd *ResilientDatabase) DeleteMailRouteWithRetry(ctx context.Context, address string) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).DeleteMailRoute(ctx, tx, address)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op, consts.ErrDBNotFound)
+	return err
+}
+
+// --- Delivery Settings Wrappers ---
+
+func (rd *ResilientDatabase) GetDeliverySettingsWithRetry(ctx context.Context, AccountID int64, domain string) (*db.DeliverySettings, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).GetDeliverySettings(ctx, AccountID, domain)
+	}
+	result, err := rd.executeReadWithRetry(ctx, apiRetryConfig, timeoutRead, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*db.DeliverySettings), nil
+}
+
+func (rd *ResilientDatabase) SetAccountDeliverySettingsWithRetry(ctx context.Context, AccountID int64, settings *db.DeliverySettings) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).SetAccountDeliverySettings(ctx, tx, AccountID, settings)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, apiRetryConfig, timeoutWrite, op)
+	return err
+}
+
+func (rd *ResilientDatabase) DeleteAccountDeliverySettingsWithRetry(ctx context.Context, AccountID int64) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).DeleteAccountDeliverySettings(ctx, tx, AccountID)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, apiRetryConfig, timeoutWrite, op, consts.ErrDBNotFound)
+	return err
+}
+
+func (rd *ResilientDatabase) GetDomainDeliverySettingsWithRetry(ctx context.Context, domain string) (*db.DeliverySettings, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).GetDomainDeliverySettings(ctx, domain)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op, consts.ErrDBNotFound)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*db.DeliverySettings), nil
+}
+
+func (rd *ResilientDatabase) SetDomainDeliverySettingsWithRetry(ctx context.Context, domain string, settings *db.DeliverySettings) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).SetDomainDeliverySettings(ctx, tx, domain, settings)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	return err
+}
+
+func (rd *ResilientDatabase) DeleteDomainDeliverySettingsWithRetry(ctx context.Context, domain string) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).DeleteDomainDeliverySettings(ctx, tx, domain)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op, consts.ErrDBNotFound)
+	return err
+}
+
+// --- Access List Wrappers ---
+
+func (rd *ResilientDatabase) AddAccessListEntryWithRetry(ctx context.Context, entry *server.AccessListEntry) (*server.AccessListEntry, []int64, error) {
+	var replaced []int64
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		added, ids, err := rd.getOperationalDatabaseForOperation(ctx, true).AddAccessListEntry(ctx, tx, entry)
+		replaced = ids
+		return added, err
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.(*server.AccessListEntry), replaced, nil
+}
+
+func (rd *ResilientDatabase) ListAccessListEntriesWithRetry(ctx context.Context, includeExpired bool) ([]*server.AccessListEntry, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).ListAccessListEntries(ctx, includeExpired)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*server.AccessListEntry), nil
+}
+
+func (rd *ResilientDatabase) DeleteAccessListEntryWithRetry(ctx context.Context, id int64) (*server.AccessListEntry, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, true).DeleteAccessListEntry(ctx, tx, id)
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op, consts.ErrDBNotFound)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*server.AccessListEntry), nil
+}
+
+func (rd *ResilientDatabase) DeleteAccessListBlocksWithRetry(ctx context.Context, cidr, username string) ([]int64, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, true).DeleteAccessListBlocks(ctx, tx, cidr, username)
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]int64), nil
+}
+
+// --- Audit Log Wrappers ---
+
+func (rd *ResilientDatabase) InsertAuditEntryWithRetry(ctx context.Context, entry *db.AuditEntry) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).InsertAuditEntry(ctx, tx, entry)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutWrite, op)
+	return err
+}
+
+func (rd *ResilientDatabase) ListAuditEntriesWithRetry(ctx context.Context, filter db.AuditFilter) ([]*db.AuditEntry, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).ListAuditEntries(ctx, filter)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*db.AuditEntry), nil
+}
+
+func (rd *ResilientDatabase) SearchDeliveryLogWithRetry(ctx context.Context, filter db.DeliveryLogFilter) ([]server.DeliveryRecord, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).SearchDeliveryLog(ctx, filter)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]server.DeliveryRecord), nil
+}
+
+func (rd *ResilientDatabase) VerifyAuditLogWithRetry(ctx context.Context) (*db.AuditVerifyResult, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).VerifyAuditLog(ctx)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*db.AuditVerifyResult), nil
+}
+
+// --- Admin Job Wrappers ---
+
+func (rd *ResilientDatabase) InsertAdminJobWithRetry(ctx context.Context, job *db.AdminJob) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).InsertAdminJob(ctx, tx, job)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutWrite, op)
+	return err
+}
+
+func (rd *ResilientDatabase) ClaimAdminJobWithRetry(ctx context.Context, workerID string, types []string, staleAfter time.Duration) (*db.AdminJob, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, true).ClaimAdminJob(ctx, tx, workerID, types, staleAfter)
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, cleanupRetryConfig, timeoutWrite, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*db.AdminJob), nil
+}
+
+func (rd *ResilientDatabase) UpdateAdminJobProgressWithRetry(ctx context.Context, id int64, workerID string, done, total int64, message string) (bool, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, true).UpdateAdminJobProgress(ctx, tx, id, workerID, done, total, message)
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, cleanupRetryConfig, timeoutWrite, op, db.ErrAdminJobState)
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
+func (rd *ResilientDatabase) FinishAdminJobWithRetry(ctx context.Context, id int64, workerID, status, lastError string, result map[string]any) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).FinishAdminJob(ctx, tx, id, workerID, status, lastError, result)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, cleanupRetryConfig, timeoutWrite, op, db.ErrAdminJobState)
+	return err
+}
+
+func (rd *ResilientDatabase) RequeueAdminJobWithRetry(ctx context.Context, id int64, workerID, lastError string, delay time.Duration) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).RequeueAdminJob(ctx, tx, id, workerID, lastError, delay)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, cleanupRetryConfig, timeoutWrite, op, db.ErrAdminJobState)
+	return err
+}
+
+func (rd *ResilientDatabase) CancelAdminJobWithRetry(ctx context.Context, id int64) (*db.AdminJob, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, true).CancelAdminJob(ctx, tx, id)
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutWrite, op, consts.ErrDBNotFound, db.ErrAdminJobState)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*db.AdminJob), nil
+}
+
+func (rd *ResilientDatabase) RetryAdminJobWithRetry(ctx context.Context, id int64) (*db.AdminJob, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, true).RetryAdminJob(ctx, tx, id)
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, adminRetryConfig, timeoutWrite, op, consts.ErrDBNotFound, db.ErrAdminJobState)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*db.AdminJob), nil
+}
+
+func (rd *ResilientDatabase) GetAdminJobWithRetry(ctx context.Context, id int64) (*db.AdminJob, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).GetAdminJob(ctx, id)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op, consts.ErrDBNotFound)
+	if err != nil {
+		return nil, err
+	}
+	return result.(*db.AdminJob), nil
+}
+
+func (rd *ResilientDatabase) ListAdminJobsWithRetry(ctx context.Context, filter db.AdminJobFilter) ([]*db.AdminJob, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).ListAdminJobs(ctx, filter)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*db.AdminJob), nil
+}
+
+func (rd *ResilientDatabase) CleanupOldAdminJobsWithRetry(ctx context.Context, retention time.Duration) (int64, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, true).CleanupOldAdminJobs(ctx, tx, retention)
+	}
+	result, err := rd.executeWriteInTxWithRetry(ctx, cleanupRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return 0, err
+	}
+	return result.(int64), nil
+}
+
+// --- FTS Rebuild Wrappers ---
+
+func (rd *ResilientDatabase) CountMessagesWithoutFTSWithRetry(ctx context.Context, accountID int64) (int64, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).CountMessagesWithoutFTS(ctx, accountID)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return 0, err
+	}
+	return result.(int64), nil
+}
+
+func (rd *ResilientDatabase) GetMessagesWithoutFTSWithRetry(ctx context.Context, accountID, afterID int64, limit int) ([]db.FTSRebuildCandidate, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).GetMessagesWithoutFTS(ctx, accountID, afterID, limit)
+	}
+	result, err := rd.executeReadWithRetry(ctx, adminRetryConfig, timeoutAdmin, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]db.FTSRebuildCandidate), nil
+}
+
+func (rd *ResilientDatabase) StageFTSBodyWithRetry(ctx context.Context, contentHash, textBody string, sentDate *time.Time) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).StageFTSBody(ctx, tx, contentHash, textBody, sentDate)
+	}
+	_, err := rd.executeWriteInTxWithRetry(ctx, writeRetryConfig, timeoutWrite, op)
+	return err
+}
@@ -414,6 +414,19 @@ func (rd *ResilientDatabase) HasMailboxWithSpecialUseWithRetry(ctx context.Conte
 	return result.(bool), nil
 }
 
+// GetMailboxNameBySpecialUseWithRetry returns the name of the account's own
+// mailbox carrying the given special-use attribute.
+func (rd *ResilientDatabase) GetMailboxNameBySpecialUseWithRetry(ctx context.Context, accountID int64, attr string) (string, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).GetMailboxNameBySpecialUse(ctx, accountID, attr)
+	}
+	result, err := rd.executeReadWithRetry(ctx, readRetryConfig, timeoutRead, op, consts.ErrMailboxNotFound)
+	if err != nil {
+		return "", err
+	}
+	return result.(string), nil
+}
+
 func (rd *ResilientDatabase) CountMessagesGreaterThanUIDWithRetry(ctx context.Context, mailboxID int64, minUID imap.UID) (uint32, error) {
 	op := func(ctx context.Context) (any, error) {
 		return rd.getOperationalDatabaseForOperation(ctx, false).CountMessagesGreaterThanUID(ctx, mailboxID, minUID)
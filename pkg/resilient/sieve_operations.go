@@ -241,6 +241,60 @@ func (rd *ResilientDatabase) RecordVacationResponseWithRetry(ctx context.Context
 	return err
 }
 
+// GetVacationSettingsWithRetry retrieves an account's vacation settings with retry logic
+func (rd *ResilientDatabase) GetVacationSettingsWithRetry(ctx context.Context, AccountID int64) (*db.VacationSettings, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rd.getOperationalDatabaseForOperation(ctx, false).GetVacationSettings(ctx, AccountID)
+	}
+
+	result, err := rd.executeReadWithRetry(ctx, sieveReadRetryConfig, timeoutRead, op, consts.ErrDBNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.(*db.VacationSettings), nil
+}
+
+// SetVacationSettingsWithRetry creates or replaces an account's vacation settings with retry logic
+func (rd *ResilientDatabase) SetVacationSettingsWithRetry(ctx context.Context, settings *db.VacationSettings) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).SetVacationSettings(ctx, tx, settings)
+	}
+
+	_, err := rd.executeWriteInTxWithRetry(ctx, sieveWriteRetryConfig, timeoutWrite, op)
+	return err
+}
+
+// DeleteVacationSettingsWithRetry removes an account's vacation settings with retry logic
+func (rd *ResilientDatabase) DeleteVacationSettingsWithRetry(ctx context.Context, AccountID int64) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rd.getOperationalDatabaseForOperation(ctx, true).DeleteVacationSettings(ctx, tx, AccountID)
+	}
+
+	_, err := rd.executeWriteInTxWithRetry(ctx, sieveWriteRetryConfig, timeoutWrite, op, consts.ErrDBNotFound)
+	return err
+}
+
+// GetActiveVacationScriptWithRetry retrieves an account's compiled vacation script, if enabled, with retry logic
+func (rd *ResilientDatabase) GetActiveVacationScriptWithRetry(ctx context.Context, AccountID int64) (string, time.Time, error) {
+	type vacationScript struct {
+		script    string
+		updatedAt time.Time
+	}
+	op := func(ctx context.Context) (any, error) {
+		script, updatedAt, err := rd.getOperationalDatabaseForOperation(ctx, false).GetActiveVacationScript(ctx, AccountID)
+		return vacationScript{script, updatedAt}, err
+	}
+
+	result, err := rd.executeReadWithRetry(ctx, sieveReadRetryConfig, timeoutRead, op, consts.ErrDBNotFound)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	v := result.(vacationScript)
+	return v.script, v.updatedAt, nil
+}
+
 // Redirect rate limit methods
 
 // CountRedirectsSinceWithRetry returns the number of redirects performed by the given account within the specified duration window with retry logic
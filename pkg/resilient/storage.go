@@ -10,9 +10,7 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/smithy-go"
 	"github.com/migadu/sora/logger"
 	"github.com/migadu/sora/pkg/circuitbreaker"
@@ -22,13 +20,13 @@ import (
 )
 
 type ResilientS3Storage struct {
-	storage       *storage.S3Storage
+	storage       storage.Backend
 	getBreaker    *circuitbreaker.CircuitBreaker
 	putBreaker    *circuitbreaker.CircuitBreaker
 	deleteBreaker *circuitbreaker.CircuitBreaker
 }
 
-func NewResilientS3Storage(s3storage *storage.S3Storage) *ResilientS3Storage {
+func NewResilientS3Storage(s3storage storage.Backend) *ResilientS3Storage {
 	getSettings := circuitbreaker.DefaultSettings("s3_get")
 	getSettings.ReadyToTrip = func(counts circuitbreaker.Counts) bool {
 		failureRatio := float64(counts.TotalFailures) / float64(counts.Requests)
@@ -99,7 +97,7 @@ func NewResilientS3Storage(s3storage *storage.S3Storage) *ResilientS3Storage {
 	}
 }
 
-func (rs *ResilientS3Storage) GetStorage() *storage.S3Storage {
+func (rs *ResilientS3Storage) GetStorage() storage.Backend {
 	return rs.storage
 }
 
@@ -126,6 +124,9 @@ func IsNotFoundError(err error) bool {
 	if err == nil {
 		return false
 	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return true
+	}
 	// Structured AWS SDK check first — most reliable.
 	var apiErr smithy.APIError
 	if errors.As(err, &apiErr) {
@@ -312,84 +313,12 @@ func (rs *ResilientS3Storage) DeleteWithRetry(ctx context.Context, key string) e
 	return err
 }
 
-func (rs *ResilientS3Storage) PutObjectWithRetry(ctx context.Context, key string, reader io.Reader, objectSize int64) (*s3.PutObjectOutput, error) {
-	config := retry.BackoffConfig{
-		InitialInterval: 1 * time.Second,
-		MaxInterval:     30 * time.Second,
-		Multiplier:      2.0,
-		Jitter:          true,
-		MaxRetries:      3,
-		OperationName:   "s3_put_object",
-	}
-
-	op := func() (any, error) {
-		if seeker, ok := reader.(io.Seeker); ok {
-			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
-				return nil, fmt.Errorf("failed to rewind reader for S3 put object retry: %w", err)
-			}
-		}
-		input := &s3.PutObjectInput{
-			Bucket: aws.String(rs.storage.BucketName),
-			Key:    aws.String(key),
-			Body:   reader,
-		}
-		return rs.storage.Client.PutObject(ctx, input)
-	}
-	result, err := rs.executeS3OperationWithRetry(ctx, rs.putBreaker, config, rs.isRetryableError, op, key, "PUT")
-	if err != nil {
-		return nil, err
-	}
-	output := result.(*s3.PutObjectOutput)
-	return output, err
-}
-
-func (rs *ResilientS3Storage) GetObjectWithRetry(ctx context.Context, key string) (*s3.GetObjectOutput, error) {
-	config := retry.BackoffConfig{
-		InitialInterval: 500 * time.Millisecond,
-		MaxInterval:     10 * time.Second,
-		Multiplier:      2.0,
-		Jitter:          true,
-		MaxRetries:      4,
-		OperationName:   "s3_get_object",
-	}
-
-	op := func() (any, error) {
-		input := &s3.GetObjectInput{
-			Bucket: aws.String(rs.storage.BucketName),
-			Key:    aws.String(key),
-		}
-		return rs.storage.Client.GetObject(ctx, input)
-	}
-	result, err := rs.executeS3OperationWithRetry(ctx, rs.getBreaker, config, rs.isRetryableGetError, op, key, "GET")
-	if err != nil {
-		return nil, err
-	}
-	if result == nil {
-		return nil, nil
-	}
-	output := result.(*s3.GetObjectOutput)
-	return output, err
-}
-
-// ExistsWithRetry returns true if an S3 object with the given key exists.
-// A 404 response is treated as "does not exist" (returns false, nil).
-// All other errors are returned as-is.
+// ExistsWithRetry returns true if an object with the given key exists.
+// A missing object is reported as (false, nil); all other errors are
+// returned as-is.
 // This is used by the uploader to self-heal stuck uploads whose local file
 // was deleted but whose content was already successfully stored in S3.
 func (rs *ResilientS3Storage) ExistsWithRetry(ctx context.Context, key string) (bool, error) {
-	_, err := rs.StatObjectWithRetry(ctx, key)
-	if err != nil {
-		// A 404 means the object simply isn't there — not an error condition.
-		var httpErr *awshttp.ResponseError
-		if errors.As(err, &httpErr) && httpErr.HTTPStatusCode() == 404 {
-			return false, nil
-		}
-		return false, err
-	}
-	return true, nil
-}
-
-func (rs *ResilientS3Storage) StatObjectWithRetry(ctx context.Context, key string) (*s3.HeadObjectOutput, error) {
 	config := retry.BackoffConfig{
 		InitialInterval: 500 * time.Millisecond,
 		MaxInterval:     5 * time.Second,
@@ -400,18 +329,14 @@ func (rs *ResilientS3Storage) StatObjectWithRetry(ctx context.Context, key strin
 	}
 
 	op := func() (any, error) {
-		input := &s3.HeadObjectInput{
-			Bucket: aws.String(rs.storage.BucketName),
-			Key:    aws.String(key),
-		}
-		return rs.storage.Client.HeadObject(ctx, input)
+		exists, _, err := rs.storage.Exists(key)
+		return exists, err
 	}
 	result, err := rs.executeS3OperationWithRetry(ctx, rs.getBreaker, config, rs.isRetryableError, op, key, "STAT")
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	output := result.(*s3.HeadObjectOutput)
-	return output, err
+	return result.(bool), nil
 }
 
 // executeS3OperationWithRetry provides a generic wrapper for executing an S3 operation
@@ -3,7 +3,10 @@ package resilient
 import (
 	"context"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/migadu/sora/consts"
 	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/server"
 )
 
 // GetAccountIDByEmailWithRetry retrieves account ID from email address with retry logic
@@ -220,3 +223,119 @@ func (rdb *ResilientDatabase) MarkMessagesAsNotUploadedWithRetry(ctx context.Con
 	}
 	return result.(int64), nil
 }
+
+// RecordLoginsWithRetry stores login history records with retry logic
+func (rdb *ResilientDatabase) RecordLoginsWithRetry(ctx context.Context, records []server.LoginRecord, maxPerAccount int) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rdb.getOperationalDatabaseForOperation(ctx, true).InsertLoginHistory(ctx, tx, records, maxPerAccount)
+	}
+
+	_, err := rdb.executeWriteInTxWithRetry(ctx, writeRetryConfig, timeoutWrite, op)
+	return err
+}
+
+// RecordDeliveriesWithRetry stores delivery log records with retry logic
+func (rdb *ResilientDatabase) RecordDeliveriesWithRetry(ctx context.Context, records []server.DeliveryRecord) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rdb.getOperationalDatabaseForOperation(ctx, true).InsertDeliveryLog(ctx, tx, records)
+	}
+
+	_, err := rdb.executeWriteInTxWithRetry(ctx, writeRetryConfig, timeoutWrite, op)
+	return err
+}
+
+// GetLoginHistoryWithRetry retrieves an account's recent logins with retry logic
+func (rdb *ResilientDatabase) GetLoginHistoryWithRetry(ctx context.Context, accountID int64, limit int) ([]server.LoginRecord, error) {
+	config := readRetryConfig
+
+	op := func(ctx context.Context) (any, error) {
+		return rdb.getOperationalDatabaseForOperation(ctx, false).GetLoginHistory(ctx, accountID, limit)
+	}
+
+	result, err := rdb.executeReadWithRetry(ctx, config, timeoutRead, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]server.LoginRecord), nil
+}
+
+// ObserveLoginSourcesWithRetry records the sources of a successful login with
+// retry logic and returns the sources that were new and those still pending
+// confirmation.
+func (rdb *ResilientDatabase) ObserveLoginSourcesWithRetry(ctx context.Context, accountID int64, ip, protocol string, sources []server.LoginSource, confirmNew bool, maxPerAccount int) (newSources, pending []server.LoginSource, err error) {
+	type observed struct{ newSources, pending []server.LoginSource }
+
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		n, p, err := rdb.getOperationalDatabaseForOperation(ctx, true).ObserveLoginSources(ctx, tx, accountID, ip, protocol, sources, confirmNew, maxPerAccount)
+		return observed{n, p}, err
+	}
+
+	result, err := rdb.executeWriteInTxWithRetry(ctx, writeRetryConfig, timeoutWrite, op)
+	if err != nil {
+		return nil, nil, err
+	}
+	o := result.(observed)
+	return o.newSources, o.pending, nil
+}
+
+// ListLoginSourcesWithRetry retrieves an account's known login sources with retry logic
+func (rdb *ResilientDatabase) ListLoginSourcesWithRetry(ctx context.Context, accountID int64) ([]server.LoginSource, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rdb.getOperationalDatabaseForOperation(ctx, false).ListLoginSources(ctx, accountID)
+	}
+
+	result, err := rdb.executeReadWithRetry(ctx, readRetryConfig, timeoutRead, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]server.LoginSource), nil
+}
+
+// ConfirmLoginSourceWithRetry confirms one of an account's login sources with retry logic
+func (rdb *ResilientDatabase) ConfirmLoginSourceWithRetry(ctx context.Context, accountID, id int64) (server.LoginSource, error) {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return rdb.getOperationalDatabaseForOperation(ctx, true).ConfirmLoginSource(ctx, tx, accountID, id)
+	}
+
+	result, err := rdb.executeWriteInTxWithRetry(ctx, writeRetryConfig, timeoutWrite, op, consts.ErrDBNotFound)
+	if err != nil {
+		return server.LoginSource{}, err
+	}
+	return result.(server.LoginSource), nil
+}
+
+// DeleteLoginSourceWithRetry removes one of an account's login sources with retry logic
+func (rdb *ResilientDatabase) DeleteLoginSourceWithRetry(ctx context.Context, accountID, id int64) error {
+	op := func(ctx context.Context, tx pgx.Tx) (any, error) {
+		return nil, rdb.getOperationalDatabaseForOperation(ctx, true).DeleteLoginSource(ctx, tx, accountID, id)
+	}
+
+	_, err := rdb.executeWriteInTxWithRetry(ctx, writeRetryConfig, timeoutWrite, op, consts.ErrDBNotFound)
+	return err
+}
+
+// ListVacationResponsesWithRetry retrieves the vacation responses sent for an account with retry logic
+func (rdb *ResilientDatabase) ListVacationResponsesWithRetry(ctx context.Context, accountID int64) ([]db.VacationResponse, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rdb.getOperationalDatabaseForOperation(ctx, false).ListVacationResponses(ctx, accountID)
+	}
+
+	result, err := rdb.executeReadWithRetry(ctx, readRetryConfig, timeoutRead, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]db.VacationResponse), nil
+}
+
+// ListAccountMetadataWithRetry retrieves an account's server and mailbox metadata with retry logic
+func (rdb *ResilientDatabase) ListAccountMetadataWithRetry(ctx context.Context, accountID int64) ([]db.MetadataEntry, error) {
+	op := func(ctx context.Context) (any, error) {
+		return rdb.getOperationalDatabaseForOperation(ctx, false).ListAccountMetadata(ctx, accountID)
+	}
+
+	result, err := rdb.executeReadWithRetry(ctx, readRetryConfig, timeoutRead, op)
+	if err != nil {
+		return nil, err
+	}
+	return result.([]db.MetadataEntry), nil
+}
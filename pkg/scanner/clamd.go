@@ -0,0 +1,86 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/migadu/sora/config"
+)
+
+// clamdChunkSize is the size of the INSTREAM chunks sent to clamd.
+const clamdChunkSize = 64 * 1024
+
+// clamd scans messages with the clamd INSTREAM protocol.
+type clamd struct {
+	address     string
+	virusAction Action
+}
+
+func newClamd(cfg *config.ScannerConfig) (*clamd, error) {
+	if strings.Contains(cfg.Address, "://") {
+		return nil, fmt.Errorf("scanner: clamd address must be host:port or unix:/path, got %q", cfg.Address)
+	}
+	return &clamd{address: cfg.Address, virusAction: actionFor(cfg.GetVirusAction())}, nil
+}
+
+func (c *clamd) name() string { return "clamd" }
+
+func (c *clamd) scan(ctx context.Context, env Envelope, message []byte) (*Verdict, error) {
+	conn, err := dial(ctx, c.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriter(conn)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return nil, fmt.Errorf("failed to send to clamd: %w", err)
+	}
+	var size [4]byte
+	for rest := message; len(rest) > 0; {
+		chunk := rest[:min(len(rest), clamdChunkSize)]
+		rest = rest[len(chunk):]
+		binary.BigEndian.PutUint32(size[:], uint32(len(chunk)))
+		w.Write(size[:])
+		w.Write(chunk)
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	w.Write(size[:])
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to send to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return nil, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return c.verdict(strings.TrimRight(reply, "\x00\r\n"))
+}
+
+// verdict parses a clamd reply: "stream: OK", "stream: <name> FOUND" or
+// "<message> ERROR".
+func (c *clamd) verdict(reply string) (*Verdict, error) {
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return &Verdict{
+			Action:  ActionAccept,
+			Headers: []Header{{Name: "X-Virus-Scanned", Value: "ClamAV"}},
+		}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		virus := strings.TrimSuffix(result, " FOUND")
+		return &Verdict{
+			Action: c.virusAction,
+			Headers: []Header{
+				{Name: "X-Virus-Scanned", Value: "ClamAV"},
+				{Name: "X-Virus", Value: virus},
+			},
+			Reason: "virus found: " + virus,
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: clamd: %s", errScanner, strings.TrimSpace(reply))
+	}
+}
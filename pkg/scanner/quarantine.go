@@ -0,0 +1,84 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QuarantineRecord describes a quarantined message. It is written next to
+// the message as <id>.json; the message itself is <id>.eml.
+type QuarantineRecord struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	From      string    `json:"from"`
+	Rcpt      []string  `json:"rcpt"`
+	IP        string    `json:"ip,omitempty"`
+	MessageID string    `json:"message_id,omitempty"`
+	Scanner   string    `json:"scanner"`
+	Score     float64   `json:"score,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Size      int       `json:"size"`
+}
+
+// Quarantine writes a message and its record to a per-day directory under
+// the quarantine path and returns the record's ID. Both files are written
+// atomically, the message first, so a record always has its message.
+func (c *Client) Quarantine(env Envelope, messageID string, verdict Verdict, message []byte) (string, error) {
+	now := time.Now().UTC()
+	dir := filepath.Join(c.QuarantinePath(), now.Format("20060102"))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+
+	rec := QuarantineRecord{
+		ID:        uuid.NewString(),
+		Time:      now,
+		From:      env.From,
+		Rcpt:      env.Rcpt,
+		IP:        env.IP,
+		MessageID: messageID,
+		Scanner:   c.engine.name(),
+		Score:     verdict.Score,
+		Reason:    verdict.Reason,
+		Size:      len(message),
+	}
+	if err := writeFileAtomic(filepath.Join(dir, rec.ID+".eml"), message); err != nil {
+		return "", fmt.Errorf("failed to write quarantined message: %w", err)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(filepath.Join(dir, rec.ID+".json"), data); err != nil {
+		return "", fmt.Errorf("failed to write quarantine record: %w", err)
+	}
+	return rec.ID, nil
+}
+
+// writeFileAtomic writes data to path using a temp file and rename.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/config"
+)
+
+// rspamd scans messages with the rspamd /checkv2 HTTP protocol.
+type rspamd struct {
+	url        string
+	password   string
+	httpClient *http.Client
+	actions    map[string]Action // rspamd action -> verdict, for non-accept actions
+}
+
+func newRspamd(cfg *config.ScannerConfig, timeout time.Duration) (*rspamd, error) {
+	transport := &http.Transport{
+		MaxIdleConns:        10,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	base := strings.TrimSuffix(cfg.Address, "/")
+	if path, ok := strings.CutPrefix(cfg.Address, "unix:"); ok {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+		base = "http://localhost"
+	} else if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		return nil, fmt.Errorf("scanner: rspamd address must be an http(s) URL or unix:/path, got %q", cfg.Address)
+	}
+
+	actions := make(map[string]Action)
+	for _, a := range cfg.GetJunkActions() {
+		actions[a] = ActionJunk
+	}
+	for _, a := range cfg.QuarantineActions {
+		actions[a] = ActionQuarantine
+	}
+	for _, a := range cfg.GetRejectActions() {
+		actions[a] = ActionReject
+	}
+
+	return &rspamd{
+		url:        base + "/checkv2",
+		password:   cfg.Password,
+		httpClient: &http.Client{Timeout: timeout, Transport: transport},
+		actions:    actions,
+	}, nil
+}
+
+func (r *rspamd) name() string { return "rspamd" }
+
+// rspamdResult is the part of the /checkv2 reply used for the verdict.
+type rspamdResult struct {
+	Action        string  `json:"action"`
+	Score         float64 `json:"score"`
+	RequiredScore float64 `json:"required_score"`
+	IsSkipped     bool    `json:"is_skipped"`
+	Error         string  `json:"error"`
+	Milter        struct {
+		AddHeaders map[string]json.RawMessage `json:"add_headers"`
+	} `json:"milter"`
+	Messages struct {
+		SMTPMessage string `json:"smtp_message"`
+	} `json:"messages"`
+}
+
+func (r *rspamd) scan(ctx context.Context, env Envelope, message []byte) (*Verdict, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(message))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rspamd request: %w", err)
+	}
+	if env.From != "" {
+		req.Header.Set("From", env.From)
+	}
+	for _, rcpt := range env.Rcpt {
+		req.Header.Add("Rcpt", rcpt)
+	}
+	if env.IP != "" {
+		req.Header.Set("IP", env.IP)
+	}
+	if env.Helo != "" {
+		req.Header.Set("Helo", env.Helo)
+	}
+	if r.password != "" {
+		req.Header.Set("Password", r.password)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rspamd request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rspamd reply: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: rspamd returned status %d: %s", errScanner, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result rspamdResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse rspamd reply: %w", err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("%w: %s", errScanner, result.Error)
+	}
+	return r.verdict(&result), nil
+}
+
+// verdict maps a /checkv2 reply to a verdict. "soft reject" always defers;
+// "greylist", "no action" and unmapped actions accept.
+func (r *rspamd) verdict(result *rspamdResult) *Verdict {
+	v := &Verdict{Action: ActionAccept, Score: result.Score, Reason: result.Messages.SMTPMessage}
+	if result.IsSkipped {
+		return v
+	}
+	if result.Action == "soft reject" {
+		v.Action = ActionTempFail
+	} else if action, ok := r.actions[result.Action]; ok {
+		v.Action = action
+	}
+
+	spam := "No"
+	if v.Action != ActionAccept {
+		spam = "Yes"
+	}
+	v.Headers = append(v.Headers,
+		Header{Name: "X-Spam", Value: spam},
+		Header{Name: "X-Spam-Score", Value: strconv.FormatFloat(result.Score, 'f', 2, 64) + " / " + strconv.FormatFloat(result.RequiredScore, 'f', 2, 64)},
+		Header{Name: "X-Spam-Action", Value: result.Action},
+	)
+	v.Headers = append(v.Headers, milterHeaders(result.Milter.AddHeaders)...)
+	return v
+}
+
+// milterHeaders decodes milter.add_headers, whose values are a string or an
+// object with a value and an order, sorted by order and then name.
+func milterHeaders(raw map[string]json.RawMessage) []Header {
+	type ordered struct {
+		Header
+		order int
+	}
+	var list []ordered
+	for name, data := range raw {
+		var value string
+		if err := json.Unmarshal(data, &value); err == nil {
+			list = append(list, ordered{Header{name, value}, 0})
+			continue
+		}
+		var obj struct {
+			Value string `json:"value"`
+			Order int    `json:"order"`
+		}
+		if err := json.Unmarshal(data, &obj); err == nil {
+			list = append(list, ordered{Header{name, obj.Value}, obj.Order})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].order != list[j].order {
+			return list[i].order < list[j].order
+		}
+		return list[i].Name < list[j].Name
+	})
+	headers := make([]Header, len(list))
+	for i, h := range list {
+		headers[i] = h.Header
+	}
+	return headers
+}
@@ -0,0 +1,215 @@
+// Package scanner sends inbound messages to a content scanner (rspamd or
+// clamd) before they are stored, and turns the scanner's answer into a
+// delivery verdict.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/config"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/circuitbreaker"
+	"github.com/migadu/sora/pkg/metrics"
+)
+
+// Action is what delivery does with a scanned message.
+type Action string
+
+const (
+	// ActionAccept delivers the message normally
+	ActionAccept Action = "accept"
+	// ActionJunk delivers the message into the recipient's Junk mailbox
+	ActionJunk Action = "junk"
+	// ActionReject refuses the message with a permanent error
+	ActionReject Action = "reject"
+	// ActionQuarantine keeps the message out of the mailbox, in the quarantine directory
+	ActionQuarantine Action = "quarantine"
+	// ActionTempFail defers the message so the sending MTA retries
+	ActionTempFail Action = "tempfail"
+)
+
+// Header is a header field the verdict adds to the message.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// Verdict is the outcome of a scan.
+type Verdict struct {
+	Action  Action
+	Headers []Header // Added on top of the message, in order
+	Reason  string   // Scanner message or virus name, for logs and SMTP replies
+	Score   float64  // rspamd score
+}
+
+// Envelope is the SMTP envelope passed to the scanner along with the message.
+type Envelope struct {
+	From string
+	Rcpt []string
+	IP   string
+	Helo string
+}
+
+// engine is a scanner protocol.
+type engine interface {
+	name() string
+	scan(ctx context.Context, env Envelope, message []byte) (*Verdict, error)
+}
+
+// Client scans messages through the configured scanner. Failures and an open
+// circuit breaker resolve to the configured fail policy.
+type Client struct {
+	config         *config.ScannerConfig
+	engine         engine
+	timeout        time.Duration
+	maxSize        int64
+	addHeaders     bool
+	circuitBreaker *circuitbreaker.CircuitBreaker
+}
+
+// NewClient creates a scanner client
+func NewClient(cfg *config.ScannerConfig) (*Client, error) {
+	if cfg == nil || !cfg.IsConfigured() {
+		return nil, fmt.Errorf("scanner not configured")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	timeout, _ := cfg.GetTimeout()
+	maxSize, _ := cfg.GetMaxMessageSize()
+	cbTimeout, _ := cfg.CircuitBreaker.GetTimeout()
+
+	var e engine
+	var err error
+	switch cfg.Type {
+	case "rspamd":
+		e, err = newRspamd(cfg, timeout)
+	case "clamd":
+		e, err = newClamd(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cbSettings := circuitbreaker.Settings{
+		Name:        "scanner",
+		MaxRequests: uint32(cfg.CircuitBreaker.GetMaxRequests()),
+		Interval:    0, // Don't clear counts (use Timeout for recovery)
+		Timeout:     cbTimeout,
+		ReadyToTrip: func(counts circuitbreaker.Counts) bool {
+			threshold := cfg.CircuitBreaker.GetThreshold()
+			return counts.ConsecutiveFailures >= uint32(threshold)
+		},
+		OnStateChange: func(name string, from circuitbreaker.State, to circuitbreaker.State) {
+			logger.Info("Scanner circuit breaker state change",
+				"scanner", cfg.Type,
+				"from", from.String(),
+				"to", to.String())
+		},
+	}
+
+	return &Client{
+		config:         cfg,
+		engine:         e,
+		timeout:        timeout,
+		maxSize:        maxSize,
+		addHeaders:     cfg.ShouldAddHeaders(),
+		circuitBreaker: circuitbreaker.NewCircuitBreaker(cbSettings),
+	}, nil
+}
+
+// Scan scans a message and returns its verdict. It never fails: a scanner
+// error, timeout or open circuit breaker accepts the message unscanned or
+// defers it, depending on fail_mode. Messages over max_message_size are
+// accepted unscanned.
+func (c *Client) Scan(ctx context.Context, env Envelope, message []byte) Verdict {
+	scannerName := c.engine.name()
+	if int64(len(message)) > c.maxSize {
+		logger.Debug("Scanner: message too large, skipping scan", "scanner", scannerName, "size", len(message), "limit", c.maxSize)
+		metrics.ScannerVerdicts.WithLabelValues(scannerName, "skipped").Inc()
+		return Verdict{Action: ActionAccept}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	res, err := c.circuitBreaker.Execute(func() (any, error) {
+		return c.engine.scan(ctx, env, message)
+	})
+	metrics.ScannerDuration.WithLabelValues(scannerName).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		metrics.ScannerVerdicts.WithLabelValues(scannerName, "error").Inc()
+		if c.config.IsFailClosed() {
+			logger.Warn("Scanner: scan failed, deferring message", "scanner", scannerName, "error", err)
+			return Verdict{Action: ActionTempFail, Reason: "content scanner unavailable"}
+		}
+		logger.Warn("Scanner: scan failed, delivering message unscanned", "scanner", scannerName, "error", err)
+		return Verdict{Action: ActionAccept}
+	}
+
+	verdict := *res.(*Verdict)
+	if !c.addHeaders {
+		verdict.Headers = nil
+	}
+	metrics.ScannerVerdicts.WithLabelValues(scannerName, string(verdict.Action)).Inc()
+	return verdict
+}
+
+// GetCircuitBreaker returns the scanner's circuit breaker
+func (c *Client) GetCircuitBreaker() *circuitbreaker.CircuitBreaker {
+	return c.circuitBreaker
+}
+
+// QuarantinePath returns the directory quarantined messages are written to
+func (c *Client) QuarantinePath() string {
+	return c.config.GetQuarantinePath()
+}
+
+// actionFor maps a configured verdict name to an Action.
+func actionFor(name string) Action {
+	switch name {
+	case "junk":
+		return ActionJunk
+	case "quarantine":
+		return ActionQuarantine
+	default:
+		return ActionReject
+	}
+}
+
+// dialAddress splits a configured address into a network and an address:
+// "unix:/path" is a local socket, anything else TCP.
+func dialAddress(address string) (string, string) {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		return "unix", path
+	}
+	return "tcp", address
+}
+
+// dial connects to a scanner address, honouring the context deadline.
+func dial(ctx context.Context, address string) (net.Conn, error) {
+	network, addr := dialAddress(address)
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// errScanner wraps a failure reported by the scanner itself.
+var errScanner = errors.New("scanner error")
@@ -0,0 +1,331 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/migadu/sora/config"
+	"github.com/migadu/sora/pkg/circuitbreaker"
+)
+
+const testMessage = "From: alice@example.org\r\nSubject: hi\r\n\r\nhello\r\n"
+
+func newTestClient(t *testing.T, cfg config.ScannerConfig) *Client {
+	t.Helper()
+	cfg.Enabled = true
+	c, err := NewClient(&cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+// rspamdServer answers /checkv2 with reply and records the last request.
+func rspamdServer(t *testing.T, reply string, last *http.Request) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/checkv2" {
+			http.NotFound(w, r)
+			return
+		}
+		if last != nil {
+			*last = *r.Clone(context.Background())
+		}
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(reply))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRspamdVerdicts(t *testing.T) {
+	tests := []struct {
+		action string
+		want   Action
+	}{
+		{"no action", ActionAccept},
+		{"greylist", ActionAccept},
+		{"add header", ActionJunk},
+		{"rewrite subject", ActionJunk},
+		{"soft reject", ActionTempFail},
+		{"reject", ActionReject},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			srv := rspamdServer(t, `{"action":"`+tt.action+`","score":7.5,"required_score":15}`, nil)
+			c := newTestClient(t, config.ScannerConfig{Type: "rspamd", Address: srv.URL})
+			if v := c.Scan(context.Background(), Envelope{}, []byte(testMessage)); v.Action != tt.want {
+				t.Errorf("action %q: verdict %q, want %q", tt.action, v.Action, tt.want)
+			}
+		})
+	}
+}
+
+func TestRspamdRequestAndHeaders(t *testing.T) {
+	var req http.Request
+	srv := rspamdServer(t, `{
+		"action": "add header", "score": 9.25, "required_score": 15,
+		"milter": {"add_headers": {
+			"X-Spamd-Result": {"value": "default: False [9.25 / 15.00]", "order": 1},
+			"X-Rspamd-Server": "scan1"
+		}}
+	}`, &req)
+	c := newTestClient(t, config.ScannerConfig{Type: "rspamd", Address: srv.URL + "/", Password: "secret"})
+
+	env := Envelope{From: "alice@example.org", Rcpt: []string{"bob@example.com"}, IP: "192.0.2.1", Helo: "mx.example.org"}
+	v := c.Scan(context.Background(), env, []byte(testMessage))
+	if v.Action != ActionJunk || v.Score != 9.25 {
+		t.Fatalf("verdict = %+v", v)
+	}
+	for header, want := range map[string]string{
+		"From": "alice@example.org", "Rcpt": "bob@example.com", "Ip": "192.0.2.1", "Helo": "mx.example.org", "Password": "secret",
+	} {
+		if got := req.Header.Get(header); got != want {
+			t.Errorf("request header %s = %q, want %q", header, got, want)
+		}
+	}
+
+	var names []string
+	for _, h := range v.Headers {
+		names = append(names, h.Name+": "+h.Value)
+	}
+	want := []string{
+		"X-Spam: Yes",
+		"X-Spam-Score: 9.25 / 15.00",
+		"X-Spam-Action: add header",
+		"X-Rspamd-Server: scan1",
+		"X-Spamd-Result: default: False [9.25 / 15.00]",
+	}
+	if strings.Join(names, "\n") != strings.Join(want, "\n") {
+		t.Errorf("headers:\n%s\nwant:\n%s", strings.Join(names, "\n"), strings.Join(want, "\n"))
+	}
+
+	noHeaders := false
+	c = newTestClient(t, config.ScannerConfig{Type: "rspamd", Address: srv.URL, AddHeaders: &noHeaders})
+	if v := c.Scan(context.Background(), env, []byte(testMessage)); len(v.Headers) != 0 {
+		t.Errorf("add_headers = false still added %v", v.Headers)
+	}
+}
+
+func TestRspamdConfiguredActions(t *testing.T) {
+	srv := rspamdServer(t, `{"action":"add header","score":12}`, nil)
+	c := newTestClient(t, config.ScannerConfig{
+		Type:              "rspamd",
+		Address:           srv.URL,
+		JunkActions:       []string{},
+		QuarantineActions: []string{"add header"},
+	})
+	if v := c.Scan(context.Background(), Envelope{}, []byte(testMessage)); v.Action != ActionQuarantine {
+		t.Errorf("verdict %q, want quarantine", v.Action)
+	}
+}
+
+func TestRspamdUnixSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "rspamd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "rspamd.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"action":"reject","score":20}`))
+	}))
+	srv.Listener = ln
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	c := newTestClient(t, config.ScannerConfig{Type: "rspamd", Address: "unix:" + path})
+	if v := c.Scan(context.Background(), Envelope{}, []byte(testMessage)); v.Action != ActionReject {
+		t.Errorf("verdict %q, want reject", v.Action)
+	}
+}
+
+func TestFailPolicy(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	open := newTestClient(t, config.ScannerConfig{Type: "rspamd", Address: srv.URL})
+	if v := open.Scan(context.Background(), Envelope{}, []byte(testMessage)); v.Action != ActionAccept || len(v.Headers) != 0 {
+		t.Errorf("fail open: verdict %+v, want unscanned accept", v)
+	}
+
+	closed := newTestClient(t, config.ScannerConfig{
+		Type:           "rspamd",
+		Address:        srv.URL,
+		FailMode:       "closed",
+		CircuitBreaker: config.SpamTrainingCircuitBreakerConfig{Threshold: 2, Timeout: "1h"},
+	})
+	for i := 0; i < 4; i++ {
+		if v := closed.Scan(context.Background(), Envelope{}, []byte(testMessage)); v.Action != ActionTempFail {
+			t.Fatalf("fail closed: scan %d verdict %q, want tempfail", i, v.Action)
+		}
+	}
+	if state := closed.GetCircuitBreaker().State(); state != circuitbreaker.StateOpen {
+		t.Errorf("circuit breaker %s, want open", state)
+	}
+	// One call for the fail-open client, two before the breaker opened
+	if n := calls.Load(); n != 3 {
+		t.Errorf("scanner called %d times, want 3", n)
+	}
+}
+
+func TestOversizedMessageSkipped(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{"action":"reject"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := newTestClient(t, config.ScannerConfig{Type: "rspamd", Address: srv.URL, MaxMessageSize: "10"})
+	if v := c.Scan(context.Background(), Envelope{}, []byte(testMessage)); v.Action != ActionAccept {
+		t.Errorf("verdict %q, want accept", v.Action)
+	}
+	if calls.Load() != 0 {
+		t.Error("oversized message sent to the scanner")
+	}
+}
+
+// clamdServer answers INSTREAM scans with reply and returns the address and
+// a channel receiving each streamed message.
+func clamdServer(t *testing.T, reply string) (string, <-chan []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	streamed := make(chan []byte, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var data []byte
+				for {
+					var size [4]byte
+					if _, err := io.ReadFull(r, size[:]); err != nil {
+						return
+					}
+					n := binary.BigEndian.Uint32(size[:])
+					if n == 0 {
+						break
+					}
+					chunk := make([]byte, n)
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						return
+					}
+					data = append(data, chunk...)
+				}
+				streamed <- data
+				conn.Write([]byte(reply + "\x00"))
+			}()
+		}
+	}()
+	return ln.Addr().String(), streamed
+}
+
+func TestClamd(t *testing.T) {
+	addr, streamed := clamdServer(t, "stream: OK")
+	c := newTestClient(t, config.ScannerConfig{Type: "clamd", Address: addr})
+	large := testMessage + strings.Repeat("x", 3*clamdChunkSize)
+	v := c.Scan(context.Background(), Envelope{}, []byte(large))
+	if v.Action != ActionAccept || len(v.Headers) != 1 || v.Headers[0].Name != "X-Virus-Scanned" {
+		t.Errorf("clean verdict = %+v", v)
+	}
+	if got := <-streamed; string(got) != large {
+		t.Errorf("clamd received %d bytes, want %d", len(got), len(large))
+	}
+
+	addr, _ = clamdServer(t, "stream: Eicar-Test-Signature FOUND")
+	for virusAction, want := range map[string]Action{"": ActionReject, "junk": ActionJunk, "quarantine": ActionQuarantine} {
+		c = newTestClient(t, config.ScannerConfig{Type: "clamd", Address: addr, VirusAction: virusAction})
+		v = c.Scan(context.Background(), Envelope{}, []byte(testMessage))
+		if v.Action != want || v.Reason != "virus found: Eicar-Test-Signature" {
+			t.Errorf("virus_action %q: verdict %+v, want %q", virusAction, v, want)
+		}
+	}
+
+	addr, _ = clamdServer(t, "INSTREAM size limit exceeded. ERROR")
+	c = newTestClient(t, config.ScannerConfig{Type: "clamd", Address: addr, FailMode: "closed"})
+	if v := c.Scan(context.Background(), Envelope{}, []byte(testMessage)); v.Action != ActionTempFail {
+		t.Errorf("clamd error: verdict %q, want tempfail", v.Action)
+	}
+}
+
+func TestNewClientRejectsBadAddress(t *testing.T) {
+	for _, cfg := range []config.ScannerConfig{
+		{Type: "rspamd", Address: "127.0.0.1:11333"},
+		{Type: "clamd", Address: "http://127.0.0.1:3310"},
+		{Type: "spamassassin", Address: "127.0.0.1:783"},
+	} {
+		cfg.Enabled = true
+		if _, err := NewClient(&cfg); err == nil {
+			t.Errorf("NewClient(%s %s) succeeded", cfg.Type, cfg.Address)
+		}
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	dir := t.TempDir()
+	c := newTestClient(t, config.ScannerConfig{Type: "clamd", Address: "127.0.0.1:3310", QuarantinePath: dir})
+	env := Envelope{From: "alice@example.org", Rcpt: []string{"bob@example.com"}, IP: "192.0.2.1"}
+	verdict := Verdict{Action: ActionQuarantine, Reason: "virus found: Eicar-Test-Signature"}
+
+	id, err := c.Quarantine(env, "m1@example.org", verdict, []byte(testMessage))
+	if err != nil {
+		t.Fatalf("Quarantine: %v", err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*", id+".*"))
+	if len(matches) != 2 {
+		t.Fatalf("quarantine files = %v, want .eml and .json", matches)
+	}
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch filepath.Ext(path) {
+		case ".eml":
+			if string(data) != testMessage {
+				t.Errorf("quarantined message = %q", data)
+			}
+		case ".json":
+			var rec QuarantineRecord
+			if err := json.Unmarshal(data, &rec); err != nil {
+				t.Fatal(err)
+			}
+			if rec.ID != id || rec.From != env.From || rec.Rcpt[0] != "bob@example.com" ||
+				rec.MessageID != "m1@example.org" || rec.Scanner != "clamd" || rec.Reason != verdict.Reason || rec.Size != len(testMessage) {
+				t.Errorf("quarantine record = %+v", rec)
+			}
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Package srs implements the Sender Rewriting Scheme (SRS) for messages that
+// are forwarded to external recipients, such as Sieve redirects.
+//
+// A forwarded message keeps its original envelope sender unless it is
+// rewritten, so the destination sees a sender domain that does not authorize
+// the forwarding host and SPF/DMARC fail. Forward rewrites the sender into an
+// address of the forwarding domain that encodes the original one:
+//
+//	SRS0=HHHH=TT=origdomain.com=user@forwarder.com
+//	SRS1=HHHH=firsthop.com==HHHH=TT=origdomain.com=user@forwarder.com
+//
+// HHHH is a truncated HMAC that prevents the address from being forged, and
+// TT is the day it was created, so old addresses stop working. Reverse decodes
+// a bounce to such an address back to the sender it should be returned to.
+package srs
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// hashLength is the number of base64 characters kept from the HMAC
+	hashLength = 4
+
+	// timestampPrecision is the unit of the timestamp: one day
+	timestampPrecision = 24 * time.Hour
+
+	// timestampSlots is the number of distinct timestamps (two base32 characters)
+	timestampSlots = 1024
+
+	// DefaultMaxAge is how long a rewritten address accepts bounces by default
+	DefaultMaxAge = 21 * 24 * time.Hour
+
+	base32Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+)
+
+var (
+	// ErrNotSRS is returned by Reverse for addresses that are not SRS-encoded
+	ErrNotSRS = errors.New("not an SRS address")
+
+	// ErrInvalidSRS is returned by Reverse for SRS addresses that are
+	// malformed or whose hash does not verify with any key
+	ErrInvalidSRS = errors.New("invalid SRS address")
+
+	// ErrExpiredSRS is returned by Reverse for SRS0 addresses older than the
+	// maximum age
+	ErrExpiredSRS = errors.New("expired SRS address")
+)
+
+// Rewriter rewrites envelope senders into, and back from, SRS addresses of
+// one domain. It is safe for concurrent use.
+type Rewriter struct {
+	domain string
+	keys   [][]byte
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+// New creates a Rewriter for domain. The first secret signs new addresses;
+// every secret is accepted when decoding, so a key is rotated by prepending
+// the new secret and dropping the old one once maxAge has passed. A maxAge of
+// zero uses DefaultMaxAge.
+func New(domain string, secrets []string, maxAge time.Duration) (*Rewriter, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" || strings.ContainsAny(domain, "@= \t") {
+		return nil, fmt.Errorf("invalid SRS domain %q", domain)
+	}
+	if len(secrets) == 0 {
+		return nil, errors.New("at least one SRS secret is required")
+	}
+	keys := make([][]byte, 0, len(secrets))
+	for i, secret := range secrets {
+		if secret == "" {
+			return nil, fmt.Errorf("SRS secret %d is empty", i+1)
+		}
+		keys = append(keys, []byte(secret))
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	if maxAge >= timestampSlots*timestampPrecision {
+		return nil, fmt.Errorf("SRS max age %s exceeds the timestamp range", maxAge)
+	}
+	return &Rewriter{domain: domain, keys: keys, maxAge: maxAge, now: time.Now}, nil
+}
+
+// Domain returns the domain of the rewritten addresses.
+func (r *Rewriter) Domain() string {
+	return r.domain
+}
+
+// IsSRS reports whether address has an SRS0 or SRS1 local part.
+func IsSRS(address string) bool {
+	local, _, ok := splitAddress(address)
+	if !ok {
+		return false
+	}
+	_, _, ok = srsTag(local)
+	return ok
+}
+
+// Forward returns the envelope sender to use when forwarding a message from
+// sender. The null sender and addresses of the rewriter's own domain are
+// returned unchanged; an SRS address of another forwarder becomes SRS1 so
+// bounces go back through the first forwarder.
+func (r *Rewriter) Forward(sender string) (string, error) {
+	if sender == "" {
+		return "", nil
+	}
+	local, domain, ok := splitAddress(sender)
+	if !ok {
+		return "", fmt.Errorf("invalid sender address %q", sender)
+	}
+	if strings.EqualFold(domain, r.domain) {
+		return sender, nil
+	}
+
+	if tag, rest, ok := srsTag(local); ok {
+		switch tag {
+		case "SRS0":
+			// rest is "=HHHH=TT=domain=user": keep it and record the previous hop
+			return r.srs1(domain, rest), nil
+		case "SRS1":
+			// rest is "=HHHH=firsthop==...": re-sign for the same first hop
+			parts := strings.SplitN(rest[1:], "=", 3)
+			if len(parts) == 3 && parts[1] != "" {
+				return r.srs1(parts[1], parts[2]), nil
+			}
+		}
+	}
+
+	ts := r.timestamp()
+	return "SRS0=" + r.hash(r.keys[0], ts, domain, local) + "=" + ts + "=" + domain + "=" + local + "@" + r.domain, nil
+}
+
+// srs1 builds an SRS1 address for the first hop and the SRS0 remainder,
+// which starts with its separator.
+func (r *Rewriter) srs1(firstHop, srs0Rest string) string {
+	return "SRS1=" + r.hash(r.keys[0], firstHop, srs0Rest) + "=" + firstHop + "=" + srs0Rest + "@" + r.domain
+}
+
+// Reverse decodes an SRS address into the address a bounce to it must be
+// returned to: the original sender for SRS0, and the first forwarder's SRS0
+// address for SRS1.
+func (r *Rewriter) Reverse(address string) (string, error) {
+	local, _, ok := splitAddress(address)
+	if !ok {
+		return "", ErrNotSRS
+	}
+	tag, rest, ok := srsTag(local)
+	if !ok {
+		return "", ErrNotSRS
+	}
+
+	switch tag {
+	case "SRS0":
+		// HHHH=TT=domain=user; the user part may itself contain '='
+		parts := strings.SplitN(rest[1:], "=", 4)
+		if len(parts) != 4 || parts[2] == "" || parts[3] == "" {
+			return "", ErrInvalidSRS
+		}
+		hash, ts, domain, user := parts[0], parts[1], parts[2], parts[3]
+		if !r.verify(hash, ts, domain, user) {
+			return "", ErrInvalidSRS
+		}
+		if err := r.checkTimestamp(ts); err != nil {
+			return "", err
+		}
+		return user + "@" + domain, nil
+
+	default:
+		// HHHH=firsthop=<SRS0 remainder starting with its separator>
+		parts := strings.SplitN(rest[1:], "=", 3)
+		if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+			return "", ErrInvalidSRS
+		}
+		hash, firstHop, srs0Rest := parts[0], parts[1], parts[2]
+		if !r.verify(hash, firstHop, srs0Rest) {
+			return "", ErrInvalidSRS
+		}
+		return "SRS0" + srs0Rest + "@" + firstHop, nil
+	}
+}
+
+// hash returns the truncated HMAC of data. Data is lowercased because the
+// case of an address may not survive the round trip through other MTAs.
+func (r *Rewriter) hash(key []byte, data ...string) string {
+	mac := hmac.New(sha1.New, key)
+	for _, d := range data {
+		mac.Write([]byte(strings.ToLower(d)))
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// verify checks hash against every key, ignoring case for the same reason.
+func (r *Rewriter) verify(hash string, data ...string) bool {
+	if len(hash) != hashLength {
+		return false
+	}
+	for _, key := range r.keys {
+		if hmac.Equal([]byte(strings.ToLower(hash)), []byte(strings.ToLower(r.hash(key, data...)))) {
+			return true
+		}
+	}
+	return false
+}
+
+// timestamp returns the current day as two base32 characters.
+func (r *Rewriter) timestamp() string {
+	day := (r.now().Unix() / int64(timestampPrecision/time.Second)) % timestampSlots
+	return string([]byte{base32Chars[day>>5], base32Chars[day&31]})
+}
+
+// checkTimestamp rejects timestamps older than the maximum age. Timestamps
+// wrap around every 1024 days, so the age is taken modulo that period.
+func (r *Rewriter) checkTimestamp(ts string) error {
+	if len(ts) != 2 {
+		return ErrInvalidSRS
+	}
+	var then int64
+	for _, c := range strings.ToUpper(ts) {
+		i := strings.IndexRune(base32Chars, c)
+		if i < 0 {
+			return ErrInvalidSRS
+		}
+		then = then<<5 | int64(i)
+	}
+	now := (r.now().Unix() / int64(timestampPrecision/time.Second)) % timestampSlots
+	age := (now - then + timestampSlots) % timestampSlots
+	if time.Duration(age)*timestampPrecision > r.maxAge {
+		return ErrExpiredSRS
+	}
+	return nil
+}
+
+// srsTag splits an SRS local part into its upper-cased tag and the rest,
+// which starts with the separator ('=', '+' or '-').
+func srsTag(local string) (string, string, bool) {
+	if len(local) < 6 {
+		return "", "", false
+	}
+	tag := strings.ToUpper(local[:4])
+	if tag != "SRS0" && tag != "SRS1" {
+		return "", "", false
+	}
+	switch local[4] {
+	case '=', '+', '-':
+		// Mail::SRS accepts '+' and '-' as alternative separators
+		return tag, "=" + local[5:], true
+	}
+	return "", "", false
+}
+
+// splitAddress splits an address at its last '@'.
+func splitAddress(address string) (string, string, bool) {
+	at := strings.LastIndex(address, "@")
+	if at <= 0 || at == len(address)-1 {
+		return "", "", false
+	}
+	return address[:at], address[at+1:], true
+}
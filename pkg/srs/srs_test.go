@@ -0,0 +1,136 @@
+package srs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRewriter(t *testing.T, domain string, secrets ...string) *Rewriter {
+	t.Helper()
+	r, err := New(domain, secrets, 0)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return r
+}
+
+func TestForwardAndReverse(t *testing.T) {
+	r := newTestRewriter(t, "Forward.Example", "secret")
+
+	srs0, err := r.Forward("Alice=Ops@origin.example")
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	if !strings.HasPrefix(srs0, "SRS0=") || !strings.HasSuffix(srs0, "=origin.example=Alice=Ops@forward.example") {
+		t.Fatalf("unexpected SRS0 address %q", srs0)
+	}
+	if !IsSRS(srs0) {
+		t.Errorf("IsSRS(%q) = false", srs0)
+	}
+
+	// MTAs may change the case of the local part on the way back
+	for _, bounce := range []string{srs0, strings.ToLower(srs0)} {
+		got, err := r.Reverse(bounce)
+		if err != nil {
+			t.Fatalf("Reverse(%q): %v", bounce, err)
+		}
+		if !strings.EqualFold(got, "alice=ops@origin.example") {
+			t.Errorf("Reverse(%q) = %q", bounce, got)
+		}
+	}
+
+	for _, sender := range []string{"", "bob@forward.example"} {
+		if got, err := r.Forward(sender); err != nil || got != sender {
+			t.Errorf("Forward(%q) = %q, %v; want unchanged", sender, got, err)
+		}
+	}
+}
+
+func TestForwardSRS1(t *testing.T) {
+	first := newTestRewriter(t, "first.example", "one")
+	second := newTestRewriter(t, "second.example", "two")
+	third := newTestRewriter(t, "third.example", "three")
+
+	srs0, _ := first.Forward("alice@origin.example")
+	srs1, err := second.Forward(srs0)
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	if !strings.HasPrefix(srs1, "SRS1=") || !strings.Contains(srs1, "=first.example==") {
+		t.Fatalf("unexpected SRS1 address %q", srs1)
+	}
+
+	// A third hop re-signs the SRS1 address for the same first hop
+	resigned, err := third.Forward(srs1)
+	if err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	if !strings.HasSuffix(resigned, "@third.example") || !strings.Contains(resigned, "=first.example==") {
+		t.Fatalf("unexpected re-signed address %q", resigned)
+	}
+
+	back, err := third.Reverse(resigned)
+	if err != nil || back != srs0 {
+		t.Fatalf("third.Reverse = %q, %v; want %q", back, err, srs0)
+	}
+	orig, err := first.Reverse(back)
+	if err != nil || orig != "alice@origin.example" {
+		t.Errorf("first.Reverse = %q, %v", orig, err)
+	}
+}
+
+func TestReverseRejects(t *testing.T) {
+	r := newTestRewriter(t, "forward.example", "secret")
+	srs0, _ := r.Forward("alice@origin.example")
+
+	other := newTestRewriter(t, "forward.example", "other")
+	if _, err := other.Reverse(srs0); !errors.Is(err, ErrInvalidSRS) {
+		t.Errorf("wrong key: err = %v", err)
+	}
+	tampered := strings.Replace(srs0, "=alice@", "=mallory@", 1)
+	if _, err := r.Reverse(tampered); !errors.Is(err, ErrInvalidSRS) {
+		t.Errorf("tampered: err = %v", err)
+	}
+	if _, err := r.Reverse("alice@forward.example"); !errors.Is(err, ErrNotSRS) {
+		t.Errorf("plain address: err = %v", err)
+	}
+	if _, err := r.Reverse("SRS0=abc@forward.example"); !errors.Is(err, ErrInvalidSRS) {
+		t.Errorf("truncated: err = %v", err)
+	}
+
+	r.now = func() time.Time { return time.Now().Add(DefaultMaxAge + 48*time.Hour) }
+	if _, err := r.Reverse(srs0); !errors.Is(err, ErrExpiredSRS) {
+		t.Errorf("expired: err = %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := newTestRewriter(t, "forward.example", "old")
+	srs0, _ := old.Forward("alice@origin.example")
+
+	rotated := newTestRewriter(t, "forward.example", "new", "old")
+	if got, err := rotated.Reverse(srs0); err != nil || got != "alice@origin.example" {
+		t.Errorf("Reverse with rotated keys = %q, %v", got, err)
+	}
+	fresh, _ := rotated.Forward("alice@origin.example")
+	if _, err := old.Reverse(fresh); !errors.Is(err, ErrInvalidSRS) {
+		t.Errorf("new addresses must be signed with the first key, err = %v", err)
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	if _, err := New("", []string{"s"}, 0); err == nil {
+		t.Error("expected error for empty domain")
+	}
+	if _, err := New("forward.example", nil, 0); err == nil {
+		t.Error("expected error without secrets")
+	}
+	if _, err := New("forward.example", []string{"s", ""}, 0); err == nil {
+		t.Error("expected error for empty secret")
+	}
+	if _, err := New("forward.example", []string{"s"}, 2000*24*time.Hour); err == nil {
+		t.Error("expected error for max age beyond the timestamp range")
+	}
+}
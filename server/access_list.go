@@ -0,0 +1,440 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/migadu/sora/cluster"
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/logger"
+)
+
+// DefaultAccessListReloadInterval is how often the access list is reloaded from
+// the database. Changes made through the Admin API are gossiped immediately;
+// the reload catches anything a node missed.
+const DefaultAccessListReloadInterval = time.Minute
+
+// Access list actions.
+const (
+	AccessListBlock = "block"
+	AccessListAllow = "allow"
+)
+
+// AccessListEntry is an admin-managed block or allow rule. A block entry names
+// a network, a username, or both (the username only from that network). Allow
+// entries always name a network and exempt it from auth rate limiting.
+type AccessListEntry struct {
+	ID        int64      `json:"id"`
+	Action    string     `json:"action"`             // AccessListBlock or AccessListAllow
+	CIDR      string     `json:"cidr,omitempty"`     // Normalized network, e.g. "192.0.2.1/32"
+	Username  string     `json:"username,omitempty"` // Lowercased; empty matches any username
+	Reason    string     `json:"reason,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil = never expires
+}
+
+// Expired reports whether the entry has expired at now.
+func (e *AccessListEntry) Expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !e.ExpiresAt.After(now)
+}
+
+// Normalize validates the entry and normalizes its CIDR and username. A bare IP
+// is accepted as a single-host network.
+func (e *AccessListEntry) Normalize() error {
+	if e.Action != AccessListBlock && e.Action != AccessListAllow {
+		return fmt.Errorf("action must be %q or %q", AccessListBlock, AccessListAllow)
+	}
+	e.CIDR = strings.TrimSpace(e.CIDR)
+	e.Username = strings.ToLower(strings.TrimSpace(e.Username))
+	if e.CIDR != "" {
+		networks, err := helpers.ParseTrustedNetworks([]string{e.CIDR})
+		if err != nil {
+			return fmt.Errorf("invalid cidr %q: not a valid IP address or CIDR", e.CIDR)
+		}
+		e.CIDR = networks[0].String()
+	}
+	if e.CIDR == "" && e.Username == "" {
+		return fmt.Errorf("cidr or username is required")
+	}
+	if e.Action == AccessListAllow && (e.CIDR == "" || e.Username != "") {
+		return fmt.Errorf("allow entries take a cidr and no username")
+	}
+	return nil
+}
+
+// AccessListLoader loads the active access list entries (implemented by
+// resilient.ResilientDatabase).
+type AccessListLoader interface {
+	ListAccessListEntriesWithRetry(ctx context.Context, includeExpired bool) ([]*AccessListEntry, error)
+}
+
+// AccessListEventType represents the type of access list event
+type AccessListEventType string
+
+const (
+	// AccessListEventAdd adds (or replaces) an entry
+	AccessListEventAdd AccessListEventType = "ADD"
+
+	// AccessListEventRemove removes entries by ID
+	AccessListEventRemove AccessListEventType = "REMOVE"
+)
+
+// AccessListEvent represents a cluster-wide access list change
+type AccessListEvent struct {
+	Type      AccessListEventType
+	Entry     AccessListEntry // For ADD events
+	IDs       []int64         // For ADD (replaced entries) and REMOVE events
+	Timestamp time.Time
+	NodeID    string
+}
+
+// accessRule is an access list entry with its network parsed.
+type accessRule struct {
+	entry   AccessListEntry
+	network *net.IPNet // nil for username-only blocks
+}
+
+// AccessList is the process-wide set of admin-managed block and allow entries,
+// consulted by the auth rate limiter of every protocol server and proxy.
+//
+// An address matching both block and allow networks follows the most specific
+// network (longest prefix); on a tie the block wins. Username blocks are not
+// lifted by allow entries.
+type AccessList struct {
+	mu    sync.RWMutex
+	rules map[int64]*accessRule
+
+	loader         AccessListLoader
+	clusterManager *cluster.Manager
+
+	broadcastQueue []AccessListEvent
+	queueMu        sync.Mutex
+}
+
+var globalAccessList = &AccessList{rules: make(map[int64]*accessRule)}
+
+// GetAccessList returns the process-wide access list
+func GetAccessList() *AccessList {
+	return globalAccessList
+}
+
+// Start loads the access list, keeps it fresh from loader every reloadInterval
+// and joins cluster synchronization. loader and clusterMgr may be nil: without
+// a database the list is fed by cluster events only.
+func (al *AccessList) Start(ctx context.Context, loader AccessListLoader, clusterMgr *cluster.Manager, reloadInterval time.Duration) {
+	al.loader = loader
+	al.clusterManager = clusterMgr
+
+	if clusterMgr != nil {
+		clusterMgr.RegisterAccessListHandler(al.HandleClusterEvent)
+		clusterMgr.RegisterAccessListBroadcaster(al.GetBroadcasts)
+	}
+
+	if loader == nil {
+		return
+	}
+	if err := al.Reload(ctx); err != nil {
+		logger.Warn("Access list: Initial load failed", "error", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := al.Reload(ctx); err != nil {
+					logger.Warn("Access list: Reload failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Reload replaces the in-memory list with the active entries from the database.
+func (al *AccessList) Reload(ctx context.Context) error {
+	if al.loader == nil {
+		return nil
+	}
+	entries, err := al.loader.ListAccessListEntriesWithRetry(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	rules := make(map[int64]*accessRule, len(entries))
+	for _, entry := range entries {
+		if rule := newAccessRule(*entry); rule != nil {
+			rules[entry.ID] = rule
+		}
+	}
+
+	al.mu.Lock()
+	al.rules = rules
+	al.mu.Unlock()
+
+	logger.Debug("Access list: Reloaded", "entries", len(rules))
+	return nil
+}
+
+func newAccessRule(entry AccessListEntry) *accessRule {
+	rule := &accessRule{entry: entry}
+	if entry.CIDR != "" {
+		networks, err := helpers.ParseTrustedNetworks([]string{entry.CIDR})
+		if err != nil {
+			logger.Warn("Access list: Ignoring entry with invalid network", "id", entry.ID, "cidr", entry.CIDR)
+			return nil
+		}
+		rule.network = networks[0]
+	}
+	return rule
+}
+
+// Add adds or replaces entries in the local list without notifying the cluster.
+func (al *AccessList) Add(entries ...*AccessListEntry) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	for _, entry := range entries {
+		if rule := newAccessRule(*entry); rule != nil {
+			al.rules[entry.ID] = rule
+		}
+	}
+}
+
+// Remove removes entries from the local list without notifying the cluster.
+func (al *AccessList) Remove(ids ...int64) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	for _, id := range ids {
+		delete(al.rules, id)
+	}
+}
+
+// PublishAdd adds a stored entry, drops the entries it replaced, and tells the cluster.
+func (al *AccessList) PublishAdd(entry *AccessListEntry, replaced []int64) {
+	al.Remove(replaced...)
+	al.Add(entry)
+	al.queueEvent(AccessListEvent{Type: AccessListEventAdd, Entry: *entry, IDs: replaced})
+}
+
+// PublishRemove removes deleted entries and tells the cluster.
+func (al *AccessList) PublishRemove(ids ...int64) {
+	if len(ids) == 0 {
+		return
+	}
+	al.Remove(ids...)
+	al.queueEvent(AccessListEvent{Type: AccessListEventRemove, IDs: ids})
+}
+
+// Entries returns the active entries, ordered by ID.
+func (al *AccessList) Entries() []AccessListEntry {
+	now := time.Now()
+	al.mu.RLock()
+	entries := make([]AccessListEntry, 0, len(al.rules))
+	for _, rule := range al.rules {
+		if !rule.entry.Expired(now) {
+			entries = append(entries, rule.entry)
+		}
+	}
+	al.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// matchIPLocked returns the most specific unexpired network entry containing ip, or
+// nil. Caller must hold mu.
+func (al *AccessList) matchIPLocked(ip net.IP, now time.Time) *AccessListEntry {
+	var best *accessRule
+	bestOnes := -1
+	for _, rule := range al.rules {
+		if rule.network == nil || rule.entry.Username != "" || rule.entry.Expired(now) || !rule.network.Contains(ip) {
+			continue
+		}
+		ones, _ := rule.network.Mask.Size()
+		if ones > bestOnes || (ones == bestOnes && rule.entry.Action == AccessListBlock) {
+			best, bestOnes = rule, ones
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return &best.entry
+}
+
+// BlockingEntry returns the entry blocking ip, or username from ip, or nil when
+// neither is blocked. username may be empty to check the address only.
+func (al *AccessList) BlockingEntry(ipStr, username string) *AccessListEntry {
+	ip := net.ParseIP(ipStr)
+	now := time.Now()
+
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+	if len(al.rules) == 0 {
+		return nil
+	}
+
+	if ip != nil {
+		if entry := al.matchIPLocked(ip, now); entry != nil && entry.Action == AccessListBlock {
+			e := *entry
+			return &e
+		}
+	}
+
+	if username == "" {
+		return nil
+	}
+	username = strings.ToLower(username)
+	for _, rule := range al.rules {
+		if rule.entry.Username != username || rule.entry.Expired(now) {
+			continue
+		}
+		if rule.network == nil || (ip != nil && rule.network.Contains(ip)) {
+			e := rule.entry
+			return &e
+		}
+	}
+	return nil
+}
+
+// IsIPBlocked reports whether ip is blocked by a network entry.
+func (al *AccessList) IsIPBlocked(ipStr string) bool {
+	return al.BlockingEntry(ipStr, "") != nil
+}
+
+// IsIPAllowed reports whether ip is exempted from rate limiting by an allow entry.
+func (al *AccessList) IsIPAllowed(ipStr string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+	entry := al.matchIPLocked(ip, time.Now())
+	return entry != nil && entry.Action == AccessListAllow
+}
+
+// accessListError returns the rate limit error for an access list block on
+// ip/username, or nil when the client is not blocked.
+func accessListError(ip, username string) error {
+	entry := GetAccessList().BlockingEntry(ip, username)
+	if entry == nil {
+		return nil
+	}
+	var until time.Time
+	if entry.ExpiresAt != nil {
+		until = *entry.ExpiresAt
+	}
+	logger.Info("Auth rate limiter: Rejecting authentication (access list)",
+		"ip", ip, "username", username, "entry_id", entry.ID, "cidr", entry.CIDR, "blocked_username", entry.Username)
+	return &RateLimitError{
+		Reason:       "access_list_blocked",
+		IP:           ip,
+		Username:     username,
+		BlockedUntil: until,
+		BaseError:    ErrRateLimitExceeded,
+	}
+}
+
+// nodeID returns this node's cluster ID, or "" without a cluster manager.
+func (al *AccessList) nodeID() string {
+	if al.clusterManager == nil {
+		return ""
+	}
+	return al.clusterManager.GetNodeID()
+}
+
+func (al *AccessList) queueEvent(event AccessListEvent) {
+	if al.clusterManager == nil {
+		return
+	}
+	event.Timestamp = time.Now()
+	event.NodeID = al.nodeID()
+
+	al.queueMu.Lock()
+	defer al.queueMu.Unlock()
+	al.broadcastQueue = append(al.broadcastQueue, event)
+}
+
+// GetBroadcasts returns events to broadcast (called by cluster manager)
+func (al *AccessList) GetBroadcasts(overhead, limit int) [][]byte {
+	al.queueMu.Lock()
+	defer al.queueMu.Unlock()
+
+	if len(al.broadcastQueue) == 0 {
+		return nil
+	}
+
+	broadcasts := make([][]byte, 0, len(al.broadcastQueue))
+	totalSize := 0
+	for i, event := range al.broadcastQueue {
+		encoded, err := encodeAccessListEvent(event)
+		if err != nil {
+			logger.Warn("Access list: Failed to encode event", "error", err)
+			continue
+		}
+
+		msgSize := overhead + len(encoded)
+		if totalSize+msgSize > limit && len(broadcasts) > 0 {
+			// Keep remaining events for next broadcast
+			al.broadcastQueue = al.broadcastQueue[i:]
+			return broadcasts
+		}
+		broadcasts = append(broadcasts, encoded)
+		totalSize += msgSize
+	}
+
+	al.broadcastQueue = al.broadcastQueue[:0]
+	return broadcasts
+}
+
+// HandleClusterEvent processes an access list event from another node
+func (al *AccessList) HandleClusterEvent(data []byte) {
+	var event AccessListEvent
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&event); err != nil {
+		logger.Warn("Access list: Failed to decode cluster event", "error", err)
+		return
+	}
+
+	// Skip events from this node (we already applied them locally)
+	if event.NodeID == al.nodeID() {
+		return
+	}
+
+	// The periodic reload is authoritative for old events
+	if age := time.Since(event.Timestamp); age > 5*time.Minute {
+		logger.Debug("Access list: Ignoring stale cluster event", "node_id", event.NodeID, "age", age)
+		return
+	}
+
+	switch event.Type {
+	case AccessListEventAdd:
+		al.Remove(event.IDs...)
+		al.Add(&event.Entry)
+		logger.Info("Access list: Applied entry from cluster", "id", event.Entry.ID, "action", event.Entry.Action,
+			"cidr", event.Entry.CIDR, "username", event.Entry.Username, "from_node", event.NodeID)
+	case AccessListEventRemove:
+		al.Remove(event.IDs...)
+		logger.Info("Access list: Removed entries from cluster", "ids", event.IDs, "from_node", event.NodeID)
+	default:
+		logger.Warn("Access list: Unknown cluster event type", "type", event.Type)
+	}
+}
+
+func encodeAccessListEvent(event AccessListEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(event); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/migadu/sora/config"
+)
+
+func TestAccessListEntryNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    AccessListEntry
+		wantCIDR string
+		wantUser string
+		wantErr  bool
+	}{
+		{"bare IPv4", AccessListEntry{Action: AccessListBlock, CIDR: " 192.0.2.7 "}, "192.0.2.7/32", "", false},
+		{"bare IPv6", AccessListEntry{Action: AccessListBlock, CIDR: "2001:db8::1"}, "2001:db8::1/128", "", false},
+		{"network", AccessListEntry{Action: AccessListAllow, CIDR: "10.1.2.3/8"}, "10.0.0.0/8", "", false},
+		{"username", AccessListEntry{Action: AccessListBlock, Username: " User@Example.COM"}, "", "user@example.com", false},
+		{"username from network", AccessListEntry{Action: AccessListBlock, CIDR: "198.51.100.0/24", Username: "a@b.c"}, "198.51.100.0/24", "a@b.c", false},
+		{"invalid action", AccessListEntry{Action: "deny", CIDR: "192.0.2.1"}, "", "", true},
+		{"invalid cidr", AccessListEntry{Action: AccessListBlock, CIDR: "192.0.2.300"}, "", "", true},
+		{"empty", AccessListEntry{Action: AccessListBlock}, "", "", true},
+		{"allow username", AccessListEntry{Action: AccessListAllow, CIDR: "192.0.2.1", Username: "a@b.c"}, "", "", true},
+		{"allow without cidr", AccessListEntry{Action: AccessListAllow, Username: "a@b.c"}, "", "", true},
+	}
+	for _, tt := range tests {
+		e := tt.entry
+		err := e.Normalize()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Normalize() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (e.CIDR != tt.wantCIDR || e.Username != tt.wantUser) {
+			t.Errorf("%s: got cidr=%q username=%q, want cidr=%q username=%q", tt.name, e.CIDR, e.Username, tt.wantCIDR, tt.wantUser)
+		}
+	}
+}
+
+func TestAccessListMatching(t *testing.T) {
+	al := &AccessList{rules: make(map[int64]*accessRule)}
+	past := time.Now().Add(-time.Minute)
+	al.Add(
+		&AccessListEntry{ID: 1, Action: AccessListBlock, CIDR: "10.0.0.0/8"},
+		&AccessListEntry{ID: 2, Action: AccessListAllow, CIDR: "10.1.0.0/16"},
+		&AccessListEntry{ID: 3, Action: AccessListBlock, CIDR: "10.1.2.3/32"},
+		&AccessListEntry{ID: 4, Action: AccessListAllow, CIDR: "192.0.2.0/24"},
+		&AccessListEntry{ID: 5, Action: AccessListBlock, CIDR: "192.0.2.0/24"},
+		&AccessListEntry{ID: 6, Action: AccessListBlock, CIDR: "198.51.100.0/24", ExpiresAt: &past},
+		&AccessListEntry{ID: 7, Action: AccessListBlock, Username: "spammer@example.com"},
+		&AccessListEntry{ID: 8, Action: AccessListBlock, CIDR: "203.0.113.0/24", Username: "victim@example.com"},
+	)
+
+	tests := []struct {
+		ip        string
+		blocked   bool
+		allowed   bool
+		wantEntry int64
+	}{
+		{"10.2.0.1", true, false, 1},      // Covered by block only
+		{"10.1.9.9", false, true, 0},      // More specific allow wins
+		{"10.1.2.3", true, false, 3},      // More specific block wins again
+		{"192.0.2.1", true, false, 5},     // Tie goes to block
+		{"198.51.100.1", false, false, 0}, // Expired
+		{"203.0.113.1", false, false, 0},  // Username-scoped entries don't block the address
+		{"not-an-ip", false, false, 0},
+	}
+	for _, tt := range tests {
+		if got := al.IsIPBlocked(tt.ip); got != tt.blocked {
+			t.Errorf("IsIPBlocked(%s) = %v, want %v", tt.ip, got, tt.blocked)
+		}
+		if got := al.IsIPAllowed(tt.ip); got != tt.allowed {
+			t.Errorf("IsIPAllowed(%s) = %v, want %v", tt.ip, got, tt.allowed)
+		}
+		if entry := al.BlockingEntry(tt.ip, ""); tt.blocked && (entry == nil || entry.ID != tt.wantEntry) {
+			t.Errorf("BlockingEntry(%s) = %+v, want ID %d", tt.ip, entry, tt.wantEntry)
+		}
+	}
+
+	if entry := al.BlockingEntry("10.1.9.9", "Spammer@Example.com"); entry == nil || entry.ID != 7 {
+		t.Errorf("username block should apply from allowed network, got %+v", entry)
+	}
+	if entry := al.BlockingEntry("203.0.113.5", "victim@example.com"); entry == nil || entry.ID != 8 {
+		t.Errorf("username block should apply from its network, got %+v", entry)
+	}
+	if entry := al.BlockingEntry("172.16.0.1", "victim@example.com"); entry != nil {
+		t.Errorf("username block should not apply outside its network, got %+v", entry)
+	}
+
+	if got := len(al.Entries()); got != 7 {
+		t.Errorf("Entries() returned %d entries, want 7 (expired excluded)", got)
+	}
+	al.Remove(1, 3)
+	if al.IsIPBlocked("10.2.0.1") {
+		t.Error("10.2.0.1 should not be blocked after removing its entry")
+	}
+}
+
+func TestAccessListClusterEvent(t *testing.T) {
+	al := &AccessList{rules: make(map[int64]*accessRule)}
+	al.Add(&AccessListEntry{ID: 1, Action: AccessListBlock, CIDR: "192.0.2.1/32"})
+
+	// Without a cluster manager nothing is queued
+	al.PublishRemove(1)
+	if broadcasts := al.GetBroadcasts(0, 1400); broadcasts != nil {
+		t.Errorf("expected no broadcasts without cluster, got %d", len(broadcasts))
+	}
+
+	event := AccessListEvent{
+		Type:      AccessListEventAdd,
+		Entry:     AccessListEntry{ID: 2, Action: AccessListBlock, CIDR: "192.0.2.2/32"},
+		IDs:       []int64{1},
+		Timestamp: time.Now(),
+		NodeID:    "other-node",
+	}
+	data, err := encodeAccessListEvent(event)
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	al.HandleClusterEvent(data)
+	if !al.IsIPBlocked("192.0.2.2") {
+		t.Error("entry from cluster event should be applied")
+	}
+
+	event = AccessListEvent{Type: AccessListEventRemove, IDs: []int64{2}, Timestamp: time.Now().Add(-10 * time.Minute), NodeID: "other-node"}
+	data, _ = encodeAccessListEvent(event)
+	al.HandleClusterEvent(data)
+	if !al.IsIPBlocked("192.0.2.2") {
+		t.Error("stale cluster event should be ignored")
+	}
+
+	event.Timestamp = time.Now()
+	data, _ = encodeAccessListEvent(event)
+	al.HandleClusterEvent(data)
+	if al.IsIPBlocked("192.0.2.2") {
+		t.Error("remove event should be applied")
+	}
+}
+
+func TestAuthRateLimiterAccessList(t *testing.T) {
+	al := GetAccessList()
+	al.Add(
+		&AccessListEntry{ID: 9001, Action: AccessListBlock, CIDR: "192.0.2.10/32"},
+		&AccessListEntry{ID: 9002, Action: AccessListBlock, Username: "blocked@example.com"},
+		&AccessListEntry{ID: 9003, Action: AccessListAllow, CIDR: "198.51.100.0/24"},
+	)
+	defer al.Remove(9001, 9002, 9003)
+
+	ctx := context.Background()
+	blockedAddr := &StringAddr{Addr: "192.0.2.10:1234"}
+	otherAddr := &StringAddr{Addr: "192.0.2.11:1234"}
+
+	// Access list blocks apply even when rate limiting is disabled
+	var disabled *AuthRateLimiter
+	if err := disabled.CanAttemptAuth(ctx, blockedAddr, "user@example.com"); !errors.Is(err, ErrRateLimitExceeded) {
+		t.Errorf("blocked IP with nil limiter: err = %v, want ErrRateLimitExceeded", err)
+	}
+	if err := disabled.CanAttemptAuth(ctx, otherAddr, "blocked@example.com"); err == nil {
+		t.Error("blocked username with nil limiter should be rejected")
+	}
+	if err := disabled.CanAttemptAuth(ctx, otherAddr, "user@example.com"); err != nil {
+		t.Errorf("unlisted client with nil limiter: %v", err)
+	}
+	if !disabled.IsIPBlocked(blockedAddr) {
+		t.Error("IsIPBlocked should report access list blocks with nil limiter")
+	}
+
+	// Allowed networks are exempt from automatic blocking
+	limiter := NewAuthRateLimiter("imap", "", "", config.AuthRateLimiterConfig{
+		Enabled:          true,
+		MaxAttemptsPerIP: 2,
+		IPBlockDuration:  5 * time.Minute,
+		IPWindowDuration: 15 * time.Minute,
+		CleanupInterval:  time.Minute,
+	})
+	defer limiter.Stop()
+
+	allowedAddr := &StringAddr{Addr: "198.51.100.5:1234"}
+	for range 3 {
+		limiter.RecordAuthAttempt(ctx, allowedAddr, "user@example.com", false)
+	}
+	if err := limiter.CanAttemptAuth(ctx, allowedAddr, "user@example.com"); err != nil {
+		t.Errorf("allowed network should not be rate limited: %v", err)
+	}
+}
+
+func TestAuthRateLimiterUnblock(t *testing.T) {
+	limiter := NewAuthRateLimiter("imap", "", "", config.AuthRateLimiterConfig{
+		Enabled:                  true,
+		MaxAttemptsPerIP:         2,
+		MaxAttemptsPerIPUsername: 2,
+		IPBlockDuration:          5 * time.Minute,
+		IPUsernameBlockDuration:  5 * time.Minute,
+		IPWindowDuration:         15 * time.Minute,
+		IPUsernameWindowDuration: 15 * time.Minute,
+		CleanupInterval:          time.Minute,
+	})
+	defer limiter.Stop()
+
+	ctx := context.Background()
+	addr := &StringAddr{Addr: "192.0.2.20:1234"}
+	for range 2 {
+		limiter.RecordAuthAttempt(ctx, addr, "user@example.com", false)
+	}
+	if err := limiter.CanAttemptAuth(ctx, addr, "user@example.com"); err == nil {
+		t.Fatal("expected client to be blocked after 2 failures")
+	}
+
+	if lifted := limiter.Unblock("192.0.2.99", ""); lifted != 0 {
+		t.Errorf("unblocking an unrelated IP lifted %d blocks", lifted)
+	}
+	if lifted := limiter.Unblock("192.0.2.20", ""); lifted == 0 {
+		t.Error("expected blocks to be lifted")
+	}
+	if err := limiter.CanAttemptAuth(ctx, addr, "user@example.com"); err != nil {
+		t.Errorf("client should be unblocked: %v", err)
+	}
+
+	var disabled *AuthRateLimiter
+	if lifted := disabled.Unblock("192.0.2.20", ""); lifted != 0 {
+		t.Errorf("nil limiter lifted %d blocks", lifted)
+	}
+}
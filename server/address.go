@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // RFC 5321/5322 compliant email validation regex (dot-string local part).
@@ -14,7 +18,11 @@ import (
 // plus ALPHA and DIGIT. In particular "/" and "`" (\x60) must be allowed:
 // many ESPs use base64/VERP-style reverse-paths (e.g. Constant Contact) whose
 // local part contains "/", and rejecting them bounces legitimate mail.
-const LocalPartRegex = `^(?i)(?:[a-z0-9!#$%&'*+/=?^_\x60\{\|\}~-])+(?:\.(?:[a-z0-9!#$%&'*+/=?^_\x60\{\|\}~-])+)*$`
+//
+// Non-ASCII characters are atext as well (RFC 6531 §3.3), for SMTPUTF8
+// addresses. NewAddress additionally rejects invalid UTF-8 and non-ASCII
+// spaces and control characters, and normalizes the local part to NFC.
+const LocalPartRegex = `^(?i)(?:[a-z0-9!#$%&'*+/=?^_\x60\{\|\}~\x{80}-\x{10FFFF}-])+(?:\.(?:[a-z0-9!#$%&'*+/=?^_\x60\{\|\}~\x{80}-\x{10FFFF}-])+)*$`
 const DomainNameRegex = `^(?i)(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z0-9](?:[a-z0-9-]*[a-z0-9])?$`
 
 // Precompiled once at package init — NewAddress is on the hot path for every
@@ -34,6 +42,25 @@ const (
 	MaxAddressLength   = 512 // Maximum total length (256 + 1 + 255)
 )
 
+// IsASCII reports whether s is plain ASCII. Addresses that are not need the
+// SMTPUTF8 extension (RFC 6531) to be sent.
+func IsASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// validUTF8LocalPart rejects local parts with non-ASCII spaces, control or
+// format characters, which the regex lets through.
+func validUTF8LocalPart(localPart string) bool {
+	return strings.IndexFunc(localPart, func(r rune) bool {
+		return r >= utf8.RuneSelf && (unicode.IsSpace(r) || unicode.IsControl(r) || unicode.Is(unicode.Cf, r))
+	}) == -1
+}
+
 // Separator constant for master authentication
 const (
 	// SuffixSeparator is used for master username and remotelookup tokens
@@ -131,6 +158,11 @@ func NewAddress(input string) (Address, error) {
 		return Address{}, fmt.Errorf("address is empty")
 	}
 
+	// Before lowercasing, which would turn invalid bytes into U+FFFD
+	if !utf8.ValidString(input) {
+		return Address{}, fmt.Errorf("address is not valid UTF-8: '%s'", input)
+	}
+
 	// Parse suffix BEFORE lowercasing (to preserve case for master username/token)
 	// Format: localpart@domain or localpart@domain@SUFFIX (where SUFFIX may contain @)
 	var suffix string
@@ -177,9 +209,15 @@ func NewAddress(input string) (Address, error) {
 	}
 
 	// Validate local part format
-	if !localPartRe.MatchString(localPart) {
+	if !localPartRe.MatchString(localPart) || !validUTF8LocalPart(localPart) {
 		return Address{}, fmt.Errorf("unacceptable local part: '%s'", localPart)
 	}
+	// Compare UTF-8 local parts in one normalization form, so credentials
+	// match whichever form the sending MTA used
+	if !IsASCII(localPart) {
+		localPart = norm.NFC.String(localPart)
+		emailPart = localPart + "@" + domain
+	}
 
 	// Validate domain length (RFC 5321)
 	if len(domain) > MaxDomainLength {
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestUTF8LocalParts(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		wantFullAddress string
+		wantDetail      string
+		wantErr         bool
+	}{
+		{
+			name:            "accented local part",
+			input:           "josé@example.com",
+			wantFullAddress: "josé@example.com",
+		},
+		{
+			name:            "non-latin local part with detail",
+			input:           "用户+收据@example.com",
+			wantFullAddress: "用户+收据@example.com",
+			wantDetail:      "收据",
+		},
+		{
+			name:            "uppercase is folded",
+			input:           "ÜBER@Example.com",
+			wantFullAddress: "über@example.com",
+		},
+		{
+			name:            "decomposed form is normalized to NFC",
+			input:           "josé@example.com",
+			wantFullAddress: "josé@example.com",
+		},
+		{
+			name:    "invalid UTF-8",
+			input:   "us\xffer@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "non-breaking space",
+			input:   "us er@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "zero-width joiner",
+			input:   "us‍er@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "C1 control character",
+			input:   "us\u0085er@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "non-ASCII domain is not accepted",
+			input:   "user@exämple.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := NewAddress(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("NewAddress(%q) = %q, want error", tt.input, addr.FullAddress())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewAddress(%q) error = %v", tt.input, err)
+			}
+			if addr.FullAddress() != tt.wantFullAddress {
+				t.Errorf("FullAddress() = %q, want %q", addr.FullAddress(), tt.wantFullAddress)
+			}
+			if addr.Detail() != tt.wantDetail {
+				t.Errorf("Detail() = %q, want %q", addr.Detail(), tt.wantDetail)
+			}
+			if IsASCII(addr.FullAddress()) {
+				t.Errorf("IsASCII(%q) = true", addr.FullAddress())
+			}
+		})
+	}
+
+	if !IsASCII("user+tag@example.com") {
+		t.Error("IsASCII reported an ASCII address as non-ASCII")
+	}
+}
@@ -0,0 +1,344 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server"
+)
+
+// AccessListRequest is the body of POST /admin/access-list
+type AccessListRequest struct {
+	Action    string `json:"action"`             // "block" or "allow"
+	CIDR      string `json:"cidr,omitempty"`     // Network or bare IP
+	Username  string `json:"username,omitempty"` // Block only
+	Reason    string `json:"reason,omitempty"`
+	ExpiresIn string `json:"expires_in,omitempty"` // Go duration, e.g. "24h"
+	ExpiresAt string `json:"expires_at,omitempty"` // RFC3339; ignored when expires_in is set
+}
+
+// Export formats for GET /admin/access-list/export
+const (
+	exportFormatPlain    = "plain"
+	exportFormatNftables = "nftables"
+)
+
+// handleAccessList handles GET and POST /admin/access-list
+func (s *Server) handleAccessList(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		s.handleAccessListGet(w, r)
+		return
+	}
+
+	var req AccessListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+
+	entry := &server.AccessListEntry{
+		Action:   req.Action,
+		CIDR:     req.CIDR,
+		Username: req.Username,
+		Reason:   req.Reason,
+	}
+	if actor, ok := db.AuditActorFromContext(r.Context()); ok {
+		entry.CreatedBy = actor.Actor
+	}
+	switch {
+	case req.ExpiresIn != "":
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			s.writeError(w, http.StatusBadRequest, "expires_in must be a positive duration, e.g. 24h")
+			return
+		}
+		expiresAt := time.Now().Add(d)
+		entry.ExpiresAt = &expiresAt
+	case req.ExpiresAt != "":
+		expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil || !expiresAt.After(time.Now()) {
+			s.writeError(w, http.StatusBadRequest, "expires_at must be a future RFC3339 time")
+			return
+		}
+		entry.ExpiresAt = &expiresAt
+	}
+	if err := entry.Normalize(); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	added, replaced, err := s.rdb.AddAccessListEntryWithRetry(r.Context(), entry)
+	if err != nil {
+		logger.Warn("HTTP API: Error adding access list entry", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to add access list entry")
+		return
+	}
+	server.GetAccessList().PublishAdd(added, replaced)
+
+	s.audit(r, db.AuditAccessListAdd, accessListTarget(added), map[string]any{
+		"id":         added.ID,
+		"action":     added.Action,
+		"reason":     added.Reason,
+		"expires_at": added.ExpiresAt,
+	})
+	s.writeJSON(w, http.StatusCreated, added)
+}
+
+// handleAccessListGet handles GET /admin/access-list with optional action and
+// include_expired query parameters.
+func (s *Server) handleAccessListGet(w http.ResponseWriter, r *http.Request) {
+	action := r.URL.Query().Get("action")
+	includeExpired := r.URL.Query().Get("include_expired") == "true"
+
+	entries, err := s.rdb.ListAccessListEntriesWithRetry(r.Context(), includeExpired)
+	if err != nil {
+		logger.Warn("HTTP API: Error listing access list", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to list access list")
+		return
+	}
+	if action != "" {
+		filtered := make([]*server.AccessListEntry, 0, len(entries))
+		for _, e := range entries {
+			if e.Action == action {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// handleAccessListOperations handles DELETE /admin/access-list/{id} and
+// GET /admin/access-list/export
+func (s *Server) handleAccessListOperations(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/admin/access-list/")
+
+	if rest == "export" {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleAccessListExport(w, r)
+		return
+	}
+
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil || id <= 0 {
+		s.writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entry, err := s.rdb.DeleteAccessListEntryWithRetry(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			s.writeError(w, http.StatusNotFound, "Access list entry not found")
+			return
+		}
+		logger.Warn("HTTP API: Error deleting access list entry", "name", s.name, "id", id, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to delete access list entry")
+		return
+	}
+	server.GetAccessList().PublishRemove(entry.ID)
+
+	s.audit(r, db.AuditAccessListRemove, accessListTarget(entry), map[string]any{
+		"id":     entry.ID,
+		"action": entry.Action,
+	})
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"success": true,
+		"entry":   entry,
+	})
+}
+
+// handleAccessListExport handles GET /admin/access-list/export. Only network
+// entries are exported; username blocks cannot be enforced by a firewall.
+func (s *Server) handleAccessListExport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = exportFormatPlain
+	}
+	if format != exportFormatPlain && format != exportFormatNftables {
+		s.writeError(w, http.StatusBadRequest, "format must be 'plain' or 'nftables'")
+		return
+	}
+	action := q.Get("action")
+	if action == "" {
+		action = server.AccessListBlock
+	}
+	if action != server.AccessListBlock && action != server.AccessListAllow {
+		s.writeError(w, http.StatusBadRequest, "action must be 'block' or 'allow'")
+		return
+	}
+
+	entries, err := s.rdb.ListAccessListEntriesWithRetry(r.Context(), false)
+	if err != nil {
+		logger.Warn("HTTP API: Error listing access list", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to list access list")
+		return
+	}
+
+	var body string
+	if format == exportFormatNftables {
+		table := q.Get("table")
+		if table == "" {
+			table = "sora"
+		}
+		set := q.Get("set")
+		if set == "" {
+			set = "sora_" + action
+		}
+		if !isNftIdentifier(table) || !isNftIdentifier(set) {
+			s.writeError(w, http.StatusBadRequest, "table and set must be letters, digits and underscores")
+			return
+		}
+		body = formatNftablesExport(entries, action, table, set)
+	} else {
+		body = formatPlainExport(entries, action)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(body)); err != nil {
+		logger.Warn("HTTP API: Error writing access list export", "name", s.name, "error", err)
+	}
+}
+
+// handleAuthUnblock handles DELETE /admin/auth/blocked?ip=...&username=...
+// It lifts automatic rate limiter blocks across the cluster and removes access
+// list block entries for exactly that IP and/or username.
+func (s *Server) handleAuthUnblock(w http.ResponseWriter, r *http.Request) {
+	ip := strings.TrimSpace(r.URL.Query().Get("ip"))
+	username := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("username")))
+	if ip == "" && username == "" {
+		s.writeError(w, http.StatusBadRequest, "ip or username query parameter is required")
+		return
+	}
+	if ip != "" && net.ParseIP(ip) == nil {
+		if _, _, err := net.ParseCIDR(ip); err != nil {
+			s.writeError(w, http.StatusBadRequest, "ip must be an IP address or CIDR")
+			return
+		}
+	}
+
+	// Rate limiter state is keyed by exact IP, so a CIDR only matches access list entries
+	lifted := 0
+	if net.ParseIP(ip) != nil || ip == "" {
+		lifted = server.UnblockAuth(ip, username)
+	}
+
+	removed, err := s.rdb.DeleteAccessListBlocksWithRetry(r.Context(), ip, username)
+	if err != nil {
+		logger.Warn("HTTP API: Error removing access list blocks", "name", s.name, "ip", ip, "username", username, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to remove access list blocks")
+		return
+	}
+	server.GetAccessList().PublishRemove(removed...)
+
+	s.audit(r, db.AuditAuthUnblock, strings.TrimSpace(ip+" "+username), map[string]any{
+		"rate_limit_blocks_lifted": lifted,
+		"access_list_removed":      removed,
+	})
+	if removed == nil {
+		removed = []int64{}
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"success":                  true,
+		"ip":                       ip,
+		"username":                 username,
+		"rate_limit_blocks_lifted": lifted,
+		"access_list_removed":      removed,
+	})
+}
+
+// accessListTarget returns the audit target of an entry: its network and/or username.
+func accessListTarget(e *server.AccessListEntry) string {
+	return strings.TrimSpace(e.CIDR + " " + e.Username)
+}
+
+// exportNetworks returns the networks of entries with the given action, split by family.
+func exportNetworks(entries []*server.AccessListEntry, action string) (v4, v6 []string) {
+	for _, e := range entries {
+		if e.Action != action || e.CIDR == "" || e.Username != "" {
+			continue
+		}
+		ip, _, err := net.ParseCIDR(e.CIDR)
+		if err != nil {
+			continue
+		}
+		if ip.To4() != nil {
+			v4 = append(v4, e.CIDR)
+		} else {
+			v6 = append(v6, e.CIDR)
+		}
+	}
+	return v4, v6
+}
+
+// formatPlainExport returns one CIDR per line.
+func formatPlainExport(entries []*server.AccessListEntry, action string) string {
+	v4, v6 := exportNetworks(entries, action)
+	var b strings.Builder
+	for _, cidr := range append(v4, v6...) {
+		b.WriteString(cidr)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// formatNftablesExport returns an nft script defining <set>_v4 and <set>_v6
+// interval sets in the inet table. Loading it with `nft -f` replaces the sets'
+// contents, so it can be re-applied periodically.
+func formatNftablesExport(entries []*server.AccessListEntry, action, table, set string) string {
+	v4, v6 := exportNetworks(entries, action)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# sora access list (%s), generated %s\n", action, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "table inet %s {\n}\n", table)
+	for _, family := range []struct {
+		suffix, addrType string
+		networks         []string
+	}{
+		{"v4", "ipv4_addr", v4},
+		{"v6", "ipv6_addr", v6},
+	} {
+		name := set + "_" + family.suffix
+		// Declare the set (a no-op if it exists), then replace its contents
+		fmt.Fprintf(&b, "add set inet %s %s { type %s; flags interval; auto-merge; }\n", table, name, family.addrType)
+		fmt.Fprintf(&b, "flush set inet %s %s\n", table, name)
+		if len(family.networks) > 0 {
+			fmt.Fprintf(&b, "add element inet %s %s { %s }\n", table, name, strings.Join(family.networks, ", "))
+		}
+	}
+	return b.String()
+}
+
+// isNftIdentifier reports whether s is safe to use as an nftables table or set name.
+func isNftIdentifier(s string) bool {
+	if s == "" || len(s) > 64 {
+		return false
+	}
+	for _, c := range s {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
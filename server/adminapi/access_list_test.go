@@ -0,0 +1,120 @@
+package adminapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/migadu/sora/server"
+)
+
+func testAccessListEntries() []*server.AccessListEntry {
+	return []*server.AccessListEntry{
+		{ID: 1, Action: server.AccessListBlock, CIDR: "192.0.2.0/24"},
+		{ID: 2, Action: server.AccessListBlock, CIDR: "2001:db8::/32"},
+		{ID: 3, Action: server.AccessListBlock, Username: "spammer@example.com"},
+		{ID: 4, Action: server.AccessListBlock, CIDR: "198.51.100.7/32", Username: "victim@example.com"},
+		{ID: 5, Action: server.AccessListAllow, CIDR: "10.0.0.0/8"},
+	}
+}
+
+func TestFormatPlainExport(t *testing.T) {
+	entries := testAccessListEntries()
+
+	if got, want := formatPlainExport(entries, server.AccessListBlock), "192.0.2.0/24\n2001:db8::/32\n"; got != want {
+		t.Errorf("block export = %q, want %q", got, want)
+	}
+	if got, want := formatPlainExport(entries, server.AccessListAllow), "10.0.0.0/8\n"; got != want {
+		t.Errorf("allow export = %q, want %q", got, want)
+	}
+	if got := formatPlainExport(nil, server.AccessListBlock); got != "" {
+		t.Errorf("empty export = %q, want empty", got)
+	}
+}
+
+func TestFormatNftablesExport(t *testing.T) {
+	got := formatNftablesExport(testAccessListEntries(), server.AccessListBlock, "filter", "blocked")
+
+	for _, want := range []string{
+		"table inet filter {\n}\n",
+		"add set inet filter blocked_v4 { type ipv4_addr; flags interval; auto-merge; }\n",
+		"flush set inet filter blocked_v4\n",
+		"add element inet filter blocked_v4 { 192.0.2.0/24 }\n",
+		"add set inet filter blocked_v6 { type ipv6_addr; flags interval; auto-merge; }\n",
+		"flush set inet filter blocked_v6\n",
+		"add element inet filter blocked_v6 { 2001:db8::/32 }\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("nftables export missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "198.51.100.7") {
+		t.Errorf("username-scoped block should not be exported:\n%s", got)
+	}
+
+	// Empty sets are still declared and flushed so stale elements are removed
+	got = formatNftablesExport(nil, server.AccessListBlock, "sora", "sora_block")
+	if !strings.Contains(got, "flush set inet sora sora_block_v4\n") || strings.Contains(got, "add element") {
+		t.Errorf("unexpected empty nftables export:\n%s", got)
+	}
+}
+
+func TestIsNftIdentifier(t *testing.T) {
+	tests := map[string]bool{
+		"sora":                  true,
+		"sora_block_2":          true,
+		"":                      false,
+		"bad name":              false,
+		"x;flush":               false,
+		"a}":                    false,
+		strings.Repeat("a", 65): false,
+	}
+	for s, want := range tests {
+		if got := isNftIdentifier(s); got != want {
+			t.Errorf("isNftIdentifier(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestAuthUnblockValidation(t *testing.T) {
+	s := &Server{apiKey: "test-api-key"}
+	for _, path := range []string{
+		"/admin/auth/blocked",
+		"/admin/auth/blocked?ip=not-an-ip",
+	} {
+		rr := httptest.NewRecorder()
+		s.handleAuthUnblock(rr, httptest.NewRequest("DELETE", path, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %v, want %v", path, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAccessListValidation(t *testing.T) {
+	s := &Server{apiKey: "test-api-key"}
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid json", `{`},
+		{"invalid action", `{"action":"deny","cidr":"192.0.2.1"}`},
+		{"invalid cidr", `{"action":"block","cidr":"192.0.2.300"}`},
+		{"allow username", `{"action":"allow","username":"a@example.com"}`},
+		{"invalid expires_in", `{"action":"block","cidr":"192.0.2.1","expires_in":"-1h"}`},
+		{"past expires_at", `{"action":"block","cidr":"192.0.2.1","expires_at":"2000-01-01T00:00:00Z"}`},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		s.handleAccessList(rr, httptest.NewRequest("POST", "/admin/access-list", strings.NewReader(tt.body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %v, want %v (%s)", tt.name, rr.Code, http.StatusBadRequest, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	s.handleAccessListOperations(rr, httptest.NewRequest("DELETE", "/admin/access-list/abc", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("non-numeric id: status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
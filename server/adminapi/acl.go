@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/migadu/sora/db"
 	"github.com/migadu/sora/logger"
 	"github.com/migadu/sora/server/aclservice"
 )
@@ -56,6 +57,10 @@ func (s *Server) handleACLGrant(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.authorizeAddress(w, r, req.Owner) {
+		return
+	}
+
 	// Create ACL service
 	aclSvc := aclservice.New(s.rdb)
 
@@ -65,6 +70,11 @@ func (s *Server) handleACLGrant(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusInternalServerError, "Failed to grant ACL")
 		return
 	}
+	s.audit(r, db.AuditACLGrant, req.Owner, map[string]any{
+		"mailbox":    req.Mailbox,
+		"identifier": req.Identifier,
+		"rights":     req.Rights,
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -100,6 +110,10 @@ func (s *Server) handleACLRevoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.authorizeAddress(w, r, req.Owner) {
+		return
+	}
+
 	// Create ACL service
 	aclSvc := aclservice.New(s.rdb)
 
@@ -109,6 +123,10 @@ func (s *Server) handleACLRevoke(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusInternalServerError, "Failed to revoke ACL")
 		return
 	}
+	s.audit(r, db.AuditACLRevoke, req.Owner, map[string]any{
+		"mailbox":    req.Mailbox,
+		"identifier": req.Identifier,
+	})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -137,6 +155,10 @@ func (s *Server) handleACLList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.authorizeAddress(w, r, owner) {
+		return
+	}
+
 	// Create ACL service
 	aclSvc := aclservice.New(s.rdb)
 
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+
+	"github.com/migadu/sora/db"
 )
 
 // AffinitySetRequest represents a request to set user affinity
@@ -35,6 +37,9 @@ func (s *Server) handleAffinitySet(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error": "user, protocol, and backend are required"}`, http.StatusBadRequest)
 		return
 	}
+	if !s.authorizeAddress(w, r, req.User) {
+		return
+	}
 
 	// Validate protocol
 	req.Protocol = strings.ToLower(req.Protocol)
@@ -69,7 +74,13 @@ func (s *Server) handleAffinitySet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set affinity (this will gossip to all nodes in the cluster)
+	previous, _ := s.affinityManager.GetBackend(req.User, req.Protocol)
 	s.affinityManager.SetBackend(req.User, req.Backend, req.Protocol)
+	s.audit(r, db.AuditAffinitySet, req.User, map[string]any{
+		"protocol": req.Protocol,
+		"before":   previous,
+		"after":    req.Backend,
+	})
 
 	// Return success
 	w.Header().Set("Content-Type", "application/json")
@@ -91,6 +102,9 @@ func (s *Server) handleAffinityGet(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error": "user and protocol query parameters are required"}`, http.StatusBadRequest)
 		return
 	}
+	if !s.authorizeAddress(w, r, user) {
+		return
+	}
 
 	// Check if affinity manager is available
 	if s.affinityManager == nil {
@@ -125,6 +139,9 @@ func (s *Server) handleAffinityDelete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `{"error": "user and protocol query parameters are required"}`, http.StatusBadRequest)
 		return
 	}
+	if !s.authorizeAddress(w, r, user) {
+		return
+	}
 
 	// Check if affinity manager is available
 	if s.affinityManager == nil {
@@ -133,7 +150,12 @@ func (s *Server) handleAffinityDelete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete affinity (this will gossip to all nodes in the cluster)
+	previous, _ := s.affinityManager.GetBackend(user, protocol)
 	s.affinityManager.DeleteBackend(user, protocol)
+	s.audit(r, db.AuditAffinityDelete, user, map[string]any{
+		"protocol": protocol,
+		"before":   previous,
+	})
 
 	// Return success
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,316 @@
+package adminapi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/migadu/sora/config"
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/resilient"
+)
+
+// API key scopes. A key holding ScopeAll may call every endpoint. ScopeRead
+// grants every GET endpoint; the other scopes grant both reads and writes in
+// their area.
+const (
+	ScopeAll         = "*"
+	ScopeRead        = "read"
+	ScopeAccounts    = "accounts"    // accounts, credentials, messages, mailboxes, ACLs, Sieve
+	ScopeConnections = "connections" // connections, kicks, affinity
+	ScopeCache       = "cache"       // cache, uploader, auth blocks and access list, health stats
+	ScopeDelivery    = "delivery"    // mail injection, relay queue
+)
+
+// Scopes lists the valid scope names, for validation and help output.
+var Scopes = []string{ScopeAll, ScopeRead, ScopeAccounts, ScopeConnections, ScopeCache, ScopeDelivery}
+
+// APIKeyPrefix marks keys generated by GenerateAPIKey.
+const APIKeyPrefix = "sora_"
+
+// apiKeyCacheTTL bounds how long a database key lookup (hit or miss) is
+// reused, so a revoked key stops working within this time.
+const apiKeyCacheTTL = 30 * time.Second
+
+// ValidateScopes returns an error for an empty list or an unknown scope.
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, sc := range scopes {
+		valid := false
+		for _, known := range Scopes {
+			if sc == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("unknown scope %q (valid: %s)", sc, strings.Join(Scopes, ", "))
+		}
+	}
+	return nil
+}
+
+// GenerateAPIKey returns a new random key, the short prefix shown in listings,
+// and the hash to store.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	key = APIKeyPrefix + hex.EncodeToString(buf)
+	return key, key[:len(APIKeyPrefix)+8], HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hex SHA-256 of key. Keys are long random strings, so
+// a fast unsalted hash is enough to keep them out of the database and config.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeKeyHash accepts a config key_hash with or without a "sha256:" prefix.
+func normalizeKeyHash(h string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(h), "sha256:"))
+}
+
+// apiPrincipal is the authenticated caller of a request.
+type apiPrincipal struct {
+	name    string
+	scopes  map[string]bool
+	domains []string // lowercased; empty means every domain
+}
+
+func newAPIPrincipal(name string, scopes, domains []string) *apiPrincipal {
+	p := &apiPrincipal{name: name, scopes: make(map[string]bool, len(scopes))}
+	for _, sc := range scopes {
+		p.scopes[sc] = true
+	}
+	for _, d := range domains {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			p.domains = append(p.domains, d)
+		}
+	}
+	return p
+}
+
+// fullAccessPrincipal is used for the legacy api_key.
+var fullAccessPrincipal = newAPIPrincipal("api_key", []string{ScopeAll}, nil)
+
+// allows reports whether the principal may call an endpoint in scope with method.
+func (p *apiPrincipal) allows(scope, method string) bool {
+	if p.scopes[ScopeAll] || p.scopes[scope] {
+		return true
+	}
+	return p.scopes[ScopeRead] && (method == http.MethodGet || method == http.MethodHead)
+}
+
+// restricted reports whether the principal is limited to some domains.
+func (p *apiPrincipal) restricted() bool {
+	return len(p.domains) > 0
+}
+
+func (p *apiPrincipal) allowsDomain(domain string) bool {
+	if !p.restricted() {
+		return true
+	}
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	for _, d := range p.domains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *apiPrincipal) allowsAddress(address string) bool {
+	if !p.restricted() {
+		return true
+	}
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return false
+	}
+	return p.allowsDomain(address[at+1:])
+}
+
+type principalContextKey struct{}
+
+func withPrincipal(ctx context.Context, p *apiPrincipal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+func principalFromContext(ctx context.Context) *apiPrincipal {
+	p, _ := ctx.Value(principalContextKey{}).(*apiPrincipal)
+	return p
+}
+
+type cachedAPIKey struct {
+	principal *apiPrincipal // nil for a cached miss
+	expires   time.Time
+}
+
+// apiKeyStore resolves bearer tokens to principals from the config file and
+// the admin_api_keys table.
+type apiKeyStore struct {
+	rdb        *resilient.ResilientDatabase
+	configKeys map[string]*apiPrincipal // key hash -> principal
+
+	mu    sync.Mutex
+	cache map[string]cachedAPIKey
+}
+
+func newAPIKeyStore(rdb *resilient.ResilientDatabase, keys []config.AdminAPIKeyConfig) (*apiKeyStore, error) {
+	configKeys := make(map[string]*apiPrincipal, len(keys))
+	for _, k := range keys {
+		if k.Name == "" {
+			return nil, fmt.Errorf("api_keys entry is missing a name")
+		}
+		hash := normalizeKeyHash(k.KeyHash)
+		if len(hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("api key %q: key_hash must be a hex SHA-256", k.Name)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("api key %q: key_hash must be a hex SHA-256", k.Name)
+		}
+		if err := ValidateScopes(k.Scopes); err != nil {
+			return nil, fmt.Errorf("api key %q: %w", k.Name, err)
+		}
+		configKeys[hash] = newAPIPrincipal(k.Name, k.Scopes, k.Domains)
+	}
+	return &apiKeyStore{
+		rdb:        rdb,
+		configKeys: configKeys,
+		cache:      make(map[string]cachedAPIKey),
+	}, nil
+}
+
+// lookup returns the principal for token, nil for an unknown token, or an
+// error if the database could not be asked.
+func (ks *apiKeyStore) lookup(ctx context.Context, token string) (*apiPrincipal, error) {
+	hash := HashAPIKey(token)
+	if p, ok := ks.configKeys[hash]; ok {
+		return p, nil
+	}
+	if ks.rdb == nil {
+		return nil, nil
+	}
+
+	ks.mu.Lock()
+	if c, ok := ks.cache[hash]; ok && time.Now().Before(c.expires) {
+		ks.mu.Unlock()
+		return c.principal, nil
+	}
+	ks.mu.Unlock()
+
+	key, err := ks.rdb.GetAdminAPIKeyByHashWithRetry(ctx, hash)
+	if err != nil && !errors.Is(err, consts.ErrDBNotFound) {
+		return nil, err
+	}
+
+	var p *apiPrincipal
+	if key != nil {
+		p = newAPIPrincipal(key.Name, key.Scopes, key.Domains)
+		id := key.ID
+		go func() {
+			touchCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := ks.rdb.TouchAdminAPIKeyWithRetry(touchCtx, id); err != nil {
+				logger.Debug("HTTP API: Failed to record API key use", "key", key.Name, "error", err)
+			}
+		}()
+	}
+
+	ks.mu.Lock()
+	// Drop expired entries, and stop caching misses when full, so random
+	// tokens cannot grow the cache without bound.
+	if len(ks.cache) >= 1024 {
+		now := time.Now()
+		for h, c := range ks.cache {
+			if now.After(c.expires) {
+				delete(ks.cache, h)
+			}
+		}
+	}
+	if p != nil || len(ks.cache) < 1024 {
+		ks.cache[hash] = cachedAPIKey{principal: p, expires: time.Now().Add(apiKeyCacheTTL)}
+	}
+	ks.mu.Unlock()
+	return p, nil
+}
+
+// authenticate resolves a bearer token. The legacy api_key is checked first in
+// constant time and grants full access.
+func (s *Server) authenticate(ctx context.Context, token string) (*apiPrincipal, error) {
+	if s.apiKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.apiKey)) == 1 {
+		return fullAccessPrincipal, nil
+	}
+	apiKeys := s.apiKeys.Load()
+	if apiKeys == nil {
+		return nil, nil
+	}
+	return apiKeys.lookup(ctx, token)
+}
+
+// requireScope wraps a handler for an endpoint that acts on individual
+// accounts or domains. Domain-restricted keys may call it; the handler checks
+// the addresses it touches with authorizeAddress or authorizeDomain.
+func (s *Server) requireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := principalFromContext(r.Context())
+		if p == nil || !p.allows(scope, r.Method) {
+			s.writeError(w, http.StatusForbidden, "API key lacks the required scope: "+scope)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// requireGlobalScope wraps a handler for a server-wide endpoint, which
+// domain-restricted keys may not call.
+func (s *Server) requireGlobalScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return s.requireScope(scope, func(w http.ResponseWriter, r *http.Request) {
+		if principalFromContext(r.Context()).restricted() {
+			s.writeError(w, http.StatusForbidden, "API key is restricted to specific domains")
+			return
+		}
+		h(w, r)
+	})
+}
+
+// authorizeAddress writes a 403 and returns false if the caller's key may not
+// act on address. Requests without a principal (handlers called directly)
+// are not restricted; every routed request has one.
+func (s *Server) authorizeAddress(w http.ResponseWriter, r *http.Request, address string) bool {
+	if p := principalFromContext(r.Context()); p != nil && !p.allowsAddress(address) {
+		s.writeError(w, http.StatusForbidden, "API key is not authorized for this domain")
+		return false
+	}
+	return true
+}
+
+// authorizeDomain is authorizeAddress for a bare domain.
+func (s *Server) authorizeDomain(w http.ResponseWriter, r *http.Request, domain string) bool {
+	if p := principalFromContext(r.Context()); p != nil && !p.allowsDomain(domain) {
+		s.writeError(w, http.StatusForbidden, "API key is not authorized for this domain")
+		return false
+	}
+	return true
+}
+
+// principalRestricted reports whether the caller's key is limited to some
+// domains, for handlers that filter list results.
+func principalRestricted(r *http.Request) (*apiPrincipal, bool) {
+	p := principalFromContext(r.Context())
+	return p, p != nil && p.restricted()
+}
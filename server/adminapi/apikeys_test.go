@@ -0,0 +1,104 @@
+package adminapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/migadu/sora/config"
+)
+
+func newScopedKeyServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := New(nil, ServerOptions{
+		APIKeys: []config.AdminAPIKeyConfig{
+			{Name: "monitoring", KeyHash: HashAPIKey("monitoring-key"), Scopes: []string{ScopeRead}},
+			{Name: "example", KeyHash: "sha256:" + HashAPIKey("example-key"), Scopes: []string{ScopeAccounts, ScopeConnections}, Domains: []string{"Example.com"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+func TestScopedAPIKeys(t *testing.T) {
+	handler := newScopedKeyServer(t).setupRoutes()
+
+	cases := []struct {
+		name, key, method, path string
+		wantStatus              int
+		wantBody                string
+	}{
+		{"unknown key", "nope", "GET", "/admin/connections", http.StatusForbidden, "Invalid API key"},
+		{"read key can GET", "monitoring-key", "GET", "/admin/connections", http.StatusOK, ""},
+		{"read key cannot POST", "monitoring-key", "POST", "/admin/cache/purge", http.StatusForbidden, "required scope: cache"},
+		{"missing scope", "example-key", "POST", "/admin/mail/deliver", http.StatusForbidden, "required scope: delivery"},
+		{"restricted key on global route", "example-key", "GET", "/admin/connections/stats", http.StatusForbidden, "restricted to specific domains"},
+		{"restricted key on other domain", "example-key", "GET", "/admin/accounts/user@other.org", http.StatusForbidden, "not authorized for this domain"},
+		{"restricted key on other domain listing", "example-key", "GET", "/admin/domains/other.org/accounts", http.StatusForbidden, "not authorized for this domain"},
+		{"restricted key on own domain", "example-key", "GET", "/admin/connections/user/user@EXAMPLE.com", http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, nil)
+			req.Header.Set("Authorization", "Bearer "+c.key)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != c.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", rr.Code, c.wantStatus, rr.Body.String())
+			}
+			if c.wantBody != "" && !strings.Contains(rr.Body.String(), c.wantBody) {
+				t.Errorf("body = %s, want to contain %q", rr.Body.String(), c.wantBody)
+			}
+		})
+	}
+}
+
+func TestReloadConfigRevokesRemovedKeys(t *testing.T) {
+	s := newScopedKeyServer(t)
+	ctx := context.Background()
+	if p, err := s.authenticate(ctx, "monitoring-key"); err != nil || p == nil {
+		t.Fatalf("authenticate before reload = %v, %v", p, err)
+	}
+
+	if err := s.ReloadConfig(config.ServerConfig{}); err != nil {
+		t.Fatalf("ReloadConfig: %v", err)
+	}
+	if p, err := s.authenticate(ctx, "monitoring-key"); err != nil || p != nil {
+		t.Errorf("removed key still authenticates after reload: %v, %v", p, err)
+	}
+}
+
+func TestNew_RejectsInvalidScopedKeys(t *testing.T) {
+	cases := []struct {
+		name string
+		key  config.AdminAPIKeyConfig
+	}{
+		{"bad hash", config.AdminAPIKeyConfig{Name: "a", KeyHash: "abc", Scopes: []string{ScopeRead}}},
+		{"no scopes", config.AdminAPIKeyConfig{Name: "a", KeyHash: HashAPIKey("x")}},
+		{"unknown scope", config.AdminAPIKeyConfig{Name: "a", KeyHash: HashAPIKey("x"), Scopes: []string{"admin"}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := New(nil, ServerOptions{APIKeys: []config.AdminAPIKeyConfig{c.key}}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey: %v", err)
+	}
+	if !strings.HasPrefix(key, APIKeyPrefix) || !strings.HasPrefix(key, prefix) || len(key) < minAPIKeyLength {
+		t.Errorf("unexpected key %q / prefix %q", key, prefix)
+	}
+	if hash != HashAPIKey(key) {
+		t.Error("hash does not match HashAPIKey(key)")
+	}
+}
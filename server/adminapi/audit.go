@@ -0,0 +1,116 @@
+package adminapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+)
+
+// auditSource is the audit log source for Admin API requests.
+const auditSource = "adminapi"
+
+// withAuditActor tags the request context with the caller, so account
+// operations in the db layer are written to the audit log.
+func withAuditActor(r *http.Request, principal *apiPrincipal) context.Context {
+	return db.WithAuditActor(r.Context(), db.AuditActor{
+		Source:     auditSource,
+		Actor:      principal.name,
+		RemoteAddr: getClientIP(r),
+	})
+}
+
+// audit records an action that does not go through an audited db operation
+// (kicks, cache purges, ACL changes). A failure is logged but does not fail
+// the request, since the action has already happened.
+func (s *Server) audit(r *http.Request, action, target string, details map[string]any) {
+	if s.rdb == nil {
+		return
+	}
+	actor, ok := db.AuditActorFromContext(r.Context())
+	if !ok {
+		return
+	}
+	entry := &db.AuditEntry{
+		Source:     actor.Source,
+		Actor:      actor.Actor,
+		RemoteAddr: actor.RemoteAddr,
+		Action:     action,
+		Target:     target,
+		Details:    details,
+	}
+	if err := s.rdb.InsertAuditEntryWithRetry(r.Context(), entry); err != nil {
+		logger.Error("HTTP API: Failed to write audit entry", "name", s.name, "action", action, "target", target, "error", err)
+	}
+}
+
+// handleAuditList handles GET /admin/audit with optional action, actor,
+// target, since, until, after_id and limit query parameters.
+func (s *Server) handleAuditList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := db.AuditFilter{
+		Action: q.Get("action"),
+		Actor:  q.Get("actor"),
+		Target: q.Get("target"),
+	}
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if filter.Since, err = parseTimeParam(v); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid 'since' parameter: "+err.Error())
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if filter.Until, err = parseTimeParam(v); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid 'until' parameter: "+err.Error())
+			return
+		}
+	}
+	if v := q.Get("after_id"); v != "" {
+		if filter.AfterID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid 'after_id' parameter")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 {
+			s.writeError(w, http.StatusBadRequest, "Invalid 'limit' parameter")
+			return
+		}
+	}
+
+	entries, err := s.rdb.ListAuditEntriesWithRetry(r.Context(), filter)
+	if err != nil {
+		logger.Warn("HTTP API: Error listing audit log", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to list audit log")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// handleAuditVerify handles GET /admin/audit/verify, which recomputes the
+// audit log hash chain.
+func (s *Server) handleAuditVerify(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	result, err := s.rdb.VerifyAuditLogWithRetry(r.Context())
+	if err != nil {
+		logger.Warn("HTTP API: Error verifying audit log", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to verify audit log")
+		return
+	}
+	if !result.Valid {
+		logger.Error("HTTP API: Audit log hash chain is broken", "name", s.name, "entry", result.BrokenID, "reason", result.Reason)
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"result":   result,
+		"duration": time.Since(start).String(),
+	})
+}
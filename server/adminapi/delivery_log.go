@@ -0,0 +1,61 @@
+package adminapi
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+)
+
+// handleDeliveryLogSearch handles GET /admin/delivery-log with optional
+// recipient, sender, message_id, since, until and limit query parameters.
+// A key restricted to some domains must search by a recipient in them.
+func (s *Server) handleDeliveryLogSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := db.DeliveryLogFilter{
+		Recipient: q.Get("recipient"),
+		Sender:    q.Get("sender"),
+		MessageID: q.Get("message_id"),
+	}
+
+	if p := principalFromContext(r.Context()); p != nil && p.restricted() && filter.Recipient == "" {
+		s.writeError(w, http.StatusForbidden, "API key is restricted to specific domains: 'recipient' is required")
+		return
+	}
+	if filter.Recipient != "" && !s.authorizeAddress(w, r, filter.Recipient) {
+		return
+	}
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if filter.Since, err = parseTimeParam(v); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid 'since' parameter: "+err.Error())
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if filter.Until, err = parseTimeParam(v); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid 'until' parameter: "+err.Error())
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 {
+			s.writeError(w, http.StatusBadRequest, "Invalid 'limit' parameter")
+			return
+		}
+	}
+
+	entries, err := s.rdb.SearchDeliveryLogWithRetry(r.Context(), filter)
+	if err != nil {
+		logger.Warn("HTTP API: Error searching delivery log", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to search delivery log")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
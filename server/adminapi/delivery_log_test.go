@@ -0,0 +1,36 @@
+package adminapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestDeliveryLogSearchDomainRestriction tests that a domain-restricted key
+// can only search the delivery log of its own domains.
+func TestDeliveryLogSearchDomainRestriction(t *testing.T) {
+	server := &Server{apiKey: "test-api-key"}
+	principal := newAPIPrincipal("support", []string{ScopeDelivery}, []string{"example.com"})
+
+	for name, target := range map[string]string{
+		"no recipient":       "/admin/delivery-log?sender=someone@example.net",
+		"other domain":       "/admin/delivery-log?recipient=user@example.net",
+		"other whole domain": "/admin/delivery-log?recipient=@example.net",
+	} {
+		req := httptest.NewRequest("GET", target, nil)
+		req = req.WithContext(withPrincipal(req.Context(), principal))
+		rr := httptest.NewRecorder()
+		server.handleDeliveryLogSearch(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %v, want %v", name, rr.Code, http.StatusForbidden)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/admin/delivery-log?recipient=user@example.com&since=yesterday", nil)
+	req = req.WithContext(withPrincipal(req.Context(), principal))
+	rr := httptest.NewRecorder()
+	server.handleDeliveryLogSearch(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("own domain with invalid since: status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,182 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/delivery"
+)
+
+// maxDeliverySettingsRequestSize bounds a delivery settings body
+const maxDeliverySettingsRequestSize = 4096
+
+// handleAccountDeliveryOperations routes /admin/accounts/{email}/delivery
+func (s *Server) handleAccountDeliveryOperations(w http.ResponseWriter, r *http.Request) {
+	email, err := url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/accounts/"), "/delivery"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid email")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.handleAccountDeliveryGet(w, r, email)
+	case "PUT":
+		s.handleAccountDeliveryPut(w, r, email)
+	case "DELETE":
+		s.handleAccountDeliveryDelete(w, r, email)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDomainDeliveryOperations routes /admin/domains/{domain}/delivery
+func (s *Server) handleDomainDeliveryOperations(w http.ResponseWriter, r *http.Request) {
+	domain, err := url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/domains/"), "/delivery"))
+	if err != nil || domain == "" || strings.Contains(domain, "/") {
+		s.writeError(w, http.StatusBadRequest, "Domain is required")
+		return
+	}
+	domain = strings.ToLower(domain)
+	if !s.authorizeDomain(w, r, domain) {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.handleDomainDeliveryGet(w, r, domain)
+	case "PUT":
+		s.handleDomainDeliveryPut(w, r, domain)
+	case "DELETE":
+		s.handleDomainDeliveryDelete(w, r, domain)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeDeliverySettingsError maps delivery settings errors to responses
+func (s *Server) writeDeliverySettingsError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, consts.ErrDBNotFound):
+		s.writeError(w, http.StatusNotFound, "Delivery settings not configured")
+	case errors.Is(err, delivery.ErrInvalidDeliverySettings):
+		s.writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		logger.Warn("HTTP API: Error in delivery settings operation", "name", s.name, "operation", op, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to "+op+" delivery settings")
+	}
+}
+
+// decodeDeliverySettings reads and validates a delivery settings body
+func (s *Server) decodeDeliverySettings(w http.ResponseWriter, r *http.Request) (*db.DeliverySettings, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDeliverySettingsRequestSize)
+	var settings db.DeliverySettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+	if err := delivery.ValidateDeliverySettings(&settings); err != nil {
+		s.writeDeliverySettingsError(w, "save", err)
+		return nil, false
+	}
+	return &settings, true
+}
+
+// handleAccountDeliveryGet handles GET /admin/accounts/{email}/delivery. The
+// response holds the effective settings and where they come from.
+func (s *Server) handleAccountDeliveryGet(w http.ResponseWriter, r *http.Request, email string) {
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	settings, err := s.rdb.GetDeliverySettingsWithRetry(r.Context(), accountID, "")
+	if err != nil {
+		s.writeDeliverySettingsError(w, "get", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+// handleAccountDeliveryPut handles PUT /admin/accounts/{email}/delivery
+func (s *Server) handleAccountDeliveryPut(w http.ResponseWriter, r *http.Request, email string) {
+	settings, ok := s.decodeDeliverySettings(w, r)
+	if !ok {
+		return
+	}
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	if err := s.rdb.SetAccountDeliverySettingsWithRetry(r.Context(), accountID, settings); err != nil {
+		s.writeDeliverySettingsError(w, "save", err)
+		return
+	}
+	s.audit(r, db.AuditDeliveryPut, email, map[string]any{"subaddress_folders": settings.SubaddressFolders})
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+// handleAccountDeliveryDelete handles DELETE /admin/accounts/{email}/delivery.
+// The account falls back to its domain's settings.
+func (s *Server) handleAccountDeliveryDelete(w http.ResponseWriter, r *http.Request, email string) {
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	if err := s.rdb.DeleteAccountDeliverySettingsWithRetry(r.Context(), accountID); err != nil {
+		s.writeDeliverySettingsError(w, "delete", err)
+		return
+	}
+	s.audit(r, db.AuditDeliveryDelete, email, nil)
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Delivery settings deleted successfully",
+		"email":   email,
+	})
+}
+
+// handleDomainDeliveryGet handles GET /admin/domains/{domain}/delivery
+func (s *Server) handleDomainDeliveryGet(w http.ResponseWriter, r *http.Request, domain string) {
+	settings, err := s.rdb.GetDomainDeliverySettingsWithRetry(r.Context(), domain)
+	if err != nil {
+		s.writeDeliverySettingsError(w, "get", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+// handleDomainDeliveryPut handles PUT /admin/domains/{domain}/delivery. The
+// settings apply to accounts of the domain that have none of their own.
+func (s *Server) handleDomainDeliveryPut(w http.ResponseWriter, r *http.Request, domain string) {
+	settings, ok := s.decodeDeliverySettings(w, r)
+	if !ok {
+		return
+	}
+	if err := s.rdb.SetDomainDeliverySettingsWithRetry(r.Context(), domain, settings); err != nil {
+		s.writeDeliverySettingsError(w, "save", err)
+		return
+	}
+	s.audit(r, db.AuditDeliveryPut, "@"+domain, map[string]any{"subaddress_folders": settings.SubaddressFolders})
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+// handleDomainDeliveryDelete handles DELETE /admin/domains/{domain}/delivery
+func (s *Server) handleDomainDeliveryDelete(w http.ResponseWriter, r *http.Request, domain string) {
+	if err := s.rdb.DeleteDomainDeliverySettingsWithRetry(r.Context(), domain); err != nil {
+		s.writeDeliverySettingsError(w, "delete", err)
+		return
+	}
+	s.audit(r, db.AuditDeliveryDelete, "@"+domain, nil)
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Delivery settings deleted successfully",
+		"domain":  domain,
+	})
+}
@@ -0,0 +1,165 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/dkim"
+	"github.com/migadu/sora/server/delivery"
+)
+
+// maxDKIMRequestSize bounds a DKIM key body, which may carry a 4096-bit PEM key.
+const maxDKIMRequestSize = 16384
+
+// CreateDKIMKeyRequest creates a domain's DKIM key. Without PrivateKey a new
+// key of Algorithm ("rsa" or "ed25519") is generated.
+type CreateDKIMKeyRequest struct {
+	Selector   string `json:"selector"`
+	Algorithm  string `json:"algorithm,omitempty"`
+	Bits       int    `json:"bits,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	Active     bool   `json:"active"`
+}
+
+// handleDKIMOperations routes /admin/domains/{domain}/dkim[/{selector}[/activate]]
+func (s *Server) handleDKIMOperations(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/admin/domains/")
+	domain, rest, _ := strings.Cut(rest, "/dkim")
+	domain, err := url.PathUnescape(domain)
+	if err != nil || domain == "" {
+		s.writeError(w, http.StatusBadRequest, "Domain is required")
+		return
+	}
+	domain = strings.ToLower(domain)
+	if !s.authorizeDomain(w, r, domain) {
+		return
+	}
+
+	rest = strings.Trim(rest, "/")
+	switch {
+	case rest == "":
+		switch r.Method {
+		case "GET":
+			s.handleListDKIMKeys(w, r, domain)
+		case "POST":
+			s.handleCreateDKIMKey(w, r, domain)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case strings.HasSuffix(rest, "/activate"):
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleActivateDKIMKey(w, r, domain, strings.TrimSuffix(rest, "/activate"))
+	case !strings.Contains(rest, "/"):
+		if r.Method != "DELETE" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleDeleteDKIMKey(w, r, domain, rest)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// writeDKIMError maps DKIM key errors to responses
+func (s *Server) writeDKIMError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, consts.ErrDBNotFound):
+		s.writeError(w, http.StatusNotFound, "DKIM key not found")
+	case errors.Is(err, db.ErrDKIMKeyExists):
+		s.writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, delivery.ErrInvalidDKIMKey):
+		s.writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		logger.Warn("HTTP API: Error in DKIM key operation", "name", s.name, "operation", op, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to "+op+" DKIM key")
+	}
+}
+
+// handleListDKIMKeys handles GET /admin/domains/{domain}/dkim
+func (s *Server) handleListDKIMKeys(w http.ResponseWriter, r *http.Request, domain string) {
+	keys, err := s.rdb.ListDKIMKeysWithRetry(r.Context(), domain)
+	if err != nil {
+		s.writeDKIMError(w, "list", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"domain": domain,
+		"keys":   keys,
+		"total":  len(keys),
+	})
+}
+
+// handleCreateDKIMKey handles POST /admin/domains/{domain}/dkim. The response
+// includes the DNS record to publish before the key is activated.
+func (s *Server) handleCreateDKIMKey(w http.ResponseWriter, r *http.Request, domain string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDKIMRequestSize)
+	var req CreateDKIMKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	key, err := delivery.NewDKIMKey(domain, req.Selector, req.Algorithm, req.Bits, req.PrivateKey, req.Active)
+	if err != nil {
+		s.writeDKIMError(w, "create", err)
+		return
+	}
+	if err := s.rdb.CreateDKIMKeyWithRetry(r.Context(), key); err != nil {
+		s.writeDKIMError(w, "create", err)
+		return
+	}
+	s.audit(r, db.AuditDKIMCreate, domain, map[string]any{
+		"selector":  key.Selector,
+		"algorithm": key.Algorithm,
+		"imported":  req.PrivateKey != "",
+		"active":    key.Active,
+	})
+
+	s.writeJSON(w, http.StatusCreated, map[string]any{
+		"key": key,
+		"dns_record": map[string]string{
+			"name":  dkim.RecordName(key.Selector, key.Domain),
+			"type":  "TXT",
+			"value": key.PublicKey,
+		},
+	})
+}
+
+// handleActivateDKIMKey handles POST /admin/domains/{domain}/dkim/{selector}/activate
+func (s *Server) handleActivateDKIMKey(w http.ResponseWriter, r *http.Request, domain, selector string) {
+	if err := s.rdb.ActivateDKIMKeyWithRetry(r.Context(), domain, selector); err != nil {
+		s.writeDKIMError(w, "activate", err)
+		return
+	}
+	s.audit(r, db.AuditDKIMActivate, domain, map[string]any{"selector": selector})
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message":  "DKIM key activated successfully",
+		"domain":   domain,
+		"selector": selector,
+	})
+}
+
+// handleDeleteDKIMKey handles DELETE /admin/domains/{domain}/dkim/{selector}
+func (s *Server) handleDeleteDKIMKey(w http.ResponseWriter, r *http.Request, domain, selector string) {
+	if err := s.rdb.DeleteDKIMKeyWithRetry(r.Context(), domain, selector); err != nil {
+		s.writeDKIMError(w, "delete", err)
+		return
+	}
+	s.audit(r, db.AuditDKIMDelete, domain, map[string]any{"selector": selector})
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message":  "DKIM key deleted successfully",
+		"domain":   domain,
+		"selector": selector,
+	})
+}
@@ -0,0 +1,183 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/server"
+	"github.com/migadu/sora/server/proxy"
+)
+
+// DrainRequest is the body of POST /admin/proxy/backends/drain
+type DrainRequest struct {
+	Backend       string `json:"backend"`                   // host:port, or a bare host to drain every port
+	KickPerMinute int    `json:"kick_per_minute,omitempty"` // Sessions each proxy kicks per minute (0 = none)
+}
+
+// BackendDrainer is implemented by affinity managers that gossip drain state
+// cluster-wide (server.AffinityManager).
+type BackendDrainer interface {
+	DrainBackend(backend string, kickPerMinute int)
+	UndrainBackend(backend string) bool
+	GetDrainedBackends() []server.BackendDrain
+}
+
+// backendDrainer returns the cluster-wide drainer, or nil when drains are local to this node.
+func (s *Server) backendDrainer() BackendDrainer {
+	if s.affinityManager == nil {
+		return nil
+	}
+	drainer, _ := s.affinityManager.(BackendDrainer)
+	return drainer
+}
+
+// connectionManagers returns the connection managers of this node's proxies.
+func (s *Server) connectionManagers() []*proxy.ConnectionManager {
+	var managers []*proxy.ConnectionManager
+	for _, proxyServer := range s.proxyServers {
+		if proxyServer == nil {
+			continue
+		}
+		if cm := proxyServer.GetConnectionManager(); cm != nil {
+			managers = append(managers, cm)
+		}
+	}
+	return managers
+}
+
+// isKnownBackend reports whether backend names a configured backend address or host.
+// With no backends known on this node any address is accepted, since a
+// cluster-wide drain may be requested from a node that runs no proxies.
+func (s *Server) isKnownBackend(backend string) bool {
+	var addrs []string
+	for _, backends := range s.validBackends {
+		addrs = append(addrs, backends...)
+	}
+	for _, cm := range s.connectionManagers() {
+		for _, status := range cm.GetBackendHealthStatuses() {
+			addrs = append(addrs, status.Address)
+		}
+	}
+	if len(addrs) == 0 {
+		return true
+	}
+
+	drain := server.BackendDrain{Backend: backend}
+	for _, addr := range addrs {
+		if drain.Matches(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// handleBackendDrain handles GET, POST and DELETE /admin/proxy/backends/drain
+func (s *Server) handleBackendDrain(w http.ResponseWriter, r *http.Request) {
+	drainer := s.backendDrainer()
+	managers := s.connectionManagers()
+	scope := "cluster"
+	if drainer == nil {
+		scope = "local"
+		if len(managers) == 0 {
+			s.writeError(w, http.StatusServiceUnavailable, "No proxy servers or cluster affinity on this server")
+			return
+		}
+	}
+
+	switch r.Method {
+	case "GET":
+		var drains []server.BackendDrain
+		if drainer != nil {
+			drains = drainer.GetDrainedBackends()
+		} else {
+			seen := make(map[string]bool)
+			for _, cm := range managers {
+				for _, d := range cm.GetDrainedBackends() {
+					if !seen[d.Backend] {
+						seen[d.Backend] = true
+						drains = append(drains, d)
+					}
+				}
+			}
+		}
+		if drains == nil {
+			drains = []server.BackendDrain{}
+		}
+		s.writeJSON(w, http.StatusOK, map[string]any{
+			"drains": drains,
+			"count":  len(drains),
+			"scope":  scope,
+		})
+
+	case "POST":
+		var req DrainRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+			return
+		}
+		req.Backend = strings.TrimSpace(req.Backend)
+		if req.Backend == "" {
+			s.writeError(w, http.StatusBadRequest, "backend is required")
+			return
+		}
+		if req.KickPerMinute < 0 {
+			s.writeError(w, http.StatusBadRequest, "kick_per_minute must not be negative")
+			return
+		}
+		if !s.isKnownBackend(req.Backend) {
+			s.writeError(w, http.StatusBadRequest, "Backend "+req.Backend+" is not configured on any proxy")
+			return
+		}
+
+		if drainer != nil {
+			drainer.DrainBackend(req.Backend, req.KickPerMinute)
+		} else {
+			for _, cm := range managers {
+				cm.DrainBackend(req.Backend, req.KickPerMinute)
+			}
+		}
+		s.audit(r, db.AuditBackendDrain, req.Backend, map[string]any{
+			"kick_per_minute": req.KickPerMinute,
+			"scope":           scope,
+		})
+		s.writeJSON(w, http.StatusOK, map[string]any{
+			"success":         true,
+			"backend":         req.Backend,
+			"kick_per_minute": req.KickPerMinute,
+			"scope":           scope,
+		})
+
+	case "DELETE":
+		backend := strings.TrimSpace(r.URL.Query().Get("backend"))
+		if backend == "" {
+			s.writeError(w, http.StatusBadRequest, "backend query parameter is required")
+			return
+		}
+
+		drained := false
+		if drainer != nil {
+			drained = drainer.UndrainBackend(backend)
+		} else {
+			for _, cm := range managers {
+				if cm.UndrainBackend(backend) {
+					drained = true
+				}
+			}
+		}
+		if !drained {
+			s.writeError(w, http.StatusNotFound, "Backend "+backend+" is not drained")
+			return
+		}
+		s.audit(r, db.AuditBackendUndrain, backend, map[string]any{"scope": scope})
+		s.writeJSON(w, http.StatusOK, map[string]any{
+			"success": true,
+			"backend": backend,
+			"scope":   scope,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,104 @@
+package adminapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/migadu/sora/server"
+)
+
+// fakeDrainAffinity is an AffinityManager that also implements BackendDrainer
+type fakeDrainAffinity struct {
+	drains map[string]server.BackendDrain
+}
+
+func (f *fakeDrainAffinity) SetBackend(username, backend, protocol string)       {}
+func (f *fakeDrainAffinity) GetBackend(username, protocol string) (string, bool) { return "", false }
+func (f *fakeDrainAffinity) DeleteBackend(username, protocol string)             {}
+func (f *fakeDrainAffinity) GetStats(ctx context.Context) map[string]any         { return nil }
+
+func (f *fakeDrainAffinity) DrainBackend(backend string, kickPerMinute int) {
+	f.drains[backend] = server.BackendDrain{Backend: backend, Since: time.Now(), KickPerMinute: kickPerMinute}
+}
+
+func (f *fakeDrainAffinity) UndrainBackend(backend string) bool {
+	_, ok := f.drains[backend]
+	delete(f.drains, backend)
+	return ok
+}
+
+func (f *fakeDrainAffinity) GetDrainedBackends() []server.BackendDrain {
+	var drains []server.BackendDrain
+	for _, d := range f.drains {
+		drains = append(drains, d)
+	}
+	return drains
+}
+
+func TestBackendDrainWithoutProxies(t *testing.T) {
+	s := &Server{apiKey: "test-api-key"}
+	rr := httptest.NewRecorder()
+	s.handleBackendDrain(rr, httptest.NewRequest("GET", "/admin/proxy/backends/drain", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestBackendDrainCluster(t *testing.T) {
+	affinity := &fakeDrainAffinity{drains: make(map[string]server.BackendDrain)}
+	s := &Server{
+		apiKey:          "test-api-key",
+		affinityManager: affinity,
+		validBackends:   map[string][]string{"imap": {"10.0.0.1:143", "10.0.0.2:143"}},
+	}
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.handleBackendDrain(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"missing backend", `{}`, http.StatusBadRequest},
+		{"negative kick rate", `{"backend":"10.0.0.1:143","kick_per_minute":-1}`, http.StatusBadRequest},
+		{"unknown backend", `{"backend":"10.0.0.9:143"}`, http.StatusBadRequest},
+		{"invalid json", `{`, http.StatusBadRequest},
+		{"bare host", `{"backend":"10.0.0.1","kick_per_minute":5}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rr := do("POST", "/admin/proxy/backends/drain", tt.body); rr.Code != tt.want {
+			t.Errorf("%s: status = %v, want %v (%s)", tt.name, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+
+	rr := do("GET", "/admin/proxy/backends/drain", "")
+	var list struct {
+		Drains []server.BackendDrain `json:"drains"`
+		Count  int                   `json:"count"`
+		Scope  string                `json:"scope"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("list failed: %d %s", rr.Code, rr.Body.String())
+	}
+	if list.Count != 1 || list.Scope != "cluster" || list.Drains[0].KickPerMinute != 5 {
+		t.Errorf("unexpected drain list: %+v", list)
+	}
+
+	if rr := do("DELETE", "/admin/proxy/backends/drain", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("undrain without backend status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := do("DELETE", "/admin/proxy/backends/drain?backend=10.0.0.1", ""); rr.Code != http.StatusOK {
+		t.Errorf("undrain status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	if rr := do("DELETE", "/admin/proxy/backends/drain?backend=10.0.0.1", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("second undrain status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,145 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/adminjobs"
+)
+
+// SubmitJobRequest is the body of POST /admin/jobs.
+type SubmitJobRequest struct {
+	Type        string         `json:"type"`
+	Params      map[string]any `json:"params"`
+	MaxAttempts int            `json:"max_attempts,omitempty"` // default: [jobs] max_attempts
+}
+
+// writeJobError maps job errors to responses
+func (s *Server) writeJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, consts.ErrDBNotFound):
+		s.writeError(w, http.StatusNotFound, "Job not found")
+	case errors.Is(err, db.ErrAdminJobState):
+		s.writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, adminjobs.ErrInvalidJob):
+		s.writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		logger.Warn("HTTP API: Job operation failed", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Job operation failed")
+	}
+}
+
+// handleJobs handles GET and POST /admin/jobs
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" {
+		s.handleSubmitJob(w, r)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := db.AdminJobFilter{Status: q.Get("status"), Type: q.Get("type")}
+	if v := q.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			s.writeError(w, http.StatusBadRequest, "Invalid 'limit' parameter")
+			return
+		}
+		filter.Limit = l
+	}
+	jobs, err := s.rdb.ListAdminJobsWithRetry(r.Context(), filter)
+	if err != nil {
+		s.writeJobError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"jobs":  jobs,
+		"count": len(jobs),
+	})
+}
+
+// handleSubmitJob handles POST /admin/jobs
+func (s *Server) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	var req SubmitJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+	if req.MaxAttempts < 0 {
+		s.writeError(w, http.StatusBadRequest, "max_attempts must not be negative")
+		return
+	}
+	// Checked before touching the database so bad requests fail fast.
+	if err := adminjobs.ValidateParams(req.Type, req.Params); err != nil {
+		s.writeJobError(w, err)
+		return
+	}
+	maxAttempts := req.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = s.jobMaxAttempts
+	}
+
+	var createdBy string
+	if actor, ok := db.AuditActorFromContext(r.Context()); ok {
+		createdBy = actor.Actor
+	}
+	job, err := adminjobs.Submit(r.Context(), s.rdb, req.Type, req.Params, createdBy, maxAttempts)
+	if err != nil {
+		s.writeJobError(w, err)
+		return
+	}
+	if s.jobWorker != nil {
+		s.jobWorker.Wake()
+	}
+	s.audit(r, db.AuditJobSubmit, adminjobs.Target(job), map[string]any{"job_id": job.ID, "type": job.Type})
+	s.writeJSON(w, http.StatusAccepted, job)
+}
+
+// handleJobOperations routes /admin/jobs/{id}[/cancel|/retry]
+func (s *Server) handleJobOperations(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/admin/jobs/")
+	idStr, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		s.writeError(w, http.StatusBadRequest, "Invalid job ID")
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == "GET":
+		job, err := s.rdb.GetAdminJobWithRetry(r.Context(), id)
+		if err != nil {
+			s.writeJobError(w, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, job)
+	case action == "cancel" && r.Method == "POST":
+		job, err := s.rdb.CancelAdminJobWithRetry(r.Context(), id)
+		if err != nil {
+			s.writeJobError(w, err)
+			return
+		}
+		s.audit(r, db.AuditJobCancel, adminjobs.Target(job), map[string]any{"job_id": job.ID, "type": job.Type})
+		s.writeJSON(w, http.StatusOK, job)
+	case action == "retry" && r.Method == "POST":
+		job, err := s.rdb.RetryAdminJobWithRetry(r.Context(), id)
+		if err != nil {
+			s.writeJobError(w, err)
+			return
+		}
+		if s.jobWorker != nil {
+			s.jobWorker.Wake()
+		}
+		s.audit(r, db.AuditJobRetry, adminjobs.Target(job), map[string]any{"job_id": job.ID, "type": job.Type})
+		s.writeJSON(w, http.StatusOK, job)
+	case action == "" || action == "cancel" || action == "retry":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		s.writeError(w, http.StatusNotFound, "Not found")
+	}
+}
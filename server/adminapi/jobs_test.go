@@ -0,0 +1,54 @@
+package adminapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubmitJobValidation(t *testing.T) {
+	server := &Server{apiKey: "test-api-key"}
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid JSON", `{`},
+		{"unknown type", `{"type": "reindex", "params": {"email": "user@example.com"}}`},
+		{"missing email", `{"type": "purge-account", "params": {}}`},
+		{"negative attempts", `{"type": "verify-s3", "params": {"email": "user@example.com"}, "max_attempts": -1}`},
+		{"absolute import path", `{"type": "import", "params": {"email": "user@example.com", "path": "/etc"}}`},
+		{"import path traversal", `{"type": "export", "params": {"email": "user@example.com", "path": "../x"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			server.handleJobs(rr, httptest.NewRequest("POST", "/admin/jobs", strings.NewReader(tt.body)))
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("status = %v, want %v (%s)", rr.Code, http.StatusBadRequest, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestJobOperationsRouting(t *testing.T) {
+	server := &Server{apiKey: "test-api-key"}
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{"GET", "/admin/jobs/abc", http.StatusBadRequest},
+		{"GET", "/admin/jobs/0", http.StatusBadRequest},
+		{"POST", "/admin/jobs/1/restart", http.StatusNotFound},
+		{"DELETE", "/admin/jobs/1", http.StatusMethodNotAllowed},
+		{"GET", "/admin/jobs/1/cancel", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		server.handleJobOperations(rr, httptest.NewRequest(tt.method, tt.path, nil))
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: status = %v, want %v", tt.method, tt.path, rr.Code, tt.want)
+		}
+	}
+}
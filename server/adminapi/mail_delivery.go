@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
@@ -181,6 +182,11 @@ func (s *Server) handleDeliverMail(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusBadRequest, "At least one recipient is required")
 		return
 	}
+	for _, recipient := range req.Recipients {
+		if !s.authorizeAddress(w, r, recipient) {
+			return
+		}
+	}
 
 	// Extract sender address if not provided
 	if req.From == "" {
@@ -274,9 +280,12 @@ func (s *Server) deliverToRecipient(ctx context.Context, req *DeliverMailRequest
 		VacationOracle:     vacationOracle,
 		VacationHandler:    vacationHandler,
 		RelayQueue:         s.relayQueue,
+		SRS:                s.srs,
+		ARCSealer:          s.arcSealer,
 		RedirectRateLimit:  s.redirectRateLimit,
 		RedirectRateWindow: s.redirectRateWindow,
 		MaxRedirectHops:    s.maxRedirectHops,
+		SieveExtensions:    s.sieveExtensions,
 	}
 
 	deliveryCtx.SieveExecutor = sieveExecutor
@@ -285,6 +294,26 @@ func (s *Server) deliverToRecipient(ctx context.Context, req *DeliverMailRequest
 
 	// Lookup recipient
 	recipientInfo, err := deliveryCtx.LookupRecipient(ctx, recipient)
+	if errors.Is(err, delivery.ErrRecipientNotFound) {
+		// Not a login credential: groups, catch-alls and domain aliases
+		addr, _ := server.NewAddress(recipient) // Validated by LookupRecipient
+		route := addr.BaseAddress()
+		var resolution *delivery.Resolution
+		if resolution, err = delivery.ResolveRecipient(ctx, s.rdb, route); err == nil {
+			account, single := resolution.Single()
+			if !single {
+				if err := s.deliverToRoute(ctx, req, deliveryCtx, route, resolution, messageBytes); err != nil {
+					logger.Log("delivery failed: %v", err)
+					status.Error = err.Error()
+					return status
+				}
+				logger.Log("message delivered to %d accounts and forwarded to %d addresses", len(resolution.Accounts), len(resolution.Forwards))
+				status.Accepted = true
+				return status
+			}
+			recipientInfo, err = deliveryCtx.LookupRecipient(ctx, account.Address)
+		}
+	}
 	if err != nil {
 		logger.Log("recipient lookup failed: %v", err)
 		status.Error = err.Error()
@@ -327,3 +356,26 @@ func (s *Server) deliverToRecipient(ctx context.Context, req *DeliverMailRequest
 	status.Accepted = result.Success
 	return status
 }
+
+// deliverToRoute delivers a message to every destination of a routed
+// recipient: each local account through its own Sieve script, and external
+// targets through the relay queue.
+func (s *Server) deliverToRoute(ctx context.Context, req *DeliverMailRequest, deliveryCtx *delivery.DeliveryContext, route string, resolution *delivery.Resolution, messageBytes []byte) error {
+	if len(resolution.Forwards) > 0 && s.relayQueue == nil {
+		return delivery.ErrForwardingUnavailable
+	}
+	var from *server.Address
+	if req.From != "" {
+		if addr, parseErr := server.NewAddress(req.From); parseErr == nil {
+			from = &addr
+		}
+	}
+	forwarder := &delivery.Forwarder{
+		RelayQueue: s.relayQueue,
+		SRS:        s.srs,
+		ARCSealer:  s.arcSealer,
+		MaxHops:    s.maxRedirectHops,
+		Logger:     deliveryCtx.Logger,
+	}
+	return deliveryCtx.DeliverToRoute(ctx, route, resolution, from, forwarder, messageBytes)
+}
@@ -0,0 +1,277 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/webhook"
+)
+
+// MailboxRequest is the request body for creating, deleting, subscribing to
+// and unsubscribing from a mailbox
+type MailboxRequest struct {
+	Email   string `json:"email"`   // Account that owns the mailbox
+	Mailbox string `json:"mailbox"` // Mailbox name (e.g., "Projects/2024")
+}
+
+// MailboxRenameRequest is the request body for renaming or moving a mailbox
+type MailboxRenameRequest struct {
+	Email   string `json:"email"`
+	OldName string `json:"old_name"`
+	NewName string `json:"new_name"`
+}
+
+// MailboxInfo describes a mailbox in API responses
+type MailboxInfo struct {
+	Name        string `json:"name"`
+	Subscribed  bool   `json:"subscribed"`
+	HasChildren bool   `json:"has_children"`
+	UIDValidity uint32 `json:"uid_validity"`
+}
+
+// resolveAccount checks that the caller may act on email and returns its
+// account ID, writing the error response if not.
+func (s *Server) resolveAccount(w http.ResponseWriter, r *http.Request, email string) (int64, bool) {
+	if email == "" {
+		s.writeError(w, http.StatusBadRequest, "email is required")
+		return 0, false
+	}
+	if !s.authorizeAddress(w, r, email) {
+		return 0, false
+	}
+	accountID, err := s.rdb.GetAccountIDByEmailWithRetry(r.Context(), email)
+	if err != nil {
+		if errors.Is(err, consts.ErrUserNotFound) {
+			s.writeError(w, http.StatusNotFound, "Account not found")
+			return 0, false
+		}
+		logger.Warn("HTTP API: Error looking up account", "name", s.name, "email", email, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to look up account")
+		return 0, false
+	}
+	return accountID, true
+}
+
+// validateMailboxName validates a mailbox name
+func validateMailboxName(name string) error {
+	if name == "" {
+		return errors.New("mailbox name cannot be empty")
+	}
+	if strings.ContainsAny(name, "\x00\r\n") {
+		return errors.New("mailbox name contains invalid characters")
+	}
+	if len(name) > 255 {
+		return errors.New("mailbox name too long (max 255 characters)")
+	}
+	return nil
+}
+
+// writeMailboxError maps mailbox operation errors to responses
+func (s *Server) writeMailboxError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, consts.ErrMailboxNotFound), errors.Is(err, consts.ErrDBNotFound):
+		s.writeError(w, http.StatusNotFound, "Mailbox not found")
+	case errors.Is(err, consts.ErrMailboxAlreadyExists), errors.Is(err, consts.ErrDBUniqueViolation):
+		s.writeError(w, http.StatusConflict, "Mailbox already exists")
+	case errors.Is(err, consts.ErrMailboxInvalidName):
+		s.writeError(w, http.StatusBadRequest, "Invalid mailbox name")
+	case errors.Is(err, consts.ErrNotPermitted):
+		s.writeError(w, http.StatusForbidden, "Operation not permitted on this mailbox")
+	default:
+		logger.Warn("HTTP API: Error in mailbox operation", "name", s.name, "operation", op, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to "+op+" mailbox")
+	}
+}
+
+// handleListMailboxes handles GET /admin/mailboxes?email=...[&subscribed=true]
+func (s *Server) handleListMailboxes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	email := r.URL.Query().Get("email")
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	subscribedOnly := r.URL.Query().Get("subscribed") == "true"
+
+	// Subscription state lives in the name-based subscriptions table, not
+	// mailboxes.subscribed, so it is looked up separately.
+	mailboxes, err := s.rdb.GetMailboxesForUserWithRetry(ctx, accountID, false)
+	if err != nil {
+		logger.Warn("HTTP API: Error listing mailboxes", "name", s.name, "email", email, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to list mailboxes")
+		return
+	}
+	subNames, err := s.rdb.GetSubscribedMailboxNamesWithRetry(ctx, accountID)
+	if err != nil {
+		logger.Warn("HTTP API: Error listing subscriptions", "name", s.name, "email", email, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to list subscriptions")
+		return
+	}
+	subscribed := make(map[string]bool, len(subNames))
+	for _, n := range subNames {
+		subscribed[strings.ToLower(n)] = true
+	}
+
+	infos := make([]MailboxInfo, 0, len(mailboxes))
+	for _, mb := range mailboxes {
+		isSubscribed := subscribed[strings.ToLower(mb.Name)]
+		if subscribedOnly && !isSubscribed {
+			continue
+		}
+		infos = append(infos, MailboxInfo{
+			Name:        mb.Name,
+			Subscribed:  isSubscribed,
+			HasChildren: mb.HasChildren,
+			UIDValidity: mb.UIDValidity,
+		})
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"email":     email,
+		"mailboxes": infos,
+		"count":     len(infos),
+	})
+}
+
+// decodeMailboxRequest decodes and validates a MailboxRequest and resolves
+// its account.
+func (s *Server) decodeMailboxRequest(w http.ResponseWriter, r *http.Request) (MailboxRequest, int64, bool) {
+	var req MailboxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return req, 0, false
+	}
+	if err := validateMailboxName(req.Mailbox); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return req, 0, false
+	}
+	accountID, ok := s.resolveAccount(w, r, req.Email)
+	return req, accountID, ok
+}
+
+// handleCreateMailbox handles POST /admin/mailboxes/create
+func (s *Server) handleCreateMailbox(w http.ResponseWriter, r *http.Request) {
+	req, accountID, ok := s.decodeMailboxRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.rdb.CreateMailboxForUserWithRetry(r.Context(), accountID, req.Mailbox); err != nil {
+		s.writeMailboxError(w, "create", err)
+		return
+	}
+	s.audit(r, db.AuditMailboxCreate, req.Email, map[string]any{"mailbox": req.Mailbox})
+	s.webhooks.Emit(webhook.NewEvent(webhook.EventMailboxCreated, "adminapi", accountID, req.Email, map[string]any{"mailbox": req.Mailbox}))
+
+	s.writeJSON(w, http.StatusCreated, map[string]any{
+		"message": "Mailbox created successfully",
+		"email":   req.Email,
+		"mailbox": req.Mailbox,
+	})
+}
+
+// handleDeleteMailbox handles POST /admin/mailboxes/delete. Messages in the
+// mailbox are expunged and removed by the cleaner after the grace period.
+func (s *Server) handleDeleteMailbox(w http.ResponseWriter, r *http.Request) {
+	req, accountID, ok := s.decodeMailboxRequest(w, r)
+	if !ok {
+		return
+	}
+	if strings.EqualFold(req.Mailbox, "INBOX") {
+		s.writeError(w, http.StatusForbidden, "Cannot delete INBOX")
+		return
+	}
+
+	if err := s.rdb.DeleteMailboxForUserWithRetry(r.Context(), accountID, req.Mailbox); err != nil {
+		s.writeMailboxError(w, "delete", err)
+		return
+	}
+	s.audit(r, db.AuditMailboxDelete, req.Email, map[string]any{"mailbox": req.Mailbox})
+	s.webhooks.Emit(webhook.NewEvent(webhook.EventMailboxDeleted, "adminapi", accountID, req.Email, map[string]any{"mailbox": req.Mailbox}))
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Mailbox deleted successfully; messages will be cleaned up after the grace period",
+		"email":   req.Email,
+		"mailbox": req.Mailbox,
+	})
+}
+
+// handleRenameMailbox handles POST /admin/mailboxes/rename
+func (s *Server) handleRenameMailbox(w http.ResponseWriter, r *http.Request) {
+	var req MailboxRenameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.OldName == "" || req.NewName == "" {
+		s.writeError(w, http.StatusBadRequest, "old_name and new_name are required")
+		return
+	}
+	if err := validateMailboxName(req.NewName); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	accountID, ok := s.resolveAccount(w, r, req.Email)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	mbox, err := s.rdb.GetMailboxByNameWithRetry(ctx, accountID, req.OldName)
+	if err != nil {
+		s.writeMailboxError(w, "rename", err)
+		return
+	}
+	// The new parent is resolved from the name by RenameMailbox
+	if err := s.rdb.RenameMailboxWithRetry(ctx, mbox.ID, accountID, req.NewName, nil); err != nil {
+		s.writeMailboxError(w, "rename", err)
+		return
+	}
+	s.audit(r, db.AuditMailboxRename, req.Email, map[string]any{"old_name": req.OldName, "new_name": req.NewName})
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message":  "Mailbox renamed successfully",
+		"email":    req.Email,
+		"old_name": req.OldName,
+		"new_name": req.NewName,
+	})
+}
+
+// handleSubscribeMailbox handles POST /admin/mailboxes/subscribe
+func (s *Server) handleSubscribeMailbox(w http.ResponseWriter, r *http.Request) {
+	s.setMailboxSubscribed(w, r, true)
+}
+
+// handleUnsubscribeMailbox handles POST /admin/mailboxes/unsubscribe
+func (s *Server) handleUnsubscribeMailbox(w http.ResponseWriter, r *http.Request) {
+	s.setMailboxSubscribed(w, r, false)
+}
+
+func (s *Server) setMailboxSubscribed(w http.ResponseWriter, r *http.Request, subscribed bool) {
+	req, accountID, ok := s.decodeMailboxRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+	if subscribed {
+		err = s.rdb.SubscribeToMailboxWithRetry(r.Context(), accountID, req.Mailbox)
+	} else {
+		err = s.rdb.UnsubscribeFromMailboxWithRetry(r.Context(), accountID, req.Mailbox)
+	}
+	if err != nil {
+		s.writeMailboxError(w, "update", err)
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"email":      req.Email,
+		"mailbox":    req.Mailbox,
+		"subscribed": subscribed,
+	})
+}
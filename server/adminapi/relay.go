@@ -0,0 +1,210 @@
+package adminapi
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/relayqueue"
+)
+
+// relayAll is the message ID that selects every message in a queue, as in
+// 'sora-admin relay delete --id all'.
+const relayAll = "all"
+
+// relayDiskQueue returns the relay queue if it can be managed, or nil when
+// relay is not configured.
+func (s *Server) relayDiskQueue() *relayqueue.DiskQueue {
+	q, _ := s.relayQueue.(*relayqueue.DiskQueue)
+	return q
+}
+
+// requireRelayQueue writes a 503 and returns nil if relay is not configured.
+func (s *Server) requireRelayQueue(w http.ResponseWriter) *relayqueue.DiskQueue {
+	q := s.relayDiskQueue()
+	if q == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "Relay queue is not configured")
+	}
+	return q
+}
+
+// writeRelayError maps relay queue errors to responses
+func (s *Server) writeRelayError(w http.ResponseWriter, err error) {
+	if errors.Is(err, relayqueue.ErrMessageNotFound) {
+		s.writeError(w, http.StatusNotFound, "Message not found in queue")
+		return
+	}
+	if errors.Is(err, relayqueue.ErrInvalidQueue) || errors.Is(err, relayqueue.ErrInvalidMessageID) {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	logger.Warn("HTTP API: Relay queue error", "name", s.name, "error", err)
+	s.writeError(w, http.StatusInternalServerError, "Relay queue operation failed")
+}
+
+// handleRelayStats handles GET /admin/relay/stats
+func (s *Server) handleRelayStats(w http.ResponseWriter, r *http.Request) {
+	q := s.requireRelayQueue(w)
+	if q == nil {
+		return
+	}
+	pending, processing, failed, err := q.GetStats()
+	if err != nil {
+		s.writeRelayError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"pending":    pending,
+		"processing": processing,
+		"failed":     failed,
+		"total":      pending + processing + failed,
+	})
+}
+
+// handleRelayList handles GET /admin/relay/queue?queue=pending&limit=100
+func (s *Server) handleRelayList(w http.ResponseWriter, r *http.Request) {
+	q := s.requireRelayQueue(w)
+	if q == nil {
+		return
+	}
+	state := r.URL.Query().Get("queue")
+	if state == "" {
+		state = relayqueue.StatePending
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			s.writeError(w, http.StatusBadRequest, "Invalid 'limit' parameter")
+			return
+		}
+		limit = l
+	}
+
+	messages, err := q.List(state, limit)
+	if err != nil {
+		s.writeRelayError(w, err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"queue":    state,
+		"messages": messages,
+		"count":    len(messages),
+	})
+}
+
+// handleRelayMessageOperations routes /admin/relay/queue/{id}[/requeue]
+func (s *Server) handleRelayMessageOperations(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/admin/relay/queue/")
+	id, action, _ := strings.Cut(rest, "/")
+	id, err := url.PathUnescape(id)
+	if err != nil || id == "" {
+		s.writeError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == "GET" && id != relayAll:
+		s.handleRelayShow(w, r, id)
+	case action == "" && r.Method == "DELETE":
+		s.handleRelayDelete(w, r, id)
+	case action == "requeue" && r.Method == "POST":
+		s.handleRelayRequeue(w, r, id)
+	case action == "" || action == "requeue":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		s.writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// queueParam returns the queue query parameter, or def if it is not set.
+func queueParam(r *http.Request, def string) string {
+	if v := r.URL.Query().Get("queue"); v != "" {
+		return v
+	}
+	return def
+}
+
+// handleRelayShow handles GET /admin/relay/queue/{id}?queue=pending. The body
+// is included with include_body=true.
+func (s *Server) handleRelayShow(w http.ResponseWriter, r *http.Request, id string) {
+	q := s.requireRelayQueue(w)
+	if q == nil {
+		return
+	}
+	state := queueParam(r, relayqueue.StatePending)
+	msg, body, err := q.Get(state, id)
+	if err != nil {
+		s.writeRelayError(w, err)
+		return
+	}
+	resp := map[string]any{
+		"queue":     state,
+		"message":   msg,
+		"body_size": len(body),
+	}
+	if r.URL.Query().Get("include_body") == "true" {
+		resp["body"] = string(body)
+	}
+	s.writeJSON(w, http.StatusOK, resp)
+}
+
+// handleRelayDelete handles DELETE /admin/relay/queue/{id}?queue=failed,
+// where {id} may be "all".
+func (s *Server) handleRelayDelete(w http.ResponseWriter, r *http.Request, id string) {
+	q := s.requireRelayQueue(w)
+	if q == nil {
+		return
+	}
+	state := queueParam(r, relayqueue.StateFailed)
+
+	deleted := 1
+	var err error
+	if id == relayAll {
+		deleted, err = q.DeleteAll(state)
+	} else {
+		err = q.Delete(state, id)
+	}
+	if err != nil {
+		s.writeRelayError(w, err)
+		return
+	}
+	s.audit(r, db.AuditRelayDelete, "", map[string]any{"queue": state, "id": id, "deleted": deleted})
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"queue":   state,
+		"id":      id,
+		"deleted": deleted,
+	})
+}
+
+// handleRelayRequeue handles POST /admin/relay/queue/{id}/requeue, which
+// moves a failed message (or "all" of them) back to pending.
+func (s *Server) handleRelayRequeue(w http.ResponseWriter, r *http.Request, id string) {
+	q := s.requireRelayQueue(w)
+	if q == nil {
+		return
+	}
+
+	requeued := 1
+	var err error
+	if id == relayAll {
+		requeued, err = q.RequeueAll()
+	} else {
+		err = q.Requeue(id)
+	}
+	if err != nil {
+		s.writeRelayError(w, err)
+		return
+	}
+	s.audit(r, db.AuditRelayRequeue, "", map[string]any{"id": id, "requeued": requeued})
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"id":       id,
+		"requeued": requeued,
+	})
+}
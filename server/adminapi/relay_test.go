@@ -0,0 +1,89 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/migadu/sora/server/relayqueue"
+)
+
+func TestRelayEndpointsWithoutQueue(t *testing.T) {
+	server := &Server{apiKey: "test-api-key"}
+	rr := httptest.NewRecorder()
+	server.handleRelayStats(rr, httptest.NewRequest("GET", "/admin/relay/stats", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestRelayQueueEndpoints(t *testing.T) {
+	q, err := relayqueue.NewDiskQueue(t.TempDir(), 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Enqueue("sender@example.com", "rcpt@example.com", "redirect", []byte("body")); err != nil {
+		t.Fatal(err)
+	}
+	msg, _, err := q.AcquireNext()
+	if err != nil || msg == nil {
+		t.Fatalf("AcquireNext: %v", err)
+	}
+	if err := q.MarkPermanentFailure(msg.ID, "550 no such user"); err != nil {
+		t.Fatal(err)
+	}
+
+	server := &Server{apiKey: "test-api-key", relayQueue: q}
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if strings.HasPrefix(req.URL.Path, "/admin/relay/queue/") {
+			server.handleRelayMessageOperations(rr, req)
+		} else {
+			server.handleRelayList(rr, req)
+		}
+		return rr
+	}
+
+	rr := do("GET", "/admin/relay/queue?queue=failed")
+	var list struct {
+		Count    int                        `json:"count"`
+		Messages []relayqueue.QueuedMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || list.Count != 1 || list.Messages[0].ID != msg.ID {
+		t.Fatalf("list failed: %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := do("GET", "/admin/relay/queue?queue=bogus"); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid queue status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := do("GET", "/admin/relay/queue/"+msg.ID); rr.Code != http.StatusNotFound {
+		t.Errorf("show in pending status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+	if rr := do("GET", "/admin/relay/queue/"+msg.ID+"?queue=failed&include_body=true"); rr.Code != http.StatusOK {
+		t.Errorf("show status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	if rr := do("GET", "/admin/relay/queue/..?queue=failed"); rr.Code != http.StatusBadRequest {
+		t.Errorf("dot ID status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := do("PUT", "/admin/relay/queue/"+msg.ID); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT status = %v, want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+
+	if rr := do("POST", "/admin/relay/queue/all/requeue"); rr.Code != http.StatusOK {
+		t.Fatalf("requeue status = %v: %s", rr.Code, rr.Body.String())
+	}
+	pending, _, failed, _ := q.GetStats()
+	if pending != 1 || failed != 0 {
+		t.Fatalf("after requeue pending=%d failed=%d, want 1 and 0", pending, failed)
+	}
+
+	if rr := do("DELETE", "/admin/relay/queue/all?queue=pending"); rr.Code != http.StatusOK {
+		t.Fatalf("delete status = %v: %s", rr.Code, rr.Body.String())
+	}
+	if pending, _, _, _ := q.GetStats(); pending != 0 {
+		t.Errorf("after delete pending=%d, want 0", pending)
+	}
+}
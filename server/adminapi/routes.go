@@ -0,0 +1,143 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/delivery"
+)
+
+// maxRouteRequestSize bounds a mail route body, which may list many group members.
+const maxRouteRequestSize = 256 * 1024
+
+// PutMailRouteRequest creates or replaces a domain's mail route. Address is a
+// group address for kind "group", and "@domain" for "catchall" and
+// "domain_alias". A domain alias has the target domain as its only target.
+type PutMailRouteRequest struct {
+	Address string   `json:"address"`
+	Kind    string   `json:"kind"`
+	Targets []string `json:"targets"`
+}
+
+// isRoutesPath reports whether path is /admin/domains/{domain}/routes[/...]
+func isRoutesPath(path string) bool {
+	_, sub, _ := strings.Cut(strings.TrimPrefix(path, "/admin/domains/"), "/")
+	return sub == "routes" || strings.HasPrefix(sub, "routes/")
+}
+
+// handleRouteOperations routes /admin/domains/{domain}/routes[/{address}]
+func (s *Server) handleRouteOperations(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/admin/domains/")
+	domain, rest, _ := strings.Cut(rest, "/routes")
+	domain, err := url.PathUnescape(domain)
+	if err != nil || domain == "" {
+		s.writeError(w, http.StatusBadRequest, "Domain is required")
+		return
+	}
+	domain = strings.ToLower(domain)
+	if !s.authorizeDomain(w, r, domain) {
+		return
+	}
+
+	rest = strings.Trim(rest, "/")
+	switch {
+	case rest == "":
+		switch r.Method {
+		case "GET":
+			s.handleListMailRoutes(w, r, domain)
+		case "PUT":
+			s.handlePutMailRoute(w, r, domain)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case !strings.Contains(rest, "/"):
+		if r.Method != "DELETE" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleDeleteMailRoute(w, r, domain, strings.ToLower(rest))
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// writeRouteError maps mail route errors to responses
+func (s *Server) writeRouteError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, consts.ErrDBNotFound):
+		s.writeError(w, http.StatusNotFound, "Mail route not found")
+	case errors.Is(err, delivery.ErrInvalidMailRoute):
+		s.writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		logger.Warn("HTTP API: Error in mail route operation", "name", s.name, "operation", op, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to "+op+" mail route")
+	}
+}
+
+// handleListMailRoutes handles GET /admin/domains/{domain}/routes
+func (s *Server) handleListMailRoutes(w http.ResponseWriter, r *http.Request, domain string) {
+	routes, err := s.rdb.ListMailRoutesWithRetry(r.Context(), domain)
+	if err != nil {
+		s.writeRouteError(w, "list", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"domain": domain,
+		"routes": routes,
+		"total":  len(routes),
+	})
+}
+
+// handlePutMailRoute handles PUT /admin/domains/{domain}/routes
+func (s *Server) handlePutMailRoute(w http.ResponseWriter, r *http.Request, domain string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRouteRequestSize)
+	var req PutMailRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	route, err := delivery.NewMailRoute(req.Address, req.Kind, req.Targets)
+	if err != nil {
+		s.writeRouteError(w, "save", err)
+		return
+	}
+	if route.Domain != domain {
+		s.writeError(w, http.StatusBadRequest, "Route address must be in domain "+domain)
+		return
+	}
+	if err := s.rdb.PutMailRouteWithRetry(r.Context(), route); err != nil {
+		s.writeRouteError(w, "save", err)
+		return
+	}
+	s.audit(r, db.AuditRoutePut, route.Address, map[string]any{
+		"kind":    route.Kind,
+		"targets": route.Targets,
+	})
+
+	s.writeJSON(w, http.StatusOK, route)
+}
+
+// handleDeleteMailRoute handles DELETE /admin/domains/{domain}/routes/{address}
+func (s *Server) handleDeleteMailRoute(w http.ResponseWriter, r *http.Request, domain, address string) {
+	if !strings.HasSuffix(address, "@"+domain) {
+		s.writeError(w, http.StatusBadRequest, "Route address must be in domain "+domain)
+		return
+	}
+	if err := s.rdb.DeleteMailRouteWithRetry(r.Context(), address); err != nil {
+		s.writeRouteError(w, "delete", err)
+		return
+	}
+	s.audit(r, db.AuditRouteDelete, address, nil)
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Mail route deleted successfully",
+		"address": address,
+	})
+}
@@ -0,0 +1,47 @@
+package adminapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsRoutesPath(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/admin/domains/example.com/routes":                  true,
+		"/admin/domains/example.com/routes/team@example.com": true,
+		"/admin/domains/example.com/routes/@example.com":     true,
+		"/admin/domains/example.com/dkim/routes":             false,
+		"/admin/domains/example.com/accounts":                false,
+	} {
+		if got := isRoutesPath(path); got != want {
+			t.Errorf("isRoutesPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+// TestPutMailRouteValidation tests that invalid routes are rejected before
+// they reach the database.
+func TestPutMailRouteValidation(t *testing.T) {
+	server := &Server{apiKey: "test-api-key"}
+	for name, body := range map[string]string{
+		"invalid JSON":   `{`,
+		"unknown kind":   `{"address":"team@example.com","kind":"list","targets":["a@example.com"]}`,
+		"no targets":     `{"address":"team@example.com","kind":"group","targets":[]}`,
+		"other domain":   `{"address":"team@example.net","kind":"group","targets":["a@example.com"]}`,
+		"other catchall": `{"address":"@example.net","kind":"catchall","targets":["a@example.com"]}`,
+	} {
+		rr := httptest.NewRecorder()
+		server.handleRouteOperations(rr, httptest.NewRequest("PUT", "/admin/domains/example.com/routes", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %v, want %v", name, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	server.handleRouteOperations(rr, httptest.NewRequest("DELETE", "/admin/domains/example.com/routes/team@example.net", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("delete in other domain: status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
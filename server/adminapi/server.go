@@ -2,16 +2,17 @@ package adminapi
 
 import (
 	"context"
-	"crypto/subtle"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/netutil"
@@ -24,10 +25,14 @@ import (
 	"github.com/migadu/sora/db"
 	"github.com/migadu/sora/helpers"
 	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/pkg/srs"
 	"github.com/migadu/sora/server"
+	"github.com/migadu/sora/server/adminjobs"
 	"github.com/migadu/sora/server/delivery"
 	"github.com/migadu/sora/server/proxy"
+	"github.com/migadu/sora/server/sieveengine"
 	"github.com/migadu/sora/server/uploader"
+	"github.com/migadu/sora/server/webhook"
 	"github.com/migadu/sora/storage"
 )
 
@@ -44,12 +49,15 @@ type Server struct {
 	name               string
 	addr               string
 	apiKey             string
+	apiKeys            atomic.Pointer[apiKeyStore] // scoped keys from config and database; swapped on reload
 	allowedHosts       []string
 	rdb                *resilient.ResilientDatabase
 	cache              *cache.Cache
 	uploader           *uploader.UploadWorker
-	storage            *storage.S3Storage
+	storage            storage.Backend
 	relayQueue         delivery.RelayQueue // Global relay queue for mail delivery
+	srs                *srs.Rewriter       // Optional: rewrites the envelope sender of Sieve redirects
+	arcSealer          delivery.ARCSealer  // Optional: ARC-seals Sieve redirects
 	maxMessageSize     int64               // Max accepted mail-injection body size (bytes); 0 -> default
 	maxConnections     int                 // Max concurrent connections; 0 -> unlimited
 	server             *http.Server
@@ -69,18 +77,25 @@ type Server struct {
 	redirectRateLimit  int                                  // Max redirects per account
 	redirectRateWindow time.Duration                        // Window for redirect limit
 	maxRedirectHops    int                                  // Max redirect hops per message (mail-loop backstop)
+	webhooks           *webhook.Dispatcher                  // Outbound event webhooks (optional)
+	jobWorker          *adminjobs.Worker                    // Local job worker, woken on submit (optional)
+	jobMaxAttempts     int                                  // Default attempts for submitted jobs
+	sieveExtensions    []string                             // extensions scripts are validated and run with
 }
 
 // ServerOptions holds configuration options for the HTTP API server
 type ServerOptions struct {
 	Name               string
 	Addr               string
-	APIKey             string
+	APIKey             string                     // Full-access key (optional when APIKeys is set)
+	APIKeys            []config.AdminAPIKeyConfig // Scoped keys from the config file
 	AllowedHosts       []string
 	Cache              *cache.Cache
 	Uploader           *uploader.UploadWorker
-	Storage            *storage.S3Storage
+	Storage            storage.Backend
 	RelayQueue         delivery.RelayQueue // Global relay queue for mail delivery
+	SRS                *srs.Rewriter       // Optional: Sender Rewriting Scheme for Sieve redirects
+	ARCSealer          delivery.ARCSealer  // Optional: ARC-seals Sieve redirects
 	MaxMessageSize     int64               // Max accepted mail-injection body size (bytes); 0 -> 50MB default
 	MaxConnections     int                 // Max concurrent connections; 0 -> unlimited
 	TLS                bool
@@ -98,6 +113,10 @@ type ServerOptions struct {
 	RedirectRateLimit  int
 	RedirectRateWindow time.Duration
 	MaxRedirectHops    int
+	Webhooks           *webhook.Dispatcher // Outbound event webhooks (optional)
+	JobWorker          *adminjobs.Worker   // Local job worker, woken on submit (optional)
+	JobMaxAttempts     int                 // Default attempts for submitted jobs; 0 -> 3
+	SieveExtensions    []string            // Sieve extensions to enable (nil/empty = all default extensions)
 
 	// PROXY protocol for incoming connections (from HAProxy, nginx, etc.)
 	ProxyProtocol               bool     // Enable PROXY protocol support for incoming connections
@@ -134,6 +153,9 @@ type BackendHealthInfo struct {
 	LastSuccess        time.Time `json:"last_success,omitempty"`
 	HealthCheckEnabled bool      `json:"health_check_enabled"`
 	IsRemoteLookup     bool      `json:"is_remote_lookup"` // True if backend from remote_lookup (not in pool)
+	Weight             int       `json:"weight"`
+	Draining           bool      `json:"draining"`
+	ActiveSessions     int       `json:"active_sessions"` // Sessions on this proxy (0 without a connection tracker)
 }
 
 // ProxyBackendsResponse is the response structure for /admin/proxy/backends
@@ -155,16 +177,21 @@ const minAPIKeyLength = 16
 
 // New creates a new HTTP API server
 func New(rdb *resilient.ResilientDatabase, options ServerOptions) (*Server, error) {
-	if options.APIKey == "" {
-		return nil, fmt.Errorf("API key is required for HTTP API server")
+	// The full-access api_key may be omitted when scoped api_keys are configured.
+	if options.APIKey == "" && len(options.APIKeys) == 0 {
+		return nil, fmt.Errorf("API key is required for HTTP API server (set api_key or api_keys)")
 	}
-	if len(options.APIKey) < minAPIKeyLength {
+	if options.APIKey != "" && len(options.APIKey) < minAPIKeyLength {
 		return nil, fmt.Errorf("API key must be at least %d characters (got %d) — use a long, random value", minAPIKeyLength, len(options.APIKey))
 	}
 	switch options.APIKey {
 	case "your-api-key-here", "your-secret-api-key-here":
 		return nil, fmt.Errorf("API key is the placeholder value from config.toml.example — set a real, random api_key")
 	}
+	apiKeys, err := newAPIKeyStore(rdb, options.APIKeys)
+	if err != nil {
+		return nil, err
+	}
 
 	// Validate TLS configuration
 	if options.TLS {
@@ -202,6 +229,8 @@ func New(rdb *resilient.ResilientDatabase, options ServerOptions) (*Server, erro
 		uploader:           options.Uploader,
 		storage:            options.Storage,
 		relayQueue:         options.RelayQueue,
+		srs:                options.SRS,
+		arcSealer:          options.ARCSealer,
 		maxMessageSize:     options.MaxMessageSize,
 		maxConnections:     options.MaxConnections,
 		tls:                options.TLS,
@@ -220,7 +249,15 @@ func New(rdb *resilient.ResilientDatabase, options ServerOptions) (*Server, erro
 		redirectRateLimit:  options.RedirectRateLimit,
 		redirectRateWindow: options.RedirectRateWindow,
 		maxRedirectHops:    options.MaxRedirectHops,
+		webhooks:           options.Webhooks,
+		jobWorker:          options.JobWorker,
+		jobMaxAttempts:     options.JobMaxAttempts,
+		sieveExtensions:    options.SieveExtensions,
+	}
+	if len(s.sieveExtensions) == 0 {
+		s.sieveExtensions = sieveengine.DefaultSieveExtensions
 	}
+	s.apiKeys.Store(apiKeys)
 
 	return s, nil
 }
@@ -239,6 +276,14 @@ func (s *Server) ReloadConfig(cfg config.ServerConfig) error {
 		s.apiKey = cfg.APIKey
 		reloaded = append(reloaded, "api_key")
 	}
+	// Always rebuild the key store, so removing every [[server.api_keys]]
+	// entry revokes the keys instead of leaving the old ones in place
+	apiKeys, err := newAPIKeyStore(s.rdb, cfg.APIKeys)
+	if err != nil {
+		return fmt.Errorf("invalid api_keys: %w", err)
+	}
+	s.apiKeys.Store(apiKeys)
+	reloaded = append(reloaded, "api_keys")
 	if len(cfg.AllowedHosts) > 0 {
 		s.allowedHosts = cfg.AllowedHosts
 		reloaded = append(reloaded, "allowed_hosts")
@@ -356,62 +401,107 @@ func (s *Server) start(ctx context.Context) error {
 func (s *Server) setupRoutes() http.Handler {
 	mux := http.NewServeMux()
 
+	// Every route is wrapped with the scope it needs (see apikeys.go).
+	// requireGlobalScope marks server-wide routes that domain-restricted keys
+	// cannot use; the others check domains inside the handler.
+
 	// Account management routes
-	mux.HandleFunc("/admin/accounts", routeHandler("POST", s.handleCreateAccount))
-	mux.HandleFunc("/admin/accounts/", s.handleAccountOperations)
+	mux.HandleFunc("/admin/accounts", routeHandler("POST", s.requireScope(ScopeAccounts, s.handleCreateAccount)))
+	mux.HandleFunc("/admin/accounts/", s.requireScope(ScopeAccounts, s.handleAccountOperations))
 
 	// Domain-scoped account management routes
-	mux.HandleFunc("/admin/domains/", s.handleDomainOperations)
+	mux.HandleFunc("/admin/domains/", s.requireScope(ScopeAccounts, s.handleDomainOperations))
 
 	// Credential management routes
-	mux.HandleFunc("/admin/credentials/", s.handleCredentialOperations)
+	mux.HandleFunc("/admin/credentials/", s.requireScope(ScopeAccounts, s.handleCredentialOperations))
 
 	// Connection management routes
-	mux.HandleFunc("/admin/connections", routeHandler("GET", s.handleListConnections))
-	mux.HandleFunc("/admin/connections/stats", routeHandler("GET", s.handleConnectionStats))
-	mux.HandleFunc("/admin/connections/kick", routeHandler("POST", s.handleKickConnections))
-	mux.HandleFunc("/admin/connections/user/", routeHandler("GET", s.handleGetUserConnections))
+	mux.HandleFunc("/admin/connections", routeHandler("GET", s.requireScope(ScopeConnections, s.handleListConnections)))
+	mux.HandleFunc("/admin/connections/stats", routeHandler("GET", s.requireGlobalScope(ScopeConnections, s.handleConnectionStats)))
+	mux.HandleFunc("/admin/connections/kick", routeHandler("POST", s.requireScope(ScopeConnections, s.handleKickConnections)))
+	mux.HandleFunc("/admin/connections/user/", routeHandler("GET", s.requireScope(ScopeConnections, s.handleGetUserConnections)))
 
 	// Cache management routes
-	mux.HandleFunc("/admin/cache/stats", routeHandler("GET", s.handleCacheStats))
-	mux.HandleFunc("/admin/cache/metrics", routeHandler("GET", s.handleCacheMetrics))
-	mux.HandleFunc("/admin/cache/purge", routeHandler("POST", s.handleCachePurge))
+	mux.HandleFunc("/admin/cache/stats", routeHandler("GET", s.requireGlobalScope(ScopeCache, s.handleCacheStats)))
+	mux.HandleFunc("/admin/cache/metrics", routeHandler("GET", s.requireGlobalScope(ScopeCache, s.handleCacheMetrics)))
+	mux.HandleFunc("/admin/cache/purge", routeHandler("POST", s.requireGlobalScope(ScopeCache, s.handleCachePurge)))
 
 	// Uploader routes
-	mux.HandleFunc("/admin/uploader/status", routeHandler("GET", s.handleUploaderStatus))
-	mux.HandleFunc("/admin/uploader/failed", routeHandler("GET", s.handleFailedUploads))
+	mux.HandleFunc("/admin/uploader/status", routeHandler("GET", s.requireGlobalScope(ScopeCache, s.handleUploaderStatus)))
+	mux.HandleFunc("/admin/uploader/failed", routeHandler("GET", s.requireGlobalScope(ScopeCache, s.handleFailedUploads)))
+	mux.HandleFunc("/admin/uploader/resolve", routeHandler("POST", s.requireGlobalScope(ScopeCache, s.handleResolveUploads)))
 
 	// Authentication statistics routes
-	mux.HandleFunc("/admin/auth/stats", routeHandler("GET", s.handleAuthStats))
-	mux.HandleFunc("/admin/auth/blocked", routeHandler("GET", s.handleAuthBlocked))
-	mux.HandleFunc("/admin/auth-cache/stats", routeHandler("GET", s.handleAuthCacheStats))
+	mux.HandleFunc("/admin/auth/stats", routeHandler("GET", s.requireGlobalScope(ScopeCache, s.handleAuthStats)))
+	mux.HandleFunc("/admin/auth/blocked", s.requireGlobalScope(ScopeCache, multiMethodHandler(map[string]http.HandlerFunc{
+		"GET":    s.handleAuthBlocked,
+		"DELETE": s.handleAuthUnblock,
+	})))
+	mux.HandleFunc("/admin/access-list", s.requireGlobalScope(ScopeCache, multiMethodHandler(map[string]http.HandlerFunc{
+		"GET":  s.handleAccessList,
+		"POST": s.handleAccessList,
+	})))
+	mux.HandleFunc("/admin/access-list/", s.requireGlobalScope(ScopeCache, s.handleAccessListOperations))
+	mux.HandleFunc("/admin/auth-cache/stats", routeHandler("GET", s.requireGlobalScope(ScopeCache, s.handleAuthCacheStats)))
 
 	// Health monitoring routes
-	mux.HandleFunc("/admin/health/overview", routeHandler("GET", s.handleHealthOverview))
-	mux.HandleFunc("/admin/health/servers/", s.handleHealthOperations)
+	mux.HandleFunc("/admin/health/overview", routeHandler("GET", s.requireGlobalScope(ScopeCache, s.handleHealthOverview)))
+	mux.HandleFunc("/admin/health/servers/", s.requireGlobalScope(ScopeCache, s.handleHealthOperations))
 
 	// Proxy backend health routes
-	mux.HandleFunc("/admin/proxy/backends", routeHandler("GET", s.handleProxyBackends))
+	mux.HandleFunc("/admin/proxy/backends", routeHandler("GET", s.requireGlobalScope(ScopeConnections, s.handleProxyBackends)))
+	mux.HandleFunc("/admin/proxy/backends/drain", s.requireGlobalScope(ScopeConnections, multiMethodHandler(map[string]http.HandlerFunc{
+		"GET":    s.handleBackendDrain,
+		"POST":   s.handleBackendDrain,
+		"DELETE": s.handleBackendDrain,
+	})))
 
 	// System configuration and status routes
-	mux.HandleFunc("/admin/config", routeHandler("GET", s.handleConfigInfo))
+	mux.HandleFunc("/admin/config", routeHandler("GET", s.requireGlobalScope(ScopeRead, s.handleConfigInfo)))
 
 	// Mail delivery route
-	mux.HandleFunc("/admin/mail/deliver", routeHandler("POST", s.handleDeliverMail))
+	mux.HandleFunc("/admin/mail/deliver", routeHandler("POST", s.requireScope(ScopeDelivery, s.handleDeliverMail)))
+
+	// Delivery log route
+	mux.HandleFunc("/admin/delivery-log", routeHandler("GET", s.requireScope(ScopeDelivery, s.handleDeliveryLogSearch)))
 
 	// ACL management routes
-	mux.HandleFunc("/admin/mailboxes/acl/grant", routeHandler("POST", s.handleACLGrant))
-	mux.HandleFunc("/admin/mailboxes/acl/revoke", routeHandler("POST", s.handleACLRevoke))
-	mux.HandleFunc("/admin/mailboxes/acl", routeHandler("GET", s.handleACLList))
+	mux.HandleFunc("/admin/mailboxes/acl/grant", routeHandler("POST", s.requireScope(ScopeAccounts, s.handleACLGrant)))
+	mux.HandleFunc("/admin/mailboxes/acl/revoke", routeHandler("POST", s.requireScope(ScopeAccounts, s.handleACLRevoke)))
+	mux.HandleFunc("/admin/mailboxes/acl", routeHandler("GET", s.requireScope(ScopeAccounts, s.handleACLList)))
+
+	// Mailbox management routes
+	mux.HandleFunc("/admin/mailboxes", routeHandler("GET", s.requireScope(ScopeAccounts, s.handleListMailboxes)))
+	mux.HandleFunc("/admin/mailboxes/create", routeHandler("POST", s.requireScope(ScopeAccounts, s.handleCreateMailbox)))
+	mux.HandleFunc("/admin/mailboxes/delete", routeHandler("POST", s.requireScope(ScopeAccounts, s.handleDeleteMailbox)))
+	mux.HandleFunc("/admin/mailboxes/rename", routeHandler("POST", s.requireScope(ScopeAccounts, s.handleRenameMailbox)))
+	mux.HandleFunc("/admin/mailboxes/subscribe", routeHandler("POST", s.requireScope(ScopeAccounts, s.handleSubscribeMailbox)))
+	mux.HandleFunc("/admin/mailboxes/unsubscribe", routeHandler("POST", s.requireScope(ScopeAccounts, s.handleUnsubscribeMailbox)))
+
+	// Relay queue routes
+	mux.HandleFunc("/admin/relay/stats", routeHandler("GET", s.requireGlobalScope(ScopeDelivery, s.handleRelayStats)))
+	mux.HandleFunc("/admin/relay/queue", routeHandler("GET", s.requireGlobalScope(ScopeDelivery, s.handleRelayList)))
+	mux.HandleFunc("/admin/relay/queue/", s.requireGlobalScope(ScopeDelivery, s.handleRelayMessageOperations))
 
 	// Affinity management routes
-	mux.HandleFunc("/admin/affinity", multiMethodHandler(map[string]http.HandlerFunc{
+	mux.HandleFunc("/admin/affinity", s.requireScope(ScopeConnections, multiMethodHandler(map[string]http.HandlerFunc{
 		"GET":    s.handleAffinityGet,
 		"POST":   s.handleAffinitySet,
 		"DELETE": s.handleAffinityDelete,
-	}))
-	mux.HandleFunc("/admin/affinity/list", routeHandler("GET", s.handleAffinityList))
-	mux.HandleFunc("/admin/affinity/stats", routeHandler("GET", s.handleAffinityStats))
+	})))
+	mux.HandleFunc("/admin/affinity/list", routeHandler("GET", s.requireGlobalScope(ScopeConnections, s.handleAffinityList)))
+	mux.HandleFunc("/admin/affinity/stats", routeHandler("GET", s.requireGlobalScope(ScopeConnections, s.handleAffinityStats)))
+
+	// Admin job routes
+	mux.HandleFunc("/admin/jobs", s.requireGlobalScope(ScopeAccounts, multiMethodHandler(map[string]http.HandlerFunc{
+		"GET":  s.handleJobs,
+		"POST": s.handleJobs,
+	})))
+	mux.HandleFunc("/admin/jobs/", s.requireGlobalScope(ScopeAccounts, s.handleJobOperations))
+
+	// Audit log routes
+	mux.HandleFunc("/admin/audit", routeHandler("GET", s.requireGlobalScope(ScopeRead, s.handleAuditList)))
+	mux.HandleFunc("/admin/audit/verify", routeHandler("GET", s.requireGlobalScope(ScopeRead, s.handleAuditVerify)))
 
 	// Wrap with middleware (in reverse order - last applied is outermost).
 	// The host allowlist is outermost so off-allowlist clients are rejected before
@@ -462,6 +552,18 @@ func (s *Server) handleAccountOperations(w http.ResponseWriter, r *http.Request)
 		s.handleAccountExists(w, r)
 		return
 	}
+	if strings.Contains(path, "/sieve/") || strings.HasSuffix(path, "/sieve") {
+		s.handleSieveOperations(w, r)
+		return
+	}
+	if strings.HasSuffix(path, "/vacation") {
+		s.handleVacationOperations(w, r)
+		return
+	}
+	if strings.HasSuffix(path, "/delivery") {
+		s.handleAccountDeliveryOperations(w, r)
+		return
+	}
 	if strings.Contains(path, "/credentials") {
 		switch r.Method {
 		case "GET":
@@ -503,6 +605,24 @@ func (s *Server) handleCredentialOperations(w http.ResponseWriter, r *http.Reque
 func (s *Server) handleDomainOperations(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 
+	// Check for /admin/domains/{domain}/routes[/...]
+	if isRoutesPath(path) {
+		s.handleRouteOperations(w, r)
+		return
+	}
+
+	// Check for /admin/domains/{domain}/delivery
+	if strings.HasSuffix(path, "/delivery") {
+		s.handleDomainDeliveryOperations(w, r)
+		return
+	}
+
+	// Check for /admin/domains/{domain}/dkim[/...]
+	if strings.Contains(path, "/dkim") {
+		s.handleDKIMOperations(w, r)
+		return
+	}
+
 	// Check for /admin/domains/{domain}/accounts
 	if strings.Contains(path, "/accounts") {
 		if r.Method != "GET" {
@@ -587,12 +707,19 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		if subtle.ConstantTimeCompare([]byte(parts[1]), []byte(s.apiKey)) != 1 {
+		principal, err := s.authenticate(r.Context(), parts[1])
+		if err != nil {
+			logger.Warn("HTTP API: Error looking up API key", "name", s.name, "error", err)
+			s.writeError(w, http.StatusServiceUnavailable, "Unable to verify API key")
+			return
+		}
+		if principal == nil {
 			s.writeError(w, http.StatusForbidden, "Invalid API key")
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		ctx := withPrincipal(withAuditActor(r, principal), principal)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -686,6 +813,9 @@ func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Credential %d: email is required", i+1))
 				return
 			}
+			if !s.authorizeAddress(w, r, cred.Email) {
+				return
+			}
 			if cred.Password == "" && cred.PasswordHash == "" {
 				s.writeError(w, http.StatusBadRequest, fmt.Sprintf("Credential %d: either password or password_hash is required", i+1))
 				return
@@ -727,10 +857,17 @@ func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 
 		// Prepare response with all created credentials
 		var createdCredentials []string
+		primary := req.Credentials[0].Email
 		for _, cred := range req.Credentials {
 			createdCredentials = append(createdCredentials, cred.Email)
+			if cred.IsPrimary {
+				primary = cred.Email
+			}
 		}
 
+		s.webhooks.Emit(webhook.NewEvent(webhook.EventAccountCreated, "adminapi", accountID, primary,
+			map[string]any{"credentials": createdCredentials}))
+
 		s.writeJSON(w, http.StatusCreated, map[string]any{
 			"account_id":  accountID,
 			"credentials": createdCredentials,
@@ -741,6 +878,9 @@ func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 			s.writeError(w, http.StatusBadRequest, "Email is required")
 			return
 		}
+		if !s.authorizeAddress(w, r, req.Email) {
+			return
+		}
 
 		if req.Password == "" && req.PasswordHash == "" {
 			s.writeError(w, http.StatusBadRequest, "Either password or password_hash is required")
@@ -772,6 +912,8 @@ func (s *Server) handleCreateAccount(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		s.webhooks.Emit(webhook.NewEvent(webhook.EventAccountCreated, "adminapi", accountID, req.Email, nil))
+
 		s.writeJSON(w, http.StatusCreated, map[string]any{
 			"account_id": accountID,
 			"email":      req.Email,
@@ -789,6 +931,9 @@ func (s *Server) handleListAccountsByDomain(w http.ResponseWriter, r *http.Reque
 		s.writeError(w, http.StatusBadRequest, "Domain is required")
 		return
 	}
+	if !s.authorizeDomain(w, r, domain) {
+		return
+	}
 
 	summaries, err := s.rdb.ListAccountsByDomainWithRetry(ctx, domain)
 	if err != nil {
@@ -813,6 +958,9 @@ func (s *Server) handleListAccountsByDomain(w http.ResponseWriter, r *http.Reque
 func (s *Server) handleAccountExists(w http.ResponseWriter, r *http.Request) {
 	// Extract email from path: /admin/accounts/{email}/exists
 	email := extractPathParam(r.URL.Path, "/admin/accounts/", "/exists")
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 
 	ctx := r.Context()
 
@@ -834,6 +982,9 @@ func (s *Server) handleAccountExists(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGetAccount(w http.ResponseWriter, r *http.Request) {
 	// Extract email from path: /admin/accounts/{email}
 	email := extractLastPathSegment(r.URL.Path)
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 	ctx := r.Context()
 
 	accountDetails, err := s.rdb.GetAccountDetailsWithRetry(ctx, email)
@@ -854,6 +1005,9 @@ func (s *Server) handleUpdateAccount(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	// Extract email from path: /admin/accounts/{email}
 	email := extractLastPathSegment(r.URL.Path)
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 
 	var req UpdateAccountRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -896,6 +1050,9 @@ func (s *Server) handleUpdateAccount(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 	// Extract email from path: /admin/accounts/{email}
 	email := extractLastPathSegment(r.URL.Path)
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 	ctx := r.Context()
 
 	err := s.rdb.DeleteAccountWithRetry(ctx, email)
@@ -914,6 +1071,8 @@ func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	s.webhooks.Emit(webhook.NewEvent(webhook.EventAccountDeleted, "adminapi", 0, email, nil))
+
 	s.writeJSON(w, http.StatusOK, map[string]any{
 		"email":   email,
 		"message": "Account soft-deleted successfully. It will be permanently removed after the grace period.",
@@ -923,6 +1082,9 @@ func (s *Server) handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleRestoreAccount(w http.ResponseWriter, r *http.Request) {
 	// Extract email from path: /admin/accounts/{email}/restore
 	email := extractPathParam(r.URL.Path, "/admin/accounts/", "/restore")
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 	ctx := r.Context()
 
 	err := s.rdb.RestoreAccountWithRetry(ctx, email)
@@ -951,6 +1113,9 @@ func (s *Server) handleAddCredential(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	// Extract email from path: /admin/accounts/{email}/credentials
 	primaryEmail := extractPathParam(r.URL.Path, "/admin/accounts/", "/credentials")
+	if !s.authorizeAddress(w, r, primaryEmail) {
+		return
+	}
 
 	var req AddCredentialRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -962,6 +1127,9 @@ func (s *Server) handleAddCredential(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusBadRequest, "Email is required")
 		return
 	}
+	if !s.authorizeAddress(w, r, req.Email) {
+		return
+	}
 
 	if req.Password == "" && req.PasswordHash == "" {
 		s.writeError(w, http.StatusBadRequest, "Either password or password_hash is required")
@@ -1017,6 +1185,9 @@ func (s *Server) handleAddCredential(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleListCredentials(w http.ResponseWriter, r *http.Request) {
 	// Extract email from path: /admin/accounts/{email}/credentials
 	email := extractPathParam(r.URL.Path, "/admin/accounts/", "/credentials")
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 
 	ctx := r.Context()
 
@@ -1041,6 +1212,9 @@ func (s *Server) handleListCredentials(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGetCredential(w http.ResponseWriter, r *http.Request) {
 	// Extract email from path: /admin/credentials/{email}
 	email := extractLastPathSegment(r.URL.Path)
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 	ctx := r.Context()
 
 	// Get detailed credential information using the same logic as CLI
@@ -1061,6 +1235,9 @@ func (s *Server) handleGetCredential(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleDeleteCredential(w http.ResponseWriter, r *http.Request) {
 	// Extract email from path: /admin/credentials/{email}
 	email := extractLastPathSegment(r.URL.Path)
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 	ctx := r.Context()
 
 	err := s.rdb.DeleteCredentialWithRetry(ctx, email)
@@ -1123,6 +1300,8 @@ func (s *Server) handleListConnections(w http.ResponseWriter, r *http.Request) {
 	// Collect connections from all trackers
 	// Only report connections where this tracker instance has actual local connections
 	// This prevents duplicate entries where trackers report gossip data from other instances
+	// Domain-restricted keys only see connections in their domains.
+	principal, restricted := principalRestricted(r)
 	allConnections := make([]map[string]any, 0)
 	for trackerKey, tracker := range s.connectionTrackers {
 		if tracker == nil {
@@ -1137,6 +1316,9 @@ func (s *Server) handleListConnections(w http.ResponseWriter, r *http.Request) {
 			if localCount == 0 {
 				continue // Skip - this is only gossip data, not an actual connection on this instance
 			}
+			if restricted && !principal.allowsAddress(connInfo.Username) {
+				continue
+			}
 
 			// Extract protocol from tracker key
 			// Key format: "PROTOCOL-hostname-servername" or "PROTOCOL-servername"
@@ -1177,6 +1359,9 @@ func (s *Server) handleKickConnections(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusBadRequest, "user_email is required for kick operation")
 		return
 	}
+	if !s.authorizeAddress(w, r, req.UserEmail) {
+		return
+	}
 
 	// Get account ID from email
 	// Try database first (for local accounts)
@@ -1256,6 +1441,10 @@ func (s *Server) handleKickConnections(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusInternalServerError, "Failed to kick user on any protocol")
 		return
 	}
+	s.audit(r, db.AuditConnectionKick, req.UserEmail, map[string]any{
+		"account_id": accountID,
+		"trackers":   kickedProtocols,
+	})
 
 	s.writeJSON(w, http.StatusOK, map[string]any{
 		"message":   "User kicked successfully via gossip protocol",
@@ -1300,6 +1489,9 @@ func (s *Server) handleConnectionStats(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGetUserConnections(w http.ResponseWriter, r *http.Request) {
 	// Extract email from path: /admin/connections/user/{email}
 	email := extractLastPathSegment(r.URL.Path)
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 
 	if len(s.connectionTrackers) == 0 {
 		// No connection trackers available
@@ -1453,6 +1645,11 @@ func (s *Server) handleCachePurge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.audit(r, db.AuditCachePurge, "", map[string]any{
+		"before": statsBefore,
+		"after":  statsAfter,
+	})
+
 	s.writeJSON(w, http.StatusOK, map[string]any{
 		"message":      "Cache purged successfully",
 		"stats_before": statsBefore,
@@ -1598,6 +1795,9 @@ func (s *Server) handleProxyBackends(w http.ResponseWriter, r *http.Request) {
 					LastSuccess:        status.LastSuccess,
 					HealthCheckEnabled: status.HealthCheckEnabled,
 					IsRemoteLookup:     status.IsRemoteLookup,
+					Weight:             status.Weight,
+					Draining:           status.Draining,
+					ActiveSessions:     status.ActiveSessions,
 				}
 			}
 
@@ -1697,6 +1897,53 @@ func (s *Server) handleFailedUploads(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ResolveUploadsRequest is the request body for resolving failed uploads
+type ResolveUploadsRequest struct {
+	DryRun      bool `json:"dry_run"`
+	Limit       int  `json:"limit,omitempty"`        // Default 100
+	MaxAttempts int  `json:"max_attempts,omitempty"` // Default 5
+}
+
+// handleResolveUploads handles POST /admin/uploader/resolve, the equivalent of
+// 'sora-admin uploader resolve'.
+func (s *Server) handleResolveUploads(w http.ResponseWriter, r *http.Request) {
+	var req ResolveUploadsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Limit < 0 || req.MaxAttempts < 0 {
+		s.writeError(w, http.StatusBadRequest, "limit and max_attempts must be positive")
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+	if req.MaxAttempts == 0 {
+		req.MaxAttempts = 5
+	}
+	if s.storage == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "Storage is not configured")
+		return
+	}
+
+	report, err := uploader.ResolveFailedUploads(r.Context(), s.rdb, s.storage, req.MaxAttempts, req.Limit, req.DryRun)
+	if err != nil {
+		logger.Warn("HTTP API: Error resolving failed uploads", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to resolve failed uploads")
+		return
+	}
+	if !req.DryRun {
+		s.audit(r, db.AuditUploadsResolve, "", map[string]any{
+			"repaired": report.Repaired,
+			"deleted":  report.Deleted,
+			"skipped":  report.Skipped,
+		})
+	}
+
+	s.writeJSON(w, http.StatusOK, report)
+}
+
 func (s *Server) handleAuthStats(w http.ResponseWriter, r *http.Request) {
 	// Auth statistics are tracked in-memory via the rate limiter.
 	// The window parameter is accepted for backwards compatibility but ignored.
@@ -1708,6 +1955,8 @@ func (s *Server) handleAuthStats(w http.ResponseWriter, r *http.Request) {
 		},
 		"access_methods": map[string]string{
 			"blocked_list": "GET /admin/auth/blocked - list currently blocked addresses",
+			"unblock":      "DELETE /admin/auth/blocked?ip=&username= - lift blocks cluster-wide",
+			"access_list":  "GET|POST /admin/access-list - persistent block/allow entries",
 		},
 	})
 }
@@ -1762,6 +2011,9 @@ func (s *Server) handleListDeletedMessages(w http.ResponseWriter, r *http.Reques
 	// Extract email from path: /admin/accounts/{email}/messages/deleted
 	email := extractPathParam(r.URL.Path, "/admin/accounts/", "/messages/deleted")
 	email, _ = url.QueryUnescape(email) // Decode URL-encoded characters
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 	ctx := r.Context()
 
 	// Parse query parameters
@@ -1837,6 +2089,9 @@ func (s *Server) handleRestoreMessages(w http.ResponseWriter, r *http.Request) {
 	// Extract email from path: /admin/accounts/{email}/messages/restore
 	email := extractPathParam(r.URL.Path, "/admin/accounts/", "/messages/restore")
 	email, _ = url.QueryUnescape(email) // Decode URL-encoded characters
+	if !s.authorizeAddress(w, r, email) {
+		return
+	}
 	ctx := r.Context()
 
 	var req struct {
@@ -1957,6 +2212,12 @@ func (s *Server) handleConfigInfo(w http.ResponseWriter, r *http.Request) {
 			},
 			"domain_management": {
 				"GET /admin/domains/{domain}/accounts (list accounts scoped to domain)",
+				"GET|POST /admin/domains/{domain}/dkim",
+				"DELETE /admin/domains/{domain}/dkim/{selector}",
+				"POST /admin/domains/{domain}/dkim/{selector}/activate",
+				"GET|PUT /admin/domains/{domain}/routes",
+				"DELETE /admin/domains/{domain}/routes/{address}",
+				"GET|PUT|DELETE /admin/domains/{domain}/delivery",
 			},
 			"credential_management": {
 				"GET /admin/credentials/{email}",
@@ -1984,10 +2245,45 @@ func (s *Server) handleConfigInfo(w http.ResponseWriter, r *http.Request) {
 			"uploader_monitoring": {
 				"GET /admin/uploader/status",
 				"GET /admin/uploader/failed",
+				"POST /admin/uploader/resolve",
+			},
+			"mailbox_management": {
+				"GET /admin/mailboxes?email={email}",
+				"POST /admin/mailboxes/create",
+				"POST /admin/mailboxes/delete",
+				"POST /admin/mailboxes/rename",
+				"POST /admin/mailboxes/subscribe",
+				"POST /admin/mailboxes/unsubscribe",
+			},
+			"sieve_management": {
+				"GET /admin/accounts/{email}/sieve",
+				"GET|PUT|DELETE /admin/accounts/{email}/sieve/{name}",
+				"POST /admin/accounts/{email}/sieve/{name}/activate",
+				"POST /admin/accounts/{email}/sieve/{name}/rename",
+				"POST /admin/accounts/{email}/sieve/deactivate",
+				"GET|PUT|DELETE /admin/accounts/{email}/vacation",
+				"GET|PUT|DELETE /admin/accounts/{email}/delivery",
+			},
+			"relay_queue": {
+				"GET /admin/relay/stats",
+				"GET /admin/relay/queue",
+				"GET|DELETE /admin/relay/queue/{id}",
+				"POST /admin/relay/queue/{id}/requeue",
+			},
+			"admin_jobs": {
+				"GET|POST /admin/jobs",
+				"GET /admin/jobs/{id}",
+				"POST /admin/jobs/{id}/cancel",
+				"POST /admin/jobs/{id}/retry",
 			},
 			"auth_statistics": {
 				"GET /admin/auth/stats",
-				"GET /admin/auth/blocked",
+				"GET|DELETE /admin/auth/blocked",
+			},
+			"access_list": {
+				"GET|POST /admin/access-list",
+				"DELETE /admin/access-list/{id}",
+				"GET /admin/access-list/export?format=plain|nftables",
 			},
 			"system_information": {
 				"GET /admin/config",
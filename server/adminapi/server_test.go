@@ -926,3 +926,77 @@ func TestACLListRequestValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestMailboxRequestValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		requestBody    string
+		expectedStatus int
+		expectedError  string
+	}{
+		{"invalid JSON", "/admin/mailboxes/create", "invalid-json", http.StatusBadRequest, "Invalid request body"},
+		{"empty mailbox", "/admin/mailboxes/create", `{"email":"user@example.com","mailbox":""}`, http.StatusBadRequest, "mailbox name cannot be empty"},
+		{"newline in mailbox", "/admin/mailboxes/create", `{"email":"user@example.com","mailbox":"a\nb"}`, http.StatusBadRequest, "invalid characters"},
+		{"missing email", "/admin/mailboxes/subscribe", `{"mailbox":"Projects"}`, http.StatusBadRequest, "email is required"},
+		{"rename missing new name", "/admin/mailboxes/rename", `{"email":"user@example.com","old_name":"A"}`, http.StatusBadRequest, "old_name and new_name are required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.requestBody))
+			rr := httptest.NewRecorder()
+			server := &Server{apiKey: "test-api-key"}
+
+			switch tt.path {
+			case "/admin/mailboxes/rename":
+				server.handleRenameMailbox(rr, req)
+			case "/admin/mailboxes/subscribe":
+				server.handleSubscribeMailbox(rr, req)
+			default:
+				server.handleCreateMailbox(rr, req)
+			}
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+			if !strings.Contains(rr.Body.String(), tt.expectedError) {
+				t.Errorf("body = %v, want to contain %v", rr.Body.String(), tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestSieveRequestValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		requestBody    string
+		expectedStatus int
+		expectedError  string
+	}{
+		{"invalid script name", "PUT", "/admin/accounts/user@example.com/sieve/bad%20name", `{"script":"keep;"}`, http.StatusBadRequest, "invalid characters"},
+		{"empty script", "PUT", "/admin/accounts/user@example.com/sieve/main", `{"script":""}`, http.StatusBadRequest, "script is required"},
+		{"script does not compile", "PUT", "/admin/accounts/user@example.com/sieve/main", `{"script":"if true {"}`, http.StatusBadRequest, "Invalid Sieve script"},
+		{"invalid new name", "POST", "/admin/accounts/user@example.com/sieve/main/rename", `{"new_name":"a/b"}`, http.StatusBadRequest, "invalid characters"},
+		{"unknown action", "POST", "/admin/accounts/user@example.com/sieve/main/compile", "", http.StatusNotFound, "Not found"},
+		{"wrong method", "PATCH", "/admin/accounts/user@example.com/sieve/main", "", http.StatusMethodNotAllowed, "Method not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.requestBody))
+			rr := httptest.NewRecorder()
+			server := &Server{apiKey: "test-api-key"}
+			server.handleAccountOperations(rr, req)
+
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("status = %v, want %v", rr.Code, tt.expectedStatus)
+			}
+			if !strings.Contains(rr.Body.String(), tt.expectedError) {
+				t.Errorf("body = %v, want to contain %v", rr.Body.String(), tt.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/sieveengine"
+)
+
+// maxSieveScriptSize matches the limit of sora-admin and the User API.
+const maxSieveScriptSize = 65536
+
+// SieveScriptInfo describes a Sieve script in API responses
+type SieveScriptInfo struct {
+	Name      string `json:"name"`
+	Script    string `json:"script,omitempty"`
+	Active    bool   `json:"active"`
+	Size      int    `json:"size"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// SievePutRequest is the request body for creating or replacing a script
+type SievePutRequest struct {
+	Script   string `json:"script"`
+	Activate bool   `json:"activate,omitempty"` // Also make this the active script
+}
+
+// SieveRenameRequest is the request body for renaming a script
+type SieveRenameRequest struct {
+	NewName string `json:"new_name"`
+}
+
+func newSieveScriptInfo(script *db.SieveScript, withBody bool) SieveScriptInfo {
+	info := SieveScriptInfo{
+		Name:      script.Name,
+		Active:    script.Active,
+		Size:      len(script.Script),
+		UpdatedAt: script.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if withBody {
+		info.Script = script.Script
+	}
+	return info
+}
+
+// validateScriptName validates a Sieve script name
+func validateScriptName(name string) error {
+	if name == "" {
+		return errors.New("script name cannot be empty")
+	}
+	for _, c := range name {
+		if !((c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.') {
+			return errors.New("script name contains invalid characters")
+		}
+	}
+	if len(name) > 128 {
+		return errors.New("script name too long (max 128 characters)")
+	}
+	return nil
+}
+
+// handleSieveOperations routes /admin/accounts/{email}/sieve[/{name}[/action]]
+func (s *Server) handleSieveOperations(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/admin/accounts/")
+	email, tail, _ := strings.Cut(rest, "/sieve")
+	email, err := url.PathUnescape(email)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid email")
+		return
+	}
+	tail = strings.Trim(tail, "/")
+
+	var name, action string
+	if tail != "" {
+		name, action, _ = strings.Cut(tail, "/")
+		if name, err = url.PathUnescape(name); err != nil {
+			s.writeError(w, http.StatusBadRequest, "Invalid script name")
+			return
+		}
+	}
+
+	switch {
+	case name == "" && r.Method == "GET":
+		s.handleSieveList(w, r, email)
+	case name == "deactivate" && action == "" && r.Method == "POST":
+		s.handleSieveDeactivate(w, r, email)
+	case name != "" && action == "":
+		switch r.Method {
+		case "GET":
+			s.handleSieveGet(w, r, email, name)
+		case "PUT":
+			s.handleSievePut(w, r, email, name)
+		case "DELETE":
+			s.handleSieveDelete(w, r, email, name)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case name != "" && action == "activate" && r.Method == "POST":
+		s.handleSieveActivate(w, r, email, name)
+	case name != "" && action == "rename" && r.Method == "POST":
+		s.handleSieveRename(w, r, email, name)
+	case name == "" || action == "activate" || action == "rename":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		s.writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// writeSieveError maps script operation errors to responses
+func (s *Server) writeSieveError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, consts.ErrDBNotFound):
+		s.writeError(w, http.StatusNotFound, "Script not found")
+	case errors.Is(err, consts.ErrDBUniqueViolation):
+		s.writeError(w, http.StatusConflict, "A script with that name already exists")
+	default:
+		logger.Warn("HTTP API: Error in Sieve operation", "name", s.name, "operation", op, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to "+op+" script")
+	}
+}
+
+// handleSieveList handles GET /admin/accounts/{email}/sieve
+func (s *Server) handleSieveList(w http.ResponseWriter, r *http.Request, email string) {
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	scripts, err := s.rdb.GetUserScriptsWithRetry(r.Context(), accountID)
+	if err != nil {
+		s.writeSieveError(w, "list", err)
+		return
+	}
+	infos := make([]SieveScriptInfo, 0, len(scripts))
+	for _, script := range scripts {
+		infos = append(infos, newSieveScriptInfo(script, false))
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"email":   email,
+		"scripts": infos,
+		"count":   len(infos),
+	})
+}
+
+// handleSieveGet handles GET /admin/accounts/{email}/sieve/{name}
+func (s *Server) handleSieveGet(w http.ResponseWriter, r *http.Request, email, name string) {
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	script, err := s.rdb.GetScriptByNameWithRetry(r.Context(), name, accountID)
+	if err != nil {
+		s.writeSieveError(w, "get", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, newSieveScriptInfo(script, true))
+}
+
+// handleSievePut handles PUT /admin/accounts/{email}/sieve/{name}. The script
+// is compiled first, so a broken script cannot be stored.
+func (s *Server) handleSievePut(w http.ResponseWriter, r *http.Request, email, name string) {
+	r.Body = http.MaxBytesReader(w, r.Body, 2*maxSieveScriptSize+1024)
+	var req SievePutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateScriptName(name); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Script == "" {
+		s.writeError(w, http.StatusBadRequest, "script is required")
+		return
+	}
+	if len(req.Script) > maxSieveScriptSize {
+		s.writeError(w, http.StatusRequestEntityTooLarge, "Script exceeds maximum size")
+		return
+	}
+	if err := sieveengine.ValidateScript(req.Script, s.sieveExtensions); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid Sieve script: "+err.Error())
+		return
+	}
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	script, err := s.rdb.CreateOrUpdateScriptWithRetry(ctx, accountID, name, req.Script)
+	if err != nil {
+		s.writeSieveError(w, "save", err)
+		return
+	}
+	if req.Activate && !script.Active {
+		if err := s.rdb.ActivateScriptWithRetry(ctx, name, accountID); err != nil {
+			s.writeSieveError(w, "activate", err)
+			return
+		}
+		script.Active = true
+	}
+	s.audit(r, db.AuditSievePut, email, map[string]any{"script": name, "size": len(req.Script), "active": script.Active})
+
+	s.writeJSON(w, http.StatusOK, newSieveScriptInfo(script, false))
+}
+
+// handleSieveDelete handles DELETE /admin/accounts/{email}/sieve/{name}
+func (s *Server) handleSieveDelete(w http.ResponseWriter, r *http.Request, email, name string) {
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	if err := s.rdb.DeleteScriptWithRetry(r.Context(), name, accountID); err != nil {
+		s.writeSieveError(w, "delete", err)
+		return
+	}
+	s.audit(r, db.AuditSieveDelete, email, map[string]any{"script": name})
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Script deleted successfully",
+		"email":   email,
+		"name":    name,
+	})
+}
+
+// handleSieveActivate handles POST /admin/accounts/{email}/sieve/{name}/activate
+func (s *Server) handleSieveActivate(w http.ResponseWriter, r *http.Request, email, name string) {
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	if err := s.rdb.ActivateScriptWithRetry(r.Context(), name, accountID); err != nil {
+		s.writeSieveError(w, "activate", err)
+		return
+	}
+	s.audit(r, db.AuditSieveActivate, email, map[string]any{"script": name})
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Script activated successfully",
+		"email":   email,
+		"name":    name,
+		"active":  true,
+	})
+}
+
+// handleSieveDeactivate handles POST /admin/accounts/{email}/sieve/deactivate,
+// which leaves the account with no active script.
+func (s *Server) handleSieveDeactivate(w http.ResponseWriter, r *http.Request, email string) {
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	if err := s.rdb.DeactivateAllScriptsWithRetry(r.Context(), accountID); err != nil {
+		s.writeSieveError(w, "deactivate", err)
+		return
+	}
+	s.audit(r, db.AuditSieveDeactivate, email, nil)
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message": "All scripts deactivated",
+		"email":   email,
+	})
+}
+
+// handleSieveRename handles POST /admin/accounts/{email}/sieve/{name}/rename
+func (s *Server) handleSieveRename(w http.ResponseWriter, r *http.Request, email, name string) {
+	var req SieveRenameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateScriptName(req.NewName); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	if err := s.rdb.RenameScriptWithRetry(r.Context(), accountID, name, req.NewName); err != nil {
+		s.writeSieveError(w, "rename", err)
+		return
+	}
+	s.audit(r, db.AuditSieveRename, email, map[string]any{"old_name": name, "new_name": req.NewName})
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message":  "Script renamed successfully",
+		"email":    email,
+		"old_name": name,
+		"new_name": req.NewName,
+	})
+}
@@ -0,0 +1,108 @@
+package adminapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/delivery"
+)
+
+// maxVacationRequestSize bounds a vacation settings body: two 64 KiB bodies
+// with JSON escaping headroom plus the remaining fields.
+const maxVacationRequestSize = 4*65536 + 16384
+
+// handleVacationOperations routes /admin/accounts/{email}/vacation
+func (s *Server) handleVacationOperations(w http.ResponseWriter, r *http.Request) {
+	email, err := url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/accounts/"), "/vacation"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid email")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s.handleVacationGet(w, r, email)
+	case "PUT":
+		s.handleVacationPut(w, r, email)
+	case "DELETE":
+		s.handleVacationDelete(w, r, email)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeVacationError maps vacation settings errors to responses
+func (s *Server) writeVacationError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, consts.ErrDBNotFound):
+		s.writeError(w, http.StatusNotFound, "Vacation reply not configured")
+	case errors.Is(err, delivery.ErrInvalidVacation):
+		s.writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		logger.Warn("HTTP API: Error in vacation operation", "name", s.name, "operation", op, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to "+op+" vacation settings")
+	}
+}
+
+// handleVacationGet handles GET /admin/accounts/{email}/vacation
+func (s *Server) handleVacationGet(w http.ResponseWriter, r *http.Request, email string) {
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	settings, err := s.rdb.GetVacationSettingsWithRetry(r.Context(), accountID)
+	if err != nil {
+		s.writeVacationError(w, "get", err)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+// handleVacationPut handles PUT /admin/accounts/{email}/vacation. The settings
+// replace any previous ones and are compiled into the account's managed
+// vacation script.
+func (s *Server) handleVacationPut(w http.ResponseWriter, r *http.Request, email string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxVacationRequestSize)
+	var settings db.VacationSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	settings.AccountID = accountID
+
+	if err := delivery.SaveVacationSettings(r.Context(), s.rdb, &settings, s.sieveExtensions); err != nil {
+		s.writeVacationError(w, "save", err)
+		return
+	}
+	s.audit(r, db.AuditVacationPut, email, map[string]any{"enabled": settings.Enabled, "start_at": settings.StartAt, "end_at": settings.EndAt})
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+// handleVacationDelete handles DELETE /admin/accounts/{email}/vacation
+func (s *Server) handleVacationDelete(w http.ResponseWriter, r *http.Request, email string) {
+	accountID, ok := s.resolveAccount(w, r, email)
+	if !ok {
+		return
+	}
+	if err := s.rdb.DeleteVacationSettingsWithRetry(r.Context(), accountID); err != nil {
+		s.writeVacationError(w, "delete", err)
+		return
+	}
+	s.audit(r, db.AuditVacationDelete, email, nil)
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Vacation reply deleted successfully",
+		"email":   email,
+	})
+}
@@ -0,0 +1,322 @@
+package adminjobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/emersion/go-message"
+	"github.com/migadu/sora/cache"
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/storage"
+)
+
+const (
+	purgeBatchSize      = 1000
+	ftsRebuildBatchSize = 200
+	maxReportedKeys     = 100
+)
+
+// RegisterBuiltins registers the handlers that need only the database and
+// object storage: purge-account, purge-domain, verify-s3 and rebuild-fts.
+// ftsRetention is the [cleanup] fts_retention; rebuild-fts skips messages
+// older than it, since the cleaner would prune them again.
+func RegisterBuiltins(w *Worker, rdb *resilient.ResilientDatabase, backend storage.Backend, ftsRetention time.Duration) {
+	b := &builtins{rdb: rdb, backend: backend, ftsRetention: ftsRetention}
+	w.Register(TypePurgeAccount, b.purgeAccount)
+	w.Register(TypePurgeDomain, b.purgeDomain)
+	w.Register(TypeVerifyS3, b.verifyS3)
+	w.Register(TypeRebuildFTS, b.rebuildFTS)
+}
+
+type builtins struct {
+	rdb          *resilient.ResilientDatabase
+	backend      storage.Backend
+	ftsRetention time.Duration
+	cache        *cache.Cache // Takeout only; may be nil
+	dataPath     string       // Takeout only
+}
+
+// accountID resolves the job's account, failing permanently if it is gone.
+func (b *builtins) accountID(ctx context.Context, email string) (int64, error) {
+	id, err := b.rdb.GetAccountIDByAddressWithRetry(ctx, email)
+	if errors.Is(err, consts.ErrUserNotFound) {
+		return 0, Permanent(fmt.Errorf("account %s not found", email))
+	}
+	return id, err
+}
+
+func (b *builtins) purgeAccount(ctx context.Context, job *Job) (map[string]any, error) {
+	var p AccountParams
+	if err := job.Decode(&p); err != nil {
+		return nil, err
+	}
+	id, err := b.accountID(ctx, p.Email)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := b.purge(ctx, job, id, p.Email)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"objects_deleted": objects}, nil
+}
+
+// purge deletes an account's messages, objects, mailboxes, credentials and
+// the account itself. Each step is idempotent, so a retried job picks up
+// where the last attempt stopped.
+func (b *builtins) purge(ctx context.Context, job *Job, accountID int64, email string) (int64, error) {
+	if _, err := b.rdb.ExpungeAllMessagesForAccount(ctx, accountID); err != nil {
+		return 0, fmt.Errorf("failed to expunge messages: %w", err)
+	}
+
+	var deleted int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+		// Deleted rows drop out of the result, so this pages through the account.
+		batch, err := b.rdb.GetAllUploadedObjectsForAccount(ctx, accountID, purgeBatchSize)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to list objects: %w", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		keys := make([]string, len(batch))
+		for i, c := range batch {
+			keys[i] = helpers.NewS3Key(c.S3Domain, c.S3Localpart, c.ContentHash)
+		}
+		failed := b.backend.DeleteBulk(keys)
+		var ok []db.UserScopedObjectForCleanup
+		for i, key := range keys {
+			if _, bad := failed[key]; !bad {
+				ok = append(ok, batch[i])
+			}
+		}
+		if len(ok) == 0 {
+			return deleted, fmt.Errorf("failed to delete any of %d objects for %s", len(keys), email)
+		}
+		n, err := b.rdb.DeleteExpungedMessagesByS3KeyPartsBatchWithRetry(ctx, ok)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete message records: %w", err)
+		}
+		deleted += int64(len(ok))
+		job.SetMessage(fmt.Sprintf("%s: deleted %d objects (%d records)", email, deleted, n))
+	}
+
+	if err := b.deleteTakeoutArchives(ctx, accountID); err != nil {
+		return deleted, err
+	}
+
+	if err := b.rdb.PurgeMailboxesForAccount(ctx, accountID); err != nil {
+		return deleted, fmt.Errorf("failed to purge mailboxes: %w", err)
+	}
+	if err := b.rdb.PurgeCredentialsForAccount(ctx, accountID); err != nil {
+		return deleted, fmt.Errorf("failed to purge credentials: %w", err)
+	}
+	if err := b.rdb.PurgeAccount(ctx, accountID); err != nil {
+		return deleted, fmt.Errorf("failed to purge account: %w", err)
+	}
+	return deleted, nil
+}
+
+// deleteTakeoutArchives removes the takeout archives stored for an account.
+func (b *builtins) deleteTakeoutArchives(ctx context.Context, accountID int64) error {
+	objects, errs := b.backend.ListObjects(ctx, fmt.Sprintf("takeout/%d/", accountID), true)
+	var keys []string
+	for obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	if err := <-errs; err != nil {
+		return fmt.Errorf("failed to list takeout archives: %w", err)
+	}
+	for key, err := range b.backend.DeleteBulk(keys) {
+		return fmt.Errorf("failed to delete takeout archive %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *builtins) purgeDomain(ctx context.Context, job *Job) (map[string]any, error) {
+	var p DomainParams
+	if err := job.Decode(&p); err != nil {
+		return nil, err
+	}
+	accounts, err := b.rdb.GetAccountsByDomain(ctx, p.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	aliases, err := b.rdb.GetAliasCredentialsByDomain(ctx, p.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list aliases: %w", err)
+	}
+
+	// Already purged accounts no longer appear in the listing, so a retried
+	// attempt counts from zero.
+	job.Restart(int64(len(accounts) + len(aliases)))
+
+	var objects int64
+	for _, acct := range accounts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := b.purge(ctx, job, acct.AccountID, acct.PrimaryEmail)
+		objects += n
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", acct.PrimaryEmail, err)
+		}
+		job.Advance(1, "purged "+acct.PrimaryEmail)
+	}
+	// Addresses in the domain on accounts whose primary lives elsewhere.
+	for _, addr := range aliases {
+		if err := b.rdb.DeleteCredentialWithRetry(ctx, addr); err != nil {
+			return nil, fmt.Errorf("failed to remove alias %s: %w", addr, err)
+		}
+		job.Advance(1, "removed alias "+addr)
+	}
+	return map[string]any{
+		"accounts_purged": len(accounts),
+		"aliases_removed": len(aliases),
+		"objects_deleted": objects,
+	}, nil
+}
+
+func (b *builtins) verifyS3(ctx context.Context, job *Job) (map[string]any, error) {
+	var p AccountParams
+	if err := job.Decode(&p); err != nil {
+		return nil, err
+	}
+	id, err := b.accountID(ctx, p.Email)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := b.rdb.GetAllMessagesForUserVerificationWithRetry(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	// Messages with identical content share one object.
+	seen := make(map[string]bool, len(messages))
+	var keys []string
+	for _, m := range messages {
+		key := helpers.NewS3Key(m.S3Domain, m.S3Localpart, m.ContentHash)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	job.Restart(int64(len(keys)))
+	var missing []string
+	missingCount := 0
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		exists, _, err := b.backend.Exists(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", key, err)
+		}
+		if !exists {
+			missingCount++
+			if len(missing) < maxReportedKeys {
+				missing = append(missing, key)
+			}
+		}
+		job.Advance(1, "")
+	}
+	return map[string]any{
+		"messages": len(messages),
+		"objects":  len(keys),
+		"missing":  missingCount,
+		// At most the first 100 missing keys.
+		"missing_keys": missing,
+	}, nil
+}
+
+func (b *builtins) rebuildFTS(ctx context.Context, job *Job) (map[string]any, error) {
+	var p AccountParams
+	if err := job.Decode(&p); err != nil {
+		return nil, err
+	}
+	id, err := b.accountID(ctx, p.Email)
+	if err != nil {
+		return nil, err
+	}
+	total, err := b.rdb.CountMessagesWithoutFTSWithRetry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	job.Restart(total)
+
+	var cutoff time.Time
+	if b.ftsRetention > 0 {
+		cutoff = time.Now().Add(-b.ftsRetention)
+	}
+
+	staged, skipped, failed := 0, 0, 0
+	seen := make(map[string]bool)
+	var afterID int64
+	for {
+		candidates, err := b.rdb.GetMessagesWithoutFTSWithRetry(ctx, id, afterID, ftsRebuildBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		for _, c := range candidates {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			afterID = c.ID
+			if seen[c.ContentHash] {
+				continue
+			}
+			seen[c.ContentHash] = true
+
+			if !cutoff.IsZero() && c.SentDate != nil && c.SentDate.Before(cutoff) {
+				skipped++
+			} else if err := b.stageBody(ctx, c); err != nil {
+				// One unreadable message should not fail the whole rebuild.
+				failed++
+				job.SetMessage(fmt.Sprintf("message %d: %v", c.ID, err))
+			} else {
+				staged++
+			}
+			job.Advance(1, "")
+		}
+	}
+	// The FTS worker indexes the staged bodies in the background.
+	return map[string]any{"staged": staged, "skipped_retention": skipped, "failed": failed}, nil
+}
+
+func (b *builtins) stageBody(ctx context.Context, c db.FTSRebuildCandidate) error {
+	body, err := b.backend.Get(helpers.NewS3Key(c.S3Domain, c.S3Localpart, c.ContentHash))
+	if err != nil {
+		return fmt.Errorf("failed to fetch body: %w", err)
+	}
+	defer body.Close()
+	entity, err := message.Read(body)
+	if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		return fmt.Errorf("failed to parse message: %w", err)
+	}
+	text, err := helpers.ExtractPlaintextBody(entity)
+	// Drain so that truncated objects surface as errors.
+	if _, cerr := io.Copy(io.Discard, body); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract text: %w", err)
+	}
+	if text == nil {
+		return nil
+	}
+	return b.rdb.StageFTSBodyWithRetry(ctx, c.ContentHash, *text, c.SentDate)
+}
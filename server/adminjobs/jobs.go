@@ -0,0 +1,158 @@
+// Package adminjobs runs long administrative operations (import, export,
+// purges, S3 verification, FTS rebuild, account takeouts) as jobs stored in the admin_jobs
+// table, so they survive the client that submitted them.
+//
+// Jobs are submitted with Submit, from the Admin API or 'sora-admin jobs
+// submit'. A Worker claims jobs of the types it has handlers for, reports
+// progress while they run and retries them on failure. The sora server and
+// 'sora-admin jobs worker' both run a worker with every handler (see
+// RegisterBuiltins, RegisterMaildir and RegisterTakeout).
+package adminjobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/server"
+)
+
+// Job types.
+const (
+	TypeImport       = "import"
+	TypeExport       = "export"
+	TypePurgeAccount = "purge-account"
+	TypePurgeDomain  = "purge-domain"
+	TypeVerifyS3     = "verify-s3"
+	TypeRebuildFTS   = "rebuild-fts"
+	TypeTakeout      = "takeout"
+)
+
+// Types lists the valid job types.
+var Types = []string{TypeImport, TypeExport, TypePurgeAccount, TypePurgeDomain, TypeVerifyS3, TypeRebuildFTS, TypeTakeout}
+
+// ErrInvalidJob is returned by Submit for an unknown type or invalid params.
+var ErrInvalidJob = errors.New("invalid job")
+
+// AccountParams are the params of purge-account, verify-s3 and rebuild-fts jobs.
+type AccountParams struct {
+	Email string `json:"email"`
+}
+
+// DomainParams are the params of purge-domain jobs.
+type DomainParams struct {
+	Domain string `json:"domain"`
+}
+
+// MaildirParams are the params of import and export jobs.
+type MaildirParams struct {
+	Email   string   `json:"email"`
+	Path    string   `json:"path"`              // Maildir, relative to [jobs] data_path
+	Mailbox []string `json:"mailbox,omitempty"` // Only these mailboxes (default: all)
+	DryRun  bool     `json:"dry_run,omitempty"`
+	Dovecot bool     `json:"dovecot,omitempty"` // Read or write Dovecot metadata files
+}
+
+// DecodeParams decodes job params into v.
+func DecodeParams(params map[string]any, v any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// ValidateParams checks that params are complete for jobType.
+func ValidateParams(jobType string, params map[string]any) error {
+	switch jobType {
+	case TypePurgeAccount, TypeVerifyS3, TypeRebuildFTS:
+		var p AccountParams
+		if err := DecodeParams(params, &p); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidJob, err)
+		}
+		if _, err := server.NewAddress(p.Email); err != nil {
+			return fmt.Errorf("%w: email: %v", ErrInvalidJob, err)
+		}
+	case TypePurgeDomain:
+		var p DomainParams
+		if err := DecodeParams(params, &p); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidJob, err)
+		}
+		if p.Domain == "" || strings.ContainsAny(p.Domain, "@/ ") {
+			return fmt.Errorf("%w: domain is required", ErrInvalidJob)
+		}
+	case TypeImport, TypeExport:
+		var p MaildirParams
+		if err := DecodeParams(params, &p); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidJob, err)
+		}
+		if _, err := server.NewAddress(p.Email); err != nil {
+			return fmt.Errorf("%w: email: %v", ErrInvalidJob, err)
+		}
+		if p.Path == "" {
+			return fmt.Errorf("%w: path is required", ErrInvalidJob)
+		}
+		if _, err := ResolvePath("", p.Path); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidJob, err)
+		}
+	case TypeTakeout:
+		var p TakeoutParams
+		if err := DecodeParams(params, &p); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidJob, err)
+		}
+		if _, err := server.NewAddress(p.Email); err != nil {
+			return fmt.Errorf("%w: email: %v", ErrInvalidJob, err)
+		}
+		if p.Format != "" && p.Format != TakeoutFormatMbox && p.Format != TakeoutFormatMaildir {
+			return fmt.Errorf("%w: format must be %s or %s", ErrInvalidJob, TakeoutFormatMbox, TakeoutFormatMaildir)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q (valid: %s)", ErrInvalidJob, jobType, strings.Join(Types, ", "))
+	}
+	return nil
+}
+
+// ResolvePath returns rel joined to base, refusing absolute paths and paths
+// that leave base.
+func ResolvePath(base, rel string) (string, error) {
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path %q must be relative to, and inside, the jobs data path", rel)
+	}
+	return filepath.Join(base, rel), nil
+}
+
+// Target returns the account address or domain a job acts on, for audit
+// entries and listings.
+func Target(job *db.AdminJob) string {
+	for _, key := range []string{"email", "domain"} {
+		if v, ok := job.Params[key].(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// Submit validates and queues a job.
+func Submit(ctx context.Context, rdb *resilient.ResilientDatabase, jobType string, params map[string]any, createdBy string, maxAttempts int) (*db.AdminJob, error) {
+	if err := ValidateParams(jobType, params); err != nil {
+		return nil, err
+	}
+	job := &db.AdminJob{
+		Type:        jobType,
+		Params:      params,
+		CreatedBy:   createdBy,
+		MaxAttempts: maxAttempts,
+	}
+	if err := rdb.InsertAdminJobWithRetry(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
@@ -0,0 +1,134 @@
+package adminjobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/migadu/sora/pkg/maildir"
+	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/storage"
+)
+
+// maildirProgressInterval is how often import and export jobs copy the
+// importer's counters into the job's progress.
+const maildirProgressInterval = 2 * time.Second
+
+// RegisterMaildir registers the import and export handlers, which run the
+// maildir importer and exporter on paths under dataPath ([jobs] data_path).
+// Imported messages larger than maxMessageSize are skipped.
+func RegisterMaildir(w *Worker, rdb *resilient.ResilientDatabase, backend storage.Backend, dataPath string, maxMessageSize int64) {
+	w.Register(TypeImport, importHandler(rdb, backend, dataPath, maxMessageSize))
+	w.Register(TypeExport, exportHandler(rdb, backend, dataPath))
+}
+
+// importHandler runs the maildir importer for import jobs. The import is
+// incremental, so a retried attempt skips messages already imported.
+func importHandler(rdb *resilient.ResilientDatabase, backend storage.Backend, dataPath string, maxMessageSize int64) Handler {
+	return func(ctx context.Context, job *Job) (map[string]any, error) {
+		var p MaildirParams
+		if err := job.Decode(&p); err != nil {
+			return nil, err
+		}
+		path, err := ResolvePath(dataPath, p.Path)
+		if err != nil {
+			return nil, Permanent(err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, Permanent(fmt.Errorf("maildir: %w", err))
+		}
+
+		importer, err := maildir.NewImporter(ctx, path, p.Email, 4, rdb, backend, maildir.ImporterOptions{
+			DryRun:         p.DryRun,
+			MailboxFilter:  p.Mailbox,
+			PreserveFlags:  true,
+			Dovecot:        p.Dovecot,
+			PreserveUIDs:   p.Dovecot,
+			Incremental:    true,
+			MaxMessageSize: maxMessageSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create importer: %w", err)
+		}
+		err = runWithProgress(ctx, job, importer.Run, func() (done, total int64) {
+			total, imported, skipped, failed := importer.Stats()
+			return imported + skipped + failed, total
+		})
+		total, imported, skipped, failed := importer.Stats()
+		result := map[string]any{
+			"total":    total,
+			"imported": imported,
+			"skipped":  skipped,
+			"failed":   failed,
+		}
+		return result, err
+	}
+}
+
+// exportHandler runs the maildir exporter for export jobs.
+func exportHandler(rdb *resilient.ResilientDatabase, backend storage.Backend, dataPath string) Handler {
+	return func(ctx context.Context, job *Job) (map[string]any, error) {
+		var p MaildirParams
+		if err := job.Decode(&p); err != nil {
+			return nil, err
+		}
+		path, err := ResolvePath(dataPath, p.Path)
+		if err != nil {
+			return nil, Permanent(err)
+		}
+
+		exporter, err := maildir.NewExporter(ctx, path, p.Email, 4, rdb, backend, maildir.ExporterOptions{
+			DryRun:        p.DryRun,
+			MailboxFilter: p.Mailbox,
+			Dovecot:       p.Dovecot,
+			ExportUIDList: p.Dovecot,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create exporter: %w", err)
+		}
+		err = runWithProgress(ctx, job, exporter.Run, func() (done, total int64) {
+			total, exported, skipped, failed := exporter.Stats()
+			return exported + skipped + failed, total
+		})
+		total, exported, skipped, failed := exporter.Stats()
+		result := map[string]any{
+			"total":    total,
+			"exported": exported,
+			"skipped":  skipped,
+			"failed":   failed,
+		}
+		return result, err
+	}
+}
+
+// runWithProgress runs fn, copying the counters reported by progress into
+// the job until it returns.
+func runWithProgress(ctx context.Context, job *Job, fn func() error, progress func() (done, total int64)) error {
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(maildirProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				done, total := progress()
+				job.Restart(total)
+				job.Advance(done, "")
+			}
+		}
+	}()
+	err := fn()
+	close(stop)
+	<-finished
+	done, total := progress()
+	job.Restart(total)
+	job.Advance(done, "")
+	return err
+}
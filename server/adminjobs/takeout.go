@@ -0,0 +1,389 @@
+package adminjobs
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/migadu/sora/cache"
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/mailexport"
+	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/storage"
+)
+
+// Takeout archive formats.
+const (
+	TakeoutFormatMbox    = "mbox"
+	TakeoutFormatMaildir = "maildir"
+)
+
+const (
+	takeoutMessageAttempts = 3
+	takeoutLoginHistory    = 1000
+)
+
+// TakeoutParams are the params of takeout jobs.
+type TakeoutParams struct {
+	Email  string `json:"email"`
+	Format string `json:"format,omitempty"` // "mbox" (default) or "maildir"
+}
+
+// TakeoutArchiveKey returns the object storage key of the archive built by a
+// takeout job.
+func TakeoutArchiveKey(accountID, jobID int64) string {
+	return fmt.Sprintf("takeout/%d/%d.zip", accountID, jobID)
+}
+
+// RegisterTakeout registers the takeout handler, which builds a zip archive
+// of an account's mail, Sieve scripts and metadata and stores it in object
+// storage under TakeoutArchiveKey. Message bodies are read from bodyCache (may
+// be nil) before object storage. The archive is assembled in dataPath ([jobs]
+// data_path).
+func RegisterTakeout(w *Worker, rdb *resilient.ResilientDatabase, backend storage.Backend, bodyCache *cache.Cache, dataPath string) {
+	b := &builtins{rdb: rdb, backend: backend, cache: bodyCache, dataPath: dataPath}
+	w.Register(TypeTakeout, b.takeout)
+}
+
+func (b *builtins) takeout(ctx context.Context, job *Job) (map[string]any, error) {
+	var p TakeoutParams
+	if err := job.Decode(&p); err != nil {
+		return nil, err
+	}
+	if p.Format == "" {
+		p.Format = TakeoutFormatMbox
+	}
+	id, err := b.accountID(ctx, p.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	mailboxes, err := b.rdb.GetMailboxesWithRetry(ctx, id, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mailboxes: %w", err)
+	}
+	all := imap.SeqSet{}
+	all.AddRange(1, 0)
+	messages := make([][]db.Message, len(mailboxes))
+	var total int64
+	for i, mbox := range mailboxes {
+		if messages[i], err = b.rdb.GetMessagesByNumSetWithRetry(ctx, mbox.ID, all); err != nil {
+			return nil, fmt.Errorf("failed to list messages in %s: %w", mbox.Name, err)
+		}
+		total += int64(len(messages[i]))
+	}
+	job.Restart(total)
+
+	dir := filepath.Join(b.dataPath, "takeout")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create takeout directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, fmt.Sprintf("%d-*.zip", job.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	hostname, _ := os.Hostname()
+	archive := newTakeoutArchive(f, p.Format, hostname)
+	summary := takeoutAccount{Email: p.Email, AccountID: id, ExportedAt: time.Now().UTC(), Format: p.Format}
+
+	subscribed, err := b.rdb.GetSubscribedMailboxNamesWithRetry(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
+	}
+	summary.Subscriptions = subscribed
+
+	for i, mbox := range mailboxes {
+		summary.Mailboxes = append(summary.Mailboxes, takeoutMailbox{
+			Name:        mbox.Name,
+			UIDValidity: mbox.UIDValidity,
+			Messages:    len(messages[i]),
+		})
+		for j := range messages[i] {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			msg := &messages[i][j]
+			content, err := b.takeoutBody(ctx, msg)
+			if err != nil {
+				// Retried as a whole by the worker; the archive must be complete.
+				return nil, fmt.Errorf("failed to read message %d in %s: %w", msg.UID, mbox.Name, err)
+			}
+			if err := archive.addMessage(mbox.Name, msg, content); err != nil {
+				return nil, err
+			}
+			job.Advance(1, mbox.Name)
+		}
+	}
+
+	scripts, err := b.rdb.GetUserScriptsWithRetry(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Sieve scripts: %w", err)
+	}
+	for _, script := range scripts {
+		name, err := archive.addFile(path.Join("sieve", archiveName(script.Name)+".sieve"), script.UpdatedAt, []byte(script.Script))
+		if err != nil {
+			return nil, err
+		}
+		summary.SieveScripts = append(summary.SieveScripts, takeoutScript{Name: script.Name, Active: script.Active, File: name})
+	}
+
+	vacationSettings, err := b.rdb.GetVacationSettingsWithRetry(ctx, id)
+	if err != nil && !errors.Is(err, consts.ErrDBNotFound) {
+		return nil, fmt.Errorf("failed to get vacation settings: %w", err)
+	}
+	if vacationSettings != nil {
+		if err := archive.addJSON("vacation_settings.json", vacationSettings); err != nil {
+			return nil, err
+		}
+	}
+	vacation, err := b.rdb.ListVacationResponsesWithRetry(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vacation responses: %w", err)
+	}
+	metadata, err := b.rdb.ListAccountMetadataWithRetry(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list metadata: %w", err)
+	}
+	logins, err := b.rdb.GetLoginHistoryWithRetry(ctx, id, takeoutLoginHistory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login history: %w", err)
+	}
+	sources, err := b.rdb.ListLoginSourcesWithRetry(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list login sources: %w", err)
+	}
+
+	for _, file := range []struct {
+		name string
+		v    any
+	}{
+		{"account.json", summary},
+		{"messages.json", archive.index},
+		{"vacation_responses.json", vacation},
+		{"metadata.json", metadata},
+		{"logins.json", logins},
+		{"login_sources.json", sources},
+	} {
+		if err := archive.addJSON(file.name, file.v); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to size archive: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind archive: %w", err)
+	}
+	key := TakeoutArchiveKey(id, job.ID)
+	job.SetMessage("uploading archive")
+	if err := b.backend.Put(key, f, size); err != nil {
+		return nil, fmt.Errorf("failed to store archive: %w", err)
+	}
+	logger.Info("Takeout archive stored", "job_id", job.ID, "email", p.Email, "key", key, "size", size, "messages", total)
+
+	return map[string]any{
+		"account_id":  id,
+		"archive_key": key,
+		"format":      p.Format,
+		"size":        size,
+		"messages":    total,
+	}, nil
+}
+
+// takeoutBody returns a message's content from the cache or object storage.
+func (b *builtins) takeoutBody(ctx context.Context, msg *db.Message) ([]byte, error) {
+	if b.cache != nil {
+		if data, err := b.cache.Get(msg.ContentHash); err == nil && data != nil {
+			return data, nil
+		}
+	}
+	if msg.S3Domain == "" || msg.S3Localpart == "" {
+		return nil, fmt.Errorf("missing S3 key information (may be pending upload)")
+	}
+	key := helpers.NewS3Key(msg.S3Domain, msg.S3Localpart, msg.ContentHash)
+	var err error
+	for attempt := range takeoutMessageAttempts {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+		var r io.ReadCloser
+		if r, err = b.backend.Get(key); err != nil {
+			continue
+		}
+		var data []byte
+		data, err = io.ReadAll(r)
+		r.Close()
+		if err == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
+
+// takeoutAccount is account.json in a takeout archive.
+type takeoutAccount struct {
+	Email         string           `json:"email"`
+	AccountID     int64            `json:"account_id"`
+	ExportedAt    time.Time        `json:"exported_at"`
+	Format        string           `json:"format"`
+	Mailboxes     []takeoutMailbox `json:"mailboxes"`
+	Subscriptions []string         `json:"subscriptions"`
+	SieveScripts  []takeoutScript  `json:"sieve_scripts"`
+}
+
+type takeoutMailbox struct {
+	Name        string `json:"name"`
+	UIDValidity uint32 `json:"uid_validity"`
+	Messages    int    `json:"messages"`
+}
+
+type takeoutScript struct {
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+	File   string `json:"file"`
+}
+
+// takeoutMessage is an entry of messages.json, which keeps the flags and
+// dates an mbox cannot hold.
+type takeoutMessage struct {
+	Mailbox      string    `json:"mailbox"`
+	UID          uint32    `json:"uid"`
+	File         string    `json:"file"`
+	InternalDate time.Time `json:"internal_date"`
+	Size         int       `json:"size"`
+	Flags        []string  `json:"flags"`
+}
+
+// takeoutArchive writes the zip archive of a takeout. Mail goes to mbox/ as
+// one <mailbox>.mbox file per mailbox, or to maildir/ laid out like
+// 'sora-admin export' (INBOX at the top, other mailboxes in subdirectories).
+type takeoutArchive struct {
+	zw       *zip.Writer
+	format   string
+	hostname string
+	index    []takeoutMessage
+
+	// The mbox being written; zip entries are written one at a time, so
+	// each mailbox's messages are appended before the next mailbox starts.
+	mbox     io.Writer
+	mboxName string
+}
+
+func newTakeoutArchive(w io.Writer, format, hostname string) *takeoutArchive {
+	return &takeoutArchive{zw: zip.NewWriter(w), format: format, hostname: hostname, index: []takeoutMessage{}}
+}
+
+func (a *takeoutArchive) addMessage(mailbox string, msg *db.Message, content []byte) error {
+	var name string
+	if a.format == TakeoutFormatMaildir {
+		dir := "maildir"
+		if mailbox != "INBOX" {
+			dir = path.Join(dir, archiveName(mailbox))
+		}
+		name = path.Join(dir, "cur", mailexport.MaildirFilename(msg, a.hostname))
+		w, err := a.create(name, msg.InternalDate)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	} else {
+		name = path.Join("mbox", archiveName(mailbox)+".mbox")
+		if a.mboxName != name {
+			w, err := a.create(name, time.Now())
+			if err != nil {
+				return err
+			}
+			a.mbox, a.mboxName = w, name
+		}
+		if err := mailexport.WriteMboxMessage(a.mbox, "", msg.InternalDate, content); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	flags := []string{}
+	for _, f := range db.BitwiseToFlags(msg.BitwiseFlags) {
+		flags = append(flags, string(f))
+	}
+	a.index = append(a.index, takeoutMessage{
+		Mailbox:      mailbox,
+		UID:          uint32(msg.UID),
+		File:         name,
+		InternalDate: msg.InternalDate,
+		Size:         msg.Size,
+		Flags:        append(flags, msg.CustomFlags...),
+	})
+	return nil
+}
+
+// addFile adds a file and returns its name in the archive.
+func (a *takeoutArchive) addFile(name string, modified time.Time, data []byte) (string, error) {
+	w, err := a.create(name, modified)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return name, nil
+}
+
+func (a *takeoutArchive) addJSON(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	_, err = a.addFile(name, time.Now(), data)
+	return err
+}
+
+func (a *takeoutArchive) create(name string, modified time.Time) (io.Writer, error) {
+	a.mbox, a.mboxName = nil, ""
+	w, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	return w, nil
+}
+
+func (a *takeoutArchive) close() error {
+	return a.zw.Close()
+}
+
+// archiveName turns a mailbox or script name into a relative path inside the
+// archive: empty, "." and ".." segments cannot escape the extraction
+// directory.
+func archiveName(name string) string {
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		if s == "" || s == "." || s == ".." {
+			segments[i] = "_"
+		}
+	}
+	return strings.Join(segments, "/")
+}
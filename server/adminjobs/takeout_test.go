@@ -0,0 +1,81 @@
+package adminjobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/migadu/sora/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		r.Close()
+		require.NoError(t, err)
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func writeTakeout(t *testing.T, format string) map[string]string {
+	t.Helper()
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	a := newTakeoutArchive(&buf, format, "host")
+	require.NoError(t, a.addMessage("INBOX", &db.Message{UID: 1, InternalDate: date, Size: 12, BitwiseFlags: db.FlagSeen}, []byte("Subject: a\r\n\r\nA\r\n")))
+	require.NoError(t, a.addMessage("INBOX", &db.Message{UID: 2, InternalDate: date, Size: 12, CustomFlags: []string{"Work"}}, []byte("Subject: b\r\n\r\nFrom me\r\n")))
+	require.NoError(t, a.addMessage("Archive/2024", &db.Message{UID: 7, InternalDate: date, Size: 12}, []byte("Subject: c\r\n\r\nC\r\n")))
+	_, err := a.addFile("sieve/main.sieve", date, []byte("keep;"))
+	require.NoError(t, err)
+	require.NoError(t, a.addJSON("messages.json", a.index))
+	require.NoError(t, a.close())
+	return readZip(t, buf.Bytes())
+}
+
+func TestTakeoutArchiveMbox(t *testing.T) {
+	files := writeTakeout(t, TakeoutFormatMbox)
+
+	assert.Len(t, files, 4)
+	inbox := files["mbox/INBOX.mbox"]
+	assert.Contains(t, inbox, "Subject: a\n\nA\n")
+	assert.Contains(t, inbox, "Subject: b\n\n>From me\n")
+	assert.Contains(t, files["mbox/Archive/2024.mbox"], "Subject: c\n")
+	assert.Equal(t, "keep;", files["sieve/main.sieve"])
+
+	var index []takeoutMessage
+	require.NoError(t, json.Unmarshal([]byte(files["messages.json"]), &index))
+	require.Len(t, index, 3)
+	assert.Equal(t, "mbox/INBOX.mbox", index[0].File)
+	assert.Equal(t, []string{`\Seen`}, index[0].Flags)
+	assert.Equal(t, []string{"Work"}, index[1].Flags)
+	assert.Equal(t, "Archive/2024", index[2].Mailbox)
+}
+
+func TestTakeoutArchiveMaildir(t *testing.T) {
+	files := writeTakeout(t, TakeoutFormatMaildir)
+
+	assert.Len(t, files, 5)
+	assert.Equal(t, "Subject: a\r\n\r\nA\r\n", files["maildir/cur/1709294400.M1709294400P1.host:2,S"])
+	assert.Contains(t, files, "maildir/cur/1709294400.M1709294400P2.host:2,")
+	assert.Contains(t, files, "maildir/Archive/2024/cur/1709294400.M1709294400P7.host:2,")
+}
+
+func TestArchiveName(t *testing.T) {
+	assert.Equal(t, "INBOX", archiveName("INBOX"))
+	assert.Equal(t, "Archive/2024", archiveName("Archive/2024"))
+	assert.Equal(t, "_/_/etc", archiveName("../../etc"))
+	assert.Equal(t, "_/a/_", archiveName("/a/"))
+	assert.Equal(t, "a/_/b", archiveName("a/./b"))
+}
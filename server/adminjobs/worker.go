@@ -0,0 +1,405 @@
+package adminjobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/migadu/sora/config"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+)
+
+// Store is the subset of the resilient database used by the worker. It is an
+// interface so the worker can be tested without PostgreSQL.
+type Store interface {
+	ClaimAdminJobWithRetry(ctx context.Context, workerID string, types []string, staleAfter time.Duration) (*db.AdminJob, error)
+	UpdateAdminJobProgressWithRetry(ctx context.Context, id int64, workerID string, done, total int64, message string) (bool, error)
+	FinishAdminJobWithRetry(ctx context.Context, id int64, workerID, status, lastError string, result map[string]any) error
+	RequeueAdminJobWithRetry(ctx context.Context, id int64, workerID, lastError string, delay time.Duration) error
+	CleanupOldAdminJobsWithRetry(ctx context.Context, retention time.Duration) (int64, error)
+}
+
+// Handler runs one job. It reports progress through job and returns a result
+// summary stored with the job. It must return promptly once ctx is done,
+// which happens when the job is cancelled or the worker stops.
+type Handler func(ctx context.Context, job *Job) (map[string]any, error)
+
+// permanentError marks an error that retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails without further attempts, e.g. when
+// the account no longer exists.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+var (
+	errJobCancelled = errors.New("job cancelled")
+	errJobLost      = errors.New("job taken over by another worker")
+	errWorkerStop   = errors.New("worker stopping")
+)
+
+// Job is a claimed job as seen by its handler.
+type Job struct {
+	*db.AdminJob
+
+	mu      sync.Mutex
+	done    int64
+	total   int64
+	message string
+}
+
+// SetTotal sets the number of units of work, for the percentage and ETA.
+func (j *Job) SetTotal(total int64) {
+	j.mu.Lock()
+	j.total = total
+	j.mu.Unlock()
+}
+
+// Restart sets the total and resets the count of units done, for handlers
+// that recompute their work from scratch on each attempt.
+func (j *Job) Restart(total int64) {
+	j.mu.Lock()
+	j.done, j.total = 0, total
+	j.mu.Unlock()
+}
+
+// Advance records n more units done and an optional status message.
+func (j *Job) Advance(n int64, message string) {
+	j.mu.Lock()
+	j.done += n
+	if message != "" {
+		j.message = message
+	}
+	j.mu.Unlock()
+}
+
+// SetMessage sets the status message without changing the counters.
+func (j *Job) SetMessage(message string) {
+	j.Advance(0, message)
+}
+
+// Decode decodes the job params into v.
+func (j *Job) Decode(v any) error {
+	if err := DecodeParams(j.Params, v); err != nil {
+		return Permanent(fmt.Errorf("invalid params: %w", err))
+	}
+	return nil
+}
+
+func (j *Job) progress() (done, total int64, message string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done, j.total, j.message
+}
+
+// Options configures a Worker.
+type Options struct {
+	Concurrency      int
+	PollInterval     time.Duration
+	HeartbeatTimeout time.Duration // running jobs without a heartbeat for this long are taken over
+	RetryDelay       time.Duration // multiplied by the attempt number
+	Retention        time.Duration // finished jobs older than this are deleted; 0 keeps them
+}
+
+// OptionsFromConfig converts the [jobs] configuration into worker options.
+func OptionsFromConfig(cfg *config.JobsConfig) (Options, error) {
+	opts := Options{Concurrency: cfg.GetConcurrency()}
+	var err error
+	if opts.PollInterval, err = cfg.GetPollInterval(); err != nil {
+		return opts, fmt.Errorf("invalid poll_interval: %w", err)
+	}
+	if opts.HeartbeatTimeout, err = cfg.GetHeartbeatTimeout(); err != nil {
+		return opts, fmt.Errorf("invalid heartbeat_timeout: %w", err)
+	}
+	if opts.RetryDelay, err = cfg.GetRetryDelay(); err != nil {
+		return opts, fmt.Errorf("invalid retry_delay: %w", err)
+	}
+	if opts.Retention, err = cfg.GetRetention(); err != nil {
+		return opts, fmt.Errorf("invalid retention: %w", err)
+	}
+	return opts, nil
+}
+
+// Worker claims and runs jobs of the types registered with it.
+type Worker struct {
+	store    Store
+	workerID string
+	opts     Options
+	handlers map[string]Handler
+
+	stopCh  chan struct{} // made by each Start, closed by Stop
+	wakeCh  chan struct{}
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	running bool
+}
+
+// NewWorker creates a worker. workerID identifies it in the admin_jobs table
+// and must be unique among running workers, e.g. hostname and PID.
+func NewWorker(store Store, workerID string, opts Options) *Worker {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 10 * time.Second
+	}
+	if opts.HeartbeatTimeout <= 0 {
+		opts.HeartbeatTimeout = 2 * time.Minute
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = time.Minute
+	}
+	return &Worker{
+		store:    store,
+		workerID: workerID,
+		opts:     opts,
+		handlers: make(map[string]Handler),
+		wakeCh:   make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler for a job type. It must be called before Start.
+func (w *Worker) Register(jobType string, h Handler) {
+	w.handlers[jobType] = h
+}
+
+// Types returns the job types this worker runs.
+func (w *Worker) Types() []string {
+	types := make([]string, 0, len(w.handlers))
+	for t := range w.handlers {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Start starts the worker goroutines.
+func (w *Worker) Start(ctx context.Context) {
+	w.mu.Lock()
+	if w.running {
+		w.mu.Unlock()
+		return
+	}
+	w.running = true
+	// A fresh channel per run, so the worker can be started again after Stop.
+	stopCh := make(chan struct{})
+	w.stopCh = stopCh
+	w.mu.Unlock()
+
+	logger.Info("Jobs: worker starting", "worker", w.workerID, "types", w.Types(), "concurrency", w.opts.Concurrency)
+	for i := 0; i < w.opts.Concurrency; i++ {
+		w.wg.Add(1)
+		go w.run(ctx, stopCh)
+	}
+	if w.opts.Retention > 0 {
+		w.wg.Add(1)
+		go w.cleanupLoop(ctx, stopCh)
+	}
+}
+
+// Stop stops the worker and waits for running jobs to return. Interrupted
+// jobs are queued again for another worker.
+func (w *Worker) Stop() {
+	w.mu.Lock()
+	if !w.running {
+		w.mu.Unlock()
+		return
+	}
+	w.running = false
+	close(w.stopCh)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	logger.Info("Jobs: worker stopped", "worker", w.workerID)
+}
+
+// Wake makes an idle worker look for jobs now instead of at the next poll.
+func (w *Worker) Wake() {
+	select {
+	case w.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (w *Worker) run(ctx context.Context, stopCh <-chan struct{}) {
+	defer w.wg.Done()
+	types := w.Types()
+
+	for {
+		claimed := false
+		job, err := w.store.ClaimAdminJobWithRetry(ctx, w.workerID, types, w.opts.HeartbeatTimeout)
+		if err != nil {
+			logger.Warn("Jobs: failed to claim job", "worker", w.workerID, "error", err)
+		} else if job != nil {
+			claimed = true
+			w.runJob(ctx, stopCh, job)
+		}
+
+		if claimed {
+			// Look for the next job straight away, unless stopping.
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopCh:
+				return
+			default:
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-w.wakeCh:
+		case <-time.After(w.opts.PollInterval):
+		}
+	}
+}
+
+// runJob runs a claimed job and records its outcome.
+func (w *Worker) runJob(parent context.Context, stopCh <-chan struct{}, dbJob *db.AdminJob) {
+	job := &Job{AdminJob: dbJob, done: dbJob.ProgressDone, total: dbJob.ProgressTotal, message: dbJob.ProgressMessage}
+	log := []any{"worker", w.workerID, "job", dbJob.ID, "type", dbJob.Type, "attempt", dbJob.Attempts}
+
+	// A job reclaimed from a dead worker may already be cancelled or out of attempts.
+	if dbJob.CancelRequested {
+		w.finish(parent, job, db.AdminJobCancelled, "cancelled", nil)
+		return
+	}
+	if dbJob.Attempts > dbJob.MaxAttempts {
+		w.finish(parent, job, db.AdminJobFailed, dbJob.LastError, nil)
+		return
+	}
+
+	handler := w.handlers[dbJob.Type]
+	if handler == nil {
+		w.finish(parent, job, db.AdminJobFailed, fmt.Sprintf("no handler for job type %q", dbJob.Type), nil)
+		return
+	}
+
+	logger.Info("Jobs: job started", log...)
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		w.heartbeat(ctx, stopCh, cancel, job)
+	}()
+
+	result, err := w.safeRun(ctx, handler, job)
+	cause := context.Cause(ctx)
+	cancel(nil)
+	<-heartbeatDone
+
+	// Write the final state on a context that outlives the job's.
+	finishCtx, finishCancel := context.WithTimeout(context.WithoutCancel(parent), 30*time.Second)
+	defer finishCancel()
+
+	switch {
+	case errors.Is(cause, errJobLost):
+		logger.Warn("Jobs: job taken over by another worker; abandoning", log...)
+	case errors.Is(cause, errJobCancelled):
+		logger.Info("Jobs: job cancelled", log...)
+		w.finish(finishCtx, job, db.AdminJobCancelled, "cancelled", result)
+	case err == nil:
+		logger.Info("Jobs: job succeeded", log...)
+		w.finish(finishCtx, job, db.AdminJobSucceeded, "", result)
+	case errors.Is(cause, errWorkerStop) || parent.Err() != nil:
+		logger.Info("Jobs: worker stopping; job queued again", log...)
+		if rerr := w.store.RequeueAdminJobWithRetry(finishCtx, dbJob.ID, w.workerID, "interrupted by worker shutdown", 0); rerr != nil {
+			logger.Warn("Jobs: failed to requeue interrupted job", append(log, "error", rerr)...)
+		}
+	case errors.As(err, new(permanentError)) || dbJob.Attempts >= dbJob.MaxAttempts:
+		logger.Warn("Jobs: job failed", append(log, "error", err)...)
+		w.finish(finishCtx, job, db.AdminJobFailed, err.Error(), result)
+	default:
+		delay := w.opts.RetryDelay * time.Duration(dbJob.Attempts)
+		logger.Warn("Jobs: job attempt failed; will retry", append(log, "error", err, "retry_in", delay)...)
+		if rerr := w.store.RequeueAdminJobWithRetry(finishCtx, dbJob.ID, w.workerID, err.Error(), delay); rerr != nil {
+			logger.Warn("Jobs: failed to requeue job", append(log, "error", rerr)...)
+		}
+	}
+}
+
+// safeRun runs the handler, turning a panic into a permanent failure so one
+// bad job cannot take down the server.
+func (w *Worker) safeRun(ctx context.Context, h Handler, job *Job) (result map[string]any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("job panicked: %v", r))
+		}
+	}()
+	return h(ctx, job)
+}
+
+// heartbeat writes the job's progress periodically until ctx is done, and
+// cancels ctx when the job is cancelled, taken over, or the worker stops.
+func (w *Worker) heartbeat(ctx context.Context, stopCh <-chan struct{}, cancel context.CancelCauseFunc, job *Job) {
+	interval := min(5*time.Second, w.opts.HeartbeatTimeout/4)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			cancel(errWorkerStop)
+			return
+		case <-ticker.C:
+		}
+		done, total, message := job.progress()
+		cancelRequested, err := w.store.UpdateAdminJobProgressWithRetry(ctx, job.ID, w.workerID, done, total, message)
+		switch {
+		case errors.Is(err, db.ErrAdminJobState):
+			cancel(errJobLost)
+			return
+		case err != nil:
+			if ctx.Err() == nil {
+				logger.Warn("Jobs: failed to record progress", "job", job.ID, "error", err)
+			}
+		case cancelRequested:
+			cancel(errJobCancelled)
+			return
+		}
+	}
+}
+
+func (w *Worker) finish(ctx context.Context, job *Job, status, lastError string, result map[string]any) {
+	if err := w.store.FinishAdminJobWithRetry(ctx, job.ID, w.workerID, status, lastError, result); err != nil {
+		logger.Warn("Jobs: failed to record job outcome", "job", job.ID, "status", status, "error", err)
+	}
+}
+
+func (w *Worker) cleanupLoop(ctx context.Context, stopCh <-chan struct{}) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if n, err := w.store.CleanupOldAdminJobsWithRetry(ctx, w.opts.Retention); err != nil {
+				logger.Warn("Jobs: failed to clean up old jobs", "error", err)
+			} else if n > 0 {
+				logger.Info("Jobs: cleaned up old jobs", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package adminjobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/migadu/sora/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore hands out queued jobs once and records their outcome.
+type fakeStore struct {
+	mu        sync.Mutex
+	queue     []*db.AdminJob
+	cancel    map[int64]bool
+	lost      map[int64]bool
+	finished  map[int64]string
+	lastError map[int64]string
+	results   map[int64]map[string]any
+	requeued  map[int64]time.Duration
+	progress  map[int64][2]int64
+	done      chan int64
+}
+
+func newFakeStore(jobs ...*db.AdminJob) *fakeStore {
+	return &fakeStore{
+		queue:     jobs,
+		cancel:    map[int64]bool{},
+		lost:      map[int64]bool{},
+		finished:  map[int64]string{},
+		lastError: map[int64]string{},
+		results:   map[int64]map[string]any{},
+		requeued:  map[int64]time.Duration{},
+		progress:  map[int64][2]int64{},
+		done:      make(chan int64, 10),
+	}
+}
+
+func (s *fakeStore) ClaimAdminJobWithRetry(ctx context.Context, workerID string, types []string, staleAfter time.Duration) (*db.AdminJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil, nil
+	}
+	job := s.queue[0]
+	s.queue = s.queue[1:]
+	job.Attempts++
+	job.Status = db.AdminJobRunning
+	job.WorkerID = workerID
+	return job, nil
+}
+
+func (s *fakeStore) UpdateAdminJobProgressWithRetry(ctx context.Context, id int64, workerID string, done, total int64, message string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lost[id] {
+		return false, db.ErrAdminJobState
+	}
+	s.progress[id] = [2]int64{done, total}
+	return s.cancel[id], nil
+}
+
+func (s *fakeStore) FinishAdminJobWithRetry(ctx context.Context, id int64, workerID, status, lastError string, result map[string]any) error {
+	s.mu.Lock()
+	s.finished[id] = status
+	s.lastError[id] = lastError
+	s.results[id] = result
+	s.mu.Unlock()
+	s.done <- id
+	return nil
+}
+
+func (s *fakeStore) RequeueAdminJobWithRetry(ctx context.Context, id int64, workerID, lastError string, delay time.Duration) error {
+	s.mu.Lock()
+	s.requeued[id] = delay
+	s.lastError[id] = lastError
+	s.mu.Unlock()
+	s.done <- id
+	return nil
+}
+
+func (s *fakeStore) CleanupOldAdminJobsWithRetry(ctx context.Context, retention time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) setCancel(id int64) {
+	s.mu.Lock()
+	s.cancel[id] = true
+	s.mu.Unlock()
+}
+
+func newTestWorker(store Store) *Worker {
+	return NewWorker(store, "test-worker", Options{
+		PollInterval:     10 * time.Millisecond,
+		HeartbeatTimeout: 40 * time.Millisecond, // heartbeat every 10ms
+		RetryDelay:       time.Second,
+	})
+}
+
+func waitDone(t *testing.T, s *fakeStore) int64 {
+	t.Helper()
+	select {
+	case id := <-s.done:
+		return id
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for job outcome")
+		return 0
+	}
+}
+
+func TestWorkerRunsJob(t *testing.T) {
+	store := newFakeStore(&db.AdminJob{ID: 1, Type: TypeVerifyS3, MaxAttempts: 3})
+	w := newTestWorker(store)
+	w.Register(TypeVerifyS3, func(ctx context.Context, job *Job) (map[string]any, error) {
+		job.SetTotal(2)
+		job.Advance(2, "checked")
+		time.Sleep(30 * time.Millisecond) // let a heartbeat record the progress
+		return map[string]any{"missing": 0}, nil
+	})
+	w.Start(context.Background())
+	defer w.Stop()
+
+	require.Equal(t, int64(1), waitDone(t, store))
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, db.AdminJobSucceeded, store.finished[1])
+	assert.Equal(t, map[string]any{"missing": 0}, store.results[1])
+	assert.Equal(t, [2]int64{2, 2}, store.progress[1])
+}
+
+func TestWorkerRetriesFailedAttempt(t *testing.T) {
+	store := newFakeStore(&db.AdminJob{ID: 1, Type: TypeVerifyS3, MaxAttempts: 3})
+	w := newTestWorker(store)
+	w.Register(TypeVerifyS3, func(ctx context.Context, job *Job) (map[string]any, error) {
+		return nil, errors.New("storage unavailable")
+	})
+	w.Start(context.Background())
+	defer w.Stop()
+
+	waitDone(t, store)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Empty(t, store.finished)
+	assert.Equal(t, time.Second, store.requeued[1])
+	assert.Equal(t, "storage unavailable", store.lastError[1])
+}
+
+func TestWorkerFailsAfterLastAttempt(t *testing.T) {
+	store := newFakeStore(&db.AdminJob{ID: 1, Type: TypeVerifyS3, Attempts: 2, MaxAttempts: 3})
+	w := newTestWorker(store)
+	w.Register(TypeVerifyS3, func(ctx context.Context, job *Job) (map[string]any, error) {
+		return nil, errors.New("storage unavailable")
+	})
+	w.Start(context.Background())
+	defer w.Stop()
+
+	waitDone(t, store)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, db.AdminJobFailed, store.finished[1])
+	assert.Empty(t, store.requeued)
+}
+
+func TestWorkerPermanentError(t *testing.T) {
+	store := newFakeStore(&db.AdminJob{ID: 1, Type: TypeVerifyS3, MaxAttempts: 3})
+	w := newTestWorker(store)
+	w.Register(TypeVerifyS3, func(ctx context.Context, job *Job) (map[string]any, error) {
+		return nil, Permanent(errors.New("account not found"))
+	})
+	w.Start(context.Background())
+	defer w.Stop()
+
+	waitDone(t, store)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, db.AdminJobFailed, store.finished[1])
+	assert.Equal(t, "account not found", store.lastError[1])
+}
+
+func TestWorkerPanicFailsJob(t *testing.T) {
+	store := newFakeStore(&db.AdminJob{ID: 1, Type: TypeVerifyS3, MaxAttempts: 3})
+	w := newTestWorker(store)
+	w.Register(TypeVerifyS3, func(ctx context.Context, job *Job) (map[string]any, error) {
+		panic("boom")
+	})
+	w.Start(context.Background())
+	defer w.Stop()
+
+	waitDone(t, store)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, db.AdminJobFailed, store.finished[1])
+	assert.Contains(t, store.lastError[1], "boom")
+}
+
+func TestWorkerCancelsRunningJob(t *testing.T) {
+	store := newFakeStore(&db.AdminJob{ID: 1, Type: TypeVerifyS3, MaxAttempts: 3})
+	w := newTestWorker(store)
+	started := make(chan struct{})
+	w.Register(TypeVerifyS3, func(ctx context.Context, job *Job) (map[string]any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	w.Start(context.Background())
+	defer w.Stop()
+
+	<-started
+	store.setCancel(1)
+	waitDone(t, store)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, db.AdminJobCancelled, store.finished[1])
+}
+
+func TestWorkerCancelledBeforeStart(t *testing.T) {
+	store := newFakeStore(&db.AdminJob{ID: 1, Type: TypeVerifyS3, MaxAttempts: 3, CancelRequested: true})
+	w := newTestWorker(store)
+	w.Register(TypeVerifyS3, func(ctx context.Context, job *Job) (map[string]any, error) {
+		t.Error("handler must not run for a cancelled job")
+		return nil, nil
+	})
+	w.Start(context.Background())
+	defer w.Stop()
+
+	waitDone(t, store)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, db.AdminJobCancelled, store.finished[1])
+}
+
+func TestWorkerAbandonsLostJob(t *testing.T) {
+	store := newFakeStore(&db.AdminJob{ID: 1, Type: TypeVerifyS3, MaxAttempts: 3})
+	store.lost[1] = true
+	w := newTestWorker(store)
+	returned := make(chan struct{})
+	w.Register(TypeVerifyS3, func(ctx context.Context, job *Job) (map[string]any, error) {
+		defer close(returned)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	w.Start(context.Background())
+	defer w.Stop()
+
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not stopped after losing the job")
+	}
+	// The new owner records the outcome, not this worker.
+	w.Stop()
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Empty(t, store.finished)
+	assert.Empty(t, store.requeued)
+}
+
+func TestWorkerStopRequeuesJob(t *testing.T) {
+	store := newFakeStore(&db.AdminJob{ID: 1, Type: TypeVerifyS3, MaxAttempts: 3})
+	w := newTestWorker(store)
+	started := make(chan struct{})
+	w.Register(TypeVerifyS3, func(ctx context.Context, job *Job) (map[string]any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	w.Start(context.Background())
+
+	<-started
+	w.Stop()
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Empty(t, store.finished)
+	delay, ok := store.requeued[1]
+	assert.True(t, ok)
+	assert.Zero(t, delay)
+}
+
+func TestWorkerRestart(t *testing.T) {
+	store := newFakeStore()
+	w := newTestWorker(store)
+	w.Register(TypeVerifyS3, func(ctx context.Context, job *Job) (map[string]any, error) {
+		return nil, nil
+	})
+	w.Start(context.Background())
+	w.Stop()
+	w.Start(context.Background())
+	defer w.Stop()
+
+	store.mu.Lock()
+	store.queue = append(store.queue, &db.AdminJob{ID: 1, Type: TypeVerifyS3, MaxAttempts: 3})
+	store.mu.Unlock()
+	w.Wake()
+
+	require.Equal(t, int64(1), waitDone(t, store))
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Equal(t, db.AdminJobSucceeded, store.finished[1])
+}
+
+func TestValidateParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		jobType string
+		params  map[string]any
+		wantErr bool
+	}{
+		{"purge account", TypePurgeAccount, map[string]any{"email": "user@example.com"}, false},
+		{"missing email", TypeVerifyS3, map[string]any{}, true},
+		{"unknown field", TypeRebuildFTS, map[string]any{"email": "user@example.com", "extra": 1}, true},
+		{"purge domain", TypePurgeDomain, map[string]any{"domain": "example.com"}, false},
+		{"domain with @", TypePurgeDomain, map[string]any{"domain": "user@example.com"}, true},
+		{"import", TypeImport, map[string]any{"email": "user@example.com", "path": "user/Maildir"}, false},
+		{"import without path", TypeImport, map[string]any{"email": "user@example.com"}, true},
+		{"absolute path", TypeExport, map[string]any{"email": "user@example.com", "path": "/etc"}, true},
+		{"path traversal", TypeExport, map[string]any{"email": "user@example.com", "path": "a/../../etc"}, true},
+		{"takeout", TypeTakeout, map[string]any{"email": "user@example.com"}, false},
+		{"takeout maildir", TypeTakeout, map[string]any{"email": "user@example.com", "format": "maildir"}, false},
+		{"takeout bad format", TypeTakeout, map[string]any{"email": "user@example.com", "format": "pst"}, true},
+		{"unknown type", "reindex", map[string]any{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateParams(tt.jobType, tt.params)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidJob)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	p, err := ResolvePath("/var/lib/sora/jobs", "user/Maildir")
+	require.NoError(t, err)
+	assert.Equal(t, "/var/lib/sora/jobs/user/Maildir", p)
+
+	for _, rel := range []string{"../etc", "/etc", "", "a/../../b"} {
+		_, err := ResolvePath("/var/lib/sora/jobs", rel)
+		assert.Error(t, err, rel)
+	}
+}
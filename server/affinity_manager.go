@@ -26,6 +26,12 @@ const (
 
 	// AffinityEventDelete indicates a user's affinity should be removed
 	AffinityEventDelete AffinityEventType = "AFFINITY_DELETE"
+
+	// AffinityEventDrain indicates a backend is taken out of rotation
+	AffinityEventDrain AffinityEventType = "BACKEND_DRAIN"
+
+	// AffinityEventUndrain indicates a drained backend is back in rotation
+	AffinityEventUndrain AffinityEventType = "BACKEND_UNDRAIN"
 )
 
 // AffinityEvent represents a cluster-wide affinity event
@@ -38,6 +44,10 @@ type AffinityEvent struct {
 	Timestamp  time.Time         `json:"timestamp"`
 	NodeID     string            `json:"node_id"`
 	TTL        time.Duration     `json:"ttl"` // How long affinity is valid
+
+	// Drain events only
+	DrainedAt     time.Time `json:"drained_at"`      // When the drain (or undrain) was requested
+	KickPerMinute int       `json:"kick_per_minute"` // Sessions to kick per minute per proxy (0 = none)
 }
 
 // AffinityInfo tracks affinity information for a user
@@ -76,6 +86,11 @@ type AffinityManager struct {
 	broadcastQueue []AffinityEvent
 	queueMu        sync.Mutex
 
+	// Backend drain state (gossiped, re-announced so joining nodes converge)
+	drains    map[string]BackendDrain // backend -> active drain
+	undrained map[string]time.Time    // backend -> undrain time (tombstones)
+	drainMu   sync.RWMutex
+
 	// Shutdown
 	stopCleanup   chan struct{}
 	stopBroadcast chan struct{}
@@ -106,6 +121,8 @@ func NewAffinityManager(clusterMgr *cluster.Manager, enabled bool, ttl, cleanupI
 		defaultTTL:      ttl,
 		cleanupInterval: cleanupInterval,
 		broadcastQueue:  make([]AffinityEvent, 0, 100),
+		drains:          make(map[string]BackendDrain),
+		undrained:       make(map[string]time.Time),
 		stopCleanup:     make(chan struct{}),
 		stopBroadcast:   make(chan struct{}),
 	}
@@ -456,6 +473,15 @@ func (am *AffinityManager) HandleClusterEvent(data []byte) {
 		am.handleAffinityUpdate(event)
 	case AffinityEventDelete:
 		am.handleAffinityDelete(event)
+	case AffinityEventDrain:
+		am.applyDrain(BackendDrain{
+			Backend:       event.Backend,
+			Since:         event.DrainedAt,
+			KickPerMinute: event.KickPerMinute,
+			NodeID:        event.NodeID,
+		})
+	case AffinityEventUndrain:
+		am.applyUndrain(event.Backend, event.DrainedAt)
 	default:
 		logger.Warn("Affinity: Unknown event type", "type", event.Type)
 	}
@@ -629,8 +655,14 @@ func (am *AffinityManager) broadcastRoutine() {
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
 
+	drainTicker := time.NewTicker(drainAnnounceInterval)
+	defer drainTicker.Stop()
+
 	for {
 		select {
+		case <-drainTicker.C:
+			am.announceDrains()
+
 		case <-ticker.C:
 			// Trigger broadcast by checking queue
 			am.queueMu.Lock()
@@ -686,7 +718,12 @@ func (am *AffinityManager) GetStats(ctx context.Context) map[string]any {
 	queueSize := len(am.broadcastQueue)
 	am.queueMu.Unlock()
 
+	am.drainMu.RLock()
+	drainedCount := len(am.drains)
+	am.drainMu.RUnlock()
+
 	stats := map[string]any{
+		"drained_backends": drainedCount,
 		"enabled":          am.enabled,
 		"total_entries":    totalEntries,
 		"ttl":              am.defaultTTL.String(),
@@ -207,15 +207,19 @@ func (a *AuthRateLimiter) SetClusterLimiter(clusterLimiter *ClusterRateLimiter)
 
 // CanAttemptAuth checks if authentication can be attempted using two-tier blocking
 func (a *AuthRateLimiter) CanAttemptAuth(ctx context.Context, remoteAddr net.Addr, username string) error {
-	if a == nil {
-		return nil
-	}
-
 	ip, _, err := net.SplitHostPort(remoteAddr.String())
 	if err != nil {
 		ip = remoteAddr.String()
 	}
 
+	// Admin-managed blocks apply even when rate limiting is disabled
+	if err := accessListError(ip, username); err != nil {
+		return err
+	}
+	if a == nil {
+		return nil
+	}
+
 	// Exempt networks are never rate limited (decoupled from PROXY/XCLIENT trust)
 	if a.isRateLimitExempt(ip) {
 		return nil
@@ -312,15 +316,19 @@ func (a *AuthRateLimiter) CanAttemptAuth(ctx context.Context, remoteAddr net.Add
 // This is used for TCP-level connection rejection, before we know the username.
 // Returns true if the IP should be rejected at the TCP accept level.
 func (a *AuthRateLimiter) IsIPBlocked(remoteAddr net.Addr) bool {
-	if a == nil || a.config.MaxAttemptsPerIP == 0 {
-		return false // Rate limiting disabled or Tier 2 disabled
-	}
-
 	ip, _, err := net.SplitHostPort(remoteAddr.String())
 	if err != nil {
 		ip = remoteAddr.String()
 	}
 
+	// Admin-managed blocks apply even when rate limiting is disabled
+	if GetAccessList().IsIPBlocked(ip) {
+		return true
+	}
+	if a == nil || a.config.MaxAttemptsPerIP == 0 {
+		return false // Rate limiting disabled or Tier 2 disabled
+	}
+
 	// Exempt networks are never blocked (Tier 2)
 	if a.isRateLimitExempt(ip) {
 		return false
@@ -342,10 +350,6 @@ func (a *AuthRateLimiter) IsIPBlocked(remoteAddr net.Addr) bool {
 // IsIPBlockedWithProxy checks if an IP is currently blocked, supporting PROXY protocol.
 // This is used for TCP-level connection rejection with proper proxy IP detection.
 func (a *AuthRateLimiter) IsIPBlockedWithProxy(conn net.Conn, proxyInfo *ProxyProtocolInfo) bool {
-	if a == nil || a.config.MaxAttemptsPerIP == 0 {
-		return false // Rate limiting disabled or Tier 2 disabled
-	}
-
 	// Determine real client IP (with PROXY protocol support)
 	var realClientIP string
 	if proxyInfo != nil && proxyInfo.SrcIP != "" {
@@ -359,6 +363,14 @@ func (a *AuthRateLimiter) IsIPBlockedWithProxy(conn net.Conn, proxyInfo *ProxyPr
 		}
 	}
 
+	// Admin-managed blocks apply even when rate limiting is disabled
+	if GetAccessList().IsIPBlocked(realClientIP) {
+		return true
+	}
+	if a == nil || a.config.MaxAttemptsPerIP == 0 {
+		return false // Rate limiting disabled or Tier 2 disabled
+	}
+
 	// Exempt networks are never blocked (Tier 2)
 	if a.isRateLimitExempt(realClientIP) {
 		return false
@@ -379,13 +391,14 @@ func (a *AuthRateLimiter) IsIPBlockedWithProxy(conn net.Conn, proxyInfo *ProxyPr
 
 // CanAttemptAuthWithProxy checks if authentication can be attempted with proper proxy IP detection
 func (a *AuthRateLimiter) CanAttemptAuthWithProxy(ctx context.Context, conn net.Conn, proxyInfo *ProxyProtocolInfo, username string) error {
-	if a == nil {
-		return nil
-	}
-
 	// Extract real client IP and proxy IP
 	clientIP, proxyIP := GetConnectionIPs(conn, proxyInfo)
 
+	if a == nil {
+		// Admin-managed blocks apply even when rate limiting is disabled
+		return accessListError(clientIP, username)
+	}
+
 	// Exempt the real client IP from rate limiting if it is in the exempt networks
 	if a.isRateLimitExempt(clientIP) {
 		if proxyIP != "" {
@@ -393,7 +406,8 @@ func (a *AuthRateLimiter) CanAttemptAuthWithProxy(ctx context.Context, conn net.
 		} else {
 			logger.Debug("Auth limiter: Skipping rate limiting for exempt client", "protocol", a.protocol, "client", clientIP)
 		}
-		return nil
+		// Username blocks still apply to exempt networks
+		return accessListError(clientIP, username)
 	}
 
 	// Use the real client IP for rate limiting
@@ -443,8 +457,12 @@ func (a *AuthRateLimiter) RecordAuthAttemptWithProxy(ctx context.Context, conn n
 
 // isRateLimitExempt reports whether an IP is exempt from IP-based blocking (Tier 1 and
 // Tier 2). Username statistics are tracked regardless. The exemption list is decoupled
-// from PROXY/XCLIENT source-IP trust. (security-audit M2)
+// from PROXY/XCLIENT source-IP trust. (security-audit M2) Access list allow
+// entries exempt an IP as well.
 func (a *AuthRateLimiter) isRateLimitExempt(ipStr string) bool {
+	if GetAccessList().IsIPAllowed(ipStr) {
+		return true
+	}
 	if len(a.exemptNetworks) == 0 {
 		return false
 	}
@@ -947,6 +965,63 @@ func (a *AuthRateLimiter) GetBlockedEntries() []BlockedEntry {
 	return entries
 }
 
+// Unblock lifts automatic blocks and clears failure tracking so the client can
+// authenticate again. With only ip set, the IP block and every IP+username block
+// from that IP are lifted; with only username set, every IP+username block for
+// that username; with both, just that IP+username block. The unblock is
+// broadcast to the cluster. Returns the number of active blocks lifted.
+func (a *AuthRateLimiter) Unblock(ip, username string) int {
+	if a == nil {
+		return 0
+	}
+	lifted := a.unblockLocal(ip, username)
+	if a.clusterLimiter != nil {
+		a.clusterLimiter.BroadcastUnblock(ip, username)
+	}
+	return lifted
+}
+
+// unblockLocal implements Unblock without notifying the cluster.
+func (a *AuthRateLimiter) unblockLocal(ip, username string) int {
+	now := time.Now()
+	lifted := 0
+
+	a.ipUsernameMu.Lock()
+	for key, info := range a.blockedIPUsernames {
+		if (ip != "" && info.IP != ip) || (username != "" && info.Username != username) {
+			continue
+		}
+		if info.BlockedUntil.After(now) {
+			lifted++
+		}
+		delete(a.blockedIPUsernames, key)
+	}
+	a.ipUsernameMu.Unlock()
+
+	if ip != "" && username == "" {
+		a.ipMu.Lock()
+		if info, exists := a.blockedIPs[ip]; exists {
+			if info.BlockedUntil.After(now) {
+				lifted++
+			}
+			delete(a.blockedIPs, ip)
+		}
+		delete(a.ipFailureCounts, ip)
+		a.ipMu.Unlock()
+	}
+
+	if ip == "" && username != "" {
+		a.usernameMu.Lock()
+		delete(a.usernameFailureCounts, username)
+		a.usernameMu.Unlock()
+	}
+
+	if lifted > 0 {
+		logger.Info("Auth rate limiter: Lifted blocks", "protocol", a.protocol, "ip", ip, "username", username, "count", lifted)
+	}
+	return lifted
+}
+
 func (a *AuthRateLimiter) Stop() {
 	if a == nil {
 		return
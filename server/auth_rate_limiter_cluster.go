@@ -17,7 +17,7 @@ const (
 	// RateLimitEventBlockIP indicates an IP should be blocked
 	RateLimitEventBlockIP RateLimitEventType = "BLOCK_IP"
 
-	// RateLimitEventUnblockIP indicates an IP block should be lifted
+	// RateLimitEventUnblockIP indicates an IP, IP+username or username block should be lifted
 	RateLimitEventUnblockIP RateLimitEventType = "UNBLOCK_IP"
 
 	// RateLimitEventFailureCount indicates progressive delay failure count update
@@ -119,8 +119,9 @@ func (crl *ClusterRateLimiter) BroadcastBlockIP(ip string, blockedUntil time.Tim
 	crl.queueEvent(event)
 }
 
-// BroadcastUnblockIP broadcasts an IP unblock event to the cluster
-func (crl *ClusterRateLimiter) BroadcastUnblockIP(ip string) {
+// BroadcastUnblock broadcasts a manual unblock to the cluster. ip or username may
+// be empty (see AuthRateLimiter.Unblock).
+func (crl *ClusterRateLimiter) BroadcastUnblock(ip, username string) {
 	if !crl.syncBlocks {
 		return
 	}
@@ -128,6 +129,7 @@ func (crl *ClusterRateLimiter) BroadcastUnblockIP(ip string) {
 	event := RateLimitEvent{
 		Type:      RateLimitEventUnblockIP,
 		IP:        ip,
+		Username:  username,
 		Timestamp: time.Now(),
 		NodeID:    crl.clusterManager.GetNodeID(),
 	}
@@ -318,19 +320,15 @@ func (crl *ClusterRateLimiter) handleBlockIP(event RateLimitEvent) {
 		"ip", event.IP, "node", event.NodeID, "until", event.BlockedUntil, "failures", event.FailureCount)
 }
 
-// handleUnblockIP removes an IP block from another node
+// handleUnblockIP applies an unblock from another node
 func (crl *ClusterRateLimiter) handleUnblockIP(event RateLimitEvent) {
 	if !crl.syncBlocks {
 		return
 	}
 
-	crl.limiter.ipMu.Lock()
-	defer crl.limiter.ipMu.Unlock()
-
-	if _, exists := crl.limiter.blockedIPs[event.IP]; exists {
-		delete(crl.limiter.blockedIPs, event.IP)
-		logger.Debug("Cluster limiter: Applied cluster unblock", "protocol", crl.limiter.protocol, "ip", event.IP, "from_node", event.NodeID)
-	}
+	lifted := crl.limiter.unblockLocal(event.IP, event.Username)
+	logger.Debug("Cluster limiter: Applied cluster unblock", "protocol", crl.limiter.protocol,
+		"ip", event.IP, "username", event.Username, "lifted", lifted, "from_node", event.NodeID)
 }
 
 // handleFailureCount updates progressive delay tracking from another node
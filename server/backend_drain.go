@@ -0,0 +1,216 @@
+package server
+
+import (
+	"net"
+	"sort"
+	"time"
+
+	"github.com/migadu/sora/logger"
+)
+
+const (
+	// drainAnnounceInterval is how often known drains and undrains are re-gossiped,
+	// so nodes that join (or missed an event) converge on the same drain state.
+	drainAnnounceInterval = 30 * time.Second
+
+	// drainTombstoneTTL is how long an undrain is remembered (and re-announced) so
+	// that a late re-announcement of the older drain cannot resurrect it.
+	drainTombstoneTTL = 10 * time.Minute
+)
+
+// BackendDrain describes a backend taken out of rotation: proxies send it no new
+// sessions, move affinity away from it and optionally kick existing sessions.
+type BackendDrain struct {
+	Backend       string    `json:"backend"`         // host:port, or a bare host to drain every port
+	Since         time.Time `json:"since"`           // When the drain was requested
+	KickPerMinute int       `json:"kick_per_minute"` // Sessions each proxy kicks per minute (0 = none)
+	NodeID        string    `json:"node_id"`         // Node the drain was announced by
+}
+
+// Matches reports whether the drain applies to the backend address addr.
+// A drain on a bare host matches that host on any port, so draining a machine
+// covers every protocol proxy pointing at it.
+func (d BackendDrain) Matches(addr string) bool {
+	if d.Backend == addr {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && host == d.Backend
+}
+
+// DrainBackend takes a backend out of rotation cluster-wide. Affinity pointing at
+// the backend is dropped so users are re-placed on their next connection.
+func (am *AffinityManager) DrainBackend(backend string, kickPerMinute int) {
+	if am == nil {
+		return
+	}
+
+	drain := BackendDrain{
+		Backend:       backend,
+		Since:         time.Now(),
+		KickPerMinute: kickPerMinute,
+		NodeID:        am.nodeID(),
+	}
+	am.applyDrain(drain)
+	am.queueEvent(drainEvent(drain))
+}
+
+// UndrainBackend returns a drained backend to rotation cluster-wide.
+// Returns false if the backend was not drained.
+func (am *AffinityManager) UndrainBackend(backend string) bool {
+	if am == nil {
+		return false
+	}
+
+	am.drainMu.RLock()
+	_, drained := am.drains[backend]
+	am.drainMu.RUnlock()
+
+	now := time.Now()
+	am.applyUndrain(backend, now)
+	am.queueEvent(undrainEvent(backend, now, am.nodeID()))
+	return drained
+}
+
+// IsBackendDraining reports whether any active drain matches the backend address.
+func (am *AffinityManager) IsBackendDraining(addr string) bool {
+	if am == nil {
+		return false
+	}
+
+	am.drainMu.RLock()
+	defer am.drainMu.RUnlock()
+	for _, d := range am.drains {
+		if d.Matches(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDrainedBackends returns the active drains, oldest first.
+func (am *AffinityManager) GetDrainedBackends() []BackendDrain {
+	if am == nil {
+		return nil
+	}
+
+	am.drainMu.RLock()
+	drains := make([]BackendDrain, 0, len(am.drains))
+	for _, d := range am.drains {
+		drains = append(drains, d)
+	}
+	am.drainMu.RUnlock()
+
+	sort.Slice(drains, func(i, j int) bool { return drains[i].Since.Before(drains[j].Since) })
+	return drains
+}
+
+// applyDrain records a drain unless a newer undrain or drain for the same backend
+// is already known (last-write-wins on the request time).
+func (am *AffinityManager) applyDrain(d BackendDrain) {
+	am.drainMu.Lock()
+	if undrainedAt, ok := am.undrained[d.Backend]; ok && !d.Since.After(undrainedAt) {
+		am.drainMu.Unlock()
+		return
+	}
+	if existing, ok := am.drains[d.Backend]; ok && !d.Since.After(existing.Since) {
+		am.drainMu.Unlock()
+		return
+	}
+	am.drains[d.Backend] = d
+	delete(am.undrained, d.Backend)
+	am.drainMu.Unlock()
+
+	logger.Info("Affinity: Backend drained", "backend", d.Backend, "kick_per_minute", d.KickPerMinute, "node", d.NodeID)
+
+	// Migrate affinity away from the drained backend. Every node applies the drain
+	// itself, so the deletions are local and not gossiped.
+	am.mu.Lock()
+	removed := 0
+	for key, info := range am.affinityMap {
+		if d.Matches(info.Backend) {
+			delete(am.affinityMap, key)
+			removed++
+			username := key[:len(key)-len(info.Protocol)-1]
+			am.persistDeleteAsync(username, info.Protocol)
+		}
+	}
+	am.mu.Unlock()
+
+	if removed > 0 {
+		logger.Info("Affinity: Dropped affinities for drained backend", "backend", d.Backend, "count", removed)
+	}
+}
+
+// applyUndrain removes a drain requested before at and remembers the undrain.
+func (am *AffinityManager) applyUndrain(backend string, at time.Time) {
+	am.drainMu.Lock()
+	defer am.drainMu.Unlock()
+
+	if existing, ok := am.drains[backend]; ok {
+		if existing.Since.After(at) {
+			return
+		}
+		delete(am.drains, backend)
+		logger.Info("Affinity: Backend undrained", "backend", backend)
+	}
+	if prev, ok := am.undrained[backend]; !ok || at.After(prev) {
+		am.undrained[backend] = at
+	}
+}
+
+// announceDrains re-queues the known drain state and expires old undrain tombstones.
+func (am *AffinityManager) announceDrains() {
+	nodeID := am.nodeID()
+	now := time.Now()
+
+	am.drainMu.Lock()
+	events := make([]AffinityEvent, 0, len(am.drains)+len(am.undrained))
+	for _, d := range am.drains {
+		events = append(events, drainEvent(d))
+	}
+	for backend, at := range am.undrained {
+		if now.Sub(at) > drainTombstoneTTL {
+			delete(am.undrained, backend)
+			continue
+		}
+		events = append(events, undrainEvent(backend, at, nodeID))
+	}
+	am.drainMu.Unlock()
+
+	for _, event := range events {
+		// Re-announcements are sent as our own: receivers skip events from themselves
+		// and the original requester may be gone.
+		event.NodeID = nodeID
+		am.queueEvent(event)
+	}
+}
+
+// nodeID returns this node's cluster ID, or "" without a cluster manager.
+func (am *AffinityManager) nodeID() string {
+	if am.clusterManager == nil {
+		return ""
+	}
+	return am.clusterManager.GetNodeID()
+}
+
+func drainEvent(d BackendDrain) AffinityEvent {
+	return AffinityEvent{
+		Type:          AffinityEventDrain,
+		Backend:       d.Backend,
+		Timestamp:     time.Now(),
+		NodeID:        d.NodeID,
+		DrainedAt:     d.Since,
+		KickPerMinute: d.KickPerMinute,
+	}
+}
+
+func undrainEvent(backend string, at time.Time, nodeID string) AffinityEvent {
+	return AffinityEvent{
+		Type:      AffinityEventUndrain,
+		Backend:   backend,
+		Timestamp: time.Now(),
+		NodeID:    nodeID,
+		DrainedAt: at,
+	}
+}
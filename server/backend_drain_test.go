@@ -0,0 +1,148 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestDrainAffinityManager builds an AffinityManager without cluster or database
+func newTestDrainAffinityManager() *AffinityManager {
+	return &AffinityManager{
+		affinityMap:    make(map[string]*AffinityInfo),
+		drains:         make(map[string]BackendDrain),
+		undrained:      make(map[string]time.Time),
+		broadcastQueue: make([]AffinityEvent, 0),
+	}
+}
+
+func TestBackendDrainMatches(t *testing.T) {
+	tests := []struct {
+		backend string
+		addr    string
+		want    bool
+	}{
+		{"10.0.0.1:143", "10.0.0.1:143", true},
+		{"10.0.0.1:143", "10.0.0.1:110", false},
+		{"10.0.0.1", "10.0.0.1:143", true},
+		{"10.0.0.1", "10.0.0.1:110", true},
+		{"10.0.0.1", "10.0.0.10:143", false},
+		{"[::1]:143", "[::1]:143", true},
+		{"::1", "[::1]:143", true},
+		{"backend1", "backend1", true},
+	}
+	for _, tt := range tests {
+		d := BackendDrain{Backend: tt.backend}
+		if got := d.Matches(tt.addr); got != tt.want {
+			t.Errorf("BackendDrain{%q}.Matches(%q) = %v, want %v", tt.backend, tt.addr, got, tt.want)
+		}
+	}
+}
+
+// TestAffinityManagerDrainLastWriteWins verifies drains and undrains from gossip
+// converge on the most recent request regardless of arrival order
+func TestAffinityManagerDrainLastWriteWins(t *testing.T) {
+	am := newTestDrainAffinityManager()
+	t0 := time.Now().Add(-time.Minute)
+
+	am.applyDrain(BackendDrain{Backend: "10.0.0.1:143", Since: t0, KickPerMinute: 5})
+	if !am.IsBackendDraining("10.0.0.1:143") {
+		t.Fatal("Expected backend to be draining")
+	}
+
+	// An older re-announcement must not overwrite the newer drain
+	am.applyDrain(BackendDrain{Backend: "10.0.0.1:143", Since: t0.Add(-time.Second), KickPerMinute: 1})
+	if d := am.GetDrainedBackends(); len(d) != 1 || d[0].KickPerMinute != 5 {
+		t.Errorf("Expected drain with kick_per_minute 5, got %+v", d)
+	}
+
+	// An undrain older than the drain is ignored
+	am.applyUndrain("10.0.0.1:143", t0.Add(-time.Second))
+	if !am.IsBackendDraining("10.0.0.1:143") {
+		t.Error("Expected older undrain to be ignored")
+	}
+
+	am.applyUndrain("10.0.0.1:143", t0.Add(time.Second))
+	if am.IsBackendDraining("10.0.0.1:143") {
+		t.Fatal("Expected backend to be undrained")
+	}
+
+	// A late re-announcement of the original drain must not resurrect it
+	am.applyDrain(BackendDrain{Backend: "10.0.0.1:143", Since: t0, KickPerMinute: 5})
+	if am.IsBackendDraining("10.0.0.1:143") {
+		t.Error("Expected tombstone to block stale drain")
+	}
+
+	// A drain newer than the undrain applies again
+	am.applyDrain(BackendDrain{Backend: "10.0.0.1:143", Since: t0.Add(2 * time.Second)})
+	if !am.IsBackendDraining("10.0.0.1:143") {
+		t.Error("Expected newer drain to apply")
+	}
+}
+
+// TestAffinityManagerDrainDropsAffinity verifies affinity to a drained host is removed
+func TestAffinityManagerDrainDropsAffinity(t *testing.T) {
+	am := newTestDrainAffinityManager()
+	am.affinityMap["alice@example.com:imap"] = &AffinityInfo{Backend: "10.0.0.1:143", Protocol: "imap"}
+	am.affinityMap["alice@example.com:pop3"] = &AffinityInfo{Backend: "10.0.0.1:110", Protocol: "pop3"}
+	am.affinityMap["bob@example.com:imap"] = &AffinityInfo{Backend: "10.0.0.2:143", Protocol: "imap"}
+
+	am.DrainBackend("10.0.0.1", 0)
+
+	if len(am.affinityMap) != 1 {
+		t.Fatalf("Expected 1 remaining affinity, got %d", len(am.affinityMap))
+	}
+	if _, ok := am.affinityMap["bob@example.com:imap"]; !ok {
+		t.Error("Expected affinity to undrained backend to remain")
+	}
+
+	if !am.UndrainBackend("10.0.0.1") {
+		t.Error("Expected undrain to report the backend was drained")
+	}
+	if am.UndrainBackend("10.0.0.1") {
+		t.Error("Expected second undrain to report nothing drained")
+	}
+}
+
+func TestConnectionTrackerBackendSessions(t *testing.T) {
+	tracker := NewConnectionTracker("IMAP", "", "", "node-1", nil, 0, 0, 0, false)
+
+	ch1 := tracker.RegisterBackendSession(1, "10.0.0.1:143")
+	ch2 := tracker.RegisterBackendSession(1, "10.0.0.1:143")
+	ch3 := tracker.RegisterBackendSession(2, "10.0.0.2:143")
+	_ = tracker.RegisterSession(3)
+
+	if n := tracker.BackendSessionCount("10.0.0.1:143"); n != 2 {
+		t.Errorf("Expected 2 sessions on 10.0.0.1:143, got %d", n)
+	}
+
+	tracker.UnregisterSession(2, ch3)
+	if n := tracker.BackendSessionCount("10.0.0.2:143"); n != 0 {
+		t.Errorf("Expected 0 sessions on 10.0.0.2:143 after unregister, got %d", n)
+	}
+
+	if kicked := tracker.KickBackendSessions("10.0.0.1:143", 1); kicked != 1 {
+		t.Errorf("Expected 1 session kicked, got %d", kicked)
+	}
+	if n := tracker.BackendSessionCount("10.0.0.1:143"); n != 1 {
+		t.Errorf("Expected 1 session left, got %d", n)
+	}
+
+	closed := 0
+	for _, ch := range []<-chan struct{}{ch1, ch2} {
+		select {
+		case <-ch:
+			closed++
+		default:
+		}
+	}
+	if closed != 1 {
+		t.Errorf("Expected exactly 1 kicked channel closed, got %d", closed)
+	}
+
+	if kicked := tracker.KickBackendSessions("10.0.0.1:143", 10); kicked != 1 {
+		t.Errorf("Expected remaining session kicked, got %d", kicked)
+	}
+	// Unregistering an already kicked session is harmless
+	tracker.UnregisterSession(1, ch1)
+	tracker.UnregisterSession(1, ch2)
+}
@@ -40,6 +40,8 @@ type DatabaseManager interface {
 	CleanupOldVacationResponsesWithRetry(ctx context.Context, gracePeriod time.Duration) (int64, error)
 	CleanupOldRedirectsWithRetry(ctx context.Context, gracePeriod time.Duration) (int64, error)
 	CleanupOldHealthStatusesWithRetry(ctx context.Context, retention time.Duration) (int64, error)
+	CleanupOldAuditEntriesWithRetry(ctx context.Context, retention time.Duration) (int64, error)
+	MaintainDeliveryLogWithRetry(ctx context.Context, retention time.Duration) (db.DeliveryLogMaintenance, error)
 	GetUserScopedObjectsForCleanupWithRetry(ctx context.Context, gracePeriod time.Duration, limit int) ([]db.UserScopedObjectForCleanup, error)
 	ExecuteS3DeleteTxWithRetry(ctx context.Context, accountID int64, contentHash string, gracePeriod time.Duration, s3DeleteFunc func() error) (bool, error)
 	DeleteExpungedMessagesByS3KeyPartsBatchWithRetry(ctx context.Context, objects []db.UserScopedObjectForCleanup) (int64, error)
@@ -71,6 +73,8 @@ type CleanupWorker struct {
 	maxAgeRestriction     time.Duration
 	ftsRetention          time.Duration // How long to keep FTS vectors
 	healthStatusRetention time.Duration
+	auditLogRetention     time.Duration // How long to keep audit log entries (0 = forever)
+	deliveryLogRetention  time.Duration // How long to keep delivery log entries (0 = forever)
 	stopCh                chan struct{}
 	errCh                 chan<- error
 	wg                    sync.WaitGroup
@@ -79,7 +83,7 @@ type CleanupWorker struct {
 }
 
 // New creates a new CleanupWorker.
-func New(rdb *resilient.ResilientDatabase, s3 *storage.S3Storage, cache *cache.Cache, interval, gracePeriod, maxAgeRestriction, ftsRetention, healthStatusRetention time.Duration, errCh chan<- error) *CleanupWorker {
+func New(rdb *resilient.ResilientDatabase, s3 storage.Backend, cache *cache.Cache, interval, gracePeriod, maxAgeRestriction, ftsRetention, healthStatusRetention, auditLogRetention, deliveryLogRetention time.Duration, errCh chan<- error) *CleanupWorker {
 	// Wrap S3 storage with resilient patterns including circuit breakers
 	resilientS3 := resilient.NewResilientS3Storage(s3)
 
@@ -92,6 +96,8 @@ func New(rdb *resilient.ResilientDatabase, s3 *storage.S3Storage, cache *cache.C
 		maxAgeRestriction:     maxAgeRestriction,
 		ftsRetention:          ftsRetention,
 		healthStatusRetention: healthStatusRetention,
+		auditLogRetention:     auditLogRetention,
+		deliveryLogRetention:  deliveryLogRetention,
 		stopCh:                make(chan struct{}),
 		errCh:                 errCh,
 	}
@@ -131,6 +137,12 @@ func (w *CleanupWorker) run(ctx context.Context) {
 		logParts = append(logParts, fmt.Sprintf("FTS vector retention: %v", w.ftsRetention))
 	}
 	logParts = append(logParts, fmt.Sprintf("health status retention: %v", w.healthStatusRetention))
+	if w.auditLogRetention > 0 {
+		logParts = append(logParts, fmt.Sprintf("audit log retention: %v", w.auditLogRetention))
+	}
+	if w.deliveryLogRetention > 0 {
+		logParts = append(logParts, fmt.Sprintf("delivery log retention: %v", w.deliveryLogRetention))
+	}
 
 	logger.Info("Cleanup: Worker processing", "config", strings.Join(logParts, ", "))
 
@@ -284,6 +296,26 @@ func (w *CleanupWorker) runOnce(ctx context.Context) error {
 		}
 	}
 
+	// --- Cleanup of old audit log entries ---
+	// Entries are pruned from the start of the hash chain, so the remaining
+	// log still verifies from its oldest entry.
+	if w.auditLogRetention > 0 {
+		if auditCount, err := w.rdb.CleanupOldAuditEntriesWithRetry(ctx, w.auditLogRetention); err != nil {
+			logger.Error("Cleanup: Failed to clean up old audit log entries", "error", err)
+		} else if auditCount > 0 {
+			logger.Info("Cleanup: Deleted old audit log entries", "count", auditCount, "retention", w.auditLogRetention)
+		}
+	}
+
+	// --- Delivery log partitions ---
+	// The delivery log is partitioned by day: partitions for the coming days
+	// are created ahead of time, and expired ones are dropped whole.
+	if m, err := w.rdb.MaintainDeliveryLogWithRetry(ctx, w.deliveryLogRetention); err != nil {
+		logger.Error("Cleanup: Failed to maintain delivery log partitions", "error", err)
+	} else if m.Created > 0 || m.Dropped > 0 || m.Deleted > 0 {
+		logger.Info("Cleanup: Maintained delivery log", "partitions_created", m.Created, "partitions_dropped", m.Dropped, "entries_deleted", m.Deleted, "retention", w.deliveryLogRetention)
+	}
+
 	// --- Reconcile drifted mailbox stats ---
 	// The unseen_count cache is maintained incrementally by triggers and can drift
 	// negative under concurrent flag/expunge races (see db.lockMailboxStats). This
@@ -53,6 +53,14 @@ func (m *mockDatabase) CleanupOldHealthStatusesWithRetry(ctx context.Context, re
 	args := m.Called(ctx, retention)
 	return args.Get(0).(int64), args.Error(1)
 }
+func (m *mockDatabase) CleanupOldAuditEntriesWithRetry(ctx context.Context, retention time.Duration) (int64, error) {
+	args := m.Called(ctx, retention)
+	return args.Get(0).(int64), args.Error(1)
+}
+func (m *mockDatabase) MaintainDeliveryLogWithRetry(ctx context.Context, retention time.Duration) (db.DeliveryLogMaintenance, error) {
+	args := m.Called(ctx, retention)
+	return args.Get(0).(db.DeliveryLogMaintenance), args.Error(1)
+}
 func (m *mockDatabase) GetUserScopedObjectsForCleanupWithRetry(ctx context.Context, gracePeriod time.Duration, limit int) ([]db.UserScopedObjectForCleanup, error) {
 	args := m.Called(ctx, gracePeriod, limit)
 	return args.Get(0).([]db.UserScopedObjectForCleanup), args.Error(1)
@@ -151,6 +159,7 @@ func TestCleanupWorker_RunOnce_HappyPath(t *testing.T) {
 	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, gracePeriod).Return(int64(2), nil).Once()
 	mockDB.On("CleanupOldRedirectsWithRetry", ctx, gracePeriod).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldHealthStatusesWithRetry", ctx, healthRetention).Return(int64(20), nil).Once()
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, mock.Anything).Return(db.DeliveryLogMaintenance{}, nil).Once()
 
 	// Phase 1: User-scoped cleanup
 	userScopedCandidates := []db.UserScopedObjectForCleanup{
@@ -234,6 +243,7 @@ func TestCleanupWorker_RunOnce_PartialFailures(t *testing.T) {
 	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldRedirectsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldHealthStatusesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, mock.Anything).Return(db.DeliveryLogMaintenance{}, nil).Once()
 	mockDB.On("GetUserScopedObjectsForCleanupWithRetry", ctx, mock.Anything, mock.Anything).Return([]db.UserScopedObjectForCleanup{}, criticalErr).Once()
 
 	err := worker.runOnce(ctx)
@@ -265,6 +275,7 @@ func TestCleanupWorker_RunOnce_S3DeleteFails(t *testing.T) {
 	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, mock.Anything).Return(int64(0), nil)
 	mockDB.On("CleanupOldRedirectsWithRetry", ctx, mock.Anything).Return(int64(0), nil)
 	mockDB.On("CleanupOldHealthStatusesWithRetry", ctx, mock.Anything).Return(int64(0), nil)
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, mock.Anything).Return(db.DeliveryLogMaintenance{}, nil)
 
 	s3Err := errors.New("s3 is down")
 	candidates := []db.UserScopedObjectForCleanup{{ContentHash: "hash1", S3Domain: "d", S3Localpart: "l"}}
@@ -306,6 +317,7 @@ func TestCleanupWorker_RunOnce_NoOp(t *testing.T) {
 	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldRedirectsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldHealthStatusesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, mock.Anything).Return(db.DeliveryLogMaintenance{}, nil).Once()
 	mockDB.On("GetUserScopedObjectsForCleanupWithRetry", ctx, mock.Anything, mock.Anything).Return([]db.UserScopedObjectForCleanup{}, nil).Once()
 	mockDB.On("GetUnusedFTSHashesWithRetry", ctx, mock.Anything).Return([]string{}, nil).Once()
 	mockDB.On("GetDanglingAccountsForFinalDeletionWithRetry", ctx, mock.Anything).Return([]int64{}, nil).Once()
@@ -343,6 +355,7 @@ func TestCleanupWorker_RunOnce_VectorPruning(t *testing.T) {
 	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldRedirectsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldHealthStatusesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, mock.Anything).Return(db.DeliveryLogMaintenance{}, nil).Once()
 	mockDB.On("GetUserScopedObjectsForCleanupWithRetry", ctx, mock.Anything, mock.Anything).Return([]db.UserScopedObjectForCleanup{}, nil).Once()
 
 	// Both pruning functions should be called
@@ -379,6 +392,7 @@ func TestCleanupWorker_RunOnce_NoFTSPruningWhenBothZero(t *testing.T) {
 	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldRedirectsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldHealthStatusesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, mock.Anything).Return(db.DeliveryLogMaintenance{}, nil).Once()
 	mockDB.On("GetUserScopedObjectsForCleanupWithRetry", ctx, mock.Anything, mock.Anything).Return([]db.UserScopedObjectForCleanup{}, nil).Once()
 
 	// PruneOldMessageVectorsWithRetry should not be called (ftsRetention = 0)
@@ -417,6 +431,7 @@ func TestCleanupWorker_RunOnce_NoFTSRetention(t *testing.T) {
 	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldRedirectsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldHealthStatusesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, mock.Anything).Return(db.DeliveryLogMaintenance{}, nil).Once()
 	mockDB.On("GetUserScopedObjectsForCleanupWithRetry", ctx, mock.Anything, mock.Anything).Return([]db.UserScopedObjectForCleanup{}, nil).Once()
 
 	// Vector pruning should NOT be called (ftsRetention = 0)
@@ -459,6 +474,7 @@ func TestCleanupWorker_RunOnce_SkipsFailedUploadCleanupWhenS3Unhealthy(t *testin
 	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldRedirectsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldHealthStatusesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, mock.Anything).Return(db.DeliveryLogMaintenance{}, nil).Once()
 	mockDB.On("GetUserScopedObjectsForCleanupWithRetry", ctx, mock.Anything, mock.Anything).Return([]db.UserScopedObjectForCleanup{}, nil).Once()
 	mockDB.On("GetUnusedFTSHashesWithRetry", ctx, mock.Anything).Return([]string{}, nil).Once()
 	mockDB.On("GetDanglingAccountsForFinalDeletionWithRetry", ctx, mock.Anything).Return([]int64{}, nil).Once()
@@ -496,6 +512,7 @@ func TestCleanupWorker_RunOnce_VectorOnlyPruning(t *testing.T) {
 	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldRedirectsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
 	mockDB.On("CleanupOldHealthStatusesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, mock.Anything).Return(db.DeliveryLogMaintenance{}, nil).Once()
 	mockDB.On("GetUserScopedObjectsForCleanupWithRetry", ctx, mock.Anything, mock.Anything).Return([]db.UserScopedObjectForCleanup{}, nil).Once()
 
 	// Vector pruning should be called when ftsRetention > 0
@@ -509,3 +526,70 @@ func TestCleanupWorker_RunOnce_VectorOnlyPruning(t *testing.T) {
 	assert.NoError(t, err)
 	mockDB.AssertExpectations(t)
 }
+
+func TestCleanupWorker_RunOnce_AuditLogRetention(t *testing.T) {
+	mockDB := new(mockDatabase)
+	mockCache := new(mockCache)
+	ctx := context.Background()
+
+	auditLogRetention := 365 * 24 * time.Hour
+
+	worker := &CleanupWorker{
+		rdb:                   mockDB,
+		s3:                    &mockS3{healthy: true},
+		cache:                 mockCache,
+		healthStatusRetention: 1 * time.Hour,
+		auditLogRetention:     auditLogRetention,
+	}
+
+	mockDB.On("AcquireCleanupLockWithRetry", ctx).Return(true, nil).Once()
+	mockDB.On("ReleaseCleanupLockWithRetry", ctx).Return(nil).Once()
+	mockDB.On("CleanupFailedUploadsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("CleanupSoftDeletedAccountsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("PurgeSoftDeletedMailboxesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("CleanupOldRedirectsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("CleanupOldHealthStatusesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, mock.Anything).Return(db.DeliveryLogMaintenance{}, nil).Once()
+	mockDB.On("CleanupOldAuditEntriesWithRetry", ctx, auditLogRetention).Return(int64(3), nil).Once()
+	mockDB.On("GetUserScopedObjectsForCleanupWithRetry", ctx, mock.Anything, mock.Anything).Return([]db.UserScopedObjectForCleanup{}, nil).Once()
+	mockDB.On("GetUnusedFTSHashesWithRetry", ctx, mock.Anything).Return([]string{}, nil).Once()
+	mockDB.On("GetDanglingAccountsForFinalDeletionWithRetry", ctx, mock.Anything).Return([]int64{}, nil).Once()
+
+	err := worker.runOnce(ctx)
+
+	assert.NoError(t, err)
+	mockDB.AssertExpectations(t)
+}
+
+func TestCleanupWorker_RunOnce_DeliveryLogRetention(t *testing.T) {
+	mockDB := new(mockDatabase)
+	mockCache := new(mockCache)
+	ctx := context.Background()
+
+	deliveryLogRetention := 30 * 24 * time.Hour
+
+	worker := &CleanupWorker{
+		rdb:                  mockDB,
+		s3:                   &mockS3{healthy: true},
+		cache:                mockCache,
+		deliveryLogRetention: deliveryLogRetention,
+	}
+
+	mockDB.On("AcquireCleanupLockWithRetry", ctx).Return(true, nil).Once()
+	mockDB.On("ReleaseCleanupLockWithRetry", ctx).Return(nil).Once()
+	mockDB.On("CleanupFailedUploadsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("CleanupSoftDeletedAccountsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("PurgeSoftDeletedMailboxesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("CleanupOldVacationResponsesWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("CleanupOldRedirectsWithRetry", ctx, mock.Anything).Return(int64(0), nil).Once()
+	mockDB.On("MaintainDeliveryLogWithRetry", ctx, deliveryLogRetention).Return(db.DeliveryLogMaintenance{Created: 1, Dropped: 1}, nil).Once()
+	mockDB.On("GetUserScopedObjectsForCleanupWithRetry", ctx, mock.Anything, mock.Anything).Return([]db.UserScopedObjectForCleanup{}, nil).Once()
+	mockDB.On("GetUnusedFTSHashesWithRetry", ctx, mock.Anything).Return([]string{}, nil).Once()
+	mockDB.On("GetDanglingAccountsForFinalDeletionWithRetry", ctx, mock.Anything).Return([]int64{}, nil).Once()
+
+	err := worker.runOnce(ctx)
+
+	assert.NoError(t, err)
+	mockDB.AssertExpectations(t)
+}
@@ -102,6 +102,11 @@ type ConnectionTracker struct {
 	kickSessions   map[int64][]chan struct{} // accountID -> channels to notify
 	kickSessionsMu sync.RWMutex
 
+	// Local proxy sessions by backend address (guarded by kickSessionsMu).
+	// Used for least-connections balancing and for kicking sessions off a
+	// draining backend.
+	backendSessions map[string]map[chan struct{}]int64 // backend -> kick channel -> accountID
+
 	// Cache invalidation (optional, for proxies)
 	lookupCache LookupCacheInvalidator // Interface for invalidating auth/routing cache on kick
 
@@ -155,6 +160,7 @@ func NewConnectionTracker(name, serverName, hostname string, instanceID string,
 		maxEventQueueSize:          maxEventQueueSize,
 		snapshotOnly:               snapshotOnly,
 		kickSessions:               make(map[int64][]chan struct{}),
+		backendSessions:            make(map[string]map[chan struct{}]int64),
 		broadcastQueue:             make([]ConnectionEvent, 0, 100),
 		instanceLastSeen:           make(map[string]time.Time),
 		stopBroadcast:              make(chan struct{}),
@@ -569,6 +575,13 @@ func (ct *ConnectionTracker) KickUser(accountID int64, protocol string) error {
 // RegisterSession registers a session for kick notifications
 // Returns a channel that will be closed when the user should be kicked
 func (ct *ConnectionTracker) RegisterSession(accountID int64) <-chan struct{} {
+	return ct.RegisterBackendSession(accountID, "")
+}
+
+// RegisterBackendSession registers a proxy session connected to the given backend
+// for kick notifications. The session counts towards BackendSessionCount until it
+// is unregistered, and can be kicked by KickBackendSessions.
+func (ct *ConnectionTracker) RegisterBackendSession(accountID int64, backend string) <-chan struct{} {
 	if ct == nil {
 		// Return a channel that never closes
 		ch := make(chan struct{})
@@ -581,8 +594,14 @@ func (ct *ConnectionTracker) RegisterSession(accountID int64) <-chan struct{} {
 	defer ct.kickSessionsMu.Unlock()
 
 	ct.kickSessions[accountID] = append(ct.kickSessions[accountID], ch)
+	if backend != "" {
+		if ct.backendSessions[backend] == nil {
+			ct.backendSessions[backend] = make(map[chan struct{}]int64)
+		}
+		ct.backendSessions[backend][ch] = accountID
+	}
 
-	logger.Debug("Gossip tracker: Registered session", "name", ct.name, "account_id", accountID)
+	logger.Debug("Gossip tracker: Registered session", "name", ct.name, "account_id", accountID, "backend", backend)
 
 	return ch
 }
@@ -609,6 +628,76 @@ func (ct *ConnectionTracker) UnregisterSession(accountID int64, ch <-chan struct
 	if len(ct.kickSessions[accountID]) == 0 {
 		delete(ct.kickSessions, accountID)
 	}
+
+	for backend, sessions := range ct.backendSessions {
+		for c := range sessions {
+			if c == ch {
+				delete(sessions, c)
+				if len(sessions) == 0 {
+					delete(ct.backendSessions, backend)
+				}
+				return
+			}
+		}
+	}
+}
+
+// BackendSessionCount returns the number of local sessions registered against a backend.
+func (ct *ConnectionTracker) BackendSessionCount(backend string) int {
+	if ct == nil {
+		return 0
+	}
+
+	ct.kickSessionsMu.RLock()
+	defer ct.kickSessionsMu.RUnlock()
+	return len(ct.backendSessions[backend])
+}
+
+// KickBackendSessions disconnects up to limit local sessions connected to a backend
+// and returns how many were kicked. Unlike KickUser this is never gossiped: every
+// proxy node kicks its own sessions.
+func (ct *ConnectionTracker) KickBackendSessions(backend string, limit int) int {
+	if ct == nil || limit <= 0 {
+		return 0
+	}
+
+	ct.kickSessionsMu.Lock()
+	defer ct.kickSessionsMu.Unlock()
+
+	kicked := 0
+	sessions := ct.backendSessions[backend]
+	for ch, accountID := range sessions {
+		if kicked >= limit {
+			break
+		}
+		select {
+		case <-ch:
+			// Already closed
+		default:
+			close(ch)
+		}
+		delete(sessions, ch)
+
+		remaining := ct.kickSessions[accountID]
+		for i, c := range remaining {
+			if c == ch {
+				ct.kickSessions[accountID] = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+		if len(ct.kickSessions[accountID]) == 0 {
+			delete(ct.kickSessions, accountID)
+		}
+		kicked++
+	}
+	if len(sessions) == 0 {
+		delete(ct.backendSessions, backend)
+	}
+
+	if kicked > 0 {
+		logger.Info("Connection tracker: Kicked backend sessions", "name", ct.name, "backend", backend, "count", kicked)
+	}
+	return kicked
 }
 
 // queueEvent adds an event to the broadcast queue with bounded size.
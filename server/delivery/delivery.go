@@ -38,6 +38,7 @@ type DeliveryContext struct {
 	SieveExecutor SieveExecutor
 	Logger        Logger
 	OwnerResolver *resilient.OwnerResolver
+	Junk          bool // Content scanner verdict: file into Junk, bypassing Sieve
 }
 
 // Logger interface for logging delivery operations.
@@ -95,7 +96,7 @@ func (d *DeliveryContext) DeliverMessage(recipient RecipientInfo, messageBytes [
 		deliveredTo := recipient.Address.BaseAddress()
 		if helpers.IsRedirectLoop(helpers.HeaderGetter(messageEntity.Header.Map()), deliveredTo) {
 			result.ErrorMessage = "routing loop detected (Delivered-To)"
-			return result, fmt.Errorf("mail loop detected for %s", deliveredTo)
+			return result, fmt.Errorf("%w for %s", ErrMailLoop, deliveredTo)
 		}
 		// Add our Received: trace for this delivery hop, then Delivered-To on top.
 		received := helpers.BuildReceivedHeader("", d.Hostname, "HTTP", deliveredTo, idgen.New(), time.Now().Format(time.RFC1123Z))
@@ -193,9 +194,13 @@ func (d *DeliveryContext) DeliverMessage(recipient RecipientInfo, messageBytes [
 		// Use explicit target mailbox (bypasses Sieve - for migrations)
 		mailboxName = recipient.TargetMailbox
 		discarded = false
+	} else if d.Junk {
+		// The content scanner classified the message as spam
+		mailboxName = JunkMailbox(d.Ctx, d.RDB, recipient.AccountID)
 	} else {
 		// Execute Sieve scripts
-		mailboxName, discarded, sieveFlags, err = d.SieveExecutor.ExecuteSieve(
+		var rewritten []byte
+		mailboxName, discarded, sieveFlags, rewritten, err = d.SieveExecutor.ExecuteSieve(
 			d.Ctx,
 			recipient,
 			messageEntity,
@@ -207,6 +212,24 @@ func (d *DeliveryContext) DeliverMessage(recipient RecipientInfo, messageBytes [
 			return result, err
 		}
 
+		// The script replaced the message (RFC 5703 replace/enclose); the new
+		// body is already staged, so re-derive everything stored with it.
+		if rewritten != nil {
+			messageBytes = rewritten
+			contentHash = helpers.HashContent(messageBytes)
+			if messageEntity, err = message.Read(bytes.NewReader(messageBytes)); err != nil {
+				result.ErrorMessage = fmt.Sprintf("Invalid rewritten message: %v", err)
+				return result, err
+			}
+			mailHeader = mail.Header{Header: messageEntity.Header}
+			subject, _ = mailHeader.Subject()
+			recipients = helpers.ExtractRecipients(messageEntity.Header)
+			if text, _ := helpers.ExtractPlaintextBody(messageEntity); text != nil {
+				plaintextBody = text
+			}
+			bodyStructureVal = imapserver.ExtractBodyStructure(bytes.NewReader(messageBytes))
+		}
+
 		if discarded {
 			result.Discarded = true
 			result.Success = true
@@ -341,7 +364,7 @@ func (d *DeliveryContext) LookupRecipient(ctx context.Context, recipient string)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("recipient not found: %s", recipient)
+			return nil, fmt.Errorf("%w: %s", ErrRecipientNotFound, recipient)
 		}
 		return nil, fmt.Errorf("database error: %w", err)
 	}
@@ -429,6 +452,31 @@ func (d *DeliveryContext) resolveOwnedTarget(ctx context.Context, recipient Reci
 	return mailbox, destAccountID, destS3Domain, destS3Localpart, nil
 }
 
+// JunkMailbox returns the name of the account's \Junk mailbox, or "Junk" if
+// it has none. Delivery to a missing mailbox falls back to INBOX.
+func JunkMailbox(ctx context.Context, rdb *resilient.ResilientDatabase, accountID int64) string {
+	name, err := rdb.GetMailboxNameBySpecialUseWithRetry(ctx, accountID, string(imap.MailboxAttrJunk))
+	if err != nil {
+		return consts.MailboxJunk
+	}
+	return name
+}
+
+// stageLocally writes messageBytes to the uploader's staging area for the
+// account unless a file for the same content is already there.
+func (d *DeliveryContext) stageLocally(accountID int64, messageBytes []byte) error {
+	contentHash := helpers.HashContent(messageBytes)
+	if _, err := os.Stat(d.Uploader.FilePath(contentHash, accountID)); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check file existence: %w", err)
+	}
+	if _, err := d.Uploader.StoreLocally(contentHash, accountID, messageBytes); err != nil {
+		return fmt.Errorf("failed to save message to disk: %w", err)
+	}
+	return nil
+}
+
 // SaveMessageToMailbox saves a message to a specific mailbox (helper for Sieve :copy).
 // flags carries any keywords/flags set by the Sieve script (imap4flags, RFC 5232).
 func (d *DeliveryContext) SaveMessageToMailbox(ctx context.Context, recipient RecipientInfo, mailboxName string, messageBytes []byte, messageEntity *message.Entity, plaintextBody *string, flags []imap.Flag) error {
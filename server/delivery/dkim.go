@@ -0,0 +1,249 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	soralogger "github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/dkim"
+)
+
+// dkimKeyCacheTTL bounds how long a domain's key (or its absence) is cached,
+// so relay deliveries do not query the database for every message and key
+// changes still take effect within minutes.
+const dkimKeyCacheTTL = 5 * time.Minute
+
+// dkimLookupTimeout bounds the key lookup for one message.
+const dkimLookupTimeout = 5 * time.Second
+
+// ErrInvalidDKIMKey is returned for DKIM keys that cannot be stored.
+var ErrInvalidDKIMKey = errors.New("invalid DKIM key")
+
+// MessageSigner signs outgoing messages before they are handed to the relay.
+type MessageSigner interface {
+	Sign(from string, message []byte) ([]byte, error)
+}
+
+// ARCSealer adds an ARC set to messages forwarded on behalf of domain.
+type ARCSealer interface {
+	Seal(domain string, message []byte) ([]byte, error)
+}
+
+// DKIMKeyStore looks up a domain's active DKIM key.
+type DKIMKeyStore interface {
+	GetActiveDKIMKeyWithRetry(ctx context.Context, domain string) (*db.DKIMKey, error)
+}
+
+// DKIMSigner signs and seals messages with the per-domain keys stored in the
+// database. Domains without an active key are left unsigned. A nil
+// *DKIMSigner returns messages unchanged.
+type DKIMSigner struct {
+	store DKIMKeyStore
+	now   func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]cachedDKIMKey
+}
+
+type cachedDKIMKey struct {
+	key     *dkim.Key // nil: the domain has no usable key
+	expires time.Time
+}
+
+// NewDKIMSigner creates a signer using the keys in store.
+func NewDKIMSigner(store DKIMKeyStore) *DKIMSigner {
+	return &DKIMSigner{store: store, now: time.Now, keys: make(map[string]cachedDKIMKey)}
+}
+
+// Sign returns message with a DKIM signature for the domain of its From
+// header, or else of the envelope sender (e.g. the SRS domain of a redirect).
+func (s *DKIMSigner) Sign(from string, message []byte) ([]byte, error) {
+	if s == nil {
+		return message, nil
+	}
+	for _, domain := range []string{headerFromDomain(message), addressDomain(from)} {
+		if domain == "" {
+			continue
+		}
+		key, err := s.key(domain)
+		if err != nil {
+			return message, err
+		}
+		if key != nil {
+			return dkim.Sign(message, *key)
+		}
+	}
+	return message, nil
+}
+
+// Seal returns message with an ARC set for domain. Messages are returned
+// unchanged when domain has no key, when there are no upstream
+// Authentication-Results to preserve, or when the existing chain has failed.
+func (s *DKIMSigner) Seal(domain string, message []byte) ([]byte, error) {
+	if s == nil || domain == "" {
+		return message, nil
+	}
+	key, err := s.key(domain)
+	if err != nil || key == nil {
+		return message, err
+	}
+	sealed, err := dkim.Seal(message, *key, s.now())
+	if errors.Is(err, dkim.ErrNoAuthResults) || errors.Is(err, dkim.ErrARCChainFailed) {
+		return message, nil
+	}
+	if err != nil {
+		return message, err
+	}
+	return sealed, nil
+}
+
+// key returns the cached active key of domain, or nil if it has none.
+func (s *DKIMSigner) key(domain string) (*dkim.Key, error) {
+	domain = strings.ToLower(domain)
+	now := s.now()
+	s.mu.Lock()
+	cached, ok := s.keys[domain]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dkimLookupTimeout)
+	defer cancel()
+	var key *dkim.Key
+	stored, err := s.store.GetActiveDKIMKeyWithRetry(ctx, domain)
+	switch {
+	case errors.Is(err, consts.ErrDBNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("failed to look up DKIM key for %s: %w", domain, err)
+	default:
+		signer, err := dkim.ParsePrivateKey(stored.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DKIM key %s for %s: %w", stored.Selector, domain, err)
+		}
+		key = &dkim.Key{Domain: stored.Domain, Selector: stored.Selector, Signer: signer}
+	}
+
+	s.mu.Lock()
+	s.keys[domain] = cachedDKIMKey{key: key, expires: now.Add(dkimKeyCacheTTL)}
+	s.mu.Unlock()
+	return key, nil
+}
+
+// NewDKIMKey prepares a key for storage: the PEM privateKey is imported when
+// given, otherwise a new key of the algorithm (and RSA bits) is generated.
+// The public key is derived from the private key.
+func NewDKIMKey(domain, selector, algorithm string, bits int, privateKey string, active bool) (*db.DKIMKey, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" || strings.ContainsAny(domain, "@ ") {
+		return nil, fmt.Errorf("%w: invalid domain %q", ErrInvalidDKIMKey, domain)
+	}
+	if !validSelector(selector) {
+		return nil, fmt.Errorf("%w: selector must be a DNS label of letters, digits and hyphens", ErrInvalidDKIMKey)
+	}
+
+	if privateKey == "" {
+		if algorithm == "" {
+			algorithm = dkim.AlgorithmRSA
+		}
+		generated, _, err := dkim.GenerateKey(algorithm, bits)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidDKIMKey, err)
+		}
+		privateKey = generated
+	}
+	signer, err := dkim.ParsePrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDKIMKey, err)
+	}
+	keyAlgorithm, err := dkim.Algorithm(signer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDKIMKey, err)
+	}
+	if algorithm != "" && algorithm != keyAlgorithm {
+		return nil, fmt.Errorf("%w: private key is %s, not %s", ErrInvalidDKIMKey, keyAlgorithm, algorithm)
+	}
+	record, err := dkim.DNSRecord(signer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDKIMKey, err)
+	}
+
+	return &db.DKIMKey{
+		Domain:     domain,
+		Selector:   selector,
+		Algorithm:  keyAlgorithm,
+		PrivateKey: privateKey,
+		PublicKey:  record,
+		Active:     active,
+	}, nil
+}
+
+// validSelector reports whether selector is a single DNS label.
+func validSelector(selector string) bool {
+	if selector == "" || len(selector) > 63 || selector[0] == '-' || selector[len(selector)-1] == '-' {
+		return false
+	}
+	for _, c := range selector {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// headerFromDomain returns the domain of the first From header address.
+func headerFromDomain(message []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(message))
+	if err != nil {
+		return ""
+	}
+	addrs, err := msg.Header.AddressList("From")
+	if err != nil || len(addrs) == 0 {
+		return ""
+	}
+	return addressDomain(addrs[0].Address)
+}
+
+// addressDomain returns the lowercased domain of an address.
+func addressDomain(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(address[at+1:])
+}
+
+// SealRedirect ARC-seals a redirected message for the forwarding domain. On
+// error the message is returned unchanged with the error.
+func SealRedirect(sealer ARCSealer, domain string, message []byte) ([]byte, error) {
+	if sealer == nil {
+		return message, nil
+	}
+	sealed, err := sealer.Seal(domain, message)
+	if err != nil {
+		return message, err
+	}
+	return sealed, nil
+}
+
+// signOutgoing signs a message for the relay, falling back to the unsigned
+// message if signing fails: a missing signature must not hold up delivery.
+func signOutgoing(signer MessageSigner, from string, message []byte) []byte {
+	if signer == nil {
+		return message
+	}
+	signed, err := signer.Sign(from, message)
+	if err != nil {
+		soralogger.Warn("Relay: Failed to DKIM-sign message - sending unsigned", "from", from, "error", err)
+		return message
+	}
+	return signed
+}
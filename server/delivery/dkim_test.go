@@ -0,0 +1,159 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/pkg/dkim"
+)
+
+// fakeDKIMKeyStore serves the active keys of a map and counts lookups.
+type fakeDKIMKeyStore struct {
+	keys    map[string]*db.DKIMKey
+	err     error
+	lookups int
+}
+
+func (f *fakeDKIMKeyStore) GetActiveDKIMKeyWithRetry(ctx context.Context, domain string) (*db.DKIMKey, error) {
+	f.lookups++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if key, ok := f.keys[domain]; ok {
+		return key, nil
+	}
+	return nil, consts.ErrDBNotFound
+}
+
+func newTestDKIMKey(t *testing.T, domain string) *db.DKIMKey {
+	t.Helper()
+	key, err := NewDKIMKey(domain, "sel", dkim.AlgorithmEd25519, 0, "", true)
+	if err != nil {
+		t.Fatalf("NewDKIMKey: %v", err)
+	}
+	return key
+}
+
+func dkimDomain(message []byte) string {
+	if !bytes.HasPrefix(message, []byte("DKIM-Signature:")) {
+		return ""
+	}
+	header := string(message[:bytes.Index(message, []byte("\r\n\r\n"))])
+	for _, tag := range strings.Split(header, ";") {
+		if k, v, ok := strings.Cut(tag, "="); ok && strings.TrimSpace(k) == "d" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func TestDKIMSignerSign(t *testing.T) {
+	store := &fakeDKIMKeyStore{keys: map[string]*db.DKIMKey{
+		"example.com": newTestDKIMKey(t, "example.com"),
+		"srs.example": newTestDKIMKey(t, "srs.example"),
+	}}
+	signer := NewDKIMSigner(store)
+
+	message := []byte("From: Alice <alice@Example.com>\r\nTo: bob@example.net\r\nSubject: hi\r\n\r\nbody\r\n")
+	signed, err := signer.Sign("alice@example.com", message)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if d := dkimDomain(signed); d != "example.com" {
+		t.Errorf("signed for %q, want example.com", d)
+	}
+
+	// A redirect keeps the original From; the SRS envelope domain signs
+	redirected := []byte("From: carol@elsewhere.org\r\nSubject: fwd\r\n\r\nbody\r\n")
+	signed, err = signer.Sign("SRS0=abcd=XY=elsewhere.org=carol@srs.example", redirected)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if d := dkimDomain(signed); d != "srs.example" {
+		t.Errorf("signed for %q, want srs.example", d)
+	}
+
+	unsigned := []byte("From: dave@nokey.example\r\n\r\nbody\r\n")
+	signed, err = signer.Sign("dave@nokey.example", unsigned)
+	if err != nil || !bytes.Equal(signed, unsigned) {
+		t.Errorf("message without key changed (err %v)", err)
+	}
+
+	// Both domains, with and without a key, are cached
+	lookups := store.lookups
+	signer.Sign("alice@example.com", message)
+	signer.Sign("dave@nokey.example", unsigned)
+	if store.lookups != lookups {
+		t.Errorf("cached keys looked up again: %d lookups, want %d", store.lookups, lookups)
+	}
+}
+
+func TestDKIMSignerSeal(t *testing.T) {
+	signer := NewDKIMSigner(&fakeDKIMKeyStore{keys: map[string]*db.DKIMKey{"example.com": newTestDKIMKey(t, "example.com")}})
+
+	message := []byte("Authentication-Results: mx.example.com; spf=pass smtp.mailfrom=example.org\r\nFrom: carol@example.org\r\n\r\nbody\r\n")
+	sealed, err := SealRedirect(signer, "example.com", message)
+	if err != nil {
+		t.Fatalf("SealRedirect: %v", err)
+	}
+	if !bytes.HasPrefix(sealed, []byte("ARC-Seal: i=1;")) {
+		t.Errorf("message not sealed: %.40q", sealed)
+	}
+
+	// Nothing to seal without upstream results or a key
+	noResults := []byte("From: carol@example.org\r\n\r\nbody\r\n")
+	if out, err := signer.Seal("example.com", noResults); err != nil || !bytes.Equal(out, noResults) {
+		t.Errorf("message without Authentication-Results changed (err %v)", err)
+	}
+	if out, err := signer.Seal("nokey.example", message); err != nil || !bytes.Equal(out, message) {
+		t.Errorf("message for domain without key changed (err %v)", err)
+	}
+
+	// A nil sealer or signer passes messages through
+	var nilSigner *DKIMSigner
+	if out, err := SealRedirect(nil, "example.com", message); err != nil || !bytes.Equal(out, message) {
+		t.Errorf("nil sealer changed message (err %v)", err)
+	}
+	if out, err := nilSigner.Sign("a@example.com", message); err != nil || !bytes.Equal(out, message) {
+		t.Errorf("nil signer changed message (err %v)", err)
+	}
+}
+
+func TestSignOutgoingFallsBackToUnsigned(t *testing.T) {
+	signer := NewDKIMSigner(&fakeDKIMKeyStore{err: errors.New("database unavailable")})
+	message := []byte("From: alice@example.com\r\n\r\nbody\r\n")
+	if out := signOutgoing(signer, "alice@example.com", message); !bytes.Equal(out, message) {
+		t.Error("message changed although signing failed")
+	}
+}
+
+func TestNewDKIMKey(t *testing.T) {
+	pemKey, record, err := dkim.GenerateKey(dkim.AlgorithmRSA, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewDKIMKey("Example.COM", "mail", "", 0, pemKey, false)
+	if err != nil {
+		t.Fatalf("NewDKIMKey: %v", err)
+	}
+	if key.Domain != "example.com" || key.Algorithm != dkim.AlgorithmRSA || key.PublicKey != record {
+		t.Errorf("unexpected imported key: %+v", key)
+	}
+
+	for name, args := range map[string][]string{
+		"bad selector":       {"example.com", "a.b", "", ""},
+		"bad domain":         {"user@example.com", "mail", "", ""},
+		"bad algorithm":      {"example.com", "mail", "dsa", ""},
+		"algorithm mismatch": {"example.com", "mail", dkim.AlgorithmEd25519, pemKey},
+		"bad PEM":            {"example.com", "mail", "", "garbage"},
+	} {
+		if _, err := NewDKIMKey(args[0], args[1], args[2], 0, args[3], false); !errors.Is(err, ErrInvalidDKIMKey) {
+			t.Errorf("%s: err = %v, want ErrInvalidDKIMKey", name, err)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package delivery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/server/uploader"
+)
+
+// FailedRelay describes a relay queue message that will not be delivered.
+type FailedRelay struct {
+	ID        string
+	From      string // Envelope sender
+	To        string // Recipient the relay gave up on
+	Type      string // "redirect", "forward", "vacation", "notification" or "bounce"
+	QueuedAt  time.Time
+	Attempts  int
+	Errors    []string // Error history, oldest first
+	Permanent bool     // The relay rejected the message (5xx), rather than attempts running out
+}
+
+// enhancedStatus matches an RFC 3463 enhanced status code in a relay error.
+var enhancedStatus = regexp.MustCompile(`\b([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+// smtpReplyCode matches an SMTP reply code in a relay error.
+var smtpReplyCode = regexp.MustCompile(`\b[45]\d\d\b`)
+
+// queueErrorPrefix matches the "[timestamp] " and "PERMANENT: " prefixes the
+// relay queue adds to recorded errors.
+var queueErrorPrefix = regexp.MustCompile(`^\[[^\]]*\] (PERMANENT: )?`)
+
+// LastError returns the most recent delivery error without the queue's prefixes.
+func (f FailedRelay) LastError() string {
+	if len(f.Errors) == 0 {
+		return "unknown error"
+	}
+	return queueErrorPrefix.ReplaceAllString(f.Errors[len(f.Errors)-1], "")
+}
+
+// Status returns the RFC 3463 status of the failure: the relay's enhanced
+// code when it gave a permanent one, 5.4.7 (delivery time expired) when the
+// attempts ran out, and 5.0.0 otherwise.
+func (f FailedRelay) Status() string {
+	if m := enhancedStatus.FindString(f.LastError()); m != "" && m[0] == '5' {
+		return m
+	}
+	if !f.Permanent {
+		return "5.4.7"
+	}
+	return "5.0.0"
+}
+
+// Bouncer delivers delivery status notifications for failed relay messages to
+// the account that originated them. Notifications are stored directly in the
+// account's INBOX, bypassing Sieve, so they cannot be redirected or bounce in
+// turn.
+type Bouncer struct {
+	RDB          *resilient.ResilientDatabase
+	Uploader     *uploader.UploadWorker
+	Hostname     string
+	FTSRetention time.Duration
+	Logger       Logger
+	Types        []string // Message types to bounce
+}
+
+// Bounce notifies the originating account of a failed message. Messages of
+// other types, and messages with a null sender, are not bounced.
+func (b *Bouncer) Bounce(ctx context.Context, failed FailedRelay, original []byte) error {
+	if !b.bounces(failed.Type) {
+		return nil
+	}
+	// Never answer a null sender (RFC 5321 §4.5.5): it is a bounce or an
+	// auto-reply, and answering it risks a loop.
+	if failed.From == "" || failed.From == "<>" {
+		return nil
+	}
+
+	originator := DSNOriginator(failed, original)
+	deliveryCtx := &DeliveryContext{
+		Ctx:          ctx,
+		RDB:          b.RDB,
+		Uploader:     b.Uploader,
+		Hostname:     b.Hostname,
+		FTSRetention: b.FTSRetention,
+		MetricsLabel: "relay_dsn",
+		Logger:       b.Logger,
+	}
+	recipient, err := deliveryCtx.LookupRecipient(ctx, originator)
+	if err != nil {
+		return fmt.Errorf("originating account %s: %w", originator, err)
+	}
+	recipient.TargetMailbox = consts.MailboxInbox
+
+	dsn, err := BuildDSN(b.Hostname, originator, failed, original, time.Now())
+	if err != nil {
+		return err
+	}
+	if _, err := deliveryCtx.DeliverMessage(*recipient, dsn); err != nil {
+		return fmt.Errorf("failed to deliver DSN to %s: %w", originator, err)
+	}
+	b.Logger.Log("Relay: Delivered DSN for failed %s message %s to %s", failed.Type, failed.ID, originator)
+	return nil
+}
+
+func (b *Bouncer) bounces(messageType string) bool {
+	for _, t := range b.Types {
+		if t == messageType {
+			return true
+		}
+	}
+	return false
+}
+
+// DSNOriginator returns the address of the account that originated a relayed
+// message: for redirects the account whose Sieve script redirected it (the
+// topmost Delivered-To), otherwise the envelope sender.
+func DSNOriginator(failed FailedRelay, original []byte) string {
+	if failed.Type == "redirect" {
+		header, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(original))).ReadMIMEHeader()
+		if deliveredTo := strings.TrimSpace(header.Get(helpers.DeliveredToHeader)); deliveredTo != "" {
+			return deliveredTo
+		}
+	}
+	return failed.From
+}
+
+// BuildDSN builds a multipart/report delivery status notification (RFC 3464)
+// for a failed message, addressed to recipient. The original message is
+// returned as headers only (text/rfc822-headers).
+func BuildDSN(hostname, recipient string, failed FailedRelay, original []byte, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: Mail Delivery System <MAILER-DAEMON@%s>\r\n", hostname)
+	fmt.Fprintf(&msg, "To: <%s>\r\n", recipient)
+	msg.WriteString("Subject: Undelivered Mail Returned to Sender\r\n")
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%d.dsn.%s@%s>\r\n", now.UnixNano(), failed.ID, hostname)
+	msg.WriteString("Auto-Submitted: auto-replied\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/report; report-type=delivery-status; boundary=%q\r\n\r\n", mw.Boundary())
+
+	// Human-readable explanation
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"text/plain; charset=utf-8"},
+		"Content-Description": {"Notification"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	reason := fmt.Sprintf("The message could not be delivered after %d attempts.", failed.Attempts)
+	if failed.Permanent {
+		reason = "The receiving server rejected the message."
+	}
+	fmt.Fprintf(part, "This is the mail system at %s.\r\n\r\n"+
+		"Your %s message to <%s> could not be delivered. %s\r\n\r\n"+
+		"    <%s>: %s\r\n",
+		hostname, failed.Type, failed.To, reason, failed.To, failed.LastError())
+
+	// Machine-readable status
+	part, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"message/delivery-status"},
+		"Content-Description": {"Delivery report"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(part, "Reporting-MTA: dns; %s\r\n", hostname)
+	fmt.Fprintf(part, "X-Sora-Queue-ID: %s\r\n", failed.ID)
+	if !failed.QueuedAt.IsZero() {
+		fmt.Fprintf(part, "Arrival-Date: %s\r\n", failed.QueuedAt.Format(time.RFC1123Z))
+	}
+	part.Write([]byte("\r\n"))
+	fmt.Fprintf(part, "Final-Recipient: rfc822; %s\r\n", failed.To)
+	part.Write([]byte("Action: failed\r\n"))
+	fmt.Fprintf(part, "Status: %s\r\n", failed.Status())
+	diagnostic := strings.Join(strings.Fields(failed.LastError()), " ")
+	if smtpReplyCode.MatchString(diagnostic) {
+		fmt.Fprintf(part, "Diagnostic-Code: smtp; %s\r\n", diagnostic)
+	} else {
+		fmt.Fprintf(part, "Diagnostic-Code: X-Sora; %s\r\n", diagnostic)
+	}
+	fmt.Fprintf(part, "Last-Attempt-Date: %s\r\n", now.Format(time.RFC1123Z))
+
+	// Headers of the undelivered message
+	part, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"text/rfc822-headers"},
+		"Content-Description": {"Undelivered Message Headers"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	part.Write(messageHeader(original))
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// messageHeader returns the header section of a message with CRLF line endings.
+func messageHeader(message []byte) []byte {
+	header := message
+	if i := bytes.Index(message, []byte("\r\n\r\n")); i >= 0 {
+		header = message[:i+2]
+	} else if i := bytes.Index(message, []byte("\n\n")); i >= 0 {
+		header = message[:i+1]
+	}
+	header = bytes.ReplaceAll(header, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(header, []byte("\n"), []byte("\r\n"))
+}
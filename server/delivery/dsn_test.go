@@ -0,0 +1,129 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+const dsnOriginal = "Delivered-To: alice@example.com\r\n" +
+	"Delivered-To: list@example.com\r\n" +
+	"From: carol@example.org\r\n" +
+	"To: alice@example.com\r\n" +
+	"Subject: Hello\r\n" +
+	"\r\n" +
+	"secret body\r\n"
+
+func TestBuildDSN(t *testing.T) {
+	failed := FailedRelay{
+		ID:        "abc-123",
+		From:      "SRS0=hash=tt=example.org=carol@srs.example.com",
+		To:        "bob@example.net",
+		Type:      "redirect",
+		QueuedAt:  time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		Attempts:  1,
+		Errors:    []string{"[2026-10-01T12:00:01Z] PERMANENT: SMTP error 550: 5.1.1 Mailbox unavailable"},
+		Permanent: true,
+	}
+	dsn, err := BuildDSN("mx.example.com", "alice@example.com", failed, []byte(dsnOriginal), time.Date(2026, 10, 1, 12, 0, 2, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("BuildDSN: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(dsn))
+	if err != nil {
+		t.Fatalf("DSN is not a valid message: %v", err)
+	}
+	if got := msg.Header.Get("To"); got != "<alice@example.com>" {
+		t.Errorf("To = %q", got)
+	}
+	if got := msg.Header.Get("Auto-Submitted"); got != "auto-replied" {
+		t.Errorf("Auto-Submitted = %q", got)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || params["report-type"] != "delivery-status" {
+		t.Fatalf("Content-Type = %q", msg.Header.Get("Content-Type"))
+	}
+
+	var types, bodies []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type"))
+		bodies = append(bodies, string(body))
+	}
+	if len(types) != 3 || types[1] != "message/delivery-status" || types[2] != "text/rfc822-headers" {
+		t.Fatalf("unexpected parts: %v", types)
+	}
+	for _, want := range []string{
+		"Reporting-MTA: dns; mx.example.com\r\n",
+		"Final-Recipient: rfc822; bob@example.net\r\n",
+		"Action: failed\r\n",
+		"Status: 5.1.1\r\n",
+		"Diagnostic-Code: smtp; SMTP error 550: 5.1.1 Mailbox unavailable\r\n",
+	} {
+		if !strings.Contains(bodies[1], want) {
+			t.Errorf("delivery status lacks %q:\n%s", want, bodies[1])
+		}
+	}
+	if !strings.Contains(bodies[2], "Subject: Hello\r\n") || strings.Contains(bodies[2], "secret body") {
+		t.Errorf("returned headers should include the header only:\n%s", bodies[2])
+	}
+}
+
+func TestFailedRelayStatus(t *testing.T) {
+	tests := []struct {
+		failed FailedRelay
+		want   string
+	}{
+		{FailedRelay{Errors: []string{"[t] connection refused"}}, "5.4.7"},
+		{FailedRelay{Errors: []string{"[t] SMTP error 451: 4.7.1 try later"}}, "5.4.7"},
+		{FailedRelay{Errors: []string{"[t] PERMANENT: HTTP relay returned error status: 400"}, Permanent: true}, "5.0.0"},
+		{FailedRelay{Errors: []string{"[t] PERMANENT: SMTP error 554: 5.7.1 rejected"}, Permanent: true}, "5.7.1"},
+	}
+	for _, tt := range tests {
+		if got := tt.failed.Status(); got != tt.want {
+			t.Errorf("Status(%q) = %q, want %q", tt.failed.Errors, got, tt.want)
+		}
+	}
+}
+
+func TestDSNOriginator(t *testing.T) {
+	redirect := FailedRelay{Type: "redirect", From: "carol@example.org"}
+	if got := DSNOriginator(redirect, []byte(dsnOriginal)); got != "alice@example.com" {
+		t.Errorf("redirect originator = %q, want the topmost Delivered-To", got)
+	}
+	vacation := FailedRelay{Type: "vacation", From: "alice@example.com"}
+	if got := DSNOriginator(vacation, []byte(dsnOriginal)); got != "alice@example.com" {
+		t.Errorf("vacation originator = %q, want the envelope sender", got)
+	}
+}
+
+// TestBouncerSkips tests that messages of unlisted types and messages with a
+// null sender are not bounced. The bouncer has no database, so any attempt to
+// deliver would fail.
+func TestBouncerSkips(t *testing.T) {
+	b := &Bouncer{Hostname: "mx.example.com", Types: []string{"redirect", "vacation"}}
+	for _, failed := range []FailedRelay{
+		{Type: "notification", From: "postmaster@example.com", To: "alice@example.com"},
+		{Type: "bounce", From: "", To: "carol@example.org"},
+		{Type: "redirect", From: "<>", To: "bob@example.net"},
+	} {
+		if err := b.Bounce(context.Background(), failed, []byte(dsnOriginal)); err != nil {
+			t.Errorf("Bounce(%+v) = %v, want skipped", failed, err)
+		}
+	}
+}
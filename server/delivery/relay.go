@@ -13,6 +13,7 @@ import (
 	soralogger "github.com/migadu/sora/logger"
 	"github.com/migadu/sora/pkg/circuitbreaker"
 	"github.com/migadu/sora/pkg/metrics"
+	"github.com/migadu/sora/server"
 )
 
 // RelayError wraps an error with information about whether it's permanent or temporary.
@@ -75,6 +76,7 @@ type SMTPRelayHandler struct {
 	MetricsLabel   string
 	Logger         Logger
 	CircuitBreaker *circuitbreaker.CircuitBreaker // Circuit breaker for resilience
+	Signer         MessageSigner                  // Optional: DKIM-signs messages before submission
 }
 
 // GetCircuitBreaker returns the circuit breaker for health monitoring
@@ -87,6 +89,7 @@ func (r *SMTPRelayHandler) SendToExternalRelay(from string, to string, messageBy
 	if r.SMTPHost == "" {
 		return fmt.Errorf("SMTP relay host not configured")
 	}
+	messageBytes = signOutgoing(r.Signer, from, messageBytes)
 
 	// Wrap delivery in circuit breaker if available
 	if r.CircuitBreaker != nil {
@@ -175,7 +178,22 @@ func (r *SMTPRelayHandler) sendToSMTPRelay(from string, to string, messageBytes
 		}
 	}
 
-	if relayErr = c.Mail(from, nil); relayErr != nil {
+	// UTF-8 addresses need SMTPUTF8; a relay without it can never take the
+	// message (RFC 6531 §3.2), so that is a permanent failure
+	var mailOpts *smtp.MailOptions
+	if !server.IsASCII(from) || !server.IsASCII(to) {
+		// NOOP completes EHLO first, so a missing extension is not a failed greeting
+		if relayErr = c.Noop(); relayErr != nil {
+			return &RelayError{Err: relayErr, Permanent: IsPermanentError(relayErr)}
+		}
+		if ok, _ := c.Extension("SMTPUTF8"); !ok {
+			relayErr = errors.New("SMTP relay does not support SMTPUTF8 for UTF-8 addresses")
+			return &RelayError{Err: relayErr, Permanent: true}
+		}
+		mailOpts = &smtp.MailOptions{UTF8: true}
+	}
+
+	if relayErr = c.Mail(from, mailOpts); relayErr != nil {
 		// Classify SMTP error (5xx = permanent, 4xx = temporary)
 		return &RelayError{Err: relayErr, Permanent: IsPermanentError(relayErr)}
 	}
@@ -217,6 +235,7 @@ type HTTPRelayHandler struct {
 	MetricsLabel   string
 	Logger         Logger
 	CircuitBreaker *circuitbreaker.CircuitBreaker // Circuit breaker for resilience
+	Signer         MessageSigner                  // Optional: DKIM-signs messages before submission
 }
 
 // GetCircuitBreaker returns the circuit breaker for health monitoring
@@ -236,6 +255,7 @@ func (r *HTTPRelayHandler) SendToExternalRelay(from string, to string, messageBy
 	if r.HTTPURL == "" {
 		return fmt.Errorf("HTTP relay URL not configured")
 	}
+	messageBytes = signOutgoing(r.Signer, from, messageBytes)
 
 	// Wrap delivery in circuit breaker if available
 	if r.CircuitBreaker != nil {
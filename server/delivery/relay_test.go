@@ -1,8 +1,11 @@
 package delivery
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -638,3 +641,63 @@ func TestHTTPRelayHandler_ErrorClassification(t *testing.T) {
 		})
 	}
 }
+
+// utf8RelayBackend records the MAIL options of each transaction
+type utf8RelayBackend struct{ utf8 chan bool }
+
+func (b *utf8RelayBackend) NewSession(*smtp.Conn) (smtp.Session, error) {
+	return &utf8RelaySession{b}, nil
+}
+
+type utf8RelaySession struct{ b *utf8RelayBackend }
+
+func (s *utf8RelaySession) Mail(_ context.Context, _ string, opts *smtp.MailOptions) error {
+	s.b.utf8 <- opts != nil && opts.UTF8
+	return nil
+}
+func (s *utf8RelaySession) Rcpt(context.Context, string, *smtp.RcptOptions) error { return nil }
+func (s *utf8RelaySession) Data(_ context.Context, r io.Reader) error {
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+func (s *utf8RelaySession) Reset()        {}
+func (s *utf8RelaySession) Logout() error { return nil }
+
+func TestSMTPRelayHandlerSMTPUTF8(t *testing.T) {
+	startRelay := func(enableUTF8 bool) (string, chan bool) {
+		backend := &utf8RelayBackend{utf8: make(chan bool, 4)}
+		srv := smtp.NewServer(backend)
+		srv.Domain = "relay.example.com"
+		srv.EnableSMTPUTF8 = enableUTF8
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go srv.Serve(ln)
+		t.Cleanup(func() { srv.Close() })
+		return ln.Addr().String(), backend.utf8
+	}
+	message := []byte("Subject: hi\r\n\r\nhi\r\n")
+
+	addr, utf8 := startRelay(true)
+	relay := &SMTPRelayHandler{SMTPHost: addr}
+	if err := relay.SendToExternalRelay("sender@example.com", "bob@example.com", message); err != nil {
+		t.Fatalf("ASCII relay: %v", err)
+	}
+	if <-utf8 {
+		t.Error("SMTPUTF8 sent for ASCII addresses")
+	}
+	if err := relay.SendToExternalRelay("sender@example.com", "josé@example.com", message); err != nil {
+		t.Fatalf("UTF-8 relay: %v", err)
+	}
+	if !<-utf8 {
+		t.Error("SMTPUTF8 not sent for a UTF-8 recipient")
+	}
+
+	addr, _ = startRelay(false)
+	relay = &SMTPRelayHandler{SMTPHost: addr}
+	err := relay.SendToExternalRelay("josé@example.com", "bob@example.com", message)
+	if err == nil || !IsPermanentError(err) {
+		t.Errorf("UTF-8 sender via a relay without SMTPUTF8: %v, want a permanent error", err)
+	}
+}
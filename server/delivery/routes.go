@@ -0,0 +1,315 @@
+package delivery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/pkg/srs"
+	"github.com/migadu/sora/server"
+)
+
+// maxRouteDepth bounds how many routes one recipient may pass through
+// (a group containing a group on an aliased domain, ...).
+const maxRouteDepth = 8
+
+// maxRouteTargets bounds the targets of a single route.
+const maxRouteTargets = 1000
+
+var (
+	// ErrInvalidMailRoute is returned for a route that cannot be stored.
+	ErrInvalidMailRoute = errors.New("invalid mail route")
+	// ErrRecipientNotFound is returned when an address is neither a login
+	// credential nor routed.
+	ErrRecipientNotFound = errors.New("recipient not found")
+	// ErrRouteTooDeep is returned when resolving an address passes through
+	// more than maxRouteDepth routes.
+	ErrRouteTooDeep = errors.New("mail routes nested too deeply")
+	// ErrMailLoop is returned when a message has already been delivered to
+	// the recipient by a Sora redirect or forward.
+	ErrMailLoop = errors.New("mail loop detected")
+	// ErrForwardingUnavailable is returned when a route has external targets
+	// but no relay queue is configured.
+	ErrForwardingUnavailable = errors.New("forwarding to external targets requires a relay queue")
+)
+
+// NewMailRoute validates and normalizes a mail route. Groups are keyed by a
+// full address; catch-alls and domain aliases by a domain, given as "domain"
+// or "@domain".
+func NewMailRoute(address, kind string, targets []string) (*db.MailRoute, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	route := &db.MailRoute{Kind: kind}
+
+	switch kind {
+	case db.MailRouteGroup:
+		addr, err := server.NewAddress(address)
+		if err != nil || addr.Detail() != "" {
+			return nil, fmt.Errorf("%w: invalid group address %q", ErrInvalidMailRoute, address)
+		}
+		route.Address, route.Domain = addr.FullAddress(), addr.Domain()
+	case db.MailRouteCatchAll, db.MailRouteDomainAlias:
+		domain, ok := validRouteDomain(address)
+		if !ok {
+			return nil, fmt.Errorf("%w: invalid domain %q", ErrInvalidMailRoute, address)
+		}
+		route.Address, route.Domain = "@"+domain, domain
+	default:
+		return nil, fmt.Errorf("%w: kind must be %s, %s or %s", ErrInvalidMailRoute, db.MailRouteGroup, db.MailRouteCatchAll, db.MailRouteDomainAlias)
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: at least one target is required", ErrInvalidMailRoute)
+	}
+	if len(targets) > maxRouteTargets {
+		return nil, fmt.Errorf("%w: more than %d targets", ErrInvalidMailRoute, maxRouteTargets)
+	}
+
+	if kind == db.MailRouteDomainAlias {
+		if len(targets) != 1 {
+			return nil, fmt.Errorf("%w: a domain alias has exactly one target domain", ErrInvalidMailRoute)
+		}
+		domain, ok := validRouteDomain(targets[0])
+		if !ok || domain == route.Domain {
+			return nil, fmt.Errorf("%w: invalid target domain %q", ErrInvalidMailRoute, targets[0])
+		}
+		route.Targets = []string{domain}
+		return route, nil
+	}
+
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		addr, err := server.NewAddress(target)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid target %q", ErrInvalidMailRoute, target)
+		}
+		t := addr.FullAddress()
+		if t == route.Address {
+			return nil, fmt.Errorf("%w: %s cannot be its own target", ErrInvalidMailRoute, t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			route.Targets = append(route.Targets, t)
+		}
+	}
+	return route, nil
+}
+
+// validRouteDomain returns the lowercase domain of "domain" or "@domain".
+func validRouteDomain(s string) (string, bool) {
+	domain := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "@")
+	if domain == "" {
+		return "", false
+	}
+	if _, err := server.NewAddress("postmaster@" + domain); err != nil {
+		return "", false
+	}
+	return domain, true
+}
+
+// RouteStore looks up accounts and mail routes. It is implemented by
+// resilient.ResilientDatabase.
+type RouteStore interface {
+	GetActiveAccountIDByAddressWithRetry(ctx context.Context, address string) (int64, error)
+	GetMailRouteWithRetry(ctx context.Context, address string) (*db.MailRoute, error)
+}
+
+// RouteAccount is a local account a recipient resolved to.
+type RouteAccount struct {
+	AccountID int64
+	Address   string // Credential address that matched
+}
+
+// Resolution is the set of destinations of a recipient.
+type Resolution struct {
+	Accounts []RouteAccount // Local accounts, each at most once
+	Forwards []string       // External addresses to relay to
+}
+
+// Single returns the account of a recipient that resolved to exactly one
+// local account and nothing else; such a recipient is delivered like the
+// account's own address.
+func (r *Resolution) Single() (RouteAccount, bool) {
+	if len(r.Accounts) == 1 && len(r.Forwards) == 0 {
+		return r.Accounts[0], true
+	}
+	return RouteAccount{}, false
+}
+
+// ResolveRecipient resolves an address to local accounts and external
+// forwarding targets. In order, an address is a login credential, a group, an
+// address on an aliased domain, or an address covered by its domain's
+// catch-all. A target that resolves to none of these is an external address;
+// the recipient itself is ErrRecipientNotFound.
+func ResolveRecipient(ctx context.Context, store RouteStore, address string) (*Resolution, error) {
+	r := &routeResolver{
+		store:    store,
+		seen:     make(map[string]bool),
+		accounts: make(map[int64]bool),
+	}
+	if err := r.resolve(ctx, strings.ToLower(address), 0); err != nil {
+		return nil, err
+	}
+	if len(r.result.Accounts) == 0 && len(r.result.Forwards) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRecipientNotFound, address)
+	}
+	return &r.result, nil
+}
+
+type routeResolver struct {
+	store    RouteStore
+	seen     map[string]bool
+	accounts map[int64]bool
+	result   Resolution
+}
+
+func (r *routeResolver) resolve(ctx context.Context, address string, depth int) error {
+	if depth > maxRouteDepth {
+		return fmt.Errorf("%w: %s", ErrRouteTooDeep, address)
+	}
+	// Each address is resolved once, which also breaks routing cycles
+	if r.seen[address] {
+		return nil
+	}
+	r.seen[address] = true
+
+	accountID, err := r.store.GetActiveAccountIDByAddressWithRetry(ctx, address)
+	if err == nil {
+		if !r.accounts[accountID] {
+			r.accounts[accountID] = true
+			r.result.Accounts = append(r.result.Accounts, RouteAccount{AccountID: accountID, Address: address})
+		}
+		return nil
+	}
+	if !errors.Is(err, consts.ErrUserNotFound) {
+		return err
+	}
+
+	route, err := r.store.GetMailRouteWithRetry(ctx, address)
+	if errors.Is(err, consts.ErrDBNotFound) {
+		localPart, domain, _ := strings.Cut(address, "@")
+		route, err = r.store.GetMailRouteWithRetry(ctx, "@"+domain)
+		if err == nil && route.Kind == db.MailRouteDomainAlias {
+			return r.resolve(ctx, localPart+"@"+route.Targets[0], depth+1)
+		}
+	}
+	if errors.Is(err, consts.ErrDBNotFound) {
+		if depth == 0 {
+			return fmt.Errorf("%w: %s", ErrRecipientNotFound, address)
+		}
+		r.result.Forwards = append(r.result.Forwards, address)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, target := range route.Targets {
+		if err := r.resolve(ctx, target, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Forwarder relays copies of routed messages to external targets.
+type Forwarder struct {
+	RelayQueue RelayQueue
+	SRS        *srs.Rewriter // Optional: rewrites the envelope sender
+	ARCSealer  ARCSealer     // Optional: ARC-seals forwarded copies
+	MaxHops    int           // Redirects and forwards a message may already have passed; 0 means the default
+	Logger     Logger
+}
+
+// Forward queues a copy of the message for each target. The copies carry a
+// Delivered-To for the routed address and an incremented X-Sora-Loop count,
+// so a copy that comes back is recognized as a loop, the same way as Sieve
+// redirects.
+func (f *Forwarder) Forward(route, sender string, targets []string, message []byte) error {
+	if len(targets) == 0 {
+		return nil
+	}
+	if f == nil || f.RelayQueue == nil {
+		return ErrForwardingUnavailable
+	}
+
+	header, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(message))).ReadMIMEHeader()
+	headers := helpers.HeaderGetter(header)
+	if helpers.IsRedirectLoop(headers, route) {
+		f.Logger.Log("Not forwarding message for %s: it has already passed this route", route)
+		return nil
+	}
+	maxHops := f.MaxHops
+	if maxHops <= 0 {
+		maxHops = helpers.DefaultMaxRedirectHops
+	}
+	hops := helpers.RedirectHopCount(headers)
+	if hops >= maxHops {
+		f.Logger.Log("Not forwarding message for %s: already redirected %d times", route, hops)
+		return nil
+	}
+
+	relayBytes := helpers.PrependHeaderLine(message, helpers.RedirectLoopHeader, strconv.Itoa(hops+1))
+	relayBytes = helpers.PrependHeaderLine(relayBytes, helpers.DeliveredToHeader, route)
+	if _, domain, ok := strings.Cut(route, "@"); ok {
+		sealed, err := SealRedirect(f.ARCSealer, domain, relayBytes)
+		if err != nil {
+			f.Logger.Log("Failed to ARC-seal forwarded message: %v", err)
+		}
+		relayBytes = sealed
+	}
+
+	from := sender
+	if sender != "" {
+		rewritten, err := RedirectSender(f.SRS, sender)
+		if err != nil {
+			f.Logger.Log("Failed to rewrite forwarding sender %s: %v", sender, err)
+		}
+		from = rewritten
+	}
+
+	for _, target := range targets {
+		if err := f.RelayQueue.Enqueue(from, target, "forward", relayBytes); err != nil {
+			return fmt.Errorf("failed to queue forward to %s: %w", target, err)
+		}
+		f.Logger.Log("Queued message for %s to %s", route, target)
+	}
+	return nil
+}
+
+// DeliverToRoute delivers a message for a routed recipient: a copy to each
+// local account, each through its own Sieve script, then to the external
+// targets through the forwarder. Copies a previous attempt already stored are
+// not an error, so a failed delivery can be retried as a whole.
+func (d *DeliveryContext) DeliverToRoute(ctx context.Context, route string, resolution *Resolution, from *server.Address, forwarder *Forwarder, messageBytes []byte) error {
+	for _, account := range resolution.Accounts {
+		recipient, err := d.LookupRecipient(ctx, account.Address)
+		if err != nil {
+			return err
+		}
+		recipient.FromAddress = from
+		if _, err := d.DeliverMessage(*recipient, messageBytes); err != nil {
+			switch {
+			case errors.Is(err, consts.ErrMessageExists), errors.Is(err, consts.ErrDBUniqueViolation):
+				d.Logger.Log("Message for %s already delivered to %s", route, account.Address)
+			case errors.Is(err, ErrMailLoop):
+				d.Logger.Log("Skipping %s for %s: %v", account.Address, route, err)
+			default:
+				return fmt.Errorf("delivery to %s failed: %w", account.Address, err)
+			}
+		}
+	}
+
+	sender := ""
+	if from != nil {
+		sender = from.FullAddress()
+	}
+	return forwarder.Forward(route, sender, resolution.Forwards, messageBytes)
+}
@@ -0,0 +1,202 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/pkg/srs"
+)
+
+// fakeRouteStore serves accounts and routes from maps.
+type fakeRouteStore struct {
+	accounts map[string]int64
+	routes   map[string]*db.MailRoute
+	err      error
+}
+
+func (f *fakeRouteStore) GetActiveAccountIDByAddressWithRetry(ctx context.Context, address string) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	if id, ok := f.accounts[address]; ok {
+		return id, nil
+	}
+	return 0, consts.ErrUserNotFound
+}
+
+func (f *fakeRouteStore) GetMailRouteWithRetry(ctx context.Context, address string) (*db.MailRoute, error) {
+	if route, ok := f.routes[address]; ok {
+		return route, nil
+	}
+	return nil, consts.ErrDBNotFound
+}
+
+func (f *fakeRouteStore) put(t *testing.T, address, kind string, targets ...string) {
+	t.Helper()
+	route, err := NewMailRoute(address, kind, targets)
+	if err != nil {
+		t.Fatalf("NewMailRoute(%q): %v", address, err)
+	}
+	f.routes[route.Address] = route
+}
+
+func newFakeRouteStore(t *testing.T) *fakeRouteStore {
+	store := &fakeRouteStore{
+		accounts: map[string]int64{
+			"alice@example.com":      1,
+			"alias@example.com":      1, // Second credential of alice
+			"bob@example.com":        2,
+			"carol@new.example":      3,
+			"postmaster@new.example": 4,
+		},
+		routes: map[string]*db.MailRoute{},
+	}
+	store.put(t, "team@example.com", db.MailRouteGroup, "alice@example.com", "alias@example.com", "bob@example.com", "dave@partner.example")
+	store.put(t, "all@example.com", db.MailRouteGroup, "team@example.com", "carol@old.example")
+	store.put(t, "@old.example", db.MailRouteDomainAlias, "new.example")
+	store.put(t, "@new.example", db.MailRouteCatchAll, "postmaster@new.example")
+	store.put(t, "ping@example.com", db.MailRouteGroup, "pong@example.com", "alice@example.com")
+	store.put(t, "pong@example.com", db.MailRouteGroup, "ping@example.com")
+	return store
+}
+
+func TestResolveRecipient(t *testing.T) {
+	store := newFakeRouteStore(t)
+	tests := []struct {
+		address  string
+		accounts []int64
+		forwards []string
+	}{
+		{"bob@example.com", []int64{2}, nil},
+		// alice once, although two of her addresses are members
+		{"team@example.com", []int64{1, 2}, []string{"dave@partner.example"}},
+		{"all@example.com", []int64{1, 2, 3}, []string{"dave@partner.example"}},
+		{"carol@old.example", []int64{3}, nil},
+		{"unknown@old.example", []int64{4}, nil},
+		{"unknown@new.example", []int64{4}, nil},
+		{"ping@example.com", []int64{1}, nil},
+	}
+	for _, tt := range tests {
+		resolution, err := ResolveRecipient(context.Background(), store, tt.address)
+		if err != nil {
+			t.Errorf("ResolveRecipient(%s): %v", tt.address, err)
+			continue
+		}
+		var accounts []int64
+		for _, a := range resolution.Accounts {
+			accounts = append(accounts, a.AccountID)
+		}
+		if !reflect.DeepEqual(accounts, tt.accounts) || !reflect.DeepEqual(resolution.Forwards, tt.forwards) {
+			t.Errorf("ResolveRecipient(%s) = %v %v, want %v %v", tt.address, accounts, resolution.Forwards, tt.accounts, tt.forwards)
+		}
+	}
+
+	if _, err := ResolveRecipient(context.Background(), store, "nobody@example.com"); !errors.Is(err, ErrRecipientNotFound) {
+		t.Errorf("unknown address: err = %v, want ErrRecipientNotFound", err)
+	}
+	dbErr := errors.New("database unavailable")
+	if _, err := ResolveRecipient(context.Background(), &fakeRouteStore{err: dbErr}, "bob@example.com"); !errors.Is(err, dbErr) {
+		t.Errorf("database error: err = %v", err)
+	}
+}
+
+func TestResolveRecipientDepth(t *testing.T) {
+	store := &fakeRouteStore{routes: map[string]*db.MailRoute{}}
+	for i := 0; i <= maxRouteDepth; i++ {
+		store.put(t, "g"+string(rune('a'+i))+"@example.com", db.MailRouteGroup, "g"+string(rune('a'+i+1))+"@example.com")
+	}
+	if _, err := ResolveRecipient(context.Background(), store, "ga@example.com"); !errors.Is(err, ErrRouteTooDeep) {
+		t.Errorf("err = %v, want ErrRouteTooDeep", err)
+	}
+}
+
+func TestNewMailRoute(t *testing.T) {
+	route, err := NewMailRoute(" Team@Example.COM", db.MailRouteGroup, []string{"Alice@example.com", "alice@example.com", "bob@example.net"})
+	if err != nil {
+		t.Fatalf("NewMailRoute: %v", err)
+	}
+	if route.Address != "team@example.com" || route.Domain != "example.com" || !reflect.DeepEqual(route.Targets, []string{"alice@example.com", "bob@example.net"}) {
+		t.Errorf("unexpected group: %+v", route)
+	}
+	route, err = NewMailRoute("Old.example", db.MailRouteDomainAlias, []string{"@New.example"})
+	if err != nil || route.Address != "@old.example" || route.Targets[0] != "new.example" {
+		t.Errorf("unexpected domain alias: %+v (err %v)", route, err)
+	}
+
+	for name, args := range map[string][]string{
+		"bad kind":          {"team@example.com", "list", "a@example.com"},
+		"group of a domain": {"@example.com", db.MailRouteGroup, "a@example.com"},
+		"detail address":    {"team+x@example.com", db.MailRouteGroup, "a@example.com"},
+		"no targets":        {"team@example.com", db.MailRouteGroup},
+		"bad target":        {"team@example.com", db.MailRouteGroup, "not an address"},
+		"self target":       {"team@example.com", db.MailRouteGroup, "team@example.com"},
+		"bad domain":        {"@", db.MailRouteCatchAll, "a@example.com"},
+		"alias to itself":   {"@example.com", db.MailRouteDomainAlias, "example.com"},
+		"two alias targets": {"@old.example", db.MailRouteDomainAlias, "a.example", "b.example"},
+	} {
+		if _, err := NewMailRoute(args[0], args[1], args[2:]); !errors.Is(err, ErrInvalidMailRoute) {
+			t.Errorf("%s: err = %v, want ErrInvalidMailRoute", name, err)
+		}
+	}
+}
+
+type captureQueue struct{ from, to, messages []string }
+
+func (q *captureQueue) Enqueue(from, to, messageType string, messageBytes []byte) error {
+	q.from = append(q.from, from)
+	q.to = append(q.to, to)
+	q.messages = append(q.messages, string(messageBytes))
+	return nil
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(string, ...any) {}
+
+func TestForwarder(t *testing.T) {
+	rw, err := srs.New("srs.example.com", []string{"secret"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := &captureQueue{}
+	f := &Forwarder{RelayQueue: queue, SRS: rw, Logger: nopLogger{}}
+	message := []byte("From: carol@example.org\r\nSubject: hi\r\n\r\nbody\r\n")
+
+	if err := f.Forward("team@example.com", "carol@example.org", []string{"dave@partner.example", "erin@partner.example"}, message); err != nil {
+		t.Fatalf("Forward: %v", err)
+	}
+	if len(queue.to) != 2 || queue.to[1] != "erin@partner.example" {
+		t.Fatalf("unexpected forwards: %v", queue.to)
+	}
+	if !srs.IsSRS(queue.from[0]) || !strings.HasSuffix(queue.from[0], "@srs.example.com") {
+		t.Errorf("sender not rewritten: %q", queue.from[0])
+	}
+	forwarded := []byte(queue.messages[0])
+	if !bytes.HasPrefix(forwarded, []byte("Delivered-To: team@example.com\r\nX-Sora-Loop: 1\r\n")) {
+		t.Errorf("forwarded copy not stamped: %.60q", forwarded)
+	}
+
+	// The copy coming back to the same route is dropped
+	if err := f.Forward("team@example.com", "carol@example.org", []string{"dave@partner.example"}, forwarded); err != nil || len(queue.to) != 2 {
+		t.Errorf("looped copy forwarded again (err %v)", err)
+	}
+
+	// Null senders stay null
+	if err := f.Forward("team@example.com", "", []string{"dave@partner.example"}, message); err != nil || queue.from[2] != "" {
+		t.Errorf("null sender forwarded as %q (err %v)", queue.from[2], err)
+	}
+
+	var none *Forwarder
+	if err := none.Forward("team@example.com", "carol@example.org", nil, message); err != nil {
+		t.Errorf("nothing to forward: err = %v", err)
+	}
+	if err := none.Forward("team@example.com", "carol@example.org", []string{"dave@partner.example"}, message); !errors.Is(err, ErrForwardingUnavailable) {
+		t.Errorf("no relay queue: err = %v, want ErrForwardingUnavailable", err)
+	}
+}
@@ -1,6 +1,7 @@
 package delivery
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"strconv"
@@ -12,12 +13,15 @@ import (
 	"github.com/migadu/sora/helpers"
 	"github.com/migadu/sora/pkg/metrics"
 	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/pkg/srs"
 	"github.com/migadu/sora/server/sieveengine"
 )
 
 // SieveExecutor interface defines the contract for Sieve script execution.
 type SieveExecutor interface {
-	ExecuteSieve(ctx context.Context, recipient RecipientInfo, messageEntity *message.Entity, plaintextBody *string, fullMessageBytes []byte) (mailboxName string, discarded bool, flags []imap.Flag, err error)
+	// rewritten is non-nil when the script replaced the message (RFC 5703
+	// replace/enclose); it has already been staged locally for upload.
+	ExecuteSieve(ctx context.Context, recipient RecipientInfo, messageEntity *message.Entity, plaintextBody *string, fullMessageBytes []byte) (mailboxName string, discarded bool, flags []imap.Flag, rewritten []byte, err error)
 }
 
 // VacationOracle implements the sieveengine.VacationOracle interface using the database.
@@ -60,15 +64,26 @@ type StandardSieveExecutor struct {
 	VacationOracle     *VacationOracle
 	VacationHandler    VacationHandler
 	RelayHandler       RelayHandler
-	RelayQueue         RelayQueue // Optional: disk-based queue for relay retry
+	RelayQueue         RelayQueue    // Optional: disk-based queue for relay retry
+	SRS                *srs.Rewriter // Optional: rewrites the envelope sender of redirects
+	ARCSealer          ARCSealer     // Optional: ARC-seals redirects
 	RedirectRateLimit  int
 	RedirectRateWindow time.Duration
 	MaxRedirectHops    int
+	SieveExtensions    []string // Sieve extensions to enable (nil/empty = all default extensions)
+}
+
+// extensions returns the configured Sieve extensions, or the defaults.
+func (s *StandardSieveExecutor) extensions() []string {
+	if len(s.SieveExtensions) == 0 {
+		return sieveengine.DefaultSieveExtensions
+	}
+	return s.SieveExtensions
 }
 
 // ExecuteSieve executes Sieve scripts and returns target mailbox.
-// Returns: mailboxName, discarded, error
-func (s *StandardSieveExecutor) ExecuteSieve(ctx context.Context, recipient RecipientInfo, messageEntity *message.Entity, plaintextBody *string, fullMessageBytes []byte) (string, bool, []imap.Flag, error) {
+// Returns: mailboxName, discarded, flags, rewritten message (nil if unchanged), error
+func (s *StandardSieveExecutor) ExecuteSieve(ctx context.Context, recipient RecipientInfo, messageEntity *message.Entity, plaintextBody *string, fullMessageBytes []byte) (string, bool, []imap.Flag, []byte, error) {
 	// Default to INBOX
 	mailboxName := consts.MailboxInbox
 
@@ -83,34 +98,55 @@ func (s *StandardSieveExecutor) ExecuteSieve(ctx context.Context, recipient Reci
 		EnvelopeTo:   recipient.ToAddress.FullAddress(),
 		Header:       messageEntity.Header.Map(),
 		Body:         *plaintextBody,
+		Raw:          fullMessageBytes,
 	}
 
-	// Get user's active script
+	// Get user's active script. A script that cannot be loaded, compiled or
+	// evaluated is treated like no script: the message is kept.
+	result := sieveengine.Result{Action: sieveengine.ActionKeep}
 	activeScript, err := s.DeliveryCtx.RDB.GetActiveScriptWithRetry(ctx, recipient.AccountID)
 	if err != nil && err != consts.ErrDBNotFound {
-		// Non-critical error, continue with INBOX delivery
-		return mailboxName, false, nil, nil
-	}
-
-	var result sieveengine.Result
-	if activeScript != nil {
+		s.DeliveryCtx.Logger.Log("Failed to load active Sieve script, keeping message: %v", err)
+	} else if activeScript != nil {
 		// Execute user script
-		executor, err := sieveengine.NewSieveExecutorWithOracle(activeScript.Script, recipient.AccountID, s.VacationOracle, s.VacationOracle, s.RedirectRateLimit, s.RedirectRateWindow, s.MaxRedirectHops)
+		executor, err := sieveengine.NewSieveExecutorWithOracleAndExtensions(activeScript.Script, recipient.AccountID, s.VacationOracle, s.VacationOracle, s.RedirectRateLimit, s.RedirectRateWindow, s.MaxRedirectHops, s.extensions())
 		if err != nil {
 			metrics.SieveExecutions.WithLabelValues(s.DeliveryCtx.MetricsLabel, "failure").Inc()
-			return mailboxName, false, nil, nil
+			s.DeliveryCtx.Logger.Log("Failed to compile Sieve script, keeping message: %v", err)
+		} else if userResult, err := executor.Evaluate(ctx, sieveCtx); err != nil {
+			metrics.SieveExecutions.WithLabelValues(s.DeliveryCtx.MetricsLabel, "failure").Inc()
+			s.DeliveryCtx.Logger.Log("Failed to evaluate Sieve script, keeping message: %v", err)
+		} else {
+			metrics.SieveExecutions.WithLabelValues(s.DeliveryCtx.MetricsLabel, "success").Inc()
+			result = userResult
 		}
+	}
 
-		result, err = executor.Evaluate(ctx, sieveCtx)
-		if err != nil {
-			metrics.SieveExecutions.WithLabelValues(s.DeliveryCtx.MetricsLabel, "failure").Inc()
-			return mailboxName, false, nil, nil
+	// The reply from the account's vacation settings runs next to its script.
+	if result.Action != sieveengine.ActionDiscard && result.Action != sieveengine.ActionVacation &&
+		s.VacationHandler != nil && recipient.FromAddress != nil {
+		if vacation, ok := EvaluateManagedVacation(ctx, s.DeliveryCtx.RDB, recipient.AccountID, s.VacationOracle, sieveCtx, s.extensions()); ok {
+			_ = s.VacationHandler.HandleVacationResponse(ctx, recipient.AccountID, vacation, recipient.FromAddress, recipient.Address, messageEntity)
 		}
+	}
 
-		metrics.SieveExecutions.WithLabelValues(s.DeliveryCtx.MetricsLabel, "success").Inc()
-	} else {
-		// No script, keep in INBOX
-		result = sieveengine.Result{Action: sieveengine.ActionKeep}
+	// The script replaced the message (RFC 5703 replace/enclose). Stage the new
+	// body for upload and use it for every copy stored here and by the caller.
+	var rewritten []byte
+	if result.Message != nil {
+		if entity, err := message.Read(bytes.NewReader(result.Message)); err == nil {
+			if err := s.DeliveryCtx.stageLocally(recipient.AccountID, result.Message); err != nil {
+				return "", false, nil, nil, err
+			}
+			rewritten = result.Message
+			fullMessageBytes = rewritten
+			if text, _ := helpers.ExtractPlaintextBody(entity); text != nil {
+				plaintextBody = text
+			}
+			if messageEntity, err = message.Read(bytes.NewReader(rewritten)); err != nil {
+				return "", false, nil, nil, err
+			}
+		}
 	}
 
 	// Flags set by the Sieve script via imap4flags (RFC 5232). Applied to every
@@ -120,7 +156,7 @@ func (s *StandardSieveExecutor) ExecuteSieve(ctx context.Context, recipient Reci
 	// Process result
 	switch result.Action {
 	case sieveengine.ActionDiscard:
-		return "", true, nil, nil
+		return "", true, nil, nil, nil
 
 	case sieveengine.ActionFileInto:
 		mailboxName = result.Mailbox
@@ -128,7 +164,7 @@ func (s *StandardSieveExecutor) ExecuteSieve(ctx context.Context, recipient Reci
 			// Save to specified mailbox
 			err := s.DeliveryCtx.SaveMessageToMailbox(ctx, recipient, result.Mailbox, fullMessageBytes, messageEntity, plaintextBody, sieveFlags)
 			if err != nil {
-				return "", false, nil, err
+				return "", false, nil, nil, err
 			}
 			// Also save to INBOX
 			mailboxName = consts.MailboxInbox
@@ -139,22 +175,32 @@ func (s *StandardSieveExecutor) ExecuteSieve(ctx context.Context, recipient Reci
 		if recipient.FromAddress != nil {
 			// Stamp the outgoing copy with an incremented hop count (loop backstop).
 			relayBytes := helpers.PrependHeaderLine(fullMessageBytes, helpers.RedirectLoopHeader, strconv.Itoa(helpers.RedirectHopCount(helpers.HeaderGetter(messageEntity.Header.Map()))+1))
+			redirectFrom, err := RedirectSender(s.SRS, recipient.FromAddress.FullAddress())
+			if err != nil {
+				s.DeliveryCtx.Logger.Log("Failed to rewrite redirect sender %s: %v", recipient.FromAddress.FullAddress(), err)
+			}
+			if recipient.Address != nil {
+				relayBytes, err = SealRedirect(s.ARCSealer, recipient.Address.Domain(), relayBytes)
+				if err != nil {
+					s.DeliveryCtx.Logger.Log("Failed to ARC-seal redirect: %v", err)
+				}
+			}
 			// Try immediate delivery first if queue is not configured
 			if s.RelayQueue == nil && s.RelayHandler != nil {
-				err := s.RelayHandler.SendToExternalRelay(recipient.FromAddress.FullAddress(), result.RedirectTo, relayBytes)
+				err := s.RelayHandler.SendToExternalRelay(redirectFrom, result.RedirectTo, relayBytes)
 				if err == nil && !result.Copy {
 					// Successfully redirected without copy
-					return "", true, nil, nil
+					return "", true, nil, nil, nil
 				}
 			} else if s.RelayQueue != nil {
 				// Queue for background delivery with retry
-				err := s.RelayQueue.Enqueue(recipient.FromAddress.FullAddress(), result.RedirectTo, "redirect", relayBytes)
+				err := s.RelayQueue.Enqueue(redirectFrom, result.RedirectTo, "redirect", relayBytes)
 				if err != nil {
 					// Failed to enqueue, log error but don't fail delivery
 					s.DeliveryCtx.Logger.Log("Failed to enqueue redirect message: %v", err)
 				} else if !result.Copy {
 					// Successfully queued for redirect without copy
-					return "", true, nil, nil
+					return "", true, nil, nil, nil
 				}
 			}
 		}
@@ -166,11 +212,29 @@ func (s *StandardSieveExecutor) ExecuteSieve(ctx context.Context, recipient Reci
 		if s.VacationHandler != nil && recipient.FromAddress != nil {
 			_ = s.VacationHandler.HandleVacationResponse(ctx, recipient.AccountID, result, recipient.FromAddress, recipient.Address, messageEntity)
 		}
-		mailboxName = consts.MailboxInbox
+		mailboxName = s.keepMailbox(ctx, recipient)
 
 	default:
-		mailboxName = consts.MailboxInbox
+		mailboxName = s.keepMailbox(ctx, recipient)
 	}
 
-	return mailboxName, false, sieveFlags, nil
+	return mailboxName, false, sieveFlags, rewritten, nil
+}
+
+// keepMailbox returns where a kept message is stored: the mailbox named by the
+// recipient's +detail when the account's delivery settings file by subaddress,
+// otherwise INBOX.
+func (s *StandardSieveExecutor) keepMailbox(ctx context.Context, recipient RecipientInfo) string {
+	if recipient.ToAddress == nil || recipient.ToAddress.Detail() == "" {
+		return consts.MailboxInbox
+	}
+	name, err := SubaddressMailbox(ctx, s.DeliveryCtx.RDB, recipient.AccountID, recipient.ToAddress.Detail(), recipient.ToAddress.Domain())
+	if err != nil {
+		s.DeliveryCtx.Logger.Log("Failed to resolve subaddress mailbox, delivering to INBOX: %v", err)
+		return consts.MailboxInbox
+	}
+	if name == "" {
+		return consts.MailboxInbox
+	}
+	return name
 }
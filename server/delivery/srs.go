@@ -0,0 +1,20 @@
+package delivery
+
+import (
+	"github.com/migadu/sora/pkg/srs"
+)
+
+// RedirectSender returns the envelope sender for a redirected copy of a
+// message from sender. With SRS configured the sender is rewritten into the
+// SRS domain so the copy passes SPF/DMARC at the destination; on error (a
+// sender that cannot be rewritten) the original sender is returned with it.
+func RedirectSender(rw *srs.Rewriter, sender string) (string, error) {
+	if rw == nil {
+		return sender, nil
+	}
+	rewritten, err := rw.Forward(sender)
+	if err != nil {
+		return sender, err
+	}
+	return rewritten, nil
+}
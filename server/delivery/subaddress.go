@@ -0,0 +1,88 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/pkg/resilient"
+)
+
+// ErrInvalidDeliverySettings is returned for delivery settings that cannot be saved.
+var ErrInvalidDeliverySettings = errors.New("invalid delivery settings")
+
+// maxSubaddressMailbox bounds the length of a mailbox name taken from a +detail.
+const maxSubaddressMailbox = 255
+
+// ValidateDeliverySettings checks the settings before they are saved.
+func ValidateDeliverySettings(s *db.DeliverySettings) error {
+	switch s.SubaddressFolders {
+	case db.SubaddressFoldersOff, db.SubaddressFoldersExisting, db.SubaddressFoldersCreate:
+		return nil
+	default:
+		return fmt.Errorf("%w: subaddress_folders must be %q, %q or %q", ErrInvalidDeliverySettings,
+			db.SubaddressFoldersOff, db.SubaddressFoldersExisting, db.SubaddressFoldersCreate)
+	}
+}
+
+// subaddressMailboxName returns the mailbox a +detail names, or "" when the
+// detail is not usable as one (empty, INBOX, control characters, "." or ".."
+// segments, or a leading, trailing or doubled hierarchy delimiter).
+func subaddressMailboxName(detail string) string {
+	if detail == "" || len(detail) > maxSubaddressMailbox || strings.EqualFold(detail, consts.MailboxInbox) {
+		return ""
+	}
+	for _, r := range detail {
+		if r < 0x20 || r == 0x7f {
+			return ""
+		}
+	}
+	delimiter := string(consts.MailboxDelimiter)
+	if helpers.MailboxNameHasTraversal(detail) || strings.HasPrefix(detail, delimiter) ||
+		strings.HasSuffix(detail, delimiter) || strings.Contains(detail, delimiter+delimiter) {
+		return ""
+	}
+	return detail
+}
+
+// SubaddressMailbox returns the mailbox that mail kept for user+detail@domain
+// goes to under the account's delivery settings for that domain, or "" for
+// INBOX. With
+// subaddress_folders "existing" the detail must name a mailbox the account
+// can see, including shared ones; "create" also creates a missing top-level
+// mailbox in the account's own namespace. The caller stores the message
+// through the usual path, which checks the insert right on shared mailboxes
+// and falls back to INBOX.
+func SubaddressMailbox(ctx context.Context, rdb *resilient.ResilientDatabase, accountID int64, detail, domain string) (string, error) {
+	name := subaddressMailboxName(detail)
+	if name == "" {
+		return "", nil
+	}
+	settings, err := rdb.GetDeliverySettingsWithRetry(ctx, accountID, domain)
+	if err != nil {
+		return "", err
+	}
+	if settings.SubaddressFolders != db.SubaddressFoldersExisting && settings.SubaddressFolders != db.SubaddressFoldersCreate {
+		return "", nil
+	}
+
+	mailbox, err := rdb.GetMailboxByNameWithRetry(ctx, accountID, name)
+	if err == nil {
+		return mailbox.Name, nil
+	}
+	if !errors.Is(err, consts.ErrMailboxNotFound) {
+		return "", err
+	}
+	// Nested names could land in another account's shared namespace
+	if settings.SubaddressFolders != db.SubaddressFoldersCreate || strings.ContainsRune(name, consts.MailboxDelimiter) {
+		return "", nil
+	}
+	if mailbox, err = rdb.GetOrCreateMailboxByNameWithRetry(ctx, accountID, name); err != nil {
+		return "", fmt.Errorf("failed to create mailbox '%s': %w", name, err)
+	}
+	return mailbox.Name, nil
+}
@@ -0,0 +1,47 @@
+package delivery
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/migadu/sora/db"
+)
+
+func TestSubaddressMailboxName(t *testing.T) {
+	tests := []struct {
+		detail string
+		want   string
+	}{
+		{"lists", "lists"},
+		{"Work/Invoices", "Work/Invoices"},
+		{"", ""},
+		{"inbox", ""},
+		{"INBOX", ""},
+		{"..", ""},
+		{"a/../b", ""},
+		{"/root", ""},
+		{"trailing/", ""},
+		{"a//b", ""},
+		{"bad\x00name", ""},
+		{"tab\tname", ""},
+	}
+	for _, tt := range tests {
+		if got := subaddressMailboxName(tt.detail); got != tt.want {
+			t.Errorf("subaddressMailboxName(%q) = %q, want %q", tt.detail, got, tt.want)
+		}
+	}
+}
+
+func TestValidateDeliverySettings(t *testing.T) {
+	for _, mode := range []string{db.SubaddressFoldersOff, db.SubaddressFoldersExisting, db.SubaddressFoldersCreate} {
+		if err := ValidateDeliverySettings(&db.DeliverySettings{SubaddressFolders: mode}); err != nil {
+			t.Errorf("mode %q rejected: %v", mode, err)
+		}
+	}
+	for _, mode := range []string{"", "on", "Create"} {
+		err := ValidateDeliverySettings(&db.DeliverySettings{SubaddressFolders: mode})
+		if !errors.Is(err, ErrInvalidDeliverySettings) {
+			t.Errorf("mode %q: got %v, want ErrInvalidDeliverySettings", mode, err)
+		}
+	}
+}
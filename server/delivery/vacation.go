@@ -1,14 +1,18 @@
 package delivery
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"mime"
+	"strings"
 	"time"
 
 	"github.com/emersion/go-message"
 	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
 	"github.com/migadu/sora/helpers"
 	"github.com/migadu/sora/server"
 	"github.com/migadu/sora/server/sieveengine"
@@ -33,6 +37,9 @@ type StandardVacationHandler struct {
 	// enqueued, so a relay worker can process it immediately instead of on its next
 	// poll. Optional.
 	RelayNotify func()
+	// OnSent, when set, is called with the recipient address after a vacation
+	// response has been queued or relayed. Optional.
+	OnSent func(to string)
 }
 
 // shouldSuppressVacation implements RFC 5230 §4.5 mandatory suppression rules for
@@ -90,6 +97,13 @@ func (h *StandardVacationHandler) recipientIsAddressed(ctx context.Context, acco
 	return false, nil
 }
 
+// sent reports a sent response via the optional OnSent callback.
+func (h *StandardVacationHandler) sent(to string) {
+	if h.OnSent != nil {
+		h.OnSent(to)
+	}
+}
+
 // log emits a message via the optional Logger.
 func (h *StandardVacationHandler) log(format string, args ...any) {
 	if h.Logger != nil {
@@ -187,15 +201,36 @@ func (h *StandardVacationHandler) HandleVacationResponse(ctx context.Context, Ac
 		msgHeader.Set("References", "<"+originalMessageID+">")
 	}
 
-	w, err := message.CreateWriter(&vacationMessage, msgHeader)
-	if err != nil {
-		return err
-	}
-	if _, err := w.Write([]byte(result.VacationMsg)); err != nil {
+	if result.VacationIsMime {
+		// RFC 5230 :mime: the reason is a MIME entity whose content headers
+		// replace the text/plain default above.
+		br := bufio.NewReader(strings.NewReader(result.VacationMsg))
+		reasonHeader, err := textproto.ReadHeader(br)
+		if err != nil {
+			return fmt.Errorf("invalid :mime vacation reason: %w", err)
+		}
+		for _, key := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+			if value := reasonHeader.Get(key); value != "" {
+				msgHeader.Set(key, value)
+			}
+		}
+		if err := textproto.WriteHeader(&vacationMessage, msgHeader.Header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(&vacationMessage, br); err != nil {
+			return err
+		}
+	} else {
+		w, err := message.CreateWriter(&vacationMessage, msgHeader)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(result.VacationMsg)); err != nil {
+			w.Close()
+			return err
+		}
 		w.Close()
-		return err
 	}
-	w.Close()
 
 	// Send via external relay or queue
 	if h.RelayQueue != nil {
@@ -207,9 +242,13 @@ func (h *StandardVacationHandler) HandleVacationResponse(ctx context.Context, Ac
 		if h.RelayNotify != nil {
 			h.RelayNotify()
 		}
+		h.sent(fromAddr.FullAddress())
 		return nil
 	} else if h.RelayHandler != nil {
-		return h.RelayHandler.SendToExternalRelay(vacationFrom, fromAddr.FullAddress(), vacationMessage.Bytes())
+		if err := h.RelayHandler.SendToExternalRelay(vacationFrom, fromAddr.FullAddress(), vacationMessage.Bytes()); err != nil {
+			return err
+		}
+		h.sent(fromAddr.FullAddress())
 	}
 
 	return nil
@@ -0,0 +1,251 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/server"
+	"github.com/migadu/sora/server/sieveengine"
+)
+
+// ErrInvalidVacation is returned for vacation settings that cannot be saved.
+var ErrInvalidVacation = errors.New("invalid vacation settings")
+
+const (
+	// managedVacationHandle is the :handle of the compiled vacation action.
+	managedVacationHandle = "sora-vacation"
+
+	defaultVacationDays   = 7
+	maxVacationDays       = 365
+	maxVacationSubject    = 998
+	maxVacationBody       = 65536
+	maxVacationListLength = 100
+)
+
+// NormalizeVacationSettings validates settings and fills in defaults: days
+// defaults to 7, addresses and domains are lowercased and deduplicated.
+func NormalizeVacationSettings(v *db.VacationSettings) error {
+	if v.Days == 0 {
+		v.Days = defaultVacationDays
+	}
+	if v.Days < 1 || v.Days > maxVacationDays {
+		return fmt.Errorf("%w: days must be between 1 and %d", ErrInvalidVacation, maxVacationDays)
+	}
+	if v.StartAt != nil && v.EndAt != nil && !v.EndAt.After(*v.StartAt) {
+		return fmt.Errorf("%w: end_at must be after start_at", ErrInvalidVacation)
+	}
+	if len(v.Subject) > maxVacationSubject || strings.ContainsAny(v.Subject, "\r\n") {
+		return fmt.Errorf("%w: subject must be a single line of at most %d bytes", ErrInvalidVacation, maxVacationSubject)
+	}
+	if strings.TrimSpace(v.TextBody) == "" {
+		return fmt.Errorf("%w: text_body is required", ErrInvalidVacation)
+	}
+	if len(v.TextBody) > maxVacationBody || len(v.HTMLBody) > maxVacationBody {
+		return fmt.Errorf("%w: bodies must be at most %d bytes", ErrInvalidVacation, maxVacationBody)
+	}
+	if v.From != "" {
+		addr, err := server.NewAddress(v.From)
+		if err != nil {
+			return fmt.Errorf("%w: from: %v", ErrInvalidVacation, err)
+		}
+		v.From = addr.FullAddress()
+	}
+
+	if len(v.Addresses) > maxVacationListLength || len(v.ExcludeDomains) > maxVacationListLength {
+		return fmt.Errorf("%w: at most %d addresses and excluded domains", ErrInvalidVacation, maxVacationListLength)
+	}
+	addresses := []string{}
+	for _, a := range v.Addresses {
+		addr, err := server.NewAddress(a)
+		if err != nil {
+			return fmt.Errorf("%w: address %q: %v", ErrInvalidVacation, a, err)
+		}
+		addresses = appendUnique(addresses, addr.FullAddress())
+	}
+	v.Addresses = addresses
+	domains := []string{}
+	for _, d := range v.ExcludeDomains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))
+		if d == "" || strings.ContainsAny(d, "@ \t\"\\*?") {
+			return fmt.Errorf("%w: invalid domain %q", ErrInvalidVacation, d)
+		}
+		domains = appendUnique(domains, d)
+	}
+	v.ExcludeDomains = domains
+	return nil
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// CompileVacationScript returns the Sieve script that sends the reply
+// described by v (already normalized). Dates are compared in UTC, and mail
+// from excluded domains or their subdomains gets no reply. With an HTML body
+// the reason is a multipart/alternative MIME entity (RFC 5230 :mime).
+func CompileVacationScript(v *db.VacationSettings) (string, error) {
+	extensions := []string{"vacation"}
+	var tests []string
+	if v.StartAt != nil {
+		tests = append(tests, fmt.Sprintf(`currentdate :zone "+0000" :value "ge" "iso8601" %s`, sieveString(v.StartAt.UTC().Format(time.RFC3339))))
+	}
+	if v.EndAt != nil {
+		tests = append(tests, fmt.Sprintf(`currentdate :zone "+0000" :value "lt" "iso8601" %s`, sieveString(v.EndAt.UTC().Format(time.RFC3339))))
+	}
+	if len(tests) > 0 {
+		extensions = append(extensions, "date", "relational")
+	}
+	if len(v.ExcludeDomains) > 0 {
+		var patterns []string
+		for _, d := range v.ExcludeDomains {
+			patterns = append(patterns, d, "*."+d)
+		}
+		list := sieveStringList(patterns)
+		tests = append(tests, fmt.Sprintf(`not anyof(envelope :domain :matches "from" %s, address :domain :matches "from" %s)`, list, list))
+		extensions = append(extensions, "envelope")
+	}
+
+	var action strings.Builder
+	fmt.Fprintf(&action, "vacation :days %d", v.Days)
+	if v.Subject != "" {
+		fmt.Fprintf(&action, " :subject %s", sieveString(v.Subject))
+	}
+	if v.From != "" {
+		fmt.Fprintf(&action, " :from %s", sieveString(v.From))
+	}
+	if len(v.Addresses) > 0 {
+		fmt.Fprintf(&action, " :addresses %s", sieveStringList(v.Addresses))
+	}
+	reason := v.TextBody
+	if v.HTMLBody != "" {
+		var err error
+		if reason, err = vacationMIME(v.TextBody, v.HTMLBody); err != nil {
+			return "", err
+		}
+		action.WriteString(" :mime")
+	}
+	fmt.Fprintf(&action, " :handle %s %s;", sieveString(managedVacationHandle), sieveString(reason))
+
+	var script strings.Builder
+	script.WriteString("# Generated from the account's vacation settings; do not edit.\n")
+	fmt.Fprintf(&script, "require %s;\n", sieveStringList(extensions))
+	if len(tests) == 0 {
+		script.WriteString(action.String() + "\n")
+	} else {
+		fmt.Fprintf(&script, "if allof(%s) {\n\t%s\n}\n", strings.Join(tests, ",\n\t\t"), action.String())
+	}
+	return script.String(), nil
+}
+
+// vacationMIME builds the multipart/alternative reason of an HTML reply.
+func vacationMIME(text, html string) (string, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return "", err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return "", err
+		}
+		if err := qp.Close(); err != nil {
+			return "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Content-Type: multipart/alternative; boundary=%q\r\n\r\n%s", mw.Boundary(), body.String()), nil
+}
+
+// sieveString quotes s as a Sieve quoted string (RFC 5228 §2.4.2).
+func sieveString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func sieveStringList(list []string) string {
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = sieveString(s)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// SaveVacationSettings validates v, checks that its from address belongs to
+// the account, compiles it with the enabled Sieve extensions and stores it.
+// Validation failures wrap ErrInvalidVacation.
+func SaveVacationSettings(ctx context.Context, rdb *resilient.ResilientDatabase, v *db.VacationSettings, extensions []string) error {
+	if err := NormalizeVacationSettings(v); err != nil {
+		return err
+	}
+	if v.From != "" {
+		owned, err := rdb.IsAddressOwnedByAccountWithRetry(ctx, v.AccountID, v.From)
+		if err != nil {
+			return err
+		}
+		if !owned {
+			return fmt.Errorf("%w: from must be one of the account's addresses", ErrInvalidVacation)
+		}
+	}
+	script, err := CompileVacationScript(v)
+	if err != nil {
+		return err
+	}
+	if err := sieveengine.ValidateScript(script, extensions); err != nil {
+		return fmt.Errorf("failed to compile vacation settings: %w", err)
+	}
+	v.Script = script
+	return rdb.SetVacationSettingsWithRetry(ctx, v)
+}
+
+// EvaluateManagedVacation runs the account's compiled vacation script, if its
+// reply is enabled, and returns the vacation result when a reply is due. The
+// reply is sent independently of the user's own Sieve script, so filing or
+// forwarding rules do not silence it. The script runs with the enabled Sieve
+// extensions.
+func EvaluateManagedVacation(ctx context.Context, rdb *resilient.ResilientDatabase, accountID int64, oracle sieveengine.VacationOracle, sieveCtx sieveengine.Context, extensions []string) (sieveengine.Result, bool) {
+	script, _, err := rdb.GetActiveVacationScriptWithRetry(ctx, accountID)
+	if err != nil {
+		if !errors.Is(err, consts.ErrDBNotFound) {
+			logger.Warn("Failed to load vacation settings", "account_id", accountID, "error", err)
+		}
+		return sieveengine.Result{}, false
+	}
+	executor, err := sieveengine.NewSieveExecutorWithOracleAndExtensions(script, accountID, oracle, nil, 0, 0, 0, extensions)
+	if err != nil {
+		logger.Warn("Failed to load vacation script", "account_id", accountID, "error", err)
+		return sieveengine.Result{}, false
+	}
+	result, err := executor.Evaluate(ctx, sieveCtx)
+	if err != nil {
+		logger.Warn("Failed to evaluate vacation script", "account_id", accountID, "error", err)
+		return sieveengine.Result{}, false
+	}
+	return result, result.Action == sieveengine.ActionVacation
+}
@@ -0,0 +1,181 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-message"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/server/sieveengine"
+)
+
+type allowAllVacations struct{ sent int }
+
+func (o *allowAllVacations) IsVacationResponseAllowed(context.Context, int64, string, string, time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (o *allowAllVacations) RecordVacationResponseSent(context.Context, int64, string, string) error {
+	o.sent++
+	return nil
+}
+
+// evaluateVacation compiles v and runs it against a message from sender.
+func evaluateVacation(t *testing.T, v *db.VacationSettings, sender string) sieveengine.Result {
+	t.Helper()
+	if err := NormalizeVacationSettings(v); err != nil {
+		t.Fatalf("NormalizeVacationSettings: %v", err)
+	}
+	script, err := CompileVacationScript(v)
+	if err != nil {
+		t.Fatalf("CompileVacationScript: %v", err)
+	}
+	executor, err := sieveengine.NewSieveExecutorWithOracleAndExtensions(script, 1, &allowAllVacations{}, nil, 0, 0, 0, sieveengine.DefaultSieveExtensions)
+	if err != nil {
+		t.Fatalf("compiled script does not load: %v\n%s", err, script)
+	}
+	result, err := executor.Evaluate(context.Background(), sieveengine.Context{
+		EnvelopeFrom: sender,
+		EnvelopeTo:   "jane@example.com",
+		Header: map[string][]string{
+			"From":    {sender},
+			"To":      {"jane@example.com"},
+			"Subject": {"Hello"},
+		},
+		Body: "Hi",
+	})
+	if err != nil {
+		t.Fatalf("Evaluate: %v\n%s", err, script)
+	}
+	return result
+}
+
+func TestCompileVacationScript(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	t.Run("plain reply", func(t *testing.T) {
+		result := evaluateVacation(t, &db.VacationSettings{
+			Subject:  `Away "until" Monday`,
+			TextBody: "I am away.\nBack on Monday \\o/",
+			From:     "Jane@Example.com",
+		}, "bob@external.com")
+		if result.Action != sieveengine.ActionVacation {
+			t.Fatalf("action = %q, want vacation", result.Action)
+		}
+		if result.VacationSubj != `Away "until" Monday` || result.VacationMsg != "I am away.\r\nBack on Monday \\o/" {
+			t.Errorf("unexpected reply: %q / %q", result.VacationSubj, result.VacationMsg)
+		}
+		if result.VacationFrom != "jane@example.com" || result.VacationIsMime {
+			t.Errorf("unexpected reply: from %q, mime %v", result.VacationFrom, result.VacationIsMime)
+		}
+	})
+
+	t.Run("within dates", func(t *testing.T) {
+		result := evaluateVacation(t, &db.VacationSettings{TextBody: "away", StartAt: &past, EndAt: &future}, "bob@external.com")
+		if result.Action != sieveengine.ActionVacation {
+			t.Errorf("action = %q, want vacation", result.Action)
+		}
+	})
+
+	t.Run("not started", func(t *testing.T) {
+		result := evaluateVacation(t, &db.VacationSettings{TextBody: "away", StartAt: &future}, "bob@external.com")
+		if result.Action != sieveengine.ActionKeep {
+			t.Errorf("action = %q, want keep", result.Action)
+		}
+	})
+
+	t.Run("ended", func(t *testing.T) {
+		before := past.Add(-time.Hour)
+		result := evaluateVacation(t, &db.VacationSettings{TextBody: "away", StartAt: &before, EndAt: &past}, "bob@external.com")
+		if result.Action != sieveengine.ActionKeep {
+			t.Errorf("action = %q, want keep", result.Action)
+		}
+	})
+
+	t.Run("excluded domains", func(t *testing.T) {
+		v := &db.VacationSettings{TextBody: "away", ExcludeDomains: []string{"@Example.com"}}
+		for _, sender := range []string{"colleague@example.com", "colleague@eu.example.com"} {
+			if result := evaluateVacation(t, v, sender); result.Action != sieveengine.ActionKeep {
+				t.Errorf("%s: action = %q, want keep", sender, result.Action)
+			}
+		}
+		if result := evaluateVacation(t, v, "bob@notexample.com"); result.Action != sieveengine.ActionVacation {
+			t.Errorf("action = %q, want vacation", result.Action)
+		}
+	})
+
+	t.Run("html reply", func(t *testing.T) {
+		result := evaluateVacation(t, &db.VacationSettings{TextBody: "away", HTMLBody: "<p>away</p>"}, "bob@external.com")
+		if result.Action != sieveengine.ActionVacation || !result.VacationIsMime {
+			t.Fatalf("action = %q, mime = %v", result.Action, result.VacationIsMime)
+		}
+
+		rq := &captureRelayQueue{}
+		h := &StandardVacationHandler{Hostname: "mail.example.com", RelayQueue: rq}
+		orig := makeMessage(map[string]string{"From": "bob@external.com", "To": "jane@example.com"})
+		if err := h.HandleVacationResponse(context.Background(), 1, result, vacAddr(t, "bob@external.com"), vacAddr(t, "jane@example.com"), orig); err != nil {
+			t.Fatalf("HandleVacationResponse: %v", err)
+		}
+		if len(rq.calls) != 1 {
+			t.Fatalf("expected 1 enqueue, got %d", len(rq.calls))
+		}
+		ent, err := message.Read(bytes.NewReader(rq.calls[0].body))
+		if err != nil {
+			t.Fatalf("parse message: %v", err)
+		}
+		mr := ent.MultipartReader()
+		if mr == nil {
+			t.Fatalf("reply is not multipart: %s", rq.calls[0].body)
+		}
+		var types, bodies []string
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("NextPart: %v", err)
+			}
+			mediaType, _, _ := part.Header.ContentType()
+			body, _ := io.ReadAll(part.Body)
+			types = append(types, mediaType)
+			bodies = append(bodies, string(body))
+		}
+		if strings.Join(types, ",") != "text/plain,text/html" || strings.Join(bodies, ",") != "away,<p>away</p>" {
+			t.Errorf("unexpected parts: %v %q", types, bodies)
+		}
+	})
+}
+
+func TestNormalizeVacationSettings(t *testing.T) {
+	start := time.Now()
+	end := start.Add(-time.Hour)
+	tests := map[string]*db.VacationSettings{
+		"missing body":   {Subject: "Away"},
+		"days too large": {TextBody: "away", Days: 366},
+		"end before":     {TextBody: "away", StartAt: &start, EndAt: &end},
+		"bad from":       {TextBody: "away", From: "not an address"},
+		"bad address":    {TextBody: "away", Addresses: []string{"jane"}},
+		"bad domain":     {TextBody: "away", ExcludeDomains: []string{"*.example.com"}},
+		"folded subject": {TextBody: "away", Subject: "Away\r\nBcc: x@example.com"},
+	}
+	for name, v := range tests {
+		if err := NormalizeVacationSettings(v); !errors.Is(err, ErrInvalidVacation) {
+			t.Errorf("%s: err = %v, want ErrInvalidVacation", name, err)
+		}
+	}
+
+	v := &db.VacationSettings{TextBody: "away", Addresses: []string{"Jane@Example.com", "jane@example.com"}}
+	if err := NormalizeVacationSettings(v); err != nil {
+		t.Fatalf("NormalizeVacationSettings: %v", err)
+	}
+	if v.Days != 7 || len(v.Addresses) != 1 || v.Addresses[0] != "jane@example.com" || v.ExcludeDomains == nil {
+		t.Errorf("unexpected normalized settings: %+v", v)
+	}
+}
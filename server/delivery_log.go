@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/migadu/sora/logger"
+)
+
+const (
+	// deliveryLogQueueSize bounds records waiting to be written. Records are
+	// dropped when the database falls this far behind.
+	deliveryLogQueueSize = 10000
+
+	// deliveryLogBatchSize is the maximum number of records written at once
+	deliveryLogBatchSize = 200
+
+	// deliveryLogFlushInterval is how long a record waits for a batch to fill
+	deliveryLogFlushInterval = time.Second
+)
+
+// Delivery results recorded in the delivery log.
+const (
+	DeliveryDelivered = "delivered" // Accepted (stored, filed, redirected or discarded by Sieve)
+	DeliveryDeferred  = "deferred"  // Temporary failure (4xx): the sending MTA retries
+	DeliveryRejected  = "rejected"  // Permanent failure (5xx)
+)
+
+// DeliveryRecord is the outcome of one message delivered to one recipient.
+type DeliveryRecord struct {
+	ID           int64     `json:"id,omitempty"`
+	Time         time.Time `json:"delivered_at"`
+	MessageID    string    `json:"message_id"`              // Message-ID header, without angle brackets
+	Sender       string    `json:"sender"`                  // Envelope sender ("" = null sender)
+	Recipient    string    `json:"recipient"`               // Envelope recipient as given in RCPT TO
+	AccountID    int64     `json:"account_id,omitempty"`    // 0 = no local account (routed or returned mail)
+	Mailbox      string    `json:"mailbox,omitempty"`       // Mailbox the message was stored in
+	SieveActions []string  `json:"sieve_actions,omitempty"` // Sieve actions taken, e.g. "fileinto", "redirect :copy"
+	Size         int64     `json:"size"`
+	Result       string    `json:"result"` // DeliveryDelivered, DeliveryDeferred or DeliveryRejected
+	Code         string    `json:"code"`   // SMTP reply code and enhanced status code, e.g. "250 2.0.0"
+	Node         string    `json:"node"`   // Host name of the server that handled the delivery
+}
+
+// DeliveryLogStore persists delivery records (implemented by
+// resilient.ResilientDatabase).
+type DeliveryLogStore interface {
+	RecordDeliveriesWithRetry(ctx context.Context, records []DeliveryRecord) error
+}
+
+// DeliveryLog writes delivery records to the database in the background so
+// delivery never waits on it. A nil *DeliveryLog is valid and drops every
+// record, so callers do not need to check whether it is enabled.
+type DeliveryLog struct {
+	store DeliveryLogStore
+	queue chan DeliveryRecord
+	wg    sync.WaitGroup
+}
+
+// NewDeliveryLog creates a delivery log writing to store.
+func NewDeliveryLog(store DeliveryLogStore) *DeliveryLog {
+	return &DeliveryLog{
+		store: store,
+		queue: make(chan DeliveryRecord, deliveryLogQueueSize),
+	}
+}
+
+// Start runs the writer until ctx is cancelled; queued records are flushed on
+// the way out.
+func (l *DeliveryLog) Start(ctx context.Context) {
+	if l == nil {
+		return
+	}
+	l.wg.Add(1)
+	go l.run(ctx)
+}
+
+// Wait blocks until the writer has stopped.
+func (l *DeliveryLog) Wait() {
+	if l == nil {
+		return
+	}
+	l.wg.Wait()
+}
+
+// Record queues a delivery record. It never blocks: when the queue is full
+// the record is dropped.
+func (l *DeliveryLog) Record(rec DeliveryRecord) {
+	if l == nil || rec.Recipient == "" {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	select {
+	case l.queue <- rec:
+	default:
+		logger.Debug("Delivery log: Queue full, dropping record", "recipient", rec.Recipient, "message_id", rec.MessageID)
+	}
+}
+
+func (l *DeliveryLog) run(ctx context.Context) {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(deliveryLogFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]DeliveryRecord, 0, deliveryLogBatchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := l.store.RecordDeliveriesWithRetry(ctx, batch); err != nil {
+			logger.Warn("Delivery log: Failed to store records", "count", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case rec := <-l.queue:
+			batch = append(batch, rec)
+			if len(batch) >= deliveryLogBatchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			// Drain what is queued with a short deadline of its own
+		drain:
+			for {
+				select {
+				case rec := <-l.queue:
+					batch = append(batch, rec)
+				default:
+					break drain
+				}
+			}
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			flush(flushCtx)
+			cancel()
+			return
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeDeliveryLogStore struct {
+	mu      sync.Mutex
+	records []DeliveryRecord
+}
+
+func (f *fakeDeliveryLogStore) RecordDeliveriesWithRetry(_ context.Context, records []DeliveryRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records = append(f.records, records...)
+	return nil
+}
+
+func (f *fakeDeliveryLogStore) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.records)
+}
+
+func TestDeliveryLogFlush(t *testing.T) {
+	store := &fakeDeliveryLogStore{}
+	l := NewDeliveryLog(store)
+	ctx, cancel := context.WithCancel(context.Background())
+	l.Start(ctx)
+
+	l.Record(DeliveryRecord{Recipient: "user@example.com", AccountID: 1, Mailbox: "INBOX", Result: DeliveryDelivered, Code: "250 2.0.0"})
+	l.Record(DeliveryRecord{Recipient: "user@example.com", Result: DeliveryRejected, Code: "552 5.3.4"})
+	l.Record(DeliveryRecord{Result: DeliveryDelivered}) // No recipient: dropped
+
+	deadline := time.Now().Add(5 * time.Second)
+	for store.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := store.count(); got != 2 {
+		t.Fatalf("stored %d records after flush interval, want 2", got)
+	}
+	if store.records[0].Time.IsZero() {
+		t.Error("Record should set the time")
+	}
+
+	// Records queued at shutdown are flushed before Wait returns
+	l.Record(DeliveryRecord{Recipient: "other@example.com", Result: DeliveryDeferred, Code: "452 4.3.1"})
+	cancel()
+	l.Wait()
+	if got := store.count(); got != 3 {
+		t.Errorf("stored %d records after shutdown, want 3", got)
+	}
+}
+
+func TestDeliveryLogNil(t *testing.T) {
+	var l *DeliveryLog
+	l.Start(context.Background())
+	l.Record(DeliveryRecord{Recipient: "user@example.com"})
+	l.Wait()
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/migadu/sora/consts"
 	"github.com/migadu/sora/db"
 	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/server/webhook"
 )
 
 // Create a new mailbox
@@ -222,6 +223,7 @@ func (s *IMAPSession) Create(ctx context.Context, name string, options *imap.Cre
 			return s.internalError("failed to create mailbox '%s': %v", name, err)
 		}
 		s.DebugLog("mailbox created with special-use", "mailbox", name, "special_use", specialUse)
+		s.emitEvent(webhook.EventMailboxCreated, map[string]any{"mailbox": name, "special_use": specialUse})
 		s.useMasterDB.Store(true) // Pin session to master DB for read-your-writes consistency
 		return nil
 	}
@@ -241,6 +243,7 @@ func (s *IMAPSession) Create(ctx context.Context, name string, options *imap.Cre
 	}
 
 	s.DebugLog("mailbox created", "mailbox", name)
+	s.emitEvent(webhook.EventMailboxCreated, map[string]any{"mailbox": name})
 	s.useMasterDB.Store(true) // Pin session to master DB for read-your-writes consistency
 	return nil
 }
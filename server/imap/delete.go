@@ -7,6 +7,7 @@ import (
 
 	"github.com/emersion/go-imap/v2"
 	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/server/webhook"
 )
 
 func (s *IMAPSession) Delete(ctx context.Context, mboxName string) error {
@@ -80,6 +81,7 @@ func (s *IMAPSession) Delete(ctx context.Context, mboxName string) error {
 	}
 
 	s.DebugLog("mailbox soft-deleted (pending background purge)", "mailbox", mboxName)
+	s.emitEvent(webhook.EventMailboxDeleted, map[string]any{"mailbox": mboxName})
 	s.useMasterDB.Store(true) // Pin session to master DB for read-your-writes consistency
 	return nil
 }
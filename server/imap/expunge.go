@@ -6,6 +6,7 @@ import (
 	"github.com/emersion/go-imap/v2"
 	"github.com/emersion/go-imap/v2/imapserver"
 	"github.com/migadu/sora/pkg/metrics"
+	"github.com/migadu/sora/server/webhook"
 )
 
 func (s *IMAPSession) Expunge(ctx context.Context, w *imapserver.ExpungeWriter, uidSet *imap.UIDSet) error {
@@ -35,6 +36,7 @@ func (s *IMAPSession) Expunge(ctx context.Context, w *imapserver.ExpungeWriter,
 		}
 	}
 	mailboxID := s.selectedMailbox.ID
+	mailboxName := s.selectedMailbox.Name
 	AccountID := s.AccountID()
 	// RFC 5182: resolve a "$" marker (UID EXPUNGE $) to the saved search result.
 	if uidSet != nil && imap.IsSearchRes(*uidSet) {
@@ -111,6 +113,12 @@ func (s *IMAPSession) Expunge(ctx context.Context, w *imapserver.ExpungeWriter,
 
 	s.DebugLog("expunge command processed", "count", len(uidsToDelete))
 
+	uids := make([]uint32, len(uidsToDelete))
+	for i, uid := range uidsToDelete {
+		uids[i] = uint32(uid)
+	}
+	s.emitEvent(webhook.EventMessageExpunged, map[string]any{"mailbox": mailboxName, "uids": uids})
+
 	// Track domain and user command activity - EXPUNGE is database intensive!
 	if s.IMAPUser != nil && len(uidsToDelete) > 0 {
 		metrics.TrackDomainCommand("imap", s.IMAPUser.Address.Domain(), "EXPUNGE")
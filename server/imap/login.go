@@ -10,6 +10,7 @@ import (
 	"github.com/emersion/go-imap/v2"
 	"github.com/migadu/sora/pkg/metrics"
 	"github.com/migadu/sora/server"
+	"github.com/migadu/sora/server/webhook"
 )
 
 // Login handles the IMAP LOGIN command. It always applies the authentication
@@ -182,6 +183,9 @@ func (s *IMAPSession) login(ctx context.Context, address, password string, apply
 			// Start termination poller to check for kick commands
 			s.startTerminationPoller()
 
+			s.emitEvent(webhook.EventLoginSucceeded, map[string]any{"remote_ip": s.RemoteIP, "method": "master"})
+			s.recordLogin(addressParsed.BaseAddress(), s.IMAPUser.AccountID(), "")
+
 			// Clear auth idle timeout after successful authentication
 			// Post-auth timeouts are handled by SoraConn (command_timeout)
 			if s.server.authIdleTimeout > 0 {
@@ -199,6 +203,9 @@ func (s *IMAPSession) login(ctx context.Context, address, password string, apply
 			s.server.authLimiter.RecordAuthAttemptWithProxy(ctx, netConn, proxyInfo, addressParsed.BaseAddress(), false)
 		}
 
+		s.emitLoginFailed(addressParsed.BaseAddress(), "invalid master credentials")
+		s.recordLogin(addressParsed.BaseAddress(), 0, "invalid master credentials")
+
 		// Master username suffix was provided but master password was wrong - fail immediately
 		return &imap.Error{
 			Type: imap.StatusResponseTypeNo,
@@ -234,6 +241,8 @@ func (s *IMAPSession) login(ctx context.Context, address, password string, apply
 		if s.server.authLimiter != nil {
 			s.server.authLimiter.RecordAuthAttemptWithProxy(ctx, netConn, proxyInfo, addressParsed.BaseAddress(), false)
 		}
+		s.emitLoginFailed(addressParsed.BaseAddress(), "invalid credentials")
+		s.recordLogin(addressParsed.BaseAddress(), 0, "invalid credentials")
 
 		return &imap.Error{
 			Type: imap.StatusResponseTypeNo,
@@ -255,6 +264,10 @@ func (s *IMAPSession) login(ctx context.Context, address, password string, apply
 		return s.internalError("failed to get primary email: %v", err)
 	}
 
+	if err := s.checkSuspiciousLogin(ctx, addressParsed.BaseAddress(), AccountID); err != nil {
+		return err
+	}
+
 	s.server.authenticatedConnections.Add(1)
 	duration := time.Since(authStart)
 
@@ -301,6 +314,9 @@ func (s *IMAPSession) login(ctx context.Context, address, password string, apply
 	// Start termination poller to check for kick commands
 	s.startTerminationPoller()
 
+	s.emitEvent(webhook.EventLoginSucceeded, map[string]any{"remote_ip": s.RemoteIP, "method": "password"})
+	s.recordLogin(loginAddr, AccountID, "")
+
 	// Trigger cache warmup for the authenticated user
 	s.triggerCacheWarmup()
 
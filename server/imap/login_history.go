@@ -0,0 +1,36 @@
+package imap
+
+import (
+	"strings"
+
+	"github.com/migadu/sora/server"
+)
+
+// recordLogin adds a login attempt to the account's login history. An empty
+// failure reason records a successful login; failed logins pass accountID 0
+// and are matched to the account by address when stored.
+func (s *IMAPSession) recordLogin(address string, accountID int64, failure string) {
+	if s.server.loginHistory == nil {
+		return
+	}
+	record := s.loginRecord(address, accountID)
+	record.Success = failure == ""
+	record.Reason = failure
+	s.server.loginHistory.Record(record)
+}
+
+// loginRecord describes a login of this session.
+func (s *IMAPSession) loginRecord(address string, accountID int64) server.LoginRecord {
+	var clientID string
+	if s.clientID != nil {
+		clientID = strings.TrimSpace(s.clientID.Name + " " + s.clientID.Version)
+	}
+	return server.LoginRecord{
+		AccountID: accountID,
+		Username:  address,
+		Protocol:  "imap",
+		IP:        s.RemoteIP,
+		ClientID:  clientID,
+		JA4:       s.ja4Fingerprint,
+	}
+}
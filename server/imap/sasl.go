@@ -176,6 +176,8 @@ func (s *IMAPSession) Authenticate(mechanism string) (sasl.Server, error) {
 						}
 					}
 
+					s.recordLogin(loginAddr, AccountID, "")
+
 					// Trigger cache warmup for the authenticated user (if configured)
 					s.triggerCacheWarmup()
 
@@ -195,6 +197,7 @@ func (s *IMAPSession) Authenticate(mechanism string) (sasl.Server, error) {
 				if s.server.authLimiter != nil {
 					s.server.authLimiter.RecordAuthAttemptWithProxy(s.ctx, netConn, proxyInfo, usernameParsed.BaseAddress(), false)
 				}
+				s.recordLogin(usernameParsed.BaseAddress(), 0, "invalid master credentials")
 
 				// Master username suffix was provided but master password was wrong - fail immediately
 				return &imap.Error{
@@ -285,6 +288,10 @@ func (s *IMAPSession) Authenticate(mechanism string) (sasl.Server, error) {
 						return s.internalError("failed to prepare impersonated user session: %v", dbErr)
 					}
 
+					if err := s.checkSuspiciousLogin(s.ctx, address.BaseAddress(), AccountID); err != nil {
+						return err
+					}
+
 					s.server.authenticatedConnections.Add(1)
 					duration := time.Since(authStart)
 
@@ -318,6 +325,8 @@ func (s *IMAPSession) Authenticate(mechanism string) (sasl.Server, error) {
 						}
 					}
 
+					s.recordLogin(loginAddr, AccountID, "")
+
 					// Trigger cache warmup for the authenticated user (if configured)
 					s.triggerCacheWarmup()
 
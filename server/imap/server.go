@@ -34,6 +34,7 @@ import (
 	serverPkg "github.com/migadu/sora/server"
 	"github.com/migadu/sora/server/idgen"
 	"github.com/migadu/sora/server/uploader"
+	"github.com/migadu/sora/server/webhook"
 	"github.com/migadu/sora/storage"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -242,8 +243,11 @@ type IMAPServer struct {
 	appendLimit        int64
 	ftsRetention       time.Duration
 	version            string
-	config             *config.Config       // Full config reference for shared mailboxes
-	spamTraining       *spamtraining.Client // Spam filter training client (optional)
+	config             *config.Config                     // Full config reference for shared mailboxes
+	spamTraining       *spamtraining.Client               // Spam filter training client (optional)
+	webhooks           *webhook.Dispatcher                // Outbound event webhooks (optional)
+	loginHistory       *serverPkg.LoginHistory            // Per-account login history (optional)
+	suspiciousLogins   *serverPkg.SuspiciousLoginDetector // New network/country/client alerts (optional)
 
 	// Metadata limits (RFC 5464)
 	metadataMaxEntrySize         int
@@ -385,9 +389,15 @@ type IMAPServerOptions struct {
 	Config *config.Config
 	// Spam training client (optional)
 	SpamTraining *spamtraining.Client
+	// Outbound event webhooks (optional)
+	Webhooks *webhook.Dispatcher
+	// Per-account login history (optional)
+	LoginHistory *serverPkg.LoginHistory
+	// Suspicious login detection (optional)
+	SuspiciousLogins *serverPkg.SuspiciousLoginDetector
 }
 
-func New(appCtx context.Context, name, hostname, imapAddr string, s3 *storage.S3Storage, rdb *resilient.ResilientDatabase, uploadWorker *uploader.UploadWorker, cache *cache.Cache, options IMAPServerOptions) (*IMAPServer, error) {
+func New(appCtx context.Context, name, hostname, imapAddr string, s3 storage.Backend, rdb *resilient.ResilientDatabase, uploadWorker *uploader.UploadWorker, cache *cache.Cache, options IMAPServerOptions) (*IMAPServer, error) {
 	logger.Debug("IMAP: Creating server", "name", name, "tls", options.TLS, "cert", options.TLSCertFile, "key", options.TLSKeyFile)
 	// Validate required dependencies
 	if s3 == nil {
@@ -544,6 +554,9 @@ func New(appCtx context.Context, name, hostname, imapAddr string, s3 *storage.S3
 		version:                      options.Version,
 		config:                       options.Config,
 		spamTraining:                 options.SpamTraining,
+		webhooks:                     options.Webhooks,
+		loginHistory:                 options.LoginHistory,
+		suspiciousLogins:             options.SuspiciousLogins,
 		metadataMaxEntrySize:         options.MetadataMaxEntrySize,
 		metadataMaxEntriesPerMailbox: options.MetadataMaxEntriesPerMailbox,
 		metadataMaxEntriesPerServer:  options.MetadataMaxEntriesPerServer,
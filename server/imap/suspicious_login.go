@@ -0,0 +1,29 @@
+package imap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/migadu/sora/pkg/metrics"
+	"github.com/migadu/sora/server"
+)
+
+// checkSuspiciousLogin runs the suspicious login checks for a login whose
+// credentials were accepted. It returns the error to send when the login must
+// be rejected until the user confirms its source.
+func (s *IMAPSession) checkSuspiciousLogin(ctx context.Context, address string, accountID int64) error {
+	err := s.server.suspiciousLogins.CheckLogin(ctx, s.loginRecord(address, accountID))
+	if !errors.Is(err, server.ErrLoginConfirmationRequired) {
+		return nil
+	}
+
+	s.InfoLog("login rejected until the new login source is confirmed", "address", address, "account_id", accountID)
+	metrics.AuthenticationAttempts.WithLabelValues("imap", s.server.name, s.server.hostname, "failure").Inc()
+	s.recordLogin(address, accountID, "confirmation required")
+	return &imap.Error{
+		Type: imap.StatusResponseTypeNo,
+		Code: imap.ResponseCodeAlert,
+		Text: "Login from a new location must be confirmed in your account settings",
+	}
+}
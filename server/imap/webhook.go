@@ -0,0 +1,24 @@
+package imap
+
+import "github.com/migadu/sora/server/webhook"
+
+// emitEvent sends a webhook event for the authenticated user. It is a no-op
+// when webhooks are disabled.
+func (s *IMAPSession) emitEvent(eventType string, data map[string]any) {
+	if s.server.webhooks == nil || s.IMAPUser == nil {
+		return
+	}
+	s.server.webhooks.Emit(webhook.NewEvent(eventType, "imap", s.IMAPUser.AccountID(), s.IMAPUser.Address.FullAddress(), data))
+}
+
+// emitLoginFailed sends a login.failed event. The account is not known, so
+// only the attempted address is reported.
+func (s *IMAPSession) emitLoginFailed(address, reason string) {
+	if s.server.webhooks == nil {
+		return
+	}
+	s.server.webhooks.Emit(webhook.NewEvent(webhook.EventLoginFailed, "imap", 0, address, map[string]any{
+		"remote_ip": s.RemoteIP,
+		"reason":    reason,
+	}))
+}
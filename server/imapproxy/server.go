@@ -558,6 +558,11 @@ func (s *Server) Start() error {
 		s.limiter.StartCleanup(s.ctx)
 	}
 
+	// Kick sessions off draining backends at their configured rate
+	if s.connManager != nil {
+		go s.connManager.RunDrainKicker(s.ctx)
+	}
+
 	// Startup throttle: spread reconnection load after proxy restart
 	// to prevent thundering herd on the database connection pool
 	s.startupThrottleUntil = time.Now().Add(30 * time.Second)
@@ -653,6 +658,10 @@ func (s *Server) SetConnectionTracker(tracker *server.ConnectionTracker) {
 	if tracker != nil && s.lookupCache != nil {
 		tracker.SetLookupCache(s.lookupCache)
 	}
+	// Per-backend session counts drive least-connections balancing and drain kicks
+	if tracker != nil && s.connManager != nil {
+		s.connManager.SetBackendSessions(tracker)
+	}
 }
 
 // GetConnectionTracker returns the connection tracker for testing
@@ -1682,7 +1682,7 @@ func (s *Session) updateActivityPeriodically(ctx context.Context) {
 
 	// Register for kick notifications
 	s.DebugLog("registering session for kick notifications")
-	kickChan := s.server.connTracker.RegisterSession(s.accountID)
+	kickChan := s.server.connTracker.RegisterBackendSession(s.accountID, s.serverAddr)
 	defer s.server.connTracker.UnregisterSession(s.accountID, kickChan)
 
 	for {
@@ -0,0 +1,78 @@
+package lmtp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+
+	"github.com/emersion/go-smtp"
+	"github.com/migadu/sora/server"
+	"github.com/migadu/sora/server/sieveengine"
+)
+
+// newDeliveryRecord starts the delivery log record of the current
+// transaction from its envelope.
+func (s *LMTPSession) newDeliveryRecord() server.DeliveryRecord {
+	rec := server.DeliveryRecord{
+		Sender: s.sender.FullAddress(),
+		Node:   s.backend.hostname,
+	}
+	switch {
+	case s.User != nil:
+		rec.AccountID = s.AccountID()
+		rec.Recipient = s.User.FullAddress()
+		if s.recipientAddr != nil {
+			rec.Recipient = s.recipientAddr.FullAddress()
+		}
+	case s.routeResolution != nil:
+		rec.Recipient = s.route
+	default:
+		rec.Recipient = s.srsBounceTo
+	}
+	return rec
+}
+
+// recordDelivery completes rec with the reply DATA returns and adds it to the
+// delivery log.
+func (s *LMTPSession) recordDelivery(rec *server.DeliveryRecord, err error) {
+	var smtpErr *smtp.SMTPError
+	switch {
+	case err == nil:
+		rec.Result = server.DeliveryDelivered
+		rec.Code = "250 2.0.0"
+	case errors.As(err, &smtpErr):
+		rec.Result = server.DeliveryRejected
+		if smtpErr.Code < 500 {
+			rec.Result = server.DeliveryDeferred
+		}
+		rec.Code = fmt.Sprint(smtpErr.Code)
+		if e := smtpErr.EnhancedCode; e != smtp.EnhancedCodeNotSet && e != smtp.NoEnhancedCode {
+			rec.Code += fmt.Sprintf(" %d.%d.%d", e[0], e[1], e[2])
+		}
+	default:
+		// go-smtp replies to other errors with 554 5.0.0
+		rec.Result = server.DeliveryRejected
+		rec.Code = "554 5.0.0"
+	}
+	s.backend.deliveryLog.Record(*rec)
+}
+
+// sieveActions describes the Sieve result for the delivery log.
+func sieveActions(result sieveengine.Result) []string {
+	action := string(result.Action)
+	if result.Copy {
+		action += " :copy"
+	}
+	return []string{action}
+}
+
+// headerMessageID returns the Message-ID of a raw message without angle
+// brackets, or "" if it has none.
+func headerMessageID(message []byte) string {
+	// A malformed header still returns the fields read before the error
+	header, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(message))).ReadMIMEHeader()
+	return strings.Trim(strings.TrimSpace(header.Get("Message-Id")), "<>")
+}
@@ -0,0 +1,77 @@
+package lmtp
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/migadu/sora/pkg/srs"
+	"github.com/migadu/sora/server"
+)
+
+type captureDeliveryStore struct {
+	mu      sync.Mutex
+	records []server.DeliveryRecord
+}
+
+func (c *captureDeliveryStore) RecordDeliveriesWithRetry(_ context.Context, records []server.DeliveryRecord) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.records = append(c.records, records...)
+	return nil
+}
+
+func TestDataRecordsDeliveryOutcome(t *testing.T) {
+	rw, err := srs.New("srs.example.com", []string{"secret"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &captureDeliveryStore{}
+	logCtx, stopLog := context.WithCancel(context.Background())
+	deliveryLog := server.NewDeliveryLog(store)
+	deliveryLog.Start(logCtx)
+
+	s := newSRSTestSession(t, rw, &captureRelayQueue{})
+	s.backend.hostname = "mx1.example.com"
+	s.backend.deliveryLog = deliveryLog
+	if err := s.sendToExternalRelay("alice@origin.example", "bob@elsewhere.example", []byte("Subject: hi\r\n\r\nhi\r\n")); err != nil {
+		t.Fatalf("sendToExternalRelay: %v", err)
+	}
+	srsSender := s.backend.relayQueue.(*captureRelayQueue).queued[0].from
+
+	ctx := context.Background()
+	transaction := func(message string) error {
+		t.Helper()
+		if err := s.Mail(ctx, "", nil); err != nil {
+			t.Fatalf("MAIL: %v", err)
+		}
+		if err := s.Rcpt(ctx, srsSender, nil); err != nil {
+			t.Fatalf("RCPT: %v", err)
+		}
+		return s.Data(ctx, strings.NewReader(message))
+	}
+
+	if err := transaction("Message-ID: <dsn-1@elsewhere.example>\r\nSubject: Undelivered Mail\r\n\r\nbounce\r\n"); err != nil {
+		t.Fatalf("DATA: %v", err)
+	}
+	s.backend.maxMessageSize = 10
+	if err := transaction("Subject: too large\r\n\r\nbounce\r\n"); err == nil {
+		t.Fatal("DATA over the size limit accepted")
+	}
+
+	stopLog()
+	deliveryLog.Wait()
+	if len(store.records) != 2 {
+		t.Fatalf("recorded %d deliveries, want 2", len(store.records))
+	}
+	delivered, rejected := store.records[0], store.records[1]
+	if delivered.Result != server.DeliveryDelivered || delivered.Code != "250 2.0.0" ||
+		delivered.MessageID != "dsn-1@elsewhere.example" || delivered.Recipient != "alice@origin.example" ||
+		delivered.Sender != "" || delivered.Node != "mx1.example.com" || delivered.Size == 0 {
+		t.Errorf("delivered record = %+v", delivered)
+	}
+	if rejected.Result != server.DeliveryRejected || rejected.Code != "552 5.3.4" {
+		t.Errorf("rejected record = %+v", rejected)
+	}
+}
@@ -0,0 +1,132 @@
+package lmtp
+
+import (
+	"context"
+	"errors"
+
+	"github.com/emersion/go-smtp"
+	"github.com/migadu/sora/server/delivery"
+)
+
+// rcptRoute resolves a recipient that is not a login credential through the
+// mail routes. A recipient that resolves to exactly one account (a catch-all,
+// an address on an aliased domain) returns the account and is then accepted
+// like the account's own address. Any other route is stored on the session and
+// delivered at DATA, and 0 is returned.
+func (s *LMTPSession) rcptRoute(ctx context.Context, address string) (int64, error) {
+	resolution, err := delivery.ResolveRecipient(ctx, s.backend.rdb, address)
+	if err != nil {
+		switch {
+		case errors.Is(err, delivery.ErrRecipientNotFound):
+			// User not found or account deleted - permanent failure
+			s.DebugLog("user not found", "address", address)
+			return 0, &smtp.SMTPError{
+				Code:         550,
+				EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+				Message:      "No such user here",
+			}
+		case errors.Is(err, delivery.ErrRouteTooDeep):
+			s.WarnLog("rejecting recipient", "address", address, "error", err)
+			return 0, &smtp.SMTPError{
+				Code:         550,
+				EnhancedCode: smtp.EnhancedCode{5, 4, 6},
+				Message:      "Routing loop detected",
+			}
+		}
+		// Database error (connection failure, timeout, etc.) - temporary failure
+		s.WarnLog("database error during route lookup", "address", address, "error", err)
+		return 0, &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 4, 3},
+			Message:      "Temporary failure, please try again later",
+		}
+	}
+
+	if account, ok := resolution.Single(); ok {
+		s.DebugLog("recipient routed to account", "to", address, "target", account.Address, "account_id", account.AccountID)
+		return account.AccountID, nil
+	}
+	if len(resolution.Forwards) > 0 && s.backend.relayQueue == nil {
+		s.WarnLog("route has external targets but relay queue not configured", "to", address)
+		return 0, &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 5},
+			Message:      "Cannot forward message, please try again later",
+		}
+	}
+
+	acquired, release := s.mutexHelper.AcquireWriteLockWithTimeout(ctx)
+	if !acquired {
+		s.WarnLog("failed to acquire write lock", "command", "RCPT")
+		return 0, &smtp.SMTPError{
+			Code:         421,
+			EnhancedCode: smtp.EnhancedCode{4, 4, 5},
+			Message:      "Server busy, try again later",
+		}
+	}
+	defer release()
+
+	s.User = nil
+	s.recipientAddr = nil
+	s.recipientDomain = ""
+	s.srsBounceTo = ""
+	s.route = address
+	s.routeResolution = resolution
+	s.useMasterDB = true
+
+	s.DebugLog("routed recipient accepted", "to", address, "accounts", len(resolution.Accounts), "forwards", len(resolution.Forwards))
+	return 0, nil
+}
+
+// deliverToRoute delivers a message for a routed recipient through the shared
+// delivery path (the same one the Admin API uses): each local account gets a
+// copy filtered by its own Sieve script, or filed into its Junk mailbox when
+// the content scanner says junk, and external targets get a copy through the
+// relay queue.
+func (s *LMTPSession) deliverToRoute(ctx context.Context, message []byte, junk bool) error {
+	logger := &lmtpDeliveryLogger{s: s}
+	deliveryCtx := &delivery.DeliveryContext{
+		Ctx:           ctx,
+		RDB:           s.backend.rdb,
+		Uploader:      s.backend.uploader,
+		Hostname:      s.backend.hostname,
+		FTSRetention:  s.backend.ftsRetention,
+		MetricsLabel:  "lmtp",
+		Logger:        logger,
+		OwnerResolver: s.ownerResolver,
+		Junk:          junk,
+	}
+	deliveryCtx.SieveExecutor = &delivery.StandardSieveExecutor{
+		DeliveryCtx:    deliveryCtx,
+		VacationOracle: &delivery.VacationOracle{RDB: s.backend.rdb},
+		VacationHandler: &delivery.StandardVacationHandler{
+			Hostname:       s.backend.hostname,
+			RelayQueue:     s.backend.relayQueue,
+			Logger:         logger,
+			IsOwnedAddress: s.backend.rdb.IsAddressOwnedByAccountWithRetry,
+			RelayNotify:    s.notifyRelayWorker,
+		},
+		RelayQueue:         s.backend.relayQueue,
+		SRS:                s.backend.srs,
+		ARCSealer:          s.backend.arcSealer,
+		RedirectRateLimit:  s.backend.redirectRateLimit,
+		RedirectRateWindow: s.backend.redirectRateWindow,
+		MaxRedirectHops:    s.backend.maxRedirectHops,
+		SieveExtensions:    s.backend.sieveExtensions,
+	}
+	forwarder := &delivery.Forwarder{
+		RelayQueue: s.backend.relayQueue,
+		SRS:        s.backend.srs,
+		ARCSealer:  s.backend.arcSealer,
+		MaxHops:    s.backend.maxRedirectHops,
+		Logger:     logger,
+	}
+
+	if err := deliveryCtx.DeliverToRoute(ctx, s.route, s.routeResolution, s.sender, forwarder, message); err != nil {
+		return s.InternalError("failed to deliver to %s: %v", s.route, err)
+	}
+	s.notifyRelayWorker()
+	s.InfoLog("delivered to routed recipient", "to", s.route,
+		"accounts", len(s.routeResolution.Accounts), "forwards", len(s.routeResolution.Forwards))
+	return nil
+}
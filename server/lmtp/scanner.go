@@ -0,0 +1,80 @@
+package lmtp
+
+import (
+	"context"
+	"strings"
+
+	"github.com/emersion/go-smtp"
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/pkg/scanner"
+	"github.com/migadu/sora/server"
+)
+
+// scanMessage runs the content scanner over a message before any copy of it
+// is stored or routed. It returns the message with the verdict's headers and
+// whether it goes to Junk. Reject and tempfail verdicts are returned as the
+// DATA reply; a quarantined message is accepted and done is set.
+func (s *LMTPSession) scanMessage(ctx context.Context, rec *server.DeliveryRecord, message []byte) (out []byte, junk, done bool, err error) {
+	env := scanner.Envelope{
+		From: s.sender.FullAddress(),
+		Rcpt: []string{rec.Recipient},
+		IP:   s.RemoteIP,
+		Helo: s.clientHelo(),
+	}
+	verdict := s.backend.scanner.Scan(ctx, env, message)
+
+	switch verdict.Action {
+	case scanner.ActionReject:
+		s.InfoLog("message rejected by content scanner", "reason", verdict.Reason, "score", verdict.Score)
+		msg := "Message rejected by content filter"
+		if verdict.Reason != "" {
+			msg += ": " + strings.Join(strings.Fields(verdict.Reason), " ")
+		}
+		return nil, false, false, &smtp.SMTPError{
+			Code:         550,
+			EnhancedCode: smtp.EnhancedCode{5, 7, 1},
+			Message:      msg,
+		}
+
+	case scanner.ActionTempFail:
+		s.InfoLog("message deferred by content scanner", "reason", verdict.Reason, "score", verdict.Score)
+		return nil, false, false, &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 7, 1},
+			Message:      "Message deferred by content filter, try again later",
+		}
+	}
+
+	// Prepend in reverse so the headers end up in the scanner's order
+	for i := len(verdict.Headers) - 1; i >= 0; i-- {
+		message = helpers.PrependHeaderLine(message, verdict.Headers[i].Name, verdict.Headers[i].Value)
+	}
+
+	switch verdict.Action {
+	case scanner.ActionQuarantine:
+		id, err := s.backend.scanner.Quarantine(env, rec.MessageID, verdict, message)
+		if err != nil {
+			return nil, false, false, s.InternalError("failed to quarantine message: %v", err)
+		}
+		s.InfoLog("message quarantined by content scanner", "quarantine_id", id, "reason", verdict.Reason, "score", verdict.Score)
+		rec.SieveActions = []string{"quarantine"}
+		return message, false, true, nil
+
+	case scanner.ActionJunk:
+		s.InfoLog("message classified as junk by content scanner", "score", verdict.Score)
+		return message, true, false, nil
+	}
+	return message, false, false, nil
+}
+
+// clientHelo returns the HELO name of the sending client: the one forwarded
+// over XCLIENT by a front proxy, or else the connection's LHLO name.
+func (s *LMTPSession) clientHelo() string {
+	if s.ForwardingParams != nil && s.ForwardingParams.HELO != "" {
+		return s.ForwardingParams.HELO
+	}
+	if s.conn != nil {
+		return s.conn.Hostname()
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package lmtp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-smtp"
+	"github.com/migadu/sora/config"
+	"github.com/migadu/sora/pkg/scanner"
+	"github.com/migadu/sora/server"
+)
+
+func newScannerTestSession(t *testing.T, action, quarantineDir string) *LMTPSession {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Rcpt") != "bob@example.com" || r.Header.Get("From") != "alice@example.org" {
+			http.Error(w, "bad envelope", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"action":"` + action + `","score":11,"required_score":15,"messages":{"smtp_message":"spam\r\ndetected"}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := scanner.NewClient(&config.ScannerConfig{
+		Enabled:           true,
+		Type:              "rspamd",
+		Address:           srv.URL,
+		FailMode:          "closed",
+		QuarantineActions: []string{"quarantine"},
+		QuarantinePath:    quarantineDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := server.NewAddress("alice@example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &LMTPSession{
+		backend: &LMTPServerBackend{scanner: client},
+		ctx:     context.Background(),
+		sender:  &sender,
+	}
+}
+
+func TestScanMessageVerdicts(t *testing.T) {
+	message := []byte("Subject: hi\r\n\r\nhello\r\n")
+
+	tests := []struct {
+		action   string
+		code     int
+		enhanced smtp.EnhancedCode
+		junk     bool
+		done     bool
+	}{
+		{action: "no action"},
+		{action: "add header", junk: true},
+		{action: "reject", code: 550, enhanced: smtp.EnhancedCode{5, 7, 1}},
+		{action: "soft reject", code: 451, enhanced: smtp.EnhancedCode{4, 7, 1}},
+		{action: "quarantine", done: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			dir := t.TempDir()
+			s := newScannerTestSession(t, tt.action, dir)
+			rec := server.DeliveryRecord{Recipient: "bob@example.com", MessageID: "m1@example.org"}
+
+			out, junk, done, err := s.scanMessage(context.Background(), &rec, message)
+			if tt.code != 0 {
+				var smtpErr *smtp.SMTPError
+				if !errors.As(err, &smtpErr) || smtpErr.Code != tt.code || smtpErr.EnhancedCode != tt.enhanced {
+					t.Fatalf("err = %v, want %d %v", err, tt.code, tt.enhanced)
+				}
+				if strings.ContainsAny(smtpErr.Message, "\r\n") {
+					t.Errorf("reply text %q contains a line break", smtpErr.Message)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("scanMessage: %v", err)
+			}
+			if junk != tt.junk || done != tt.done {
+				t.Errorf("junk, done = %v, %v, want %v, %v", junk, done, tt.junk, tt.done)
+			}
+			if !strings.HasPrefix(string(out), "X-Spam: ") || !strings.HasSuffix(string(out), string(message)) {
+				t.Errorf("scanned message = %q", out)
+			}
+
+			quarantined, _ := filepath.Glob(filepath.Join(dir, "*", "*.eml"))
+			if tt.done != (len(quarantined) == 1) {
+				t.Errorf("quarantined files = %v", quarantined)
+			}
+			if tt.done && (len(rec.SieveActions) != 1 || rec.SieveActions[0] != "quarantine") {
+				t.Errorf("delivery log actions = %v", rec.SieveActions)
+			}
+		})
+	}
+}
@@ -18,11 +18,14 @@ import (
 	"github.com/emersion/go-smtp"
 	"github.com/migadu/sora/pkg/metrics"
 	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/pkg/scanner"
+	"github.com/migadu/sora/pkg/srs"
 	"github.com/migadu/sora/server"
 	"github.com/migadu/sora/server/delivery"
 	"github.com/migadu/sora/server/idgen"
 	"github.com/migadu/sora/server/sieveengine"
 	"github.com/migadu/sora/server/uploader"
+	"github.com/migadu/sora/server/webhook"
 	"github.com/migadu/sora/storage"
 )
 
@@ -121,7 +124,7 @@ type LMTPServerBackend struct {
 	name           string
 	hostname       string
 	rdb            *resilient.ResilientDatabase
-	s3             *storage.S3Storage
+	s3             storage.Backend
 	uploader       *uploader.UploadWorker
 	server         *smtp.Server
 	appCtx         context.Context
@@ -131,6 +134,11 @@ type LMTPServerBackend struct {
 	maxMessageSize int64               // Maximum size for incoming messages
 	relayQueue     delivery.RelayQueue // Disk-based queue for relay retry
 	relayWorker    RelayWorkerNotifier // Optional: notifies worker for immediate processing
+	srs            *srs.Rewriter       // Optional: SRS for redirects and bounces to SRS addresses
+	arcSealer      delivery.ARCSealer  // Optional: ARC-seals redirects
+	webhooks       *webhook.Dispatcher // Optional: outbound event webhooks
+	deliveryLog    *server.DeliveryLog // Optional: per-message delivery log
+	scanner        *scanner.Client     // Optional: pre-delivery content scanner
 
 	redirectRateLimit  int
 	redirectRateWindow time.Duration
@@ -160,6 +168,7 @@ type LMTPServerBackend struct {
 	// Sieve script caching
 	sieveCache           *SieveScriptCache
 	defaultSieveExecutor sieveengine.Executor
+	sieveExtensions      []string // enabled extensions, also for managed vacation
 
 	// PROXY protocol support
 	proxyReader *server.ProxyProtocolReader
@@ -173,6 +182,8 @@ type RelayWorkerNotifier interface {
 type LMTPServerOptions struct {
 	RelayQueue                  delivery.RelayQueue // Global relay queue for Sieve redirect/vacation
 	RelayWorker                 RelayWorkerNotifier // Optional: notifies worker for immediate processing
+	SRS                         *srs.Rewriter       // Optional: Sender Rewriting Scheme for redirects (nil = disabled)
+	ARCSealer                   delivery.ARCSealer  // Optional: ARC-seals redirects (nil = disabled)
 	Debug                       bool
 	TLS                         bool
 	TLSCertFile                 string
@@ -196,9 +207,12 @@ type LMTPServerOptions struct {
 	MaxRedirectHops             int
 	CommandTimeoutOverrides     map[string]time.Duration // Per-command hard execution timeouts (overrides defaults)
 	IdleTimeout                 time.Duration            // Maximum idle time between commands (0 = default 5m); enforced by go-smtp with a 421 notice
+	Webhooks                    *webhook.Dispatcher      // Optional: outbound event webhooks (nil = disabled)
+	DeliveryLog                 *server.DeliveryLog      // Optional: per-message delivery log (nil = disabled)
+	Scanner                     *scanner.Client          // Optional: pre-delivery content scanner (nil = disabled)
 }
 
-func New(appCtx context.Context, name, hostname, addr string, s3 *storage.S3Storage, rdb *resilient.ResilientDatabase, uploadWorker *uploader.UploadWorker, options LMTPServerOptions) (*LMTPServerBackend, error) {
+func New(appCtx context.Context, name, hostname, addr string, s3 storage.Backend, rdb *resilient.ResilientDatabase, uploadWorker *uploader.UploadWorker, options LMTPServerOptions) (*LMTPServerBackend, error) {
 	// Initialize PROXY protocol reader if enabled
 	var proxyReader *server.ProxyProtocolReader
 	if options.ProxyProtocol {
@@ -240,6 +254,11 @@ func New(appCtx context.Context, name, hostname, addr string, s3 *storage.S3Stor
 		proxyReader:        proxyReader,
 		relayQueue:         options.RelayQueue,
 		relayWorker:        options.RelayWorker,
+		srs:                options.SRS,
+		arcSealer:          options.ARCSealer,
+		webhooks:           options.Webhooks,
+		deliveryLog:        options.DeliveryLog,
+		scanner:            options.Scanner,
 		redirectRateLimit:  options.RedirectRateLimit,
 		redirectRateWindow: options.RedirectRateWindow,
 		maxRedirectHops:    options.MaxRedirectHops,
@@ -287,6 +306,7 @@ func New(appCtx context.Context, name, hostname, addr string, s3 *storage.S3Stor
 		return nil, fmt.Errorf("failed to parse default Sieve script: %w", err)
 	}
 	backend.defaultSieveExecutor = defaultExecutor
+	backend.sieveExtensions = defaultExtensions
 	logger.Debug("default sieve script parsed and cached", "name", backend.name)
 
 	// Set up TLS config: Support both file-based certificates and global TLS manager
@@ -354,6 +374,11 @@ func New(appCtx context.Context, name, hostname, addr string, s3 *storage.S3Stor
 	// Configure XCLIENT support (always enabled)
 	s.EnableXCLIENT = true
 
+	// BDAT is always advertised (CHUNKING); BINARYMIME bodies can only be
+	// sent with it. SMTPUTF8 allows UTF-8 local parts (see server.NewAddress).
+	s.EnableBINARYMIME = true
+	s.EnableSMTPUTF8 = true
+
 	// Configure XRCPTFORWARD support (always enabled)
 	// Enable custom RCPT TO parameters like XRCPTFORWARD
 	s.EnableRCPTExtensions = true
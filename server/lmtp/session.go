@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -23,10 +24,12 @@ import (
 	"github.com/migadu/sora/helpers"
 	"github.com/migadu/sora/pkg/metrics"
 	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/pkg/srs"
 	"github.com/migadu/sora/server"
 	"github.com/migadu/sora/server/delivery"
 	"github.com/migadu/sora/server/idgen"
 	"github.com/migadu/sora/server/sieveengine"
+	"github.com/migadu/sora/server/webhook"
 )
 
 //go:embed default.sieve
@@ -38,8 +41,14 @@ func (s *LMTPSession) sendToExternalRelay(from string, to string, message []byte
 		return fmt.Errorf("relay queue not configured")
 	}
 
+	// Rewrite the envelope sender (SRS) so the copy passes SPF/DMARC
+	from, err := delivery.RedirectSender(s.backend.srs, from)
+	if err != nil {
+		s.WarnLog("failed to rewrite redirect sender", "from", from, "error", err)
+	}
+
 	// Queue the message for background delivery
-	err := s.backend.relayQueue.Enqueue(from, to, "redirect", message)
+	err = s.backend.relayQueue.Enqueue(from, to, "redirect", message)
 	if err != nil {
 		return fmt.Errorf("failed to enqueue relay message: %w", err)
 	}
@@ -52,21 +61,34 @@ func (s *LMTPSession) sendToExternalRelay(from string, to string, message []byte
 	return nil
 }
 
+// errSMTPUTF8Required rejects UTF-8 addresses in a transaction whose MAIL FROM
+// did not carry SMTPUTF8 (RFC 6531 §3.5).
+var errSMTPUTF8Required = &smtp.SMTPError{
+	Code:         553,
+	EnhancedCode: smtp.EnhancedCode{5, 6, 7},
+	Message:      "UTF-8 addresses require SMTPUTF8",
+}
+
 // LMTPSession represents a single LMTP session.
 type LMTPSession struct {
 	server.Session
-	backend       *LMTPServerBackend
-	sender        *server.Address
-	recipientAddr *server.Address // Original recipient address (may include +detail)
-	conn          *smtp.Conn
-	cancel        context.CancelFunc
-	ctx           context.Context
-	ownerResolver *resilient.OwnerResolver
-	mutex         sync.RWMutex
-	mutexHelper   *server.MutexTimeoutHelper
-	releaseConn   func() // Function to release connection from limiter
-	useMasterDB   bool   // Pin session to master DB after a write to ensure consistency
-	startTime     time.Time
+	backend         *LMTPServerBackend
+	sender          *server.Address
+	smtputf8        bool            // MAIL FROM carried SMTPUTF8: UTF-8 addresses are allowed
+	recipientAddr   *server.Address // Original recipient address (may include +detail)
+	recipientDomain string          // Domain the recipient was addressed at, for its delivery settings
+	srsBounceTo     string          // Decoded SRS recipient: the message is returned there via the relay queue
+	route           string          // Routed recipient (group, ...) delivered to routeResolution at DATA
+	routeResolution *delivery.Resolution
+	conn            *smtp.Conn
+	cancel          context.CancelFunc
+	ctx             context.Context
+	ownerResolver   *resilient.OwnerResolver
+	mutex           sync.RWMutex
+	mutexHelper     *server.MutexTimeoutHelper
+	releaseConn     func() // Function to release connection from limiter
+	useMasterDB     bool   // Pin session to master DB after a write to ensure consistency
+	startTime       time.Time
 }
 
 func (s *LMTPSession) Mail(ctx context.Context, from string, opts *smtp.MailOptions) error {
@@ -100,6 +122,12 @@ func (s *LMTPSession) Mail(ctx context.Context, from string, opts *smtp.MailOpti
 		}
 		s.DebugLog("mail from accepted", "from", fromAddress.FullAddress())
 	}
+	utf8 := opts != nil && opts.UTF8
+	if !utf8 && !server.IsASCII(from) {
+		s.WarnLog("UTF-8 sender without SMTPUTF8", "from", from)
+		recordMetrics("failure")
+		return errSMTPUTF8Required
+	}
 
 	// Acquire write lock to update sender
 	acquired, release := s.mutexHelper.AcquireWriteLockWithTimeout(ctx)
@@ -115,6 +143,7 @@ func (s *LMTPSession) Mail(ctx context.Context, from string, opts *smtp.MailOpti
 	defer release()
 
 	s.sender = &fromAddress
+	s.smtputf8 = utf8
 
 	recordMetrics("success")
 	return nil
@@ -145,6 +174,21 @@ func (s *LMTPSession) Rcpt(ctx context.Context, to string, opts *smtp.RcptOption
 			Message:      "Invalid recipient",
 		}
 	}
+	if !s.smtputf8 && !server.IsASCII(to) {
+		s.WarnLog("UTF-8 recipient without SMTPUTF8", "to", to)
+		recordMetrics("failure")
+		return errSMTPUTF8Required
+	}
+	// Bounces to the SRS sender of a redirected message go back to the original sender
+	if s.backend.srs != nil && srs.IsSRS(to) && strings.EqualFold(toAddress.Domain(), s.backend.srs.Domain()) {
+		if err := s.rcptSRS(to); err != nil {
+			recordMetrics("failure")
+			return err
+		}
+		recordMetrics("success")
+		return nil
+	}
+
 	fullAddress := toAddress.FullAddress()
 	lookupAddress := toAddress.BaseAddress()
 
@@ -168,23 +212,25 @@ func (s *LMTPSession) Rcpt(ctx context.Context, to string, opts *smtp.RcptOption
 	// Look up account ID by credential address (excluding deleted accounts)
 	AccountID, err := s.backend.rdb.GetActiveAccountIDByAddressWithRetry(readCtx, lookupAddress)
 	if err != nil {
-		if errors.Is(err, consts.ErrUserNotFound) {
-			// User not found or account deleted - permanent failure
-			s.DebugLog("user not found", "address", lookupAddress)
+		if !errors.Is(err, consts.ErrUserNotFound) {
+			// Database error (connection failure, timeout, etc.) - temporary failure
+			s.WarnLog("database error during user lookup", "address", lookupAddress, "error", err)
 			recordMetrics("failure")
 			return &smtp.SMTPError{
-				Code:         550,
-				EnhancedCode: smtp.EnhancedCode{5, 1, 1},
-				Message:      "No such user here",
+				Code:         451,
+				EnhancedCode: smtp.EnhancedCode{4, 4, 3},
+				Message:      "Temporary failure, please try again later",
 			}
 		}
-		// Database error (connection failure, timeout, etc.) - temporary failure
-		s.WarnLog("database error during user lookup", "address", lookupAddress, "error", err)
-		recordMetrics("failure")
-		return &smtp.SMTPError{
-			Code:         451,
-			EnhancedCode: smtp.EnhancedCode{4, 4, 3},
-			Message:      "Temporary failure, please try again later",
+		// Not a login credential: groups, catch-alls and domain aliases
+		AccountID, err = s.rcptRoute(readCtx, lookupAddress)
+		if err != nil {
+			recordMetrics("failure")
+			return err
+		}
+		if AccountID == 0 {
+			recordMetrics("success")
+			return nil
 		}
 	}
 
@@ -259,6 +305,9 @@ func (s *LMTPSession) Rcpt(ctx context.Context, to string, opts *smtp.RcptOption
 	}
 	defer release()
 	s.User = server.NewUser(primaryAddr, AccountID) // Always use primary address
+	s.srsBounceTo = ""
+	s.route = ""
+	s.routeResolution = nil
 
 	// Construct envelope recipient address for Sieve:
 	// - If original recipient has +detail, preserve it but use primary address domain
@@ -275,6 +324,7 @@ func (s *LMTPSession) Rcpt(ctx context.Context, to string, opts *smtp.RcptOption
 		}
 	}
 	s.recipientAddr = &envelopeRecipient // Store for Sieve envelope (with +detail preserved on primary address)
+	s.recipientDomain = toAddress.Domain()
 
 	// Pin the session to the master DB to prevent reading stale data from a replica.
 	s.useMasterDB = true
@@ -289,7 +339,7 @@ func (s *LMTPSession) Rcpt(ctx context.Context, to string, opts *smtp.RcptOption
 	return nil
 }
 
-func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
+func (s *LMTPSession) Data(ctx context.Context, r io.Reader) (retErr error) {
 	// Prometheus metrics - start delivery timing
 	start := time.Now()
 	recordMetrics := func(status string) {
@@ -311,7 +361,7 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 	defer release()
 
 	// Check if we have a valid sender and recipient
-	if s.sender == nil || s.User == nil {
+	if s.sender == nil || (s.User == nil && s.srsBounceTo == "" && s.routeResolution == nil) {
 		s.WarnLog("data command without valid sender or recipient")
 		recordMetrics("failure")
 		return &smtp.SMTPError{
@@ -321,6 +371,10 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 		}
 	}
 
+	// Every outcome from here on is added to the delivery log
+	rec := s.newDeliveryRecord()
+	defer func() { s.recordDelivery(&rec, retErr) }()
+
 	var buf bytes.Buffer
 
 	// Enforce message size limit BEFORE reading into memory to prevent DoS
@@ -335,6 +389,7 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 	reader := io.LimitReader(r, limitToUse+1)
 
 	_, err := io.Copy(&buf, reader)
+	rec.Size = int64(buf.Len())
 	if err != nil {
 		// Read errors during DATA command:
 		// - unexpected EOF: client disconnected, incomplete transmission, or malformed message stream
@@ -391,12 +446,47 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 			Message:      "empty message rejected: a message must contain at least headers",
 		}
 	}
+	rec.MessageID = headerMessageID(fullMessageBytes)
+
+	if s.srsBounceTo != "" {
+		if err := s.returnSRSBounce(s.sender.FullAddress(), fullMessageBytes); err != nil {
+			recordMetrics("failure")
+			return err
+		}
+		recordMetrics("success")
+		return nil
+	}
 
 	// Prometheus metrics
 	metrics.MessageSizeBytes.WithLabelValues("lmtp").Observe(float64(len(fullMessageBytes)))
 	metrics.BytesThroughput.WithLabelValues("lmtp", "in").Add(float64(len(fullMessageBytes)))
 	metrics.MessageThroughput.WithLabelValues("lmtp", "received", "success").Inc()
 
+	// The content scanner sees the message once, before any copy is stored or
+	// routed, and its verdict applies to every recipient
+	junk := false
+	if s.backend.scanner != nil {
+		var done bool
+		fullMessageBytes, junk, done, err = s.scanMessage(ctx, &rec, fullMessageBytes)
+		if err != nil {
+			recordMetrics("failure")
+			return err
+		}
+		if done {
+			recordMetrics("success")
+			return nil
+		}
+	}
+
+	if s.routeResolution != nil {
+		if err := s.deliverToRoute(ctx, fullMessageBytes, junk); err != nil {
+			recordMetrics("failure")
+			return err
+		}
+		recordMetrics("success")
+		return nil
+	}
+
 	// Warn if headers are not clearly separated from the body. This might
 	// indicate a malformed email or an email with only headers and no separator.
 	if !bytes.Contains(fullMessageBytes, []byte("\r\n\r\n")) {
@@ -438,14 +528,8 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 		// is already rewritten to the client IP by the XCLIENT/PROXY-protocol handlers; do the
 		// same for the HELO here, since s.conn.Hostname() only ever holds the proxy's LHLO name.
 		// Falls back to the connection's LHLO name for direct (non-proxied) deliveries.
-		helo := ""
-		if s.ForwardingParams != nil && s.ForwardingParams.HELO != "" {
-			helo = s.ForwardingParams.HELO
-		} else if s.conn != nil {
-			helo = s.conn.Hostname()
-		}
 		received := helpers.BuildReceivedHeader(
-			helpers.ReceivedFrom(helo, s.RemoteIP),
+			helpers.ReceivedFrom(s.clientHelo(), s.RemoteIP),
 			s.backend.hostname, "LMTP", deliveredTo, idgen.New(), time.Now().Format(time.RFC1123Z))
 		fullMessageBytes = helpers.PrependRawHeader(fullMessageBytes, received)
 		fullMessageBytes = helpers.PrependHeaderLine(fullMessageBytes, helpers.DeliveredToHeader, deliveredTo)
@@ -535,11 +619,16 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 		EnvelopeTo:   envelopeTo,
 		Header:       messageContent.Header.Map(),
 		Body:         *plaintextBody,
+		Raw:          fullMessageBytes,
 	}
 
-	// Always run the default script first as a "before script"
-	// Use the pre-parsed default executor from the backend
-	if s.backend.defaultSieveExecutor != nil {
+	// Junk skips Sieve and vacation replies altogether: the scanner's verdict
+	// decides where the message goes. Otherwise always run the default script
+	// first as a "before script", using the pre-parsed default executor.
+	if junk {
+		result = sieveengine.Result{Action: sieveengine.ActionFileInto, Mailbox: delivery.JunkMailbox(readCtx, s.backend.rdb, s.AccountID())}
+		s.InfoLog("content scanner junk - skipping sieve", "mailbox", result.Mailbox)
+	} else if s.backend.defaultSieveExecutor != nil {
 		// SIEVE debugging information
 		if s.backend.debug {
 			s.DebugLog("sieve message headers for evaluation")
@@ -581,7 +670,7 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 	}
 
 	// If user has an active script, run it and let it override the resultAction
-	if err == nil && activeScript != nil {
+	if !junk && err == nil && activeScript != nil {
 		s.InfoLog("using user sieve script", "name", activeScript.Name, "script_id", activeScript.ID, "updated_at", activeScript.UpdatedAt.Format(time.RFC3339))
 		// Try to get the user script from cache or create and cache it with metadata validation
 		userSieveExecutor, userScriptErr := s.backend.sieveCache.GetOrCreateWithMetadata(
@@ -633,7 +722,7 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 				}
 			}
 		}
-	} else {
+	} else if !junk {
 		if err != nil && err != consts.ErrDBNotFound {
 			s.DebugLog("failed to get active sieve script", "error", err)
 		} else {
@@ -641,6 +730,44 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 		}
 	}
 
+	// The out-of-office reply from the account's vacation settings (User and
+	// Admin APIs) runs next to the user's script, so filing or forwarding rules
+	// do not silence it. A discarded message gets no reply.
+	if !junk && result.Action != sieveengine.ActionDiscard && result.Action != sieveengine.ActionVacation {
+		if vacation, ok := delivery.EvaluateManagedVacation(readCtx, s.backend.rdb, s.AccountID(), sieveVacOracle, sieveCtx, s.backend.sieveExtensions); ok {
+			s.InfoLog("managed vacation response triggered")
+			if err := s.handleVacationResponse(ctx, vacation, messageContent); err != nil {
+				s.DebugLog("error handling vacation response", "error", err)
+			}
+			rec.SieveActions = append(rec.SieveActions, "vacation")
+		}
+	}
+	rec.SieveActions = append(sieveActions(result), rec.SieveActions...)
+
+	// Replace the message if the script rewrote it (RFC 5703 - replace/enclose).
+	// The body changed, so everything derived from it is recomputed.
+	if result.Message != nil {
+		s.DebugLog("applying sieve message rewrite", "size", len(result.Message))
+		rewritten, err := server.ParseMessage(bytes.NewReader(result.Message))
+		if err != nil {
+			s.WarnLog("failed to parse rewritten message, keeping original", "error", err)
+		} else {
+			fullMessageBytes = result.Message
+			contentHash = helpers.HashContent(fullMessageBytes)
+			mailHeader = mail.Header{Header: rewritten.Header}
+			subject, _ = mailHeader.Subject()
+			recipients = helpers.ExtractRecipients(rewritten.Header)
+			bodyStructureVal = imapserver.ExtractBodyStructure(bytes.NewReader(fullMessageBytes))
+			if text, _ := helpers.ExtractPlaintextBody(rewritten); text != nil {
+				plaintextBody = text
+			}
+			if messageContent, err = server.ParseMessage(bytes.NewReader(fullMessageBytes)); err != nil {
+				recordMetrics("failure")
+				return s.InternalError("failed to re-parse rewritten message: %v", err)
+			}
+		}
+	}
+
 	// Apply header edits if any (RFC 5293 - editheader extension)
 	if len(result.HeaderEdits) > 0 {
 		s.DebugLog("applying header edits", "count", len(result.HeaderEdits))
@@ -736,6 +863,7 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 
 	case sieveengine.ActionFileInto:
 		mailboxName = result.Mailbox
+		rec.Mailbox = mailboxName
 		if result.Copy {
 			s.InfoLog("sieve fileinto :copy - saving to both mailbox and inbox", "mailbox", mailboxName)
 
@@ -787,7 +915,11 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 			// Stamp the outgoing copy with an incremented hop count (loop backstop).
 			hops := helpers.RedirectHopCount(helpers.HeaderGetter(messageContent.Header.Map()))
 			relayBytes := helpers.PrependHeaderLine(fullMessageBytes, helpers.RedirectLoopHeader, strconv.Itoa(hops+1))
-			err := s.sendToExternalRelay(s.sender.FullAddress(), result.RedirectTo, relayBytes)
+			relayBytes, err := delivery.SealRedirect(s.backend.arcSealer, s.User.Domain(), relayBytes)
+			if err != nil {
+				s.WarnLog("failed to ARC-seal redirect", "error", err)
+			}
+			err = s.sendToExternalRelay(s.sender.FullAddress(), result.RedirectTo, relayBytes)
 			if err != nil {
 				s.DebugLog("error enqueuing redirected message, falling back to inbox", "error", err)
 				// Continue processing even if queue fails, store in INBOX as fallback
@@ -817,17 +949,18 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 			s.DebugLog("error handling vacation response", "error", err)
 			// Continue processing even if vacation response fails
 		}
-		// Store the original message in INBOX
-		mailboxName = consts.MailboxInbox
+		// Store the original message in INBOX (or its +detail mailbox)
+		mailboxName = s.keepMailbox(readCtx)
 
 	default:
 		s.DebugLog("sieve keep action")
-		mailboxName = consts.MailboxInbox
+		mailboxName = s.keepMailbox(readCtx)
 	}
 
 	// Save the message to the determined mailbox (either the specified one or INBOX)
 	// For fileinto actions without :copy, pass the :create flag if specified
 	createMailbox := result.Action == sieveengine.ActionFileInto && result.CreateMailbox
+	rec.Mailbox = mailboxName
 	err = s.saveMessageToMailbox(ctx, mailboxName, fullMessageBytes, contentHash,
 		subject, messageID, sentDate, inReplyTo, references, bodyStructure, plaintextBody, recipients, createMailbox, sieveFlags)
 	if err != nil {
@@ -901,6 +1034,16 @@ func (s *LMTPSession) Data(ctx context.Context, r io.Reader) error {
 
 	s.InfoLog("message delivered", "mailbox", mailboxName)
 
+	if s.User != nil {
+		s.backend.webhooks.Emit(webhook.NewEvent(webhook.EventMessageDelivered, "lmtp", s.AccountID(), s.User.FullAddress(), map[string]any{
+			"mailbox":    mailboxName,
+			"from":       s.sender.FullAddress(),
+			"message_id": messageID,
+			"subject":    subject,
+			"size":       len(fullMessageBytes),
+		}))
+	}
+
 	// Track domain and user activity - LMTP delivery is critical!
 	if s.User != nil {
 		metrics.TrackDomainMessage("lmtp", s.Domain(), "delivered")
@@ -930,6 +1073,10 @@ func (s *LMTPSession) Reset() {
 
 	s.User = nil
 	s.sender = nil
+	s.smtputf8 = false
+	s.srsBounceTo = ""
+	s.route = ""
+	s.routeResolution = nil
 
 	s.DebugLog("session reset")
 	recordMetrics("success")
@@ -1018,10 +1165,33 @@ func (s *LMTPSession) handleVacationResponse(ctx context.Context, result sieveen
 		Logger:         &lmtpDeliveryLogger{s: s},
 		IsOwnedAddress: s.backend.rdb.IsAddressOwnedByAccountWithRetry,
 		RelayNotify:    s.notifyRelayWorker,
+		OnSent: func(to string) {
+			s.backend.webhooks.Emit(webhook.NewEvent(webhook.EventVacationSent, "lmtp", s.AccountID(), s.User.FullAddress(),
+				map[string]any{"to": to}))
+		},
 	}
 	return handler.HandleVacationResponse(ctx, s.AccountID(), result, s.sender, &s.User.Address, originalMessage)
 }
 
+// keepMailbox returns where a kept message is stored: the mailbox named by the
+// recipient's +detail when the account's delivery settings file by subaddress,
+// otherwise INBOX. saveMessageToMailbox applies the insert-right check to it.
+func (s *LMTPSession) keepMailbox(ctx context.Context) string {
+	if s.recipientAddr == nil || s.recipientAddr.Detail() == "" {
+		return consts.MailboxInbox
+	}
+	name, err := delivery.SubaddressMailbox(ctx, s.backend.rdb, s.AccountID(), s.recipientAddr.Detail(), s.recipientDomain)
+	if err != nil {
+		s.WarnLog("failed to resolve subaddress mailbox, delivering to inbox", "detail", s.recipientAddr.Detail(), "error", err)
+		return consts.MailboxInbox
+	}
+	if name == "" {
+		return consts.MailboxInbox
+	}
+	s.DebugLog("filing by subaddress", "mailbox", name)
+	return name
+}
+
 // saveMessageToMailbox saves a message to the specified mailbox.
 // flags carries any keywords/flags set by the Sieve script (imap4flags, RFC 5232);
 // they are stored on the message (InsertMessage folds keyword case per RFC 9051 §2.3.2).
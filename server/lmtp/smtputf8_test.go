@@ -0,0 +1,40 @@
+package lmtp
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/emersion/go-smtp"
+)
+
+func TestSMTPUTF8RequiredForUTF8Addresses(t *testing.T) {
+	s := newSRSTestSession(t, nil, &captureRelayQueue{})
+	ctx := context.Background()
+
+	isUTF8Rejection := func(err error) bool {
+		var smtpErr *smtp.SMTPError
+		return errors.As(err, &smtpErr) && smtpErr.Code == 553 && smtpErr.EnhancedCode == smtp.EnhancedCode{5, 6, 7}
+	}
+
+	if err := s.Mail(ctx, "josé@example.com", &smtp.MailOptions{}); !isUTF8Rejection(err) {
+		t.Errorf("MAIL with a UTF-8 sender and no SMTPUTF8: %v", err)
+	}
+	if err := s.Mail(ctx, "josé@example.com", &smtp.MailOptions{UTF8: true}); err != nil {
+		t.Fatalf("MAIL with SMTPUTF8: %v", err)
+	}
+	if s.sender.FullAddress() != "josé@example.com" || !s.smtputf8 {
+		t.Errorf("sender = %q, smtputf8 = %v", s.sender.FullAddress(), s.smtputf8)
+	}
+
+	s.Reset()
+	if s.smtputf8 {
+		t.Error("RSET kept SMTPUTF8")
+	}
+	if err := s.Mail(ctx, "sender@example.com", nil); err != nil {
+		t.Fatalf("MAIL: %v", err)
+	}
+	if err := s.Rcpt(ctx, "用户@example.com", nil); !isUTF8Rejection(err) {
+		t.Errorf("RCPT with a UTF-8 recipient and no SMTPUTF8: %v", err)
+	}
+}
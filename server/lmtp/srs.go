@@ -0,0 +1,71 @@
+package lmtp
+
+import (
+	"errors"
+
+	"github.com/emersion/go-smtp"
+	"github.com/migadu/sora/pkg/srs"
+)
+
+// rcptSRS accepts a recipient that is an SRS address of this server: a bounce
+// (or reply) to the envelope sender of a message Sieve redirected. The
+// address is decoded and the message is returned to the original sender
+// through the relay queue at DATA, instead of being rejected as an unknown
+// user.
+func (s *LMTPSession) rcptSRS(to string) error {
+	if s.backend.relayQueue == nil {
+		s.WarnLog("SRS recipient but relay queue not configured", "to", to)
+		return &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 5},
+			Message:      "Cannot return message, please try again later",
+		}
+	}
+
+	original, err := s.backend.srs.Reverse(to)
+	if err != nil {
+		s.InfoLog("rejecting SRS recipient", "to", to, "error", err)
+		message := "Invalid SRS address"
+		if errors.Is(err, srs.ErrExpiredSRS) {
+			message = "Expired SRS address"
+		}
+		return &smtp.SMTPError{
+			Code:         550,
+			EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+			Message:      message,
+		}
+	}
+
+	acquired, release := s.mutexHelper.AcquireWriteLockWithTimeout(s.ctx)
+	if !acquired {
+		s.WarnLog("failed to acquire write lock", "command", "RCPT")
+		return &smtp.SMTPError{
+			Code:         421,
+			EnhancedCode: smtp.EnhancedCode{4, 4, 5},
+			Message:      "Server busy, try again later",
+		}
+	}
+	defer release()
+
+	s.User = nil
+	s.recipientAddr = nil
+	s.recipientDomain = ""
+	s.srsBounceTo = original
+	s.route = ""
+	s.routeResolution = nil
+
+	s.DebugLog("SRS recipient accepted", "to", to, "original", original)
+	return nil
+}
+
+// returnSRSBounce queues a message addressed to an SRS recipient for the
+// decoded original sender. The envelope sender is kept, so DSNs keep their
+// null reverse-path and cannot bounce again.
+func (s *LMTPSession) returnSRSBounce(from string, message []byte) error {
+	if err := s.backend.relayQueue.Enqueue(from, s.srsBounceTo, "bounce", message); err != nil {
+		return s.InternalError("failed to queue SRS bounce: %v", err)
+	}
+	s.notifyRelayWorker()
+	s.InfoLog("returned bounce to original sender", "from", from, "to", s.srsBounceTo)
+	return nil
+}
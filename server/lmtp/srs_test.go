@@ -0,0 +1,89 @@
+package lmtp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-smtp"
+	"github.com/migadu/sora/pkg/srs"
+	"github.com/migadu/sora/server"
+)
+
+type queuedRelay struct {
+	from, to, messageType string
+	message               []byte
+}
+
+type captureRelayQueue struct{ queued []queuedRelay }
+
+func (q *captureRelayQueue) Enqueue(from, to, messageType string, messageBytes []byte) error {
+	q.queued = append(q.queued, queuedRelay{from, to, messageType, messageBytes})
+	return nil
+}
+
+func newSRSTestSession(t *testing.T, rw *srs.Rewriter, queue *captureRelayQueue) *LMTPSession {
+	t.Helper()
+	s := &LMTPSession{
+		backend: &LMTPServerBackend{srs: rw, relayQueue: queue},
+		ctx:     context.Background(),
+	}
+	s.mutexHelper = server.NewMutexTimeoutHelper(&s.mutex, s.ctx, "LMTP", func(string, ...any) {})
+	return s
+}
+
+func TestSRSBounceReturnedToOriginalSender(t *testing.T) {
+	rw, err := srs.New("srs.example.com", []string{"secret"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue := &captureRelayQueue{}
+	s := newSRSTestSession(t, rw, queue)
+
+	// A redirect from alice leaves with an SRS sender...
+	if err := s.sendToExternalRelay("alice@origin.example", "bob@elsewhere.example", []byte("Subject: hi\r\n\r\nhi\r\n")); err != nil {
+		t.Fatalf("sendToExternalRelay: %v", err)
+	}
+	srsSender := queue.queued[0].from
+	if !srs.IsSRS(srsSender) || !strings.HasSuffix(srsSender, "@srs.example.com") {
+		t.Fatalf("redirect sender not rewritten: %q", srsSender)
+	}
+
+	// ...and the bounce to it goes back to alice through the relay queue
+	ctx := context.Background()
+	if err := s.Mail(ctx, "", nil); err != nil {
+		t.Fatalf("MAIL: %v", err)
+	}
+	if err := s.Rcpt(ctx, srsSender, nil); err != nil {
+		t.Fatalf("RCPT: %v", err)
+	}
+	dsn := "From: MAILER-DAEMON@elsewhere.example\r\nSubject: Undelivered Mail\r\n\r\nbounce\r\n"
+	if err := s.Data(ctx, strings.NewReader(dsn)); err != nil {
+		t.Fatalf("DATA: %v", err)
+	}
+
+	if len(queue.queued) != 2 {
+		t.Fatalf("expected the bounce to be queued, got %d messages", len(queue.queued))
+	}
+	bounce := queue.queued[1]
+	if bounce.from != "" || bounce.to != "alice@origin.example" || bounce.messageType != "bounce" || string(bounce.message) != dsn {
+		t.Errorf("unexpected bounce: from %q to %q type %q", bounce.from, bounce.to, bounce.messageType)
+	}
+}
+
+func TestSRSRecipientRejected(t *testing.T) {
+	rw, _ := srs.New("srs.example.com", []string{"secret"}, 0)
+	other, _ := srs.New("srs.example.com", []string{"other"}, 0)
+	forged, _ := other.Forward("alice@origin.example")
+
+	s := newSRSTestSession(t, rw, &captureRelayQueue{})
+	err := s.Rcpt(context.Background(), forged, nil)
+	var smtpErr *smtp.SMTPError
+	if !errors.As(err, &smtpErr) || smtpErr.Code != 550 {
+		t.Fatalf("expected 550 for a forged SRS address, got %v", err)
+	}
+	if s.srsBounceTo != "" {
+		t.Error("forged SRS recipient was accepted")
+	}
+}
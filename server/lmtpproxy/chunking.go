@@ -0,0 +1,229 @@
+package lmtpproxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/pkg/metrics"
+)
+
+// bdatChunkBuffer is the size of the buffer chunks are copied through.
+const bdatChunkBuffer = 32 * 1024
+
+// bdatTransfer is a message being sent with BDAT (RFC 3030). Every chunk is
+// forwarded as a BDAT command of its own to each backend with accepted
+// recipients, the way fanoutData streams DATA, so one or several backends
+// take the same path.
+type bdatTransfer struct {
+	targets []*fanoutTarget
+	byConn  map[net.Conn]*fanoutTarget
+	size    int64
+}
+
+// mailParameters returns the MAIL FROM parameters that are replayed to the
+// backends: BODY (RFC 6152, RFC 3030) and SMTPUTF8 (RFC 6531). Other
+// parameters only concern the MTA that sent the message and are dropped.
+func mailParameters(args []string) string {
+	var params []string
+	for _, arg := range args {
+		name, value, _ := strings.Cut(strings.ToUpper(arg), "=")
+		switch name {
+		case "BODY":
+			switch value {
+			case "7BIT", "8BITMIME", "BINARYMIME":
+				params = append(params, "BODY="+value)
+			}
+		case "SMTPUTF8":
+			params = append(params, "SMTPUTF8")
+		}
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return " " + strings.Join(params, " ")
+}
+
+// hasMailParameter reports whether the transaction's MAIL FROM carried param.
+func (s *Session) hasMailParameter(param string) bool {
+	for _, p := range strings.Fields(s.mailParams) {
+		if p == param {
+			return true
+		}
+	}
+	return false
+}
+
+// handleBdat handles one BDAT command and its chunk. The chunk is always read
+// in full, even when the command is rejected, so the client stays in sync.
+// The first chunk starts the transfer on every backend of the transaction;
+// the LAST one is answered with a reply per recipient, in RCPT order, and
+// ends the transaction as fanoutData does. It returns false when the client
+// connection is unusable.
+func (s *Session) handleBdat(args []string) bool {
+	if len(args) < 1 || len(args) > 2 || (len(args) == 2 && !strings.EqualFold(args[1], "LAST")) {
+		return s.sendResponse("501 5.5.4 Syntax error in BDAT command") == nil
+	}
+	size, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || size < 0 {
+		return s.sendResponse("501 5.5.4 Invalid BDAT chunk size") == nil
+	}
+	last := len(args) == 2
+
+	if s.bdat == nil {
+		if !s.mailFromReceived || len(s.rcptConns) == 0 {
+			if !s.discardChunk(size) {
+				return false
+			}
+			return s.sendResponse("503 5.5.1 No valid recipients") == nil
+		}
+		targets, byConn := s.fanoutTargets()
+		for _, t := range targets {
+			t.open = t.writer != nil
+		}
+		s.bdat = &bdatTransfer{targets: targets, byConn: byConn}
+	}
+	transfer := s.bdat
+
+	transfer.size += size
+	if s.server.maxMessageSize > 0 && transfer.size > s.server.maxMessageSize {
+		s.resetFanout()
+		metrics.LMTPProxyFanoutTransactions.WithLabelValues("failure").Inc()
+		if !s.discardChunk(size) {
+			return false
+		}
+		return s.sendResponse("552 5.3.4 Message size exceeds fixed maximum message size") == nil
+	}
+
+	command := "BDAT " + args[0]
+	if last {
+		command += " LAST"
+	}
+	for _, t := range transfer.targets {
+		if !t.open {
+			continue
+		}
+		if _, err := t.writer.WriteString(command + "\r\n"); err != nil {
+			s.WarnLog("fan-out write failed", "backend", t.addr, "error", err)
+			t.open = false
+		}
+	}
+	if !s.streamChunk(transfer.targets, size) {
+		return false
+	}
+
+	if last {
+		defer s.resetFanout()
+		if failure, ok := noOpenTarget(transfer.targets, nil); ok {
+			metrics.LMTPProxyFanoutTransactions.WithLabelValues("failure").Inc()
+			return s.sendResponse(failure) == nil
+		}
+		return s.fanoutReplies(transfer.targets, transfer.byConn)
+	}
+
+	// Each backend acknowledges the chunk; one that rejects it is out of the
+	// transaction and its recipients get its reply after the LAST chunk
+	var first *fanoutTarget
+	for _, t := range transfer.targets {
+		if !t.open {
+			continue
+		}
+		reply, err := s.readReplyFrom(t.conn, t.reader, fanoutReplyTimeout)
+		switch {
+		case err != nil:
+			s.WarnLog("fan-out BDAT failed", "backend", t.addr, "error", err)
+			t.open = false
+		case !strings.HasPrefix(reply, "2"):
+			t.failure = reply
+			t.open = false
+		}
+		if first == nil {
+			first = t
+		}
+	}
+	if failure, ok := noOpenTarget(transfer.targets, first); ok {
+		// The transaction is over: later chunks are rejected until a new MAIL
+		s.resetFanout()
+		metrics.LMTPProxyFanoutTransactions.WithLabelValues("failure").Inc()
+		return s.sendResponse(failure) == nil
+	}
+	return s.sendResponse(fmt.Sprintf("250 2.0.0 %d octets received", size)) == nil
+}
+
+// streamChunk copies size bytes of a BDAT chunk from the client to every open
+// target and flushes them. A target that fails to take a write is closed for
+// the rest of the transaction. It returns false if reading from the client
+// failed.
+func (s *Session) streamChunk(targets []*fanoutTarget, size int64) bool {
+	var bytesIn int64
+	defer func() {
+		metrics.BytesThroughput.WithLabelValues("lmtp_proxy", "in").Add(float64(bytesIn))
+	}()
+
+	buf := make([]byte, min(size, bdatChunkBuffer))
+	for bytesIn < size {
+		n := min(size-bytesIn, int64(len(buf)))
+		if !s.readChunk(buf[:n]) {
+			return false
+		}
+		bytesIn += n
+		for _, t := range targets {
+			if !t.open {
+				continue
+			}
+			if _, err := t.writer.Write(buf[:n]); err != nil {
+				s.WarnLog("fan-out write failed", "backend", t.addr, "error", err)
+				t.open = false
+			}
+		}
+	}
+
+	for _, t := range targets {
+		if !t.open {
+			continue
+		}
+		if err := t.writer.Flush(); err != nil {
+			s.WarnLog("fan-out write failed", "backend", t.addr, "error", err)
+			t.open = false
+		}
+	}
+	return true
+}
+
+// discardChunk reads and drops a BDAT chunk that is not forwarded.
+func (s *Session) discardChunk(size int64) bool {
+	buf := make([]byte, min(size, bdatChunkBuffer))
+	for read := int64(0); read < size; {
+		n := min(size-read, int64(len(buf)))
+		if !s.readChunk(buf[:n]) {
+			return false
+		}
+		read += n
+	}
+	return true
+}
+
+// readChunk fills buf from the client under the idle timeout.
+func (s *Session) readChunk(buf []byte) bool {
+	if s.server.authIdleTimeout > 0 {
+		if err := s.clientConn.SetReadDeadline(time.Now().Add(s.server.authIdleTimeout)); err != nil {
+			s.DebugLog("Failed to set read deadline", "error", err)
+			return false
+		}
+		defer func() {
+			if err := s.clientConn.SetReadDeadline(time.Time{}); err != nil {
+				s.DebugLog("Failed to clear read deadline", "error", err)
+			}
+		}()
+	}
+	if _, err := io.ReadFull(s.clientReader, buf); err != nil {
+		if !isClosingError(err) {
+			s.DebugLog("Error reading BDAT chunk from client", "error", err)
+		}
+		return false
+	}
+	return true
+}
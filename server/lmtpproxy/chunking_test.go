@@ -0,0 +1,226 @@
+package lmtpproxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/migadu/sora/server/proxy"
+)
+
+// fakeBDATBackend accepts one connection and takes messages sent with BDAT.
+// Chunks are acknowledged with chunkReply, and every recipient of the
+// transaction is answered with reply after the LAST chunk. The commands and
+// chunks it received are sent on transcript when the connection ends.
+func fakeBDATBackend(t *testing.T, chunkReply, reply string) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	transcript := make(chan string, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		var seen strings.Builder
+		defer func() { transcript <- seen.String() }()
+
+		r := bufio.NewReader(c)
+		w := bufio.NewWriter(c)
+		w.WriteString("220 backend ready\r\n")
+		w.Flush()
+		rcpts := 0
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			seen.WriteString(line)
+			switch fields := strings.Fields(strings.ToUpper(line)); fields[0] {
+			case "LHLO":
+				w.WriteString("250-backend\r\n250-CHUNKING\r\n250-BINARYMIME\r\n250 SMTPUTF8\r\n")
+			case "RCPT":
+				rcpts++
+				w.WriteString("250 2.1.5 Ok\r\n")
+			case "BDAT":
+				size, _ := strconv.Atoi(fields[1])
+				chunk := make([]byte, size)
+				if _, err := io.ReadFull(r, chunk); err != nil {
+					return
+				}
+				seen.Write(chunk)
+				if len(fields) < 3 {
+					w.WriteString(chunkReply + "\r\n")
+					break
+				}
+				for i := 0; i < rcpts; i++ {
+					w.WriteString(reply + "\r\n")
+				}
+				rcpts = 0
+			default:
+				w.WriteString("250 2.0.0 Ok\r\n")
+			}
+			w.Flush()
+		}
+	}()
+	return ln.Addr().String(), transcript
+}
+
+// newBDATTestSession returns a session whose client sends input and whose
+// replies to the client are sent on the returned channel.
+func newBDATTestSession(t *testing.T, input string, backends ...string) (*Session, net.Conn, <-chan string) {
+	t.Helper()
+	cm, err := proxy.NewConnectionManager(backends, 24, false, false, false, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create connection manager: %v", err)
+	}
+	clientConn, clientPeer := net.Pipe()
+	t.Cleanup(func() { clientConn.Close() })
+	replies := make(chan string, 16)
+	go func() {
+		r := bufio.NewReader(clientPeer)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(replies)
+				return
+			}
+			replies <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+
+	s := &Session{
+		server:               &Server{name: "test", hostname: "proxy", connManager: cm},
+		clientConn:           clientConn,
+		clientReader:         bufio.NewReader(strings.NewReader(input)),
+		clientWriter:         bufio.NewWriter(clientConn),
+		ctx:                  context.Background(),
+		registeredAccountIDs: make(map[int64]struct{}),
+	}
+	return s, clientConn, replies
+}
+
+// collect closes the client connection and returns the replies it got.
+func collect(clientConn net.Conn, replies <-chan string) string {
+	clientConn.Close()
+	var got []string
+	for line := range replies {
+		got = append(got, line)
+	}
+	return strings.Join(got, "\n")
+}
+
+func TestBdatMixedRecipients(t *testing.T) {
+	addr1, transcript1 := fakeBDATBackend(t, "250 2.0.0 Chunk ok", "250 2.0.0 Delivered")
+	addr2, transcript2 := fakeBDATBackend(t, "250 2.0.0 Chunk ok", "452 4.2.2 Mailbox full")
+
+	// A binary body: NUL bytes, bare LF and a lone dot line are passed through as is
+	chunk1 := "Subject: hi\r\n\r\n\x00\x01.\n"
+	chunk2 := ".\r\nend"
+	s, clientConn, replies := newBDATTestSession(t, chunk1+chunk2, addr1, addr2)
+	s.sender = "josé@example.com"
+	s.mailParams = mailParameters([]string{"FROM:<josé@example.com>", "BODY=binarymime", "SMTPUTF8", "SIZE=100"})
+	s.mailFromReceived = true
+
+	rcptTo(t, s, "a@example.com", addr1)
+	rcptTo(t, s, "b@example.com", addr2)
+	rcptTo(t, s, "c@example.com", addr1)
+
+	if !s.handleBdat([]string{strconv.Itoa(len(chunk1))}) {
+		t.Fatal("first chunk: unusable client connection")
+	}
+	if !s.handleBdat([]string{strconv.Itoa(len(chunk2)), "last"}) {
+		t.Fatal("LAST chunk: unusable client connection")
+	}
+
+	want := strings.Join([]string{
+		"250 2.1.5 Ok", "250 2.1.5 Ok", "250 2.1.5 Ok",
+		"250 2.0.0 19 octets received",
+		"250 2.0.0 Delivered", "452 4.2.2 Mailbox full", "250 2.0.0 Delivered",
+	}, "\n")
+	if got := collect(clientConn, replies); got != want {
+		t.Errorf("client replies:\n%s\nwant:\n%s", got, want)
+	}
+
+	for i, transcript := range []<-chan string{transcript1, transcript2} {
+		seen := <-transcript
+		if !strings.Contains(seen, "MAIL FROM:<josé@example.com> BODY=BINARYMIME SMTPUTF8\r\n") ||
+			!strings.Contains(seen, "BDAT 19\r\n"+chunk1+"BDAT 6 LAST\r\n"+chunk2) {
+			t.Errorf("backend %d transcript:\n%q", i+1, seen)
+		}
+	}
+	if s.bdat != nil || s.backendConn != nil || s.extraBackends != nil || s.rcptConns != nil || s.mailFromReceived || s.mailParams != "" {
+		t.Error("transaction state not reset after BDAT LAST")
+	}
+}
+
+func TestBdatChunkRejectedByOneBackend(t *testing.T) {
+	addr1, _ := fakeBDATBackend(t, "250 2.0.0 Chunk ok", "250 2.0.0 Delivered")
+	addr2, transcript2 := fakeBDATBackend(t, "554 5.6.0 Content rejected", "250 2.0.0 Delivered")
+
+	s, clientConn, replies := newBDATTestSession(t, "part1part2", addr1, addr2)
+	s.sender = "sender@example.com"
+	s.mailFromReceived = true
+	rcptTo(t, s, "a@example.com", addr1)
+	rcptTo(t, s, "b@example.com", addr2)
+
+	if !s.handleBdat([]string{"5"}) || !s.handleBdat([]string{"5", "LAST"}) {
+		t.Fatal("unusable client connection")
+	}
+
+	// The backend that rejected a chunk answers for its recipient with that reply
+	want := strings.Join([]string{
+		"250 2.1.5 Ok", "250 2.1.5 Ok",
+		"250 2.0.0 5 octets received",
+		"250 2.0.0 Delivered", "554 5.6.0 Content rejected",
+	}, "\n")
+	if got := collect(clientConn, replies); got != want {
+		t.Errorf("client replies:\n%s\nwant:\n%s", got, want)
+	}
+	if seen := <-transcript2; strings.Contains(seen, "part2") {
+		t.Errorf("chunk sent to a backend that rejected the transfer:\n%q", seen)
+	}
+}
+
+func TestBdatWithoutRecipients(t *testing.T) {
+	s, clientConn, replies := newBDATTestSession(t, "ignoredNOOP\r\n", "127.0.0.1:1")
+
+	if !s.handleBdat([]string{"7", "LAST"}) {
+		t.Fatal("unusable client connection")
+	}
+	// The chunk was consumed: the next command is read from where it ends
+	if next, _ := s.clientReader.ReadString('\n'); next != "NOOP\r\n" {
+		t.Errorf("next command = %q", next)
+	}
+	if got := collect(clientConn, replies); got != "503 5.5.1 No valid recipients" {
+		t.Errorf("BDAT reply = %q", got)
+	}
+}
+
+func TestMailParameters(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"FROM:<a@example.com>"}, ""},
+		{[]string{"FROM:<a@example.com>", "SIZE=123", "RET=FULL", "ENVID=x"}, ""},
+		{[]string{"FROM:<a@example.com>", "body=8bitmime"}, " BODY=8BITMIME"},
+		{[]string{"FROM:", "<a@example.com>", "BODY=BINARYMIME", "smtputf8"}, " BODY=BINARYMIME SMTPUTF8"},
+		{[]string{"FROM:<a@example.com>", "BODY=UNKNOWN"}, ""},
+	}
+	for _, tt := range tests {
+		if got := mailParameters(tt.args); got != tt.want {
+			t.Errorf("mailParameters(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package lmtpproxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/pkg/metrics"
+	"github.com/migadu/sora/server"
+)
+
+// maxFanoutBackends bounds the number of backends one transaction may span.
+const maxFanoutBackends = 16
+
+// fanoutReplyTimeout bounds the wait for a backend's per-recipient replies
+// after the end of DATA. Delivery (Sieve, staging) can take a while, so this
+// matches the stale-backend deadline of the relay loop.
+const fanoutReplyTimeout = 5 * time.Minute
+
+// backendLeg is an additional backend connection of a transaction whose
+// recipients route to more than one backend. The session's backendConn serves
+// the first backend; every other backend gets a leg.
+type backendLeg struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+	addr   string
+}
+
+// onLeg runs fn with leg installed as the session's backend connection, so the
+// single-backend code (handshake, MAIL FROM replay, RCPT forwarding) serves
+// legs as well. A nil leg.conn afterwards means fn dropped the connection.
+func (s *Session) onLeg(leg *backendLeg, fn func()) {
+	s.mu.Lock()
+	conn, reader, writer, addr := s.backendConn, s.backendReader, s.backendWriter, s.serverAddr
+	s.backendConn, s.backendReader, s.backendWriter, s.serverAddr = leg.conn, leg.reader, leg.writer, leg.addr
+	s.mu.Unlock()
+
+	fn()
+
+	s.mu.Lock()
+	leg.conn, leg.reader, leg.writer, leg.addr = s.backendConn, s.backendReader, s.backendWriter, s.serverAddr
+	s.backendConn, s.backendReader, s.backendWriter, s.serverAddr = conn, reader, writer, addr
+	s.mu.Unlock()
+}
+
+// extraBackend returns the connection for a recipient that routes to addr
+// instead of the session's backend, connecting and replaying MAIL FROM on
+// first use. A nil leg without error means the connection manager fell back
+// to the session's own backend, which then takes the recipient.
+func (s *Session) extraBackend(addr string) (*backendLeg, error) {
+	live := 0
+	for _, leg := range s.extraBackends {
+		if leg.conn == nil {
+			continue
+		}
+		if leg.addr == addr {
+			return leg, nil
+		}
+		live++
+	}
+	if live+1 >= maxFanoutBackends {
+		return nil, fmt.Errorf("transaction spans more than %d backends", maxFanoutBackends)
+	}
+
+	leg := &backendLeg{}
+	var err error
+	s.onLeg(leg, func() { err = s.connectToBackend() })
+	if err != nil {
+		return nil, err
+	}
+	if leg.addr == s.serverAddr {
+		leg.conn.Close()
+		return nil, nil
+	}
+	for _, existing := range s.extraBackends {
+		if existing.conn != nil && existing.addr == leg.addr {
+			leg.conn.Close()
+			return existing, nil
+		}
+	}
+
+	s.mu.Lock()
+	s.extraBackends = append(s.extraBackends, leg)
+	s.mu.Unlock()
+	if err := s.registerConnection(); err != nil {
+		s.InfoLog("rejected connection registration", "error", err)
+	}
+	return leg, nil
+}
+
+// fanoutTarget is one backend taking part in a multi-backend DATA.
+type fanoutTarget struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	addr    string
+	rcpts   int
+	open    bool     // DATA accepted and the message is being streamed
+	failure string   // reply for recipients that did not get one from the backend
+	replies []string // per-recipient replies, in RCPT order
+	next    int
+}
+
+// fanoutData handles DATA for a transaction whose recipients span several
+// backends: DATA is opened on each backend with accepted recipients, the
+// message is streamed to all of them, and the per-recipient replies are
+// returned in RCPT order (RFC 2033 §4.2). A backend that fails answers for its
+// own recipients only. Afterwards all backend connections are closed so the
+// next transaction starts fresh. It returns false when the client connection
+// is unusable.
+func (s *Session) fanoutData() bool {
+	defer s.resetFanout()
+
+	if len(s.rcptConns) == 0 {
+		s.sendResponse("503 5.5.1 No valid recipients")
+		return true
+	}
+	targets, byConn := s.fanoutTargets()
+
+	// Open DATA on every backend that still has its connection
+	var first *fanoutTarget
+	for _, t := range targets {
+		if t.writer == nil {
+			continue
+		}
+		response, err := s.commandOn(t, "DATA", s.server.connManager.GetConnectTimeout())
+		switch {
+		case err != nil:
+			s.WarnLog("fan-out DATA failed", "backend", t.addr, "error", err)
+		case !strings.HasPrefix(response, "354"):
+			t.failure = response
+		default:
+			t.open = true
+		}
+		if first == nil {
+			first = t
+		}
+	}
+	if failure, ok := noOpenTarget(targets, first); ok {
+		// No backend takes the message: DATA itself fails, without per-recipient replies
+		metrics.LMTPProxyFanoutTransactions.WithLabelValues("failure").Inc()
+		s.sendResponse(failure)
+		return true
+	}
+
+	if err := s.sendResponse("354 Start mail input; end with <CRLF>.<CRLF>"); err != nil {
+		return false
+	}
+	if !s.streamMessage(targets) {
+		return false
+	}
+	return s.fanoutReplies(targets, byConn)
+}
+
+// fanoutTargets groups the accepted recipients of the transaction by the
+// backend connection that accepted them. A recipient whose connection was
+// dropped after RCPT gets a target without writer.
+func (s *Session) fanoutTargets() ([]*fanoutTarget, map[net.Conn]*fanoutTarget) {
+	var targets []*fanoutTarget
+	byConn := make(map[net.Conn]*fanoutTarget)
+	for _, conn := range s.rcptConns {
+		t, ok := byConn[conn]
+		if !ok {
+			t = &fanoutTarget{conn: conn, failure: "451 4.4.2 Backend error"}
+			if conn != nil && conn == s.backendConn {
+				t.reader, t.writer, t.addr = s.backendReader, s.backendWriter, s.serverAddr
+			}
+			for _, leg := range s.extraBackends {
+				if conn != nil && conn == leg.conn {
+					t.reader, t.writer, t.addr = leg.reader, leg.writer, leg.addr
+				}
+			}
+			byConn[conn] = t
+			targets = append(targets, t)
+		}
+		t.rcpts++
+	}
+	metrics.LMTPProxyFanoutBackends.Observe(float64(len(targets)))
+	return targets, byConn
+}
+
+// noOpenTarget returns the reply for a transaction none of whose backends
+// takes the message: the failure of the first one that answered, if any.
+func noOpenTarget(targets []*fanoutTarget, first *fanoutTarget) (string, bool) {
+	for _, t := range targets {
+		if t.open {
+			return "", false
+		}
+	}
+	if first != nil {
+		return first.failure, true
+	}
+	return "451 4.4.2 Backend error", true
+}
+
+// fanoutReplies reads the per-recipient replies of every open target after
+// the end of the message and returns them to the client in RCPT order (RFC
+// 2033 §4.2). It returns false when the client connection is unusable.
+func (s *Session) fanoutReplies(targets []*fanoutTarget, byConn map[net.Conn]*fanoutTarget) bool {
+	// Replies: one per accepted recipient from each backend. All backends
+	// already have the whole message, so they deliver in parallel while we
+	// read them one after the other.
+	for _, t := range targets {
+		if !t.open {
+			continue
+		}
+		for len(t.replies) < t.rcpts {
+			reply, err := s.readReplyFrom(t.conn, t.reader, fanoutReplyTimeout)
+			if err != nil {
+				s.WarnLog("fan-out reply failed", "backend", t.addr, "error", err)
+				break
+			}
+			t.replies = append(t.replies, reply)
+		}
+	}
+
+	delivered := 0
+	for _, conn := range s.rcptConns {
+		t := byConn[conn]
+		reply := t.failure
+		if t.next < len(t.replies) {
+			reply = t.replies[t.next]
+		}
+		t.next++
+		if strings.HasPrefix(reply, "2") {
+			delivered++
+		}
+		s.clientWriter.WriteString(reply + "\r\n")
+	}
+	if err := s.clientWriter.Flush(); err != nil {
+		return false
+	}
+
+	result := "success"
+	if delivered == 0 {
+		result = "failure"
+	} else if delivered < len(s.rcptConns) {
+		result = "partial"
+	}
+	metrics.LMTPProxyFanoutTransactions.WithLabelValues(result).Inc()
+	s.InfoLog("fan-out delivery", "from", s.sender, "backends", len(targets), "recipients", len(s.rcptConns), "delivered", delivered)
+	return true
+}
+
+// streamMessage copies the message from the client to every open target up
+// to and including the terminating dot. Lines are forwarded as read
+// (dot-stuffing intact). A target that fails to take a write is closed for
+// the rest of the transaction. It returns false if reading from the client
+// failed.
+func (s *Session) streamMessage(targets []*fanoutTarget) bool {
+	var bytesIn int64
+	defer func() {
+		metrics.BytesThroughput.WithLabelValues("lmtp_proxy", "in").Add(float64(bytesIn))
+	}()
+
+	write := func(chunk []byte) {
+		for _, t := range targets {
+			if !t.open {
+				continue
+			}
+			if _, err := t.writer.Write(chunk); err != nil {
+				s.WarnLog("fan-out write failed", "backend", t.addr, "error", err)
+				t.open = false
+			}
+		}
+	}
+
+	atLineStart := true
+	for {
+		if s.server.authIdleTimeout > 0 && s.clientReader.Buffered() == 0 {
+			if err := s.clientConn.SetReadDeadline(time.Now().Add(s.server.authIdleTimeout)); err != nil {
+				s.DebugLog("Failed to set read deadline", "error", err)
+				return false
+			}
+		}
+		// ReadSlice bounds memory for lines without CRLF, as in the relay loop
+		chunk, err := s.clientReader.ReadSlice('\n')
+		if len(chunk) > 0 {
+			bytesIn += int64(len(chunk))
+			end := atLineStart && (string(chunk) == ".\r\n" || string(chunk) == ".\n")
+			write(chunk)
+			atLineStart = chunk[len(chunk)-1] == '\n'
+			if end {
+				break
+			}
+		}
+		if err != nil {
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			if !isClosingError(err) {
+				s.DebugLog("Error reading message from client", "error", err)
+			}
+			return false
+		}
+	}
+	if s.server.authIdleTimeout > 0 {
+		if err := s.clientConn.SetReadDeadline(time.Time{}); err != nil {
+			s.DebugLog("Failed to clear read deadline", "error", err)
+		}
+	}
+
+	for _, t := range targets {
+		if !t.open {
+			continue
+		}
+		if err := t.writer.Flush(); err != nil {
+			s.WarnLog("fan-out write failed", "backend", t.addr, "error", err)
+			t.open = false
+		}
+	}
+	return true
+}
+
+// commandOn sends a command to a fan-out target and returns its reply.
+func (s *Session) commandOn(t *fanoutTarget, command string, timeout time.Duration) (string, error) {
+	if _, err := t.writer.WriteString(command + "\r\n"); err != nil {
+		return "", err
+	}
+	if err := t.writer.Flush(); err != nil {
+		return "", err
+	}
+	return s.readReplyFrom(t.conn, t.reader, timeout)
+}
+
+// readReplyFrom reads one (possibly multiline) reply from a backend
+// connection, with continuation lines joined by CRLF and the final CRLF
+// removed.
+func (s *Session) readReplyFrom(conn net.Conn, reader *bufio.Reader, timeout time.Duration) (string, error) {
+	var lines []string
+	for len(lines) < maxLHLOResponseLines {
+		line, err := s.readLineFrom(conn, reader, timeout)
+		if err != nil {
+			return "", err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		if len(line) < 4 || line[3] != '-' {
+			return strings.Join(lines, "\r\n"), nil
+		}
+	}
+	return "", fmt.Errorf("backend reply exceeded %d lines", maxLHLOResponseLines)
+}
+
+// resetFanout ends a transaction, on one backend or several: every backend
+// connection is closed so the next transaction reconnects and replays its own
+// MAIL FROM.
+func (s *Session) resetFanout() {
+	s.mu.Lock()
+	if s.backendConn != nil {
+		s.backendConn.Close()
+		s.backendConn = nil
+	}
+	for _, leg := range s.extraBackends {
+		if leg.conn != nil {
+			leg.conn.Close()
+		}
+	}
+	s.extraBackends = nil
+	s.mu.Unlock()
+	s.backendReader, s.backendWriter = nil, nil
+	s.serverAddr = ""
+	s.rcptConns = nil
+	s.bdat = nil
+	s.sender = ""
+	s.mailParams = ""
+	s.mailFromReceived = false
+}
+
+// closeExtraBackends closes the additional backend connections of the
+// current transaction, for RSET.
+func (s *Session) closeExtraBackends() {
+	s.mu.Lock()
+	for _, leg := range s.extraBackends {
+		if leg.conn != nil {
+			leg.conn.Close()
+		}
+	}
+	s.extraBackends = nil
+	s.mu.Unlock()
+	s.rcptConns = nil
+}
+
+// readLineFrom reads one bounded line from a backend connection under a read
+// deadline, which is cleared afterwards.
+func (s *Session) readLineFrom(conn net.Conn, reader *bufio.Reader, timeout time.Duration) (string, error) {
+	if timeout > 0 {
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return "", fmt.Errorf("failed to set backend read deadline: %w", err)
+		}
+		defer func() {
+			if err := conn.SetReadDeadline(time.Time{}); err != nil {
+				s.DebugLog("failed to clear backend read deadline", "error", err)
+			}
+		}()
+	}
+	return server.ReadBoundedLine(reader, backendResponseLineMax)
+}
@@ -0,0 +1,306 @@
+package lmtpproxy
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/migadu/sora/server"
+	"github.com/migadu/sora/server/proxy"
+)
+
+// fakeLMTPBackend accepts connections and answers every recipient of a
+// transaction with reply after the end of DATA. DATA is refused when a
+// recipient contains "nodata". The commands and message received on each
+// connection are sent on transcript when the connection ends.
+func fakeLMTPBackend(t *testing.T, reply string) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	transcript := make(chan string, 4)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeLMTP(c, reply, transcript)
+		}
+	}()
+	return ln.Addr().String(), transcript
+}
+
+func serveFakeLMTP(c net.Conn, reply string, transcript chan<- string) {
+	defer c.Close()
+	var seen strings.Builder
+	defer func() { transcript <- seen.String() }()
+
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	w.WriteString("220 backend ready\r\n")
+	w.Flush()
+	rcpts := 0
+	refuse := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		seen.WriteString(line)
+		switch cmd := strings.ToUpper(line); {
+		case strings.HasPrefix(cmd, "LHLO"):
+			w.WriteString("250-backend\r\n250 PIPELINING\r\n")
+		case strings.HasPrefix(cmd, "RCPT"):
+			rcpts++
+			refuse = refuse || strings.Contains(cmd, "NODATA")
+			w.WriteString("250 2.1.5 Ok\r\n")
+		case strings.HasPrefix(cmd, "DATA") && refuse:
+			w.WriteString("451 4.3.0 Not now\r\n")
+		case strings.HasPrefix(cmd, "DATA"):
+			w.WriteString("354 Go ahead\r\n")
+			w.Flush()
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				seen.WriteString(line)
+				if line == ".\r\n" {
+					break
+				}
+			}
+			for i := 0; i < rcpts; i++ {
+				w.WriteString(reply + "\r\n")
+			}
+			rcpts = 0
+		default:
+			w.WriteString("250 2.0.0 Ok\r\n")
+		}
+		w.Flush()
+	}
+}
+
+// rcptTo routes the next recipient to backend the way handleRecipient and the
+// RCPT handler do, and forwards it.
+func rcptTo(t *testing.T, s *Session, to, backend string) {
+	t.Helper()
+	addr, err := server.NewAddress(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.to, s.toAddress, s.username, s.originalAddress = to, &addr, to, to
+	s.isRemoteLookupAccount = true
+	s.routingInfo = &proxy.UserRoutingInfo{ServerAddress: backend, IsRemoteLookupAccount: true}
+
+	line := "RCPT TO:<" + to + ">"
+	if s.backendConn == nil {
+		if err := s.connectToBackend(); err != nil {
+			t.Fatalf("connectToBackend: %v", err)
+		}
+		s.forwardRCPT(line)
+		return
+	}
+	if backend == s.serverAddr {
+		s.forwardRCPT(line)
+		return
+	}
+	leg, err := s.extraBackend(backend)
+	if err != nil || leg == nil {
+		t.Fatalf("extraBackend(%s) = %v, %v", backend, leg, err)
+	}
+	s.onLeg(leg, func() { s.forwardRCPT(line) })
+}
+
+func TestFanoutData(t *testing.T) {
+	addr1, transcript1 := fakeLMTPBackend(t, "250 2.0.0 Delivered")
+	addr2, transcript2 := fakeLMTPBackend(t, "452 4.2.2 Mailbox full")
+	cm, err := proxy.NewConnectionManager([]string{addr1, addr2}, 24, false, false, false, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create connection manager: %v", err)
+	}
+
+	clientConn, clientPeer := net.Pipe()
+	defer clientConn.Close()
+	replies := make(chan string, 16)
+	go func() {
+		r := bufio.NewReader(clientPeer)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(replies)
+				return
+			}
+			replies <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+
+	message := "Subject: hi\r\n\r\n..dot-stuffed\r\nbody\r\n.\r\n"
+	s := &Session{
+		server:               &Server{name: "test", hostname: "proxy", connManager: cm},
+		clientConn:           clientConn,
+		clientReader:         bufio.NewReader(strings.NewReader(message)),
+		clientWriter:         bufio.NewWriter(clientConn),
+		ctx:                  context.Background(),
+		registeredAccountIDs: make(map[int64]struct{}),
+		sender:               "sender@example.com",
+		mailFromReceived:     true,
+	}
+
+	rcptTo(t, s, "a@example.com", addr1)
+	rcptTo(t, s, "b@example.com", addr2)
+	rcptTo(t, s, "c@example.com", addr1)
+	if len(s.extraBackends) != 1 {
+		t.Fatalf("expected one extra backend, got %d", len(s.extraBackends))
+	}
+
+	if !s.fanoutData() {
+		t.Fatal("fanoutData reported an unusable client connection")
+	}
+	clientConn.Close()
+
+	var got []string
+	for line := range replies {
+		got = append(got, line)
+	}
+	want := []string{
+		"250 2.1.5 Ok", "250 2.1.5 Ok", "250 2.1.5 Ok",
+		"354 Start mail input; end with <CRLF>.<CRLF>",
+		"250 2.0.0 Delivered", "452 4.2.2 Mailbox full", "250 2.0.0 Delivered",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("client replies:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for i, transcript := range []<-chan string{transcript1, transcript2} {
+		seen := <-transcript
+		if !strings.Contains(seen, "MAIL FROM:<sender@example.com>\r\n") || !strings.Contains(seen, "\r\n..dot-stuffed\r\nbody\r\n.\r\n") {
+			t.Errorf("backend %d transcript:\n%s", i+1, seen)
+		}
+	}
+	if s.backendConn != nil || s.extraBackends != nil || s.rcptConns != nil || s.mailFromReceived {
+		t.Error("transaction state not reset after fan-out")
+	}
+}
+
+func TestFanoutDataWithoutOpenBackend(t *testing.T) {
+	clientConn, clientPeer := net.Pipe()
+	defer clientConn.Close()
+	defer clientPeer.Close()
+	reply := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(clientPeer).ReadString('\n')
+		reply <- line
+	}()
+
+	// A recipient whose backend connection was dropped after RCPT
+	dead, peer := net.Pipe()
+	peer.Close()
+	dead.Close()
+	s := &Session{
+		server:        &Server{name: "test", connManager: newLMTPTestConnManager(t, "127.0.0.1:1", time.Second)},
+		clientConn:    clientConn,
+		clientWriter:  bufio.NewWriter(clientConn),
+		ctx:           context.Background(),
+		extraBackends: []*backendLeg{{addr: "127.0.0.1:1"}},
+		rcptConns:     []net.Conn{dead},
+	}
+
+	result := make(chan bool, 1)
+	go func() { result <- s.fanoutData() }()
+	select {
+	case ok := <-result:
+		if !ok {
+			t.Fatal("fanoutData reported an unusable client connection")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fanoutData hung without an open backend")
+	}
+	if got := <-reply; got != "451 4.4.2 Backend error\r\n" {
+		t.Errorf("DATA reply = %q", got)
+	}
+	if s.rcptConns != nil || s.extraBackends != nil {
+		t.Error("transaction state not reset")
+	}
+}
+
+func TestFanoutAfterRejectedData(t *testing.T) {
+	addr1, transcript1 := fakeLMTPBackend(t, "250 2.0.0 Delivered")
+	addr2, _ := fakeLMTPBackend(t, "250 2.0.0 Delivered")
+	cm, err := proxy.NewConnectionManager([]string{addr1, addr2}, 24, false, false, false, 2*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create connection manager: %v", err)
+	}
+
+	clientConn, clientPeer := net.Pipe()
+	defer clientConn.Close()
+	replies := make(chan string, 16)
+	go func() {
+		r := bufio.NewReader(clientPeer)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(replies)
+				return
+			}
+			replies <- strings.TrimRight(line, "\r\n")
+		}
+	}()
+
+	s := &Session{
+		server:               &Server{name: "test", hostname: "proxy", connManager: cm},
+		clientConn:           clientConn,
+		clientReader:         bufio.NewReader(strings.NewReader("Subject: hi\r\n\r\nbody\r\n.\r\n")),
+		clientWriter:         bufio.NewWriter(clientConn),
+		ctx:                  context.Background(),
+		registeredAccountIDs: make(map[int64]struct{}),
+		sender:               "sender@example.com",
+		mailFromReceived:     true,
+	}
+
+	// The backend refuses DATA, and the client starts over without RSET
+	rcptTo(t, s, "nodata@example.com", addr1)
+	if s.forwardData() {
+		t.Fatal("forwardData accepted a refused DATA")
+	}
+	_, _, args, err := server.ParseLine("MAIL FROM:<other@example.com>", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.handleMail(args)
+	rcptTo(t, s, "a@example.com", addr1)
+	rcptTo(t, s, "b@example.com", addr2)
+	if len(s.rcptConns) != 2 {
+		t.Fatalf("rcptConns = %d, want 2", len(s.rcptConns))
+	}
+	if !s.fanoutData() {
+		t.Fatal("fanoutData reported an unusable client connection")
+	}
+	clientConn.Close()
+
+	var got []string
+	for line := range replies {
+		got = append(got, line)
+	}
+	want := []string{
+		"250 2.1.5 Ok", "451 4.3.0 Not now",
+		"250 2.1.0 Ok", "250 2.1.5 Ok", "250 2.1.5 Ok",
+		"354 Start mail input; end with <CRLF>.<CRLF>",
+		"250 2.0.0 Delivered", "250 2.0.0 Delivered",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("client replies:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The second transaction ran on a fresh connection with its own sender
+	<-transcript1
+	if seen := <-transcript1; !strings.Contains(seen, "MAIL FROM:<other@example.com>\r\n") || strings.Contains(seen, "nodata") {
+		t.Errorf("backend 1 transcript of the second transaction:\n%s", seen)
+	}
+}
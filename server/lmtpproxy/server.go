@@ -546,6 +546,10 @@ func (s *Server) SetConnectionTracker(tracker *server.ConnectionTracker) {
 	if tracker != nil && s.lookupCache != nil {
 		tracker.SetLookupCache(s.lookupCache)
 	}
+	// Per-backend session counts drive least-connections balancing and drain kicks
+	if tracker != nil && s.connManager != nil {
+		s.connManager.SetBackendSessions(tracker)
+	}
 }
 
 // GetConnectionTracker returns the connection tracker for the server.
@@ -16,6 +16,7 @@ import (
 	"github.com/migadu/sora/pkg/lookupcache"
 	"github.com/migadu/sora/pkg/metrics"
 	"github.com/migadu/sora/server"
+	"github.com/migadu/sora/server/delivery"
 	"github.com/migadu/sora/server/idgen"
 	"github.com/migadu/sora/server/proxy"
 )
@@ -30,6 +31,7 @@ type Session struct {
 	clientReader          *bufio.Reader
 	clientWriter          *bufio.Writer
 	sender                string
+	mailParams            string // MAIL FROM parameters replayed to each backend (see mailParameters)
 	mailFromReceived      bool
 	to                    string
 	toAddress             *server.Address // Parsed recipient address with detail
@@ -57,6 +59,16 @@ type Session struct {
 	// IMPORTANT: s.accountID is overwritten per RCPT; if we only unregister the last one,
 	// earlier recipients would leak connection counts permanently.
 	registeredAccountIDs map[int64]struct{}
+
+	// Transactions whose recipients route to several backends: every backend
+	// other than backendConn's gets a leg (see fanout.go). rcptConns holds the
+	// backend connection that accepted each recipient, in RCPT order.
+	extraBackends []*backendLeg
+	rcptConns     []net.Conn
+
+	// bdat is the message being transferred with BDAT, from the first chunk
+	// until the LAST one (see chunking.go).
+	bdat *bdatTransfer
 }
 
 // newSession creates a new LMTP proxy session.
@@ -110,6 +122,11 @@ func (s *Session) handleConnection() {
 		if s.backendConn != nil {
 			s.backendConn.Close()
 		}
+		for _, leg := range s.extraBackends {
+			if leg.conn != nil {
+				leg.conn.Close()
+			}
+		}
 		s.mu.Unlock()
 	}()
 
@@ -191,6 +208,12 @@ func (s *Session) handleConnection() {
 			continue // Ignore empty lines
 		}
 
+		// Until its LAST chunk, a BDAT transfer only allows BDAT, RSET, NOOP and QUIT
+		if s.bdat != nil && (command == "MAIL" || command == "RCPT" || command == "DATA") {
+			s.sendResponse("503 5.5.1 Bad sequence of commands (BDAT in progress)")
+			continue
+		}
+
 		switch command {
 		case "HELO", "EHLO", "LHLO":
 			// LHLO is LMTP-specific greeting
@@ -219,22 +242,16 @@ func (s *Session) handleConnection() {
 				}
 				s.sendResponse("250-ENHANCEDSTATUSCODES")
 				s.sendResponse("250-8BITMIME")
+				s.sendResponse("250-CHUNKING")
+				s.sendResponse("250-BINARYMIME")
+				s.sendResponse("250-SMTPUTF8")
 				s.sendResponse("250 DSN")
 			} else {
 				s.sendResponse(fmt.Sprintf("250 %s", s.server.hostname))
 			}
 
 		case "MAIL":
-			fromParam, found := findParameter(args, "FROM:")
-			if !found {
-				s.sendResponse("501 5.5.4 Syntax error in MAIL command (missing FROM)")
-				continue
-			}
-			// Note: extractAddress can return an empty string for a null sender "<>", which is valid.
-			sender := s.extractAddress(fromParam)
-			s.sender = sender
-			s.mailFromReceived = true
-			s.sendResponse("250 2.1.0 Ok")
+			s.handleMail(args)
 
 		case "RCPT":
 			toParam, found := findParameter(args, "TO:")
@@ -255,6 +272,10 @@ func (s *Session) handleConnection() {
 				continue
 			}
 			s.DebugLog("Extracted recipient address", "to", to)
+			if !server.IsASCII(to) && !s.hasMailParameter("SMTPUTF8") {
+				s.sendResponse("553 5.6.7 UTF-8 addresses require SMTPUTF8")
+				continue
+			}
 
 			lookupStart := time.Now() // Start account lookup timing
 			if err := s.handleRecipient(to, lookupStart); err != nil {
@@ -352,16 +373,24 @@ func (s *Session) handleConnection() {
 				if err == nil {
 					targetAddr := routeResult.PreferredAddr
 					// If a specific backend is required (targetAddr not empty) and it differs from
-					// the currently connected backend, we must reject this recipient.
-					// The client should retry this recipient in a separate transaction.
+					// the currently connected backend, the recipient goes to its own backend
+					// connection within this transaction; DATA is then fanned out to all of them.
 					if targetAddr != "" && targetAddr != s.serverAddr {
-						s.InfoLog("rejecting recipient - routing mismatch (requires different backend)",
+						s.DebugLog("Recipient routes to another backend",
 							"to", s.to,
 							"current_backend", s.serverAddr,
 							"target_backend", targetAddr,
 							"method", routeResult.RoutingMethod)
-						s.sendResponse("451 4.3.0 Recipient requires different backend server, start new transaction")
-						continue
+						leg, err := s.extraBackend(targetAddr)
+						if err != nil {
+							s.InfoLog("backend connection failed", "from", s.sender, "to", s.to, "backend", targetAddr, "error", err)
+							s.sendResponse("451 4.4.1 Backend connection failed")
+							continue
+						}
+						if leg != nil {
+							s.onLeg(leg, func() { s.forwardRCPT(line) })
+							continue
+						}
 					}
 				}
 			}
@@ -377,50 +406,17 @@ func (s *Session) handleConnection() {
 			// Continue loop to handle subsequent RCPT TOs or DATA
 
 		case "DATA":
-			if s.backendConn == nil {
-				s.sendResponse("503 5.5.1 Bad sequence of commands (not connected to backend)")
-				continue
-			}
-
-			// Send DATA to backend
-			_, err := s.backendWriter.WriteString("DATA\r\n")
-			if err != nil {
-				s.DebugLog("Failed to send DATA to backend", "error", err)
-				s.sendResponse("451 4.4.2 Backend error")
-				// Connection likely dead, close it?
-				s.backendConn.Close()
-				s.mu.Lock()
-				s.backendConn = nil
-				s.mu.Unlock()
-				continue
-			}
-			s.backendWriter.Flush()
-
-			// Read DATA response (bounded, with read deadline so a hung backend
-			// cannot block the session forever)
-			response, err := s.readBackendLine()
-			if err != nil {
-				s.DebugLog("Failed to read DATA response", "error", err)
-				s.sendResponse("451 4.4.2 Backend error")
-				s.backendConn.Close()
-				s.mu.Lock()
-				s.backendConn = nil
-				s.mu.Unlock()
+			if len(s.extraBackends) > 0 {
+				// Recipients span several backends: the proxy runs DATA itself
+				if !s.fanoutData() {
+					return
+				}
 				continue
 			}
-
-			// Check response
-			if !strings.HasPrefix(response, "354") {
-				// Backend rejected DATA
-				s.clientWriter.WriteString(response)
-				s.clientWriter.Flush()
+			if !s.forwardData() {
 				continue
 			}
 
-			// Backend accepted DATA (354), forward to client
-			s.clientWriter.WriteString(response)
-			s.clientWriter.Flush()
-
 			// Enter pipe mode for data transfer
 			s.DebugLog("Entering pipe mode for DATA transfer")
 			s.enterPipeMode()
@@ -492,8 +488,22 @@ func (s *Session) handleConnection() {
 			// Client must send EHLO/LHLO again after STARTTLS (RFC 3207)
 			// Continue to next iteration to wait for new EHLO/LHLO
 
+		case "BDAT":
+			// BDAT always goes through the fan-out path (chunking.go), which
+			// also serves a single backend
+			if !s.handleBdat(args) {
+				return
+			}
+
 		case "RSET":
+			if s.bdat != nil {
+				// The backends hold part of the message: start over on fresh connections
+				s.resetFanout()
+			} else {
+				s.closeExtraBackends()
+			}
 			s.sender = ""
+			s.mailParams = ""
 			s.to = ""
 			s.mailFromReceived = false
 			s.sendResponse("250 2.0.0 Ok")
@@ -852,6 +862,13 @@ func (s *Session) handleRecipient(to string, lookupStart time.Time) error {
 					"duration", fmt.Sprintf("%.3fs", duration.Seconds()))
 				metrics.RemoteLookupResult.WithLabelValues("lmtp", "user_not_found_fallback").Inc()
 				// Fallthrough to main DB
+			} else if s.server.rdb != nil && s.isMailRoute() {
+				// Groups, catch-alls and domain aliases live in the main DB only
+				s.InfoLog("user not found in remote lookup, address is a mail route - using main DB",
+					"username", s.username,
+					"duration", fmt.Sprintf("%.3fs", duration.Seconds()))
+				metrics.RemoteLookupResult.WithLabelValues("lmtp", "user_not_found_route").Inc()
+				// Fallthrough to main DB
 			} else {
 				// User not found and no database fallback - use configured response
 				response := "reject" // default
@@ -898,6 +915,20 @@ func (s *Session) handleRecipient(to string, lookupStart time.Time) error {
 	// Use GetActiveAccountIDByAddressWithRetry which properly handles ErrUserNotFound
 	// as a business logic error (not a circuit breaker failure)
 	accountID, err := s.server.rdb.GetActiveAccountIDByAddressWithRetry(dbCtx, s.username)
+	if errors.Is(err, consts.ErrUserNotFound) {
+		// Not a login credential: the backend expands groups, catch-alls and
+		// domain aliases, so route by the first account the address reaches
+		var resolution *delivery.Resolution
+		if resolution, err = delivery.ResolveRecipient(dbCtx, s.server.rdb, s.username); err == nil {
+			accountID = 0 // External targets only: any backend
+			if len(resolution.Accounts) > 0 {
+				accountID = resolution.Accounts[0].AccountID
+			}
+			s.DebugLog("recipient is a mail route", "address", s.username, "account_id", accountID)
+		} else if errors.Is(err, delivery.ErrRecipientNotFound) || errors.Is(err, delivery.ErrRouteTooDeep) {
+			err = consts.ErrUserNotFound
+		}
+	}
 	if err != nil {
 		// Check if error is due to session context cancellation (server shutdown)
 		// Note: Must check s.ctx.Err(), not just the query error, because the query context
@@ -975,6 +1006,23 @@ func (s *Session) handleRecipient(to string, lookupStart time.Time) error {
 }
 
 // connectToBackend establishes a connection to the backend server.
+// isMailRoute reports whether the recipient is a group, catch-all or
+// domain alias address in the main DB. A login credential is not a route: the
+// remote lookup is authoritative for those. Lookup errors report true, so the
+// main DB lookup that follows surfaces them as a temporary failure instead of
+// the recipient being rejected.
+func (s *Session) isMailRoute() bool {
+	dbCtx, dbCancel := context.WithTimeout(s.ctx, s.server.rdb.GetQueryTimeout())
+	defer dbCancel()
+
+	resolution, err := delivery.ResolveRecipient(dbCtx, s.server.rdb, s.username)
+	if err != nil {
+		return !errors.Is(err, delivery.ErrRecipientNotFound) && !errors.Is(err, delivery.ErrRouteTooDeep)
+	}
+	account, single := resolution.Single()
+	return !single || account.Address != s.username
+}
+
 func (s *Session) connectToBackend() error {
 	routeResult, err := proxy.DetermineRoute(proxy.RouteParams{
 		Ctx:                   s.ctx,
@@ -1232,7 +1280,7 @@ func (s *Session) connectToBackend() error {
 
 	// Send MAIL FROM to backend
 	if s.mailFromReceived {
-		mailCmd := fmt.Sprintf("MAIL FROM:<%s>\r\n", s.sender)
+		mailCmd := fmt.Sprintf("MAIL FROM:<%s>%s\r\n", s.sender, s.mailParams)
 		_, err = s.backendWriter.WriteString(mailCmd)
 		if err != nil {
 			s.backendConn.Close()
@@ -1272,18 +1320,7 @@ const maxLHLOResponseLines = 64
 // unaffected. Without this, a backend that accepted the connection but then
 // went silent kept the session goroutine blocked forever.
 func (s *Session) readBackendLine() (string, error) {
-	timeout := s.server.connManager.GetConnectTimeout()
-	if timeout > 0 {
-		if err := s.backendConn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
-			return "", fmt.Errorf("failed to set backend read deadline: %w", err)
-		}
-		defer func() {
-			if err := s.backendConn.SetReadDeadline(time.Time{}); err != nil {
-				s.DebugLog("failed to clear backend read deadline", "error", err)
-			}
-		}()
-	}
-	return server.ReadBoundedLine(s.backendReader, backendResponseLineMax)
+	return s.readLineFrom(s.backendConn, s.backendReader, s.server.connManager.GetConnectTimeout())
 }
 
 // invalidateLookupCache removes the cached routing entry for the originally
@@ -1410,11 +1447,80 @@ func (s *Session) forwardRCPT(command string) {
 	// Backend accepted (2xx) - forward response to client
 	s.clientWriter.WriteString(response)
 	s.clientWriter.Flush()
+	s.rcptConns = append(s.rcptConns, s.backendConn)
 
 	// Log routing decision at INFO level with sender, recipient, and routing method
 	s.InfoLog("routing to backend", "backend", s.serverAddr, "method", s.routingMethod, "from", s.sender, "to", s.to)
 }
 
+// handleMail starts a new transaction. Backend connections left by an earlier
+// transaction are dropped, so the recipients of this one reconnect and get
+// this MAIL FROM replayed.
+func (s *Session) handleMail(args []string) {
+	fromParam, found := findParameter(args, "FROM:")
+	if !found {
+		s.sendResponse("501 5.5.4 Syntax error in MAIL command (missing FROM)")
+		return
+	}
+	// Note: extractAddress can return an empty string for a null sender "<>", which is valid.
+	sender := s.extractAddress(fromParam)
+	mailParams := mailParameters(args)
+	s.mailParams = mailParams
+	if !server.IsASCII(sender) && !s.hasMailParameter("SMTPUTF8") {
+		s.mailParams = ""
+		s.sendResponse("553 5.6.7 UTF-8 addresses require SMTPUTF8")
+		return
+	}
+	s.resetFanout()
+	s.sender = sender
+	s.mailParams = mailParams
+	s.mailFromReceived = true
+	s.sendResponse("250 2.1.0 Ok")
+}
+
+// forwardData sends DATA to the session's single backend and relays its
+// reply. It returns true when the backend accepted (354) and the message can
+// be piped. Otherwise the transaction is over and its backend connections are
+// dropped, like after a fan-out DATA.
+func (s *Session) forwardData() bool {
+	if s.backendConn == nil {
+		s.resetFanout()
+		s.sendResponse("503 5.5.1 Bad sequence of commands (not connected to backend)")
+		return false
+	}
+
+	// Send DATA to backend
+	_, err := s.backendWriter.WriteString("DATA\r\n")
+	if err == nil {
+		err = s.backendWriter.Flush()
+	}
+	if err != nil {
+		s.DebugLog("Failed to send DATA to backend", "error", err)
+		s.resetFanout()
+		s.sendResponse("451 4.4.2 Backend error")
+		return false
+	}
+
+	// Read DATA response (bounded, with read deadline so a hung backend
+	// cannot block the session forever)
+	response, err := s.readBackendLine()
+	if err != nil {
+		s.DebugLog("Failed to read DATA response", "error", err)
+		s.resetFanout()
+		s.sendResponse("451 4.4.2 Backend error")
+		return false
+	}
+
+	s.clientWriter.WriteString(response)
+	s.clientWriter.Flush()
+	if !strings.HasPrefix(response, "354") {
+		// Backend rejected DATA
+		s.resetFanout()
+		return false
+	}
+	return true
+}
+
 // enterPipeMode enters the data piping mode for transfer of message content.
 func (s *Session) enterPipeMode() {
 	if s.backendConn == nil {
@@ -1639,6 +1745,11 @@ func (s *Session) close() {
 	if s.backendConn != nil {
 		s.backendConn.Close()
 	}
+	for _, leg := range s.extraBackends {
+		if leg.conn != nil {
+			leg.conn.Close()
+		}
+	}
 }
 
 // registerConnection registers the connection in the database.
@@ -1655,6 +1766,11 @@ func (s *Session) registerConnection() error {
 
 	// Use cached client address (real IP) to match UnregisterConnection in close()
 	if s.server.connTracker != nil {
+		// A session counts once per account, however many backend connections
+		// (fan-out legs, reconnects after a fanned-out transaction) it opens.
+		if _, ok := s.registeredAccountIDs[s.accountID]; ok {
+			return nil
+		}
 		err := s.server.connTracker.RegisterConnection(ctx, s.accountID, s.username, "LMTP", s.clientAddr)
 		if err != nil {
 			return err
@@ -1679,7 +1795,7 @@ func (s *Session) updateActivityPeriodically(ctx context.Context) {
 	}
 
 	// Register for kick notifications
-	kickChan := s.server.connTracker.RegisterSession(s.accountID)
+	kickChan := s.server.connTracker.RegisterBackendSession(s.accountID, s.serverAddr)
 	defer s.server.connTracker.UnregisterSession(s.accountID, kickChan)
 
 	for {
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/migadu/sora/logger"
+)
+
+const (
+	// loginHistoryQueueSize bounds records waiting to be written. Records are
+	// dropped when the database falls this far behind.
+	loginHistoryQueueSize = 10000
+
+	// loginHistoryBatchSize is the maximum number of records written at once
+	loginHistoryBatchSize = 200
+
+	// loginHistoryFlushInterval is how long a record waits for a batch to fill
+	loginHistoryFlushInterval = time.Second
+)
+
+// LoginRecord is one authentication attempt in an account's login history.
+type LoginRecord struct {
+	AccountID int64     `json:"-"`                         // 0 = resolve from Username when stored
+	Username  string    `json:"username"`                  // Address used to log in
+	Protocol  string    `json:"protocol"`                  // "imap", "pop3", "managesieve", "userapi"
+	IP        string    `json:"ip"`                        // Client IP (the real client when proxied)
+	ClientID  string    `json:"client_id,omitempty"`       // IMAP ID name/version or HTTP User-Agent
+	JA4       string    `json:"ja4_fingerprint,omitempty"` // TLS client fingerprint when known
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"` // Failure reason
+	Time      time.Time `json:"time"`
+}
+
+// LoginHistoryStore persists login records (implemented by
+// resilient.ResilientDatabase).
+type LoginHistoryStore interface {
+	RecordLoginsWithRetry(ctx context.Context, records []LoginRecord, maxPerAccount int) error
+}
+
+// LoginHistory writes login records to the database in the background so
+// authentication never waits on it. A nil *LoginHistory is valid and drops
+// every record, so callers do not need to check whether it is enabled.
+type LoginHistory struct {
+	store         LoginHistoryStore
+	maxPerAccount int
+	queue         chan LoginRecord
+	wg            sync.WaitGroup
+}
+
+// NewLoginHistory creates a login history that keeps the newest maxPerAccount
+// records of each account.
+func NewLoginHistory(store LoginHistoryStore, maxPerAccount int) *LoginHistory {
+	return &LoginHistory{
+		store:         store,
+		maxPerAccount: maxPerAccount,
+		queue:         make(chan LoginRecord, loginHistoryQueueSize),
+	}
+}
+
+// Start runs the writer until ctx is cancelled; queued records are flushed on
+// the way out.
+func (h *LoginHistory) Start(ctx context.Context) {
+	if h == nil {
+		return
+	}
+	h.wg.Add(1)
+	go h.run(ctx)
+}
+
+// Wait blocks until the writer has stopped.
+func (h *LoginHistory) Wait() {
+	if h == nil {
+		return
+	}
+	h.wg.Wait()
+}
+
+// Record queues a login record. It never blocks: when the queue is full the
+// record is dropped.
+func (h *LoginHistory) Record(rec LoginRecord) {
+	if h == nil || rec.Username == "" {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	select {
+	case h.queue <- rec:
+	default:
+		logger.Debug("Login history: Queue full, dropping record", "username", rec.Username, "protocol", rec.Protocol)
+	}
+}
+
+func (h *LoginHistory) run(ctx context.Context) {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(loginHistoryFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]LoginRecord, 0, loginHistoryBatchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := h.store.RecordLoginsWithRetry(ctx, batch, h.maxPerAccount); err != nil {
+			logger.Warn("Login history: Failed to store records", "count", len(batch), "error", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case rec := <-h.queue:
+			batch = append(batch, rec)
+			if len(batch) >= loginHistoryBatchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			// Drain what is queued with a short deadline of its own
+		drain:
+			for {
+				select {
+				case rec := <-h.queue:
+					batch = append(batch, rec)
+				default:
+					break drain
+				}
+			}
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			flush(flushCtx)
+			cancel()
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLoginHistoryStore struct {
+	mu      sync.Mutex
+	records []LoginRecord
+	max     int
+}
+
+func (f *fakeLoginHistoryStore) RecordLoginsWithRetry(_ context.Context, records []LoginRecord, maxPerAccount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records = append(f.records, records...)
+	f.max = maxPerAccount
+	return nil
+}
+
+func (f *fakeLoginHistoryStore) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.records)
+}
+
+func TestLoginHistoryFlush(t *testing.T) {
+	store := &fakeLoginHistoryStore{}
+	h := NewLoginHistory(store, 25)
+	ctx, cancel := context.WithCancel(context.Background())
+	h.Start(ctx)
+
+	h.Record(LoginRecord{AccountID: 1, Username: "user@example.com", Protocol: "imap", IP: "192.0.2.1", Success: true})
+	h.Record(LoginRecord{Username: "user@example.com", Protocol: "pop3", IP: "192.0.2.2", Reason: "invalid credentials"})
+	h.Record(LoginRecord{Protocol: "imap"}) // No username: dropped
+
+	deadline := time.Now().Add(5 * time.Second)
+	for store.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := store.count(); got != 2 {
+		t.Fatalf("stored %d records after flush interval, want 2", got)
+	}
+	if store.max != 25 {
+		t.Errorf("maxPerAccount = %d, want 25", store.max)
+	}
+	if store.records[0].Time.IsZero() {
+		t.Error("Record should set the time")
+	}
+
+	// Records queued at shutdown are flushed before Wait returns
+	h.Record(LoginRecord{AccountID: 1, Username: "user@example.com", Protocol: "imap", Success: true})
+	cancel()
+	h.Wait()
+	if got := store.count(); got != 3 {
+		t.Errorf("stored %d records after shutdown, want 3", got)
+	}
+}
+
+func TestLoginHistoryNil(t *testing.T) {
+	var h *LoginHistory
+	h.Start(context.Background())
+	h.Record(LoginRecord{Username: "user@example.com"})
+	h.Wait()
+}
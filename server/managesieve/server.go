@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net"
 	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -25,19 +26,33 @@ import (
 	"github.com/migadu/sora/pkg/resilient"
 	serverPkg "github.com/migadu/sora/server"
 	"github.com/migadu/sora/server/idgen"
+	"github.com/migadu/sora/server/sieveengine"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Re-exports of the SIEVE extension vocabulary, which moved to the
-// go-managesieve library with the protocol extraction. Kept as package-level
-// names because sieveengine and configuration validation reference them.
+// go-managesieve library with the protocol extraction. The lists include the
+// RFC 5703 MIME extensions that sieveengine implements on top of go-sieve.
+// Kept as package-level names because configuration validation references them.
 var (
-	SupportedExtensions      = msieve.SupportedExtensions
-	DefaultEnabledExtensions = msieve.DefaultEnabledExtensions
-	FilterExtensions         = msieve.FilterExtensions
+	SupportedExtensions      = sieveengine.SupportedSieveExtensions
+	DefaultEnabledExtensions = sieveengine.DefaultSieveExtensions
 	GetSieveCapabilities     = msieve.GetSieveCapabilities
 )
 
+// FilterExtensions splits extensions into those Sora supports and the rest.
+// It mirrors msieve.FilterExtensions but also accepts the MIME extensions.
+func FilterExtensions(extensions []string) (valid []string, invalid []string) {
+	for _, ext := range extensions {
+		if slices.Contains(SupportedExtensions, ext) {
+			valid = append(valid, ext)
+		} else {
+			invalid = append(invalid, ext)
+		}
+	}
+	return valid, invalid
+}
+
 // getProxyProtocolTrustedProxies returns proxy_protocol_trusted_proxies if set, otherwise falls back to trusted_networks
 func getProxyProtocolTrustedProxies(proxyProtocolTrusted, trustedNetworks []string) []string {
 	if len(proxyProtocolTrusted) > 0 {
@@ -95,6 +110,12 @@ type ManageSieveServer struct {
 	// Connection tracking
 	connTracker *serverPkg.ConnectionTracker
 
+	// Per-account login history (optional)
+	loginHistory *serverPkg.LoginHistory
+
+	// Suspicious login detection (optional)
+	suspiciousLogins *serverPkg.SuspiciousLoginDetector
+
 	// Startup throttle to prevent thundering herd on restart
 	startupThrottleUntil time.Time
 
@@ -135,13 +156,15 @@ type ManageSieveServerOptions struct {
 	ProxyProtocolTrustedProxies []string // CIDR blocks for PROXY protocol validation (defaults to trusted_networks if empty)
 	TrustedNetworks             []string // Global trusted networks for parameter forwarding
 	AuthRateLimit               serverPkg.AuthRateLimiterConfig
-	LookupCache                 *config.LookupCacheConfig // Authentication cache configuration
-	AuthIdleTimeout             time.Duration             // Idle timeout during authentication phase (pre-auth only, 0 = disabled)
-	CommandTimeout              time.Duration             // Maximum idle time before disconnection
-	CommandTimeoutOverrides     map[string]time.Duration  // Per-command hard execution timeouts (overrides defaults)
-	AbsoluteSessionTimeout      time.Duration             // Maximum total session duration (0 = use default 30m)
-	MinBytesPerMinute           int64                     // Minimum throughput to prevent slowloris (0 = use default 512 bytes/min)
-	Config                      *config.Config            // Full config for shared settings like connection tracking timeouts
+	LookupCache                 *config.LookupCacheConfig          // Authentication cache configuration
+	AuthIdleTimeout             time.Duration                      // Idle timeout during authentication phase (pre-auth only, 0 = disabled)
+	CommandTimeout              time.Duration                      // Maximum idle time before disconnection
+	CommandTimeoutOverrides     map[string]time.Duration           // Per-command hard execution timeouts (overrides defaults)
+	AbsoluteSessionTimeout      time.Duration                      // Maximum total session duration (0 = use default 30m)
+	MinBytesPerMinute           int64                              // Minimum throughput to prevent slowloris (0 = use default 512 bytes/min)
+	Config                      *config.Config                     // Full config for shared settings like connection tracking timeouts
+	LoginHistory                *serverPkg.LoginHistory            // Per-account login history (optional)
+	SuspiciousLogins            *serverPkg.SuspiciousLoginDetector // Suspicious login detection (optional)
 }
 
 func New(appCtx context.Context, name, hostname, addr string, rdb *resilient.ResilientDatabase, options ManageSieveServerOptions) (*ManageSieveServer, error) {
@@ -282,6 +305,8 @@ func New(appCtx context.Context, name, hostname, addr string, rdb *resilient.Res
 		absoluteSessionTimeout: options.AbsoluteSessionTimeout,
 		minBytesPerMinute:      options.MinBytesPerMinute,
 		activeSessions:         make(map[*ManageSieveSession]struct{}),
+		loginHistory:           options.LoginHistory,
+		suspiciousLogins:       options.SuspiciousLogins,
 	}
 
 	// Apply operator overrides for per-command execution timeouts
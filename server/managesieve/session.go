@@ -6,18 +6,17 @@ import (
 	"errors"
 	"fmt"
 	"net"
-	"strings"
 	"sync"
 	"time"
 
 	msieve "github.com/migadu/go-managesieve/managesieve"
 	"github.com/migadu/go-managesieve/managesieveserver"
-	"github.com/migadu/go-sieve"
 	"github.com/migadu/sora/consts"
 	"github.com/migadu/sora/db"
 	"github.com/migadu/sora/logger"
 	"github.com/migadu/sora/pkg/metrics"
 	"github.com/migadu/sora/server"
+	"github.com/migadu/sora/server/sieveengine"
 )
 
 const ManageSieveMaxLineLength = 8192 // ManageSieve commands can be longer than POP3
@@ -84,6 +83,7 @@ func (s *ManageSieveSession) AuthenticatePlain(ctx context.Context, authzID, aut
 
 	var accountID int64
 	var impersonating bool
+	var masterUsernameUsed bool // Admin impersonation, exempt from suspicious login checks
 	var targetAddress *server.Address
 
 	// 1. Check for Master Username Authentication (user@domain.com@MASTER_USERNAME)
@@ -137,6 +137,7 @@ func (s *ManageSieveSession) AuthenticatePlain(ctx context.Context, authzID, aut
 
 			targetAddress = &address
 			impersonating = true
+			masterUsernameUsed = true
 		} else {
 			// Record failed master password authentication (feeds progressive
 			// delay / blocking so the tenant-wide master password can't be
@@ -144,6 +145,7 @@ func (s *ManageSieveSession) AuthenticatePlain(ctx context.Context, authzID, aut
 			if s.server.authLimiter != nil {
 				s.server.authLimiter.RecordAuthAttemptWithProxy(ctx, netConn, proxyInfo, authnParsed.BaseAddress(), false)
 			}
+			s.recordLogin(authnParsed.BaseAddress(), 0, "invalid master credentials")
 			// Master username suffix was provided but master password was wrong - fail immediately
 			return &managesieveserver.Error{Message: "Invalid master credentials"}
 		}
@@ -235,6 +237,7 @@ func (s *ManageSieveSession) AuthenticatePlain(ctx context.Context, authzID, aut
 			if s.server.authLimiter != nil {
 				s.server.authLimiter.RecordAuthAttemptWithProxy(ctx, netConn, proxyInfo, address.FullAddress(), false)
 			}
+			s.recordLogin(address.BaseAddress(), 0, "invalid credentials")
 			s.DebugLog("authentication failed")
 			return errAuthFailed
 		}
@@ -247,6 +250,12 @@ func (s *ManageSieveSession) AuthenticatePlain(ctx context.Context, authzID, aut
 		targetAddress = &address
 	}
 
+	if !masterUsernameUsed {
+		if err := s.checkSuspiciousLogin(ctx, targetAddress.BaseAddress(), accountID); err != nil {
+			return err
+		}
+	}
+
 	if err := s.completeAuthentication(ctx, *targetAddress, accountID, impersonating, start); err != nil {
 		return err
 	}
@@ -303,6 +312,7 @@ func (s *ManageSieveSession) Login(ctx context.Context, username, password strin
 	// Master username authentication: user@domain.com@MASTER_USERNAME
 	authSuccess := false
 	masterAuthUsed := false
+	masterUsernameUsed := false // Admin impersonation, exempt from suspicious login checks
 	var accountID int64
 	if len(s.server.masterUsername) > 0 && address.HasSuffix() && checkMasterCredential(address.Suffix(), s.server.masterUsername) {
 		// Suffix matches MasterUsername, authenticate with MasterPassword
@@ -310,6 +320,7 @@ func (s *ManageSieveSession) Login(ctx context.Context, username, password strin
 			s.DebugLog("master username authentication successful", "address", address.BaseAddress(), "master_username", address.Suffix())
 			authSuccess = true
 			masterAuthUsed = true
+			masterUsernameUsed = true
 			// Use base address (without suffix) to get account
 			accountID, err = s.server.rdb.GetActiveAccountIDByAddressWithRetry(ctx, address.BaseAddress())
 			if err != nil {
@@ -326,6 +337,7 @@ func (s *ManageSieveSession) Login(ctx context.Context, username, password strin
 			if s.server.authLimiter != nil {
 				s.server.authLimiter.RecordAuthAttemptWithProxy(ctx, netConn, proxyInfo, address.BaseAddress(), false)
 			}
+			s.recordLogin(address.BaseAddress(), 0, "invalid master credentials")
 			// Master username suffix was provided but master password was wrong - fail immediately
 			return &managesieveserver.Error{Message: "Invalid master credentials"}
 		}
@@ -364,10 +376,18 @@ func (s *ManageSieveSession) Login(ctx context.Context, username, password strin
 				s.server.authLimiter.RecordAuthAttemptWithProxy(ctx, netConn, proxyInfo, address.FullAddress(), false)
 			}
 			metrics.AuthenticationAttempts.WithLabelValues("managesieve", s.server.name, s.server.hostname, "failure").Inc()
+			s.recordLogin(address.BaseAddress(), 0, "invalid credentials")
 			return errAuthFailed
 		}
 	}
 
+	if !masterUsernameUsed {
+		if err := s.checkSuspiciousLogin(ctx, address.BaseAddress(), accountID); err != nil {
+			metrics.AuthenticationAttempts.WithLabelValues("managesieve", s.server.name, s.server.hostname, "failure").Inc()
+			return err
+		}
+	}
+
 	// Record successful attempt
 	if s.server.authLimiter != nil {
 		s.server.authLimiter.RecordAuthAttemptWithProxy(ctx, netConn, proxyInfo, address.FullAddress(), true)
@@ -416,6 +436,7 @@ func (s *ManageSieveSession) completeAuthentication(ctx context.Context, address
 	// prevent a race: if the session closes between counter increments and
 	// flag setting, cleanup won't decrement.
 	s.authenticated = true
+	s.recordLogin(address.BaseAddress(), accountID, "")
 
 	// Register connection for tracking
 	s.registerConnection(ctx, address.FullAddress())
@@ -432,6 +453,39 @@ func (s *ManageSieveSession) completeAuthentication(ctx context.Context, address
 	return nil
 }
 
+// recordLogin adds a login attempt to the account's login history. An empty
+// failure reason records a successful login; failed logins pass accountID 0
+// and are matched to the account by address when stored.
+func (s *ManageSieveSession) recordLogin(address string, accountID int64, failure string) {
+	s.server.loginHistory.Record(server.LoginRecord{
+		AccountID: accountID,
+		Username:  address,
+		Protocol:  "managesieve",
+		IP:        s.RemoteIP,
+		Success:   failure == "",
+		Reason:    failure,
+	})
+}
+
+// checkSuspiciousLogin runs the suspicious login checks for a login whose
+// credentials were accepted. It returns the error to send when the login must
+// be rejected until the user confirms its source.
+func (s *ManageSieveSession) checkSuspiciousLogin(ctx context.Context, address string, accountID int64) error {
+	err := s.server.suspiciousLogins.CheckLogin(ctx, server.LoginRecord{
+		AccountID: accountID,
+		Username:  address,
+		Protocol:  "managesieve",
+		IP:        s.RemoteIP,
+	})
+	if !errors.Is(err, server.ErrLoginConfirmationRequired) {
+		return nil
+	}
+
+	s.InfoLog("login rejected until the new login source is confirmed", "address", address, "account_id", accountID)
+	s.recordLogin(address, accountID, "confirmation required")
+	return &managesieveserver.Error{Message: "Login from a new location must be confirmed in your account settings"}
+}
+
 // proxyInfo returns the PROXY-protocol peer info for rate limiting, when the
 // connection arrived through a PROXY header.
 func (s *ManageSieveSession) proxyInfo() *server.ProxyProtocolInfo {
@@ -487,15 +541,13 @@ func (s *ManageSieveSession) pinToMasterDB(command string) {
 	release()
 }
 
-// validateSieveScript validates content with go-sieve against the server's
-// enabled extensions, rendering failures as a quoted error string (safe
+// validateSieveScript validates content with the Sieve engine against the
+// server's enabled extensions, rendering failures as a quoted error string (safe
 // against response splitting even though the error echoes script tokens).
 func (s *ManageSieveSession) validateSieveScript(content string) error {
-	options := sieve.DefaultOptions()
 	// Configure extensions based on server configuration.
 	// If no extensions are configured, none are supported.
-	options.EnabledExtensions = s.server.supportedExtensions
-	if _, err := sieve.Load(strings.NewReader(content), options); err != nil {
+	if err := sieveengine.ValidateScript(content, s.server.supportedExtensions); err != nil {
 		return &managesieveserver.Error{Message: msieve.Quote("Script validation failed: " + msieve.SanitizeText(err.Error()))}
 	}
 	return nil
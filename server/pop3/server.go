@@ -98,6 +98,12 @@ type POP3Server struct {
 	// Connection tracking
 	connTracker *serverPkg.ConnectionTracker
 
+	// Per-account login history (optional)
+	loginHistory *serverPkg.LoginHistory
+
+	// Suspicious login detection (optional)
+	suspiciousLogins *serverPkg.SuspiciousLoginDetector
+
 	// Startup throttle to prevent thundering herd on restart
 	startupThrottleUntil time.Time
 
@@ -132,18 +138,20 @@ type POP3ServerOptions struct {
 	ProxyProtocolTrustedProxies []string // CIDR blocks for PROXY protocol validation (defaults to trusted_networks if empty)
 	TrustedNetworks             []string // Global trusted networks for parameter forwarding
 	AuthRateLimit               serverPkg.AuthRateLimiterConfig
-	LookupCache                 *config.LookupCacheConfig // Authentication cache configuration
-	SessionMemoryLimit          int64                     // Memory limit per session in bytes
-	AuthIdleTimeout             time.Duration             // Idle timeout during authentication phase (pre-auth only, 0 = disabled)
-	CommandTimeout              time.Duration             // Maximum idle time before disconnection
-	CommandTimeoutOverrides     map[string]time.Duration  // Per-command hard execution timeouts (overrides defaults)
-	AbsoluteSessionTimeout      time.Duration             // Maximum total session duration (0 = use default 30m)
-	MinBytesPerMinute           int64                     // Minimum throughput to prevent slowloris (0 = use default 512 bytes/min)
-	InsecureAuth                bool                      // Allow PLAIN auth over non-TLS connections (default: true for backends behind proxy)
-	Config                      *config.Config            // Full config for shared settings like connection tracking timeouts
+	LookupCache                 *config.LookupCacheConfig          // Authentication cache configuration
+	SessionMemoryLimit          int64                              // Memory limit per session in bytes
+	AuthIdleTimeout             time.Duration                      // Idle timeout during authentication phase (pre-auth only, 0 = disabled)
+	CommandTimeout              time.Duration                      // Maximum idle time before disconnection
+	CommandTimeoutOverrides     map[string]time.Duration           // Per-command hard execution timeouts (overrides defaults)
+	AbsoluteSessionTimeout      time.Duration                      // Maximum total session duration (0 = use default 30m)
+	MinBytesPerMinute           int64                              // Minimum throughput to prevent slowloris (0 = use default 512 bytes/min)
+	InsecureAuth                bool                               // Allow PLAIN auth over non-TLS connections (default: true for backends behind proxy)
+	Config                      *config.Config                     // Full config for shared settings like connection tracking timeouts
+	LoginHistory                *serverPkg.LoginHistory            // Per-account login history (optional)
+	SuspiciousLogins            *serverPkg.SuspiciousLoginDetector // Suspicious login detection (optional)
 }
 
-func New(appCtx context.Context, name, hostname, popAddr string, s3 *storage.S3Storage, rdb *resilient.ResilientDatabase, uploadWorker *uploader.UploadWorker, cache *cache.Cache, options POP3ServerOptions) (*POP3Server, error) {
+func New(appCtx context.Context, name, hostname, popAddr string, s3 storage.Backend, rdb *resilient.ResilientDatabase, uploadWorker *uploader.UploadWorker, cache *cache.Cache, options POP3ServerOptions) (*POP3Server, error) {
 	// Wrap S3 storage with resilient patterns including circuit breakers
 	resilientS3 := resilient.NewResilientS3Storage(s3)
 
@@ -292,6 +300,8 @@ func New(appCtx context.Context, name, hostname, popAddr string, s3 *storage.S3S
 		minBytesPerMinute:      options.MinBytesPerMinute,
 		maxMessageAge:          maxMessageAge,
 		activeSessions:         make(map[*POP3Session]struct{}),
+		loginHistory:           options.LoginHistory,
+		suspiciousLogins:       options.SuspiciousLogins,
 	}
 
 	// Apply operator overrides for per-command execution timeouts
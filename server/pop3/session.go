@@ -261,6 +261,7 @@ func (s *POP3Session) authenticateUser(ctx context.Context, identity, username,
 
 	authSuccess := false
 	masterAuthUsed := false
+	masterUsernameUsed := false // Admin impersonation, exempt from suspicious login checks
 	var accountID int64
 	var userAddress *server.Address
 
@@ -336,12 +337,14 @@ func (s *POP3Session) authenticateUser(ctx context.Context, identity, username,
 				}
 				authSuccess = true
 				masterAuthUsed = true
+				masterUsernameUsed = true
 				userAddress = &targetAddr
 			} else {
 				metrics.AuthenticationAttempts.WithLabelValues("pop3", s.server.name, s.server.hostname, "failure").Inc()
 				if s.server.authLimiter != nil {
 					s.server.authLimiter.RecordAuthAttemptWithProxy(ctx, netConn, proxyInfo, userAddress.BaseAddress(), false)
 				}
+				s.recordLogin(userAddress.BaseAddress(), 0, "invalid master credentials")
 				return 0, pop3AuthError("Invalid master credentials")
 			}
 		}
@@ -365,15 +368,23 @@ func (s *POP3Session) authenticateUser(ctx context.Context, identity, username,
 				s.server.authLimiter.RecordAuthAttemptWithProxy(ctx, netConn, proxyInfo, userAddress.FullAddress(), false)
 			}
 			metrics.AuthenticationAttempts.WithLabelValues("pop3", s.server.name, s.server.hostname, "failure").Inc()
+			s.recordLogin(userAddress.BaseAddress(), 0, "invalid credentials")
 			return 0, errAuthFailed
 		}
 		authSuccess = true
 	}
 
+	if !masterUsernameUsed {
+		if err := s.checkSuspiciousLogin(ctx, userAddress.BaseAddress(), accountID); err != nil {
+			return 0, err
+		}
+	}
+
 	// Record successful attempt
 	if s.server.authLimiter != nil {
 		s.server.authLimiter.RecordAuthAttemptWithProxy(ctx, netConn, proxyInfo, userAddress.FullAddress(), true)
 	}
+	s.recordLogin(userAddress.BaseAddress(), accountID, "")
 
 	// Ensure default mailboxes exist
 	if err := s.server.rdb.CreateDefaultMailboxesWithRetry(ctx, accountID); err != nil {
@@ -1201,3 +1212,37 @@ func crlfNormalizedLen(body []byte) int {
 	}
 	return n
 }
+
+// recordLogin adds a login attempt to the account's login history. An empty
+// failure reason records a successful login; failed logins pass accountID 0
+// and are matched to the account by address when stored.
+func (s *POP3Session) recordLogin(address string, accountID int64, failure string) {
+	s.server.loginHistory.Record(server.LoginRecord{
+		AccountID: accountID,
+		Username:  address,
+		Protocol:  "pop3",
+		IP:        s.RemoteIP,
+		Success:   failure == "",
+		Reason:    failure,
+	})
+}
+
+// checkSuspiciousLogin runs the suspicious login checks for a login whose
+// credentials were accepted. It returns the error to send when the login must
+// be rejected until the user confirms its source.
+func (s *POP3Session) checkSuspiciousLogin(ctx context.Context, address string, accountID int64) error {
+	err := s.server.suspiciousLogins.CheckLogin(ctx, server.LoginRecord{
+		AccountID: accountID,
+		Username:  address,
+		Protocol:  "pop3",
+		IP:        s.RemoteIP,
+	})
+	if !errors.Is(err, server.ErrLoginConfirmationRequired) {
+		return nil
+	}
+
+	s.InfoLog("login rejected until the new login source is confirmed", "address", address, "account_id", accountID)
+	metrics.AuthenticationAttempts.WithLabelValues("pop3", s.server.name, s.server.hostname, "failure").Inc()
+	s.recordLogin(address, accountID, "confirmation required")
+	return pop3AuthError("Login from a new location must be confirmed in your account settings")
+}
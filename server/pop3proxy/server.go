@@ -622,6 +622,11 @@ func (s *POP3ProxyServer) Start() error {
 		s.limiter.StartCleanup(s.appCtx)
 	}
 
+	// Kick sessions off draining backends at their configured rate
+	if s.connManager != nil {
+		go s.connManager.RunDrainKicker(s.appCtx)
+	}
+
 	// Use a goroutine to monitor application context cancellation
 	go func() {
 		<-s.appCtx.Done()
@@ -688,6 +693,10 @@ func (s *POP3ProxyServer) SetConnectionTracker(tracker *server.ConnectionTracker
 	if tracker != nil && s.lookupCache != nil {
 		tracker.SetLookupCache(s.lookupCache)
 	}
+	// Per-backend session counts drive least-connections balancing and drain kicks
+	if tracker != nil && s.connManager != nil {
+		s.connManager.SetBackendSessions(tracker)
+	}
 }
 
 // GetConnectionTracker returns the connection tracker for the server.
@@ -0,0 +1,187 @@
+package proxy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeBackendSessions is a BackendSessions with fixed per-backend counts
+type fakeBackendSessions struct {
+	counts map[string]int
+	kicked map[string]int
+}
+
+func (f *fakeBackendSessions) BackendSessionCount(backend string) int {
+	return f.counts[backend]
+}
+
+func (f *fakeBackendSessions) KickBackendSessions(backend string, limit int) int {
+	n := min(limit, f.counts[backend])
+	f.counts[backend] -= n
+	if f.kicked == nil {
+		f.kicked = make(map[string]int)
+	}
+	f.kicked[backend] += n
+	return n
+}
+
+// TestConsistentHash_Weighted verifies a heavier backend receives proportionally more users
+func TestConsistentHash_Weighted(t *testing.T) {
+	ch := NewConsistentHash(150)
+	ch.AddBackendWeighted("backend1:143", 1)
+	ch.AddBackendWeighted("backend2:143", 3)
+
+	counts := make(map[string]int)
+	for i := 0; i < 4000; i++ {
+		counts[ch.GetBackend(fmt.Sprintf("user%d@example.com", i))]++
+	}
+
+	ratio := float64(counts["backend2:143"]) / float64(counts["backend1:143"])
+	if ratio < 2 || ratio > 4.5 {
+		t.Errorf("Expected roughly 3x more users on backend2, got %v", counts)
+	}
+
+	ch.RemoveBackend("backend2:143")
+	if ch.Size() != 1 {
+		t.Errorf("Expected 1 backend after removal, got %d", ch.Size())
+	}
+	if b := ch.GetBackend("user1@example.com"); b != "backend1:143" {
+		t.Errorf("Expected backend1:143 after removal, got %s", b)
+	}
+}
+
+// TestConnectionManager_SetBalancingValidation verifies strategy and weight validation
+func TestConnectionManager_SetBalancingValidation(t *testing.T) {
+	cm, err := NewConnectionManager([]string{"backend1:143", "backend2"}, 143, false, false, false, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create connection manager: %v", err)
+	}
+
+	if err := cm.SetBalancing("random", nil); err == nil {
+		t.Error("Expected error for unknown strategy")
+	}
+	if err := cm.SetBalancing(BalancingConsistentHash, map[string]int{"backend3:143": 2}); err == nil {
+		t.Error("Expected error for weight on unknown backend")
+	}
+	if err := cm.SetBalancing(BalancingConsistentHash, map[string]int{"backend1:143": 0}); err == nil {
+		t.Error("Expected error for zero weight")
+	}
+	// A weight key without port is normalized with the default remote port
+	if err := cm.SetBalancing(BalancingLeastConnections, map[string]int{"backend2": 2}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+// TestConnectionManager_DrainExcludesBackend verifies a drained backend gets no new users
+func TestConnectionManager_DrainExcludesBackend(t *testing.T) {
+	backends := []string{"backend1:143", "backend2:143", "backend3:143"}
+	cm, err := NewConnectionManager(backends, 143, false, false, false, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create connection manager: %v", err)
+	}
+
+	username := "user1@example.com"
+	drained := cm.GetBackendByConsistentHash(username)
+
+	// Drain by bare host to check that it covers the host:port pool entry
+	host := drained[:len(drained)-len(":143")]
+	cm.DrainBackend(host, 0)
+	if !cm.IsBackendDraining(drained) {
+		t.Fatalf("Expected %s to be draining", drained)
+	}
+	if b := cm.GetBackendByConsistentHash(username); b == drained || b == "" {
+		t.Errorf("Expected a backend other than %s, got %q", drained, b)
+	}
+
+	statuses := cm.GetBackendHealthStatuses()
+	for _, s := range statuses {
+		if s.Draining != (s.Address == drained) {
+			t.Errorf("Unexpected draining=%v for %s", s.Draining, s.Address)
+		}
+	}
+
+	if !cm.UndrainBackend(host) {
+		t.Error("Expected undrain to report the backend was drained")
+	}
+	if cm.UndrainBackend(host) {
+		t.Error("Expected second undrain to report nothing drained")
+	}
+	if b := cm.GetBackendByConsistentHash(username); b != drained {
+		t.Errorf("Expected user to return to %s after undrain, got %s", drained, b)
+	}
+}
+
+// TestConnectionManager_LeastConnections verifies selection by weighted session count
+func TestConnectionManager_LeastConnections(t *testing.T) {
+	backends := []string{"backend1:143", "backend2:143", "backend3:143"}
+	cm, err := NewConnectionManager(backends, 143, false, false, false, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create connection manager: %v", err)
+	}
+
+	// Without a session source there is nothing to balance on
+	if b := cm.GetBackendByLeastConnections(); b != "" {
+		t.Errorf("Expected no backend without session source, got %s", b)
+	}
+
+	sessions := &fakeBackendSessions{counts: map[string]int{
+		"backend1:143": 10,
+		"backend2:143": 5,
+		"backend3:143": 8,
+	}}
+	cm.SetBackendSessions(sessions)
+	if err := cm.SetBalancing(BalancingLeastConnections, nil); err != nil {
+		t.Fatalf("SetBalancing failed: %v", err)
+	}
+
+	if b := cm.GetBackendByLeastConnections(); b != "backend2:143" {
+		t.Errorf("Expected backend2:143, got %s", b)
+	}
+
+	// backend1 at weight 4 carries 10/4 = 2.5 per unit, less than backend2's 5
+	if err := cm.SetBalancing(BalancingLeastConnections, map[string]int{"backend1:143": 4}); err != nil {
+		t.Fatalf("SetBalancing failed: %v", err)
+	}
+	if b := cm.GetBackendByLeastConnections(); b != "backend1:143" {
+		t.Errorf("Expected weighted backend1:143, got %s", b)
+	}
+
+	cm.DrainBackend("backend1:143", 0)
+	if b := cm.GetBackendByLeastConnections(); b != "backend2:143" {
+		t.Errorf("Expected backend2:143 with backend1 drained, got %s", b)
+	}
+}
+
+// TestConnectionManager_KickDrainingSessions verifies the kick rate is spread over ticks
+func TestConnectionManager_KickDrainingSessions(t *testing.T) {
+	cm, err := NewConnectionManager([]string{"backend1:143", "backend2:143"}, 143, false, false, false, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to create connection manager: %v", err)
+	}
+	sessions := &fakeBackendSessions{counts: map[string]int{
+		"backend1:143": 100,
+		"backend2:143": 100,
+	}}
+	cm.SetBackendSessions(sessions)
+	cm.DrainBackend("backend1:143", 3)
+
+	// 3 per minute at 10s ticks is half a session per tick
+	budget := make(map[string]float64)
+	for i := 0; i < 6; i++ {
+		cm.kickDrainingSessions(budget, 10*time.Second)
+	}
+
+	if got := sessions.kicked["backend1:143"]; got != 3 {
+		t.Errorf("Expected 3 sessions kicked from backend1 in one minute, got %d", got)
+	}
+	if got := sessions.kicked["backend2:143"]; got != 0 {
+		t.Errorf("Expected no sessions kicked from undrained backend2, got %d", got)
+	}
+
+	cm.UndrainBackend("backend1:143")
+	cm.kickDrainingSessions(budget, 10*time.Second)
+	if len(budget) != 0 {
+		t.Errorf("Expected kick budget to be cleared after undrain, got %v", budget)
+	}
+}
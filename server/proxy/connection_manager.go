@@ -24,6 +24,30 @@ type AffinityManager interface {
 	DeleteBackend(username, protocol string)
 }
 
+// BackendDrainState is implemented by affinity managers that share backend drain
+// state across the cluster (server.AffinityManager). When the configured affinity
+// manager implements it, its drains apply in addition to local ones.
+type BackendDrainState interface {
+	IsBackendDraining(addr string) bool
+	GetDrainedBackends() []server.BackendDrain
+}
+
+// BackendSessions counts and kicks this proxy's sessions per backend
+// (server.ConnectionTracker).
+type BackendSessions interface {
+	BackendSessionCount(backend string) int
+	KickBackendSessions(backend string, limit int) int
+}
+
+// Backend balancing strategies for users without remotelookup routing or affinity
+const (
+	BalancingConsistentHash   = "consistent_hash"
+	BalancingLeastConnections = "least_connections"
+)
+
+// drainKickInterval is how often sessions are kicked off draining backends
+const drainKickInterval = 10 * time.Second
+
 // BackendHealth tracks detailed health information for a backend
 type BackendHealth struct {
 	FailureCount     int       // Total failure count
@@ -67,6 +91,13 @@ type ConnectionManager struct {
 	// Affinity manager (optional, for cluster-wide affinity)
 	affinityManager AffinityManager
 
+	// Load balancing
+	balancing  string                         // BalancingConsistentHash (default) or BalancingLeastConnections
+	weights    map[string]int                 // configured pool address → weight (guarded by healthMu)
+	addrOrigin map[string]string              // resolved address → configured address (guarded by healthMu)
+	sessions   BackendSessions                // per-backend session counts (optional)
+	drains     map[string]server.BackendDrain // local drains, used without cluster-wide drain state (guarded by healthMu)
+
 	// restrictRemoteLookupToPool, when true, refuses a remote-lookup-returned backend
 	// that is not in the configured pool (SSRF defense). Set via
 	// SetRestrictRemoteLookupToPool; default false preserves dynamic routing. Written
@@ -172,6 +203,10 @@ func NewConnectionManagerWithRoutingAndStartTLSAndHealthCheck(remoteAddrs []stri
 		remoteLookupHealth:       make(map[string]*BackendHealth), // Dynamic remote lookup backends
 		routingLookup:            routingLookup,
 		enableBackendHealthCheck: !disableHealthCheck,
+		balancing:                BalancingConsistentHash,
+		weights:                  make(map[string]int),
+		addrOrigin:               make(map[string]string),
+		drains:                   make(map[string]server.BackendDrain),
 	}
 
 	logger.Debug("Connection manager: Initialized consistent hash ring", "prefix", cm.logPrefix(), "backends", len(normalizedAddrs))
@@ -197,6 +232,226 @@ func (cm *ConnectionManager) GetAffinityManager() AffinityManager {
 	return cm.affinityManager
 }
 
+// SetBalancing sets the balancing strategy and per-backend weights. Weights are keyed
+// by remote_addrs entry (the default port is applied as in remote_addrs); backends
+// without a weight get 1. The consistent hash ring is rebuilt with the new weights.
+func (cm *ConnectionManager) SetBalancing(strategy string, weights map[string]int) error {
+	switch strategy {
+	case "":
+		strategy = BalancingConsistentHash
+	case BalancingConsistentHash, BalancingLeastConnections:
+	default:
+		return fmt.Errorf("unknown balancing strategy %q", strategy)
+	}
+
+	normalized := make(map[string]int, len(weights))
+	for addr, weight := range weights {
+		if weight < 1 {
+			return fmt.Errorf("weight for backend %s must be at least 1", addr)
+		}
+		key := normalizeHostPort(addr, cm.remotePort)
+		found := false
+		for _, configured := range cm.configuredAddrs {
+			if configured == key {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("weight given for backend %s which is not in remote_addrs", addr)
+		}
+		normalized[key] = weight
+	}
+
+	cm.healthMu.Lock()
+	defer cm.healthMu.Unlock()
+	cm.balancing = strategy
+	cm.weights = normalized
+	cm.rebuildHashRingLocked()
+	return nil
+}
+
+// SetBackendSessions sets the per-backend session source used for least-connections
+// balancing and for kicking sessions off draining backends.
+func (cm *ConnectionManager) SetBackendSessions(sessions BackendSessions) {
+	cm.healthMu.Lock()
+	defer cm.healthMu.Unlock()
+	cm.sessions = sessions
+}
+
+// backendSessions returns the per-backend session source, or nil.
+func (cm *ConnectionManager) backendSessions() BackendSessions {
+	cm.healthMu.RLock()
+	defer cm.healthMu.RUnlock()
+	return cm.sessions
+}
+
+// backendWeightLocked returns the weight of a pool address, following resolved
+// addresses back to the configured entry. Caller must hold healthMu.
+func (cm *ConnectionManager) backendWeightLocked(addr string) int {
+	if origin, ok := cm.addrOrigin[addr]; ok {
+		addr = origin
+	}
+	if weight, ok := cm.weights[addr]; ok {
+		return weight
+	}
+	return 1
+}
+
+// rebuildHashRingLocked rebuilds the consistent hash ring from remoteAddrs and
+// weights. Caller must hold healthMu for writing.
+func (cm *ConnectionManager) rebuildHashRingLocked() {
+	cm.consistentHash = NewConsistentHash(150)
+	for _, addr := range cm.remoteAddrs {
+		cm.consistentHash.AddBackendWeighted(addr, cm.backendWeightLocked(addr))
+	}
+}
+
+// DrainBackend takes a backend out of rotation on this proxy only. Cluster-wide
+// drains go through the affinity manager instead (see BackendDrainState).
+func (cm *ConnectionManager) DrainBackend(backend string, kickPerMinute int) {
+	cm.healthMu.Lock()
+	cm.drains[backend] = server.BackendDrain{
+		Backend:       backend,
+		Since:         time.Now(),
+		KickPerMinute: kickPerMinute,
+	}
+	cm.healthMu.Unlock()
+	logger.Info("ConnectionManager: Backend drained", "server", cm.serverName, "backend", backend, "kick_per_minute", kickPerMinute)
+}
+
+// UndrainBackend returns a locally drained backend to rotation.
+// Returns false if the backend was not drained locally.
+func (cm *ConnectionManager) UndrainBackend(backend string) bool {
+	cm.healthMu.Lock()
+	_, drained := cm.drains[backend]
+	delete(cm.drains, backend)
+	cm.healthMu.Unlock()
+	if drained {
+		logger.Info("ConnectionManager: Backend undrained", "server", cm.serverName, "backend", backend)
+	}
+	return drained
+}
+
+// backendNamesLocked returns the names a drain may use for a pool address: the
+// address itself and, for resolved addresses, the configured remote_addrs entry.
+// Caller must hold healthMu.
+func (cm *ConnectionManager) backendNamesLocked(addr string) []string {
+	if origin, ok := cm.addrOrigin[addr]; ok && origin != addr {
+		return []string{addr, origin}
+	}
+	return []string{addr}
+}
+
+// drainMatches reports whether a drain applies to any of the names.
+func drainMatches(d server.BackendDrain, names []string) bool {
+	for _, name := range names {
+		if d.Matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBackendDraining reports whether the backend is drained, locally or cluster-wide.
+func (cm *ConnectionManager) IsBackendDraining(backend string) bool {
+	cm.healthMu.RLock()
+	names := cm.backendNamesLocked(backend)
+	for _, d := range cm.drains {
+		if drainMatches(d, names) {
+			cm.healthMu.RUnlock()
+			return true
+		}
+	}
+	cm.healthMu.RUnlock()
+
+	if state, ok := cm.affinityManager.(BackendDrainState); ok {
+		for _, name := range names {
+			if state.IsBackendDraining(name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetDrainedBackends returns the local and cluster-wide drains.
+func (cm *ConnectionManager) GetDrainedBackends() []server.BackendDrain {
+	cm.healthMu.RLock()
+	drains := make([]server.BackendDrain, 0, len(cm.drains))
+	for _, d := range cm.drains {
+		drains = append(drains, d)
+	}
+	cm.healthMu.RUnlock()
+
+	if state, ok := cm.affinityManager.(BackendDrainState); ok {
+		drains = append(drains, state.GetDrainedBackends()...)
+	}
+	return drains
+}
+
+// RunDrainKicker kicks sessions off draining backends at each drain's kick rate
+// until ctx is done. Sessions are kicked on this proxy only; every proxy runs its
+// own kicker.
+func (cm *ConnectionManager) RunDrainKicker(ctx context.Context) {
+	ticker := time.NewTicker(drainKickInterval)
+	defer ticker.Stop()
+
+	// Fractional kicks carried between ticks, so low rates still make progress
+	budget := make(map[string]float64)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cm.kickDrainingSessions(budget, drainKickInterval)
+		}
+	}
+}
+
+// kickDrainingSessions performs one kick round for RunDrainKicker.
+func (cm *ConnectionManager) kickDrainingSessions(budget map[string]float64, interval time.Duration) {
+	sessions := cm.backendSessions()
+	if sessions == nil {
+		return
+	}
+	drains := cm.GetDrainedBackends()
+
+	cm.healthMu.RLock()
+	addrs := cm.remoteAddrs
+	names := make(map[string][]string, len(addrs))
+	for _, addr := range addrs {
+		names[addr] = cm.backendNamesLocked(addr)
+	}
+	cm.healthMu.RUnlock()
+
+	active := make(map[string]bool)
+	for _, addr := range addrs {
+		rate := 0
+		for _, d := range drains {
+			if drainMatches(d, names[addr]) && d.KickPerMinute > rate {
+				rate = d.KickPerMinute
+			}
+		}
+		if rate == 0 || sessions.BackendSessionCount(addr) == 0 {
+			continue
+		}
+		active[addr] = true
+
+		budget[addr] += float64(rate) * interval.Seconds() / 60
+		if n := int(budget[addr]); n > 0 {
+			budget[addr] -= float64(n)
+			kicked := sessions.KickBackendSessions(addr, n)
+			logger.Info("ConnectionManager: Kicked sessions from draining backend", "server", cm.serverName, "backend", addr, "kicked", kicked)
+		}
+	}
+	for addr := range budget {
+		if !active[addr] {
+			delete(budget, addr)
+		}
+	}
+}
+
 // FindPoolBackendByHost returns the pool backend address matching the given hostname.
 // This enables cross-protocol affinity: when IMAP affinity points to "backend1:143",
 // the POP3 proxy can resolve it to "backend1:110" from its own pool.
@@ -247,15 +502,16 @@ func (cm *ConnectionManager) GetBackendByConsistentHash(username string) string
 			return ""
 		}
 
-		// Check if this backend is healthy
+		// Check if this backend is healthy and not draining
 		isHealthy := cm.IsBackendHealthy(backend)
-		logger.Debug("ConnectionManager: Consistent hash health check", "username", username, "backend", backend, "healthy", isHealthy, "iteration", i)
+		isDraining := cm.IsBackendDraining(backend)
+		logger.Debug("ConnectionManager: Consistent hash health check", "username", username, "backend", backend, "healthy", isHealthy, "draining", isDraining, "iteration", i)
 
-		if isHealthy {
+		if isHealthy && !isDraining {
 			return backend
 		}
 
-		// Backend unhealthy, exclude it and try next
+		// Backend unhealthy or draining, exclude it and try next
 		exclude[backend] = true
 	}
 
@@ -270,6 +526,43 @@ func (cm *ConnectionManager) GetBackendByConsistentHash(username string) string
 	return ""
 }
 
+// GetBackendByLeastConnections returns the healthy, non-draining pool backend with the
+// fewest active sessions relative to its weight. Ties rotate round-robin. Returns an
+// empty string if no session counts are available or no backend qualifies.
+func (cm *ConnectionManager) GetBackendByLeastConnections() string {
+	sessions := cm.backendSessions()
+	if sessions == nil {
+		return ""
+	}
+
+	cm.healthMu.RLock()
+	addrs := cm.remoteAddrs
+	weights := make([]int, len(addrs))
+	for i, addr := range addrs {
+		weights[i] = cm.backendWeightLocked(addr)
+	}
+	cm.healthMu.RUnlock()
+	if len(addrs) == 0 {
+		return ""
+	}
+
+	best, bestCount, bestWeight := "", 0, 1
+	start := cm.nextIndex.Add(1) - 1
+	for i := range addrs {
+		idx := (start + uint32(i)) % uint32(len(addrs))
+		addr := addrs[idx]
+		if !cm.IsBackendHealthy(addr) || cm.IsBackendDraining(addr) {
+			continue
+		}
+		// count/weight < bestCount/bestWeight, compared without division
+		count := sessions.BackendSessionCount(addr)
+		if best == "" || count*bestWeight < bestCount*weights[idx] {
+			best, bestCount, bestWeight = addr, count, weights[idx]
+		}
+	}
+	return best
+}
+
 // IsBackendHealthy checks if a backend is healthy based on detailed health tracking
 // Returns true if healthy, false if marked unhealthy
 // Auto-recovers backends after 1 minute of being marked unhealthy
@@ -368,6 +661,9 @@ type BackendHealthInfo struct {
 	LastSuccess        time.Time `json:"last_success,omitempty"`
 	HealthCheckEnabled bool      `json:"health_check_enabled"`
 	IsRemoteLookup     bool      `json:"is_remote_lookup"` // True if backend from remote_lookup (not in pool)
+	Weight             int       `json:"weight"`
+	Draining           bool      `json:"draining"`
+	ActiveSessions     int       `json:"active_sessions"` // Sessions on this proxy (0 without a connection tracker)
 }
 
 // HasHealthyPoolBackends checks if at least one pool backend (from remote_addrs config) is healthy.
@@ -398,6 +694,18 @@ func (cm *ConnectionManager) HasHealthyPoolBackends() bool {
 
 // GetBackendHealthStatuses returns health information for all backends (pool + remote lookup)
 func (cm *ConnectionManager) GetBackendHealthStatuses() []BackendHealthInfo {
+	drains := cm.GetDrainedBackends()
+	// Called with healthMu held
+	isDraining := func(addr string) bool {
+		names := cm.backendNamesLocked(addr)
+		for _, d := range drains {
+			if drainMatches(d, names) {
+				return true
+			}
+		}
+		return false
+	}
+
 	cm.healthMu.RLock()
 	defer cm.healthMu.RUnlock()
 
@@ -415,6 +723,11 @@ func (cm *ConnectionManager) GetBackendHealthStatuses() []BackendHealthInfo {
 			LastSuccess:        health.LastSuccess,
 			HealthCheckEnabled: healthCheckEnabled,
 			IsRemoteLookup:     false, // Pool backend
+			Weight:             cm.backendWeightLocked(addr),
+			Draining:           isDraining(addr),
+		}
+		if cm.sessions != nil {
+			info.ActiveSessions = cm.sessions.BackendSessionCount(addr)
 		}
 
 		// If health checks are disabled, all backends are considered healthy
@@ -436,6 +749,10 @@ func (cm *ConnectionManager) GetBackendHealthStatuses() []BackendHealthInfo {
 			LastSuccess:        health.LastSuccess,
 			HealthCheckEnabled: healthCheckEnabled,
 			IsRemoteLookup:     true, // Remote lookup backend
+			Draining:           isDraining(addr),
+		}
+		if cm.sessions != nil {
+			info.ActiveSessions = cm.sessions.BackendSessionCount(addr)
 		}
 
 		// If health checks are disabled, all backends are considered healthy
@@ -725,8 +1042,8 @@ func (cm *ConnectionManager) ConnectWithProxy(ctx context.Context, preferredAddr
 		idx := (startIndex + uint32(i)) % uint32(len(currentRemoteAddrs))
 		addr := currentRemoteAddrs[idx]
 
-		// Skip unhealthy backends
-		if !cm.IsBackendHealthy(addr) {
+		// Skip unhealthy and draining backends
+		if !cm.IsBackendHealthy(addr) || cm.IsBackendDraining(addr) {
 			continue
 		}
 
@@ -794,6 +1111,13 @@ func (cm *ConnectionManager) tryPreferredAddress(ctx context.Context, preferredA
 		return nil, "", nil, true // Fallback
 	}
 
+	// A draining backend takes no new sessions from affinity or balancing; remotelookup
+	// routes are definitive and still go through.
+	if isInList && !isRemoteLookupRoute && cm.IsBackendDraining(preferredAddr) {
+		logger.Debug("ConnectionManager: Preferred server is draining. Falling back to round-robin.", "server", preferredAddr)
+		return nil, "", nil, true // Fallback
+	}
+
 	// Attempt to dial the preferred address.
 	conn, err = cm.dialWithProxy(ctx, preferredAddr, clientIP, clientPort, serverIP, serverPort, routingInfo)
 	if err == nil {
@@ -847,8 +1171,20 @@ func (cm *ConnectionManager) ResolveAddresses() error {
 
 	var resolvedAddrs []string
 	newBackendHealth := make(map[string]*BackendHealth)
+	newAddrOrigin := make(map[string]string)
+
+	cm.healthMu.RLock()
+	currentAddrs := cm.remoteAddrs
+	currentOrigin := cm.addrOrigin
+	cm.healthMu.RUnlock()
+
+	for _, addr := range currentAddrs {
+		// Resolved addresses inherit the configured entry (for weights)
+		origin := addr
+		if o, ok := currentOrigin[addr]; ok {
+			origin = o
+		}
 
-	for _, addr := range cm.remoteAddrs {
 		host, port, err := net.SplitHostPort(addr)
 		if err != nil {
 			// If no port specified, assume it's just a host
@@ -861,6 +1197,7 @@ func (cm *ConnectionManager) ResolveAddresses() error {
 		if err != nil {
 			// If resolution fails, keep the original address
 			resolvedAddrs = append(resolvedAddrs, addr)
+			newAddrOrigin[addr] = origin
 			cm.healthMu.RLock()
 			if health, ok := cm.backendHealth[addr]; ok {
 				newBackendHealth[addr] = health
@@ -878,6 +1215,7 @@ func (cm *ConnectionManager) ResolveAddresses() error {
 				resolvedAddr = ip.String()
 			}
 			resolvedAddrs = append(resolvedAddrs, resolvedAddr)
+			newAddrOrigin[resolvedAddr] = origin
 
 			// Preserve health status if we had it
 			cm.healthMu.RLock()
@@ -897,15 +1235,13 @@ func (cm *ConnectionManager) ResolveAddresses() error {
 	oldBackendCount := len(cm.backendHealth)
 	cm.remoteAddrs = resolvedAddrs
 	cm.backendHealth = newBackendHealth
+	cm.addrOrigin = newAddrOrigin
 
 	// Rebuild consistent hash ring with resolved addresses
 	// This is critical: if we don't update the ring, it will contain old hostnames
 	// while backendHealth contains IP addresses, causing IsBackendHealthy() to fail
 	if cm.consistentHash != nil {
-		cm.consistentHash = NewConsistentHash(150)
-		for _, addr := range resolvedAddrs {
-			cm.consistentHash.AddBackend(addr)
-		}
+		cm.rebuildHashRingLocked()
 		logger.Info("ConnectionManager: Rebuilt consistent hash ring after address resolution", "server", cm.serverName, "backends", len(resolvedAddrs), "previous_health_entries", oldBackendCount)
 	}
 
@@ -1287,7 +1623,8 @@ type RouteResult struct {
 }
 
 // DetermineRoute centralizes the logic for choosing a backend server.
-// The precedence is: RemoteLookup > Affinity > Consistent Hash > Round-robin.
+// The precedence is: RemoteLookup > Affinity > Least connections (if configured) >
+// Consistent Hash > Round-robin.
 func DetermineRoute(params RouteParams) (RouteResult, error) {
 	result := RouteResult{
 		RoutingMethod: "consistent_hash", // Default: consistent hashing
@@ -1341,7 +1678,17 @@ func DetermineRoute(params RouteParams) (RouteResult, error) {
 		}
 	}
 
-	// 3. If no affinity, try consistent hashing (deterministic, no race condition)
+	// 3. If no affinity and least-connections balancing is configured, pick the least
+	// loaded backend (falls through to consistent hashing without session counts)
+	if result.PreferredAddr == "" && params.ConnManager.balancing == BalancingLeastConnections {
+		if backend := params.ConnManager.GetBackendByLeastConnections(); backend != "" {
+			result.PreferredAddr = backend
+			result.RoutingMethod = BalancingLeastConnections
+			logger.Info("Using least connections", "proxy", params.ProxyName, "user", params.Username, "backend", result.PreferredAddr)
+		}
+	}
+
+	// 4. If no affinity, try consistent hashing (deterministic, no race condition)
 	if result.PreferredAddr == "" && params.Username != "" {
 		if backend := params.ConnManager.GetBackendByConsistentHash(params.Username); backend != "" {
 			result.PreferredAddr = backend
@@ -1400,6 +1747,17 @@ func resolveAffinityRoute(params RouteParams, affinityMgr AffinityManager, resul
 			break
 		}
 
+		// A draining backend is treated like an unhealthy one: the affinity is
+		// dropped and the user is re-placed, which migrates them off the backend.
+		if params.ConnManager.IsBackendDraining(targetAddr) {
+			logger.Info("Cluster affinity backend draining - deleting affinity", "proxy", params.ProxyName, "backend", lastAddr, "user", params.Username, "protocol", foundProtocol)
+			affinityMgr.DeleteBackend(params.Username, foundProtocol)
+			if foundProtocol == params.Protocol {
+				continue
+			}
+			break
+		}
+
 		// Check if resolved backend is healthy (without auto-recovery for sticky failover)
 		if params.ConnManager.IsBackendHealthyForAffinity(targetAddr) {
 			if foundProtocol == params.Protocol {
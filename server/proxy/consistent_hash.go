@@ -12,7 +12,8 @@ import (
 type ConsistentHash struct {
 	ring         map[uint64]string // hash → backend address
 	sortedHashes []uint64          // sorted hash values
-	virtualNodes int               // number of virtual nodes per backend
+	virtualNodes int               // number of virtual nodes per backend (at weight 1)
+	weights      map[string]int    // backend → weight (virtual node multiplier)
 	mu           sync.RWMutex
 }
 
@@ -27,15 +28,28 @@ func NewConsistentHash(virtualNodes int) *ConsistentHash {
 		ring:         make(map[uint64]string),
 		sortedHashes: make([]uint64, 0),
 		virtualNodes: virtualNodes,
+		weights:      make(map[string]int),
 	}
 }
 
 // AddBackend adds a backend to the hash ring with virtual nodes
 func (ch *ConsistentHash) AddBackend(backend string) {
+	ch.AddBackendWeighted(backend, 1)
+}
+
+// AddBackendWeighted adds a backend with weight × virtualNodes virtual nodes, so it
+// receives a proportionally larger share of keys. Virtual node positions depend only
+// on the host and index, so raising a weight adds positions without moving existing ones.
+func (ch *ConsistentHash) AddBackendWeighted(backend string, weight int) {
+	if weight < 1 {
+		weight = 1
+	}
+
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
-	for i := 0; i < ch.virtualNodes; i++ {
+	ch.weights[backend] = weight
+	for i := 0; i < ch.virtualNodes*weight; i++ {
 		hash := ch.hash(backend, i)
 		ch.ring[hash] = backend
 		ch.sortedHashes = append(ch.sortedHashes, hash)
@@ -51,10 +65,15 @@ func (ch *ConsistentHash) RemoveBackend(backend string) {
 	ch.mu.Lock()
 	defer ch.mu.Unlock()
 
-	for i := 0; i < ch.virtualNodes; i++ {
+	weight := ch.weights[backend]
+	if weight < 1 {
+		weight = 1
+	}
+	for i := 0; i < ch.virtualNodes*weight; i++ {
 		hash := ch.hash(backend, i)
 		delete(ch.ring, hash)
 	}
+	delete(ch.weights, backend)
 
 	// Rebuild sorted hashes
 	ch.sortedHashes = make([]uint64, 0, len(ch.ring))
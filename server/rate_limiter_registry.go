@@ -62,3 +62,16 @@ func GetBlockedEntriesByProtocol(protocol string) []BlockedEntry {
 	}
 	return allEntries
 }
+
+// UnblockAuth lifts automatic blocks for ip and/or username in every registered
+// rate limiter (see AuthRateLimiter.Unblock) and returns the number of blocks lifted.
+func UnblockAuth(ip, username string) int {
+	globalRateLimiterRegistry.mu.RLock()
+	defer globalRateLimiterRegistry.mu.RUnlock()
+
+	lifted := 0
+	for _, limiter := range globalRateLimiterRegistry.limiters {
+		lifted += limiter.Unblock(ip, username)
+	}
+	return lifted
+}
@@ -0,0 +1,243 @@
+package relayqueue
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Queue states, matching the queue's subdirectories.
+const (
+	StatePending    = "pending"
+	StateProcessing = "processing"
+	StateFailed     = "failed"
+)
+
+var (
+	// ErrMessageNotFound is returned when a message ID is not in the given queue.
+	ErrMessageNotFound = errors.New("message not found in queue")
+	// ErrInvalidQueue is returned for a queue name other than the states above.
+	ErrInvalidQueue = errors.New("invalid queue")
+	// ErrInvalidMessageID is returned for an ID that could name a file outside the queue.
+	ErrInvalidMessageID = errors.New("invalid message ID")
+)
+
+// stateDir returns the directory for a queue state.
+func (q *DiskQueue) stateDir(state string) (string, error) {
+	switch state {
+	case StatePending:
+		return q.pendingDir, nil
+	case StateProcessing:
+		return q.processingDir, nil
+	case StateFailed:
+		return q.failedDir, nil
+	default:
+		return "", fmt.Errorf("%w %q (must be pending, processing, or failed)", ErrInvalidQueue, state)
+	}
+}
+
+// validateMessageID rejects IDs that could name a file outside the queue.
+func validateMessageID(id string) error {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("%w %q", ErrInvalidMessageID, id)
+	}
+	return nil
+}
+
+// List returns up to limit messages in a queue, oldest first. A limit of 0
+// returns every message.
+func (q *DiskQueue) List(state string, limit int) ([]QueuedMessage, error) {
+	dir, err := q.stateDir(state)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mu.Lock()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		q.mu.Unlock()
+		return nil, fmt.Errorf("failed to read %s queue: %w", state, err)
+	}
+	messages := make([]QueuedMessage, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		var msg QueuedMessage
+		if err := q.readMetadata(filepath.Join(dir, entry.Name()), &msg); err != nil {
+			// The worker may have moved it since ReadDir; skip rather than fail.
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	q.mu.Unlock()
+
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].QueuedAt.Before(messages[j].QueuedAt)
+	})
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// Get returns a message's metadata and body. A missing body is returned as
+// nil rather than an error, so the metadata can still be inspected.
+func (q *DiskQueue) Get(state, id string) (*QueuedMessage, []byte, error) {
+	dir, err := q.stateDir(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateMessageID(id); err != nil {
+		return nil, nil, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var msg QueuedMessage
+	if err := q.readMetadata(filepath.Join(dir, id+".json"), &msg); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrMessageNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	body, err := os.ReadFile(filepath.Join(dir, id+".msg"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("failed to read message body: %w", err)
+	}
+	return &msg, body, nil
+}
+
+// Delete removes a message from a queue.
+func (q *DiskQueue) Delete(state, id string) error {
+	dir, err := q.stateDir(state)
+	if err != nil {
+		return err
+	}
+	if err := validateMessageID(id); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.deleteLocked(dir, id)
+}
+
+// DeleteAll removes every message from a queue and returns how many were removed.
+func (q *DiskQueue) DeleteAll(state string) (int, error) {
+	dir, err := q.stateDir(state)
+	if err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids, err := q.messageIDs(dir)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, id := range ids {
+		if err := q.deleteLocked(dir, id); err == nil {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (q *DiskQueue) deleteLocked(dir, id string) error {
+	metadataPath := filepath.Join(dir, id+".json")
+	if err := os.Remove(metadataPath); err != nil {
+		if os.IsNotExist(err) {
+			return ErrMessageNotFound
+		}
+		return fmt.Errorf("failed to delete metadata: %w", err)
+	}
+	if err := os.Remove(filepath.Join(dir, id+".msg")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete message body: %w", err)
+	}
+	return nil
+}
+
+// Requeue moves a failed message back to pending with its attempts and error
+// history reset, ready for immediate delivery.
+func (q *DiskQueue) Requeue(id string) error {
+	if err := validateMessageID(id); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.requeueLocked(id)
+}
+
+// RequeueAll moves every failed message back to pending and returns how many
+// were moved.
+func (q *DiskQueue) RequeueAll() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids, err := q.messageIDs(q.failedDir)
+	if err != nil {
+		return 0, err
+	}
+	requeued := 0
+	for _, id := range ids {
+		if err := q.requeueLocked(id); err == nil {
+			requeued++
+		}
+	}
+	return requeued, nil
+}
+
+func (q *DiskQueue) requeueLocked(id string) error {
+	failedMetadataPath := filepath.Join(q.failedDir, id+".json")
+	failedMessagePath := filepath.Join(q.failedDir, id+".msg")
+
+	var metadata QueuedMessage
+	if err := q.readMetadata(failedMetadataPath, &metadata); err != nil {
+		if os.IsNotExist(err) {
+			return ErrMessageNotFound
+		}
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	metadata.Attempts = 0
+	metadata.LastAttempt = time.Time{}
+	metadata.NextRetry = time.Now()
+	metadata.Errors = []string{}
+
+	// Move the body first, so the worker never sees pending metadata without a body.
+	pendingMessagePath := filepath.Join(q.pendingDir, id+".msg")
+	if err := os.Rename(failedMessagePath, pendingMessagePath); err != nil {
+		return fmt.Errorf("failed to move message body to pending: %w", err)
+	}
+	if err := q.writeFileAtomic(filepath.Join(q.pendingDir, id+".json"), metadata); err != nil {
+		os.Rename(pendingMessagePath, failedMessagePath)
+		return fmt.Errorf("failed to write pending metadata: %w", err)
+	}
+	os.Remove(failedMetadataPath)
+	return nil
+}
+
+// messageIDs returns the IDs of the messages in dir.
+func (q *DiskQueue) messageIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue directory: %w", err)
+	}
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && filepath.Ext(name) == ".json" {
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	return ids, nil
+}
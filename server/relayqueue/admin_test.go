@@ -0,0 +1,100 @@
+package relayqueue
+
+import (
+	"errors"
+	"testing"
+)
+
+// failOne enqueues a message and moves it straight to the failed queue.
+func failOne(t *testing.T, q *DiskQueue, to string) string {
+	t.Helper()
+	if err := q.Enqueue("sender@example.com", to, "redirect", []byte("body")); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	msg, _, err := q.AcquireNext()
+	if err != nil || msg == nil {
+		t.Fatalf("AcquireNext: %v", err)
+	}
+	if err := q.MarkPermanentFailure(msg.ID, "550 no such user"); err != nil {
+		t.Fatalf("MarkPermanentFailure: %v", err)
+	}
+	return msg.ID
+}
+
+func TestListGetDelete(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := failOne(t, q, "a@example.com")
+	failOne(t, q, "b@example.com")
+
+	msgs, err := q.List(StateFailed, 1)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("List returned %d messages, want 1", len(msgs))
+	}
+
+	msg, body, err := q.Get(StateFailed, id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if msg.To != "a@example.com" || string(body) != "body" || len(msg.Errors) != 1 {
+		t.Errorf("unexpected message %+v body %q", msg, body)
+	}
+
+	if _, _, err := q.Get(StatePending, id); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Get from wrong queue: err = %v, want ErrMessageNotFound", err)
+	}
+	if _, err := q.List("bogus", 0); err == nil {
+		t.Error("List accepted an invalid queue")
+	}
+	if _, _, err := q.Get(StateFailed, "../pending/"+id); err == nil {
+		t.Error("Get accepted a path in the message ID")
+	}
+
+	if err := q.Delete(StateFailed, id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := q.Delete(StateFailed, id); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("second Delete: err = %v, want ErrMessageNotFound", err)
+	}
+	n, err := q.DeleteAll(StateFailed)
+	if err != nil || n != 1 {
+		t.Errorf("DeleteAll = %d, %v; want 1, nil", n, err)
+	}
+}
+
+func TestRequeue(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := failOne(t, q, "a@example.com")
+	failOne(t, q, "b@example.com")
+
+	if err := q.Requeue(id); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	msg, body, err := q.Get(StatePending, id)
+	if err != nil {
+		t.Fatalf("Get after requeue: %v", err)
+	}
+	if msg.Attempts != 0 || len(msg.Errors) != 0 || string(body) != "body" {
+		t.Errorf("requeued message not reset: %+v", msg)
+	}
+	if err := q.Requeue(id); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("second Requeue: err = %v, want ErrMessageNotFound", err)
+	}
+
+	n, err := q.RequeueAll()
+	if err != nil || n != 1 {
+		t.Errorf("RequeueAll = %d, %v; want 1, nil", n, err)
+	}
+	pending, _, failed, _ := q.GetStats()
+	if pending != 2 || failed != 0 {
+		t.Errorf("stats pending=%d failed=%d, want 2 and 0", pending, failed)
+	}
+}
@@ -0,0 +1,130 @@
+package relayqueue
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDestination is the domain key of the limit that applies to every
+// recipient domain without a limit of its own. Each domain is still counted
+// separately.
+const DefaultDestination = "*"
+
+// DestinationLimit caps deliveries to one recipient domain.
+type DestinationLimit struct {
+	Concurrency int           // Deliveries in progress at once (0 = no cap)
+	Rate        int           // Deliveries started per Period (0 = no cap)
+	Period      time.Duration // Window Rate is counted over
+}
+
+// RecipientDomain returns the lowercased domain of an address, the key
+// destination limits and queue inspection group messages by.
+func RecipientDomain(address string) string {
+	address = strings.Trim(strings.TrimSpace(address), "<>")
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return strings.ToLower(address[i+1:])
+	}
+	return ""
+}
+
+// destinationLimiter tracks deliveries in progress and recently started per
+// recipient domain. Only domains with a limit are tracked.
+type destinationLimiter struct {
+	mu       sync.Mutex
+	limits   map[string]DestinationLimit
+	inFlight map[string]int
+	started  map[string][]time.Time
+}
+
+func newDestinationLimiter(limits map[string]DestinationLimit) *destinationLimiter {
+	normalized := make(map[string]DestinationLimit, len(limits))
+	for domain, limit := range limits {
+		normalized[strings.ToLower(domain)] = limit
+	}
+	return &destinationLimiter{
+		limits:   normalized,
+		inFlight: make(map[string]int),
+		started:  make(map[string][]time.Time),
+	}
+}
+
+func (l *destinationLimiter) limit(domain string) (DestinationLimit, bool) {
+	if limit, ok := l.limits[domain]; ok {
+		return limit, true
+	}
+	limit, ok := l.limits[DefaultDestination]
+	return limit, ok
+}
+
+// allow reports whether a delivery to domain may start now.
+func (l *destinationLimiter) allow(domain string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit, ok := l.limit(domain)
+	if !ok {
+		return true
+	}
+	if limit.Concurrency > 0 && l.inFlight[domain] >= limit.Concurrency {
+		return false
+	}
+	if limit.Rate > 0 {
+		l.started[domain] = trimBefore(l.started[domain], now.Add(-limit.Period))
+		if len(l.started[domain]) >= limit.Rate {
+			return false
+		}
+	}
+	return true
+}
+
+// start records a delivery to domain starting.
+func (l *destinationLimiter) start(domain string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limit, ok := l.limit(domain)
+	if !ok {
+		return
+	}
+	l.inFlight[domain]++
+	if limit.Rate > 0 {
+		l.started[domain] = append(l.started[domain], now)
+	}
+}
+
+// done records the end of a delivery started with start.
+func (l *destinationLimiter) done(domain string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[domain] <= 1 {
+		delete(l.inFlight, domain)
+		return
+	}
+	l.inFlight[domain]--
+}
+
+// prune forgets the deliveries that no longer count against a rate.
+func (l *destinationLimiter) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for domain, started := range l.started {
+		limit, _ := l.limit(domain)
+		if started = trimBefore(started, now.Add(-limit.Period)); len(started) == 0 {
+			delete(l.started, domain)
+		} else {
+			l.started[domain] = started
+		}
+	}
+}
+
+// trimBefore drops the times before cutoff from a sorted slice.
+func trimBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
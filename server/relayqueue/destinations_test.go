@@ -0,0 +1,134 @@
+package relayqueue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDestinationLimiter(t *testing.T) {
+	l := newDestinationLimiter(map[string]DestinationLimit{
+		"Slow.example":     {Concurrency: 1},
+		DefaultDestination: {Rate: 2, Period: time.Minute},
+	})
+	now := time.Now()
+
+	// Concurrency cap: one delivery at a time
+	if !l.allow("slow.example", now) {
+		t.Fatal("first delivery to slow.example not allowed")
+	}
+	l.start("slow.example", now)
+	if l.allow("slow.example", now) {
+		t.Error("second concurrent delivery to slow.example allowed")
+	}
+	l.done("slow.example")
+	if !l.allow("slow.example", now) {
+		t.Error("delivery to slow.example not allowed after the first finished")
+	}
+
+	// Rate cap from the default limit, counted per domain
+	for i := 0; i < 2; i++ {
+		l.start("a.example", now)
+		l.done("a.example")
+	}
+	if l.allow("a.example", now) {
+		t.Error("third delivery to a.example within the period allowed")
+	}
+	if !l.allow("b.example", now) {
+		t.Error("b.example held back by the deliveries to a.example")
+	}
+	if !l.allow("a.example", now.Add(time.Minute+time.Second)) {
+		t.Error("a.example still held back after the period")
+	}
+	l.prune(now.Add(2 * time.Minute))
+	if len(l.started) != 0 {
+		t.Errorf("prune kept %d domains", len(l.started))
+	}
+}
+
+// concurrencyTrackingHandler records the most deliveries in progress at once
+// per recipient domain.
+type concurrencyTrackingHandler struct {
+	mu      sync.Mutex
+	current map[string]int
+	peak    map[string]int
+	sent    int
+}
+
+func (h *concurrencyTrackingHandler) SendToExternalRelay(from, to string, message []byte) error {
+	domain := RecipientDomain(to)
+	h.mu.Lock()
+	h.current[domain]++
+	h.peak[domain] = max(h.peak[domain], h.current[domain])
+	h.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	h.mu.Lock()
+	h.current[domain]--
+	h.sent++
+	h.mu.Unlock()
+	return nil
+}
+
+func TestWorkerDestinationConcurrency(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		to := "user@capped.example"
+		if i%2 == 1 {
+			to = "user@free.example"
+		}
+		if err := q.Enqueue("sender@example.com", to, "redirect", []byte("body")); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	handler := &concurrencyTrackingHandler{current: map[string]int{}, peak: map[string]int{}}
+	w := NewWorker(q, handler, time.Hour, 100, 5, time.Hour, time.Hour, nil)
+	w.SetDestinationLimits(map[string]DestinationLimit{"capped.example": {Concurrency: 1}})
+
+	// One cycle sends everything: held-back messages go out as slots free up
+	if err := w.processQueue(context.Background()); err != nil {
+		t.Fatalf("processQueue: %v", err)
+	}
+	if handler.sent != 6 {
+		t.Errorf("sent %d messages in one cycle, want 6", handler.sent)
+	}
+	if handler.peak["capped.example"] != 1 {
+		t.Errorf("peak concurrency to capped.example = %d, want 1", handler.peak["capped.example"])
+	}
+	if handler.peak["free.example"] < 2 {
+		t.Errorf("peak concurrency to free.example = %d, want it uncapped", handler.peak["free.example"])
+	}
+}
+
+func TestWorkerDestinationRate(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := q.Enqueue("sender@example.com", "user@capped.example", "redirect", []byte("body")); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	handler := &mockRelayHandler{}
+	w := NewWorker(q, handler, time.Hour, 100, 5, time.Hour, time.Hour, nil)
+	w.SetDestinationLimits(map[string]DestinationLimit{DefaultDestination: {Rate: 2, Period: time.Hour}})
+
+	if err := w.processQueue(context.Background()); err != nil {
+		t.Fatalf("processQueue: %v", err)
+	}
+	if got := handler.getMessageCount(); got != 2 {
+		t.Errorf("sent %d messages, want 2", got)
+	}
+	pending, _, _, _ := q.GetStats()
+	if pending != 1 {
+		t.Errorf("pending = %d, want 1 held back by the rate cap", pending)
+	}
+}
@@ -0,0 +1,84 @@
+package relayqueue
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Priority classes. Ready messages are acquired highest class first.
+const (
+	PriorityLow    = 0
+	PriorityNormal = 1
+	PriorityHigh   = 2
+)
+
+// defaultPriorities are the classes of message types that have one other than
+// normal. Vacation replies wait for redirects, forwards and bounces.
+var defaultPriorities = map[string]int{
+	"vacation": PriorityLow,
+}
+
+// PriorityName returns the name of a priority class.
+func PriorityName(priority int) string {
+	switch priority {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority parses a priority class name.
+func ParsePriority(name string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "high":
+		return PriorityHigh, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "low":
+		return PriorityLow, nil
+	default:
+		return 0, fmt.Errorf("invalid priority %q (must be high, normal, or low)", name)
+	}
+}
+
+// ParsePriorities parses a message type to priority class name map, as
+// configured in [relay.queue] priorities.
+func ParsePriorities(names map[string]string) (map[string]int, error) {
+	priorities := make(map[string]int, len(names))
+	for messageType, name := range names {
+		p, err := ParsePriority(name)
+		if err != nil {
+			return nil, fmt.Errorf("message type %q: %w", messageType, err)
+		}
+		priorities[messageType] = p
+	}
+	return priorities, nil
+}
+
+// SetPriorities overrides the priority class of message types. Types not in
+// the map keep their default.
+func (q *DiskQueue) SetPriorities(priorities map[string]int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.priorities = priorities
+}
+
+// Priority returns the priority class of a message type.
+func (q *DiskQueue) Priority(messageType string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.priorityLocked(messageType)
+}
+
+func (q *DiskQueue) priorityLocked(messageType string) int {
+	if p, ok := q.priorities[messageType]; ok {
+		return p
+	}
+	if p, ok := defaultPriorities[messageType]; ok {
+		return p
+	}
+	return PriorityNormal
+}
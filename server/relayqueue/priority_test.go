@@ -0,0 +1,79 @@
+package relayqueue
+
+import (
+	"testing"
+)
+
+func TestAcquireNextPriority(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.SetPriorities(map[string]int{"notification": PriorityHigh})
+
+	for _, m := range []struct{ to, typ string }{
+		{"vacation1@example.com", "vacation"},
+		{"redirect1@example.com", "redirect"},
+		{"vacation2@example.com", "vacation"},
+		{"notification@example.com", "notification"},
+		{"redirect2@example.com", "redirect"},
+	} {
+		if err := q.Enqueue("sender@example.com", m.to, m.typ, []byte("body")); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	want := []string{"notification@example.com", "redirect1@example.com", "redirect2@example.com", "vacation1@example.com", "vacation2@example.com"}
+	for i, to := range want {
+		msg, _, err := q.AcquireNext()
+		if err != nil || msg == nil {
+			t.Fatalf("AcquireNext %d: msg = %v, err = %v", i, msg, err)
+		}
+		if msg.To != to {
+			t.Errorf("AcquireNext %d = %s, want %s", i, msg.To, to)
+		}
+	}
+}
+
+func TestAcquireNextFuncSkipsRejected(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, to := range []string{"a@held.example", "b@open.example"} {
+		if err := q.Enqueue("sender@example.com", to, "redirect", []byte("body")); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+
+	accept := func(msg *QueuedMessage) bool { return RecipientDomain(msg.To) != "held.example" }
+	msg, _, err := q.AcquireNextFunc(accept)
+	if err != nil || msg == nil || msg.To != "b@open.example" {
+		t.Fatalf("AcquireNextFunc = %v, %v; want b@open.example", msg, err)
+	}
+	if msg, _, _ := q.AcquireNextFunc(accept); msg != nil {
+		t.Errorf("AcquireNextFunc took held message %s", msg.To)
+	}
+
+	// The held message is untouched and still pending
+	pending, _, _, _ := q.GetStats()
+	if pending != 1 {
+		t.Errorf("pending = %d, want 1", pending)
+	}
+	if msg, _, _ := q.AcquireNext(); msg == nil || msg.Attempts != 0 {
+		t.Errorf("held message = %+v, want it pending without attempts", msg)
+	}
+}
+
+func TestParsePriorities(t *testing.T) {
+	got, err := ParsePriorities(map[string]string{"vacation": "Low", "bounce": "high"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["vacation"] != PriorityLow || got["bounce"] != PriorityHigh {
+		t.Errorf("ParsePriorities = %v", got)
+	}
+	if _, err := ParsePriorities(map[string]string{"vacation": "urgent"}); err == nil {
+		t.Error("ParsePriorities accepted an unknown class")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -18,7 +19,7 @@ type QueuedMessage struct {
 	ID          string    `json:"id"`           // Unique message ID
 	From        string    `json:"from"`         // Sender address
 	To          string    `json:"to"`           // Recipient address
-	Type        string    `json:"type"`         // "redirect" or "vacation"
+	Type        string    `json:"type"`         // "redirect", "forward", "vacation", "notification" or "bounce"
 	QueuedAt    time.Time `json:"queued_at"`    // When first queued
 	Attempts    int       `json:"attempts"`     // Number of delivery attempts
 	LastAttempt time.Time `json:"last_attempt"` // Last attempt timestamp
@@ -34,6 +35,7 @@ type DiskQueue struct {
 	failedDir     string
 	maxAttempts   int
 	retryBackoff  []time.Duration
+	priorities    map[string]int // Priority class overrides by message type
 	mu            sync.Mutex
 }
 
@@ -247,6 +249,15 @@ func (q *DiskQueue) Enqueue(from, to, messageType string, messageBytes []byte) e
 
 // AcquireNext finds the next message ready for processing and moves it to processing state
 func (q *DiskQueue) AcquireNext() (*QueuedMessage, []byte, error) {
+	return q.AcquireNextFunc(nil)
+}
+
+// AcquireNextFunc moves the next ready message that accept reports true for
+// to the processing state; a nil accept takes any ready message. Messages are
+// offered highest priority class first and oldest first within a class.
+// Messages accept turns down stay pending, so a caller can hold back the
+// messages of a destination without touching their retry schedule.
+func (q *DiskQueue) AcquireNextFunc(accept func(*QueuedMessage) bool) (*QueuedMessage, []byte, error) {
 	start := time.Now()
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -261,16 +272,20 @@ func (q *DiskQueue) AcquireNext() (*QueuedMessage, []byte, error) {
 
 	now := time.Now()
 
-	// Find first message ready for retry
+	// Collect the messages ready for retry
+	type candidate struct {
+		metadata QueuedMessage
+		priority int
+	}
+	var ready []candidate
 	for _, entry := range entries {
 		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
 			continue
 		}
 
 		// Read metadata
-		metadataPath := filepath.Join(q.pendingDir, entry.Name())
 		var metadata QueuedMessage
-		if err := q.readMetadata(metadataPath, &metadata); err != nil {
+		if err := q.readMetadata(filepath.Join(q.pendingDir, entry.Name()), &metadata); err != nil {
 			logger.Error("RelayQueue: Failed to read metadata", "entry", entry.Name(), "error", err)
 			continue
 		}
@@ -279,9 +294,24 @@ func (q *DiskQueue) AcquireNext() (*QueuedMessage, []byte, error) {
 		if now.Before(metadata.NextRetry) {
 			continue
 		}
+		ready = append(ready, candidate{metadata: metadata, priority: q.priorityLocked(metadata.Type)})
+	}
+	sort.SliceStable(ready, func(i, j int) bool {
+		if ready[i].priority != ready[j].priority {
+			return ready[i].priority > ready[j].priority
+		}
+		return ready[i].metadata.QueuedAt.Before(ready[j].metadata.QueuedAt)
+	})
+
+	for i := range ready {
+		metadata := &ready[i].metadata
+		if accept != nil && !accept(metadata) {
+			continue
+		}
 
 		// Read message body
 		messageID := metadata.ID
+		metadataPath := filepath.Join(q.pendingDir, messageID+".json")
 		messagePath := filepath.Join(q.pendingDir, messageID+".msg")
 		messageBytes, err := os.ReadFile(messagePath)
 		if err != nil {
@@ -309,7 +339,7 @@ func (q *DiskQueue) AcquireNext() (*QueuedMessage, []byte, error) {
 
 		metrics.RelayQueueOperations.WithLabelValues("acquire", "success").Inc()
 		metrics.RelayQueueOperationDuration.WithLabelValues("acquire").Observe(time.Since(start).Seconds())
-		return &metadata, messageBytes, nil
+		return metadata, messageBytes, nil
 	}
 
 	// No messages ready - this is normal, not an error
@@ -0,0 +1,89 @@
+package relayqueue
+
+import (
+	"strings"
+
+	"github.com/migadu/sora/pkg/circuitbreaker"
+)
+
+// DefaultRoute is the route name of messages that match no routing rule.
+const DefaultRoute = "default"
+
+// Route sends the messages of some sender or recipient domains through a
+// relay handler of its own. A route with both domain lists set needs both to
+// match; an empty list matches any domain.
+type Route struct {
+	Name             string
+	SenderDomains    []string
+	RecipientDomains []string
+	Handler          RelayHandler
+}
+
+// Matches reports whether a message from from to to takes this route.
+func (r *Route) Matches(from, to string) bool {
+	if len(r.SenderDomains) == 0 && len(r.RecipientDomains) == 0 {
+		return false
+	}
+	return matchesDomain(r.SenderDomains, RecipientDomain(from)) &&
+		matchesDomain(r.RecipientDomains, RecipientDomain(to))
+}
+
+func matchesDomain(domains []string, domain string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	for _, d := range domains {
+		if strings.EqualFold(d, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Router is a RelayHandler that picks the handler of the first matching
+// route and falls back to the default relay for the rest. Circuit breakers
+// stay per handler: the router reports the default relay's, which drives the
+// worker's recovery checks.
+type Router struct {
+	routes   []Route
+	fallback RelayHandler
+}
+
+// NewRouter creates a router over routes, tried in order. Handlers may be nil
+// when the router is only used to classify messages.
+func NewRouter(fallback RelayHandler, routes []Route) *Router {
+	return &Router{routes: routes, fallback: fallback}
+}
+
+// Route returns the name of the route a message takes, or DefaultRoute.
+func (r *Router) Route(from, to string) string {
+	if route := r.match(from, to); route != nil {
+		return route.Name
+	}
+	return DefaultRoute
+}
+
+func (r *Router) match(from, to string) *Route {
+	for i := range r.routes {
+		if r.routes[i].Matches(from, to) {
+			return &r.routes[i]
+		}
+	}
+	return nil
+}
+
+// SendToExternalRelay delivers a message through the handler of its route.
+func (r *Router) SendToExternalRelay(from, to string, message []byte) error {
+	if route := r.match(from, to); route != nil {
+		return route.Handler.SendToExternalRelay(from, to, message)
+	}
+	return r.fallback.SendToExternalRelay(from, to, message)
+}
+
+// GetCircuitBreaker returns the default relay's circuit breaker, if any.
+func (r *Router) GetCircuitBreaker() *circuitbreaker.CircuitBreaker {
+	if provider, ok := r.fallback.(CircuitBreakerProvider); ok {
+		return provider.GetCircuitBreaker()
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package relayqueue
+
+import (
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	fallback := &mockRelayHandler{}
+	google := &mockRelayHandler{}
+	srs := &mockRelayHandler{}
+	r := NewRouter(fallback, []Route{
+		{Name: "google", RecipientDomains: []string{"gmail.com", "googlemail.com"}, Handler: google},
+		{Name: "srs", SenderDomains: []string{"srs.example.com"}, Handler: srs},
+		{Name: "never", Handler: srs}, // No domains: matches nothing
+	})
+
+	tests := []struct {
+		from, to string
+		route    string
+		handler  *mockRelayHandler
+	}{
+		{"a@example.com", "b@GMAIL.com", "google", google},
+		{"SRS0=x@srs.example.com", "b@gmail.com", "google", google}, // First match wins
+		{"SRS0=x@srs.example.com", "b@example.org", "srs", srs},
+		{"a@example.com", "b@example.org", DefaultRoute, fallback},
+		{"", "b@example.org", DefaultRoute, fallback},
+	}
+	for _, tt := range tests {
+		if got := r.Route(tt.from, tt.to); got != tt.route {
+			t.Errorf("Route(%q, %q) = %q, want %q", tt.from, tt.to, got, tt.route)
+		}
+		before := tt.handler.getMessageCount()
+		if err := r.SendToExternalRelay(tt.from, tt.to, []byte("body")); err != nil {
+			t.Fatalf("SendToExternalRelay: %v", err)
+		}
+		if tt.handler.getMessageCount() != before+1 {
+			t.Errorf("message from %q to %q not sent through route %q", tt.from, tt.to, tt.route)
+		}
+	}
+
+	both := Route{Name: "both", SenderDomains: []string{"a.example"}, RecipientDomains: []string{"b.example"}}
+	if !both.Matches("x@a.example", "y@b.example") || both.Matches("x@a.example", "y@c.example") {
+		t.Error("a route with sender and recipient domains must match both")
+	}
+}
@@ -16,7 +16,7 @@ import (
 // RelayQueue defines the interface for queue operations required by the worker.
 // This allows for mocking in tests and decouples the worker from the concrete DiskQueue.
 type RelayQueue interface {
-	AcquireNext() (*QueuedMessage, []byte, error)
+	AcquireNextFunc(accept func(*QueuedMessage) bool) (*QueuedMessage, []byte, error)
 	MarkSuccess(messageID string) error
 	MarkFailure(messageID string, errorMsg string) error
 	MarkPermanentFailure(messageID string, errorMsg string) error
@@ -32,6 +32,19 @@ type RelayHandler interface {
 	SendToExternalRelay(from, to string, message []byte) error
 }
 
+// Bouncer notifies the originator of a message that ended up in the failed
+// queue (see delivery.Bouncer).
+type Bouncer interface {
+	Bounce(ctx context.Context, failed delivery.FailedRelay, message []byte) error
+}
+
+// failedMessageReader is implemented by queues that can return a message from
+// the failed queue, such as *DiskQueue. Bounces need it to tell whether a
+// temporary failure used up the last attempt.
+type failedMessageReader interface {
+	Get(state, id string) (*QueuedMessage, []byte, error)
+}
+
 // CircuitBreakerProvider is an optional interface that relay handlers can implement
 // to provide circuit breaker state information for proactive recovery checking.
 type CircuitBreakerProvider interface {
@@ -50,6 +63,8 @@ type CircuitBreakerProvider interface {
 type Worker struct {
 	queue           RelayQueue
 	relayHandler    RelayHandler
+	bouncer         Bouncer
+	destinations    *destinationLimiter
 	interval        time.Duration
 	batchSize       int
 	concurrency     int
@@ -102,6 +117,24 @@ func NewWorker(queue RelayQueue, relayHandler RelayHandler, interval time.Durati
 	}
 }
 
+// SetBouncer sets the handler notified of messages that fail permanently or
+// run out of attempts. It must be called before Start.
+func (w *Worker) SetBouncer(b Bouncer) {
+	w.bouncer = b
+}
+
+// SetDestinationLimits caps deliveries per recipient domain; the limit keyed
+// DefaultDestination applies to every other domain. Messages over a cap stay
+// pending until a later cycle without using up an attempt. It must be called
+// before Start.
+func (w *Worker) SetDestinationLimits(limits map[string]DestinationLimit) {
+	if len(limits) == 0 {
+		w.destinations = nil
+		return
+	}
+	w.destinations = newDestinationLimiter(limits)
+}
+
 // Start begins background processing of the relay queue.
 // It is safe to call Start multiple times - subsequent calls are no-ops if already running.
 //
@@ -251,6 +284,17 @@ func (w *Worker) processQueue(ctx context.Context) error {
 	sem := make(chan struct{}, w.concurrency)
 	var wg sync.WaitGroup
 
+	// Messages to domains at their cap are passed over and stay pending
+	finished := make(chan struct{}, w.batchSize)
+	running := 0
+	var accept func(*QueuedMessage) bool
+	if w.destinations != nil {
+		w.destinations.prune(time.Now())
+		accept = func(msg *QueuedMessage) bool {
+			return w.destinations.allow(RecipientDomain(msg.To), time.Now())
+		}
+	}
+
 	processed := 0
 	for processed < w.batchSize {
 		// Check for context cancellation early
@@ -263,13 +307,25 @@ func (w *Worker) processQueue(ctx context.Context) error {
 		}
 
 		// Acquire next message
-		msg, messageBytes, err := w.queue.AcquireNext()
+		msg, messageBytes, err := w.queue.AcquireNextFunc(accept)
 		if err != nil {
 			wg.Wait() // Wait for in-flight messages before returning error
 			return fmt.Errorf("failed to acquire message: %w", err)
 		}
 
 		if msg == nil {
+			// Messages held back by a concurrency cap may become sendable when a
+			// delivery of this batch finishes
+			if accept != nil && running > 0 {
+				select {
+				case <-finished:
+					running--
+					continue
+				case <-ctx.Done():
+					wg.Wait()
+					return nil
+				}
+			}
 			// No messages ready for processing, break and wait for next interval
 			break
 		}
@@ -281,12 +337,21 @@ func (w *Worker) processQueue(ctx context.Context) error {
 			wg.Wait()
 			return nil
 		case sem <- struct{}{}:
+			domain := RecipientDomain(msg.To)
+			if w.destinations != nil {
+				w.destinations.start(domain, time.Now())
+			}
 			wg.Add(1)
 			go func(msg *QueuedMessage, messageBytes []byte) {
 				defer wg.Done()
 				defer func() { <-sem }()
+				defer func() { finished <- struct{}{} }()
+				if w.destinations != nil {
+					defer w.destinations.done(domain)
+				}
 				w.processMessage(ctx, msg, messageBytes)
 			}(msg, messageBytes)
+			running++
 			processed++
 		}
 	}
@@ -367,6 +432,8 @@ func (w *Worker) processMessage(ctx context.Context, msg *QueuedMessage, message
 
 			if markErr := w.queue.MarkPermanentFailure(msg.ID, err.Error()); markErr != nil {
 				logger.Error("Relay: CRITICAL - Failed to mark permanent failure for message", "id", msg.ID, "error", markErr)
+			} else {
+				w.bounce(ctx, msg.ID, true)
 			}
 
 			metrics.RelayDelivery.WithLabelValues(msg.Type, "permanent_failure").Inc()
@@ -377,6 +444,8 @@ func (w *Worker) processMessage(ctx context.Context, msg *QueuedMessage, message
 
 			if markErr := w.queue.MarkFailure(msg.ID, err.Error()); markErr != nil {
 				logger.Error("Relay: CRITICAL - Failed to mark failure for message", "id", msg.ID, "error", markErr)
+			} else {
+				w.bounce(ctx, msg.ID, false)
 			}
 
 			metrics.RelayDelivery.WithLabelValues(msg.Type, "temporary_failure").Inc()
@@ -396,6 +465,41 @@ func (w *Worker) processMessage(ctx context.Context, msg *QueuedMessage, message
 	metrics.RelayDeliveryDuration.WithLabelValues(msg.Type, "success").Observe(duration.Seconds())
 }
 
+// bounce hands a message that was moved to the failed queue to the bouncer.
+// Messages still pending a retry are left alone.
+func (w *Worker) bounce(ctx context.Context, id string, permanent bool) {
+	if w.bouncer == nil {
+		return
+	}
+	reader, ok := w.queue.(failedMessageReader)
+	if !ok {
+		return
+	}
+	msg, messageBytes, err := reader.Get(StateFailed, id)
+	if errors.Is(err, ErrMessageNotFound) {
+		return // retry scheduled
+	}
+	if err != nil {
+		logger.Error("Relay: Failed to read failed message for bounce", "id", id, "error", err)
+		return
+	}
+
+	failed := delivery.FailedRelay{
+		ID:        msg.ID,
+		From:      msg.From,
+		To:        msg.To,
+		Type:      msg.Type,
+		QueuedAt:  msg.QueuedAt,
+		Attempts:  msg.Attempts,
+		Errors:    msg.Errors,
+		Permanent: permanent,
+	}
+	if err := w.bouncer.Bounce(ctx, failed, messageBytes); err != nil {
+		logger.Error("Relay: Failed to bounce failed message", "id", id, "type", msg.Type, "from", msg.From, "error", err)
+		metrics.RelayDelivery.WithLabelValues(msg.Type, "bounce_error").Inc()
+	}
+}
+
 // reportError sends an error to the error channel if configured, otherwise logs it
 func (w *Worker) reportError(err error) {
 	if w.errCh != nil {
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/migadu/sora/pkg/circuitbreaker"
+	"github.com/migadu/sora/server/delivery"
 )
 
 // mockRelayHandler implements delivery.RelayHandler for testing
@@ -871,3 +872,80 @@ success:
 		t.Errorf("Expected more than 8 handler calls due to CB errors, got %d", handler.getCallCount())
 	}
 }
+
+// captureBouncer records the failed messages handed to it.
+type captureBouncer struct {
+	mu     sync.Mutex
+	failed []delivery.FailedRelay
+}
+
+func (b *captureBouncer) Bounce(ctx context.Context, failed delivery.FailedRelay, message []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failed = append(b.failed, failed)
+	return nil
+}
+
+// failingRelayHandler fails every delivery with err.
+type failingRelayHandler struct{ err error }
+
+func (h failingRelayHandler) SendToExternalRelay(from, to string, message []byte) error {
+	return h.err
+}
+
+// TestWorkerBouncesFailedMessages tests that messages moved to the failed
+// queue, and only those, are handed to the bouncer
+func TestWorkerBouncesFailedMessages(t *testing.T) {
+	process := func(t *testing.T, queue *DiskQueue, worker *Worker) {
+		t.Helper()
+		msg, body, err := queue.AcquireNext()
+		if err != nil || msg == nil {
+			t.Fatalf("AcquireNext: %v, %v", msg, err)
+		}
+		worker.processMessage(context.Background(), msg, body)
+	}
+
+	t.Run("retry scheduled", func(t *testing.T) {
+		queue, _ := NewDiskQueue(t.TempDir(), 2, []time.Duration{time.Hour})
+		bouncer := &captureBouncer{}
+		worker := NewWorker(queue, failingRelayHandler{errors.New("connection refused")}, time.Minute, 10, 1, time.Hour, time.Hour, nil)
+		worker.SetBouncer(bouncer)
+		queue.Enqueue("alice@example.com", "bob@example.net", "vacation", []byte("Subject: x\r\n\r\nbody"))
+
+		process(t, queue, worker)
+		if len(bouncer.failed) != 0 {
+			t.Errorf("bounced a message with retries left: %+v", bouncer.failed)
+		}
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		queue, _ := NewDiskQueue(t.TempDir(), 1, nil)
+		bouncer := &captureBouncer{}
+		worker := NewWorker(queue, failingRelayHandler{errors.New("connection refused")}, time.Minute, 10, 1, time.Hour, time.Hour, nil)
+		worker.SetBouncer(bouncer)
+		queue.Enqueue("alice@example.com", "bob@example.net", "vacation", []byte("Subject: x\r\n\r\nbody"))
+
+		process(t, queue, worker)
+		if len(bouncer.failed) != 1 {
+			t.Fatalf("expected 1 bounce, got %d", len(bouncer.failed))
+		}
+		f := bouncer.failed[0]
+		if f.Permanent || f.From != "alice@example.com" || f.Type != "vacation" || f.Attempts != 1 || f.LastError() != "connection refused" {
+			t.Errorf("unexpected failed message: %+v", f)
+		}
+	})
+
+	t.Run("permanent failure", func(t *testing.T) {
+		queue, _ := NewDiskQueue(t.TempDir(), 10, nil)
+		bouncer := &captureBouncer{}
+		rejected := &delivery.RelayError{Err: errors.New("550 5.1.1 no such user"), Permanent: true}
+		worker := NewWorker(queue, failingRelayHandler{rejected}, time.Minute, 10, 1, time.Hour, time.Hour, nil)
+		worker.SetBouncer(bouncer)
+		queue.Enqueue("alice@example.com", "bob@example.net", "redirect", []byte("Subject: x\r\n\r\nbody"))
+
+		process(t, queue, worker)
+		if len(bouncer.failed) != 1 || !bouncer.failed[0].Permanent || bouncer.failed[0].Status() != "5.1.1" {
+			t.Errorf("unexpected bounces: %+v", bouncer.failed)
+		}
+	})
+}
@@ -4,6 +4,7 @@
 // This package provides:
 //   - RFC 5228 SIEVE base specification
 //   - RFC 5230 vacation extension
+//   - RFC 5703 MIME extensions: mime, foreverypart, extracttext, replace, enclose
 //   - fileinto, reject, discard, keep actions
 //   - address, envelope, header tests
 //   - Vacation response tracking (prevents loops)
@@ -57,6 +58,39 @@
 //   - stop: Stop script execution
 //   - vacation: Send auto-reply
 //
+// # MIME Extensions
+//
+// go-sieve does not implement RFC 5703, so scripts requiring any of
+// MIMEExtensions are kept as a parsed command tree and rewritten for each
+// message against its MIME tree (built from Context.Raw): header/address/exists
+// tests with :mime or :anychild become "string" tests over the selected values,
+// foreverypart loops are unrolled once per body part, extracttext becomes a
+// "set", and replace/enclose are recorded and applied after execution, with the
+// rewritten message returned in Result.Message. Values are passed in variables
+// prefixed "sora_mime_", which scripts must not use, so the MIME extensions are
+// only available when "variables" is enabled. Parts that replace leaves alone
+// are written back byte for byte. Only the first 100 parts are parsed, so
+// replace fails on a larger message rather than dropping the rest. Header
+// names in :mime tests are selected before the script runs and are matched
+// literally, without variable expansion.
+//
+//	require ["fileinto", "mime", "foreverypart"];
+//	foreverypart {
+//	    if header :mime :subtype "Content-Type" "pdf" {
+//	        fileinto "Attachments";
+//	        break;
+//	    }
+//	}
+//
+// # Tracing
+//
+// Trace runs a script like Evaluate, without vacation or redirect oracles so
+// nothing is recorded, and returns a TraceStep for every test and action in
+// script order: the outcome of each evaluation of a test, and how many times
+// each action ran. It instruments the script with marker variables prefixed
+// "sora_trace_". It backs "sora-admin sieve test" and the User API
+// POST /user/filters/{name}/test.
+//
 // # Vacation Tracking
 //
 // To prevent mail loops, vacation responses are tracked in the database.
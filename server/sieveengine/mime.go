@@ -0,0 +1,1118 @@
+package sieveengine
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/textproto"
+	"github.com/k3a/html2text"
+	"github.com/migadu/go-sieve"
+	"github.com/migadu/go-sieve/interp"
+	"github.com/migadu/go-sieve/lexer"
+	"github.com/migadu/go-sieve/parser"
+)
+
+// MIMEExtensions are the RFC 5703 extensions Sora implements on top of
+// go-sieve. go-sieve does not know them, so scripts requiring any of them are
+// rewritten per message (see mimeProgram) into plain RFC 5228/5229 Sieve
+// before they are loaded.
+var MIMEExtensions = []string{"mime", "foreverypart", "extracttext", "replace", "enclose"}
+
+// DefaultMIMEExtensions is the read-only subset of MIMEExtensions enabled by
+// default. replace and enclose rewrite the stored message and, like
+// editheader, must be enabled explicitly.
+var DefaultMIMEExtensions = []string{"mime", "foreverypart", "extracttext"}
+
+const (
+	// maxMIMEParts caps how many body parts foreverypart iterates over, so a
+	// message with thousands of parts cannot blow up the unrolled script.
+	maxMIMEParts = 100
+	// maxMIMEDepth caps multipart nesting when building the MIME tree.
+	maxMIMEDepth = 16
+	// maxUnrolledCommands caps the size of a script after foreverypart loops
+	// are unrolled for a particular message.
+	maxUnrolledCommands = 5000
+	// maxExtractedText caps the text extracttext reads from a single part.
+	maxExtractedText = 64 * 1024
+)
+
+// Reserved variable names used by the rewritten script. User scripts cannot
+// collide with them in practice; the prefix is documented in doc.go.
+const (
+	mimeVarPrefix    = "sora_mime_"
+	mimeActionsVar   = "sora_mime_actions"
+	mimeBreakVarBase = "sora_mime_brk_"
+)
+
+// errMIMETruncated is returned when replace or enclose would have to rebuild
+// a multipart whose parts were not all parsed (see maxMIMEParts).
+var errMIMETruncated = errors.New("message has too many MIME parts to rewrite")
+
+// mimePart is a node of the message's MIME tree. Bodies are kept as received
+// (transfer-encoded, and for multiparts with preamble and epilogue) so
+// replace/enclose reassemble untouched parts byte for byte.
+type mimePart struct {
+	Header   textproto.Header
+	Body     []byte // raw body as received; nil for multiparts built here
+	Boundary string // non-empty for multipart nodes
+	Children []*mimePart
+	// Preamble and Epilogue are the text before the first and after the
+	// last boundary delimiter, kept when a multipart is rebuilt. Epilogue
+	// starts right after the closing "--boundary--".
+	Preamble, Epilogue []byte
+	// Truncated is set when parsing stopped at maxMIMEParts before the end
+	// of this multipart, so Children is incomplete.
+	Truncated bool
+	replaced  bool // set by replace; ancestors must be rebuilt
+}
+
+// parseMIMETree builds a MIME tree from a raw RFC 5322 message. Malformed
+// multipart bodies degrade to leaves instead of failing the delivery.
+func parseMIMETree(raw []byte) (*mimePart, error) {
+	br := bufio.NewReader(bytes.NewReader(raw))
+	h, err := textproto.ReadHeader(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message header: %w", err)
+	}
+	count := 0
+	return newMIMEPart(h, br, 0, &count), nil
+}
+
+func newMIMEPart(h textproto.Header, body io.Reader, depth int, count *int) *mimePart {
+	p := &mimePart{Header: h}
+	raw, _ := io.ReadAll(body)
+
+	mediaType, params, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" || depth >= maxMIMEDepth {
+		p.Body = raw
+		return p
+	}
+
+	p.Body = raw
+	mr := textproto.NewMultipartReader(bytes.NewReader(raw), params["boundary"])
+	var children []*mimePart
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Not a well-formed multipart: treat it as an opaque leaf.
+			return p
+		}
+		if *count >= maxMIMEParts {
+			p.Truncated = true
+			break
+		}
+		*count++
+		children = append(children, newMIMEPart(part.Header, part, depth+1, count))
+	}
+	p.Boundary = params["boundary"]
+	p.Children = children
+	p.Preamble, p.Epilogue = splitMultipart(raw, p.Boundary)
+	return p
+}
+
+// splitMultipart returns the preamble and epilogue of a multipart body. The
+// preamble keeps the line break before the first delimiter; the epilogue is
+// nil when there is no closing delimiter.
+func splitMultipart(body []byte, boundary string) (preamble, epilogue []byte) {
+	delim := []byte("--" + boundary)
+	if !bytes.HasPrefix(body, delim) {
+		i := bytes.Index(body, append([]byte("\n"), delim...))
+		if i < 0 {
+			return nil, nil
+		}
+		preamble = body[:i+1]
+	}
+	closing := append(append([]byte("\n"), delim...), "--"...)
+	if i := bytes.LastIndex(body, closing); i >= 0 {
+		epilogue = body[i+len(closing):]
+	}
+	return preamble, epilogue
+}
+
+// childReplaced reports whether a part below p was replaced, so p cannot be
+// written back from its raw body.
+func (p *mimePart) childReplaced() bool {
+	for _, c := range p.Children {
+		if c.replaced || c.childReplaced() {
+			return true
+		}
+	}
+	return false
+}
+
+// descendants returns every part below p in depth-first order, excluding p.
+func (p *mimePart) descendants() []*mimePart {
+	var out []*mimePart
+	for _, c := range p.Children {
+		out = append(out, c)
+		out = append(out, c.descendants()...)
+	}
+	return out
+}
+
+// headerValues returns the RFC 2047-decoded values of a header field. A
+// missing Content-Type defaults to text/plain (RFC 2045 §5.2).
+func (p *mimePart) headerValues(name string) []string {
+	values := p.Header.Values(name)
+	if len(values) == 0 && strings.EqualFold(name, "Content-Type") {
+		return []string{"text/plain; charset=us-ascii"}
+	}
+	dec := mime.WordDecoder{CharsetReader: charset.Reader}
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if d, err := dec.DecodeHeader(v); err == nil {
+			v = d
+		}
+		out = append(out, strings.TrimSpace(v))
+	}
+	return out
+}
+
+// text returns the decoded text of a text/* leaf, converting HTML to plain
+// text. Non-text and multipart parts have no text.
+func (p *mimePart) text() string {
+	if p.Boundary != "" {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+	if !strings.HasPrefix(mediaType, "text/") {
+		return ""
+	}
+	entity, err := message.New(message.Header{Header: p.Header}, bytes.NewReader(p.Body))
+	if err != nil && entity == nil {
+		return ""
+	}
+	b, _ := io.ReadAll(io.LimitReader(entity.Body, maxExtractedText))
+	if mediaType == "text/html" {
+		return html2text.HTML2Text(string(b))
+	}
+	return string(b)
+}
+
+// writeTo serializes the tree back into an RFC 5322 message. Multiparts
+// with nothing replaced below them are written from their raw body; others
+// are rebuilt, which fails for a truncated multipart rather than dropping
+// the parts that were not parsed.
+func (p *mimePart) writeTo(w *bytes.Buffer) error {
+	if err := textproto.WriteHeader(w, p.Header); err != nil {
+		return err
+	}
+	if p.Boundary == "" || (p.Body != nil && !p.childReplaced()) {
+		w.Write(p.Body)
+		return nil
+	}
+	if p.Truncated {
+		return errMIMETruncated
+	}
+	w.Write(p.Preamble)
+	for _, c := range p.Children {
+		w.WriteString("--" + p.Boundary + "\r\n")
+		if err := c.writeTo(w); err != nil {
+			return err
+		}
+		w.WriteString("\r\n")
+	}
+	w.WriteString("--" + p.Boundary + "--")
+	if p.Epilogue != nil {
+		w.Write(p.Epilogue)
+	} else {
+		w.WriteString("\r\n")
+	}
+	return nil
+}
+
+// mimeAction is a replace or enclose action recorded while rewriting. The
+// rewritten script stores the (variable-expanded) texts in reserved variables
+// and appends the action's index to mimeActionsVar when it executes.
+type mimeAction struct {
+	kind    string    // "replace" or "enclose"
+	part    *mimePart // replace target; the root outside foreverypart
+	mime    bool      // replace :mime - the text is a complete MIME entity
+	headers []string  // enclose :headers
+	subject bool      // :subject was given
+	from    bool      // replace :from was given
+}
+
+// mimeLoop is an active foreverypart loop during rewriting.
+type mimeLoop struct {
+	name     string
+	breakVar string // set to "1" by break; empty when the loop has no break
+}
+
+// mimeRewriter expands the RFC 5703 constructs of a parsed script against one
+// message's MIME tree.
+type mimeRewriter struct {
+	root    *mimePart
+	part    *mimePart // current foreverypart part, nil at top level
+	vars    map[string]string
+	actions []mimeAction
+	loops   []mimeLoop
+	seq     int
+	emitted int
+}
+
+func newMIMERewriter(root *mimePart) *mimeRewriter {
+	return &mimeRewriter{root: root, vars: make(map[string]string)}
+}
+
+// value stores v in a fresh reserved variable and returns a reference to it.
+// Values are passed by variable rather than inlined so "${...}" sequences in
+// message content are never expanded by the interpreter.
+func (r *mimeRewriter) value(v string) string {
+	name := mimeVarPrefix + strconv.Itoa(r.seq)
+	r.seq++
+	r.vars[name] = v
+	return "${" + name + "}"
+}
+
+func (r *mimeRewriter) rewriteBlock(cmds []parser.Cmd) ([]parser.Cmd, error) {
+	out := make([]parser.Cmd, 0, len(cmds))
+	for _, cmd := range cmds {
+		rewritten, err := r.rewriteCmd(cmd)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rewritten...)
+	}
+	r.emitted += len(out)
+	if r.emitted > maxUnrolledCommands {
+		return nil, fmt.Errorf("foreverypart: script too large for this message (more than %d commands)", maxUnrolledCommands)
+	}
+	return r.guard(out), nil
+}
+
+func (r *mimeRewriter) rewriteCmd(cmd parser.Cmd) ([]parser.Cmd, error) {
+	switch strings.ToLower(cmd.Id) {
+	case "require":
+		return []parser.Cmd{stripMIMERequires(cmd)}, nil
+	case "foreverypart":
+		return r.rewriteForEveryPart(cmd)
+	case "break":
+		return r.rewriteBreak(cmd)
+	case "extracttext":
+		return r.rewriteExtractText(cmd)
+	case "replace", "enclose":
+		return r.rewriteMessageAction(cmd)
+	}
+
+	tests, err := r.rewriteTests(cmd.Tests)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Tests = tests
+	if cmd.Block != nil {
+		block, err := r.rewriteBlock(cmd.Block)
+		if err != nil {
+			return nil, err
+		}
+		cmd.Block = block
+	}
+	return []parser.Cmd{cmd}, nil
+}
+
+// rewriteForEveryPart unrolls a foreverypart loop (RFC 5703 §3) into one copy
+// of its block per body part below the current part.
+func (r *mimeRewriter) rewriteForEveryPart(cmd parser.Cmd) ([]parser.Cmd, error) {
+	loop := mimeLoop{}
+	for i := 0; i < len(cmd.Args); i++ {
+		tag, ok := cmd.Args[i].(parser.TagArg)
+		if !ok || !strings.EqualFold(tag.Value, "name") || i+1 >= len(cmd.Args) {
+			return nil, lexer.ErrorAt(cmd.Position, "foreverypart: unexpected argument")
+		}
+		name, ok := cmd.Args[i+1].(parser.StringArg)
+		if !ok {
+			return nil, lexer.ErrorAt(cmd.Position, "foreverypart: :name requires a string")
+		}
+		loop.name = name.Value
+		i++
+	}
+	if containsBreak(cmd.Block) {
+		loop.breakVar = mimeBreakVarBase + strconv.Itoa(len(r.loops)) + "_" + strconv.Itoa(r.seq)
+		r.seq++
+	}
+
+	parent := r.part
+	scope := r.root
+	if parent != nil {
+		scope = parent
+	}
+	r.loops = append(r.loops, loop)
+	defer func() {
+		r.loops = r.loops[:len(r.loops)-1]
+		r.part = parent
+	}()
+
+	var out []parser.Cmd
+	if loop.breakVar != "" {
+		out = append(out, setCmd(cmd.Position, loop.breakVar, ""))
+	}
+	for _, part := range scope.descendants() {
+		r.part = part
+		block, err := r.rewriteBlock(cmd.Block)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, block...)
+	}
+	return out, nil
+}
+
+func (r *mimeRewriter) rewriteBreak(cmd parser.Cmd) ([]parser.Cmd, error) {
+	name := ""
+	if len(cmd.Args) == 2 {
+		tag, ok1 := cmd.Args[0].(parser.TagArg)
+		val, ok2 := cmd.Args[1].(parser.StringArg)
+		if !ok1 || !ok2 || !strings.EqualFold(tag.Value, "name") {
+			return nil, lexer.ErrorAt(cmd.Position, "break: unexpected argument")
+		}
+		name = val.Value
+	} else if len(cmd.Args) != 0 {
+		return nil, lexer.ErrorAt(cmd.Position, "break: unexpected argument")
+	}
+	for i := len(r.loops) - 1; i >= 0; i-- {
+		if name == "" || r.loops[i].name == name {
+			return []parser.Cmd{setCmd(cmd.Position, r.loops[i].breakVar, "1")}, nil
+		}
+	}
+	if name != "" {
+		return nil, lexer.ErrorAt(cmd.Position, "break: no enclosing foreverypart named %q", name)
+	}
+	return nil, lexer.ErrorAt(cmd.Position, "break: not inside foreverypart")
+}
+
+// rewriteExtractText turns extracttext (RFC 5703 §7) into a set command that
+// copies the current part's text, keeping any set modifiers.
+func (r *mimeRewriter) rewriteExtractText(cmd parser.Cmd) ([]parser.Cmd, error) {
+	if r.part == nil {
+		return nil, lexer.ErrorAt(cmd.Position, "extracttext: must be used inside foreverypart")
+	}
+	var args []parser.Arg
+	first := -1
+	var varName parser.Arg
+	for i := 0; i < len(cmd.Args); i++ {
+		switch a := cmd.Args[i].(type) {
+		case parser.TagArg:
+			if strings.EqualFold(a.Value, "first") {
+				n, ok := argAt[parser.NumberArg](cmd.Args, i+1)
+				if !ok {
+					return nil, lexer.ErrorAt(cmd.Position, "extracttext: :first requires a number")
+				}
+				first = n.Value
+				i++
+				continue
+			}
+			args = append(args, a)
+		case parser.StringArg:
+			if varName != nil {
+				return nil, lexer.ErrorAt(cmd.Position, "extracttext: unexpected argument")
+			}
+			varName = a
+		default:
+			return nil, lexer.ErrorAt(cmd.Position, "extracttext: unexpected argument")
+		}
+	}
+	if varName == nil {
+		return nil, lexer.ErrorAt(cmd.Position, "extracttext: missing variable name")
+	}
+
+	text := r.part.text()
+	if first >= 0 {
+		if runes := []rune(text); len(runes) > first {
+			text = string(runes[:first])
+		}
+	}
+	args = append(args, varName, parser.StringArg{Value: r.value(text), Position: cmd.Position})
+	return []parser.Cmd{{Position: cmd.Position, Id: "set", Args: args}}, nil
+}
+
+// rewriteMessageAction records a replace (RFC 5703 §5) or enclose (§6)
+// action. Its texts are copied into reserved variables at run time so
+// variable references in them expand as usual.
+func (r *mimeRewriter) rewriteMessageAction(cmd parser.Cmd) ([]parser.Cmd, error) {
+	kind := strings.ToLower(cmd.Id)
+	action := mimeAction{kind: kind, part: r.root}
+	if kind == "replace" && r.part != nil {
+		action.part = r.part
+	}
+	if kind == "enclose" && r.part != nil {
+		return nil, lexer.ErrorAt(cmd.Position, "enclose: cannot be used inside foreverypart")
+	}
+
+	idx := len(r.actions)
+	prefix := mimeVarPrefix + "act" + strconv.Itoa(idx) + "_"
+	var out []parser.Cmd
+	var text *parser.StringArg
+	for i := 0; i < len(cmd.Args); i++ {
+		switch a := cmd.Args[i].(type) {
+		case parser.TagArg:
+			tag := strings.ToLower(a.Value)
+			switch {
+			case tag == "mime" && kind == "replace":
+				action.mime = true
+			case tag == "subject", tag == "from" && kind == "replace":
+				s, ok := argAt[parser.StringArg](cmd.Args, i+1)
+				if !ok {
+					return nil, lexer.ErrorAt(cmd.Position, "%s: :%s requires a string", kind, tag)
+				}
+				if tag == "subject" {
+					action.subject = true
+				} else {
+					action.from = true
+				}
+				out = append(out, setCmd(cmd.Position, prefix+tag, s.Value))
+				i++
+			case tag == "headers" && kind == "enclose":
+				l, ok := stringsAt(cmd.Args, i+1)
+				if !ok {
+					return nil, lexer.ErrorAt(cmd.Position, "enclose: :headers requires a string list")
+				}
+				action.headers = l
+				i++
+			default:
+				return nil, lexer.ErrorAt(cmd.Position, "%s: unsupported tag :%s", kind, tag)
+			}
+		case parser.StringArg:
+			if text != nil {
+				return nil, lexer.ErrorAt(cmd.Position, "%s: unexpected argument", kind)
+			}
+			text = &a
+		default:
+			return nil, lexer.ErrorAt(cmd.Position, "%s: unexpected argument", kind)
+		}
+	}
+	if text == nil {
+		return nil, lexer.ErrorAt(cmd.Position, "%s: missing text", kind)
+	}
+	r.actions = append(r.actions, action)
+
+	out = append(out,
+		setCmd(cmd.Position, prefix+"text", text.Value),
+		setCmd(cmd.Position, mimeActionsVar, "${"+mimeActionsVar+"} "+strconv.Itoa(idx)))
+	return out, nil
+}
+
+func (r *mimeRewriter) rewriteTests(tests []parser.Test) ([]parser.Test, error) {
+	if tests == nil {
+		return nil, nil
+	}
+	out := make([]parser.Test, len(tests))
+	for i, t := range tests {
+		rewritten, err := r.rewriteTest(t)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = rewritten
+	}
+	return out, nil
+}
+
+func (r *mimeRewriter) rewriteTest(t parser.Test) (parser.Test, error) {
+	id := strings.ToLower(t.Id)
+	if (id == "header" || id == "address" || id == "exists") && hasTag(t.Args, "mime", "anychild") {
+		return r.rewriteMIMETest(id, t)
+	}
+	tests, err := r.rewriteTests(t.Tests)
+	if err != nil {
+		return t, err
+	}
+	t.Tests = tests
+	return t, nil
+}
+
+// rewriteMIMETest evaluates the MIME part selection of a header, address or
+// exists test with :mime/:anychild (RFC 5703 §4) and replaces it with an
+// equivalent "string" test over the selected values, keeping match type,
+// comparator and relational arguments. The selection happens before the
+// script runs, so header names are matched literally: variable references
+// in them are not expanded. Keys are expanded as usual.
+func (r *mimeRewriter) rewriteMIMETest(id string, t parser.Test) (parser.Test, error) {
+	var (
+		matchArgs   []parser.Arg
+		positional  [][]string
+		anychild    bool
+		option      string
+		params      []string
+		addressPart = "all"
+	)
+	for i := 0; i < len(t.Args); i++ {
+		switch a := t.Args[i].(type) {
+		case parser.TagArg:
+			tag := strings.ToLower(a.Value)
+			switch tag {
+			case "mime":
+			case "anychild":
+				anychild = true
+			case "type", "subtype", "contenttype":
+				option = tag
+			case "param":
+				l, ok := stringsAt(t.Args, i+1)
+				if !ok {
+					return t, lexer.ErrorAt(t.Position, "%s: :param requires a string list", id)
+				}
+				option, params = tag, l
+				i++
+			case "all", "localpart", "domain", "user", "detail":
+				addressPart = tag
+			case "is", "contains", "matches", "regex":
+				matchArgs = append(matchArgs, a)
+			case "comparator", "value", "count":
+				if i+1 >= len(t.Args) {
+					return t, lexer.ErrorAt(t.Position, "%s: :%s requires an argument", id, tag)
+				}
+				matchArgs = append(matchArgs, a, t.Args[i+1])
+				i++
+			default:
+				return t, lexer.ErrorAt(t.Position, "%s: :%s cannot be combined with :mime", id, tag)
+			}
+		default:
+			l, ok := stringsAt(t.Args, i)
+			if !ok {
+				return t, lexer.ErrorAt(t.Position, "%s: unexpected argument", id)
+			}
+			positional = append(positional, l)
+		}
+	}
+
+	wantPositional := 2
+	if id == "exists" {
+		wantPositional = 1
+	}
+	if len(positional) != wantPositional {
+		return t, lexer.ErrorAt(t.Position, "%s: expected %d string list arguments", id, wantPositional)
+	}
+	if option != "" && id == "exists" {
+		return t, lexer.ErrorAt(t.Position, "exists: :%s is not allowed", option)
+	}
+
+	parts := []*mimePart{r.root}
+	if r.part != nil {
+		parts = []*mimePart{r.part}
+	}
+	if anychild {
+		parts = append(parts, parts[0].descendants()...)
+	}
+
+	if id == "exists" {
+		for _, p := range parts {
+			all := true
+			for _, name := range positional[0] {
+				if !p.Header.Has(name) && !strings.EqualFold(name, "Content-Type") {
+					all = false
+					break
+				}
+			}
+			if all {
+				return parser.Test{Position: t.Position, Id: "true"}, nil
+			}
+		}
+		return parser.Test{Position: t.Position, Id: "false"}, nil
+	}
+
+	var values []string
+	for _, p := range parts {
+		for _, name := range positional[0] {
+			for _, v := range p.headerValues(name) {
+				if id == "address" {
+					values = append(values, addressValues(v, addressPart)...)
+					continue
+				}
+				values = append(values, headerOptionValues(name, v, option, params)...)
+			}
+		}
+	}
+
+	isCount := false
+	for _, a := range matchArgs {
+		if tag, ok := a.(parser.TagArg); ok && strings.EqualFold(tag.Value, "count") {
+			isCount = true
+		}
+	}
+	if len(values) == 0 {
+		if !isCount {
+			return parser.Test{Position: t.Position, Id: "false"}, nil
+		}
+		// "string" :count counts non-empty strings, so this counts zero.
+		values = []string{""}
+	}
+
+	source := make([]string, len(values))
+	for i, v := range values {
+		source[i] = r.value(v)
+	}
+	args := append(matchArgs,
+		parser.StringListArg{Value: source, Position: t.Position},
+		parser.StringListArg{Value: positional[1], Position: t.Position})
+	return parser.Test{Position: t.Position, Id: "string", Args: args}, nil
+}
+
+// guard wraps the commands of a foreverypart iteration so nothing after a
+// break executes. An if/elsif/else chain is wrapped as a unit.
+func (r *mimeRewriter) guard(cmds []parser.Cmd) []parser.Cmd {
+	var flags strings.Builder
+	for _, l := range r.loops {
+		if l.breakVar != "" {
+			flags.WriteString("${" + l.breakVar + "}")
+		}
+	}
+	if flags.Len() == 0 || len(cmds) == 0 {
+		return cmds
+	}
+
+	out := make([]parser.Cmd, 0, len(cmds))
+	for i := 0; i < len(cmds); i++ {
+		group := []parser.Cmd{cmds[i]}
+		if strings.EqualFold(cmds[i].Id, "if") {
+			for i+1 < len(cmds) && (strings.EqualFold(cmds[i+1].Id, "elsif") || strings.EqualFold(cmds[i+1].Id, "else")) {
+				i++
+				group = append(group, cmds[i])
+			}
+		}
+		pos := group[0].Position
+		out = append(out, parser.Cmd{
+			Position: pos,
+			Id:       "if",
+			Tests: []parser.Test{{
+				Position: pos,
+				Id:       "string",
+				Args: []parser.Arg{
+					parser.TagArg{Value: "is", Position: pos},
+					parser.StringArg{Value: flags.String(), Position: pos},
+					parser.StringArg{Value: "", Position: pos},
+				},
+			}},
+			Block: group,
+		})
+	}
+	return out
+}
+
+// apply executes the replace/enclose actions whose indexes the script
+// recorded in mimeActionsVar, in order, and returns the rewritten message.
+func (r *mimeRewriter) apply(vars map[string]string) ([]byte, error) {
+	executed := strings.Fields(vars[mimeActionsVar])
+	if len(executed) == 0 {
+		return nil, nil
+	}
+	root := r.root
+	for _, s := range executed {
+		idx, err := strconv.Atoi(s)
+		if err != nil || idx < 0 || idx >= len(r.actions) {
+			continue
+		}
+		action := r.actions[idx]
+		prefix := mimeVarPrefix + "act" + strconv.Itoa(idx) + "_"
+		text := vars[prefix+"text"]
+
+		switch action.kind {
+		case "replace":
+			replacement, err := replacementPart(text, action.mime)
+			if err != nil {
+				return nil, err
+			}
+			target := action.part
+			if target == r.root {
+				target = root
+				keepNonContentHeaders(&replacement.Header, root.Header)
+			}
+			*target = *replacement
+			target.replaced = true
+			if action.from {
+				root.Header.Set("From", vars[prefix+"from"])
+			}
+		case "enclose":
+			enclosed, err := encloseMessage(root, text, action.headers)
+			if err != nil {
+				return nil, err
+			}
+			root = enclosed
+		}
+		if action.subject {
+			root.Header.Del("Subject")
+			root.Header.Add("Subject", mime.QEncoding.Encode("utf-8", vars[prefix+"subject"]))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := root.writeTo(&buf); err != nil {
+		return nil, fmt.Errorf("failed to write rewritten message: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// replacementPart builds the part replace installs: either a complete MIME
+// entity given by the script (:mime) or a UTF-8 text/plain part.
+func replacementPart(text string, isMIME bool) (*mimePart, error) {
+	if isMIME {
+		raw := strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+		if !strings.Contains(raw, "\r\n\r\n") {
+			raw = "\r\n" + raw
+		}
+		return parseMIMETree([]byte(raw))
+	}
+	var h textproto.Header
+	h.Set("Content-Transfer-Encoding", "8bit")
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	return &mimePart{Header: h, Body: []byte(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n"))}, nil
+}
+
+// keepNonContentHeaders replaces the non-content headers of h with the
+// top-level headers of orig other than Content-* and MIME-Version, so a
+// replaced or enclosing message keeps its From, To, Subject, Date and trace
+// headers.
+func keepNonContentHeaders(h *textproto.Header, orig textproto.Header) {
+	type field struct{ k, v string }
+	var content, kept []field
+	fields := h.Fields()
+	for fields.Next() {
+		if isContentHeader(fields.Key()) {
+			content = append(content, field{fields.Key(), fields.Value()})
+		}
+	}
+	fields = orig.Fields()
+	for fields.Next() {
+		if !isContentHeader(fields.Key()) {
+			kept = append(kept, field{fields.Key(), fields.Value()})
+		}
+	}
+
+	// Add prepends, so build the header bottom-up to keep the original order
+	// with the content headers last.
+	var merged textproto.Header
+	for i := len(content) - 1; i >= 0; i-- {
+		merged.Add(content[i].k, content[i].v)
+	}
+	merged.Add("MIME-Version", "1.0")
+	for i := len(kept) - 1; i >= 0; i-- {
+		merged.Add(kept[i].k, kept[i].v)
+	}
+	*h = merged
+}
+
+func isContentHeader(k string) bool {
+	k = strings.ToLower(k)
+	return strings.HasPrefix(k, "content-") || k == "mime-version"
+}
+
+// encloseMessage wraps the message in a new multipart/mixed message whose
+// first part is text and whose second part is the original message.
+func encloseMessage(root *mimePart, text string, headers []string) (*mimePart, error) {
+	var orig bytes.Buffer
+	if err := root.writeTo(&orig); err != nil {
+		return nil, err
+	}
+
+	outer := &mimePart{}
+	keepNonContentHeaders(&outer.Header, root.Header)
+	for _, line := range headers {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(k) == "" {
+			continue
+		}
+		outer.Header.Set(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	// A random boundary cannot be predicted, and so cannot be planted, by
+	// the sender of the enclosed message.
+	outer.Boundary = "sora-enclose-" + rand.Text()
+	outer.Header.Set("Content-Type", "multipart/mixed; boundary=\""+outer.Boundary+"\"")
+
+	textPart, _ := replacementPart(text, false)
+	var rfc822 textproto.Header
+	rfc822.Set("Content-Type", "message/rfc822")
+	outer.Children = []*mimePart{textPart, {Header: rfc822, Body: orig.Bytes()}}
+	return outer, nil
+}
+
+// headerOptionValues applies the :type/:subtype/:contenttype/:param options
+// to a Content-Type or Content-Disposition value. Other headers only match
+// when no option is given.
+func headerOptionValues(name, value, option string, params []string) []string {
+	if option == "" {
+		return []string{value}
+	}
+	isType := strings.EqualFold(name, "Content-Type")
+	if !isType && !strings.EqualFold(name, "Content-Disposition") {
+		return nil
+	}
+	full, p, err := mime.ParseMediaType(value)
+	if err != nil {
+		return nil
+	}
+	typ, sub := full, ""
+	if isType {
+		typ, sub, _ = strings.Cut(full, "/")
+	}
+	switch option {
+	case "type":
+		return []string{typ}
+	case "subtype":
+		return []string{sub}
+	case "contenttype":
+		return []string{full}
+	case "param":
+		var out []string
+		for _, name := range params {
+			if v, ok := p[strings.ToLower(name)]; ok {
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// addressValues extracts the requested address part (RFC 5228 §2.7.4 and
+// RFC 5233 subaddress) from every address in a header value.
+func addressValues(value, part string) []string {
+	list, err := mail.ParseAddressList(value)
+	if err != nil {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, a := range list {
+		local, domain := a.Address, ""
+		if at := strings.LastIndex(a.Address, "@"); at >= 0 {
+			local, domain = a.Address[:at], a.Address[at+1:]
+		}
+		user, detail, _ := strings.Cut(local, "+")
+		switch part {
+		case "localpart":
+			out = append(out, local)
+		case "domain":
+			out = append(out, domain)
+		case "user":
+			out = append(out, user)
+		case "detail":
+			out = append(out, detail)
+		default:
+			out = append(out, a.Address)
+		}
+	}
+	return out
+}
+
+// mimeProgram is a script that requires RFC 5703 extensions. It is kept as a
+// parsed command tree and loaded per message after rewriting.
+type mimeProgram struct {
+	cmds       []parser.Cmd
+	opts       sieve.Options
+	extensions []string // extensions passed to go-sieve (MIME ones removed)
+}
+
+// loadScript loads scriptContent. Scripts that require none of the
+// MIMEExtensions are loaded directly; otherwise the returned mimeProgram must
+// be instantiated per message with load.
+func loadScript(scriptContent string, options sieve.Options) (*sieve.Script, *mimeProgram, error) {
+	options.EnabledExtensions = allowedExtensions(options.EnabledExtensions)
+	toks, err := lexer.Lex(strings.NewReader(scriptContent), &options.Lexer)
+	if err != nil {
+		return nil, nil, err
+	}
+	cmds, err := parser.Parse(lexer.NewStream(toks), &options.Parser)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	required := requiredMIMEExtensions(cmds)
+	if len(required) == 0 {
+		script, err := interp.LoadScript(cmds, &options.Interp, options.EnabledExtensions)
+		return script, nil, err
+	}
+	for _, ext := range required {
+		if !containsString(options.EnabledExtensions, ext) {
+			return nil, nil, fmt.Errorf("extension '%s' is not supported", ext)
+		}
+	}
+	// The rewrite passes values through variables, so it is only available
+	// where users may use variables themselves.
+	if !containsString(options.EnabledExtensions, "variables") {
+		return nil, nil, fmt.Errorf("extension '%s' requires 'variables' to be enabled", required[0])
+	}
+
+	prog := &mimeProgram{cmds: cmds, opts: options, extensions: rewrittenExtensions(options.EnabledExtensions)}
+	// Validate against an empty single-part message so syntax errors surface
+	// when the script is uploaded rather than at delivery time.
+	if _, _, err := prog.load(&mimePart{}); err != nil {
+		return nil, nil, err
+	}
+	return nil, prog, nil
+}
+
+// rewrittenExtensions returns the extensions go-sieve must accept for a
+// rewritten script: the enabled ones minus MIMEExtensions.
+func rewrittenExtensions(enabled []string) []string {
+	exts := make([]string, 0, len(enabled))
+	for _, ext := range enabled {
+		if !containsString(MIMEExtensions, ext) {
+			exts = append(exts, ext)
+		}
+	}
+	return exts
+}
+
+// load rewrites the program against a message's MIME tree and loads it.
+func (p *mimeProgram) load(root *mimePart) (*sieve.Script, *mimeRewriter, error) {
+	rw := newMIMERewriter(root)
+	cmds, err := rw.rewriteBlock(p.cmds)
+	if err != nil {
+		return nil, nil, err
+	}
+	cmds = append([]parser.Cmd{requireVariables(cmds)}, cmds...)
+	script, err := interp.LoadScript(cmds, &p.opts.Interp, p.extensions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return script, rw, nil
+}
+
+// requiredMIMEExtensions returns the MIMEExtensions named in require commands.
+func requiredMIMEExtensions(cmds []parser.Cmd) []string {
+	var out []string
+	for _, cmd := range cmds {
+		if !strings.EqualFold(cmd.Id, "require") {
+			continue
+		}
+		for i := range cmd.Args {
+			l, _ := stringsAt(cmd.Args, i)
+			for _, ext := range l {
+				if containsString(MIMEExtensions, ext) && !containsString(out, ext) {
+					out = append(out, ext)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// stripMIMERequires removes MIMEExtensions from a require command, which
+// go-sieve would otherwise reject. A require left empty becomes a no-op.
+func stripMIMERequires(cmd parser.Cmd) parser.Cmd {
+	var kept []string
+	for i := range cmd.Args {
+		l, _ := stringsAt(cmd.Args, i)
+		for _, ext := range l {
+			if !containsString(MIMEExtensions, ext) {
+				kept = append(kept, ext)
+			}
+		}
+	}
+	if len(kept) == 0 {
+		kept = []string{"variables"}
+	}
+	cmd.Args = []parser.Arg{parser.StringListArg{Value: kept, Position: cmd.Position}}
+	return cmd
+}
+
+func requireVariables(cmds []parser.Cmd) parser.Cmd {
+	pos := lexer.Position{}
+	if len(cmds) > 0 {
+		pos = cmds[0].Position
+	}
+	return parser.Cmd{Position: pos, Id: "require", Args: []parser.Arg{parser.StringArg{Value: "variables", Position: pos}}}
+}
+
+func setCmd(pos lexer.Position, name, value string) parser.Cmd {
+	return parser.Cmd{Position: pos, Id: "set", Args: []parser.Arg{
+		parser.StringArg{Value: name, Position: pos},
+		parser.StringArg{Value: value, Position: pos},
+	}}
+}
+
+func containsBreak(cmds []parser.Cmd) bool {
+	for _, cmd := range cmds {
+		id := strings.ToLower(cmd.Id)
+		if id == "break" {
+			return true
+		}
+		if id != "foreverypart" && containsBreak(cmd.Block) {
+			return true
+		}
+		if id == "foreverypart" && containsNamedBreak(cmd.Block) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsNamedBreak reports whether a nested loop breaks out by name, which
+// may target an enclosing loop.
+func containsNamedBreak(cmds []parser.Cmd) bool {
+	for _, cmd := range cmds {
+		if strings.EqualFold(cmd.Id, "break") && len(cmd.Args) > 0 {
+			return true
+		}
+		if containsNamedBreak(cmd.Block) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTag(args []parser.Arg, names ...string) bool {
+	for _, a := range args {
+		if tag, ok := a.(parser.TagArg); ok {
+			for _, n := range names {
+				if strings.EqualFold(tag.Value, n) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func argAt[T parser.Arg](args []parser.Arg, i int) (T, bool) {
+	var zero T
+	if i >= len(args) {
+		return zero, false
+	}
+	v, ok := args[i].(T)
+	return v, ok
+}
+
+func stringsAt(args []parser.Arg, i int) ([]string, bool) {
+	if i >= len(args) {
+		return nil, false
+	}
+	switch a := args[i].(type) {
+	case parser.StringArg:
+		return []string{a.Value}, true
+	case parser.StringListArg:
+		return a.Value, true
+	}
+	return nil, false
+}
+
+// allowedExtensions returns the extensions a script may require. nil, as
+// passed by NewSieveExecutor, allows every extension; go-sieve itself reads
+// nil as none.
+func allowedExtensions(enabled []string) []string {
+	if enabled == nil {
+		return SupportedSieveExtensions
+	}
+	return enabled
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
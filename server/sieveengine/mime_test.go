@@ -0,0 +1,264 @@
+package sieveengine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-message"
+)
+
+const mimeTestMessage = "From: alice@example.com\r\n" +
+	"To: bob@example.com\r\n" +
+	"Subject: Quarterly report\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Please find the report attached. Invoice 4711.\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/pdf; name=\"report.pdf\"\r\n" +
+	"Content-Disposition: attachment; filename=\"report.pdf\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"JVBERi0xLjQK\r\n" +
+	"--outer--\r\n"
+
+var mimeTestExtensions = append(append([]string{}, DefaultSieveExtensions...), "replace", "enclose")
+
+func evaluateMIME(t *testing.T, script string) Result {
+	t.Helper()
+	result, err := evaluateMIMEMessage(t, script, mimeTestMessage)
+	if err != nil {
+		t.Fatalf("Failed to evaluate script: %v", err)
+	}
+	return result
+}
+
+func evaluateMIMEMessage(t *testing.T, script, raw string) (Result, error) {
+	t.Helper()
+	executor, err := NewSieveExecutorWithExtensions(script, mimeTestExtensions)
+	if err != nil {
+		t.Fatalf("Failed to create executor: %v", err)
+	}
+	entity, err := message.Read(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Failed to parse test message: %v", err)
+	}
+	return executor.Evaluate(context.Background(), Context{
+		EnvelopeFrom: "alice@example.com",
+		EnvelopeTo:   "bob@example.com",
+		Header:       entity.Header.Map(),
+		Raw:          []byte(raw),
+	})
+}
+
+func TestMIMEHeaderAnyChild(t *testing.T) {
+	tests := []struct {
+		name    string
+		test    string
+		matches bool
+	}{
+		{"pdf attachment by content type", `header :mime :anychild :contenttype "Content-Type" "application/pdf"`, true},
+		{"pdf attachment by filename param", `header :mime :anychild :matches :param "filename" "Content-Disposition" "*.pdf"`, true},
+		{"top-level type only", `header :mime :type "Content-Type" "multipart"`, true},
+		{"no zip attachment", `header :mime :anychild :subtype "Content-Type" "zip"`, false},
+		{"exists in a child", `exists :mime :anychild "Content-Disposition"`, true},
+		{"address with :mime", `address :mime :domain "From" "example.com"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := `require ["fileinto", "mime"];
+if ` + tt.test + ` { fileinto "Matched"; }`
+			result := evaluateMIME(t, script)
+			if got := result.Action == ActionFileInto; got != tt.matches {
+				t.Errorf("matched = %v, want %v (action %s)", got, tt.matches, result.Action)
+			}
+		})
+	}
+}
+
+func TestForEveryPartWithBreak(t *testing.T) {
+	script := `require ["fileinto", "imap4flags", "mime", "foreverypart", "variables"];
+set "seen" "";
+foreverypart {
+	set "seen" "${seen}x";
+	if header :mime :subtype "Content-Type" "pdf" {
+		fileinto "Attachments";
+		break;
+	}
+	set "seen" "${seen}y";
+}
+if string :is "${seen}" "xyx" { addflag "\\Seen"; }`
+
+	result := evaluateMIME(t, script)
+	if result.Action != ActionFileInto || result.Mailbox != "Attachments" {
+		t.Fatalf("expected fileinto Attachments, got %s %q", result.Action, result.Mailbox)
+	}
+	if len(result.Flags) != 1 {
+		t.Errorf("expected break to skip the rest of the iteration, flags = %v", result.Flags)
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	script := `require ["fileinto", "mime", "foreverypart", "extracttext", "variables"];
+foreverypart {
+	if header :mime :type "Content-Type" "text" {
+		extracttext :first 60 "text";
+		if string :matches "${text}" "*Invoice*" { fileinto "Invoices"; }
+	}
+}`
+	result := evaluateMIME(t, script)
+	if result.Action != ActionFileInto || result.Mailbox != "Invoices" {
+		t.Errorf("expected fileinto Invoices, got %s %q", result.Action, result.Mailbox)
+	}
+}
+
+func TestExtractTextOutsideLoopRejected(t *testing.T) {
+	script := `require ["extracttext", "variables"]; extracttext "text";`
+	if _, err := NewSieveExecutorWithExtensions(script, mimeTestExtensions); err == nil {
+		t.Fatal("expected extracttext outside foreverypart to be rejected")
+	}
+}
+
+func TestMIMEExtensionNotEnabled(t *testing.T) {
+	script := `require ["mime", "fileinto"]; if header :mime :anychild "Content-Type" "x" { fileinto "X"; }`
+	if _, err := NewSieveExecutorWithExtensions(script, []string{"fileinto"}); err == nil {
+		t.Fatal("expected script requiring a disabled extension to be rejected")
+	}
+}
+
+func TestMIMERequiresVariablesEnabled(t *testing.T) {
+	script := `require ["mime", "fileinto"]; if header :mime :anychild "Content-Type" "x" { fileinto "X"; }`
+	if _, err := NewSieveExecutorWithExtensions(script, []string{"mime", "fileinto"}); err == nil {
+		t.Fatal("expected a MIME script to be rejected when variables is not enabled")
+	}
+	if _, err := NewSieveExecutorWithExtensions(script, []string{"mime", "fileinto", "variables"}); err != nil {
+		t.Fatalf("expected a MIME script to load with variables enabled: %v", err)
+	}
+	if _, err := NewSieveExecutor(script); err != nil {
+		t.Fatalf("expected a MIME script to load with every extension allowed: %v", err)
+	}
+}
+
+const replaceAttachmentScript = `require ["mime", "foreverypart", "replace"];
+foreverypart {
+	if header :mime :anychild :contenttype "Content-Type" "application/pdf" {
+		replace "The attachment was removed.";
+	}
+}`
+
+func TestReplaceKeepsPreambleAndEpilogue(t *testing.T) {
+	textPart := "--outer\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Please find the report attached.\r\n"
+	raw := "Subject: Quarterly report\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"outer\"\r\n" +
+		"\r\n" +
+		"This is a multi-part message in MIME format.\r\n" +
+		textPart +
+		"--outer\r\n" +
+		"Content-Type: application/pdf\r\n" +
+		"\r\n" +
+		"JVBERi0xLjQK\r\n" +
+		"--outer--\r\n" +
+		"Trailing epilogue.\r\n"
+
+	result, err := evaluateMIMEMessage(t, replaceAttachmentScript, raw)
+	if err != nil {
+		t.Fatalf("Failed to evaluate script: %v", err)
+	}
+	if bytes.Contains(result.Message, []byte("JVBERi0xLjQK")) {
+		t.Error("attachment body should have been replaced")
+	}
+	for _, want := range []string{"\r\n\r\nThis is a multi-part message in MIME format.\r\n" + textPart, "--outer--\r\nTrailing epilogue.\r\n"} {
+		if !bytes.Contains(result.Message, []byte(want)) {
+			t.Errorf("rewritten message missing %q:\n%s", want, result.Message)
+		}
+	}
+}
+
+func TestReplaceRefusesTruncatedTree(t *testing.T) {
+	var raw strings.Builder
+	raw.WriteString("Content-Type: multipart/mixed; boundary=\"b\"\r\n\r\n")
+	raw.WriteString("--b\r\nContent-Type: application/pdf\r\n\r\nJVBERi0xLjQK\r\n")
+	for i := 0; i < maxMIMEParts; i++ {
+		raw.WriteString("--b\r\nContent-Type: text/plain\r\n\r\npart\r\n")
+	}
+	raw.WriteString("--b--\r\n")
+
+	if _, err := evaluateMIMEMessage(t, replaceAttachmentScript, raw.String()); !errors.Is(err, errMIMETruncated) {
+		t.Fatalf("err = %v, want %v", err, errMIMETruncated)
+	}
+}
+
+func TestReplaceAttachment(t *testing.T) {
+	script := `require ["mime", "foreverypart", "replace"];
+foreverypart {
+	if header :mime :anychild :contenttype "Content-Type" "application/pdf" {
+		replace "The attachment was removed.";
+	}
+}`
+	result := evaluateMIME(t, script)
+	if result.Message == nil {
+		t.Fatal("expected the message to be rewritten")
+	}
+	if bytes.Contains(result.Message, []byte("JVBERi0xLjQK")) {
+		t.Error("attachment body should have been replaced")
+	}
+	for _, want := range []string{"Please find the report attached.", "The attachment was removed.", "Subject: Quarterly report"} {
+		if !bytes.Contains(result.Message, []byte(want)) {
+			t.Errorf("rewritten message missing %q:\n%s", want, result.Message)
+		}
+	}
+	if _, err := message.Read(bytes.NewReader(result.Message)); err != nil {
+		t.Errorf("rewritten message does not parse: %v", err)
+	}
+}
+
+func TestEnclose(t *testing.T) {
+	script := `require ["enclose"];
+enclose :subject "Suspicious message" "This message was quarantined.";`
+	result := evaluateMIME(t, script)
+	if result.Message == nil {
+		t.Fatal("expected the message to be rewritten")
+	}
+	entity, err := message.Read(bytes.NewReader(result.Message))
+	if err != nil {
+		t.Fatalf("enclosed message does not parse: %v", err)
+	}
+	if got := entity.Header.Get("Subject"); got != "Suspicious message" {
+		t.Errorf("Subject = %q", got)
+	}
+	mediaType, _, _ := entity.Header.ContentType()
+	if mediaType != "multipart/mixed" {
+		t.Errorf("Content-Type = %q, want multipart/mixed", mediaType)
+	}
+	if !bytes.Contains(result.Message, []byte("Content-Type: message/rfc822")) {
+		t.Error("original message should be attached as message/rfc822")
+	}
+
+	// The boundary is random, so the enclosed message cannot plant it.
+	again, err := message.Read(bytes.NewReader(evaluateMIME(t, script).Message))
+	if err != nil {
+		t.Fatalf("enclosed message does not parse: %v", err)
+	}
+	_, params, _ := entity.Header.ContentType()
+	_, againParams, _ := again.Header.ContentType()
+	if params["boundary"] == againParams["boundary"] {
+		t.Errorf("enclose used the same boundary %q twice", params["boundary"])
+	}
+}
+
+func TestScriptWithoutMIMEUnchanged(t *testing.T) {
+	result := evaluateMIME(t, `require ["fileinto"]; if header :contains "Subject" "report" { fileinto "Reports"; }`)
+	if result.Action != ActionFileInto || result.Message != nil {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
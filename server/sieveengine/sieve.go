@@ -9,10 +9,11 @@ import (
 	"time"
 
 	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/textproto"
+	msieve "github.com/migadu/go-managesieve/managesieve"
 	"github.com/migadu/go-sieve"
 	"github.com/migadu/go-sieve/interp"
 	"github.com/migadu/sora/helpers"
-	"github.com/migadu/sora/server/managesieve"
 )
 
 type Action string
@@ -56,9 +57,14 @@ func SetScriptExecutionTimeout(d time.Duration) {
 }
 
 // DefaultSieveExtensions is the safe subset of SIEVE extensions enabled by default.
-// Excludes security-sensitive extensions like editheader.
-// The canonical list is maintained in server/managesieve/capabilities.go
-var DefaultSieveExtensions = managesieve.DefaultEnabledExtensions
+// Excludes security-sensitive extensions like editheader (and the message-rewriting
+// replace/enclose). It is the go-managesieve default list plus DefaultMIMEExtensions;
+// server/managesieve re-exports it for capability advertisement.
+var DefaultSieveExtensions = append(append([]string{}, msieve.DefaultEnabledExtensions...), DefaultMIMEExtensions...)
+
+// SupportedSieveExtensions lists every SIEVE extension Sora can execute: the
+// go-managesieve vocabulary plus MIMEExtensions.
+var SupportedSieveExtensions = append(append([]string{}, msieve.SupportedExtensions...), MIMEExtensions...)
 
 // HeaderEdit represents a header modification from editheader extension
 type HeaderEdit struct {
@@ -81,6 +87,7 @@ type Result struct {
 	Copy           bool              // RFC3894 - :copy modifier for redirect and fileinto
 	CreateMailbox  bool              // RFC5490 - :create modifier (mailbox extension)
 	HeaderEdits    []HeaderEdit      // RFC5293 - editheader extension (addheader/deleteheader)
+	Message        []byte            // RFC5703 - replace/enclose: rewritten message, nil if unchanged
 	Additional     map[string]string // future-proofing
 }
 
@@ -89,6 +96,7 @@ type Context struct {
 	EnvelopeTo   string
 	Header       map[string][]string
 	Body         string
+	Raw          []byte // full message; needed by the RFC 5703 MIME extensions
 }
 
 // VacationOracle defines the methods SievePolicy needs to interact with
@@ -117,6 +125,9 @@ type Executor interface {
 // SieveExecutor implements the Executor interface using the go-sieve library
 type SieveExecutor struct {
 	script *sieve.Script
+	// mime is set instead of script when the script requires RFC 5703
+	// extensions; it is rewritten and loaded per message.
+	mime *mimeProgram
 	// policy is now initialized with AccountID and vacationOracle
 	policy *SievePolicy
 }
@@ -134,14 +145,13 @@ func NewSieveExecutor(scriptContent string) (Executor, error) {
 // If enabledExtensions is nil, all extensions are allowed
 func NewSieveExecutorWithExtensions(scriptContent string, enabledExtensions []string) (Executor, error) {
 	// Load the script
-	scriptReader := strings.NewReader(scriptContent)
 	options := sieve.DefaultOptions()
 	options.EnabledExtensions = enabledExtensions
 	// Raise the per-match regex soft-wait cap to the whole-script budget. The match
 	// input is already truncated to MaxInputLength, so a large body match is bounded;
 	// go-sieve's 100ms default can otherwise spuriously fail it under load or -race.
 	options.Interp.RegexLimits.MaxExecTime = scriptExecutionTimeout
-	script, err := sieve.Load(scriptReader, options)
+	script, mimeProg, err := loadScript(scriptContent, options)
 	if err != nil {
 		return nil, err
 	}
@@ -150,6 +160,7 @@ func NewSieveExecutorWithExtensions(scriptContent string, enabledExtensions []st
 
 	return &SieveExecutor{
 		script: script,
+		mime:   mimeProg,
 		policy: policy,
 	}, nil
 }
@@ -161,14 +172,13 @@ func NewSieveExecutorWithOracle(scriptContent string, AccountID int64, vacOracle
 
 // NewSieveExecutorWithOracleAndExtensions creates a new SieveExecutor with the given script content, AccountID, oracles, and enabled extensions.
 func NewSieveExecutorWithOracleAndExtensions(scriptContent string, AccountID int64, vacOracle VacationOracle, redirectOracle RedirectOracle, redirectRateLimit int, redirectRateWindow time.Duration, maxRedirectHops int, enabledExtensions []string) (Executor, error) {
-	scriptReader := strings.NewReader(scriptContent)
 	options := sieve.DefaultOptions()
 	options.EnabledExtensions = enabledExtensions
 	// Raise the per-match regex soft-wait cap to the whole-script budget. The match
 	// input is already truncated to MaxInputLength, so a large body match is bounded;
 	// go-sieve's 100ms default can otherwise spuriously fail it under load or -race.
 	options.Interp.RegexLimits.MaxExecTime = scriptExecutionTimeout
-	script, err := sieve.Load(scriptReader, options)
+	script, mimeProg, err := loadScript(scriptContent, options)
 	if err != nil {
 		return nil, err
 	}
@@ -184,12 +194,29 @@ func NewSieveExecutorWithOracleAndExtensions(scriptContent string, AccountID int
 
 	return &SieveExecutor{
 		script: script,
+		mime:   mimeProg,
 		policy: policy,
 	}, nil
 }
 
+// ValidateScript checks that scriptContent loads with the given enabled
+// extensions, including the RFC 5703 MIME extensions go-sieve does not know.
+func ValidateScript(scriptContent string, enabledExtensions []string) error {
+	options := sieve.DefaultOptions()
+	options.EnabledExtensions = enabledExtensions
+	_, _, err := loadScript(scriptContent, options)
+	return err
+}
+
 // Evaluate evaluates the Sieve script with the given context
 func (e *SieveExecutor) Evaluate(evalCtx context.Context, ctx Context) (Result, error) {
+	result, _, err := e.evaluate(evalCtx, ctx)
+	return result, err
+}
+
+// evaluate runs the script and also returns the runtime data, which Trace
+// inspects for the markers left by the instrumented script.
+func (e *SieveExecutor) evaluate(evalCtx context.Context, ctx Context) (Result, *interp.RuntimeData, error) {
 	// Create envelope and message implementations
 	envelope := &SieveEnvelope{
 		From: ctx.EnvelopeFrom,
@@ -231,19 +258,38 @@ func (e *SieveExecutor) Evaluate(evalCtx context.Context, ctx Context) (Result,
 	timeoutCtx, cancel := context.WithTimeout(evalCtx, timeout)
 	defer cancel()
 
+	// Scripts using the RFC 5703 MIME extensions are rewritten against this
+	// message's MIME tree and loaded per evaluation.
+	script := e.script
+	var mimeRW *mimeRewriter
+	if e.mime != nil {
+		root, err := mimeTree(ctx)
+		if err != nil {
+			return Result{Action: ActionKeep}, nil, err
+		}
+		if script, mimeRW, err = e.mime.load(root); err != nil {
+			return Result{Action: ActionKeep}, nil, err
+		}
+	}
+
 	// Create runtime data
-	data := sieve.NewRuntimeData(e.script, execPolicy, envelope, message) // RuntimeData holds policy
+	data := sieve.NewRuntimeData(script, execPolicy, envelope, message) // RuntimeData holds policy
+	if mimeRW != nil {
+		for name, value := range mimeRW.vars {
+			data.Variables[name] = value
+		}
+	}
 
 	// Execute the script
 	if err := timeoutCtx.Err(); err != nil {
-		return Result{Action: ActionKeep}, err
+		return Result{Action: ActionKeep}, nil, err
 	}
-	err := e.script.Execute(timeoutCtx, data) // Pass the evaluation context
+	err := script.Execute(timeoutCtx, data) // Pass the evaluation context
 	if err != nil {
-		return Result{Action: ActionKeep}, err
+		return Result{Action: ActionKeep}, nil, err
 	}
 	if err := timeoutCtx.Err(); err != nil {
-		return Result{Action: ActionKeep}, err
+		return Result{Action: ActionKeep}, nil, err
 	}
 
 	// Process the results
@@ -340,7 +386,25 @@ func (e *SieveExecutor) Evaluate(evalCtx context.Context, ctx Context) (Result,
 		}
 	}
 
-	return result, nil
+	// Apply replace/enclose (RFC 5703) actions that executed
+	if mimeRW != nil {
+		rewritten, err := mimeRW.apply(data.Variables)
+		if err != nil {
+			return Result{Action: ActionKeep}, nil, err
+		}
+		result.Message = rewritten
+	}
+
+	return result, data, nil
+}
+
+// mimeTree builds the MIME tree for the RFC 5703 extensions from ctx.Raw, or
+// from the headers and body when the caller did not supply the raw message.
+func mimeTree(ctx Context) (*mimePart, error) {
+	if len(ctx.Raw) > 0 {
+		return parseMIMETree(ctx.Raw)
+	}
+	return &mimePart{Header: textproto.HeaderFromMap(ctx.Header), Body: []byte(ctx.Body)}, nil
 }
 
 // SievePolicy implements the PolicyReader interface
@@ -0,0 +1,244 @@
+package sieveengine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-message"
+	"github.com/migadu/go-sieve"
+	"github.com/migadu/go-sieve/lexer"
+	"github.com/migadu/go-sieve/parser"
+	"github.com/migadu/sora/helpers"
+)
+
+// traceVarPrefix names the marker variables the instrumented script sets.
+const traceVarPrefix = "sora_trace_"
+
+// TraceStep is one test or action of a traced script, in script order.
+type TraceStep struct {
+	Line    int    `json:"line"`
+	Kind    string `json:"kind"` // "test" or "action"
+	Command string `json:"command"`
+	// Results holds the outcome of every evaluation of a test (a test inside
+	// foreverypart may be evaluated once per body part). Empty when the test
+	// was never reached.
+	Results []bool `json:"results,omitempty"`
+	// Executed counts how many times an action ran.
+	Executed int `json:"executed,omitempty"`
+}
+
+// traceChain is an instrumented if/elsif/else chain. steps holds the index
+// into the trace of each branch's test, or -1 for else.
+type traceChain struct {
+	variable string
+	steps    []int
+}
+
+type tracer struct {
+	steps   []TraceStep
+	actions map[int]string // step index -> marker variable
+	chains  []traceChain
+	seq     int
+}
+
+// Trace evaluates scriptContent against a message like Evaluate, and also
+// reports every test and action with its outcome. The script runs without
+// vacation or redirect oracles, so it has no side effects: nothing is
+// recorded, sent or stored. It is meant for dry runs of user scripts.
+func Trace(evalCtx context.Context, scriptContent string, ctx Context, enabledExtensions []string) (Result, []TraceStep, error) {
+	options := sieve.DefaultOptions()
+	enabledExtensions = allowedExtensions(enabledExtensions)
+	options.EnabledExtensions = enabledExtensions
+	options.Interp.RegexLimits.MaxExecTime = scriptExecutionTimeout
+
+	// Validate the script as written before instrumenting it.
+	if _, _, err := loadScript(scriptContent, options); err != nil {
+		return Result{Action: ActionKeep}, nil, err
+	}
+	toks, err := lexer.Lex(strings.NewReader(scriptContent), &options.Lexer)
+	if err != nil {
+		return Result{Action: ActionKeep}, nil, err
+	}
+	cmds, err := parser.Parse(lexer.NewStream(toks), &options.Parser)
+	if err != nil {
+		return Result{Action: ActionKeep}, nil, err
+	}
+
+	tr := &tracer{actions: make(map[int]string)}
+	// The instrumented script always goes through the rewriting path. The
+	// markers need "variables" even where users may not use it; the script
+	// was validated above against the enabled extensions, so it cannot.
+	extensions := rewrittenExtensions(enabledExtensions)
+	if !containsString(extensions, "variables") {
+		extensions = append(extensions, "variables")
+	}
+	executor := &SieveExecutor{
+		mime: &mimeProgram{
+			cmds:       tr.instrument(cmds),
+			opts:       options,
+			extensions: extensions,
+		},
+		policy: &SievePolicy{},
+	}
+	result, data, err := executor.evaluate(evalCtx, ctx)
+	if err != nil {
+		return result, tr.steps, err
+	}
+	tr.collect(data.Variables)
+	return result, tr.steps, nil
+}
+
+func (t *tracer) newVar() string {
+	t.seq++
+	return traceVarPrefix + strconv.Itoa(t.seq)
+}
+
+// instrument inserts marker commands into a block. Each action is preceded
+// by a command appending "x" to its marker. Each if chain appends "," to its
+// marker when reached, and the index of the branch taken at the start of
+// that branch's block, so tests are never evaluated twice.
+func (t *tracer) instrument(cmds []parser.Cmd) []parser.Cmd {
+	out := make([]parser.Cmd, 0, 2*len(cmds))
+	for i := 0; i < len(cmds); i++ {
+		cmd := cmds[i]
+		switch strings.ToLower(cmd.Id) {
+		case "require":
+			out = append(out, cmd)
+		case "foreverypart":
+			cmd.Block = t.instrument(cmd.Block)
+			out = append(out, cmd)
+		case "if":
+			chain := []parser.Cmd{cmd}
+			for i+1 < len(cmds) && (strings.EqualFold(cmds[i+1].Id, "elsif") || strings.EqualFold(cmds[i+1].Id, "else")) {
+				i++
+				chain = append(chain, cmds[i])
+			}
+			v := t.newVar()
+			c := traceChain{variable: v}
+			out = append(out, setCmd(cmd.Position, v, "${"+v+"},"))
+			for k, branch := range chain {
+				step := -1
+				if len(branch.Tests) > 0 {
+					step = len(t.steps)
+					t.steps = append(t.steps, TraceStep{
+						Line:    branch.Tests[0].Line,
+						Kind:    "test",
+						Command: formatTest(branch.Tests[0]),
+					})
+				}
+				c.steps = append(c.steps, step)
+				marker := setCmd(branch.Position, v, "${"+v+"}"+strconv.Itoa(k))
+				branch.Block = append([]parser.Cmd{marker}, t.instrument(branch.Block)...)
+				out = append(out, branch)
+			}
+			t.chains = append(t.chains, c)
+		default:
+			v := t.newVar()
+			t.actions[len(t.steps)] = v
+			t.steps = append(t.steps, TraceStep{Line: cmd.Line, Kind: "action", Command: formatCmd(cmd)})
+			out = append(out, setCmd(cmd.Position, v, "${"+v+"}x"), cmd)
+		}
+	}
+	return out
+}
+
+// collect fills in the steps from the marker variables left by a run.
+func (t *tracer) collect(vars map[string]string) {
+	for i, v := range t.actions {
+		t.steps[i].Executed = len(vars[v])
+	}
+	for _, c := range t.chains {
+		reached := strings.Split(vars[c.variable], ",")
+		for _, taken := range reached[1:] {
+			branch := len(c.steps) // no branch taken: every test was false
+			if taken != "" {
+				branch, _ = strconv.Atoi(taken)
+			}
+			for k, step := range c.steps {
+				if k > branch || step < 0 {
+					break
+				}
+				t.steps[step].Results = append(t.steps[step].Results, k == branch)
+			}
+		}
+	}
+}
+
+// formatCmd renders an action the way it would be written in a script.
+func formatCmd(cmd parser.Cmd) string {
+	return strings.TrimSpace(cmd.Id + " " + formatArgs(cmd.Args))
+}
+
+// formatTest renders a test, including nested allof/anyof/not tests.
+func formatTest(test parser.Test) string {
+	var b strings.Builder
+	b.WriteString(test.Id)
+	if args := formatArgs(test.Args); args != "" {
+		b.WriteString(" " + args)
+	}
+	switch {
+	case strings.EqualFold(test.Id, "not") && len(test.Tests) == 1:
+		b.WriteString(" " + formatTest(test.Tests[0]))
+	case len(test.Tests) > 0:
+		sub := make([]string, len(test.Tests))
+		for i, t := range test.Tests {
+			sub[i] = formatTest(t)
+		}
+		b.WriteString("(" + strings.Join(sub, ", ") + ")")
+	}
+	return b.String()
+}
+
+func formatArgs(args []parser.Arg) string {
+	parts := make([]string, 0, len(args))
+	for _, a := range args {
+		switch a := a.(type) {
+		case parser.TagArg:
+			parts = append(parts, ":"+a.Value)
+		case parser.NumberArg:
+			parts = append(parts, strconv.Itoa(a.Value))
+		case parser.StringArg:
+			parts = append(parts, quoteSieve(a.Value))
+		case parser.StringListArg:
+			quoted := make([]string, len(a.Value))
+			for i, s := range a.Value {
+				quoted[i] = quoteSieve(s)
+			}
+			parts = append(parts, "["+strings.Join(quoted, ", ")+"]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteSieve(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// ContextFromMessage builds an evaluation context from a raw RFC 5322
+// message, for running scripts against messages that are already stored. An
+// empty envelopeFrom defaults to the address in the From header.
+func ContextFromMessage(raw []byte, envelopeFrom, envelopeTo string) (Context, error) {
+	entity, err := message.Read(bytes.NewReader(raw))
+	if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		return Context{}, fmt.Errorf("failed to parse message: %w", err)
+	}
+	ctx := Context{
+		EnvelopeFrom: envelopeFrom,
+		EnvelopeTo:   envelopeTo,
+		Header:       entity.Header.Map(),
+		Raw:          raw,
+	}
+	if ctx.EnvelopeFrom == "" {
+		if from, err := mail.ParseAddress(entity.Header.Get("From")); err == nil {
+			ctx.EnvelopeFrom = from.Address
+		}
+	}
+	if text, _ := helpers.ExtractPlaintextBody(entity); text != nil {
+		ctx.Body = *text
+	}
+	return ctx, nil
+}
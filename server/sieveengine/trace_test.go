@@ -0,0 +1,93 @@
+package sieveengine
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-message"
+)
+
+func traceMessage(t *testing.T, script string) (Result, []TraceStep) {
+	t.Helper()
+	entity, err := message.Read(strings.NewReader(mimeTestMessage))
+	if err != nil {
+		t.Fatalf("Failed to parse test message: %v", err)
+	}
+	result, steps, err := Trace(context.Background(), script, Context{
+		EnvelopeFrom: "alice@example.com",
+		EnvelopeTo:   "bob@example.com",
+		Header:       entity.Header.Map(),
+		Raw:          []byte(mimeTestMessage),
+	}, mimeTestExtensions)
+	if err != nil {
+		t.Fatalf("Trace failed: %v", err)
+	}
+	return result, steps
+}
+
+func TestTraceIfChain(t *testing.T) {
+	script := `require ["fileinto", "imap4flags"];
+if header :contains "Subject" "invoice" {
+	fileinto "Invoices";
+} elsif allof(header :contains "Subject" "report", address :domain "From" "example.com") {
+	addflag "\\Flagged";
+	fileinto "Reports";
+} elsif header :is "X-Never" "x" {
+	discard;
+} else {
+	keep;
+}`
+	result, steps := traceMessage(t, script)
+	if result.Action != ActionFileInto || result.Mailbox != "Reports" {
+		t.Fatalf("unexpected result %s %q", result.Action, result.Mailbox)
+	}
+
+	want := []TraceStep{
+		{Line: 2, Kind: "test", Command: `header :contains "Subject" "invoice"`, Results: []bool{false}},
+		{Line: 3, Kind: "action", Command: `fileinto "Invoices"`},
+		{Line: 4, Kind: "test", Command: `allof(header :contains "Subject" "report", address :domain "From" "example.com")`, Results: []bool{true}},
+		{Line: 5, Kind: "action", Command: `addflag "\\Flagged"`, Executed: 1},
+		{Line: 6, Kind: "action", Command: `fileinto "Reports"`, Executed: 1},
+		{Line: 7, Kind: "test", Command: `header :is "X-Never" "x"`},
+		{Line: 8, Kind: "action", Command: `discard`},
+		{Line: 10, Kind: "action", Command: `keep`},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("steps mismatch:\ngot  %+v\nwant %+v", steps, want)
+	}
+}
+
+func TestTraceForEveryPart(t *testing.T) {
+	script := `require ["fileinto", "mime", "foreverypart"];
+foreverypart {
+	if header :mime :subtype "Content-Type" "pdf" {
+		fileinto "Attachments";
+	}
+}`
+	_, steps := traceMessage(t, script)
+	if len(steps) != 2 {
+		t.Fatalf("expected 2 steps, got %+v", steps)
+	}
+	if !reflect.DeepEqual(steps[0].Results, []bool{false, true}) {
+		t.Errorf("test results = %v, want one per body part", steps[0].Results)
+	}
+	if steps[1].Executed != 1 {
+		t.Errorf("fileinto executed %d times, want 1", steps[1].Executed)
+	}
+}
+
+func TestTraceStop(t *testing.T) {
+	_, steps := traceMessage(t, `keep; stop; discard;`)
+	got := []int{steps[0].Executed, steps[1].Executed, steps[2].Executed}
+	if !reflect.DeepEqual(got, []int{1, 1, 0}) {
+		t.Errorf("executed = %v", got)
+	}
+}
+
+func TestTraceInvalidScript(t *testing.T) {
+	if _, _, err := Trace(context.Background(), `require ["enclose"]; enclose "x";`, Context{}, []string{"fileinto"}); err == nil {
+		t.Fatal("expected a script requiring a disabled extension to be rejected")
+	}
+}
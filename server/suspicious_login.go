@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/metrics"
+)
+
+// Kinds of login source
+const (
+	LoginSourceNetwork     = "network"
+	LoginSourceCountry     = "country"
+	LoginSourceFingerprint = "fingerprint"
+)
+
+// maxLoginSourcesPerAccount bounds the known sources kept per account; the
+// least recently seen are pruned first.
+const maxLoginSourcesPerAccount = 200
+
+// ErrLoginConfirmationRequired is returned for logins from a source the user
+// has not confirmed yet when step-up is enabled.
+var ErrLoginConfirmationRequired = errors.New("login from a new location must be confirmed in the account settings")
+
+// LoginSource is a network, country or TLS client fingerprint an account has
+// logged in from.
+type LoginSource struct {
+	ID            int64     `json:"id"`
+	Kind          string    `json:"kind"`  // "network", "country" or "fingerprint"
+	Value         string    `json:"value"` // CIDR, ISO country code or JA4 fingerprint
+	Confirmed     bool      `json:"confirmed"`
+	FirstIP       string    `json:"first_ip,omitempty"`
+	FirstProtocol string    `json:"first_protocol,omitempty"`
+	FirstSeen     time.Time `json:"first_seen"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// LoginSourceStore persists the sources of an account (implemented by
+// resilient.ResilientDatabase).
+type LoginSourceStore interface {
+	// ObserveLoginSourcesWithRetry records sources seen in a successful login
+	// and returns those that were new to the account and those not yet
+	// confirmed. New sources are stored as confirmed when confirmNew is true
+	// or when the account has no sources of that kind yet.
+	ObserveLoginSourcesWithRetry(ctx context.Context, accountID int64, ip, protocol string, sources []LoginSource, confirmNew bool, maxPerAccount int) (newSources, pending []LoginSource, err error)
+}
+
+// CountryLookup resolves the ISO country code of an IP address (implemented
+// by geoip.Reader).
+type CountryLookup interface {
+	Country(ip net.IP) (string, error)
+}
+
+// NotificationQueue queues an email for delivery (implemented by the relay
+// queue).
+type NotificationQueue interface {
+	Enqueue(from, to, messageType string, messageBytes []byte) error
+}
+
+// SuspiciousLoginOptions configures a SuspiciousLoginDetector.
+type SuspiciousLoginOptions struct {
+	Store            LoginSourceStore
+	Countries        CountryLookup     // Optional; countries are not checked without it
+	Queue            NotificationQueue // Optional; required for notification emails
+	QueueNotify      func()            // Called after a notification was queued (optional)
+	Hostname         string
+	CheckNetwork     bool
+	CheckFingerprint bool
+	IPv4Prefix       int
+	IPv6Prefix       int
+	IgnoreNetworks   []string
+	Notify           bool
+	NotifyFrom       string // Default: postmaster@<account domain>
+	StepUp           bool
+}
+
+// SuspiciousLoginDetector flags successful logins from networks, countries or
+// TLS client fingerprints never seen before for the account. A nil
+// *SuspiciousLoginDetector accepts every login.
+type SuspiciousLoginDetector struct {
+	opts   SuspiciousLoginOptions
+	ignore []*net.IPNet
+}
+
+// NewSuspiciousLoginDetector creates a detector.
+func NewSuspiciousLoginDetector(opts SuspiciousLoginOptions) (*SuspiciousLoginDetector, error) {
+	ignore, err := helpers.ParseTrustedNetworks(opts.IgnoreNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ignore_networks: %w", err)
+	}
+	if opts.IPv4Prefix <= 0 || opts.IPv4Prefix > 32 {
+		opts.IPv4Prefix = 24
+	}
+	if opts.IPv6Prefix <= 0 || opts.IPv6Prefix > 128 {
+		opts.IPv6Prefix = 48
+	}
+	return &SuspiciousLoginDetector{opts: opts, ignore: ignore}, nil
+}
+
+// StepUpEnabled reports whether logins from unconfirmed sources are rejected.
+func (d *SuspiciousLoginDetector) StepUpEnabled() bool {
+	return d != nil && d.opts.StepUp
+}
+
+// CheckLogin evaluates a successful protocol login. It returns
+// ErrLoginConfirmationRequired when step-up is enabled and the login uses a
+// source the user has not confirmed; the login must then be rejected. Errors
+// of the store are logged and the login is allowed.
+func (d *SuspiciousLoginDetector) CheckLogin(ctx context.Context, login LoginRecord) error {
+	return d.check(ctx, login, true)
+}
+
+// ObserveLogin evaluates a successful login that is never rejected, such as a
+// User API login, which is where users confirm new sources.
+func (d *SuspiciousLoginDetector) ObserveLogin(ctx context.Context, login LoginRecord) {
+	_ = d.check(ctx, login, false)
+}
+
+func (d *SuspiciousLoginDetector) check(ctx context.Context, login LoginRecord, enforce bool) error {
+	if d == nil || login.AccountID <= 0 {
+		return nil
+	}
+	ip := net.ParseIP(login.IP)
+	if ip == nil {
+		return nil
+	}
+	for _, n := range d.ignore {
+		if n.Contains(ip) {
+			return nil
+		}
+	}
+	sources := d.sources(ip, login.JA4)
+	if len(sources) == 0 {
+		return nil
+	}
+
+	newSources, pending, err := d.opts.Store.ObserveLoginSourcesWithRetry(ctx, login.AccountID, login.IP, login.Protocol, sources, !d.opts.StepUp, maxLoginSourcesPerAccount)
+	if err != nil {
+		logger.Warn("Suspicious login: Failed to check login sources", "account_id", login.AccountID, "error", err)
+		return nil
+	}
+
+	if len(newSources) > 0 {
+		kinds := make([]string, 0, len(newSources))
+		for _, s := range newSources {
+			metrics.SuspiciousLogins.WithLabelValues(login.Protocol, s.Kind).Inc()
+			kinds = append(kinds, s.Kind+"="+s.Value)
+		}
+		logger.Info("Suspicious login: Login from new source", "account_id", login.AccountID, "username", login.Username,
+			"protocol", login.Protocol, "ip", login.IP, "new", kinds)
+		d.notify(login, newSources)
+	}
+
+	if enforce && d.opts.StepUp && len(pending) > 0 {
+		metrics.SuspiciousLoginsBlocked.WithLabelValues(login.Protocol).Inc()
+		return ErrLoginConfirmationRequired
+	}
+	return nil
+}
+
+// sources returns the sources of a login that are checked.
+func (d *SuspiciousLoginDetector) sources(ip net.IP, ja4 string) []LoginSource {
+	var sources []LoginSource
+	if d.opts.CheckNetwork {
+		sources = append(sources, LoginSource{Kind: LoginSourceNetwork, Value: d.network(ip)})
+	}
+	if d.opts.Countries != nil {
+		if country, err := d.opts.Countries.Country(ip); err != nil {
+			logger.Debug("Suspicious login: Country lookup failed", "ip", ip.String(), "error", err)
+		} else if country != "" {
+			sources = append(sources, LoginSource{Kind: LoginSourceCountry, Value: country})
+		}
+	}
+	if d.opts.CheckFingerprint && ja4 != "" {
+		sources = append(sources, LoginSource{Kind: LoginSourceFingerprint, Value: ja4})
+	}
+	return sources
+}
+
+// network returns the network ip belongs to, e.g. "192.0.2.0/24".
+func (d *SuspiciousLoginDetector) network(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		n := net.IPNet{IP: v4.Mask(net.CIDRMask(d.opts.IPv4Prefix, 32)), Mask: net.CIDRMask(d.opts.IPv4Prefix, 32)}
+		return n.String()
+	}
+	n := net.IPNet{IP: ip.Mask(net.CIDRMask(d.opts.IPv6Prefix, 128)), Mask: net.CIDRMask(d.opts.IPv6Prefix, 128)}
+	return n.String()
+}
+
+// notify queues a notification email to the account.
+func (d *SuspiciousLoginDetector) notify(login LoginRecord, newSources []LoginSource) {
+	if !d.opts.Notify || d.opts.Queue == nil {
+		return
+	}
+	to := login.Username
+	_, domain, ok := strings.Cut(to, "@")
+	if !ok {
+		return
+	}
+	from := d.opts.NotifyFrom
+	if from == "" {
+		from = "postmaster@" + domain
+	}
+
+	msg := buildSuspiciousLoginMessage(from, to, d.opts.Hostname, login, newSources, d.opts.StepUp)
+	if err := d.opts.Queue.Enqueue(from, to, "notification", msg); err != nil {
+		logger.Warn("Suspicious login: Failed to queue notification", "account_id", login.AccountID, "to", to, "error", err)
+		return
+	}
+	if d.opts.QueueNotify != nil {
+		d.opts.QueueNotify()
+	}
+}
+
+// buildSuspiciousLoginMessage returns the notification email for a login from
+// new sources.
+func buildSuspiciousLoginMessage(from, to, hostname string, login LoginRecord, newSources []LoginSource, stepUp bool) []byte {
+	t := login.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	var details strings.Builder
+	fmt.Fprintf(&details, "Time:       %s\r\n", t.UTC().Format("2006-01-02 15:04:05 MST"))
+	fmt.Fprintf(&details, "Protocol:   %s\r\n", strings.ToUpper(login.Protocol))
+	fmt.Fprintf(&details, "IP address: %s\r\n", login.IP)
+	for _, s := range newSources {
+		switch s.Kind {
+		case LoginSourceNetwork:
+			fmt.Fprintf(&details, "New network:     %s\r\n", s.Value)
+		case LoginSourceCountry:
+			fmt.Fprintf(&details, "New country:     %s\r\n", s.Value)
+		case LoginSourceFingerprint:
+			fmt.Fprintf(&details, "New mail client: %s\r\n", s.Value)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "New sign-in to your account"))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%d.login-alert@%s>\r\n", time.Now().UnixNano(), hostname)
+	b.WriteString("Auto-Submitted: auto-generated\r\n")
+	b.WriteString("X-Auto-Response-Suppress: All\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "Your account %s was signed in to from a network, country or mail client\r\n", to)
+	b.WriteString("it has not been used from before.\r\n\r\n")
+	b.WriteString(details.String())
+	b.WriteString("\r\n")
+	if stepUp {
+		b.WriteString("Mail clients cannot sign in from this location until you confirm it in\r\n")
+		b.WriteString("your account settings.\r\n\r\n")
+	} else {
+		b.WriteString("If this was you, no action is needed.\r\n\r\n")
+	}
+	b.WriteString("If it was not you, change your password immediately.\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// fakeLoginSourceStore mirrors db.ObserveLoginSources in memory.
+type fakeLoginSourceStore struct {
+	sources map[int64][]LoginSource
+	err     error
+}
+
+func (f *fakeLoginSourceStore) ObserveLoginSourcesWithRetry(_ context.Context, accountID int64, ip, protocol string, sources []LoginSource, confirmNew bool, _ int) (newSources, pending []LoginSource, err error) {
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+	if f.sources == nil {
+		f.sources = make(map[int64][]LoginSource)
+	}
+	known := f.sources[accountID]
+	baseline := map[string]bool{}
+	for _, s := range sources {
+		baseline[s.Kind] = true
+	}
+	for _, k := range known {
+		baseline[k.Kind] = false
+	}
+	for _, s := range sources {
+		idx := -1
+		for i, k := range known {
+			if k.Kind == s.Kind && k.Value == s.Value {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			s.ID = int64(len(known) + 1)
+			s.Confirmed = confirmNew || baseline[s.Kind]
+			s.FirstIP, s.FirstProtocol = ip, protocol
+			known = append(known, s)
+			idx = len(known) - 1
+			if !baseline[s.Kind] {
+				newSources = append(newSources, s)
+			}
+		}
+		if !known[idx].Confirmed {
+			pending = append(pending, known[idx])
+		}
+	}
+	f.sources[accountID] = known
+	return newSources, pending, nil
+}
+
+func (f *fakeLoginSourceStore) confirmAll(accountID int64) {
+	for i := range f.sources[accountID] {
+		f.sources[accountID][i].Confirmed = true
+	}
+}
+
+type fakeCountries map[string]string
+
+func (f fakeCountries) Country(ip net.IP) (string, error) {
+	return f[ip.String()], nil
+}
+
+type queuedNotification struct {
+	from, to, messageType string
+	msg                   []byte
+}
+
+type fakeNotificationQueue struct {
+	queued []queuedNotification
+}
+
+func (f *fakeNotificationQueue) Enqueue(from, to, messageType string, msg []byte) error {
+	f.queued = append(f.queued, queuedNotification{from, to, messageType, msg})
+	return nil
+}
+
+func newTestDetector(t *testing.T, opts SuspiciousLoginOptions) *SuspiciousLoginDetector {
+	t.Helper()
+	d, err := NewSuspiciousLoginDetector(opts)
+	if err != nil {
+		t.Fatalf("NewSuspiciousLoginDetector: %v", err)
+	}
+	return d
+}
+
+func testLogin(ip, ja4 string) LoginRecord {
+	return LoginRecord{AccountID: 1, Username: "user@example.com", Protocol: "imap", IP: ip, JA4: ja4}
+}
+
+func TestSuspiciousLoginNotifications(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeLoginSourceStore{}
+	queue := &fakeNotificationQueue{}
+	notified := 0
+	d := newTestDetector(t, SuspiciousLoginOptions{
+		Store:            store,
+		Countries:        fakeCountries{"192.0.2.1": "DE", "192.0.2.200": "DE", "198.51.100.1": "SE"},
+		Queue:            queue,
+		QueueNotify:      func() { notified++ },
+		Hostname:         "mx.example.com",
+		CheckNetwork:     true,
+		CheckFingerprint: true,
+		Notify:           true,
+	})
+
+	steps := []struct {
+		ip, ja4    string
+		wantQueued int
+	}{
+		{"192.0.2.1", "t13d1516h2_aaa", 0},    // First login: baseline
+		{"192.0.2.200", "t13d1516h2_aaa", 0},  // Same /24, country and client
+		{"198.51.100.1", "t13d1516h2_aaa", 1}, // New network and country
+		{"198.51.100.1", "t13d1516h2_bbb", 2}, // New client
+		{"198.51.100.1", "t13d1516h2_bbb", 2},
+	}
+	for i, step := range steps {
+		if err := d.CheckLogin(ctx, testLogin(step.ip, step.ja4)); err != nil {
+			t.Fatalf("step %d: CheckLogin: %v", i, err)
+		}
+		if len(queue.queued) != step.wantQueued {
+			t.Fatalf("step %d: %d notifications queued, want %d", i, len(queue.queued), step.wantQueued)
+		}
+	}
+	if notified != 2 {
+		t.Errorf("QueueNotify called %d times, want 2", notified)
+	}
+
+	n := queue.queued[0]
+	if n.from != "postmaster@example.com" || n.to != "user@example.com" || n.messageType != "notification" {
+		t.Errorf("unexpected notification envelope: %+v", n)
+	}
+	for _, want := range []string{"198.51.100.0/24", "New country:     SE", "Auto-Submitted: auto-generated"} {
+		if !bytes.Contains(n.msg, []byte(want)) {
+			t.Errorf("notification does not contain %q:\n%s", want, n.msg)
+		}
+	}
+}
+
+func TestSuspiciousLoginStepUp(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeLoginSourceStore{}
+	d := newTestDetector(t, SuspiciousLoginOptions{Store: store, CheckNetwork: true, StepUp: true})
+
+	if err := d.CheckLogin(ctx, testLogin("192.0.2.1", "")); err != nil {
+		t.Fatalf("baseline login rejected: %v", err)
+	}
+	if err := d.CheckLogin(ctx, testLogin("2001:db8:1::1", "")); !errors.Is(err, ErrLoginConfirmationRequired) {
+		t.Fatalf("login from new network: err = %v, want ErrLoginConfirmationRequired", err)
+	}
+	if err := d.CheckLogin(ctx, testLogin("2001:db8:1:ffff::1", "")); !errors.Is(err, ErrLoginConfirmationRequired) {
+		t.Fatalf("login from same pending /48: err = %v, want ErrLoginConfirmationRequired", err)
+	}
+
+	// The User API never blocks and leaves the source pending
+	d.ObserveLogin(ctx, testLogin("2001:db8:1::1", ""))
+	if got := store.sources[1][1]; got.Value != "2001:db8:1::/48" || got.Confirmed {
+		t.Fatalf("unexpected pending source: %+v", got)
+	}
+
+	store.confirmAll(1)
+	if err := d.CheckLogin(ctx, testLogin("2001:db8:1::1", "")); err != nil {
+		t.Fatalf("login from confirmed network rejected: %v", err)
+	}
+}
+
+func TestSuspiciousLoginSkips(t *testing.T) {
+	ctx := context.Background()
+	store := &fakeLoginSourceStore{}
+	d := newTestDetector(t, SuspiciousLoginOptions{Store: store, CheckNetwork: true, StepUp: true, IgnoreNetworks: []string{"10.0.0.0/8"}})
+
+	d.CheckLogin(ctx, testLogin("192.0.2.1", ""))
+	for _, rec := range []LoginRecord{
+		testLogin("10.1.2.3", ""),          // Ignored network
+		testLogin("not-an-ip", ""),         // Unparseable address
+		{AccountID: 0, IP: "198.51.100.1"}, // Unknown account
+	} {
+		if err := d.CheckLogin(ctx, rec); err != nil {
+			t.Errorf("CheckLogin(%+v) = %v, want nil", rec, err)
+		}
+	}
+	if got := len(store.sources[1]); got != 1 {
+		t.Errorf("%d sources stored, want 1", got)
+	}
+
+	// Store errors fail open
+	store.err = errors.New("database down")
+	if err := d.CheckLogin(ctx, testLogin("198.51.100.1", "")); err != nil {
+		t.Errorf("CheckLogin with store error = %v, want nil", err)
+	}
+
+	// A nil detector accepts everything
+	var nilDetector *SuspiciousLoginDetector
+	if err := nilDetector.CheckLogin(ctx, testLogin("198.51.100.1", "")); err != nil || nilDetector.StepUpEnabled() {
+		t.Errorf("nil detector: err = %v", err)
+	}
+
+	if _, err := NewSuspiciousLoginDetector(SuspiciousLoginOptions{IgnoreNetworks: []string{"bogus"}}); err == nil {
+		t.Error("expected error for invalid ignore network")
+	}
+}
@@ -0,0 +1,112 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/server"
+	"github.com/migadu/sora/storage"
+)
+
+// Outcomes of resolving a failed upload.
+const (
+	ResolveRepaired = "repaired" // body found in storage; messages marked uploaded
+	ResolveDeleted  = "deleted"  // body missing; undeliverable message rows removed
+	ResolveSkipped  = "skipped"  // could not be checked or changed
+)
+
+// ResolveResult describes what was done, or would be done, with one failed upload.
+type ResolveResult struct {
+	ID              int64  `json:"id"`
+	AccountID       int64  `json:"account_id"`
+	AccountEmail    string `json:"account_email,omitempty"`
+	ContentHash     string `json:"content_hash"`
+	Outcome         string `json:"outcome"`
+	DeletedMessages int64  `json:"deleted_messages,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// ResolveReport summarizes a ResolveFailedUploads run.
+type ResolveReport struct {
+	DryRun   bool            `json:"dry_run"`
+	Repaired int             `json:"repaired"`
+	Deleted  int             `json:"deleted"`
+	Skipped  int             `json:"skipped"`
+	Results  []ResolveResult `json:"results"`
+}
+
+// ResolveFailedUploads settles uploads that have used up their attempts. If
+// the body is already in storage, the messages are marked uploaded and users
+// regain access to them. If it is missing, the content was never stored and
+// the message rows and pending record are removed. With dryRun, storage is
+// checked but nothing is changed.
+func ResolveFailedUploads(ctx context.Context, rdb *resilient.ResilientDatabase, backend storage.Backend, maxAttempts, limit int, dryRun bool) (*ResolveReport, error) {
+	failedUploads, err := rdb.GetFailedUploadsWithEmailWithRetry(ctx, maxAttempts, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get failed uploads: %w", err)
+	}
+
+	report := &ResolveReport{DryRun: dryRun, Results: make([]ResolveResult, 0, len(failedUploads))}
+	for _, upload := range failedUploads {
+		res := ResolveResult{
+			ID:           upload.ID,
+			AccountID:    upload.AccountID,
+			AccountEmail: upload.AccountEmail,
+			ContentHash:  upload.ContentHash,
+		}
+		resolveOne(ctx, rdb, backend, &res, dryRun)
+		switch res.Outcome {
+		case ResolveRepaired:
+			report.Repaired++
+		case ResolveDeleted:
+			report.Deleted++
+		default:
+			report.Skipped++
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report, nil
+}
+
+func resolveOne(ctx context.Context, rdb *resilient.ResilientDatabase, backend storage.Backend, res *ResolveResult, dryRun bool) {
+	res.Outcome = ResolveSkipped
+	if res.AccountEmail == "" {
+		res.Error = "no account email found"
+		return
+	}
+	address, err := server.NewAddress(res.AccountEmail)
+	if err != nil {
+		res.Error = fmt.Sprintf("malformed email: %s", res.AccountEmail)
+		return
+	}
+
+	key := helpers.NewS3Key(address.Domain(), address.LocalPart(), res.ContentHash)
+	exists, _, err := backend.Exists(key)
+	if err != nil {
+		res.Error = fmt.Sprintf("storage check failed: %v", err)
+		return
+	}
+
+	if exists {
+		if !dryRun {
+			if err := rdb.CompleteS3UploadWithRetry(ctx, res.ContentHash, res.AccountID); err != nil {
+				res.Error = err.Error()
+				return
+			}
+		}
+		res.Outcome = ResolveRepaired
+		return
+	}
+
+	if !dryRun {
+		n, err := rdb.DeleteFailedUploadWithRetry(ctx, res.ContentHash, res.AccountID)
+		if err != nil {
+			res.Error = err.Error()
+			return
+		}
+		res.DeletedMessages = n
+	}
+	res.Outcome = ResolveDeleted
+}
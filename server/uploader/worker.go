@@ -112,7 +112,7 @@ func (b *syncBool) Load() bool {
 	return b.val
 }
 
-func New(ctx context.Context, path string, batchSize int, concurrency int, maxAttempts int, retryInterval time.Duration, maxStagingSize int64, instanceID string, rdb *resilient.ResilientDatabase, s3 *storage.S3Storage, cache *cache.Cache, errCh chan<- error) (*UploadWorker, error) {
+func New(ctx context.Context, path string, batchSize int, concurrency int, maxAttempts int, retryInterval time.Duration, maxStagingSize int64, instanceID string, rdb *resilient.ResilientDatabase, s3 storage.Backend, cache *cache.Cache, errCh chan<- error) (*UploadWorker, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		if err := os.MkdirAll(path, 0755); err != nil {
 			return nil, fmt.Errorf("failed to create local path %s: %w", path, err)
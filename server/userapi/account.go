@@ -0,0 +1,294 @@
+package userapi
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server"
+)
+
+const (
+	defaultLoginHistoryLimit = 50
+	maxLoginHistoryLimit     = 1000
+)
+
+// SessionResponse describes the connections of the authenticated user from
+// one client IP on one server.
+type SessionResponse struct {
+	Protocol   string    `json:"protocol"` // "imap", "pop3", "managesieve", ...
+	Server     string    `json:"server"`   // Connection tracker the session is registered with
+	Instance   string    `json:"instance"` // Node holding the connections
+	IP         string    `json:"ip"`
+	Count      int       `json:"count"`
+	LastUpdate time.Time `json:"last_update"`
+}
+
+// recordLogin adds a login attempt to the login history and emits the
+// matching webhook event.
+func (s *Server) recordLogin(r *http.Request, email string, accountID int64, clientIP, failure string) {
+	s.loginHistory.Record(server.LoginRecord{
+		AccountID: accountID,
+		Username:  email,
+		Protocol:  "userapi",
+		IP:        clientIP,
+		ClientID:  r.UserAgent(),
+		Success:   failure == "",
+		Reason:    failure,
+	})
+	s.emitLogin(email, accountID, clientIP, failure)
+	if failure == "" {
+		s.suspiciousLogins.ObserveLogin(r.Context(), server.LoginRecord{
+			AccountID: accountID,
+			Username:  email,
+			Protocol:  "userapi",
+			IP:        clientIP,
+			ClientID:  r.UserAgent(),
+		})
+	}
+}
+
+// handleLoginHistory handles GET /user/account/logins
+func (s *Server) handleLoginHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit := defaultLoginHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if l, err := strconv.Atoi(v); err == nil && l > 0 {
+			limit = min(l, maxLoginHistoryLimit)
+		}
+	}
+
+	logins, err := s.rdb.GetLoginHistoryWithRetry(ctx, accountID, limit)
+	if err != nil {
+		logger.Warn("HTTP Mail API: Error retrieving login history", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to retrieve login history")
+		return
+	}
+	if logins == nil {
+		logins = []server.LoginRecord{}
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"logins": logins,
+		"count":  len(logins),
+	})
+}
+
+// handleListSessions handles GET /user/account/sessions
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	accountID, err := getAccountIDFromContext(r.Context())
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	email, _ := r.Context().Value(contextKeyEmail).(string)
+
+	sessions := s.userSessions(accountID, email)
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"sessions": sessions,
+		"count":    len(sessions),
+	})
+}
+
+// handleTerminateSessions handles DELETE /user/account/sessions?protocol=...
+// Sessions are terminated with the connection tracker's kick, which closes
+// every connection of the account on the matching servers; a single
+// connection cannot be targeted.
+func (s *Server) handleTerminateSessions(w http.ResponseWriter, r *http.Request) {
+	accountID, err := getAccountIDFromContext(r.Context())
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	email, _ := r.Context().Value(contextKeyEmail).(string)
+
+	if len(s.connectionTrackers) == 0 {
+		s.writeError(w, http.StatusServiceUnavailable, "Session tracking is not available")
+		return
+	}
+
+	protocol := strings.ToLower(r.URL.Query().Get("protocol"))
+	kicked := []string{}
+	for key, tracker := range s.connectionTrackers {
+		if tracker == nil || (protocol != "" && trackerProtocol(key) != protocol) {
+			continue
+		}
+		if err := tracker.KickUser(accountID, key); err != nil {
+			logger.Warn("HTTP Mail API: Error terminating sessions", "name", s.name, "email", email, "tracker", key, "error", err)
+			continue
+		}
+		kicked = append(kicked, key)
+	}
+	if len(kicked) == 0 {
+		if protocol != "" {
+			s.writeError(w, http.StatusNotFound, "No sessions found for protocol: "+protocol)
+		} else {
+			s.writeError(w, http.StatusInternalServerError, "Failed to terminate sessions")
+		}
+		return
+	}
+	sort.Strings(kicked)
+
+	logger.Info("HTTP Mail API: User terminated sessions", "name", s.name, "email", email, "account_id", accountID, "trackers", kicked)
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"success":  true,
+		"trackers": kicked,
+	})
+}
+
+// handleListLoginSources handles GET /user/account/login-sources
+func (s *Server) handleListLoginSources(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	sources, err := s.rdb.ListLoginSourcesWithRetry(ctx, accountID)
+	if err != nil {
+		logger.Warn("HTTP Mail API: Error retrieving login sources", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to retrieve login sources")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"sources": sources,
+		"count":   len(sources),
+	})
+}
+
+// handleLoginSourceOperations routes /user/account/login-sources/{id} and
+// /user/account/login-sources/{id}/confirm
+func (s *Server) handleLoginSourceOperations(w http.ResponseWriter, r *http.Request) {
+	if idStr := extractPathParam(r.URL.Path, "/user/account/login-sources/", "/confirm"); idStr != "" {
+		routeHandler("POST", func(w http.ResponseWriter, r *http.Request) {
+			s.handleConfirmLoginSource(w, r, idStr)
+		})(w, r)
+		return
+	}
+	idStr := extractPathParam(r.URL.Path, "/user/account/login-sources/", "")
+	routeHandler("DELETE", func(w http.ResponseWriter, r *http.Request) {
+		s.handleDeleteLoginSource(w, r, idStr)
+	})(w, r)
+}
+
+// handleConfirmLoginSource handles POST /user/account/login-sources/{id}/confirm.
+// Confirming a source lifts the step-up block for protocol logins from it.
+func (s *Server) handleConfirmLoginSource(w http.ResponseWriter, r *http.Request, idStr string) {
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		s.writeError(w, http.StatusBadRequest, "Invalid login source ID")
+		return
+	}
+
+	source, err := s.rdb.ConfirmLoginSourceWithRetry(ctx, accountID, id)
+	if err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			s.writeError(w, http.StatusNotFound, "Login source not found")
+			return
+		}
+		logger.Warn("HTTP Mail API: Error confirming login source", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to confirm login source")
+		return
+	}
+
+	logger.Info("HTTP Mail API: User confirmed login source", "name", s.name, "account_id", accountID, "kind", source.Kind, "value", source.Value)
+	s.writeJSON(w, http.StatusOK, source)
+}
+
+// handleDeleteLoginSource handles DELETE /user/account/login-sources/{id}.
+// The next login from a forgotten source is reported as new again.
+func (s *Server) handleDeleteLoginSource(w http.ResponseWriter, r *http.Request, idStr string) {
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		s.writeError(w, http.StatusBadRequest, "Invalid login source ID")
+		return
+	}
+
+	if err := s.rdb.DeleteLoginSourceWithRetry(ctx, accountID, id); err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			s.writeError(w, http.StatusNotFound, "Login source not found")
+			return
+		}
+		logger.Warn("HTTP Mail API: Error deleting login source", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to delete login source")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{"success": true})
+}
+
+// userSessions collects the account's live connections from all connection
+// trackers. Trackers on proxies may know the account only by its login
+// address, so entries are matched by account ID or address.
+func (s *Server) userSessions(accountID int64, email string) []SessionResponse {
+	sessions := []SessionResponse{}
+	for key, tracker := range s.connectionTrackers {
+		for _, info := range tracker.GetAllConnections() {
+			if info.AccountID != accountID && !strings.EqualFold(info.Username, email) {
+				continue
+			}
+			for instance, perIP := range info.PerIPCountByInstance {
+				for ip, count := range perIP {
+					if count <= 0 {
+						continue
+					}
+					sessions = append(sessions, SessionResponse{
+						Protocol:   trackerProtocol(key),
+						Server:     key,
+						Instance:   instance,
+						IP:         ip,
+						Count:      count,
+						LastUpdate: info.LastUpdate,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		if a.Server != b.Server {
+			return a.Server < b.Server
+		}
+		if a.Instance != b.Instance {
+			return a.Instance < b.Instance
+		}
+		return a.IP < b.IP
+	})
+	return sessions
+}
+
+// trackerProtocol returns the protocol of a connection tracker key such as
+// "IMAP-backend1" or "IMAP-proxy-imap1".
+func trackerProtocol(key string) string {
+	protocol, _, _ := strings.Cut(key, "-")
+	return strings.ToLower(protocol)
+}
@@ -0,0 +1,95 @@
+package userapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/migadu/sora/server"
+)
+
+func TestTrackerProtocol(t *testing.T) {
+	tests := map[string]string{
+		"IMAP-main":             "imap",
+		"ManageSieve-sieve1":    "managesieve",
+		"LMTP-host1-lmtp-proxy": "lmtp",
+		"POP3":                  "pop3",
+	}
+	for key, want := range tests {
+		if got := trackerProtocol(key); got != want {
+			t.Errorf("trackerProtocol(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestUserSessions(t *testing.T) {
+	ctx := context.Background()
+	imapTracker := server.NewConnectionTracker("IMAP", "main", "localhost", "localhost-main", nil, 0, 0, 0, true)
+	pop3Tracker := server.NewConnectionTracker("POP3", "main", "localhost", "localhost-main", nil, 0, 0, 0, true)
+	for _, c := range []struct {
+		tracker  *server.ConnectionTracker
+		id       int64
+		username string
+		addr     string
+	}{
+		{imapTracker, 1, "user@example.com", "192.0.2.1:1000"},
+		{imapTracker, 1, "user@example.com", "192.0.2.1:1001"},
+		{imapTracker, 2, "other@example.com", "192.0.2.9:1000"},
+		{pop3Tracker, 99, "User@Example.com", "198.51.100.1:1000"}, // Proxy-derived account ID
+	} {
+		if err := c.tracker.RegisterConnection(ctx, c.id, c.username, "", c.addr); err != nil {
+			t.Fatalf("RegisterConnection failed: %v", err)
+		}
+	}
+
+	s := &Server{connectionTrackers: map[string]*server.ConnectionTracker{
+		"IMAP-main": imapTracker,
+		"POP3-main": pop3Tracker,
+	}}
+	sessions := s.userSessions(1, "user@example.com")
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2: %+v", len(sessions), sessions)
+	}
+	if sessions[0].Protocol != "imap" || sessions[0].IP != "192.0.2.1" || sessions[0].Count != 2 {
+		t.Errorf("unexpected IMAP session: %+v", sessions[0])
+	}
+	if sessions[1].Protocol != "pop3" || sessions[1].IP != "198.51.100.1" {
+		t.Errorf("unexpected POP3 session: %+v", sessions[1])
+	}
+}
+
+func TestTerminateSessionsWithoutTracking(t *testing.T) {
+	s := &Server{}
+	req := httptest.NewRequest("DELETE", "/user/account/sessions", nil)
+	req = req.WithContext(context.WithValue(req.Context(), contextKeyAccountID, int64(1)))
+	rr := httptest.NewRecorder()
+	s.handleTerminateSessions(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestLoginSourceOperationsValidation(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"POST", "/user/account/login-sources/abc/confirm", http.StatusBadRequest},
+		{"POST", "/user/account/login-sources/0/confirm", http.StatusBadRequest},
+		{"GET", "/user/account/login-sources/1/confirm", http.StatusMethodNotAllowed},
+		{"DELETE", "/user/account/login-sources/-1", http.StatusBadRequest},
+		{"DELETE", "/user/account/login-sources/", http.StatusBadRequest},
+		{"PUT", "/user/account/login-sources/1", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), contextKeyAccountID, int64(1)))
+		rr := httptest.NewRecorder()
+		s.handleLoginSourceOperations(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("%s %s: status = %d, want %d", tc.method, tc.path, rr.Code, tc.want)
+		}
+	}
+}
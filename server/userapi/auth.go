@@ -110,6 +110,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			if s.authLimiter != nil {
 				s.authLimiter.RecordAuthAttempt(ctx, remoteAddr, req.Email, false)
 			}
+			s.recordLogin(r, req.Email, 0, clientIP, "invalid credentials")
 			s.writeError(w, http.StatusUnauthorized, "Invalid credentials")
 			return
 		}
@@ -139,6 +140,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			// attempt would turn the rate limiter into a CPU-amplification vector. The fast
 			// rejection is existence-independent, so it is not a user-enumeration oracle.)
 			logger.Debug("User API: Login rate limited", "name", s.name, "ip", clientIP, "email", req.Email, "error", err)
+			s.recordLogin(r, req.Email, 0, clientIP, "rate limited")
 			s.writeError(w, http.StatusUnauthorized, "Invalid credentials")
 			return
 		}
@@ -159,6 +161,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 			if s.authLimiter != nil {
 				s.authLimiter.RecordAuthAttempt(ctx, remoteAddr, req.Email, false)
 			}
+			s.recordLogin(r, req.Email, 0, clientIP, "invalid credentials")
 			// Don't reveal whether user exists or not
 			s.writeError(w, http.StatusUnauthorized, "Invalid credentials")
 			return
@@ -178,6 +181,7 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		if s.authLimiter != nil {
 			s.authLimiter.RecordAuthAttempt(ctx, remoteAddr, req.Email, false)
 		}
+		s.recordLogin(r, req.Email, 0, clientIP, "invalid credentials")
 		s.writeError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
@@ -219,6 +223,7 @@ generateToken:
 		AccountID: accountID,
 	}
 
+	s.recordLogin(r, req.Email, accountID, clientIP, "")
 	s.writeJSON(w, http.StatusOK, response)
 }
 
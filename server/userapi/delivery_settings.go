@@ -0,0 +1,96 @@
+package userapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/delivery"
+)
+
+// maxDeliverySettingsRequestSize bounds a delivery settings body
+const maxDeliverySettingsRequestSize = 4096
+
+// handleGetDeliverySettings returns the authenticated user's effective
+// delivery settings and whether they come from the account, its domain or the
+// server defaults
+func (s *Server) handleGetDeliverySettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	settings, err := s.rdb.GetDeliverySettingsWithRetry(ctx, accountID, "")
+	if err != nil {
+		logger.Warn("HTTP Mail API: Error retrieving delivery settings", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to retrieve delivery settings")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+// handlePutDeliverySettings replaces the authenticated user's delivery
+// settings, overriding those of the domain
+func (s *Server) handlePutDeliverySettings(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	ctx := r.Context()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxDeliverySettingsRequestSize)
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var settings db.DeliverySettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := delivery.ValidateDeliverySettings(&settings); err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.rdb.SetAccountDeliverySettingsWithRetry(ctx, accountID, &settings); err != nil {
+		logger.Warn("HTTP Mail API: Error saving delivery settings", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to save delivery settings")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+// handleDeleteDeliverySettings removes the authenticated user's delivery
+// settings, so those of the domain apply again
+func (s *Server) handleDeleteDeliverySettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := s.rdb.DeleteAccountDeliverySettingsWithRetry(ctx, accountID); err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			s.writeError(w, http.StatusNotFound, "Delivery settings not configured")
+			return
+		}
+		logger.Warn("HTTP Mail API: Error deleting delivery settings", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to delete delivery settings")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Delivery settings deleted successfully",
+	})
+}
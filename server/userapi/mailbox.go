@@ -10,6 +10,7 @@ import (
 	"github.com/migadu/sora/logger"
 
 	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/server/webhook"
 )
 
 // MailboxInfo represents mailbox information for API responses
@@ -136,6 +137,8 @@ func (s *Server) handleCreateMailbox(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.emitEvent(ctx, webhook.EventMailboxCreated, map[string]any{"mailbox": req.Name})
+
 	s.writeJSON(w, http.StatusCreated, map[string]any{
 		"message": "Mailbox created successfully",
 		"name":    req.Name,
@@ -177,6 +180,8 @@ func (s *Server) handleDeleteMailbox(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.emitEvent(ctx, webhook.EventMailboxDeleted, map[string]any{"mailbox": name})
+
 	s.writeJSON(w, http.StatusOK, map[string]any{
 		"message": "Mailbox deleted successfully",
 		"name":    name,
@@ -20,6 +20,8 @@ import (
 	"github.com/migadu/sora/pkg/lookupcache"
 	"github.com/migadu/sora/pkg/resilient"
 	"github.com/migadu/sora/server"
+	"github.com/migadu/sora/server/sieveengine"
+	"github.com/migadu/sora/server/webhook"
 	"github.com/migadu/sora/storage"
 )
 
@@ -34,7 +36,7 @@ type Server struct {
 	allowedOrigins             []string
 	allowedHosts               []string
 	rdb                        *resilient.ResilientDatabase
-	storage                    *storage.S3Storage
+	storage                    storage.Backend
 	cache                      *cache.Cache
 	authCache                  *lookupcache.LookupCache
 	positiveRevalidationWindow time.Duration
@@ -46,6 +48,12 @@ type Server struct {
 	tlsKeyFile                 string
 	tlsVerify                  bool
 	proxyReader                *server.ProxyProtocolReader
+	webhooks                   *webhook.Dispatcher
+	loginHistory               *server.LoginHistory
+	connectionTrackers         map[string]*server.ConnectionTracker
+	suspiciousLogins           *server.SuspiciousLoginDetector
+	takeout                    *TakeoutOptions
+	sieveExtensions            []string // extensions filter tests and vacation settings use
 }
 
 // ServerOptions holds configuration options for the HTTP Mail API server
@@ -58,7 +66,7 @@ type ServerOptions struct {
 	MaxConnections              int // Max concurrent connections; 0 -> unlimited
 	AllowedOrigins              []string
 	AllowedHosts                []string
-	Storage                     *storage.S3Storage
+	Storage                     storage.Backend
 	Cache                       *cache.Cache
 	AuthRateLimit               server.AuthRateLimiterConfig
 	LookupCache                 *config.LookupCacheConfig // Authentication cache configuration
@@ -70,7 +78,13 @@ type ServerOptions struct {
 	ProxyProtocol               bool
 	ProxyProtocolTimeout        string
 	ProxyProtocolTrustedProxies []string
-	TrustedNetworks             []string // Fallback if trusted proxies empty
+	TrustedNetworks             []string                             // Fallback if trusted proxies empty
+	Webhooks                    *webhook.Dispatcher                  // Outbound event webhooks (optional)
+	LoginHistory                *server.LoginHistory                 // Per-account login history (optional)
+	ConnectionTrackers          map[string]*server.ConnectionTracker // Tracker key -> tracker, for listing and terminating sessions
+	SuspiciousLogins            *server.SuspiciousLoginDetector      // New network/country alerts for User API logins (optional)
+	Takeout                     *TakeoutOptions                      // Self-service account exports; nil disables them
+	SieveExtensions             []string                             // Sieve extensions to enable (nil/empty = all default extensions)
 }
 
 // minJWTSecretLength is the minimum accepted JWT signing secret length. RFC 7518
@@ -201,6 +215,15 @@ func New(rdb *resilient.ResilientDatabase, options ServerOptions) (*Server, erro
 		tlsKeyFile:                 options.TLSKeyFile,
 		tlsVerify:                  options.TLSVerify,
 		proxyReader:                proxyReader,
+		webhooks:                   options.Webhooks,
+		loginHistory:               options.LoginHistory,
+		connectionTrackers:         options.ConnectionTrackers,
+		suspiciousLogins:           options.SuspiciousLogins,
+		takeout:                    options.Takeout,
+		sieveExtensions:            options.SieveExtensions,
+	}
+	if len(s.sieveExtensions) == 0 {
+		s.sieveExtensions = sieveengine.DefaultSieveExtensions
 	}
 
 	return s, nil
@@ -352,6 +375,36 @@ func (s *Server) SetupRoutes() http.Handler {
 	mux.Handle("/user/filters", s.jwtAuthMiddleware(routeHandler("GET", s.handleListFilters)))
 	mux.Handle("/user/filters/", s.jwtAuthMiddleware(http.HandlerFunc(s.handleFilterOperations)))
 
+	// Vacation auto-reply
+	mux.Handle("/user/vacation", s.jwtAuthMiddleware(multiMethodHandler(map[string]http.HandlerFunc{
+		"GET":    s.handleGetVacation,
+		"PUT":    s.handlePutVacation,
+		"DELETE": s.handleDeleteVacation,
+	})))
+
+	// Delivery settings (+detail folder filing)
+	mux.Handle("/user/delivery", s.jwtAuthMiddleware(multiMethodHandler(map[string]http.HandlerFunc{
+		"GET":    s.handleGetDeliverySettings,
+		"PUT":    s.handlePutDeliverySettings,
+		"DELETE": s.handleDeleteDeliverySettings,
+	})))
+
+	// Login history and active sessions
+	mux.Handle("/user/account/logins", s.jwtAuthMiddleware(routeHandler("GET", s.handleLoginHistory)))
+	mux.Handle("/user/account/sessions", s.jwtAuthMiddleware(multiMethodHandler(map[string]http.HandlerFunc{
+		"GET":    s.handleListSessions,
+		"DELETE": s.handleTerminateSessions,
+	})))
+
+	// Known login sources (suspicious login detection)
+	mux.Handle("/user/account/login-sources", s.jwtAuthMiddleware(routeHandler("GET", s.handleListLoginSources)))
+	mux.Handle("/user/account/login-sources/", s.jwtAuthMiddleware(http.HandlerFunc(s.handleLoginSourceOperations)))
+
+	// Account export (takeout). Downloads are authorized by a signed link, so
+	// handleTakeoutOperations applies JWT authentication itself.
+	mux.Handle("/user/account/takeout", s.jwtAuthMiddleware(http.HandlerFunc(s.handleTakeout)))
+	mux.HandleFunc("/user/account/takeout/", s.handleTakeoutOperations)
+
 	// Wrap with middleware (in reverse order - last applied is outermost)
 	handler := s.loggingMiddleware(mux)
 	handler = s.corsMiddleware(handler)
@@ -468,6 +521,16 @@ func (s *Server) handleFilterOperations(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Check for test (dry run) endpoint; a script may itself be named "test"
+	if extractPathParam(path, "/user/filters/", "/test") != "" {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleTestFilter(w, r)
+		return
+	}
+
 	// Otherwise it's a CRUD operation on the filter itself
 	switch r.Method {
 	case "GET":
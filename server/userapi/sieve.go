@@ -3,12 +3,19 @@ package userapi
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
+	"github.com/emersion/go-imap/v2"
+	"github.com/migadu/sora/helpers"
 	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/resilient"
+	"github.com/migadu/sora/server/sieveengine"
 
 	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
 )
 
 // SieveScriptResponse represents a Sieve script in API responses
@@ -24,6 +31,25 @@ type SieveScriptRequest struct {
 	Script string `json:"script"`
 }
 
+// SieveTestRequest selects the stored message a script is tested against
+type SieveTestRequest struct {
+	Mailbox      string `json:"mailbox"` // defaults to INBOX
+	UID          uint32 `json:"uid"`
+	EnvelopeFrom string `json:"envelope_from,omitempty"` // defaults to the From address
+}
+
+// SieveTestResponse reports the outcome of a dry run of a Sieve script
+type SieveTestResponse struct {
+	Name       string                  `json:"name"`
+	Action     string                  `json:"action"`
+	Mailbox    string                  `json:"mailbox,omitempty"`
+	RedirectTo string                  `json:"redirect_to,omitempty"`
+	Copy       bool                    `json:"copy,omitempty"`
+	Flags      []string                `json:"flags"`
+	Rewritten  bool                    `json:"rewritten"`
+	Steps      []sieveengine.TraceStep `json:"steps"`
+}
+
 // handleListFilters lists all Sieve scripts for the authenticated user
 func (s *Server) handleListFilters(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -258,6 +284,128 @@ func (s *Server) handleActivateFilter(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleTestFilter runs a stored Sieve script against an existing message in
+// trace mode. Nothing is delivered, flagged, redirected or recorded.
+func (s *Server) handleTestFilter(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Extract script name from path: /user/filters/{name}/test
+	name := extractPathParam(r.URL.Path, "/user/filters/", "/test")
+	name, err = url.QueryUnescape(name)
+	if err != nil || name == "" {
+		s.writeError(w, http.StatusBadRequest, "Invalid script name")
+		return
+	}
+
+	var req SieveTestRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.UID == 0 {
+		s.writeError(w, http.StatusBadRequest, "Message UID is required")
+		return
+	}
+	if req.Mailbox == "" {
+		req.Mailbox = "INBOX"
+	}
+
+	script, err := s.rdb.GetScriptByNameWithRetry(ctx, name, accountID)
+	if err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			s.writeError(w, http.StatusNotFound, "Script not found")
+			return
+		}
+		logger.Warn("HTTP Mail API: Error retrieving Sieve script", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to retrieve script")
+		return
+	}
+
+	mailbox, err := s.rdb.GetMailboxByNameWithRetry(ctx, accountID, req.Mailbox)
+	if err != nil {
+		if errors.Is(err, consts.ErrMailboxNotFound) || errors.Is(err, consts.ErrDBNotFound) {
+			s.writeError(w, http.StatusNotFound, "Mailbox not found")
+			return
+		}
+		logger.Warn("HTTP Mail API: Error retrieving mailbox", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to retrieve mailbox")
+		return
+	}
+
+	messages, err := s.rdb.GetMessagesByNumSetWithRetry(ctx, mailbox.ID, imap.UIDSetNum(imap.UID(req.UID)))
+	if err != nil {
+		logger.Warn("HTTP Mail API: Error retrieving message metadata", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to retrieve message")
+		return
+	}
+	if len(messages) == 0 {
+		s.writeError(w, http.StatusNotFound, "Message not found")
+		return
+	}
+
+	if s.storage == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "Storage not configured")
+		return
+	}
+	raw, err := s.messageBytes(messages[0])
+	if err != nil {
+		logger.Warn("HTTP Mail API: Error retrieving message body", "name", s.name, "error", err)
+		s.writeError(w, http.StatusServiceUnavailable, "Message body not available")
+		return
+	}
+
+	email, _ := ctx.Value(contextKeyEmail).(string)
+	evalCtx, err := sieveengine.ContextFromMessage(raw, req.EnvelopeFrom, email)
+	if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, "Failed to parse message")
+		return
+	}
+
+	result, steps, err := sieveengine.Trace(ctx, script.Script, evalCtx, s.sieveExtensions)
+	if err != nil {
+		s.writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Script failed: %v", err))
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, SieveTestResponse{
+		Name:       script.Name,
+		Action:     string(result.Action),
+		Mailbox:    result.Mailbox,
+		RedirectTo: result.RedirectTo,
+		Copy:       result.Copy,
+		Flags:      result.Flags,
+		Rewritten:  result.Message != nil,
+		Steps:      steps,
+	})
+}
+
+// messageBytes returns the full message from the cache or S3.
+func (s *Server) messageBytes(message db.Message) ([]byte, error) {
+	if s.cache != nil {
+		if data, err := s.cache.Get(message.ContentHash); err == nil && data != nil {
+			return data, nil
+		}
+	}
+	if message.S3Domain == "" || message.S3Localpart == "" || message.ContentHash == "" {
+		return nil, fmt.Errorf("message %d missing S3 key information (may be pending upload)", message.UID)
+	}
+	reader, err := s.storage.Get(helpers.NewS3Key(message.S3Domain, message.S3Localpart, message.ContentHash))
+	// Direct (non-retrying) S3 get: record one outcome for the error-rate metric.
+	resilient.RecordS3Operation("GET", err)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
 // validateScriptName validates a Sieve script name
 func validateScriptName(name string) error {
 	if name == "" {
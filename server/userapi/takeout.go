@@ -0,0 +1,386 @@
+package userapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/adminjobs"
+)
+
+// TakeoutOptions enables self-service account exports. Archives are built by
+// the admin job worker, which must run in at least one instance.
+type TakeoutOptions struct {
+	MinInterval time.Duration // Minimum time between successful takeouts of an account
+	LinkTTL     time.Duration // Lifetime of signed download links
+	Retention   time.Duration // How long archives can be downloaded
+	MaxAttempts int           // Attempts of the takeout job
+}
+
+// TakeoutRequest is the body of POST /user/account/takeout
+type TakeoutRequest struct {
+	Format string `json:"format"` // "mbox" (default) or "maildir"
+}
+
+// TakeoutResponse describes a takeout of the authenticated account
+type TakeoutResponse struct {
+	ID              int64      `json:"id"`
+	Status          string     `json:"status"`
+	Format          string     `json:"format"`
+	CreatedAt       time.Time  `json:"created_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	Percent         float64    `json:"percent"`
+	ProgressMessage string     `json:"progress_message,omitempty"`
+	Size            int64      `json:"size,omitempty"`
+	Messages        int64      `json:"messages,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	DownloadURL     string     `json:"download_url,omitempty"`
+	DownloadExpires *time.Time `json:"download_url_expires_at,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// handleTakeout handles GET and POST /user/account/takeout
+func (s *Server) handleTakeout(w http.ResponseWriter, r *http.Request) {
+	if s.takeout == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "Account export is not enabled")
+		return
+	}
+	multiMethodHandler(map[string]http.HandlerFunc{
+		"GET":  s.handleListTakeouts,
+		"POST": s.handleStartTakeout,
+	})(w, r)
+}
+
+// handleTakeoutOperations routes /user/account/takeout/{id} and the signed
+// /user/account/takeout/{id}/download, which is authenticated by its
+// signature instead of a JWT so that it can be opened by a browser.
+func (s *Server) handleTakeoutOperations(w http.ResponseWriter, r *http.Request) {
+	if s.takeout == nil {
+		s.writeError(w, http.StatusServiceUnavailable, "Account export is not enabled")
+		return
+	}
+	if idStr := extractPathParam(r.URL.Path, "/user/account/takeout/", "/download"); idStr != "" {
+		routeHandler("GET", func(w http.ResponseWriter, r *http.Request) {
+			s.handleDownloadTakeout(w, r, idStr)
+		})(w, r)
+		return
+	}
+	idStr := extractPathParam(r.URL.Path, "/user/account/takeout/", "")
+	s.jwtAuthMiddleware(routeHandler("GET", func(w http.ResponseWriter, r *http.Request) {
+		s.handleGetTakeout(w, r, idStr)
+	})).ServeHTTP(w, r)
+}
+
+// handleStartTakeout handles POST /user/account/takeout. One takeout may be
+// pending at a time, and a new one can only be started MinInterval after the
+// last successful one.
+func (s *Server) handleStartTakeout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req TakeoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			s.writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+	if req.Format == "" {
+		req.Format = adminjobs.TakeoutFormatMbox
+	}
+	if req.Format != adminjobs.TakeoutFormatMbox && req.Format != adminjobs.TakeoutFormatMaildir {
+		s.writeError(w, http.StatusBadRequest, "Format must be 'mbox' or 'maildir'")
+		return
+	}
+
+	email, jobs, ok := s.takeoutJobs(w, r, accountID)
+	if !ok {
+		return
+	}
+	for _, job := range jobs {
+		switch job.Status {
+		case db.AdminJobQueued, db.AdminJobRunning:
+			s.writeError(w, http.StatusConflict, "An account export is already in progress")
+			return
+		case db.AdminJobSucceeded:
+			if next := job.CreatedAt.Add(s.takeout.MinInterval); time.Now().Before(next) {
+				w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(next).Seconds())+1))
+				s.writeError(w, http.StatusTooManyRequests, "An account export was made recently, try again later")
+				return
+			}
+		}
+	}
+
+	s.deleteExpiredTakeouts(jobs)
+
+	params := map[string]any{"email": email, "format": req.Format}
+	job, err := adminjobs.Submit(ctx, s.rdb, adminjobs.TypeTakeout, params, "user:"+email, s.takeout.MaxAttempts)
+	if err != nil {
+		logger.Warn("HTTP Mail API: Error starting takeout", "name", s.name, "account_id", accountID, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to start account export")
+		return
+	}
+
+	logger.Info("HTTP Mail API: User started takeout", "name", s.name, "account_id", accountID, "job_id", job.ID, "format", req.Format)
+	s.writeJSON(w, http.StatusAccepted, s.takeoutResponse(job))
+}
+
+// handleListTakeouts handles GET /user/account/takeout
+func (s *Server) handleListTakeouts(w http.ResponseWriter, r *http.Request) {
+	accountID, err := getAccountIDFromContext(r.Context())
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	_, jobs, ok := s.takeoutJobs(w, r, accountID)
+	if !ok {
+		return
+	}
+	takeouts := make([]TakeoutResponse, 0, len(jobs))
+	for _, job := range jobs {
+		takeouts = append(takeouts, s.takeoutResponse(job))
+	}
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"takeouts": takeouts,
+		"total":    len(takeouts),
+	})
+}
+
+// handleGetTakeout handles GET /user/account/takeout/{id}. Finished
+// takeouts include a signed download link valid for LinkTTL.
+func (s *Server) handleGetTakeout(w http.ResponseWriter, r *http.Request, idStr string) {
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		s.writeError(w, http.StatusBadRequest, "Invalid takeout ID")
+		return
+	}
+
+	email, err := s.rdb.GetPrimaryEmailForAccountWithRetry(ctx, accountID)
+	if err != nil {
+		logger.Warn("HTTP Mail API: Error getting primary email", "name", s.name, "account_id", accountID, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to get takeout")
+		return
+	}
+	job, err := s.rdb.GetAdminJobWithRetry(ctx, id)
+	if err != nil && !errors.Is(err, consts.ErrDBNotFound) {
+		logger.Warn("HTTP Mail API: Error getting takeout", "name", s.name, "job_id", id, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to get takeout")
+		return
+	}
+	if err != nil || job.Type != adminjobs.TypeTakeout || takeoutEmail(job) != email.FullAddress() {
+		s.writeError(w, http.StatusNotFound, "Takeout not found")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, s.takeoutResponse(job))
+}
+
+// handleDownloadTakeout handles GET /user/account/takeout/{id}/download,
+// streaming the archive from object storage.
+func (s *Server) handleDownloadTakeout(w http.ResponseWriter, r *http.Request, idStr string) {
+	ctx := r.Context()
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		s.writeError(w, http.StatusBadRequest, "Invalid takeout ID")
+		return
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || !s.verifyTakeoutSignature(id, expires, r.URL.Query().Get("signature")) {
+		s.writeError(w, http.StatusForbidden, "Invalid download link")
+		return
+	}
+	if time.Now().Unix() > expires {
+		s.writeError(w, http.StatusForbidden, "Download link has expired")
+		return
+	}
+
+	job, err := s.rdb.GetAdminJobWithRetry(ctx, id)
+	if err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			s.writeError(w, http.StatusNotFound, "Takeout not found")
+			return
+		}
+		logger.Warn("HTTP Mail API: Error getting takeout", "name", s.name, "job_id", id, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to get takeout")
+		return
+	}
+	key, _ := job.Result["archive_key"].(string)
+	if job.Type != adminjobs.TypeTakeout || job.Status != db.AdminJobSucceeded || key == "" {
+		s.writeError(w, http.StatusNotFound, "Takeout not found")
+		return
+	}
+	if s.takeoutExpired(job) {
+		if err := s.storage.Delete(key); err != nil {
+			logger.Warn("HTTP Mail API: Error deleting expired takeout archive", "name", s.name, "key", key, "error", err)
+		}
+		s.writeError(w, http.StatusGone, "Takeout archive has expired")
+		return
+	}
+
+	reader, err := s.storage.Get(key)
+	if err != nil {
+		logger.Warn("HTTP Mail API: Error reading takeout archive", "name", s.name, "key", key, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to read takeout archive")
+		return
+	}
+	defer reader.Close()
+
+	// Archives can take longer than the server's write timeout to send.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="takeout-%d.zip"`, job.ID))
+	if size := resultInt(job.Result, "size"); size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	w.WriteHeader(http.StatusOK)
+	n, err := io.Copy(w, reader)
+	if err != nil {
+		logger.Warn("HTTP Mail API: Takeout download interrupted", "name", s.name, "job_id", job.ID, "bytes", n, "error", err)
+		return
+	}
+	logger.Info("HTTP Mail API: Takeout downloaded", "name", s.name, "job_id", job.ID, "email", takeoutEmail(job), "bytes", n)
+}
+
+// takeoutJobs returns the account's primary address and its takeouts, newest
+// first. On failure it writes the error response and returns false.
+func (s *Server) takeoutJobs(w http.ResponseWriter, r *http.Request, accountID int64) (string, []*db.AdminJob, bool) {
+	ctx := r.Context()
+	primary, err := s.rdb.GetPrimaryEmailForAccountWithRetry(ctx, accountID)
+	if err != nil {
+		logger.Warn("HTTP Mail API: Error getting primary email", "name", s.name, "account_id", accountID, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to list takeouts")
+		return "", nil, false
+	}
+	email := primary.FullAddress()
+	jobs, err := s.rdb.ListAdminJobsWithRetry(ctx, db.AdminJobFilter{Type: adminjobs.TypeTakeout, Email: email})
+	if err != nil {
+		logger.Warn("HTTP Mail API: Error listing takeouts", "name", s.name, "account_id", accountID, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to list takeouts")
+		return "", nil, false
+	}
+	return email, jobs, true
+}
+
+func (s *Server) takeoutResponse(job *db.AdminJob) TakeoutResponse {
+	resp := TakeoutResponse{
+		ID:              job.ID,
+		Status:          job.Status,
+		Format:          adminjobs.TakeoutFormatMbox,
+		CreatedAt:       job.CreatedAt,
+		FinishedAt:      job.FinishedAt,
+		Percent:         job.Percent,
+		ProgressMessage: job.ProgressMessage,
+	}
+	if format, _ := job.Params["format"].(string); format != "" {
+		resp.Format = format
+	}
+	switch job.Status {
+	case db.AdminJobFailed:
+		resp.Error = "Account export failed"
+	case db.AdminJobSucceeded:
+		resp.Size = resultInt(job.Result, "size")
+		resp.Messages = resultInt(job.Result, "messages")
+		if job.FinishedAt != nil && s.takeout.Retention > 0 {
+			expiresAt := job.FinishedAt.Add(s.takeout.Retention)
+			resp.ExpiresAt = &expiresAt
+		}
+		if !s.takeoutExpired(job) {
+			linkExpires := time.Now().Add(s.takeout.LinkTTL).Truncate(time.Second)
+			if resp.ExpiresAt != nil && resp.ExpiresAt.Before(linkExpires) {
+				linkExpires = resp.ExpiresAt.Truncate(time.Second)
+			}
+			resp.DownloadURL = s.takeoutDownloadURL(job.ID, linkExpires.Unix())
+			resp.DownloadExpires = &linkExpires
+		}
+	}
+	return resp
+}
+
+// deleteExpiredTakeouts removes archives past retention that were never
+// downloaded after they expired.
+func (s *Server) deleteExpiredTakeouts(jobs []*db.AdminJob) {
+	for _, job := range jobs {
+		key, _ := job.Result["archive_key"].(string)
+		if key == "" || job.Status != db.AdminJobSucceeded || !s.takeoutExpired(job) {
+			continue
+		}
+		if err := s.storage.Delete(key); err != nil {
+			logger.Warn("HTTP Mail API: Error deleting expired takeout archive", "name", s.name, "key", key, "error", err)
+		}
+	}
+}
+
+func (s *Server) takeoutExpired(job *db.AdminJob) bool {
+	return s.takeout.Retention > 0 && job.FinishedAt != nil && time.Since(*job.FinishedAt) > s.takeout.Retention
+}
+
+// takeoutDownloadURL returns the path of a download link for a takeout valid
+// until expires (Unix seconds).
+func (s *Server) takeoutDownloadURL(id, expires int64) string {
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", s.takeoutSignature(id, expires))
+	return fmt.Sprintf("/user/account/takeout/%d/download?%s", id, q.Encode())
+}
+
+// takeoutSignature signs a download link with the JWT secret, so that links
+// issued by any User API instance of the cluster are accepted by all others.
+func (s *Server) takeoutSignature(id, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.jwtSecret))
+	fmt.Fprintf(mac, "takeout:%d:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) verifyTakeoutSignature(id, expires int64, signature string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	want, _ := hex.DecodeString(s.takeoutSignature(id, expires))
+	return hmac.Equal(got, want)
+}
+
+func takeoutEmail(job *db.AdminJob) string {
+	email, _ := job.Params["email"].(string)
+	return email
+}
+
+// resultInt reads a number from a job result, which holds float64 after the
+// JSON round trip through the database.
+func resultInt(result map[string]any, key string) int64 {
+	switch v := result[key].(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+package userapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/server/adminjobs"
+)
+
+func TestTakeoutDisabled(t *testing.T) {
+	s := &Server{}
+	for _, path := range []string{"/user/account/takeout/1", "/user/account/takeout/1/download"} {
+		rec := httptest.NewRecorder()
+		s.SetupRoutes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: status = %d, want %d", path, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestTakeoutDownloadSignature(t *testing.T) {
+	s := &Server{jwtSecret: strings.Repeat("k", 32), takeout: &TakeoutOptions{LinkTTL: time.Minute}}
+	expires := time.Now().Add(time.Minute).Unix()
+
+	link, err := url.Parse(s.takeoutDownloadURL(42, expires))
+	if err != nil {
+		t.Fatalf("invalid download URL: %v", err)
+	}
+	if link.Path != "/user/account/takeout/42/download" {
+		t.Errorf("path = %q", link.Path)
+	}
+	signature := link.Query().Get("signature")
+	if !s.verifyTakeoutSignature(42, expires, signature) {
+		t.Error("valid signature rejected")
+	}
+	if s.verifyTakeoutSignature(43, expires, signature) {
+		t.Error("signature accepted for another takeout")
+	}
+	if s.verifyTakeoutSignature(42, expires+3600, signature) {
+		t.Error("signature accepted with a later expiry")
+	}
+	other := &Server{jwtSecret: strings.Repeat("x", 32)}
+	if other.verifyTakeoutSignature(42, expires, signature) {
+		t.Error("signature accepted with another secret")
+	}
+
+	// Rejected before the job is looked up.
+	tests := map[string]int{
+		"/user/account/takeout/42/download?expires=" + strconv.FormatInt(expires, 10) + "&signature=00": http.StatusForbidden,
+		"/user/account/takeout/42/download?signature=" + signature:                                      http.StatusForbidden,
+		"/user/account/takeout/abc/download":                                                            http.StatusBadRequest,
+	}
+	expired := time.Now().Add(-time.Minute).Unix()
+	tests[s.takeoutDownloadURL(42, expired)] = http.StatusForbidden
+	for target, want := range tests {
+		rec := httptest.NewRecorder()
+		s.SetupRoutes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, want)
+		}
+	}
+}
+
+func TestTakeoutRequiresJWT(t *testing.T) {
+	s := &Server{jwtSecret: strings.Repeat("k", 32), takeout: &TakeoutOptions{}}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/user/account/takeout", nil),
+		httptest.NewRequest(http.MethodGet, "/user/account/takeout/1", nil),
+	} {
+		rec := httptest.NewRecorder()
+		s.SetupRoutes().ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s: status = %d, want %d", req.Method, req.URL.Path, rec.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestTakeoutResponse(t *testing.T) {
+	s := &Server{jwtSecret: strings.Repeat("k", 32), takeout: &TakeoutOptions{LinkTTL: time.Hour, Retention: 24 * time.Hour}}
+	finished := time.Now().Add(-23*time.Hour - 30*time.Minute)
+	job := &db.AdminJob{
+		ID:         7,
+		Type:       adminjobs.TypeTakeout,
+		Status:     db.AdminJobSucceeded,
+		Params:     map[string]any{"email": "user@example.com", "format": "maildir"},
+		FinishedAt: &finished,
+		Result:     map[string]any{"archive_key": "takeout/1/7.zip", "size": float64(1024), "messages": float64(3)},
+	}
+
+	resp := s.takeoutResponse(job)
+	if resp.Format != "maildir" || resp.Size != 1024 || resp.Messages != 3 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.DownloadURL == "" || resp.DownloadExpires == nil {
+		t.Fatal("missing download link")
+	}
+	// The link does not outlive the archive.
+	if resp.DownloadExpires.After(*resp.ExpiresAt) {
+		t.Errorf("link expires at %v, after the archive at %v", resp.DownloadExpires, resp.ExpiresAt)
+	}
+
+	old := time.Now().Add(-25 * time.Hour)
+	job.FinishedAt = &old
+	if resp := s.takeoutResponse(job); resp.DownloadURL != "" {
+		t.Error("download link for an expired archive")
+	}
+
+	job.Status = db.AdminJobRunning
+	job.FinishedAt = nil
+	if resp := s.takeoutResponse(job); resp.DownloadURL != "" || resp.ExpiresAt != nil {
+		t.Errorf("unexpected response for a running takeout: %+v", resp)
+	}
+}
@@ -0,0 +1,100 @@
+package userapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/migadu/sora/consts"
+	"github.com/migadu/sora/db"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/server/delivery"
+)
+
+// maxVacationRequestSize bounds a vacation settings body: two 64 KiB bodies
+// with JSON escaping headroom plus the remaining fields.
+const maxVacationRequestSize = 4*65536 + 16384
+
+// handleGetVacation returns the authenticated user's auto-reply settings
+func (s *Server) handleGetVacation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	settings, err := s.rdb.GetVacationSettingsWithRetry(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			s.writeError(w, http.StatusNotFound, "Vacation reply not configured")
+			return
+		}
+		logger.Warn("HTTP Mail API: Error retrieving vacation settings", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to retrieve vacation settings")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+// handlePutVacation replaces the authenticated user's auto-reply settings. The
+// settings are compiled into a managed Sieve script that runs alongside the
+// user's own filters.
+func (s *Server) handlePutVacation(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	ctx := r.Context()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxVacationRequestSize)
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var settings db.VacationSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		s.writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	settings.AccountID = accountID
+
+	if err := delivery.SaveVacationSettings(ctx, s.rdb, &settings, s.sieveExtensions); err != nil {
+		if errors.Is(err, delivery.ErrInvalidVacation) {
+			s.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.Warn("HTTP Mail API: Error saving vacation settings", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to save vacation settings")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, settings)
+}
+
+// handleDeleteVacation removes the authenticated user's auto-reply settings
+func (s *Server) handleDeleteVacation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	accountID, err := getAccountIDFromContext(ctx)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := s.rdb.DeleteVacationSettingsWithRetry(ctx, accountID); err != nil {
+		if errors.Is(err, consts.ErrDBNotFound) {
+			s.writeError(w, http.StatusNotFound, "Vacation reply not configured")
+			return
+		}
+		logger.Warn("HTTP Mail API: Error deleting vacation settings", "name", s.name, "error", err)
+		s.writeError(w, http.StatusInternalServerError, "Failed to delete vacation settings")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]any{
+		"message": "Vacation reply deleted successfully",
+	})
+}
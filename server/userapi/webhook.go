@@ -0,0 +1,32 @@
+package userapi
+
+import (
+	"context"
+
+	"github.com/migadu/sora/server/webhook"
+)
+
+// emitEvent sends a webhook event for the authenticated user of ctx. It is a
+// no-op when webhooks are disabled.
+func (s *Server) emitEvent(ctx context.Context, eventType string, data map[string]any) {
+	if s.webhooks == nil {
+		return
+	}
+	accountID, _ := ctx.Value(contextKeyAccountID).(int64)
+	email, _ := ctx.Value(contextKeyEmail).(string)
+	s.webhooks.Emit(webhook.NewEvent(eventType, "userapi", accountID, email, data))
+}
+
+// emitLogin sends a login.succeeded or login.failed event.
+func (s *Server) emitLogin(email string, accountID int64, clientIP, failure string) {
+	if s.webhooks == nil {
+		return
+	}
+	if failure != "" {
+		s.webhooks.Emit(webhook.NewEvent(webhook.EventLoginFailed, "userapi", 0, email,
+			map[string]any{"remote_ip": clientIP, "reason": failure}))
+		return
+	}
+	s.webhooks.Emit(webhook.NewEvent(webhook.EventLoginSucceeded, "userapi", accountID, email,
+		map[string]any{"remote_ip": clientIP, "method": "password"}))
+}
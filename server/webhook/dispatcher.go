@@ -0,0 +1,459 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/migadu/sora/config"
+	"github.com/migadu/sora/logger"
+	"github.com/migadu/sora/pkg/circuitbreaker"
+	"github.com/migadu/sora/pkg/metrics"
+)
+
+// endpoint is a configured receiver with its own circuit breaker.
+type endpoint struct {
+	cfg     config.WebhookEndpointConfig
+	breaker *circuitbreaker.CircuitBreaker
+}
+
+// Dispatcher queues events for the configured endpoints and delivers them in
+// the background. A nil *Dispatcher is valid and drops every event, so
+// callers do not need to check whether webhooks are enabled.
+type Dispatcher struct {
+	queue           *DiskQueue
+	endpoints       map[string]*endpoint
+	order           []*endpoint
+	httpClient      *http.Client
+	interval        time.Duration
+	batchSize       int
+	concurrency     int
+	failedRetention time.Duration
+	breakerTimeout  time.Duration // how long deliveries wait out an open breaker
+	notifyCh        chan struct{}
+	stopCh          chan struct{} // made by each Start, closed by Stop
+	wg              sync.WaitGroup
+	mu              sync.Mutex
+	running         bool
+}
+
+// New creates a dispatcher from the webhooks configuration.
+func New(cfg *config.WebhooksConfig) (*Dispatcher, error) {
+	if cfg == nil || !cfg.IsConfigured() {
+		return nil, fmt.Errorf("webhooks not configured")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	interval, err := cfg.GetWorkerInterval()
+	if err != nil {
+		return nil, fmt.Errorf("invalid worker_interval: %w", err)
+	}
+	backoff, err := cfg.GetRetryBackoff()
+	if err != nil {
+		return nil, fmt.Errorf("invalid retry_backoff: %w", err)
+	}
+	timeout, err := cfg.GetTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+	retention, err := cfg.GetFailedRetention()
+	if err != nil {
+		return nil, fmt.Errorf("invalid failed_retention: %w", err)
+	}
+	cbTimeout, err := cfg.CircuitBreaker.GetTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("invalid circuit breaker timeout: %w", err)
+	}
+
+	queue, err := NewDiskQueue(cfg.GetQueuePath(), cfg.MaxAttempts, backoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook queue: %w", err)
+	}
+
+	d := &Dispatcher{
+		queue:     queue,
+		endpoints: make(map[string]*endpoint),
+		httpClient: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				MaxIdleConns:        10,
+				MaxIdleConnsPerHost: 10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+		},
+		interval:        interval,
+		batchSize:       cfg.BatchSize,
+		concurrency:     cfg.Concurrency,
+		failedRetention: retention,
+		breakerTimeout:  cbTimeout,
+		notifyCh:        make(chan struct{}, 1),
+	}
+	if d.batchSize <= 0 {
+		d.batchSize = 100
+	}
+	if d.concurrency <= 0 {
+		d.concurrency = 5
+	}
+
+	threshold := cfg.CircuitBreaker.GetThreshold()
+	for _, epCfg := range cfg.Endpoints {
+		for _, f := range epCfg.Events {
+			if !validFilter(f) {
+				return nil, fmt.Errorf("webhooks.endpoint %q: unknown event filter %q", epCfg.Name, f)
+			}
+		}
+		ep := &endpoint{
+			cfg: epCfg,
+			breaker: circuitbreaker.NewCircuitBreaker(circuitbreaker.Settings{
+				Name:        "webhook-" + epCfg.Name,
+				MaxRequests: uint32(cfg.CircuitBreaker.GetMaxRequests()),
+				Interval:    0,
+				Timeout:     cbTimeout,
+				ReadyToTrip: func(counts circuitbreaker.Counts) bool {
+					return counts.ConsecutiveFailures >= uint32(threshold)
+				},
+				OnStateChange: func(name string, from circuitbreaker.State, to circuitbreaker.State) {
+					logger.Info("Webhook circuit breaker state change", "name", name, "from", from.String(), "to", to.String())
+				},
+			}),
+		}
+		d.endpoints[epCfg.Name] = ep
+		d.order = append(d.order, ep)
+	}
+
+	return d, nil
+}
+
+// Emit queues ev for every endpoint whose filter matches. It never blocks on
+// the network; errors writing the queue are logged, not returned, so event
+// delivery cannot fail the operation that produced the event.
+func (d *Dispatcher) Emit(ev *Event) {
+	if d == nil || ev == nil {
+		return
+	}
+	metrics.WebhookEvents.WithLabelValues(ev.Type).Inc()
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		logger.Error("Webhook: Failed to encode event", "event", ev.Type, "error", err)
+		return
+	}
+
+	queued := false
+	for _, ep := range d.order {
+		if !matchesFilter(ep.cfg.Events, ev.Type) {
+			continue
+		}
+		if err := d.queue.Enqueue(ep.cfg.Name, ev.Type, payload); err != nil {
+			logger.Error("Webhook: Failed to queue event", "endpoint", ep.cfg.Name, "event", ev.Type, "id", ev.ID, "error", err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		d.notify()
+	}
+}
+
+// Start begins background delivery. It is safe to call more than once, and
+// to call again after Stop or after ctx is done.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	d.mu.Lock()
+	if d.running {
+		d.mu.Unlock()
+		return nil
+	}
+	d.running = true
+	d.stopCh = make(chan struct{})
+	stopCh := d.stopCh
+	d.wg.Add(1)
+	d.mu.Unlock()
+
+	go d.run(ctx, stopCh)
+
+	logger.Info("Webhook: dispatcher started", "endpoints", len(d.order))
+	return nil
+}
+
+// Stop stops background delivery and waits for in-flight requests.
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if !d.running {
+		d.mu.Unlock()
+		return
+	}
+	d.running = false
+	close(d.stopCh)
+	d.mu.Unlock()
+
+	d.wg.Wait()
+
+	logger.Info("Webhook: dispatcher stopped")
+}
+
+// GetStats returns current queue statistics.
+func (d *Dispatcher) GetStats() (pending, processing, failed int, err error) {
+	return d.queue.GetStats()
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) run(ctx context.Context, stopCh chan struct{}) {
+	defer func() {
+		// A run that ended with its ctx lets a later Start begin a new one
+		d.mu.Lock()
+		if d.stopCh == stopCh {
+			d.running = false
+		}
+		d.mu.Unlock()
+		d.wg.Done()
+	}()
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	cleanupTicker := time.NewTicker(time.Hour)
+	defer cleanupTicker.Stop()
+	cbCheckTicker := time.NewTicker(5 * time.Second)
+	defer cbCheckTicker.Stop()
+
+	if recovered, err := d.queue.RecoverOrphaned(); err != nil {
+		logger.Error("Webhook: Failed to recover orphaned deliveries", "error", err)
+	} else if recovered > 0 {
+		logger.Info("Webhook: Crash recovery completed", "recovered_deliveries", recovered)
+	}
+	d.cleanupFailed()
+	d.processQueue(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			d.processQueue(ctx)
+		case <-cleanupTicker.C:
+			d.cleanupFailed()
+		case <-cbCheckTicker.C:
+			// Retry promptly when any endpoint's breaker is ready for a probe
+			for _, ep := range d.order {
+				if ep.breaker.State() == circuitbreaker.StateHalfOpen {
+					d.processQueue(ctx)
+					break
+				}
+			}
+		case <-d.notifyCh:
+			d.processQueue(ctx)
+		}
+	}
+}
+
+// processQueue delivers up to batchSize due deliveries concurrently.
+func (d *Dispatcher) processQueue(ctx context.Context) {
+	sem := make(chan struct{}, d.concurrency)
+	var wg sync.WaitGroup
+
+	processed := 0
+	for processed < d.batchSize {
+		if ctx.Err() != nil {
+			break
+		}
+		del, err := d.queue.AcquireNext()
+		if err != nil {
+			logger.Error("Webhook: Failed to acquire delivery", "error", err)
+			break
+		}
+		if del == nil {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(del *Delivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			d.deliver(ctx, del)
+		}(del)
+		processed++
+	}
+	wg.Wait()
+
+	if pending, processing, failed, err := d.queue.GetStats(); err == nil {
+		metrics.WebhookQueueDepth.WithLabelValues("pending").Set(float64(pending))
+		metrics.WebhookQueueDepth.WithLabelValues("processing").Set(float64(processing))
+		metrics.WebhookQueueDepth.WithLabelValues("failed").Set(float64(failed))
+		if processed > 0 {
+			logger.Info("Webhook: Processed deliveries", "count", processed,
+				"pending", pending, "processing", processing, "failed", failed)
+		}
+	}
+}
+
+// permanentError marks responses that retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// deliver sends one delivery and records the outcome in the queue.
+func (d *Dispatcher) deliver(ctx context.Context, del *Delivery) {
+	ep, ok := d.endpoints[del.Endpoint]
+	if !ok {
+		// The endpoint was removed from the configuration since the event was queued
+		if err := d.queue.MarkPermanentFailure(del.ID, "endpoint no longer configured"); err != nil {
+			logger.Error("Webhook: CRITICAL - Failed to mark permanent failure", "id", del.ID, "error", err)
+		}
+		metrics.WebhookDelivery.WithLabelValues(del.Endpoint, "permanent_failure").Inc()
+		return
+	}
+
+	start := time.Now()
+	var permanent *permanentError
+	_, err := ep.breaker.Execute(func() (any, error) {
+		err := d.post(ctx, ep, del)
+		if errors.As(err, &permanent) {
+			// The receiver is up and answered; do not count this against the breaker
+			return nil, nil
+		}
+		return nil, err
+	})
+	metrics.WebhookDeliveryDuration.WithLabelValues(ep.cfg.Name).Observe(time.Since(start).Seconds())
+
+	switch {
+	case errors.Is(err, circuitbreaker.ErrCircuitBreakerOpen) || errors.Is(err, circuitbreaker.ErrTooManyRequests):
+		metrics.WebhookDelivery.WithLabelValues(ep.cfg.Name, "circuit_breaker_blocked").Inc()
+		if releaseErr := d.queue.Release(del.ID, d.breakerTimeout); releaseErr != nil {
+			logger.Error("Webhook: CRITICAL - Failed to release delivery", "id", del.ID, "error", releaseErr)
+		}
+	case err != nil:
+		metrics.WebhookDelivery.WithLabelValues(ep.cfg.Name, "temporary_failure").Inc()
+		if markErr := d.queue.MarkFailure(del.ID, err.Error()); markErr != nil {
+			logger.Error("Webhook: CRITICAL - Failed to mark failure", "id", del.ID, "error", markErr)
+		}
+	case permanent != nil:
+		metrics.WebhookDelivery.WithLabelValues(ep.cfg.Name, "permanent_failure").Inc()
+		if markErr := d.queue.MarkPermanentFailure(del.ID, permanent.Error()); markErr != nil {
+			logger.Error("Webhook: CRITICAL - Failed to mark permanent failure", "id", del.ID, "error", markErr)
+		}
+	default:
+		metrics.WebhookDelivery.WithLabelValues(ep.cfg.Name, "success").Inc()
+		if markErr := d.queue.MarkSuccess(del.ID); markErr != nil {
+			logger.Error("Webhook: CRITICAL - Failed to mark success", "id", del.ID, "error", markErr)
+		}
+	}
+}
+
+// post performs the signed HTTP request. 4xx responses other than 408 and
+// 429 are returned as a *permanentError.
+func (d *Dispatcher) post(ctx context.Context, ep *endpoint, del *Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.cfg.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to create HTTP request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Sora-Webhook/1")
+	req.Header.Set("X-Sora-Event", del.EventType)
+	req.Header.Set("X-Sora-Delivery", del.ID)
+	if ep.cfg.Secret != "" {
+		req.Header.Set("X-Sora-Signature", Sign(ep.cfg.Secret, time.Now(), del.Payload))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return &permanentError{fmt.Errorf("endpoint returned status %d: %s", resp.StatusCode, string(body))}
+	default:
+		return fmt.Errorf("endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+}
+
+func (d *Dispatcher) cleanupFailed() {
+	cleaned, err := d.queue.CleanupOldFailed(d.failedRetention)
+	if err != nil {
+		logger.Error("Webhook: Failed to cleanup old failed deliveries", "error", err)
+		return
+	}
+	if cleaned > 0 {
+		logger.Info("Webhook: Cleaned up old failed deliveries", "count", cleaned, "retention", d.failedRetention)
+	}
+}
+
+// Sign returns the X-Sora-Signature header value for body sent at ts.
+func Sign(secret string, ts time.Time, body []byte) string {
+	unix := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + unix + ",v1=" + computeMAC(secret, unix, body)
+}
+
+// Verify checks an X-Sora-Signature header against body. Signatures older
+// or newer than tolerance are rejected; a zero tolerance skips that check.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var unix string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "t":
+			unix = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+	if unix == "" || len(sigs) == 0 {
+		return fmt.Errorf("malformed signature header")
+	}
+	ts, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp: %w", err)
+	}
+	if tolerance > 0 {
+		age := time.Since(time.Unix(ts, 0))
+		if age > tolerance || age < -tolerance {
+			return fmt.Errorf("signature timestamp outside tolerance")
+		}
+	}
+	expected := []byte(computeMAC(secret, unix, body))
+	for _, sig := range sigs {
+		if hmac.Equal(expected, []byte(sig)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature mismatch")
+}
+
+func computeMAC(secret, unix string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
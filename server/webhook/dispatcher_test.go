@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/migadu/sora/config"
+)
+
+func TestDispatcherDelivers(t *testing.T) {
+	var mu sync.Mutex
+	var got []Event
+	var failOnce = true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify("secret", r.Header.Get("X-Sora-Signature"), body, time.Minute); err != nil {
+			t.Errorf("bad signature: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if failOnce {
+			failOnce = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var ev Event
+		_ = json.Unmarshal(body, &ev)
+		if r.Header.Get("X-Sora-Event") != ev.Type {
+			t.Errorf("X-Sora-Event = %q, body type %q", r.Header.Get("X-Sora-Event"), ev.Type)
+		}
+		got = append(got, ev)
+	}))
+	defer srv.Close()
+
+	d, err := New(&config.WebhooksConfig{
+		Enabled:        true,
+		QueuePath:      t.TempDir(),
+		WorkerInterval: "20ms",
+		RetryBackoff:   []string{"10ms"},
+		Endpoints: []config.WebhookEndpointConfig{
+			{Name: "all", URL: srv.URL, Secret: "secret", Events: []string{"account.*"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_ = d.Start(ctx)
+	defer d.Stop()
+
+	d.Emit(NewEvent(EventAccountCreated, "adminapi", 42, "user@example.com", nil))
+	d.Emit(NewEvent(EventLoginFailed, "imap", 0, "user@example.com", nil)) // filtered out
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || got[0].Type != EventAccountCreated || got[0].AccountID != 42 {
+		t.Fatalf("received %+v, want one account.created event after a retry", got)
+	}
+}
+
+func TestDispatcherRestart(t *testing.T) {
+	delivered := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case delivered <- struct{}{}:
+		default:
+		}
+	}))
+	defer srv.Close()
+
+	d, err := New(&config.WebhooksConfig{
+		Enabled:        true,
+		QueuePath:      t.TempDir(),
+		WorkerInterval: "20ms",
+		Endpoints:      []config.WebhookEndpointConfig{{Name: "all", URL: srv.URL}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_ = d.Start(ctx)
+	d.Stop()
+	_ = d.Start(ctx)
+	defer d.Stop()
+
+	d.Emit(NewEvent(EventAccountCreated, "adminapi", 42, "user@example.com", nil))
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery after restarting the dispatcher")
+	}
+}
+
+func TestDispatcherPermanentFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	d, err := New(&config.WebhooksConfig{
+		Enabled:   true,
+		QueuePath: t.TempDir(),
+		Endpoints: []config.WebhookEndpointConfig{{Name: "gone", URL: srv.URL}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	d.Emit(NewEvent(EventMailboxCreated, "imap", 1, "user@example.com", nil))
+	d.processQueue(context.Background())
+
+	pending, processing, failed, _ := d.GetStats()
+	if pending != 0 || processing != 0 || failed != 1 {
+		t.Errorf("stats = %d/%d/%d, want 0/0/1", pending, processing, failed)
+	}
+	if d.endpoints["gone"].breaker.Counts().ConsecutiveFailures != 0 {
+		t.Error("a 4xx response counted against the circuit breaker")
+	}
+}
+
+func TestDispatcherOpenBreakerDoesNotStarveOthers(t *testing.T) {
+	var mu sync.Mutex
+	delivered := 0
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		delivered++
+		mu.Unlock()
+	}))
+	defer up.Close()
+
+	d, err := New(&config.WebhooksConfig{
+		Enabled:        true,
+		QueuePath:      t.TempDir(),
+		BatchSize:      1,
+		CircuitBreaker: config.SpamTrainingCircuitBreakerConfig{Threshold: 1, Timeout: "1h"},
+		Endpoints: []config.WebhookEndpointConfig{
+			{Name: "down", URL: "http://127.0.0.1:1", Events: []string{"account.*"}},
+			{Name: "up", URL: up.URL, Events: []string{"mailbox.*"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	_, _ = d.endpoints["down"].breaker.Execute(func() (any, error) { return nil, errors.New("down") })
+
+	for i := 0; i < 20; i++ {
+		d.Emit(NewEvent(EventAccountCreated, "adminapi", int64(i), "user@example.com", nil))
+	}
+	d.Emit(NewEvent(EventMailboxCreated, "imap", 1, "user@example.com", nil))
+
+	// Each pass takes one due delivery; held-back ones must not come back.
+	for i := 0; i < 21; i++ {
+		d.processQueue(context.Background())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if delivered != 1 {
+		t.Errorf("healthy endpoint received %d deliveries, want 1", delivered)
+	}
+	if pending, _, _, _ := d.GetStats(); pending != 20 {
+		t.Errorf("pending = %d, want the 20 held-back deliveries", pending)
+	}
+}
+
+func TestNewRejectsUnknownFilter(t *testing.T) {
+	_, err := New(&config.WebhooksConfig{
+		Enabled:   true,
+		QueuePath: t.TempDir(),
+		Endpoints: []config.WebhookEndpointConfig{{Name: "x", URL: "https://example.com", Events: []string{"mesage.*"}}},
+	})
+	if err == nil {
+		t.Error("expected an error for an unknown event filter")
+	}
+
+	var nilDispatcher *Dispatcher
+	nilDispatcher.Emit(NewEvent(EventLoginFailed, "imap", 0, "", nil)) // must not panic
+}
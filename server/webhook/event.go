@@ -0,0 +1,109 @@
+// Package webhook delivers mailbox and account events to external HTTP
+// endpoints.
+//
+// Servers call Dispatcher.Emit when something happens (a message is
+// delivered, a mailbox is created, a login fails, ...). Emit writes one
+// delivery per matching endpoint to a disk queue and returns; a background
+// worker POSTs the JSON event to each endpoint, retrying with backoff and
+// protecting each endpoint with its own circuit breaker. Events survive
+// restarts, and are delivered at least once: receivers should deduplicate
+// on the event ID.
+//
+// # Signatures
+//
+// Every request carries
+//
+//	X-Sora-Event: message.delivered
+//	X-Sora-Delivery: <delivery id>
+//	X-Sora-Signature: t=<unix seconds>,v1=<hex HMAC-SHA256>
+//
+// where the HMAC is computed with the endpoint's secret over
+// "<unix seconds>.<request body>". Receivers should recompute it, compare in
+// constant time and reject stale timestamps; see Verify.
+package webhook
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types.
+const (
+	EventMessageDelivered = "message.delivered"
+	EventMessageExpunged  = "message.expunged"
+	EventMailboxCreated   = "mailbox.created"
+	EventMailboxDeleted   = "mailbox.deleted"
+	EventLoginSucceeded   = "login.succeeded"
+	EventLoginFailed      = "login.failed"
+	EventAccountCreated   = "account.created"
+	EventAccountDeleted   = "account.deleted"
+	EventVacationSent     = "vacation.sent"
+)
+
+// EventTypes lists every event type, for validation and documentation.
+var EventTypes = []string{
+	EventMessageDelivered,
+	EventMessageExpunged,
+	EventMailboxCreated,
+	EventMailboxDeleted,
+	EventLoginSucceeded,
+	EventLoginFailed,
+	EventAccountCreated,
+	EventAccountDeleted,
+	EventVacationSent,
+}
+
+// Event is the JSON body POSTed to endpoints.
+type Event struct {
+	ID        string         `json:"id"`
+	Type      string         `json:"type"`
+	Timestamp time.Time      `json:"timestamp"`
+	AccountID int64          `json:"account_id,omitempty"`
+	Email     string         `json:"email,omitempty"`
+	Source    string         `json:"source,omitempty"` // lmtp, imap, pop3, userapi, adminapi
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// NewEvent creates an event of the given type with a fresh ID.
+func NewEvent(eventType, source string, accountID int64, email string, data map[string]any) *Event {
+	return &Event{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		AccountID: accountID,
+		Email:     email,
+		Source:    source,
+		Data:      data,
+	}
+}
+
+// matchesFilter reports whether an endpoint with the given event filter
+// should receive eventType. An empty filter matches everything; "*" matches
+// everything and "message.*" every message event.
+func matchesFilter(filter []string, eventType string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, f := range filter {
+		switch {
+		case f == "*" || f == eventType:
+			return true
+		case strings.HasSuffix(f, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(f, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// validFilter reports whether f names an event type or a wildcard that can
+// match one, so typos in the configuration are caught at startup.
+func validFilter(f string) bool {
+	for _, t := range EventTypes {
+		if matchesFilter([]string{f}, t) {
+			return true
+		}
+	}
+	return false
+}
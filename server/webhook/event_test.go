@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchesFilter(t *testing.T) {
+	tests := []struct {
+		filter    []string
+		eventType string
+		want      bool
+	}{
+		{nil, EventMessageDelivered, true},
+		{[]string{"*"}, EventLoginFailed, true},
+		{[]string{EventLoginFailed}, EventLoginFailed, true},
+		{[]string{EventLoginFailed}, EventLoginSucceeded, false},
+		{[]string{"message.*"}, EventMessageExpunged, true},
+		{[]string{"message.*"}, EventMailboxCreated, false},
+		{[]string{"mailbox.*", EventVacationSent}, EventVacationSent, true},
+	}
+	for _, tt := range tests {
+		if got := matchesFilter(tt.filter, tt.eventType); got != tt.want {
+			t.Errorf("matchesFilter(%v, %q) = %v, want %v", tt.filter, tt.eventType, got, tt.want)
+		}
+	}
+
+	for _, f := range []string{"*", "account.*", EventMessageDelivered} {
+		if !validFilter(f) {
+			t.Errorf("validFilter(%q) = false", f)
+		}
+	}
+	for _, f := range []string{"message.deliverd", "acount.*", ""} {
+		if validFilter(f) {
+			t.Errorf("validFilter(%q) = true", f)
+		}
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":"1","type":"message.delivered"}`)
+	header := Sign("s3cret", time.Now(), body)
+
+	if err := Verify("s3cret", header, body, 5*time.Minute); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if err := Verify("other", header, body, 5*time.Minute); err == nil {
+		t.Error("expected mismatch with the wrong secret")
+	}
+	if err := Verify("s3cret", header, append(body, ' '), 5*time.Minute); err == nil {
+		t.Error("expected mismatch for a modified body")
+	}
+	if err := Verify("s3cret", "garbage", body, 0); err == nil {
+		t.Error("expected an error for a malformed header")
+	}
+
+	stale := Sign("s3cret", time.Now().Add(-time.Hour), body)
+	if err := Verify("s3cret", stale, body, 5*time.Minute); err == nil {
+		t.Error("expected a stale signature to be rejected")
+	}
+	if err := Verify("s3cret", stale, body, 0); err != nil {
+		t.Errorf("zero tolerance should skip the age check: %v", err)
+	}
+}
@@ -0,0 +1,330 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/migadu/sora/logger"
+)
+
+// Delivery is one event queued for one endpoint.
+type Delivery struct {
+	ID          string          `json:"id"`
+	Endpoint    string          `json:"endpoint"`     // Endpoint name
+	EventType   string          `json:"event_type"`   // Event type, for logs and stats
+	Payload     json.RawMessage `json:"payload"`      // Event JSON, sent as-is
+	QueuedAt    time.Time       `json:"queued_at"`    // When first queued
+	Attempts    int             `json:"attempts"`     // Number of delivery attempts
+	LastAttempt time.Time       `json:"last_attempt"` // Last attempt timestamp
+	NextRetry   time.Time       `json:"next_retry"`   // When to retry next
+	Errors      []string        `json:"errors"`       // Error history
+}
+
+// DiskQueue is a durable queue of webhook deliveries. Each delivery is a
+// JSON file that moves between the pending, processing and failed
+// directories, like the relay queue.
+type DiskQueue struct {
+	pendingDir    string
+	processingDir string
+	failedDir     string
+	maxAttempts   int
+	retryBackoff  []time.Duration
+	mu            sync.Mutex
+}
+
+// NewDiskQueue creates a delivery queue below basePath.
+func NewDiskQueue(basePath string, maxAttempts int, retryBackoff []time.Duration) (*DiskQueue, error) {
+	if basePath == "" {
+		return nil, fmt.Errorf("base path cannot be empty")
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+	if len(retryBackoff) == 0 {
+		retryBackoff = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, time.Hour}
+	}
+
+	q := &DiskQueue{
+		pendingDir:    filepath.Join(basePath, "pending"),
+		processingDir: filepath.Join(basePath, "processing"),
+		failedDir:     filepath.Join(basePath, "failed"),
+		maxAttempts:   maxAttempts,
+		retryBackoff:  retryBackoff,
+	}
+	for _, dir := range []string{q.pendingDir, q.processingDir, q.failedDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	return q, nil
+}
+
+// Enqueue adds a delivery of payload to the named endpoint.
+func (q *DiskQueue) Enqueue(endpoint, eventType string, payload []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	d := Delivery{
+		ID:        uuid.New().String(),
+		Endpoint:  endpoint,
+		EventType: eventType,
+		Payload:   payload,
+		QueuedAt:  now,
+		NextRetry: now,
+		Errors:    []string{},
+	}
+	if err := writeJSONAtomic(filepath.Join(q.pendingDir, d.ID+".json"), d); err != nil {
+		return fmt.Errorf("failed to write delivery: %w", err)
+	}
+	return nil
+}
+
+// AcquireNext moves the next delivery that is due to processing and returns
+// it, or nil if none is due.
+func (q *DiskQueue) AcquireNext() (*Delivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := os.ReadDir(q.pendingDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending directory: %w", err)
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		pendingPath := filepath.Join(q.pendingDir, entry.Name())
+		var d Delivery
+		if err := readJSON(pendingPath, &d); err != nil {
+			logger.Error("Webhook: Failed to read queued delivery", "entry", entry.Name(), "error", err)
+			continue
+		}
+		if now.Before(d.NextRetry) {
+			continue
+		}
+		if err := os.Rename(pendingPath, filepath.Join(q.processingDir, entry.Name())); err != nil {
+			logger.Error("Webhook: Failed to move delivery to processing", "id", d.ID, "error", err)
+			continue
+		}
+		return &d, nil
+	}
+	return nil, nil
+}
+
+// MarkSuccess removes a delivered item.
+func (q *DiskQueue) MarkSuccess(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := os.Remove(filepath.Join(q.processingDir, id+".json")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove delivery: %w", err)
+	}
+	return nil
+}
+
+// MarkFailure records a failed attempt and schedules a retry, or moves the
+// delivery to failed once it has used up its attempts.
+func (q *DiskQueue) MarkFailure(id, errorMsg string) error {
+	return q.markFailure(id, errorMsg, false)
+}
+
+// MarkPermanentFailure moves a delivery to failed without retrying, for
+// errors that a retry cannot fix.
+func (q *DiskQueue) MarkPermanentFailure(id, errorMsg string) error {
+	return q.markFailure(id, errorMsg, true)
+}
+
+func (q *DiskQueue) markFailure(id, errorMsg string, permanent bool) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	processingPath := filepath.Join(q.processingDir, id+".json")
+	var d Delivery
+	if err := readJSON(processingPath, &d); err != nil {
+		return fmt.Errorf("failed to read delivery: %w", err)
+	}
+
+	now := time.Now()
+	d.Attempts++
+	d.LastAttempt = now
+	if permanent {
+		errorMsg = "PERMANENT: " + errorMsg
+	}
+	d.Errors = append(d.Errors, fmt.Sprintf("[%s] %s", now.Format(time.RFC3339), errorMsg))
+
+	targetDir := q.pendingDir
+	if permanent || d.Attempts >= q.maxAttempts {
+		targetDir = q.failedDir
+		logger.Error("Webhook: Giving up on delivery", "id", id, "endpoint", d.Endpoint, "event", d.EventType, "attempts", d.Attempts, "error", errorMsg)
+	} else {
+		backoffIndex := d.Attempts - 1
+		if backoffIndex >= len(q.retryBackoff) {
+			backoffIndex = len(q.retryBackoff) - 1
+		}
+		d.NextRetry = now.Add(q.retryBackoff[backoffIndex])
+		logger.Warn("Webhook: Delivery failed, will retry", "id", id, "endpoint", d.Endpoint, "event", d.EventType,
+			"attempt", d.Attempts, "retry_at", d.NextRetry.Format(time.RFC3339), "error", errorMsg)
+	}
+
+	if err := writeJSONAtomic(filepath.Join(targetDir, id+".json"), d); err != nil {
+		return fmt.Errorf("failed to write delivery: %w", err)
+	}
+	os.Remove(processingPath)
+	return nil
+}
+
+// Release moves a delivery back to pending without counting an attempt, for
+// deliveries held back by an open circuit breaker. It becomes due again after
+// delay, so it is not picked up again while the breaker stays open.
+func (q *DiskQueue) Release(id string, delay time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	processingPath := filepath.Join(q.processingDir, id+".json")
+	var d Delivery
+	if err := readJSON(processingPath, &d); err != nil {
+		return fmt.Errorf("failed to read delivery: %w", err)
+	}
+	d.NextRetry = time.Now().Add(delay)
+	if err := writeJSONAtomic(filepath.Join(q.pendingDir, id+".json"), d); err != nil {
+		return fmt.Errorf("failed to release delivery: %w", err)
+	}
+	os.Remove(processingPath)
+	return nil
+}
+
+// RecoverOrphaned moves deliveries left in processing by a crash back to
+// pending.
+func (q *DiskQueue) RecoverOrphaned() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := os.ReadDir(q.processingDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read processing directory: %w", err)
+	}
+	recovered := 0
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		if err := os.Rename(filepath.Join(q.processingDir, entry.Name()), filepath.Join(q.pendingDir, entry.Name())); err != nil {
+			logger.Error("Webhook: Failed to recover orphaned delivery", "file", entry.Name(), "error", err)
+			continue
+		}
+		recovered++
+	}
+	return recovered, nil
+}
+
+// CleanupOldFailed removes failed deliveries whose last attempt is older
+// than retention. A zero retention keeps them forever.
+func (q *DiskQueue) CleanupOldFailed(retention time.Duration) (int, error) {
+	if retention == 0 {
+		return 0, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := os.ReadDir(q.failedDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read failed directory: %w", err)
+	}
+	cleaned := 0
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(q.failedDir, entry.Name())
+		var d Delivery
+		if err := readJSON(path, &d); err != nil {
+			logger.Error("Webhook: Failed to read failed delivery during cleanup", "file", entry.Name(), "error", err)
+			continue
+		}
+		if time.Since(d.LastAttempt) < retention {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logger.Error("Webhook: Failed to delete old failed delivery", "id", d.ID, "error", err)
+			continue
+		}
+		cleaned++
+	}
+	return cleaned, nil
+}
+
+// GetStats returns the number of deliveries in each state.
+func (q *DiskQueue) GetStats() (pending, processing, failed int, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if pending, err = countJSON(q.pendingDir); err != nil {
+		return 0, 0, 0, err
+	}
+	if processing, err = countJSON(q.processingDir); err != nil {
+		return 0, 0, 0, err
+	}
+	if failed, err = countJSON(q.failedDir); err != nil {
+		return 0, 0, 0, err
+	}
+	return pending, processing, failed, nil
+}
+
+// writeJSONAtomic writes v to path using a temp file and rename. The JSON is
+// compact so an already-compact Payload is stored, and later sent, unchanged.
+func writeJSONAtomic(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func countJSON(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
+			count++
+		}
+	}
+	return count, nil
+}
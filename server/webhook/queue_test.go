@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiskQueueRetryAndFail(t *testing.T) {
+	q, err := NewDiskQueue(t.TempDir(), 2, []time.Duration{0})
+	if err != nil {
+		t.Fatalf("NewDiskQueue failed: %v", err)
+	}
+	if err := q.Enqueue("crm", EventLoginFailed, []byte(`{"id":"1"}`)); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	d, err := q.AcquireNext()
+	if err != nil || d == nil {
+		t.Fatalf("AcquireNext = %v, %v", d, err)
+	}
+	if d.Endpoint != "crm" || string(d.Payload) != `{"id":"1"}` {
+		t.Errorf("unexpected delivery %+v", d)
+	}
+	if next, _ := q.AcquireNext(); next != nil {
+		t.Error("a delivery in processing was acquired twice")
+	}
+
+	// First failure is retried, the second exhausts max_attempts.
+	if err := q.MarkFailure(d.ID, "503"); err != nil {
+		t.Fatalf("MarkFailure failed: %v", err)
+	}
+	d, _ = q.AcquireNext()
+	if d == nil || d.Attempts != 1 {
+		t.Fatalf("expected a retry with one attempt, got %+v", d)
+	}
+	if err := q.MarkFailure(d.ID, "503"); err != nil {
+		t.Fatalf("MarkFailure failed: %v", err)
+	}
+	if pending, processing, failed, _ := q.GetStats(); pending != 0 || processing != 0 || failed != 1 {
+		t.Errorf("stats = %d/%d/%d, want 0/0/1", pending, processing, failed)
+	}
+
+	if n, _ := q.CleanupOldFailed(time.Nanosecond); n != 1 {
+		t.Errorf("CleanupOldFailed removed %d, want 1", n)
+	}
+}
+
+func TestDiskQueueReleaseAndRecover(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := NewDiskQueue(dir, 5, nil)
+	_ = q.Enqueue("a", EventAccountCreated, []byte(`{}`))
+	_ = q.Enqueue("b", EventAccountCreated, []byte(`{}`))
+
+	d, _ := q.AcquireNext()
+	if err := q.Release(d.ID, 0); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	// Simulate a crash with one delivery in processing.
+	if d, _ := q.AcquireNext(); d == nil {
+		t.Fatal("released delivery was not acquirable")
+	}
+
+	q2, _ := NewDiskQueue(dir, 5, nil)
+	if n, err := q2.RecoverOrphaned(); err != nil || n != 1 {
+		t.Errorf("RecoverOrphaned = %d, %v; want 1", n, err)
+	}
+	if pending, _, _, _ := q2.GetStats(); pending != 2 {
+		t.Errorf("pending = %d, want 2", pending)
+	}
+
+	// A permanent failure skips the remaining attempts.
+	d, _ = q2.AcquireNext()
+	if err := q2.MarkPermanentFailure(d.ID, "410 Gone"); err != nil {
+		t.Fatalf("MarkPermanentFailure failed: %v", err)
+	}
+	if _, _, failed, _ := q2.GetStats(); failed != 1 {
+		t.Errorf("failed = %d, want 1", failed)
+	}
+}
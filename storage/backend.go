@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/migadu/sora/config"
+)
+
+// Backend is an object store for message bodies. Keys have the layout
+// domain/localpart/hash (see helpers.NewS3Key) regardless of the
+// implementation, so the database never depends on the backend in use.
+type Backend interface {
+	Put(key string, body io.Reader, size int64) error
+	Get(key string) (io.ReadCloser, error)
+	// Exists reports whether key exists; the string is a version ID where the
+	// backend supports versioning.
+	Exists(key string) (bool, string, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(key string) error
+	// DeleteBulk deletes keys and returns the error for each key that failed.
+	DeleteBulk(keys []string) map[string]error
+	Copy(sourcePath, destPath string) error
+	ListObjects(ctx context.Context, prefix string, recursive bool) (<-chan S3Object, <-chan error)
+	// EnableEncryption turns on client-side AES-256-GCM encryption with a
+	// hex-encoded 32-byte key.
+	EnableEncryption(encryptionKey string) error
+	// EnableCompression compresses new objects with zstd. Compressed objects
+	// are always readable, whether or not it is enabled.
+	EnableCompression()
+	// Ping checks that the backend is reachable, for health checks.
+	Ping(ctx context.Context) error
+}
+
+var (
+	_ Backend = (*S3Storage)(nil)
+	_ Backend = (*FilesystemStorage)(nil)
+)
+
+// NewFromConfig creates the backend selected by cfg.Backend ("s3", the
+// default, or "filesystem") and enables compression and encryption if
+// configured.
+func NewFromConfig(cfg *config.S3Config) (Backend, error) {
+	var backend Backend
+	switch strings.ToLower(cfg.Backend) {
+	case "", "s3":
+		if cfg.Endpoint == "" {
+			return nil, fmt.Errorf("S3 endpoint not specified")
+		}
+		if cfg.AccessKey == "" || cfg.SecretKey == "" || cfg.Bucket == "" {
+			return nil, fmt.Errorf("missing required S3 credentials (access_key, secret_key, bucket)")
+		}
+		timeout, err := cfg.GetTimeout()
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3 timeout: %w", err)
+		}
+		s3, err := New(cfg.Endpoint, cfg.AccessKey, cfg.SecretKey, cfg.Bucket, !cfg.DisableTLS, cfg.GetDebug(), timeout)
+		if err != nil {
+			return nil, err
+		}
+		backend = s3
+	case "filesystem":
+		fs, err := NewFilesystem(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		backend = fs
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q (expected \"s3\" or \"filesystem\")", cfg.Backend)
+	}
+
+	if cfg.Compress {
+		backend.EnableCompression()
+	}
+	if cfg.Encrypt {
+		if err := backend.EnableEncryption(cfg.EncryptionKey); err != nil {
+			return nil, err
+		}
+	}
+	return backend, nil
+}
@@ -12,3 +12,7 @@ var (
 	// ErrEmptyData indicates that storage returned empty data
 	ErrEmptyData = errors.New("storage returned empty data")
 )
+
+// ErrNotFound indicates that no object exists under the requested key.
+// Returned by backends that have no native not-found error (FilesystemStorage).
+var ErrNotFound = errors.New("object not found")
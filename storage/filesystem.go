@@ -0,0 +1,365 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/migadu/sora/helpers"
+	"github.com/migadu/sora/logger"
+)
+
+// tempFilePrefix marks files being written; they are never listed and are
+// renamed into place only once fully written and synced.
+const tempFilePrefix = ".tmp-"
+
+// compressedSuffix marks the file of a zstd-compressed object, the
+// counterpart of the S3 CompressionMetadataKey.
+const compressedSuffix = ".zst"
+
+// FilesystemStorage stores objects as files below a root directory, for
+// deployments without S3: a local disk, or an NFS mount shared by all nodes.
+//
+// An object key domain/localpart/hash is stored at
+// <root>/domain/localpart/<hash[:2]>/hash; the extra directory level keeps
+// mailboxes with millions of messages from producing huge directories.
+// Writes go to a temporary file that is synced and then renamed, so readers
+// never see a partially written object.
+//
+// A compressed object's file name carries compressedSuffix; Get decompresses
+// only those files, whatever the stored bytes look like.
+type FilesystemStorage struct {
+	Root          string
+	Encrypt       bool
+	EncryptionKey []byte
+	Compress      bool
+}
+
+// NewFilesystem creates filesystem storage rooted at root, creating the
+// directory if needed.
+func NewFilesystem(root string) (*FilesystemStorage, error) {
+	if root == "" {
+		return nil, fmt.Errorf("filesystem storage path not specified")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filesystem storage path %q: %w", root, err)
+	}
+	if err := os.MkdirAll(abs, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create filesystem storage directory: %w", err)
+	}
+
+	logger.Info("STORAGE: Initialized filesystem object storage", "path", abs)
+	return &FilesystemStorage{Root: abs}, nil
+}
+
+// EnableEncryption enables client-side encryption for filesystem storage
+func (s *FilesystemStorage) EnableEncryption(encryptionKey string) error {
+	masterKey, err := parseEncryptionKey(encryptionKey)
+	if err != nil {
+		return err
+	}
+	s.Encrypt = true
+	s.EncryptionKey = masterKey
+	logger.Info("STORAGE: Client-side encryption enabled")
+	return nil
+}
+
+// EnableCompression compresses new objects with zstd.
+func (s *FilesystemStorage) EnableCompression() {
+	s.Compress = true
+	logger.Info("STORAGE: zstd compression enabled")
+}
+
+// objectPath maps a key to its file, rejecting keys that would escape Root.
+func (s *FilesystemStorage) objectPath(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	dir, base := path.Split(key)
+	if strings.HasPrefix(base, tempFilePrefix) || strings.HasSuffix(base, compressedSuffix) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	if len(base) >= 4 {
+		dir = path.Join(dir, base[:2])
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(dir), base), nil
+}
+
+// Path returns the file that holds key, for tools that inspect stored
+// objects directly. A compressed object's file is preferred if it exists.
+func (s *FilesystemStorage) Path(key string) (string, error) {
+	p, err := s.objectPath(key)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(p + compressedSuffix); err == nil {
+		return p + compressedSuffix, nil
+	}
+	return p, nil
+}
+
+// objectKey is the inverse of objectPath for a path relative to Root.
+func objectKey(rel string) string {
+	rel = filepath.ToSlash(rel)
+	dir, base := path.Split(rel)
+	dir = strings.TrimSuffix(dir, "/")
+	if len(base) >= 4 && path.Base(dir) == base[:2] {
+		return path.Join(path.Dir(dir), base)
+	}
+	return rel
+}
+
+// Exists checks if an object with the given key exists.
+func (s *FilesystemStorage) Exists(key string) (bool, string, error) {
+	p, err := s.objectPath(key)
+	if err != nil {
+		return false, "", err
+	}
+	for _, name := range []string{p + compressedSuffix, p} {
+		_, err := os.Stat(name)
+		if err == nil {
+			return true, "", nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return false, "", fmt.Errorf("failed to stat object %s: %w", key, err)
+		}
+	}
+	return false, "", nil
+}
+
+func (s *FilesystemStorage) Put(key string, body io.Reader, size int64) error {
+	p, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+
+	// Bodies that do not shrink are stored uncompressed, without the suffix.
+	target, other := p, p+compressedSuffix
+	if s.Compress || s.Encrypt {
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("failed to read object data: %w", err)
+		}
+		if s.Compress {
+			if compressed := helpers.CompressZstd(data); len(compressed) < len(data) {
+				data = compressed
+				target, other = other, target
+			}
+		}
+		if s.Encrypt {
+			if data, err = encryptData(s.EncryptionKey, data); err != nil {
+				return fmt.Errorf("failed to encrypt data: %w", err)
+			}
+		}
+		body = bytes.NewReader(data)
+	}
+
+	if err := writeFileAtomic(target, body); err != nil {
+		return err
+	}
+	// Drop a copy in the other form left by an earlier Put of the same key.
+	if err := os.Remove(other); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove previous object: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes r to p via a synced temporary file in the same
+// directory, renames it into place and syncs the directory.
+func writeFileAtomic(p string, r io.Reader) error {
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, tempFilePrefix+"*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpName := tmp.Name()
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close object: %w", err)
+	}
+	if err := os.Rename(tmpName, p); err != nil {
+		return fmt.Errorf("failed to move object into place: %w", err)
+	}
+	committed = true
+
+	// Persist the rename itself.
+	if d, err := os.Open(dir); err == nil {
+		if err := d.Sync(); err != nil {
+			logger.Debug("STORAGE: Failed to sync object directory", "dir", dir, "error", err)
+		}
+		d.Close()
+	}
+	return nil
+}
+
+func (s *FilesystemStorage) Get(key string) (io.ReadCloser, error) {
+	p, err := s.objectPath(key)
+	if err != nil {
+		return nil, err
+	}
+	compressed := true
+	f, err := os.Open(p + compressedSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		compressed = false
+		f, err = os.Open(p)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		}
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+	if s.Encrypt {
+		decryptedData, err := decryptData(s.EncryptionKey, data)
+		if err != nil {
+			logger.Error("Storage: Decryption failed", "key", key, "encrypted_size", len(data), "error", err)
+			return nil, fmt.Errorf("failed to decrypt data: %w", err)
+		}
+		data = decryptedData
+	}
+	if compressed {
+		if data, err = decompressObject(key, data); err != nil {
+			return nil, err
+		}
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *FilesystemStorage) Delete(key string) error {
+	p, err := s.objectPath(key)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{p + compressedSuffix, p} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteBulk deletes multiple objects and returns a map of key -> error for
+// any objects that failed to delete.
+func (s *FilesystemStorage) DeleteBulk(keys []string) map[string]error {
+	errs := make(map[string]error)
+	for _, key := range keys {
+		if err := s.Delete(key); err != nil {
+			errs[key] = err
+		}
+	}
+	return errs
+}
+
+func (s *FilesystemStorage) Copy(sourcePath, destPath string) error {
+	src, err := s.Get(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to get source object for copy: %w", err)
+	}
+	defer src.Close()
+
+	// Get decrypts and Put re-encrypts, so each object keeps its own nonce.
+	if err := s.Put(destPath, src, -1); err != nil {
+		return fmt.Errorf("failed to copy object from %s to %s: %w", sourcePath, destPath, err)
+	}
+	return nil
+}
+
+// ListObjects lists objects whose key starts with prefix. Without recursive,
+// only objects directly below prefix are listed, as with an S3 delimiter.
+func (s *FilesystemStorage) ListObjects(ctx context.Context, prefix string, recursive bool) (<-chan S3Object, <-chan error) {
+	objectCh := make(chan S3Object)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(objectCh)
+		defer close(errCh)
+
+		// Walk from the deepest directory the prefix fully names.
+		start := s.Root
+		if i := strings.LastIndex(prefix, "/"); i >= 0 {
+			start = filepath.Join(s.Root, filepath.FromSlash(prefix[:i]))
+		}
+
+		err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) && p == start {
+					return filepath.SkipAll
+				}
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if d.IsDir() || strings.HasPrefix(d.Name(), tempFilePrefix) {
+				return nil
+			}
+			rel, err := filepath.Rel(s.Root, p)
+			if err != nil {
+				return err
+			}
+			key := objectKey(strings.TrimSuffix(rel, compressedSuffix))
+			if !strings.HasPrefix(key, prefix) {
+				return nil
+			}
+			if !recursive && strings.Contains(key[len(prefix):], "/") {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			select {
+			case objectCh <- S3Object{Key: key, Size: info.Size(), LastModified: info.ModTime()}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		})
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	return objectCh, errCh
+}
+
+// Ping checks that the root directory is writable.
+func (s *FilesystemStorage) Ping(ctx context.Context) error {
+	f, err := os.CreateTemp(s.Root, tempFilePrefix+"ping-*")
+	if err != nil {
+		return fmt.Errorf("filesystem storage not writable: %w", err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/migadu/sora/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEncryptionKey = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func putString(t *testing.T, s Backend, key, body string) {
+	t.Helper()
+	require.NoError(t, s.Put(key, strings.NewReader(body), int64(len(body))))
+}
+
+func getString(t *testing.T, s Backend, key string) string {
+	t.Helper()
+	r, err := s.Get(key)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestFilesystemStorage_PutGet(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewFilesystem(root)
+	require.NoError(t, err)
+
+	putString(t, s, "example.com/user1/abcdef123456", "hello")
+	assert.Equal(t, "hello", getString(t, s, "example.com/user1/abcdef123456"))
+
+	// Objects are sharded by the first two characters of the hash.
+	_, err = os.Stat(filepath.Join(root, "example.com", "user1", "ab", "abcdef123456"))
+	assert.NoError(t, err)
+
+	exists, _, err := s.Exists("example.com/user1/abcdef123456")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Overwriting replaces the object.
+	putString(t, s, "example.com/user1/abcdef123456", "world")
+	assert.Equal(t, "world", getString(t, s, "example.com/user1/abcdef123456"))
+}
+
+func TestFilesystemStorage_Encryption(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewFilesystem(root)
+	require.NoError(t, err)
+	require.NoError(t, s.EnableEncryption(testEncryptionKey))
+
+	putString(t, s, "example.com/user1/abcdef123456", "secret message")
+	assert.Equal(t, "secret message", getString(t, s, "example.com/user1/abcdef123456"))
+
+	raw, err := os.ReadFile(filepath.Join(root, "example.com", "user1", "ab", "abcdef123456"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret message")
+
+	require.NoError(t, s.Copy("example.com/user1/abcdef123456", "example.com/user2/abcdef123456"))
+	assert.Equal(t, "secret message", getString(t, s, "example.com/user2/abcdef123456"))
+
+	assert.Error(t, s.EnableEncryption("tooshort"))
+}
+
+func TestFilesystemStorage_Missing(t *testing.T) {
+	s, err := NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = s.Get("example.com/user1/abcdef123456")
+	assert.True(t, errors.Is(err, ErrNotFound), "expected ErrNotFound, got %v", err)
+
+	exists, _, err := s.Exists("example.com/user1/abcdef123456")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Deleting a missing object is not an error.
+	assert.NoError(t, s.Delete("example.com/user1/abcdef123456"))
+}
+
+func TestFilesystemStorage_DeleteBulk(t *testing.T) {
+	s, err := NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+
+	putString(t, s, "example.com/user1/aaaa1111", "a")
+	putString(t, s, "example.com/user1/bbbb2222", "b")
+
+	errs := s.DeleteBulk([]string{"example.com/user1/aaaa1111", "example.com/user1/bbbb2222", "../escape"})
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs, "../escape")
+
+	exists, _, err := s.Exists("example.com/user1/aaaa1111")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestFilesystemStorage_InvalidKeys(t *testing.T) {
+	s, err := NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b", "a/.tmp-123", "a/abc.zst"} {
+		err := s.Put(key, strings.NewReader("x"), 1)
+		assert.Error(t, err, "key %q should be rejected", key)
+	}
+}
+
+func TestFilesystemStorage_Compression(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewFilesystem(root)
+	require.NoError(t, err)
+
+	body := strings.Repeat("Subject: hello\r\n\r\nThe quick brown fox jumps over the lazy dog.\r\n", 50)
+	putString(t, s, "example.com/user1/plain0000", body)
+
+	s.EnableCompression()
+	require.NoError(t, s.EnableEncryption(testEncryptionKey))
+	putString(t, s, "example.com/user1/abcdef123456", body)
+
+	raw, err := os.ReadFile(filepath.Join(root, "example.com", "user1", "ab", "abcdef123456.zst"))
+	require.NoError(t, err)
+	assert.Less(t, len(raw), len(body))
+	assert.Equal(t, body, getString(t, s, "example.com/user1/abcdef123456"))
+	assert.Equal(t, []string{"example.com/user1/abcdef123456", "example.com/user1/plain0000"},
+		collectKeys(t, s, "example.com/user1/", true))
+
+	// Objects written before compression was enabled stay readable.
+	s.Encrypt = false
+	assert.Equal(t, body, getString(t, s, "example.com/user1/plain0000"))
+
+	require.NoError(t, s.Delete("example.com/user1/abcdef123456"))
+	exists, _, err := s.Exists("example.com/user1/abcdef123456")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestFilesystemStorage_ZstdMagicBody(t *testing.T) {
+	// A body that merely starts like a zstd frame must come back unchanged.
+	body := "\x28\xb5\x2f\xfd not really zstd"
+	for _, compress := range []bool{false, true} {
+		s, err := NewFilesystem(t.TempDir())
+		require.NoError(t, err)
+		if compress {
+			s.EnableCompression()
+		}
+		putString(t, s, "example.com/user1/abcdef123456", body)
+		assert.Equal(t, body, getString(t, s, "example.com/user1/abcdef123456"), "compress=%v", compress)
+	}
+}
+
+func collectKeys(t *testing.T, s Backend, prefix string, recursive bool) []string {
+	t.Helper()
+	objectCh, errCh := s.ListObjects(context.Background(), prefix, recursive)
+	var keys []string
+	for obj := range objectCh {
+		keys = append(keys, obj.Key)
+	}
+	require.NoError(t, <-errCh)
+	sort.Strings(keys)
+	return keys
+}
+
+func TestFilesystemStorage_ListObjects(t *testing.T) {
+	s, err := NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+
+	putString(t, s, "example.com/user1/aaaa1111", "1")
+	putString(t, s, "example.com/user1/bbbb2222", "2")
+	putString(t, s, "example.com/user2/cccc3333", "3")
+	putString(t, s, "other.org/user/dd", "4") // too short to shard
+
+	assert.Equal(t, []string{"example.com/user1/aaaa1111", "example.com/user1/bbbb2222"},
+		collectKeys(t, s, "example.com/user1/", false))
+	assert.Equal(t, []string{"example.com/user1/aaaa1111", "example.com/user1/bbbb2222", "example.com/user2/cccc3333"},
+		collectKeys(t, s, "example.com/", true))
+	assert.Empty(t, collectKeys(t, s, "example.com/", false))
+	assert.Equal(t, []string{"example.com/user2/cccc3333"}, collectKeys(t, s, "example.com/user2", true))
+	assert.Equal(t, []string{"other.org/user/dd"}, collectKeys(t, s, "other.org/user/", false))
+	assert.Len(t, collectKeys(t, s, "", true), 4)
+	assert.Empty(t, collectKeys(t, s, "missing.net/", true))
+}
+
+func TestFilesystemStorage_Ping(t *testing.T) {
+	s, err := NewFilesystem(t.TempDir())
+	require.NoError(t, err)
+	assert.NoError(t, s.Ping(context.Background()))
+}
+
+func TestNewFromConfig(t *testing.T) {
+	root := t.TempDir()
+	backend, err := NewFromConfig(&config.S3Config{Backend: "filesystem", Path: root, Encrypt: true, EncryptionKey: testEncryptionKey})
+	require.NoError(t, err)
+	fsStorage, ok := backend.(*FilesystemStorage)
+	require.True(t, ok)
+	assert.True(t, fsStorage.Encrypt)
+	assert.Equal(t, root, fsStorage.Root)
+
+	_, err = NewFromConfig(&config.S3Config{Backend: "filesystem"})
+	assert.Error(t, err, "filesystem backend requires a path")
+
+	_, err = NewFromConfig(&config.S3Config{Backend: "gcs"})
+	assert.Error(t, err)
+
+	_, err = NewFromConfig(&config.S3Config{Endpoint: "s3.example.com"})
+	assert.Error(t, err, "s3 backend requires credentials")
+}
@@ -1,7 +1,13 @@
-// Package storage provides S3-compatible object storage for email message bodies.
+// Package storage provides object storage for email message bodies.
+//
+// Bodies are stored through the Backend interface, implemented by S3Storage
+// for S3-compatible object stores and by FilesystemStorage for a local or
+// NFS-mounted directory. The backend is selected with [s3] backend in
+// config.toml (see NewFromConfig).
 //
 // This package implements message body storage with features including:
 //   - Client-side AES-256-GCM encryption
+//   - Optional zstd compression
 //   - Content deduplication using BLAKE3 hashes
 //   - Circuit breaker for resilience
 //   - Automatic retry with exponential backoff
@@ -13,6 +19,14 @@
 // Each message is identified by its BLAKE3 hash, enabling automatic
 // deduplication when the same message is delivered to multiple recipients.
 //
+// # Compression
+//
+// With [s3] compress enabled, bodies are compressed with zstd before
+// encryption and marked with the CompressionMetadataKey object metadata (S3)
+// or a ".zst" file name suffix (filesystem). Get decompresses marked objects
+// regardless of the setting, so enabling or disabling compression never makes
+// existing objects unreadable. Existing objects can be compressed with "sora-admin storage recompress".
+//
 // # Encryption
 //
 // When encryption is enabled, messages are encrypted client-side using
@@ -82,15 +96,25 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
+	"github.com/migadu/sora/helpers"
 	"github.com/migadu/sora/logger"
 	"github.com/migadu/sora/pkg/metrics"
 )
 
+// CompressionMetadataKey is the object metadata key that marks a body as
+// compressed, and CompressionZstd its only value. Objects without it are
+// stored as-is, so compressed and older objects can live side by side.
+const (
+	CompressionMetadataKey = "sora-compression"
+	CompressionZstd        = "zstd"
+)
+
 type S3Storage struct {
 	Client        *s3.Client
 	BucketName    string
 	Encrypt       bool
 	EncryptionKey []byte
+	Compress      bool          // Compress bodies with zstd before upload
 	Timeout       time.Duration // Timeout for individual S3 operations
 }
 
@@ -157,25 +181,137 @@ func New(endpoint, accessKeyID, secretAccessKey, bucketName string, useSSL bool,
 
 // EnableEncryption enables client-side encryption for S3 storage
 func (s *S3Storage) EnableEncryption(encryptionKey string) error {
+	masterKey, err := parseEncryptionKey(encryptionKey)
+	if err != nil {
+		return err
+	}
+
+	s.Encrypt = true
+	s.EncryptionKey = masterKey
+	logger.Info("STORAGE: Client-side encryption enabled")
+
+	return nil
+}
+
+// EnableCompression compresses new uploads with zstd. Reading compressed
+// objects does not depend on it.
+func (s *S3Storage) EnableCompression() {
+	s.Compress = true
+	logger.Info("STORAGE: zstd compression enabled")
+}
+
+// IsCompressed reports whether the stored object is zstd-compressed.
+func (s *S3Storage) IsCompressed(key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	result, err := s.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to stat object %s: %w", key, err)
+	}
+	return result.Metadata[CompressionMetadataKey] == CompressionZstd, nil
+}
+
+// RecompressResult describes what Recompress did with one object.
+type RecompressResult struct {
+	AlreadyCompressed bool
+	Size              int64 // Uncompressed body size; 0 if already compressed
+	CompressedSize    int64 // Compressed body size; 0 if left uncompressed
+}
+
+// Recompress rewrites an uncompressed object in compressed form, whether or
+// not compression is enabled for new uploads. Objects that are already
+// compressed, or that would not shrink, are left untouched. The content, and
+// therefore the key, does not change.
+func (s *S3Storage) Recompress(key string) (RecompressResult, error) {
+	var result RecompressResult
+	compressed, err := s.IsCompressed(key)
+	if err != nil {
+		return result, err
+	}
+	if compressed {
+		result.AlreadyCompressed = true
+		return result, nil
+	}
+
+	reader, err := s.Get(key)
+	if err != nil {
+		return result, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return result, fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	result.Size = int64(len(data))
+
+	body := helpers.CompressZstd(data)
+	if len(body) >= len(data) {
+		return result, nil
+	}
+	result.CompressedSize = int64(len(body))
+
+	if s.Encrypt {
+		if body, err = encryptData(s.EncryptionKey, body); err != nil {
+			return result, fmt.Errorf("failed to encrypt data: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	_, err = s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(s.BucketName),
+		Key:      aws.String(key),
+		Body:     bytes.NewReader(body),
+		Metadata: map[string]string{CompressionMetadataKey: CompressionZstd},
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to upload compressed object %s: %w", key, err)
+	}
+	return result, nil
+}
+
+// decompressObject decodes a body marked as compressed.
+func decompressObject(key string, data []byte) ([]byte, error) {
+	decompressed, err := helpers.DecompressZstd(data)
+	if err != nil {
+		logger.Error("Storage: Decompression failed", "key", key, "compressed_size", len(data), "error", err)
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+	return decompressed, nil
+}
+
+// parseEncryptionKey decodes a hex-encoded 32-byte (AES-256) master key.
+func parseEncryptionKey(encryptionKey string) ([]byte, error) {
 	if encryptionKey == "" {
-		return fmt.Errorf("encryption key is required when encryption is enabled")
+		return nil, fmt.Errorf("encryption key is required when encryption is enabled")
 	}
 
 	// Decode the hex-encoded encryption key
 	masterKey, err := hex.DecodeString(encryptionKey)
 	if err != nil {
-		return fmt.Errorf("failed to decode encryption key: %w", err)
+		return nil, fmt.Errorf("failed to decode encryption key: %w", err)
 	}
 
 	// Check if the key is 32 bytes (256 bits)
 	if len(masterKey) != 32 {
-		return fmt.Errorf("encryption key must be 32 bytes (64 hex characters)")
+		return nil, fmt.Errorf("encryption key must be 32 bytes (64 hex characters)")
 	}
+	return masterKey, nil
+}
 
-	s.Encrypt = true
-	s.EncryptionKey = masterKey
-	logger.Info("STORAGE: Client-side encryption enabled")
-
+// Ping checks that the bucket is reachable by listing at most one object.
+func (s *S3Storage) Ping(ctx context.Context) error {
+	_, err := s.Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.BucketName),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return fmt.Errorf("S3 list objects failed: %w", err)
+	}
 	return nil
 }
 
@@ -215,57 +351,56 @@ func (s *S3Storage) Put(key string, body io.Reader, size int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
 	defer cancel()
 
-	// If encryption is enabled, encrypt the data before uploading
-	if s.Encrypt {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.BucketName),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+
+	// Compression and encryption need the whole body; otherwise it is
+	// uploaded as-is.
+	if s.Compress || s.Encrypt {
 		data, err := io.ReadAll(body)
 		if err != nil {
 			metrics.StorageOperationErrors.WithLabelValues("PUT", "read_error").Inc()
-			return fmt.Errorf("failed to read data for encryption: %w", err)
+			return fmt.Errorf("failed to read object data: %w", err)
 		}
 
-		encryptedData, err := s.encryptData(data)
-		if err != nil {
-			metrics.StorageOperationErrors.WithLabelValues("PUT", "encryption_error").Inc()
-			return fmt.Errorf("failed to encrypt data: %w", err)
-		}
-
-		input := &s3.PutObjectInput{
-			Bucket: aws.String(s.BucketName),
-			Key:    aws.String(key),
-			Body:   bytes.NewReader(encryptedData),
+		// Compress before encrypting; ciphertext does not compress. Bodies that
+		// do not shrink are stored uncompressed, without the marker.
+		if s.Compress {
+			if compressed := helpers.CompressZstd(data); len(compressed) < len(data) {
+				data = compressed
+				input.Metadata = map[string]string{CompressionMetadataKey: CompressionZstd}
+			}
 		}
 
-		_, err = s.Client.PutObject(ctx, input)
-		if err != nil {
-			metrics.StorageOperationErrors.WithLabelValues("PUT", classifyS3Error(err)).Inc()
+		if s.Encrypt {
+			data, err = encryptData(s.EncryptionKey, data)
+			if err != nil {
+				metrics.StorageOperationErrors.WithLabelValues("PUT", "encryption_error").Inc()
+				return fmt.Errorf("failed to encrypt data: %w", err)
+			}
 		}
-		// sora_s3_operations_total (the error-rate counter) is recorded once per
-		// logical operation by the resilient layer (resilient.RecordS3Operation)
-		// after retries settle, so it is intentionally not incremented per attempt
-		// here. Duration stays per attempt as a true per-call latency sample.
-		metrics.S3OperationDuration.WithLabelValues("PUT").Observe(time.Since(start).Seconds())
-		return err
-	}
-
-	// No encryption, upload as-is
-	input := &s3.PutObjectInput{
-		Bucket: aws.String(s.BucketName),
-		Key:    aws.String(key),
-		Body:   body,
+		input.Body = bytes.NewReader(data)
 	}
 
 	_, err := s.Client.PutObject(ctx, input)
 	if err != nil {
 		metrics.StorageOperationErrors.WithLabelValues("PUT", classifyS3Error(err)).Inc()
 	}
+	// sora_s3_operations_total (the error-rate counter) is recorded once per
+	// logical operation by the resilient layer (resilient.RecordS3Operation)
+	// after retries settle, so it is intentionally not incremented per attempt
+	// here. Duration stays per attempt as a true per-call latency sample.
 	metrics.S3OperationDuration.WithLabelValues("PUT").Observe(time.Since(start).Seconds())
 	return err
 }
 
 // encryptData encrypts data using AES-256-GCM
-func (s *S3Storage) encryptData(plaintext []byte) ([]byte, error) {
+func encryptData(key, plaintext []byte) ([]byte, error) {
 	// Create a new AES cipher block using the key
-	block, err := aes.NewCipher(s.EncryptionKey)
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
@@ -288,9 +423,9 @@ func (s *S3Storage) encryptData(plaintext []byte) ([]byte, error) {
 }
 
 // decryptData decrypts data using AES-256-GCM
-func (s *S3Storage) decryptData(ciphertext []byte) ([]byte, error) {
+func decryptData(key, ciphertext []byte) ([]byte, error) {
 	// Create a new AES cipher block using the key
-	block, err := aes.NewCipher(s.EncryptionKey)
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
@@ -330,6 +465,8 @@ func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
 		return nil, err
 	}
 
+	compressed := result.Metadata[CompressionMetadataKey] == CompressionZstd
+
 	// If encryption is enabled, decrypt the data after downloading
 	if s.Encrypt {
 		// Encrypted path: read entire body within the timeout, then cancel
@@ -348,7 +485,7 @@ func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
 			logger.Warn("Storage: Failed to close S3 object", "error", err)
 		}
 
-		decryptedData, err := s.decryptData(encryptedData)
+		decryptedData, err := decryptData(s.EncryptionKey, encryptedData)
 		if err != nil {
 			metrics.S3OperationDuration.WithLabelValues("GET").Observe(time.Since(start).Seconds())
 			logger.Error("Storage: Decryption failed", "key", key, "encrypted_size", len(encryptedData), "error", err)
@@ -362,11 +499,35 @@ func (s *S3Storage) Get(key string) (io.ReadCloser, error) {
 			logger.Debug("Storage: Successfully decrypted data", "key", key, "decrypted_size", len(decryptedData))
 		}
 
+		if compressed {
+			decryptedData, err = decompressObject(key, decryptedData)
+			if err != nil {
+				metrics.S3OperationDuration.WithLabelValues("GET").Observe(time.Since(start).Seconds())
+				return nil, err
+			}
+		}
+
 		metrics.S3OperationDuration.WithLabelValues("GET").Observe(time.Since(start).Seconds())
 		return io.NopCloser(bytes.NewReader(decryptedData)), nil
 	}
 
-	// Non-encrypted path: body streams from the HTTP connection.
+	if compressed {
+		// Compressed path: a zstd frame is decoded as a whole.
+		compressedData, err := io.ReadAll(result.Body)
+		cancel()
+		result.Body.Close()
+		metrics.S3OperationDuration.WithLabelValues("GET").Observe(time.Since(start).Seconds())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read compressed data: %w", err)
+		}
+		data, err := decompressObject(key, compressedData)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	// Plain path: body streams from the HTTP connection.
 	// The timeout context must stay alive until the caller finishes reading,
 	// so we wrap the body to cancel the context on Close().
 	metrics.S3OperationDuration.WithLabelValues("GET").Observe(time.Since(start).Seconds())
@@ -581,6 +742,31 @@ type S3Object struct {
 
 // ListObjects lists objects in S3 with the given prefix
 func (s *S3Storage) ListObjects(ctx context.Context, prefix string, recursive bool) (<-chan S3Object, <-chan error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.BucketName),
+		Prefix: aws.String(prefix),
+	}
+	if !recursive {
+		input.Delimiter = aws.String("/")
+	}
+	return s.listObjects(ctx, input)
+}
+
+// ListObjectsAfter recursively lists objects with the given prefix whose keys
+// sort after startAfter, in key order, so long scans can resume where they
+// stopped.
+func (s *S3Storage) ListObjectsAfter(ctx context.Context, prefix, startAfter string) (<-chan S3Object, <-chan error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.BucketName),
+		Prefix: aws.String(prefix),
+	}
+	if startAfter != "" {
+		input.StartAfter = aws.String(startAfter)
+	}
+	return s.listObjects(ctx, input)
+}
+
+func (s *S3Storage) listObjects(ctx context.Context, input *s3.ListObjectsV2Input) (<-chan S3Object, <-chan error) {
 	objectCh := make(chan S3Object)
 	errCh := make(chan error, 1)
 
@@ -588,15 +774,6 @@ func (s *S3Storage) ListObjects(ctx context.Context, prefix string, recursive bo
 		defer close(objectCh)
 		defer close(errCh)
 
-		input := &s3.ListObjectsV2Input{
-			Bucket: aws.String(s.BucketName),
-			Prefix: aws.String(prefix),
-		}
-
-		if !recursive {
-			input.Delimiter = aws.String("/")
-		}
-
 		paginator := s3.NewListObjectsV2Paginator(s.Client, input)
 
 		for paginator.HasMorePages() {
@@ -607,11 +784,16 @@ func (s *S3Storage) ListObjects(ctx context.Context, prefix string, recursive bo
 			}
 
 			for _, object := range page.Contents {
-				objectCh <- S3Object{
+				select {
+				case objectCh <- S3Object{
 					Key:          aws.ToString(object.Key),
 					Size:         aws.ToInt64(object.Size),
 					LastModified: aws.ToTime(object.LastModified),
 					ETag:         strings.Trim(aws.ToString(object.ETag), "\""),
+				}:
+				case <-ctx.Done():
+					errCh <- ctx.Err()
+					return
 				}
 			}
 		}